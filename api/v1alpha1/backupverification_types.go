@@ -0,0 +1,185 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VerificationMode selects how the snapshot is compared with the source.
+// +kubebuilder:validation:Enum=Metadata;Content
+type VerificationMode string
+
+const (
+	// VerificationModeMetadata compares the files by size and modification time, like
+	// restic does to find changed files, and reads only files that changed.
+	VerificationModeMetadata VerificationMode = "Metadata"
+	// VerificationModeContent reads all source files and compares their content with
+	// the data in the repository.
+	VerificationModeContent VerificationMode = "Content"
+)
+
+// BackupVerificationSpec defines the desired state of BackupVerification.
+type BackupVerificationSpec struct {
+	// BackupName is the name of the ResticBackup in the same namespace whose latest
+	// snapshot is verified. The backup must have a PVC source, which the verification
+	// job mounts read-only.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	BackupName string `json:"backupName"`
+
+	// Schedule is the cron schedule of the verification.
+	// +kubebuilder:validation:Required
+	Schedule string `json:"schedule"`
+
+	// Timezone for schedule interpretation. Defaults to UTC.
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// Mode selects how the snapshot is compared with the source.
+	// +kubebuilder:default=Metadata
+	// +optional
+	Mode VerificationMode `json:"mode,omitempty"`
+
+	// MinSizePercent is the minimum size of the snapshot relative to the source. The
+	// verification fails if the snapshot is smaller.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=50
+	// +optional
+	MinSizePercent *int32 `json:"minSizePercent,omitempty"`
+
+	// MinFileCountPercent is the minimum number of files of the snapshot relative to
+	// the source. The verification fails if the snapshot has fewer files.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=50
+	// +optional
+	MinFileCountPercent *int32 `json:"minFileCountPercent,omitempty"`
+
+	// Image is the restic container image. Defaults to the image of the backup.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// JobConfig configures the verification job. Defaults to the jobConfig of the backup.
+	// +optional
+	JobConfig *JobConfiguration `json:"jobConfig,omitempty"`
+
+	// Suspend suspends verification scheduling.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// VerificationDrift compares the latest snapshot with the source.
+type VerificationDrift struct {
+	// SnapshotID is the ID of the verified snapshot.
+	// +optional
+	SnapshotID string `json:"snapshotID,omitempty"`
+
+	// SnapshotFiles is the number of files in the snapshot.
+	SnapshotFiles int64 `json:"snapshotFiles"`
+
+	// SnapshotSize is the size of the files in the snapshot.
+	// +optional
+	SnapshotSize string `json:"snapshotSize,omitempty"`
+
+	// SourceFiles is the number of files in the source.
+	SourceFiles int64 `json:"sourceFiles"`
+
+	// SourceSize is the size of the files in the source.
+	// +optional
+	SourceSize string `json:"sourceSize,omitempty"`
+
+	// FilesNew is the number of source files not in the snapshot.
+	FilesNew int64 `json:"filesNew"`
+
+	// FilesChanged is the number of source files that changed since the snapshot.
+	FilesChanged int64 `json:"filesChanged"`
+
+	// FilesUnmodified is the number of source files unchanged since the snapshot.
+	FilesUnmodified int64 `json:"filesUnmodified"`
+
+	// DataMissing is the size of the source data not stored in the repository.
+	// +optional
+	DataMissing string `json:"dataMissing,omitempty"`
+}
+
+// BackupVerificationStatus defines the observed state of BackupVerification.
+type BackupVerificationStatus struct {
+	// Conditions represent the latest available observations.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastVerification is the timestamp of the last finished verification.
+	// +optional
+	LastVerification *metav1.Time `json:"lastVerification,omitempty"`
+
+	// LastVerificationResult is the result of the last verification: Passed or Failed.
+	// +optional
+	LastVerificationResult string `json:"lastVerificationResult,omitempty"`
+
+	// LastVerificationJob is the name of the job of the last finished verification.
+	// +optional
+	LastVerificationJob string `json:"lastVerificationJob,omitempty"`
+
+	// NextVerification is the scheduled time of the next verification.
+	// +optional
+	NextVerification *metav1.Time `json:"nextVerification,omitempty"`
+
+	// Drift compares the snapshot with the source at the last verification.
+	// +optional
+	Drift *VerificationDrift `json:"drift,omitempty"`
+
+	// CronJobRef references the verification CronJob.
+	// +optional
+	CronJobRef *ObjectReference `json:"cronJobRef,omitempty"`
+
+	// ObservedGeneration reflects the generation of the spec observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=bver
+// +kubebuilder:printcolumn:name="Backup",type="string",JSONPath=".spec.backupName"
+// +kubebuilder:printcolumn:name="Result",type="string",JSONPath=".status.lastVerificationResult"
+// +kubebuilder:printcolumn:name="Last Verification",type="date",JSONPath=".status.lastVerification"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// BackupVerification periodically compares the latest snapshot of a ResticBackup with its
+// source PVC, guarding against backups that succeed but silently miss data.
+type BackupVerification struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BackupVerificationSpec   `json:"spec,omitempty"`
+	Status BackupVerificationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BackupVerificationList contains a list of BackupVerification.
+type BackupVerificationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BackupVerification `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BackupVerification{}, &BackupVerificationList{})
+}
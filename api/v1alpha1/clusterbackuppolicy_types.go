@@ -0,0 +1,186 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterBackupPolicySpec defines the desired state of ClusterBackupPolicy.
+type ClusterBackupPolicySpec struct {
+	// NamespaceSelector selects the namespaces whose PVCs are backed up. An empty
+	// selector selects all namespaces.
+	// +optional
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// PVCSelector selects the PVCs to back up by their labels, e.g. backup=true.
+	// +kubebuilder:validation:Required
+	PVCSelector metav1.LabelSelector `json:"pvcSelector"`
+
+	// Excludes are paths to exclude from the backup of every PVC.
+	// +optional
+	Excludes []string `json:"excludes,omitempty"`
+
+	// Template describes the ResticBackups created for the selected PVCs. PVCs already
+	// backed up by another ResticBackup of their namespace are skipped, so the policy
+	// only provides a default.
+	// +kubebuilder:validation:Required
+	Template ClusterBackupTemplate `json:"template"`
+}
+
+// ClusterBackupTemplate describes the ResticBackup created for every selected PVC.
+type ClusterBackupTemplate struct {
+	// Labels are added to the created ResticBackups.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the created ResticBackups.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Spec is the spec of the created ResticBackups.
+	// +kubebuilder:validation:Required
+	Spec ClusterBackupTemplateSpec `json:"spec"`
+}
+
+// ClusterBackupTemplateSpec is the spec of the ResticBackups created by a
+// ClusterBackupPolicy. Their source is the selected PVC.
+type ClusterBackupTemplateSpec struct {
+	// RepositoryRef references the ResticRepository to use. Without namespace, the
+	// ResticRepository of that name in the namespace of the PVC is used, e.g. one
+	// created by a RepositoryTemplate.
+	// +kubebuilder:validation:Required
+	RepositoryRef CrossNamespaceObjectReference `json:"repositoryRef"`
+
+	// FallbackRepositoryRef references a ResticRepository used automatically while
+	// the primary repository is not ready for longer than FallbackAfter.
+	// +optional
+	FallbackRepositoryRef *CrossNamespaceObjectReference `json:"fallbackRepositoryRef,omitempty"`
+
+	// FallbackAfter is how long the primary repository must be not ready before
+	// the fallback repository is used.
+	// +optional
+	FallbackAfter *metav1.Duration `json:"fallbackAfter,omitempty"`
+
+	// Schedule is the backup schedule in cron format.
+	// +kubebuilder:validation:Required
+	Schedule string `json:"schedule"`
+
+	// Timezone is the timezone for schedule interpretation.
+	// +kubebuilder:default="UTC"
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// Restic contains restic-specific configuration.
+	// +optional
+	Restic *ResticConfig `json:"restic,omitempty"`
+
+	// Retention configures snapshot retention. Defaults to the default retention
+	// of the repository.
+	// +optional
+	Retention *RetentionConfig `json:"retention,omitempty"`
+
+	// Notifications configures backup notifications.
+	// +optional
+	Notifications *NotificationConfig `json:"notifications,omitempty"`
+
+	// JobConfig configures the backup job/cronjob.
+	// +optional
+	JobConfig *JobConfiguration `json:"jobConfig,omitempty"`
+
+	// Suspend suspends backup scheduling.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// BackupWindow restricts the scheduled backups to time ranges on days of the week.
+	// +optional
+	BackupWindow *BackupWindow `json:"backupWindow,omitempty"`
+
+	// BlackoutPeriods are periods without scheduled backups, e.g. maintenance freezes.
+	// +optional
+	BlackoutPeriods []BlackoutPeriod `json:"blackoutPeriods,omitempty"`
+
+	// AutoTuneResources raises the memory limit of the backup container after an OOM
+	// kill, up to a cap, and retries the backup.
+	// +optional
+	AutoTuneResources *AutoTuneResources `json:"autoTuneResources,omitempty"`
+
+	// Retry retries a failed backup job after a backoff.
+	// +optional
+	Retry *BackupRetryPolicy `json:"retry,omitempty"`
+
+	// MaxStaleness is how old the last successful backup may get before it is reported
+	// as missed.
+	// +optional
+	MaxStaleness *metav1.Duration `json:"maxStaleness,omitempty"`
+}
+
+// ClusterBackupPolicyStatus defines the observed state of ClusterBackupPolicy.
+type ClusterBackupPolicyStatus struct {
+	// Conditions represent the latest available observations.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Backups are the selected PVCs with their ResticBackups, sorted by namespace and
+	// name.
+	// +optional
+	Backups []DiscoveredPVC `json:"backups,omitempty"`
+
+	// SelectedPVCs is the number of selected PVCs.
+	// +optional
+	SelectedPVCs int32 `json:"selectedPVCs,omitempty"`
+
+	// SkippedPVCs is the number of matching PVCs skipped because another ResticBackup
+	// of their namespace backs them up.
+	// +optional
+	SkippedPVCs int32 `json:"skippedPVCs,omitempty"`
+
+	// ObservedGeneration reflects the generation of the spec observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=cbp
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.template.spec.schedule"
+// +kubebuilder:printcolumn:name="Selected",type="integer",JSONPath=".status.selectedPVCs"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ClusterBackupPolicy is the Schema for the clusterbackuppolicies API. It creates a
+// ResticBackup for every PVC matching its selectors, in the namespace of the PVC.
+type ClusterBackupPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterBackupPolicySpec   `json:"spec,omitempty"`
+	Status ClusterBackupPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterBackupPolicyList contains a list of ClusterBackupPolicy.
+type ClusterBackupPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterBackupPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterBackupPolicy{}, &ClusterBackupPolicyList{})
+}
@@ -31,6 +31,42 @@ const (
 	ConditionProgressing = "Progressing"
 	// ConditionDegraded indicates the resource is operational but experiencing issues.
 	ConditionDegraded = "Degraded"
+	// ConditionUsedFallback indicates backups are written to the fallback repository.
+	ConditionUsedFallback = "UsedFallback"
+	// ConditionHostnameMismatch indicates snapshots of a backup were taken with different hostnames.
+	ConditionHostnameMismatch = "HostnameMismatch"
+	// ConditionCredentialsValid indicates the repository is reachable with its credentials.
+	ConditionCredentialsValid = "CredentialsValid"
+	// ConditionIntegrityCheckSucceeded indicates the last scheduled integrity check passed.
+	ConditionIntegrityCheckSucceeded = "IntegrityCheckSucceeded"
+	// ConditionOverlappingBackup indicates a backup in another namespace backs up overlapping paths of the same volume.
+	ConditionOverlappingBackup = "OverlappingBackup"
+	// ConditionAssertionsPassed indicates the restored data passed the restore assertions.
+	ConditionAssertionsPassed = "AssertionsPassed"
+	// ConditionWaitingForRepository indicates a job waits for another operation on the repository to finish.
+	ConditionWaitingForRepository = "WaitingForRepository"
+	// ConditionDeletionBlocked indicates the deletion of a repository waits for resources referencing it.
+	ConditionDeletionBlocked = "DeletionBlocked"
+	// ConditionReferenceDenied indicates a cross-namespace reference is not permitted by a ResticReferenceGrant.
+	ConditionReferenceDenied = "ReferenceDenied"
+	// ConditionScheduleOverlap indicates retention runs overlap with backups of the repository.
+	ConditionScheduleOverlap = "ScheduleOverlap"
+	// ConditionBackendFull indicates the last backup failed because the repository backend has too little free space.
+	ConditionBackendFull = "BackendFull"
+	// ConditionSnapshotVerified indicates the latest snapshot matches its source.
+	ConditionSnapshotVerified = "SnapshotVerified"
+	// ConditionReplicated indicates the last replication copied all snapshots.
+	ConditionReplicated = "Replicated"
+	// ConditionUnmatchedSelectors indicates retention selectors match no snapshots of the repository.
+	ConditionUnmatchedSelectors = "UnmatchedSelectors"
+	// ConditionReachable indicates the last connectivity probe of an intermittent repository succeeded.
+	ConditionReachable = "Reachable"
+	// ConditionSuspendedByWindow indicates the backup CronJob is suspended outside the backup window or during a blackout period.
+	ConditionSuspendedByWindow = "SuspendedByWindow"
+	// ConditionPaused indicates a backup suspended in the Drain mode has no running backup jobs left.
+	ConditionPaused = "Paused"
+	// ConditionRepositoryIdentityChanged indicates the backend serves another restic repository than the one used before.
+	ConditionRepositoryIdentityChanged = "RepositoryIdentityChanged"
 )
 
 // SecretKeySelector selects a key from a Secret.
@@ -64,7 +100,9 @@ type ObjectReference struct {
 	Namespace string `json:"namespace"`
 }
 
-// RetentionPolicy defines snapshot retention rules.
+// RetentionPolicy defines snapshot retention rules. At least one keep rule must be set,
+// restic refuses to forget snapshots without one. Counts of 0 are no keep rule.
+// +kubebuilder:validation:XValidation:rule="(has(self.keepLast) && self.keepLast > 0) || (has(self.keepHourly) && self.keepHourly > 0) || (has(self.keepDaily) && self.keepDaily > 0) || (has(self.keepWeekly) && self.keepWeekly > 0) || (has(self.keepMonthly) && self.keepMonthly > 0) || (has(self.keepYearly) && self.keepYearly > 0) || has(self.keepWithin) || has(self.keepWithinHourly) || has(self.keepWithinDaily) || has(self.keepWithinWeekly) || has(self.keepWithinMonthly) || has(self.keepWithinYearly)",message="at least one keep rule must be set"
 type RetentionPolicy struct {
 	// KeepLast specifies the number of last snapshots to keep.
 	// +kubebuilder:validation:Minimum=0
@@ -95,6 +133,55 @@ type RetentionPolicy struct {
 	// +kubebuilder:validation:Minimum=0
 	// +optional
 	KeepYearly *int32 `json:"keepYearly,omitempty"`
+
+	// KeepWithin keeps all snapshots taken within the duration (--keep-within).
+	// The duration is relative to the latest snapshot and combines years, months,
+	// days and hours, e.g. "1y6m" or "2d12h".
+	// +kubebuilder:validation:Pattern=`^([0-9]+[ymdh])+$`
+	// +optional
+	KeepWithin string `json:"keepWithin,omitempty"`
+
+	// KeepWithinHourly keeps the last snapshot of each hour within the duration (--keep-within-hourly).
+	// +kubebuilder:validation:Pattern=`^([0-9]+[ymdh])+$`
+	// +optional
+	KeepWithinHourly string `json:"keepWithinHourly,omitempty"`
+
+	// KeepWithinDaily keeps the last snapshot of each day within the duration (--keep-within-daily).
+	// +kubebuilder:validation:Pattern=`^([0-9]+[ymdh])+$`
+	// +optional
+	KeepWithinDaily string `json:"keepWithinDaily,omitempty"`
+
+	// KeepWithinWeekly keeps the last snapshot of each week within the duration (--keep-within-weekly).
+	// +kubebuilder:validation:Pattern=`^([0-9]+[ymdh])+$`
+	// +optional
+	KeepWithinWeekly string `json:"keepWithinWeekly,omitempty"`
+
+	// KeepWithinMonthly keeps the last snapshot of each month within the duration (--keep-within-monthly).
+	// +kubebuilder:validation:Pattern=`^([0-9]+[ymdh])+$`
+	// +optional
+	KeepWithinMonthly string `json:"keepWithinMonthly,omitempty"`
+
+	// KeepWithinYearly keeps the last snapshot of each year within the duration (--keep-within-yearly).
+	// +kubebuilder:validation:Pattern=`^([0-9]+[ymdh])+$`
+	// +optional
+	KeepWithinYearly string `json:"keepWithinYearly,omitempty"`
+}
+
+// HasKeepRule reports whether the policy has at least one keep rule. Like in the CEL
+// validation of the type, a count of 0 keeps nothing and is no keep rule.
+func (p *RetentionPolicy) HasKeepRule() bool {
+	for _, count := range []*int32{p.KeepLast, p.KeepHourly, p.KeepDaily, p.KeepWeekly, p.KeepMonthly, p.KeepYearly} {
+		if count != nil && *count > 0 {
+			return true
+		}
+	}
+	for _, duration := range []string{p.KeepWithin, p.KeepWithinHourly, p.KeepWithinDaily,
+		p.KeepWithinWeekly, p.KeepWithinMonthly, p.KeepWithinYearly} {
+		if duration != "" {
+			return true
+		}
+	}
+	return false
 }
 
 // PushgatewayConfig configures Prometheus Pushgateway notifications.
@@ -125,7 +212,8 @@ type NtfyCredentialsSecretRef struct {
 	// +kubebuilder:validation:Required
 	Name string `json:"name"`
 
-	// Namespace of the secret. If empty, uses the same namespace as the referencing resource.
+	// Namespace of the secret. Must be empty or the namespace of the referencing resource,
+	// the operator doesn't read secrets of other namespaces.
 	// +optional
 	Namespace string `json:"namespace,omitempty"`
 }
@@ -170,6 +258,68 @@ type NtfyConfig struct {
 	Tags []string `json:"tags,omitempty"`
 }
 
+// EmailCredentialsSecretRef references a secret in the namespace of the referencing
+// resource containing the SMTP credentials in the keys "username" and "password".
+type EmailCredentialsSecretRef struct {
+	// Name of the secret.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+// EmailConfig configures email notifications via SMTP.
+type EmailConfig struct {
+	// Enabled enables email notifications.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Host is the SMTP server host.
+	// +kubebuilder:validation:Required
+	Host string `json:"host"`
+
+	// Port is the SMTP server port.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=587
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// From is the sender address.
+	// +kubebuilder:validation:Required
+	From string `json:"from"`
+
+	// To are the recipient addresses.
+	// +kubebuilder:validation:MinItems=1
+	To []string `json:"to"`
+
+	// TLS is the TLS mode of the connection: StartTLS upgrades the connection,
+	// TLS connects with TLS (e.g. port 465), None sends unencrypted.
+	// +kubebuilder:validation:Enum=StartTLS;TLS;None
+	// +kubebuilder:default=StartTLS
+	// +optional
+	TLS string `json:"tls,omitempty"`
+
+	// InsecureSkipVerify disables the verification of the server certificate.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+
+	// CredentialsSecretRef references a secret in the namespace of the referencing resource
+	// containing the SMTP credentials in the keys "username" and "password".
+	// Authentication requires TLS.
+	// +optional
+	CredentialsSecretRef *EmailCredentialsSecretRef `json:"credentialsSecretRef,omitempty"`
+
+	// OnlyOnFailure sends emails only on failure.
+	// +optional
+	OnlyOnFailure bool `json:"onlyOnFailure,omitempty"`
+
+	// BatchWindow combines all notifications for the same recipients within this
+	// window into one email, so many backups finishing at the same time don't flood
+	// the inbox. 0s sends every notification immediately.
+	// +kubebuilder:default="5m"
+	// +optional
+	BatchWindow *metav1.Duration `json:"batchWindow,omitempty"`
+}
+
 // NotificationConfig configures backup notifications.
 type NotificationConfig struct {
 	// Pushgateway configures Prometheus Pushgateway notifications.
@@ -179,6 +329,10 @@ type NotificationConfig struct {
 	// Ntfy configures ntfy push notifications.
 	// +optional
 	Ntfy *NtfyConfig `json:"ntfy,omitempty"`
+
+	// Email configures email notifications via SMTP.
+	// +optional
+	Email *EmailConfig `json:"email,omitempty"`
 }
 
 // ExecHook defines an exec hook to run in an existing pod.
@@ -220,6 +374,38 @@ type Hook struct {
 	// Job defines a job-based hook.
 	// +optional
 	Job *JobHook `json:"job,omitempty"`
+
+	// OnError defines how a failing hook affects the backup. Fail skips the backup
+	// (preBackup) or marks it as failed (postBackup); Continue only reports the error.
+	// +kubebuilder:validation:Enum=Fail;Continue
+	// +kubebuilder:default=Fail
+	// +optional
+	OnError string `json:"onError,omitempty"`
+}
+
+// HookStatus contains the result of the last run of a hook.
+type HookStatus struct {
+	// Name of the hook: preBackup, postBackup, onFailure, or scaleDown and scaleUp of
+	// the scaleDown hook.
+	Name string `json:"name"`
+
+	// Job is the backup job the hook ran for.
+	// +optional
+	Job string `json:"job,omitempty"`
+
+	// Pod is the pod the command was executed in.
+	// +optional
+	Pod string `json:"pod,omitempty"`
+
+	// LastRun is when the hook finished.
+	LastRun metav1.Time `json:"lastRun"`
+
+	// Result is the hook result: Succeeded or Failed.
+	Result string `json:"result"`
+
+	// Message contains the error of a failed hook.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // BackupHooks defines hooks for backup operations.
@@ -235,6 +421,40 @@ type BackupHooks struct {
 	// OnFailure runs when a backup fails.
 	// +optional
 	OnFailure *Hook `json:"onFailure,omitempty"`
+
+	// ScaleDown scales a workload to zero replicas before the backup starts and back
+	// to its previous replicas after the backup finished, also if it failed. It is
+	// meant for applications that cannot be dumped consistently while running.
+	// +optional
+	ScaleDown *ScaleDownHook `json:"scaleDown,omitempty"`
+}
+
+// ScaleDownHook quiesces a workload for the duration of a backup or restore.
+type ScaleDownHook struct {
+	// WorkloadRef references the Deployment or StatefulSet in the namespace of the
+	// backup or restore.
+	// +kubebuilder:validation:Required
+	WorkloadRef WorkloadReference `json:"workloadRef"`
+
+	// Timeout is the maximum duration to wait for the pods of the workload to terminate.
+	// The backup or restore fails and the workload is scaled up again if pods are still
+	// running after the timeout.
+	// +kubebuilder:default="5m"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// WorkloadReference references a Deployment or StatefulSet.
+type WorkloadReference struct {
+	// Kind of the workload.
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+	// +kubebuilder:validation:Required
+	Kind string `json:"kind"`
+
+	// Name of the workload.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
 }
 
 // RestoreHooks defines hooks for restore operations.
@@ -268,15 +488,22 @@ type JobConfiguration struct {
 	// +optional
 	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
 
-	// ActiveDeadlineSeconds specifies the job timeout.
+	// ActiveDeadlineSeconds specifies the job timeout. Defaults to the operator's default
+	// for the operation type.
 	// +kubebuilder:validation:Minimum=1
-	// +kubebuilder:default=3600
 	// +optional
 	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
 
+	// TerminationGracePeriodSeconds is the time restic gets to exit cleanly and release
+	// its repository lock when the pod is terminated, e.g. during a node drain.
+	// Defaults to 120 for backup jobs and to the Kubernetes default of 30 otherwise.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
 	// BackoffLimit specifies the number of retries before considering a job as failed.
+	// Defaults to the operator's default for the operation type.
 	// +kubebuilder:validation:Minimum=0
-	// +kubebuilder:default=0
 	// +optional
 	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
 
@@ -308,7 +535,84 @@ type JobConfiguration struct {
 	// +kubebuilder:validation:Schemaless
 	Affinity *corev1.Affinity `json:"affinity,omitempty"`
 
+	// RuntimeClassName selects the RuntimeClass used to run the pods.
+	// +optional
+	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+
+	// AllowGPUNodes allows tolerations for dedicated GPU node taints. By default,
+	// such tolerations are dropped so that pods do not occupy GPU nodes.
+	// +optional
+	AllowGPUNodes bool `json:"allowGPUNodes,omitempty"`
+
+	// AllowSpotNodes allows tolerations for spot/preemptible node taints. By default,
+	// such tolerations are dropped so that pods are not preempted mid-run.
+	// +optional
+	AllowSpotNodes bool `json:"allowSpotNodes,omitempty"`
+
 	// ServiceAccountName specifies the service account for the backup pod.
 	// +optional
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// CreateServiceAccount creates a dedicated ServiceAccount without API permissions
+	// for the backup pods and disables service account token automounting.
+	// Ignored if ServiceAccountName is set. Only supported by ResticBackup.
+	// +optional
+	CreateServiceAccount bool `json:"createServiceAccount,omitempty"`
+
+	// DNSPolicy defines the DNS policy for the pod.
+	// +kubebuilder:validation:Enum=ClusterFirst;ClusterFirstWithHostNet;Default;None
+	// +optional
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNSConfig defines custom DNS parameters for the pod.
+	// Required when DNSPolicy is None.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// HostAliases defines additional entries for the pod's /etc/hosts file.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+
+	// Sidecars are additional containers, e.g. for log shipping or mesh egress.
+	// They run as native sidecars (Kubernetes 1.29+) that are stopped once the
+	// restic container finished, so they do not keep the job running. Names must be
+	// unique and must not be restic, dump or resources.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+
+	// NativeMeshSidecars requests Istio and Linkerd to inject their proxy as a
+	// native sidecar, so that the proxy is stopped once the job finished instead
+	// of keeping the pod running.
+	// +optional
+	NativeMeshSidecars bool `json:"nativeMeshSidecars,omitempty"`
+}
+
+// ReservedContainerNames are the names of the containers the operator adds to the
+// job pods. Sidecars must not use them.
+var ReservedContainerNames = []string{"restic", "dump", "resources"}
+
+// SidecarNameConflict returns the first sidecar name that is reserved or used by an
+// earlier sidecar, or "" if all names are unique. Pods with duplicate container names
+// are rejected by the API server, so the job could never be created.
+func (c *JobConfiguration) SidecarNameConflict() string {
+	if c == nil {
+		return ""
+	}
+	seen := map[string]bool{}
+	for _, name := range ReservedContainerNames {
+		seen[name] = true
+	}
+	for _, sidecar := range c.Sidecars {
+		if seen[sidecar.Name] {
+			return sidecar.Name
+		}
+		seen[sidecar.Name] = true
+	}
+	return ""
 }
@@ -29,6 +29,19 @@ type RetentionSelector struct {
 	// Hostname filters snapshots by hostname.
 	// +optional
 	Hostname string `json:"hostname,omitempty"`
+
+	// Paths filters snapshots by their backed up paths. A snapshot is selected if it
+	// contains all of the paths.
+	// +optional
+	// +kubebuilder:validation:items:Pattern=`^/`
+	Paths []string `json:"paths,omitempty"`
+
+	// ExcludeSnapshotIDs protects the listed snapshots from being forgotten by this
+	// policy. The snapshots are tagged with retention-protected, which changes their
+	// ID; the listed original IDs keep protecting them.
+	// +optional
+	// +kubebuilder:validation:items:Pattern=`^[0-9a-f]{8,64}$`
+	ExcludeSnapshotIDs []string `json:"excludeSnapshotIDs,omitempty"`
 }
 
 // RetentionPolicyEntry defines a retention policy for a set of snapshots.
@@ -40,6 +53,11 @@ type RetentionPolicyEntry struct {
 	// Retention defines the retention rules.
 	// +kubebuilder:validation:Required
 	Retention RetentionPolicy `json:"retention"`
+
+	// Schedule is the cron schedule of this entry. Entries with their own schedule
+	// run in a separate CronJob. Defaults to the schedule of the policy.
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
 }
 
 // EmailNotificationConfig configures email notifications.
@@ -91,10 +109,20 @@ type GlobalRetentionPolicySpec struct {
 	// +kubebuilder:validation:MinItems=1
 	Policies []RetentionPolicyEntry `json:"policies"`
 
-	// Prune runs prune after all forget operations.
+	// Prune runs prune after the forget operations of the entries without their own
+	// schedule.
 	// +optional
 	Prune bool `json:"prune,omitempty"`
 
+	// BackupOverlapPolicy defines how retention runs overlapping with backups of the
+	// repository are handled. Ignore only reports the overlap, Offset moves the retention
+	// schedule to a later time of the same day without backups, Wait lets retention wait
+	// for running backups to release the repository.
+	// +kubebuilder:validation:Enum=Ignore;Offset;Wait
+	// +kubebuilder:default=Ignore
+	// +optional
+	BackupOverlapPolicy BackupOverlapPolicy `json:"backupOverlapPolicy,omitempty"`
+
 	// Notifications configures retention notifications.
 	// +optional
 	Notifications *GlobalRetentionNotificationConfig `json:"notifications,omitempty"`
@@ -109,6 +137,33 @@ type GlobalRetentionPolicySpec struct {
 	Suspend bool `json:"suspend,omitempty"`
 }
 
+// BackupOverlapPolicy defines how retention runs overlapping with backups are handled.
+type BackupOverlapPolicy string
+
+const (
+	// BackupOverlapIgnore only reports overlapping backups.
+	BackupOverlapIgnore BackupOverlapPolicy = "Ignore"
+	// BackupOverlapOffset moves the retention schedule to a time without backups.
+	BackupOverlapOffset BackupOverlapPolicy = "Offset"
+	// BackupOverlapWait lets retention wait for backups to release the repository.
+	BackupOverlapWait BackupOverlapPolicy = "Wait"
+)
+
+// RetentionScheduleOverlap reports the backups running at the same time as a retention schedule.
+type RetentionScheduleOverlap struct {
+	// Schedule is the retention schedule as configured.
+	Schedule string `json:"schedule"`
+
+	// OverlappingBackups lists the ResticBackups (namespace/name) of the repository
+	// running during one of the next retention runs.
+	// +optional
+	OverlappingBackups []string `json:"overlappingBackups,omitempty"`
+
+	// AdjustedSchedule is the schedule used instead with the Offset policy.
+	// +optional
+	AdjustedSchedule string `json:"adjustedSchedule,omitempty"`
+}
+
 // GlobalRetentionPolicyStatus defines the observed state of GlobalRetentionPolicy.
 type GlobalRetentionPolicyStatus struct {
 	// Conditions represent the latest available observations.
@@ -127,6 +182,10 @@ type GlobalRetentionPolicyStatus struct {
 	// +optional
 	LastRunDuration string `json:"lastRunDuration,omitempty"`
 
+	// LastRunFailureMessage is the end of the log of the last retention run if it failed.
+	// +optional
+	LastRunFailureMessage string `json:"lastRunFailureMessage,omitempty"`
+
 	// RepositorySizeBefore is the repository size before the last run.
 	// +optional
 	RepositorySizeBefore string `json:"repositorySizeBefore,omitempty"`
@@ -147,6 +206,14 @@ type GlobalRetentionPolicyStatus struct {
 	// +optional
 	CronJobRef *ObjectReference `json:"cronJobRef,omitempty"`
 
+	// EntryCronJobRefs references the CronJobs of the entries with their own schedule.
+	// +optional
+	EntryCronJobRefs []ObjectReference `json:"entryCronJobRefs,omitempty"`
+
+	// ScheduleOverlaps lists the retention schedules overlapping with backups of the repository.
+	// +optional
+	ScheduleOverlaps []RetentionScheduleOverlap `json:"scheduleOverlaps,omitempty"`
+
 	// ObservedGeneration reflects the generation of the spec observed by the controller.
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
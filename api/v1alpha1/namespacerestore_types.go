@@ -0,0 +1,173 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceRestorePVCTemplate defines the PVCs created for backups whose PVC does not exist.
+type NamespaceRestorePVCTemplate struct {
+	// StorageClassName is the storage class for created PVCs. Defaults to the cluster default.
+	// +optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// AccessModes are the access modes for created PVCs. Defaults to ReadWriteOnce.
+	// +optional
+	AccessModes []string `json:"accessModes,omitempty"`
+
+	// Size is the size of created PVCs.
+	// +kubebuilder:default="10Gi"
+	// +optional
+	Size string `json:"size,omitempty"`
+}
+
+// NamespaceRestorePhase represents the current phase of a namespace restore.
+// +kubebuilder:validation:Enum=Pending;InProgress;Completed;PartiallyFailed;Failed
+type NamespaceRestorePhase string
+
+const (
+	// NamespaceRestorePhasePending indicates the restores have not been created yet.
+	NamespaceRestorePhasePending NamespaceRestorePhase = "Pending"
+	// NamespaceRestorePhaseInProgress indicates restores are running.
+	NamespaceRestorePhaseInProgress NamespaceRestorePhase = "InProgress"
+	// NamespaceRestorePhaseCompleted indicates all restores completed successfully.
+	NamespaceRestorePhaseCompleted NamespaceRestorePhase = "Completed"
+	// NamespaceRestorePhasePartiallyFailed indicates some restores failed.
+	NamespaceRestorePhasePartiallyFailed NamespaceRestorePhase = "PartiallyFailed"
+	// NamespaceRestorePhaseFailed indicates all restores failed or none could be created.
+	NamespaceRestorePhaseFailed NamespaceRestorePhase = "Failed"
+)
+
+// NamespaceRestoreSpec defines the desired state of NamespaceRestore.
+type NamespaceRestoreSpec struct {
+	// SourceNamespace is the namespace of the ResticBackups to restore.
+	// Defaults to the namespace of the NamespaceRestore.
+	// +optional
+	SourceNamespace string `json:"sourceNamespace,omitempty"`
+
+	// BackupSelector selects the ResticBackups to restore. Defaults to all.
+	// +optional
+	BackupSelector *metav1.LabelSelector `json:"backupSelector,omitempty"`
+
+	// SnapshotSelector selects the snapshot of each restore. Defaults to the latest.
+	// +optional
+	SnapshotSelector *SnapshotSelector `json:"snapshotSelector,omitempty"`
+
+	// PVCTemplate defines the PVCs created for backups whose PVC does not exist.
+	// +optional
+	PVCTemplate *NamespaceRestorePVCTemplate `json:"pvcTemplate,omitempty"`
+
+	// Options configures restore behavior.
+	// +optional
+	Options *RestoreOptions `json:"options,omitempty"`
+
+	// JobConfig configures the restore jobs.
+	// +optional
+	JobConfig *JobConfiguration `json:"jobConfig,omitempty"`
+}
+
+// NamespaceRestoreEntry reports the restore of a single ResticBackup.
+type NamespaceRestoreEntry struct {
+	// Backup is the name of the restored ResticBackup.
+	Backup string `json:"backup"`
+
+	// Restore is the name of the created ResticRestore.
+	Restore string `json:"restore"`
+
+	// PVC is the name of the target PVC.
+	PVC string `json:"pvc"`
+
+	// PVCCreated indicates that the target PVC was created by the operator.
+	// +optional
+	PVCCreated bool `json:"pvcCreated,omitempty"`
+
+	// Phase is the phase of the ResticRestore.
+	// +optional
+	Phase RestorePhase `json:"phase,omitempty"`
+}
+
+// NamespaceRestoreStatus defines the observed state of NamespaceRestore.
+type NamespaceRestoreStatus struct {
+	// Conditions represent the latest available observations.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Phase is the aggregated phase of all restores.
+	// +optional
+	Phase NamespaceRestorePhase `json:"phase,omitempty"`
+
+	// StartTime is when the restores were created.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the last restore finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Restores reports the restore of each selected ResticBackup.
+	// +optional
+	Restores []NamespaceRestoreEntry `json:"restores,omitempty"`
+
+	// Skipped lists selected ResticBackups that cannot be restored to a PVC,
+	// e.g. pod volume or custom sources.
+	// +optional
+	Skipped []string `json:"skipped,omitempty"`
+
+	// Completed is the number of completed restores.
+	// +optional
+	Completed int32 `json:"completed,omitempty"`
+
+	// Failed is the number of failed restores.
+	// +optional
+	Failed int32 `json:"failed,omitempty"`
+
+	// ObservedGeneration reflects the generation of the spec observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=nsres
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Completed",type="integer",JSONPath=".status.completed"
+// +kubebuilder:printcolumn:name="Failed",type="integer",JSONPath=".status.failed"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// NamespaceRestore is the Schema for the namespacerestores API. It restores all
+// ResticBackups of a namespace by creating a ResticRestore for each of them.
+type NamespaceRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespaceRestoreSpec   `json:"spec,omitempty"`
+	Status NamespaceRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceRestoreList contains a list of NamespaceRestore.
+type NamespaceRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceRestore{}, &NamespaceRestoreList{})
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RepositoryTemplateSpec defines the desired state of RepositoryTemplate.
+type RepositoryTemplateSpec struct {
+	// NamespaceSelector selects the tenant namespaces that get their own repository.
+	// +kubebuilder:validation:Required
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+
+	// RepositoryName is the name of the ResticRepository created in each namespace.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:default=restic-repository
+	// +optional
+	RepositoryName string `json:"repositoryName,omitempty"`
+
+	// Credentials configures the credentials Secret of each repository.
+	// +optional
+	Credentials RepositoryTemplateCredentials `json:"credentials,omitempty"`
+
+	// Template describes the ResticRepositories to create. {{namespace}} in the
+	// repositoryURL is replaced by the tenant namespace, so every tenant gets an
+	// isolated repository. The credentialsSecretRef names the Secret in the tenant
+	// namespace.
+	// +kubebuilder:validation:Required
+	Template RepositoryTemplateResource `json:"template"`
+}
+
+// RepositoryTemplateCredentials configures how the credentials Secret of a tenant
+// repository is provisioned. Existing Secret keys are never replaced, except the keys
+// copied from the source Secret.
+type RepositoryTemplateCredentials struct {
+	// SourceSecretRef references a Secret in the namespace of the template whose keys,
+	// e.g. the backend credentials, are copied into the Secret of every tenant. Its
+	// RESTIC_PASSWORD is only copied if the tenant Secret has none.
+	// +optional
+	SourceSecretRef *corev1.LocalObjectReference `json:"sourceSecretRef,omitempty"`
+
+	// GeneratePassword generates a random RESTIC_PASSWORD for every tenant Secret
+	// without one, so every tenant repository has its own encryption key. The password
+	// is never changed afterwards, losing it makes the repository unreadable.
+	// +kubebuilder:default=true
+	// +optional
+	GeneratePassword *bool `json:"generatePassword,omitempty"`
+
+	// Provisioner runs a Job in the namespace of the template before the credentials
+	// Secret of a tenant exists, e.g. to create a bucket and backend credentials. The
+	// Job gets the tenant namespace and the Secret name in the TENANT_NAMESPACE and
+	// CREDENTIALS_SECRET environment variables and should create the Secret.
+	// +optional
+	Provisioner *CredentialsProvisioner `json:"provisioner,omitempty"`
+}
+
+// CredentialsProvisioner configures the Job provisioning the credentials of a tenant.
+type CredentialsProvisioner struct {
+	// Image is the container image of the provisioner.
+	// +kubebuilder:validation:Required
+	Image string `json:"image"`
+
+	// Command is the command of the provisioner container.
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Args are the arguments of the provisioner container.
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// ServiceAccountName is the ServiceAccount of the provisioner, which needs the
+	// permission to create Secrets in the tenant namespaces.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+}
+
+// RepositoryTemplateResource describes the ResticRepository created in every tenant
+// namespace.
+type RepositoryTemplateResource struct {
+	// Labels are added to the created ResticRepositories.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the created ResticRepositories.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Spec is the spec of the created ResticRepositories.
+	// +kubebuilder:validation:Required
+	Spec ResticRepositorySpec `json:"spec"`
+}
+
+// TemplatedRepositoryPhase is the provisioning phase of a tenant repository.
+// +kubebuilder:validation:Enum=Provisioning;Pending;Ready;Failed
+type TemplatedRepositoryPhase string
+
+const (
+	// TemplatedRepositoryProvisioning means the credentials provisioner is running.
+	TemplatedRepositoryProvisioning TemplatedRepositoryPhase = "Provisioning"
+	// TemplatedRepositoryPending means the ResticRepository exists and is not ready yet.
+	TemplatedRepositoryPending TemplatedRepositoryPhase = "Pending"
+	// TemplatedRepositoryReady means the ResticRepository is ready.
+	TemplatedRepositoryReady TemplatedRepositoryPhase = "Ready"
+	// TemplatedRepositoryFailed means the repository could not be provisioned.
+	TemplatedRepositoryFailed TemplatedRepositoryPhase = "Failed"
+)
+
+// TemplatedRepository is the state of the repository of a tenant namespace.
+type TemplatedRepository struct {
+	// Namespace is the tenant namespace.
+	Namespace string `json:"namespace"`
+
+	// Phase is the provisioning phase of the repository.
+	Phase TemplatedRepositoryPhase `json:"phase"`
+
+	// Message describes the phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RepositoryTemplateStatus defines the observed state of RepositoryTemplate.
+type RepositoryTemplateStatus struct {
+	// Conditions represent the latest available observations.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Repositories are the repositories of the selected namespaces, sorted by namespace.
+	// +optional
+	Repositories []TemplatedRepository `json:"repositories,omitempty"`
+
+	// ReadyRepositories is the number of ready repositories.
+	// +optional
+	ReadyRepositories int32 `json:"readyRepositories,omitempty"`
+
+	// TotalRepositories is the number of selected namespaces.
+	// +optional
+	TotalRepositories int32 `json:"totalRepositories,omitempty"`
+
+	// ObservedGeneration reflects the generation of the spec observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=rtpl
+// +kubebuilder:printcolumn:name="Repository",type="string",JSONPath=".spec.repositoryName"
+// +kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyRepositories"
+// +kubebuilder:printcolumn:name="Total",type="integer",JSONPath=".status.totalRepositories"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// RepositoryTemplate is the Schema for the repositorytemplates API. It creates an
+// isolated ResticRepository with its own credentials in every selected namespace.
+type RepositoryTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RepositoryTemplateSpec   `json:"spec,omitempty"`
+	Status RepositoryTemplateStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RepositoryTemplateList contains a list of RepositoryTemplate.
+type RepositoryTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RepositoryTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RepositoryTemplate{}, &RepositoryTemplateList{})
+}
@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -28,6 +29,10 @@ type PVCSource struct {
 	ClaimName string `json:"claimName"`
 
 	// Paths are the paths within the PVC to backup. Defaults to "/".
+	// Only these paths are backed up, which scopes the backup to the directories of
+	// one app on a volume shared by several apps. Paths must be absolute and must not
+	// overlap. The backup fails if a path doesn't exist.
+	// +kubebuilder:validation:items:Pattern=`^/`
 	// +optional
 	Paths []string `json:"paths,omitempty"`
 
@@ -36,6 +41,17 @@ type PVCSource struct {
 	Excludes []string `json:"excludes,omitempty"`
 }
 
+// PVCSelectorSource selects the PVCs backed up by a ResticBackup by their labels.
+type PVCSelectorSource struct {
+	// Selector selects the PVCs by their labels.
+	// +kubebuilder:validation:Required
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// Excludes are paths to exclude from the backup of every PVC.
+	// +optional
+	Excludes []string `json:"excludes,omitempty"`
+}
+
 // PodVolumeBackupSource defines backing up a volume from a running pod.
 type PodVolumeBackupSource struct {
 	// Selector selects the pod to backup from.
@@ -64,12 +80,109 @@ type CustomSource struct {
 	BackupPath string `json:"backupPath"`
 }
 
+// DatabaseEngine is the engine of a database backup source.
+// +kubebuilder:validation:Enum=postgres;mysql;mongodb
+type DatabaseEngine string
+
+const (
+	// DatabaseEnginePostgres dumps a PostgreSQL database with pg_dump.
+	DatabaseEnginePostgres DatabaseEngine = "postgres"
+	// DatabaseEngineMySQL dumps a MySQL or MariaDB database with mysqldump.
+	DatabaseEngineMySQL DatabaseEngine = "mysql"
+	// DatabaseEngineMongoDB dumps a MongoDB database with mongodump.
+	DatabaseEngineMongoDB DatabaseEngine = "mongodb"
+)
+
+// DatabaseSource defines a database dump as the backup source. The dump is written
+// to a shared volume by a dump container before restic backs it up.
+type DatabaseSource struct {
+	// Engine is the database engine.
+	// +kubebuilder:validation:Required
+	Engine DatabaseEngine `json:"engine"`
+
+	// ConnectionSecretRef references a Secret in the namespace of the ResticBackup with
+	// the connection settings. Supported keys are host, port, username, password and
+	// database, and uri for mongodb.
+	// +kubebuilder:validation:Required
+	ConnectionSecretRef corev1.LocalObjectReference `json:"connectionSecretRef"`
+
+	// Image is the container image providing the dump tool. Should match the major
+	// version of the database server. Defaults to an image per engine.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// DumpArgs are additional arguments for the dump tool.
+	// +optional
+	DumpArgs []string `json:"dumpArgs,omitempty"`
+}
+
+// ResourceKind selects the objects of one kind, e.g. Deployment in group apps.
+type ResourceKind struct {
+	// Group is the API group of the kind, empty for the core group.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Version is the API version of the kind. Defaults to the preferred version of the
+	// group.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Kind is the kind of the objects, e.g. Deployment.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Kind string `json:"kind"`
+}
+
+// ResourcesSource dumps Kubernetes objects as YAML files into the snapshot, so the
+// manifests of an application are restored together with its data. The dump runs with
+// the ServiceAccount resticbackup-<name>-resources created by the operator, which has
+// no permissions until they are granted with a RoleBinding.
+type ResourcesSource struct {
+	// Namespaces whose objects are dumped. Defaults to the namespace of the ResticBackup.
+	// +kubebuilder:validation:MaxItems=32
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Kinds are the kinds of the dumped objects. Defaults to Deployments, StatefulSets,
+	// DaemonSets, CronJobs, Services, ConfigMaps, PersistentVolumeClaims,
+	// ServiceAccounts and Ingresses. Secrets are only dumped when listed.
+	// +kubebuilder:validation:MaxItems=64
+	// +optional
+	Kinds []ResourceKind `json:"kinds,omitempty"`
+
+	// LabelSelector restricts the dumped objects to the matching ones.
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+
+	// Image is the container image providing kubectl. Defaults to alpine/kubectl.
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
 // BackupSource defines the source for backup data.
+// +kubebuilder:validation:XValidation:rule="!(has(self.pvc) && has(self.pvcs))",message="pvc and pvcs are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="!has(self.pvcSelector) || !(has(self.pvc) || has(self.pvcs) || has(self.podVolumeBackup) || has(self.customSource) || has(self.database) || has(self.resources))",message="pvcSelector can't be combined with other sources"
 type BackupSource struct {
 	// PVC defines a PersistentVolumeClaim as the backup source.
 	// +optional
 	PVC *PVCSource `json:"pvc,omitempty"`
 
+	// PVCs defines several PersistentVolumeClaims of one application as the backup
+	// source. They are mounted at /backup/<claimName> of one backup job and stored in
+	// a single snapshot. The excludes of all PVCs apply to the whole snapshot.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=map
+	// +listMapKey=claimName
+	// +optional
+	PVCs []PVCSource `json:"pvcs,omitempty"`
+
+	// PVCSelector backs up every PVC matching the selectors. The operator creates a
+	// ResticBackup with a pvc source for each of them, in the namespace of the PVC and
+	// with the spec of this ResticBackup, and deletes it once the PVC no longer matches.
+	// +optional
+	PVCSelector *PVCSelectorSource `json:"pvcSelector,omitempty"`
+
 	// PodVolumeBackup defines backing up a volume from a running pod.
 	// +optional
 	PodVolumeBackup *PodVolumeBackupSource `json:"podVolumeBackup,omitempty"`
@@ -77,11 +190,22 @@ type BackupSource struct {
 	// CustomSource defines a custom backup source.
 	// +optional
 	CustomSource *CustomSource `json:"customSource,omitempty"`
+
+	// Database defines a database dump as the backup source.
+	// +optional
+	Database *DatabaseSource `json:"database,omitempty"`
+
+	// Resources dumps Kubernetes objects into the snapshot, alone or together with the
+	// data of a pvc or pvcs source.
+	// +optional
+	Resources *ResourcesSource `json:"resources,omitempty"`
 }
 
 // ResticConfig defines restic-specific configuration.
 type ResticConfig struct {
 	// Hostname is the hostname for snapshots. Defaults to the CR name.
+	// Supports Go template variables: {{ .Namespace }}, {{ .Name }} and {{ .PVC }}.
+	// With a pvcs source {{ .PVC }} is the claim names joined by dashes.
 	// +optional
 	Hostname string `json:"hostname,omitempty"`
 
@@ -89,14 +213,27 @@ type ResticConfig struct {
 	// +optional
 	Tags []string `json:"tags,omitempty"`
 
+	// Normalization normalizes the hostname and tags of the snapshots. DNS converts them
+	// to lowercase and replaces characters not valid in DNS names with dashes, and
+	// truncates the hostname to 63 characters. None uses them as configured.
+	// +kubebuilder:validation:Enum=None;DNS
+	// +optional
+	Normalization string `json:"normalization,omitempty"`
+
 	// ExtraArgs are additional restic backup arguments.
 	// +optional
 	ExtraArgs []string `json:"extraArgs,omitempty"`
 
-	// Image is the container image for restic.
-	// +kubebuilder:default="ghcr.io/restic/restic:0.18.0"
+	// Image is the container image for restic. Defaults to the restic image
+	// configured in the operator.
 	// +optional
 	Image string `json:"image,omitempty"`
+
+	// UnlockStaleLocks runs restic unlock before each backup, removing stale locks
+	// left by runs that were killed, e.g. during a node drain. Locks of running
+	// restic processes are kept.
+	// +optional
+	UnlockStaleLocks bool `json:"unlockStaleLocks,omitempty"`
 }
 
 // RetentionConfig configures snapshot retention.
@@ -113,11 +250,83 @@ type RetentionConfig struct {
 	// +optional
 	Prune bool `json:"prune,omitempty"`
 
-	// GroupBy specifies the grouping for retention. Defaults to "host,tags".
+	// GroupBy specifies the grouping for retention. Defaults to "host", because the
+	// operator tags snapshots with its version.
 	// +optional
 	GroupBy []string `json:"groupBy,omitempty"`
 }
 
+// EffectiveRetention reports the retention applied after each backup.
+type EffectiveRetention struct {
+	// Source is the kind of the resource the retention is configured in:
+	// ResticBackup or ResticRepository.
+	Source string `json:"source"`
+
+	// Policy is the retention policy.
+	// +optional
+	Policy *RetentionPolicy `json:"policy,omitempty"`
+
+	// Prune reports whether prune runs after forget.
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+
+	// GroupBy is the grouping for retention.
+	// +optional
+	GroupBy []string `json:"groupBy,omitempty"`
+}
+
+// SuspendMode defines how a suspended backup is paused.
+// +kubebuilder:validation:Enum=Schedule;Drain
+type SuspendMode string
+
+const (
+	// SuspendModeSchedule only suspends scheduling, running backup jobs are not awaited.
+	SuspendModeSchedule SuspendMode = "Schedule"
+	// SuspendModeDrain suspends scheduling and waits for running backup jobs to finish.
+	SuspendModeDrain SuspendMode = "Drain"
+)
+
+// PauseStatus records a suspension of the backup.
+type PauseStatus struct {
+	// Since is when the suspension was observed.
+	Since metav1.Time `json:"since"`
+
+	// SkippedRuns is the number of scheduled runs skipped since then.
+	SkippedRuns int32 `json:"skippedRuns"`
+
+	// RunningJobs is the number of backup jobs that haven't finished yet.
+	// +optional
+	RunningJobs int32 `json:"runningJobs,omitempty"`
+}
+
+// SourcePVCStatus records the source PVC of a backup, so an equivalent PVC can be
+// created when restoring into another cluster.
+type SourcePVCStatus struct {
+	// ClaimName is the name of the source PVC.
+	ClaimName string `json:"claimName"`
+
+	// StorageClassName is the storage class of the PVC.
+	// +optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// Capacity is the capacity of the PVC, e.g. "10Gi".
+	// +optional
+	Capacity string `json:"capacity,omitempty"`
+
+	// AccessModes are the access modes of the PVC.
+	// +optional
+	AccessModes []string `json:"accessModes,omitempty"`
+
+	// Labels are the labels of the PVC, without the labels of the operator.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are the annotations of the PVC, without the annotations set by
+	// Kubernetes and the operator.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
 // BackupRunStatus contains information about a backup run.
 type BackupRunStatus struct {
 	// StartTime is when the backup started.
@@ -139,6 +348,40 @@ type BackupRunStatus struct {
 	// Result is the backup result: Succeeded, Failed, PartiallyFailed.
 	// +optional
 	Result string `json:"result,omitempty"`
+
+	// FailureMessage is the end of the log of a job that did not succeed, or the reason
+	// it failed if its log is not available.
+	// +optional
+	FailureMessage string `json:"failureMessage,omitempty"`
+}
+
+// DiscoveredPVC is the state of the backup of a PVC matched by a pvcSelector source.
+type DiscoveredPVC struct {
+	// Namespace is the namespace of the PVC.
+	Namespace string `json:"namespace"`
+
+	// ClaimName is the name of the PVC.
+	ClaimName string `json:"claimName"`
+
+	// Backup is the name of the ResticBackup created for the PVC, in its namespace.
+	Backup string `json:"backup"`
+
+	// LastBackup contains information about the last backup of the PVC.
+	// +optional
+	LastBackup *BackupRunStatus `json:"lastBackup,omitempty"`
+
+	// Message describes why the PVC isn't backed up.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// BackupDataSample records the amount of data added by a single backup run.
+type BackupDataSample struct {
+	// Time is when the backup completed.
+	Time metav1.Time `json:"time"`
+
+	// DataAdded is the number of bytes the backup added to the repository.
+	DataAdded int64 `json:"dataAdded"`
 }
 
 // BackupStatistics contains backup statistics.
@@ -155,6 +398,14 @@ type BackupStatistics struct {
 	// +optional
 	FailedBackups int32 `json:"failedBackups,omitempty"`
 
+	// ConsecutiveFailures is the number of backups that failed since the last successful one.
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// RetriedBackups is the number of retry jobs created after failed backups.
+	// +optional
+	RetriedBackups int32 `json:"retriedBackups,omitempty"`
+
 	// LastBackupSize is the size of the last backup.
 	// +optional
 	LastBackupSize string `json:"lastBackupSize,omitempty"`
@@ -164,12 +415,137 @@ type BackupStatistics struct {
 	LastBackupFiles int64 `json:"lastBackupFiles,omitempty"`
 }
 
+// BackupWindow restricts the scheduled backups to time ranges on days of the week.
+type BackupWindow struct {
+	// Days are the days of the week the time ranges apply to. Empty means every day.
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+
+	// Ranges are the allowed time ranges of the days, in the timezone of the backup.
+	// +kubebuilder:validation:MinItems=1
+	Ranges []TimeRange `json:"ranges"`
+}
+
+// Weekday is a day of the week.
+// +kubebuilder:validation:Enum=Monday;Tuesday;Wednesday;Thursday;Friday;Saturday;Sunday
+type Weekday string
+
+// TimeRange is a time range within a day.
+type TimeRange struct {
+	// Start is the start of the range as HH:MM.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// End is the end of the range as HH:MM. An end before the start ends the range
+	// on the following day, e.g. 22:00-06:00.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	End string `json:"end"`
+}
+
+// BlackoutPeriod is a period without scheduled backups, e.g. a maintenance freeze.
+type BlackoutPeriod struct {
+	// Name describes the period in the SuspendedByWindow condition.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Start is the start of the period.
+	Start metav1.Time `json:"start"`
+
+	// End is the end of the period.
+	End metav1.Time `json:"end"`
+}
+
+// AutoTuneResources configures raising the memory limit of the backup container after
+// a backup job was OOMKilled. restic's memory usage grows with the repository index.
+type AutoTuneResources struct {
+	// MaxMemory caps the raised memory limit.
+	// +kubebuilder:validation:Required
+	MaxMemory resource.Quantity `json:"maxMemory"`
+
+	// MemoryIncreasePercent is by how much the memory limit is raised after an OOM kill.
+	// +kubebuilder:default=50
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:validation:Maximum=400
+	// +optional
+	MemoryIncreasePercent int32 `json:"memoryIncreasePercent,omitempty"`
+}
+
+// BackupResourcesStatus records the OOM kills of backup jobs and the memory limit
+// suggested or applied after them.
+type BackupResourcesStatus struct {
+	// OOMKills is the number of backup jobs killed for exceeding their memory limit.
+	// +optional
+	OOMKills int32 `json:"oomKills,omitempty"`
+
+	// LastOOMKill is when a backup job was last OOMKilled.
+	// +optional
+	LastOOMKill *metav1.Time `json:"lastOOMKill,omitempty"`
+
+	// LastOOMKilledJob is the name of the last OOMKilled backup job.
+	// +optional
+	LastOOMKilledJob string `json:"lastOOMKilledJob,omitempty"`
+
+	// SuggestedMemoryLimit is the memory limit suggested after the last OOM kill.
+	// Empty if the backup container has no memory limit.
+	// +optional
+	SuggestedMemoryLimit string `json:"suggestedMemoryLimit,omitempty"`
+
+	// MemoryLimit is the memory limit of the backup container raised by
+	// autoTuneResources. The limit of jobConfig.resources applies if it is higher.
+	// +optional
+	MemoryLimit string `json:"memoryLimit,omitempty"`
+}
+
+// BackupRetryPolicy configures retrying a failed backup job before the next scheduled
+// run.
+type BackupRetryPolicy struct {
+	// MaxRetries is the number of retry jobs created after a backup job failed.
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	MaxRetries int32 `json:"maxRetries,omitempty"`
+
+	// Backoff is the wait after the failed job before the first retry. It doubles for
+	// every further retry, up to one hour.
+	// +kubebuilder:default="5m"
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+}
+
+// BackupRetryStatus records the retries of a failed backup job.
+type BackupRetryStatus struct {
+	// FailedJob is the backup job whose failure is retried.
+	FailedJob string `json:"failedJob"`
+
+	// Attempts is the number of retry jobs created for the failed job.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// NextRetry is when the next retry job is created. Empty once the retries are
+	// exhausted or a retry was skipped.
+	// +optional
+	NextRetry *metav1.Time `json:"nextRetry,omitempty"`
+}
+
 // ResticBackupSpec defines the desired state of ResticBackup.
 type ResticBackupSpec struct {
 	// RepositoryRef references the ResticRepository to use.
 	// +kubebuilder:validation:Required
 	RepositoryRef CrossNamespaceObjectReference `json:"repositoryRef"`
 
+	// FallbackRepositoryRef references a ResticRepository used automatically while
+	// the primary repository is not ready for longer than FallbackAfter.
+	// Snapshots written to it are tagged "fallback".
+	// +optional
+	FallbackRepositoryRef *CrossNamespaceObjectReference `json:"fallbackRepositoryRef,omitempty"`
+
+	// FallbackAfter is how long the primary repository must be not ready before
+	// the fallback repository is used.
+	// +kubebuilder:default="30m"
+	// +optional
+	FallbackAfter *metav1.Duration `json:"fallbackAfter,omitempty"`
+
 	// Schedule is the backup schedule in cron format.
 	// +kubebuilder:validation:Required
 	Schedule string `json:"schedule"`
@@ -191,7 +567,8 @@ type ResticBackupSpec struct {
 	// +optional
 	Hooks *BackupHooks `json:"hooks,omitempty"`
 
-	// Retention configures snapshot retention.
+	// Retention configures snapshot retention. Defaults to the default retention
+	// of the repository.
 	// +optional
 	Retention *RetentionConfig `json:"retention,omitempty"`
 
@@ -207,6 +584,46 @@ type ResticBackupSpec struct {
 	// +kubebuilder:default=false
 	// +optional
 	Suspend bool `json:"suspend,omitempty"`
+
+	// SuspendMode defines how suspend pauses the backup. Schedule only suspends the
+	// CronJob. Drain also waits for the running backup jobs to finish and sets the
+	// Paused condition once none is left.
+	// +kubebuilder:default=Schedule
+	// +optional
+	SuspendMode SuspendMode `json:"suspendMode,omitempty"`
+
+	// BackupWindow restricts the scheduled backups to time ranges on days of the week.
+	// The CronJob is suspended outside of the window.
+	// +optional
+	BackupWindow *BackupWindow `json:"backupWindow,omitempty"`
+
+	// BlackoutPeriods are periods without scheduled backups, e.g. maintenance freezes.
+	// The CronJob is suspended during the periods.
+	// +optional
+	BlackoutPeriods []BlackoutPeriod `json:"blackoutPeriods,omitempty"`
+
+	// AutoTuneResources raises the memory limit of the backup container after an OOM
+	// kill, up to a cap, and retries the backup. Without it, OOM kills are only recorded
+	// in status.resources with a suggested limit.
+	// +optional
+	AutoTuneResources *AutoTuneResources `json:"autoTuneResources,omitempty"`
+
+	// Retry retries a failed backup job after a backoff instead of waiting for the next
+	// scheduled run.
+	// +optional
+	Retry *BackupRetryPolicy `json:"retry,omitempty"`
+
+	// MaxStaleness is how old the last successful backup may get before the Degraded
+	// condition reports a missed backup. Without it, a backup is missed once two
+	// scheduled runs passed without a successful backup.
+	// +optional
+	MaxStaleness *metav1.Duration `json:"maxStaleness,omitempty"`
+
+	// RenderOnly renders the generated CronJob into a ConfigMap instead of creating it,
+	// so the manifest can be reviewed before the backup is enabled. An existing
+	// CronJob of the backup is deleted.
+	// +optional
+	RenderOnly bool `json:"renderOnly,omitempty"`
 }
 
 // ResticBackupStatus defines the observed state of ResticBackup.
@@ -231,6 +648,41 @@ type ResticBackupStatus struct {
 	// +optional
 	Statistics *BackupStatistics `json:"statistics,omitempty"`
 
+	// DataAddedHistory contains the data added by the most recent successful backups.
+	// It is used to suggest a schedule matching the data-change rate.
+	// +kubebuilder:validation:MaxItems=48
+	// +optional
+	DataAddedHistory []BackupDataSample `json:"dataAddedHistory,omitempty"`
+
+	// ScheduleRecommendation is an advisory suggestion for the backup schedule based on
+	// the data-change rate. Empty if the schedule fits or there is not enough history.
+	// The schedule is never changed automatically.
+	// +optional
+	ScheduleRecommendation string `json:"scheduleRecommendation,omitempty"`
+
+	// Hooks contains the result of the last run of each hook.
+	// +optional
+	Hooks []HookStatus `json:"hooks,omitempty"`
+
+	// Pause records since when the backup is suspended and the scheduled runs skipped.
+	// Empty while the backup is not suspended.
+	// +optional
+	Pause *PauseStatus `json:"pause,omitempty"`
+
+	// ScaledDownWorkload is the workload currently scaled down for a backup job.
+	// +optional
+	ScaledDownWorkload *WorkloadReference `json:"scaledDownWorkload,omitempty"`
+
+	// EffectiveRetention is the retention applied after each backup. Empty if no
+	// retention is configured and snapshots are never forgotten.
+	// +optional
+	EffectiveRetention *EffectiveRetention `json:"effectiveRetention,omitempty"`
+
+	// SourcePVC records the storage class, capacity, access modes and metadata of the
+	// source PVC. All but the annotations are also stored as snapshot tags.
+	// +optional
+	SourcePVC *SourcePVCStatus `json:"sourcePVC,omitempty"`
+
 	// LastRetentionRun is the timestamp of the last retention run.
 	// +optional
 	LastRetentionRun *metav1.Time `json:"lastRetentionRun,omitempty"`
@@ -239,10 +691,29 @@ type ResticBackupStatus struct {
 	// +optional
 	SnapshotsAfterRetention int32 `json:"snapshotsAfterRetention,omitempty"`
 
+	// Resources records OOM kills of backup jobs and the memory limit raised after them.
+	// +optional
+	Resources *BackupResourcesStatus `json:"resources,omitempty"`
+
+	// Retry records the retries of the last failed backup job. Empty once a backup
+	// succeeded.
+	// +optional
+	Retry *BackupRetryStatus `json:"retry,omitempty"`
+
+	// DiscoveredPVCs are the PVCs matched by a pvcSelector source, sorted by namespace
+	// and name.
+	// +optional
+	DiscoveredPVCs []DiscoveredPVC `json:"discoveredPVCs,omitempty"`
+
 	// CronJobRef references the managed CronJob.
 	// +optional
 	CronJobRef *ObjectReference `json:"cronJobRef,omitempty"`
 
+	// RenderedManifestRef references the ConfigMap holding the rendered CronJob
+	// of a renderOnly backup.
+	// +optional
+	RenderedManifestRef *ObjectReference `json:"renderedManifestRef,omitempty"`
+
 	// ObservedGeneration reflects the generation of the spec observed by the controller.
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
@@ -254,6 +725,7 @@ type ResticBackupStatus struct {
 // +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule"
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
 // +kubebuilder:printcolumn:name="Last Backup",type="date",JSONPath=".status.lastSuccessfulBackup"
+// +kubebuilder:printcolumn:name="Retention",type="string",JSONPath=".status.effectiveRetention.source",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // ResticBackup is the Schema for the resticbackups API.
@@ -0,0 +1,124 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResticCheckSpec defines the desired state of ResticCheck.
+type ResticCheckSpec struct {
+	// RepositoryRef references the ResticRepository to check.
+	// +kubebuilder:validation:Required
+	RepositoryRef CrossNamespaceObjectReference `json:"repositoryRef"`
+
+	// Schedule is the cron schedule for the check.
+	// +kubebuilder:validation:Required
+	Schedule string `json:"schedule"`
+
+	// Timezone for schedule interpretation. Defaults to UTC.
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// ReadDataSubset is the part of the pack files read and verified on each run,
+	// passed to restic check as --read-data-subset. Either a percentage ("10%")
+	// or a fixed subset ("1/5"). Percentages select random packs on every run.
+	// +kubebuilder:default="10%"
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?%|[0-9]+/[0-9]+)$`
+	// +optional
+	ReadDataSubset string `json:"readDataSubset,omitempty"`
+
+	// Image is the container image for restic. Defaults to the restic image
+	// configured in the operator.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// JobConfig configures the check job.
+	// +optional
+	JobConfig *JobConfiguration `json:"jobConfig,omitempty"`
+
+	// Suspend suspends check scheduling.
+	// +kubebuilder:default=false
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// ResticCheckStatus defines the observed state of ResticCheck.
+type ResticCheckStatus struct {
+	// Conditions represent the latest available observations.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastCheck is the timestamp of the last finished check.
+	// +optional
+	LastCheck *metav1.Time `json:"lastCheck,omitempty"`
+
+	// LastCheckResult is the result of the last check: Passed or Failed.
+	// +optional
+	LastCheckResult string `json:"lastCheckResult,omitempty"`
+
+	// LastSuccessfulCheck is the timestamp of the last passed check.
+	// +optional
+	LastSuccessfulCheck *metav1.Time `json:"lastSuccessfulCheck,omitempty"`
+
+	// LastCheckJob is the name of the last evaluated check job.
+	// +optional
+	LastCheckJob string `json:"lastCheckJob,omitempty"`
+
+	// NextCheck is the timestamp of the next scheduled check.
+	// +optional
+	NextCheck *metav1.Time `json:"nextCheck,omitempty"`
+
+	// CronJobRef references the managed CronJob.
+	// +optional
+	CronJobRef *ObjectReference `json:"cronJobRef,omitempty"`
+
+	// ObservedGeneration reflects the generation of the spec observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=rchk
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule"
+// +kubebuilder:printcolumn:name="Subset",type="string",JSONPath=".spec.readDataSubset"
+// +kubebuilder:printcolumn:name="Result",type="string",JSONPath=".status.lastCheckResult"
+// +kubebuilder:printcolumn:name="Last Check",type="date",JSONPath=".status.lastCheck"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ResticCheck is the Schema for the resticchecks API.
+type ResticCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResticCheckSpec   `json:"spec,omitempty"`
+	Status ResticCheckStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResticCheckList contains a list of ResticCheck.
+type ResticCheckList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResticCheck `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResticCheck{}, &ResticCheckList{})
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PruneOptions configures prune behavior.
+type PruneOptions struct {
+	// MaxUnused is the amount of unused space to tolerate, e.g. "5%" or "unlimited".
+	// Passed to restic prune as --max-unused.
+	// +optional
+	MaxUnused string `json:"maxUnused,omitempty"`
+
+	// MaxRepackSize limits the amount of data repacked, e.g. "10G".
+	// Passed to restic prune as --max-repack-size.
+	// +optional
+	MaxRepackSize string `json:"maxRepackSize,omitempty"`
+
+	// RepackCacheableOnly only repacks packs containing tree blobs.
+	// +optional
+	RepackCacheableOnly bool `json:"repackCacheableOnly,omitempty"`
+}
+
+// PrunePhase represents the current phase of a prune operation.
+// +kubebuilder:validation:Enum=Pending;InProgress;Completed;Failed
+type PrunePhase string
+
+const (
+	// PrunePhasePending indicates the prune has not started.
+	PrunePhasePending PrunePhase = "Pending"
+	// PrunePhaseInProgress indicates the prune is running.
+	PrunePhaseInProgress PrunePhase = "InProgress"
+	// PrunePhaseCompleted indicates the prune completed successfully.
+	PrunePhaseCompleted PrunePhase = "Completed"
+	// PrunePhaseFailed indicates the prune failed.
+	PrunePhaseFailed PrunePhase = "Failed"
+)
+
+// ResticPruneSpec defines the desired state of ResticPrune.
+type ResticPruneSpec struct {
+	// RepositoryRef references the ResticRepository to prune.
+	// +kubebuilder:validation:Required
+	RepositoryRef CrossNamespaceObjectReference `json:"repositoryRef"`
+
+	// Image is the container image for restic. Defaults to the restic image
+	// configured in the operator.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Options configures prune behavior.
+	// +optional
+	Options *PruneOptions `json:"options,omitempty"`
+
+	// JobConfig configures the prune job.
+	// +optional
+	JobConfig *JobConfiguration `json:"jobConfig,omitempty"`
+}
+
+// ResticPruneStatus defines the observed state of ResticPrune.
+type ResticPruneStatus struct {
+	// Conditions represent the latest available observations.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Phase is the current phase of the prune operation.
+	// +optional
+	Phase PrunePhase `json:"phase,omitempty"`
+
+	// StartTime is when the prune started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the prune completed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// PacksDeleted is the number of pack files deleted from the repository.
+	// +optional
+	PacksDeleted int64 `json:"packsDeleted,omitempty"`
+
+	// BytesFreed is the number of bytes freed in the repository.
+	// +optional
+	BytesFreed int64 `json:"bytesFreed,omitempty"`
+
+	// SpaceFreed is the human-readable amount of space freed.
+	// +optional
+	SpaceFreed string `json:"spaceFreed,omitempty"`
+
+	// JobRef references the prune job.
+	// +optional
+	JobRef *ObjectReference `json:"jobRef,omitempty"`
+
+	// ObservedGeneration reflects the generation of the spec observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=rprune
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Repository",type="string",JSONPath=".spec.repositoryRef.name"
+// +kubebuilder:printcolumn:name="Packs Deleted",type="integer",JSONPath=".status.packsDeleted"
+// +kubebuilder:printcolumn:name="Freed",type="string",JSONPath=".status.spaceFreed"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ResticPrune is the Schema for the resticprunes API.
+type ResticPrune struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResticPruneSpec   `json:"spec,omitempty"`
+	Status ResticPruneStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResticPruneList contains a list of ResticPrune.
+type ResticPruneList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResticPrune `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResticPrune{}, &ResticPruneList{})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReferenceGrantFrom describes the resources allowed to reference the resources of a grant.
+type ReferenceGrantFrom struct {
+	// Kind is the kind of the referencing resource.
+	// +kubebuilder:validation:Enum=ResticBackup;ResticRestore;ResticPrune;ResticCheck;ResticReplication;GlobalRetentionPolicy
+	Kind string `json:"kind"`
+
+	// Namespace is the namespace of the referencing resources.
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+}
+
+// ReferenceGrantTo describes the resources of the grant namespace that may be referenced.
+type ReferenceGrantTo struct {
+	// Kind is the kind of the referenced resource.
+	// +kubebuilder:validation:Enum=ResticRepository;ResticBackup
+	Kind string `json:"kind"`
+
+	// Name restricts the grant to a single resource. All resources of the kind may be
+	// referenced if empty.
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// ResticReferenceGrantSpec defines the references permitted by a ResticReferenceGrant.
+type ResticReferenceGrantSpec struct {
+	// From lists the resources in other namespaces allowed to reference the resources in To.
+	// +kubebuilder:validation:MinItems=1
+	From []ReferenceGrantFrom `json:"from"`
+
+	// To lists the resources in the namespace of the grant that may be referenced.
+	// +kubebuilder:validation:MinItems=1
+	To []ReferenceGrantTo `json:"to"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=rgrant
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ResticReferenceGrant permits resources in other namespaces to reference the repositories
+// and backups of its namespace. Cross-namespace references without a grant are denied.
+type ResticReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ResticReferenceGrantSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResticReferenceGrantList contains a list of ResticReferenceGrant.
+type ResticReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResticReferenceGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResticReferenceGrant{}, &ResticReferenceGrantList{})
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResticReplicationSpec defines the desired state of ResticReplication.
+type ResticReplicationSpec struct {
+	// SourceRepositoryRef references the primary ResticRepository the snapshots are
+	// copied from.
+	// +kubebuilder:validation:Required
+	SourceRepositoryRef CrossNamespaceObjectReference `json:"sourceRepositoryRef"`
+
+	// DestinationRepositoryRef references the secondary ResticRepository the snapshots
+	// are copied to.
+	// +kubebuilder:validation:Required
+	DestinationRepositoryRef CrossNamespaceObjectReference `json:"destinationRepositoryRef"`
+
+	// Schedule is the cron schedule for the replication.
+	// +kubebuilder:validation:Required
+	Schedule string `json:"schedule"`
+
+	// Timezone for schedule interpretation. Defaults to UTC.
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// Hosts restricts the replication to the snapshots of these hostnames.
+	// +optional
+	Hosts []string `json:"hosts,omitempty"`
+
+	// Tags restricts the replication to the snapshots with these tags.
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// Image is the container image for restic. Defaults to the restic image
+	// configured in the operator.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// JobConfig configures the replication job.
+	// +optional
+	JobConfig *JobConfiguration `json:"jobConfig,omitempty"`
+
+	// Suspend suspends replication scheduling.
+	// +kubebuilder:default=false
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// ResticReplicationStatus defines the observed state of ResticReplication.
+type ResticReplicationStatus struct {
+	// Conditions represent the latest available observations.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastReplication is the timestamp of the last finished replication.
+	// +optional
+	LastReplication *metav1.Time `json:"lastReplication,omitempty"`
+
+	// LastReplicationResult is the result of the last replication: Succeeded or Failed.
+	// +optional
+	LastReplicationResult string `json:"lastReplicationResult,omitempty"`
+
+	// LastSuccessfulReplication is the timestamp of the last successful replication.
+	// +optional
+	LastSuccessfulReplication *metav1.Time `json:"lastSuccessfulReplication,omitempty"`
+
+	// LastReplicationJob is the name of the last evaluated replication job.
+	// +optional
+	LastReplicationJob string `json:"lastReplicationJob,omitempty"`
+
+	// NextReplication is the timestamp of the next scheduled replication.
+	// +optional
+	NextReplication *metav1.Time `json:"nextReplication,omitempty"`
+
+	// ReplicatedSnapshots are the short IDs of the newest source snapshots found in the
+	// destination repository, newest first, limited to 100.
+	// +optional
+	ReplicatedSnapshots []string `json:"replicatedSnapshots,omitempty"`
+
+	// ReplicatedSnapshotCount is the number of source snapshots found in the destination
+	// repository.
+	// +optional
+	ReplicatedSnapshotCount int32 `json:"replicatedSnapshotCount,omitempty"`
+
+	// PendingSnapshotCount is the number of source snapshots not copied yet.
+	// +optional
+	PendingSnapshotCount int32 `json:"pendingSnapshotCount,omitempty"`
+
+	// CronJobRef references the managed CronJob.
+	// +optional
+	CronJobRef *ObjectReference `json:"cronJobRef,omitempty"`
+
+	// ObservedGeneration reflects the generation of the spec observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=rrepl
+// +kubebuilder:printcolumn:name="Source",type="string",JSONPath=".spec.sourceRepositoryRef.name"
+// +kubebuilder:printcolumn:name="Destination",type="string",JSONPath=".spec.destinationRepositoryRef.name"
+// +kubebuilder:printcolumn:name="Result",type="string",JSONPath=".status.lastReplicationResult"
+// +kubebuilder:printcolumn:name="Pending",type="integer",JSONPath=".status.pendingSnapshotCount"
+// +kubebuilder:printcolumn:name="Last Replication",type="date",JSONPath=".status.lastReplication"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ResticReplication is the Schema for the resticreplications API. It copies the snapshots
+// of a primary repository to a secondary repository on a schedule.
+type ResticReplication struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResticReplicationSpec   `json:"spec,omitempty"`
+	Status ResticReplicationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResticReplicationList contains a list of ResticReplication.
+type ResticReplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResticReplication `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResticReplication{}, &ResticReplicationList{})
+}
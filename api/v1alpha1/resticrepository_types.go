@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,6 +32,14 @@ type IntegrityCheckConfig struct {
 	// +kubebuilder:validation:Pattern=`^(@(annually|yearly|monthly|weekly|daily|hourly))|(((\d+,)*\d+|(\d+(\/|-)\d+)|\d+|\*)\s?){5}$`
 	// +optional
 	Schedule string `json:"schedule,omitempty"`
+
+	// ReadDataSubsets splits the data verification into N subsets. Each scheduled
+	// check reads the next subset (--read-data-subset=n/N), so the whole data set
+	// is verified once every N checks without a single large IO spike.
+	// If unset, only the repository structure is checked.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ReadDataSubsets *int32 `json:"readDataSubsets,omitempty"`
 }
 
 // CacheConfig configures the restic cache.
@@ -46,6 +56,44 @@ type CacheConfig struct {
 	// StorageClassName is the storage class for the cache PVC.
 	// +optional
 	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// AccessMode is the access mode of the cache PVC. With ReadWriteOnce, jobs of the
+	// repository running at the same time on another node fail to mount the PVC.
+	// ReadWriteMany lets them run on any node if the storage class supports it.
+	// Changing it does not replace an existing cache PVC.
+	// +kubebuilder:validation:Enum=ReadWriteMany;ReadWriteOnce
+	// +kubebuilder:default=ReadWriteOnce
+	// +optional
+	AccessMode corev1.PersistentVolumeAccessMode `json:"accessMode,omitempty"`
+
+	// CleanupSchedule is the cron schedule for removing stale cache data.
+	// +kubebuilder:default="@daily"
+	// +optional
+	CleanupSchedule string `json:"cleanupSchedule,omitempty"`
+
+	// MaxAgeDays is the number of days after which unused cache data is removed.
+	// +kubebuilder:default=30
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxAgeDays *int32 `json:"maxAgeDays,omitempty"`
+}
+
+// CacheStatus reports the state of the repository cache.
+type CacheStatus struct {
+	// PVCName is the name of the cache PVC.
+	PVCName string `json:"pvcName"`
+
+	// Size is the size of the cache after the last cleanup.
+	// +optional
+	Size string `json:"size,omitempty"`
+
+	// LastCleanup is the timestamp of the last successful cache cleanup.
+	// +optional
+	LastCleanup *metav1.Time `json:"lastCleanup,omitempty"`
+
+	// LastCleanupJob is the name of the last evaluated cache cleanup Job.
+	// +optional
+	LastCleanupJob string `json:"lastCleanupJob,omitempty"`
 }
 
 // RepositoryStatistics contains repository statistics.
@@ -61,6 +109,26 @@ type RepositoryStatistics struct {
 	// SnapshotCount is the number of snapshots in the repository.
 	// +optional
 	SnapshotCount int32 `json:"snapshotCount,omitempty"`
+
+	// LastUpdated is the time the statistics were collected.
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+// CredentialsKeyMapping maps repository credentials to keys of the credentials secret.
+type CredentialsKeyMapping struct {
+	// Password is the key of the repository password.
+	// Defaults to credentialsSecretRef.key or RESTIC_PASSWORD.
+	// +optional
+	Password string `json:"password,omitempty"`
+
+	// AWSAccessKeyID is the key of the S3 access key ID. Defaults to AWS_ACCESS_KEY_ID.
+	// +optional
+	AWSAccessKeyID string `json:"awsAccessKeyID,omitempty"`
+
+	// AWSSecretAccessKey is the key of the S3 secret access key. Defaults to AWS_SECRET_ACCESS_KEY.
+	// +optional
+	AWSSecretAccessKey string `json:"awsSecretAccessKey,omitempty"`
 }
 
 // ResticRepositorySpec defines the desired state of ResticRepository.
@@ -71,10 +139,50 @@ type ResticRepositorySpec struct {
 	RepositoryURL string `json:"repositoryURL"`
 
 	// CredentialsSecretRef references the secret containing repository credentials.
-	// Expected keys: RESTIC_PASSWORD (required), AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (for S3).
+	// Expected keys: RESTIC_PASSWORD (required), AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (for S3),
+	// AZURE_ACCOUNT_NAME, AZURE_ACCOUNT_KEY, AZURE_ACCOUNT_SAS (for Azure),
+	// GOOGLE_PROJECT_ID, GOOGLE_APPLICATION_CREDENTIALS (for GCS), B2_ACCOUNT_ID,
+	// B2_ACCOUNT_KEY (for B2), SSH_PRIVATE_KEY, SSH_KNOWN_HOSTS (for SFTP) and
+	// RESTIC_REST_USERNAME, RESTIC_REST_PASSWORD (for REST server).
+	// Key overrides the key of the repository password.
 	// +kubebuilder:validation:Required
 	CredentialsSecretRef SecretKeySelector `json:"credentialsSecretRef"`
 
+	// RepositoryID pins the ID of the restic repository, as shown by "restic cat config".
+	// Defaults to the ID read when the repository was first probed. If the backend
+	// serves a repository with another ID, e.g. after a bucket was swapped or the URL
+	// changed, the repository is not ready and no backup or restore uses it. Set it to
+	// the new ID to accept a replaced repository.
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{64}$`
+	// +optional
+	RepositoryID string `json:"repositoryID,omitempty"`
+
+	// CredentialsKeyMapping maps the credentials to differently named keys of the
+	// credentials secret, e.g. for secrets created by other tools.
+	// +optional
+	CredentialsKeyMapping *CredentialsKeyMapping `json:"credentialsKeyMapping,omitempty"`
+
+	// EnvFromSecret references a secret whose keys are all passed to the restic
+	// containers of the jobs as environment variables, e.g. RESTIC_COMPRESSION or
+	// RCLONE_* variables. Variables set from the credentials secret take precedence.
+	// +optional
+	EnvFromSecret *corev1.LocalObjectReference `json:"envFromSecret,omitempty"`
+
+	// CredentialsCheckInterval is the interval of the credentials probe, which reads
+	// the repository config to verify the repository is reachable with its credentials.
+	// The probe is cheap and independent of the integrity check.
+	// +kubebuilder:default="5m"
+	// +optional
+	CredentialsCheckInterval *metav1.Duration `json:"credentialsCheckInterval,omitempty"`
+
+	// StatsInterval is the interval at which the repository statistics are gathered
+	// with restic stats, together with the snapshot listing and the retention report.
+	// restic stats reads the index of the whole repository, which can take long for
+	// huge repositories. "0" disables the statistics.
+	// +kubebuilder:default="1h"
+	// +optional
+	StatsInterval *metav1.Duration `json:"statsInterval,omitempty"`
+
 	// IntegrityCheck configures periodic repository integrity verification.
 	// +optional
 	IntegrityCheck *IntegrityCheckConfig `json:"integrityCheck,omitempty"`
@@ -82,6 +190,269 @@ type ResticRepositorySpec struct {
 	// Cache configures the restic cache.
 	// +optional
 	Cache *CacheConfig `json:"cache,omitempty"`
+
+	// DefaultRetention is the retention of ResticBackups using this repository
+	// that do not configure their own retention.
+	// +optional
+	DefaultRetention *RetentionConfig `json:"defaultRetention,omitempty"`
+
+	// DeletionPolicy defines what happens to the repository data when the ResticRepository
+	// is deleted. Retain keeps the data, Delete removes all snapshots and prunes the
+	// repository. Deletion is blocked while other resources reference the repository.
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +kubebuilder:default=Retain
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// DeletionConfirmation must repeat the RepositoryURL for deletionPolicy Delete. The
+	// data is deleted for every snapshot in the backend, including those of other
+	// ResticRepositories or clients sharing it.
+	// +optional
+	DeletionConfirmation string `json:"deletionConfirmation,omitempty"`
+
+	// CoordinateJobs serializes prune and retention jobs with the backup jobs of this
+	// repository through a Lease, so they don't fail on each other's restic locks.
+	// Backup jobs are then started by the operator.
+	// +optional
+	CoordinateJobs bool `json:"coordinateJobs,omitempty"`
+
+	// MaxConcurrentBackups limits the number of backup jobs of this repository running
+	// at the same time, so scheduled backups don't saturate the backend. Backup jobs are
+	// then started by the operator once a running backup finished.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrentBackups *int32 `json:"maxConcurrentBackups,omitempty"`
+
+	// SpaceCheck fails backup jobs before the upload if the repository backend is
+	// running out of space, instead of restic failing mid-upload.
+	// +optional
+	SpaceCheck *SpaceCheckConfig `json:"spaceCheck,omitempty"`
+
+	// SnapshotListing publishes the newest snapshots of the repository in
+	// status.snapshots, refreshed together with the repository statistics.
+	// +optional
+	SnapshotListing *SnapshotListingConfig `json:"snapshotListing,omitempty"`
+
+	// CheckStrategy defines where the statistics of the repository are gathered.
+	// InProcess runs restic in the operator, Job runs it in a short-lived Job whose
+	// result is read from its termination message, so slow backends and network access
+	// to them stay out of the operator pod. The scheduled integrity check always runs in
+	// a Job. The credentials probe runs in the operator, except for sftp repositories:
+	// the operator has no SSH client, so they require the Job strategy and are probed by a
+	// Job.
+	// +kubebuilder:validation:Enum=InProcess;Job
+	// +kubebuilder:default=InProcess
+	// +optional
+	CheckStrategy CheckStrategy `json:"checkStrategy,omitempty"`
+
+	// Heartbeat writes a heartbeat snapshot into the repository after each successful
+	// ResticCheck and GlobalRetentionPolicy run, so that a monitor reading only the
+	// object store can verify that the operator is alive.
+	// +optional
+	Heartbeat *HeartbeatConfig `json:"heartbeat,omitempty"`
+
+	// LatencyProbe times a cheap read of the repository config on an interval and
+	// exports the backend latency and availability as metrics, giving early warning of
+	// a degrading backend before backups time out.
+	// +optional
+	LatencyProbe *LatencyProbeConfig `json:"latencyProbe,omitempty"`
+
+	// ExpectedAvailability tells whether the backend is always reachable. An
+	// Intermittent backend, e.g. an offsite target behind a VPN link that is only up at
+	// night, is not reported as not ready while it is offline. Backup jobs are then
+	// started by the operator once a connectivity probe succeeded.
+	// +kubebuilder:validation:Enum=Always;Intermittent
+	// +kubebuilder:default=Always
+	// +optional
+	ExpectedAvailability ExpectedAvailability `json:"expectedAvailability,omitempty"`
+
+	// Intermittent configures when an Intermittent backend is expected to be reachable
+	// and how long it may be offline.
+	// +optional
+	Intermittent *IntermittentConfig `json:"intermittent,omitempty"`
+}
+
+// ExpectedAvailability defines whether the backend of a repository is always reachable.
+type ExpectedAvailability string
+
+const (
+	// ExpectedAvailabilityAlways reports the repository as not ready as soon as the
+	// backend is unreachable.
+	ExpectedAvailabilityAlways ExpectedAvailability = "Always"
+	// ExpectedAvailabilityIntermittent tolerates an unreachable backend and defers
+	// backup jobs until it is reachable again.
+	ExpectedAvailabilityIntermittent ExpectedAvailability = "Intermittent"
+)
+
+// IntermittentConfig configures the tolerance for an intermittently reachable backend.
+type IntermittentConfig struct {
+	// OnlineWindow is when the backend is expected to be reachable. Outside of it the
+	// backend being offline is expected and never reported. Empty means at any time.
+	// +optional
+	OnlineWindow *BackupWindow `json:"onlineWindow,omitempty"`
+
+	// Timezone of the online window.
+	// +kubebuilder:default="UTC"
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// MaxOfflineDuration is how long the backend may be unreachable while it is expected
+	// to be online before the repository is reported as not ready.
+	// +kubebuilder:default="1h"
+	// +optional
+	MaxOfflineDuration *metav1.Duration `json:"maxOfflineDuration,omitempty"`
+
+	// ProbeInterval is the time between two connectivity probes. It replaces the
+	// credentials check interval, so deferred backups start soon after the backend is
+	// reachable again.
+	// +kubebuilder:default="5m"
+	// +optional
+	ProbeInterval *metav1.Duration `json:"probeInterval,omitempty"`
+}
+
+// LatencyProbeConfig configures the backend latency probe of a repository.
+type LatencyProbeConfig struct {
+	// Interval is the time between two probes.
+	// +kubebuilder:default="1m"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Timeout fails probes that take longer.
+	// +kubebuilder:default="30s"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// CheckStrategy defines where the integrity check and the statistics of a repository run.
+type CheckStrategy string
+
+const (
+	// CheckStrategyInProcess runs restic check and stats in the operator.
+	CheckStrategyInProcess CheckStrategy = "InProcess"
+	// CheckStrategyJob runs restic check and stats in short-lived Jobs.
+	CheckStrategyJob CheckStrategy = "Job"
+)
+
+// SnapshotListingConfig configures the snapshot list in the repository status.
+type SnapshotListingConfig struct {
+	// MaxSnapshots is the number of newest snapshots listed. The size of the status
+	// is limited, so repositories with many snapshots list only the newest ones.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	// +kubebuilder:default=100
+	// +optional
+	MaxSnapshots *int32 `json:"maxSnapshots,omitempty"`
+}
+
+// SnapshotInfo describes a snapshot in the repository.
+type SnapshotInfo struct {
+	// ID is the short ID of the snapshot.
+	ID string `json:"id"`
+
+	// Time is the time the snapshot was taken.
+	Time metav1.Time `json:"time"`
+
+	// Hostname is the hostname of the snapshot.
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
+
+	// Tags are the tags of the snapshot.
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// Paths are the backed up paths.
+	// +optional
+	Paths []string `json:"paths,omitempty"`
+
+	// Size is the size of the backed up files. Only reported for snapshots taken with
+	// restic 0.17 or newer.
+	// +optional
+	Size string `json:"size,omitempty"`
+
+	// FileCount is the number of backed up files. Only reported for snapshots taken
+	// with restic 0.17 or newer.
+	// +optional
+	FileCount int64 `json:"fileCount,omitempty"`
+}
+
+// HeartbeatConfig configures the heartbeat snapshots written into a repository.
+type HeartbeatConfig struct {
+	// ClusterID identifies the cluster. It is the hostname of the heartbeat snapshots,
+	// which contain the file health/<clusterID>.json.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9]([-a-zA-Z0-9._]*[a-zA-Z0-9])?$`
+	ClusterID string `json:"clusterID"`
+}
+
+// RetentionReportStatus classifies the snapshot count of a backup.
+// +kubebuilder:validation:Enum=OK;OverRetention;UnderRetention;Unknown
+type RetentionReportStatus string
+
+const (
+	// RetentionReportOK means the snapshot count matches the schedule and retention policy.
+	RetentionReportOK RetentionReportStatus = "OK"
+	// RetentionReportOverRetention means there are more snapshots than the retention
+	// policy keeps, e.g. because forget isn't running.
+	RetentionReportOverRetention RetentionReportStatus = "OverRetention"
+	// RetentionReportUnderRetention means there are fewer snapshots than the schedule
+	// should have created, e.g. because backups are failing.
+	RetentionReportUnderRetention RetentionReportStatus = "UnderRetention"
+	// RetentionReportUnknown means no snapshot count is expected, e.g. because the
+	// backup has no retention policy or is suspended.
+	RetentionReportUnknown RetentionReportStatus = "Unknown"
+)
+
+// RetentionReport compares the snapshots of the backups of a repository with their
+// schedule and retention policy.
+type RetentionReport struct {
+	// LastUpdated is when the report was created.
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+
+	// Backups lists the report of each ResticBackup using the repository.
+	// +optional
+	Backups []BackupRetentionReport `json:"backups,omitempty"`
+}
+
+// BackupRetentionReport compares the snapshots of a backup with its schedule and
+// retention policy.
+type BackupRetentionReport struct {
+	// Backup is the namespace/name of the ResticBackup.
+	Backup string `json:"backup"`
+
+	// Status classifies the snapshot count.
+	Status RetentionReportStatus `json:"status"`
+
+	// ExpectedSnapshots is the number of snapshots the schedule and the retention
+	// policy of the backup should keep.
+	// +optional
+	ExpectedSnapshots *int32 `json:"expectedSnapshots,omitempty"`
+
+	// ActualSnapshots is the number of snapshots tagged with the backup.
+	ActualSnapshots int32 `json:"actualSnapshots"`
+
+	// NewestSnapshot is the time of the newest snapshot of the backup.
+	// +optional
+	NewestSnapshot *metav1.Time `json:"newestSnapshot,omitempty"`
+
+	// Message explains the status.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// SpaceCheckConfig configures the free space check of backup jobs. The free space is
+// probed with statvfs for local and sftp backends, other backends need a Capacity.
+type SpaceCheckConfig struct {
+	// MinFreeSpace is the free space the backend must have before a backup starts.
+	// +kubebuilder:validation:Required
+	MinFreeSpace resource.Quantity `json:"minFreeSpace"`
+
+	// Capacity is the quota of the repository, e.g. the --max-size of a REST server.
+	// The free space is then the capacity minus the size of the repository data
+	// instead of the free space of the backend file system.
+	// +optional
+	Capacity *resource.Quantity `json:"capacity,omitempty"`
 }
 
 // ResticRepositoryStatus defines the observed state of ResticRepository.
@@ -90,6 +461,15 @@ type ResticRepositoryStatus struct {
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 
+	// RepositoryID is the ID of the restic repository the backend served at the last
+	// probe that confirmed its identity.
+	// +optional
+	RepositoryID string `json:"repositoryID,omitempty"`
+
+	// LastCredentialsCheck is the timestamp of the last credentials probe.
+	// +optional
+	LastCredentialsCheck *metav1.Time `json:"lastCredentialsCheck,omitempty"`
+
 	// LastIntegrityCheck is the timestamp of the last integrity check.
 	// +optional
 	LastIntegrityCheck *metav1.Time `json:"lastIntegrityCheck,omitempty"`
@@ -98,21 +478,65 @@ type ResticRepositoryStatus struct {
 	// +optional
 	LastIntegrityCheckResult string `json:"lastIntegrityCheckResult,omitempty"`
 
+	// LastIntegrityCheckSubset is the data subset (1..N) read by the last integrity check.
+	// +optional
+	LastIntegrityCheckSubset int32 `json:"lastIntegrityCheckSubset,omitempty"`
+
 	// Statistics contains repository statistics.
 	// +optional
 	Statistics *RepositoryStatistics `json:"statistics,omitempty"`
 
+	// StatisticsUpdatedAt is the time the statistics were last gathered. It tells how
+	// current the size and snapshot count are, e.g. while spec.statsInterval is "0".
+	// +optional
+	StatisticsUpdatedAt *metav1.Time `json:"statisticsUpdatedAt,omitempty"`
+
+	// Snapshots lists the newest snapshots of the repository, newest first, if
+	// spec.snapshotListing is set. statistics.snapshotCount is the total number.
+	// +optional
+	Snapshots []SnapshotInfo `json:"snapshots,omitempty"`
+
+	// RetentionReport compares the snapshots of the backups of the repository with
+	// the number their schedule and retention policy should keep.
+	// +optional
+	RetentionReport *RetentionReport `json:"retentionReport,omitempty"`
+
+	// Cache reports the state of the repository cache.
+	// +optional
+	Cache *CacheStatus `json:"cache,omitempty"`
+
+	// LastFailure holds the restic error output of the last failed repository operation.
+	// It is cleared once the operation succeeds.
+	// +optional
+	LastFailure *RepositoryFailure `json:"lastFailure,omitempty"`
+
 	// ObservedGeneration reflects the generation of the spec observed by the controller.
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
+// RepositoryFailure describes a failed restic command run by the operator.
+type RepositoryFailure struct {
+	// Operation is the failed operation.
+	// +kubebuilder:validation:Enum=Init;Unlock;Check;Probe
+	Operation string `json:"operation"`
+
+	// Time is the time of the failure.
+	Time metav1.Time `json:"time"`
+
+	// Stderr holds the last lines of the restic error output, truncated to 1 KiB.
+	// Credentials are redacted.
+	// +optional
+	Stderr string `json:"stderr,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=rr
 // +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".spec.repositoryURL"
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
 // +kubebuilder:printcolumn:name="Snapshots",type="integer",JSONPath=".status.statistics.snapshotCount"
+// +kubebuilder:printcolumn:name="Stats Updated",type="date",JSONPath=".status.statisticsUpdatedAt",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // ResticRepository is the Schema for the resticrepositories API.
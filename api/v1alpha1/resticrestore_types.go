@@ -26,7 +26,7 @@ type SnapshotSelector struct {
 	// +optional
 	Latest bool `json:"latest,omitempty"`
 
-	// Tags filters snapshots by tags.
+	// Tags filters snapshots having all of these tags.
 	// +optional
 	Tags []string `json:"tags,omitempty"`
 
@@ -34,11 +34,36 @@ type SnapshotSelector struct {
 	// +optional
 	Hostname string `json:"hostname,omitempty"`
 
+	// Paths filters snapshots containing all of these paths, e.g. "/backup".
+	// +optional
+	Paths []string `json:"paths,omitempty"`
+
 	// Before selects the latest snapshot before this time.
 	// +optional
 	Before *metav1.Time `json:"before,omitempty"`
 }
 
+// RestoreChain restores several snapshots one after another into the same target, e.g.
+// a base backup followed by the archived write-ahead logs taken after it.
+// +kubebuilder:validation:XValidation:rule="has(self.snapshotIDs) != has(self.selector)",message="exactly one of snapshotIDs and selector must be set"
+type RestoreChain struct {
+	// SnapshotIDs are the snapshots to restore, in the listed order.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=100
+	// +optional
+	SnapshotIDs []string `json:"snapshotIDs,omitempty"`
+
+	// Selector selects the snapshots to restore. All matching snapshots are restored,
+	// oldest first. Latest is ignored, Before is the end of the time range.
+	// +optional
+	Selector *SnapshotSelector `json:"selector,omitempty"`
+
+	// After is the start of the time range of the selector. Snapshots taken before this
+	// time are not restored.
+	// +optional
+	After *metav1.Time `json:"after,omitempty"`
+}
+
 // PVCTarget defines a PVC as restore target.
 type PVCTarget struct {
 	// ClaimName is the name of the PVC to restore to.
@@ -50,12 +75,36 @@ type PVCTarget struct {
 	Path string `json:"path,omitempty"`
 }
 
+// PVCReclaimPolicy defines what happens to a PVC created for a restore when the
+// ResticRestore is deleted.
+// +kubebuilder:validation:Enum=Retain;Delete
+type PVCReclaimPolicy string
+
+const (
+	// PVCReclaimRetain keeps the PVC when the ResticRestore is deleted.
+	PVCReclaimRetain PVCReclaimPolicy = "Retain"
+	// PVCReclaimDelete deletes the PVC together with the ResticRestore.
+	PVCReclaimDelete PVCReclaimPolicy = "Delete"
+)
+
 // NewPVCTarget defines creating a new PVC for restore.
 type NewPVCTarget struct {
 	// Name is the name of the new PVC.
 	// +kubebuilder:validation:Required
 	Name string `json:"name"`
 
+	// InheritFromSource copies the storage class, access modes, volume mode and size of
+	// the source PVC of the backup. The other fields override them, e.g. to migrate the
+	// data onto a different storage backend.
+	// +optional
+	InheritFromSource bool `json:"inheritFromSource,omitempty"`
+
+	// RestoreMetadata recreates the source PVC as recorded by the backup: its labels and
+	// annotations are copied, and its storage class, access modes and size are the
+	// defaults. Unlike inheritFromSource, the source PVC doesn't have to exist anymore.
+	// +optional
+	RestoreMetadata bool `json:"restoreMetadata,omitempty"`
+
 	// StorageClassName is the storage class for the new PVC.
 	// +optional
 	StorageClassName string `json:"storageClassName,omitempty"`
@@ -64,9 +113,75 @@ type NewPVCTarget struct {
 	// +optional
 	AccessModes []string `json:"accessModes,omitempty"`
 
-	// Size is the size of the new PVC.
+	// VolumeMode is the volume mode of the new PVC. Restic restores files, so only
+	// Filesystem volumes can be restored into.
+	// +kubebuilder:validation:Enum=Filesystem;Block
+	// +optional
+	VolumeMode string `json:"volumeMode,omitempty"`
+
+	// Size is the size of the new PVC. Required unless inheritFromSource or
+	// restoreMetadata is set.
+	// +optional
+	Size string `json:"size,omitempty"`
+
+	// ReclaimPolicy defines whether the PVC is kept or deleted when the ResticRestore
+	// is deleted.
+	// +kubebuilder:default=Retain
+	// +optional
+	ReclaimPolicy PVCReclaimPolicy `json:"reclaimPolicy,omitempty"`
+}
+
+// PodTarget defines a volume of a running pod as restore target.
+type PodTarget struct {
+	// Name is the name of the running pod in the namespace of the restore.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Volume is the name of the pod volume to restore into. It must be backed by a PVC.
 	// +kubebuilder:validation:Required
-	Size string `json:"size"`
+	// +kubebuilder:validation:MinLength=1
+	Volume string `json:"volume"`
+
+	// SubPath is the directory within the volume to restore into. Defaults to the
+	// root of the volume.
+	// +optional
+	SubPath string `json:"subPath,omitempty"`
+}
+
+// DumpTargetKind is the kind of object created by a dump target.
+// +kubebuilder:validation:Enum=Secret;ConfigMap
+type DumpTargetKind string
+
+const (
+	// DumpTargetSecret creates a Secret.
+	DumpTargetSecret DumpTargetKind = "Secret"
+	// DumpTargetConfigMap creates a ConfigMap.
+	DumpTargetConfigMap DumpTargetKind = "ConfigMap"
+)
+
+// DumpTarget defines a Secret or ConfigMap created with a file of the snapshot, or a tar
+// archive of a directory, streamed by restic dump. The object is limited to 1 MiB.
+type DumpTarget struct {
+	// Path is the absolute path of the file or directory in the snapshot.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path"`
+
+	// Kind is the kind of object created in the namespace of the restore.
+	// +kubebuilder:default=Secret
+	// +optional
+	Kind DumpTargetKind `json:"kind,omitempty"`
+
+	// Name is the name of the created object. It must not exist yet.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the data key of the dumped content. Defaults to the base name of the path.
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // RestoreTarget defines where to restore data.
@@ -78,35 +193,205 @@ type RestoreTarget struct {
 	// NewPVC defines creating a new PVC for restore.
 	// +optional
 	NewPVC *NewPVCTarget `json:"newPVC,omitempty"`
+
+	// Pod defines restoring into a volume of a running pod. Only used by the
+	// FileRestore mode.
+	// +optional
+	Pod *PodTarget `json:"pod,omitempty"`
+
+	// Dump defines dumping a file or directory of the snapshot into a new Secret or
+	// ConfigMap instead of restoring into a volume.
+	// +optional
+	Dump *DumpTarget `json:"dump,omitempty"`
 }
 
+// RestoreMode defines what a restore restores.
+// +kubebuilder:validation:Enum=Full;FileRestore
+type RestoreMode string
+
+const (
+	// RestoreModeFull restores a snapshot into a PVC.
+	RestoreModeFull RestoreMode = "Full"
+	// RestoreModeFileRestore restores selected paths of a snapshot into a volume of a
+	// running pod, e.g. to recover a single deleted file.
+	RestoreModeFileRestore RestoreMode = "FileRestore"
+)
+
 // RestoreOptions configures restore behavior.
 type RestoreOptions struct {
-	// Overwrite enables overwriting existing files.
+	// Overwrite enables overwriting existing files. If false, existing files are kept
+	// (--overwrite never) unless OverwriteMode is set.
 	// +kubebuilder:default=true
 	// +optional
 	Overwrite bool `json:"overwrite,omitempty"`
 
+	// OverwriteMode selects which existing files are overwritten (--overwrite).
+	// if-changed skips files whose content is unchanged, which speeds up restores
+	// into a target that still holds most of the data. Defaults to the restic default.
+	// +kubebuilder:validation:Enum=always;if-changed;if-newer;never
+	// +optional
+	OverwriteMode string `json:"overwriteMode,omitempty"`
+
+	// Sparse restores files with large blocks of zeros as sparse files (--sparse),
+	// which speeds up restores of VM images and databases.
+	// +optional
+	Sparse bool `json:"sparse,omitempty"`
+
 	// Verify enables verification of restored data.
 	// +optional
 	Verify bool `json:"verify,omitempty"`
+
+	// FixOwnership changes the owner and permissions of the restored files after the
+	// restore, e.g. when the application runs as another user than the restore job.
+	// The restore container then runs as root with the CHOWN, FOWNER and DAC_OVERRIDE
+	// capabilities.
+	// +optional
+	FixOwnership *FixOwnership `json:"fixOwnership,omitempty"`
+}
+
+// FixOwnership configures the ownership and permissions of restored files.
+type FixOwnership struct {
+	// UID is the user ID the restored files are owned by.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Required
+	UID int64 `json:"uid"`
+
+	// GID is the group ID the restored files are owned by. Defaults to the UID.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	GID *int64 `json:"gid,omitempty"`
+
+	// Mode changes the permissions of the restored files (chmod), either octal ("0640")
+	// or symbolic ("u+rwX,g+rX").
+	// +kubebuilder:validation:Pattern=`^([0-7]{3,4}|[ugoa]*[-+=][rwxXst]*(,[ugoa]*[-+=][rwxXst]*)*)$`
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// Recursive applies the ownership and permissions to all files of the restore
+	// target instead of only its root directory. Defaults to true.
+	// +optional
+	Recursive *bool `json:"recursive,omitempty"`
+}
+
+// ConfigMapKeySelector selects a key from a ConfigMap.
+type ConfigMapKeySelector struct {
+	// Name of the ConfigMap in the same namespace.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Key within the ConfigMap to select.
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+}
+
+// ChecksumManifest references a manifest in sha256sum format ("<sha256>  <file>").
+// Exactly one of Path and ConfigMapRef must be set.
+// +kubebuilder:validation:XValidation:rule="has(self.path) != has(self.configMapRef)",message="exactly one of path and configMapRef must be set"
+type ChecksumManifest struct {
+	// Path is the path of a manifest within the restored data, e.g.
+	// "/backup/data/SHA256SUMS". The files it lists are relative to its directory.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// ConfigMapRef selects a ConfigMap key holding the manifest. The files it lists
+	// are relative to the restore target.
+	// +optional
+	ConfigMapRef *ConfigMapKeySelector `json:"configMapRef,omitempty"`
+}
+
+// RestoreAssertions are acceptance criteria the restore job checks after restoring.
+// The restore fails if one of them fails. Paths are relative to the restore target
+// and include the snapshot paths, e.g. "/backup/data" for a PVC backup.
+type RestoreAssertions struct {
+	// PathsExist are paths that must exist after the restore.
+	// +optional
+	PathsExist []string `json:"pathsExist,omitempty"`
+
+	// MinFileCount is the minimum number of files below the restore target.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinFileCount *int64 `json:"minFileCount,omitempty"`
+
+	// ChecksumManifest is a manifest whose checksums the restored files must match.
+	// +optional
+	ChecksumManifest *ChecksumManifest `json:"checksumManifest,omitempty"`
+
+	// Command is a shell command run in the restore container after the restore, with
+	// the restored data below /restore. The assertion passes if it exits with 0.
+	// +optional
+	Command string `json:"command,omitempty"`
+}
+
+// RestoreAssertionResult is the result of a restore assertion.
+type RestoreAssertionResult struct {
+	// Name identifies the assertion, e.g. "pathExists:/backup/data" or "minFileCount".
+	Name string `json:"name"`
+
+	// Passed is true if the assertion holds.
+	Passed bool `json:"passed"`
+
+	// Message describes the result.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // RestorePhase represents the current phase of a restore operation.
-// +kubebuilder:validation:Enum=Pending;InProgress;Completed;Failed
+// +kubebuilder:validation:Enum=Pending;Queued;InProgress;Completed;Failed;Scheduled
 type RestorePhase string
 
 const (
 	// RestorePhasePending indicates the restore has not started.
 	RestorePhasePending RestorePhase = "Pending"
+	// RestorePhaseQueued indicates the restore is waiting for a free restore slot.
+	RestorePhaseQueued RestorePhase = "Queued"
 	// RestorePhaseInProgress indicates the restore is running.
 	RestorePhaseInProgress RestorePhase = "InProgress"
 	// RestorePhaseCompleted indicates the restore completed successfully.
 	RestorePhaseCompleted RestorePhase = "Completed"
 	// RestorePhaseFailed indicates the restore failed.
 	RestorePhaseFailed RestorePhase = "Failed"
+	// RestorePhaseScheduled indicates a restore drill runs on its schedule.
+	RestorePhaseScheduled RestorePhase = "Scheduled"
 )
 
+// RestoreDrillResult is the result of a restore drill run.
+// +kubebuilder:validation:Enum=Succeeded;Failed
+type RestoreDrillResult string
+
+const (
+	// RestoreDrillSucceeded indicates the drill restored the snapshot and its assertions passed.
+	RestoreDrillSucceeded RestoreDrillResult = "Succeeded"
+	// RestoreDrillFailed indicates the restore or one of its assertions failed.
+	RestoreDrillFailed RestoreDrillResult = "Failed"
+)
+
+// RestoreDrillStatus is the observed state of a scheduled restore drill.
+type RestoreDrillStatus struct {
+	// LastRunTime is when the last drill run finished.
+	// +optional
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+
+	// LastResult is the result of the last drill run.
+	// +optional
+	LastResult RestoreDrillResult `json:"lastResult,omitempty"`
+
+	// LastSuccessTime is when the last successful drill run finished.
+	// +optional
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+
+	// ConsecutiveFailures is the number of drill runs failed since the last success.
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// LastJobName is the name of the job of the last finished drill run.
+	// +optional
+	LastJobName string `json:"lastJobName,omitempty"`
+
+	// NextRunTime is when the next drill run is scheduled.
+	// +optional
+	NextRunTime *metav1.Time `json:"nextRunTime,omitempty"`
+}
+
 // ResticRestoreSpec defines the desired state of ResticRestore.
 type ResticRestoreSpec struct {
 	// BackupRef references the ResticBackup CR for repository info.
@@ -121,11 +406,24 @@ type ResticRestoreSpec struct {
 	// +optional
 	SnapshotSelector *SnapshotSelector `json:"snapshotSelector,omitempty"`
 
+	// Chain restores several snapshots in order into the target instead of a single
+	// snapshot, e.g. a base backup and the log backups to replay on top of it. Mutually
+	// exclusive with snapshotID and snapshotSelector.
+	// +optional
+	Chain *RestoreChain `json:"chain,omitempty"`
+
+	// Mode defines what is restored. FileRestore restores the includePaths into the
+	// volume of the running pod of target.pod, without replacing the whole PVC.
+	// +kubebuilder:default=Full
+	// +optional
+	Mode RestoreMode `json:"mode,omitempty"`
+
 	// Target defines where to restore data.
 	// +kubebuilder:validation:Required
 	Target RestoreTarget `json:"target"`
 
-	// IncludePaths specifies paths to restore. Defaults to all.
+	// IncludePaths specifies paths to restore. Defaults to all. Required by the
+	// FileRestore mode.
 	// +optional
 	IncludePaths []string `json:"includePaths,omitempty"`
 
@@ -141,9 +439,35 @@ type ResticRestoreSpec struct {
 	// +optional
 	Hooks *RestoreHooks `json:"hooks,omitempty"`
 
+	// StopWorkload scales a workload using the target PVC to zero replicas before the
+	// restore job mounts it, and back to its previous replicas once the restore
+	// completed or failed. This avoids Multi-Attach errors of ReadWriteOnce volumes and
+	// writes of the application during the restore.
+	// +optional
+	StopWorkload *ScaleDownHook `json:"stopWorkload,omitempty"`
+
+	// Assertions are acceptance criteria checked by the restore job after restoring.
+	// +optional
+	Assertions *RestoreAssertions `json:"assertions,omitempty"`
+
 	// JobConfig configures the restore job.
 	// +optional
 	JobConfig *JobConfiguration `json:"jobConfig,omitempty"`
+
+	// Schedule turns the restore into a restore drill: on this Cron schedule, the latest
+	// snapshot matching the snapshot selector is restored into the newPVC target and
+	// the assertions are checked, verifying that the backups can be restored. Failed
+	// drills are reported to the notification backends of the referenced backup.
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// Timezone for the drill schedule (e.g., "Europe/Berlin").
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// Suspend stops scheduling new drill runs.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
 }
 
 // ResticRestoreStatus defines the observed state of ResticRestore.
@@ -164,10 +488,24 @@ type ResticRestoreStatus struct {
 	// +optional
 	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 
-	// RestoredSnapshot is the ID of the restored snapshot.
+	// FailureMessage is the end of the log of the failed restore job.
+	// +optional
+	FailureMessage string `json:"failureMessage,omitempty"`
+
+	// RestoredSnapshot is the ID of the restored snapshot. For a restore chain it is
+	// the last snapshot of the chain.
 	// +optional
 	RestoredSnapshot string `json:"restoredSnapshot,omitempty"`
 
+	// RestoredSnapshots are the IDs of the snapshots of a restore chain, in the order
+	// they are restored.
+	// +optional
+	RestoredSnapshots []string `json:"restoredSnapshots,omitempty"`
+
+	// RestoredSnapshotTime is the time of the snapshot selected by the snapshot selector.
+	// +optional
+	RestoredSnapshotTime *metav1.Time `json:"restoredSnapshotTime,omitempty"`
+
 	// RestoredFiles is the number of restored files.
 	// +optional
 	RestoredFiles int64 `json:"restoredFiles,omitempty"`
@@ -176,10 +514,34 @@ type ResticRestoreStatus struct {
 	// +optional
 	RestoredSize string `json:"restoredSize,omitempty"`
 
+	// Assertions are the results of the restore assertions.
+	// +optional
+	Assertions []RestoreAssertionResult `json:"assertions,omitempty"`
+
+	// CreatedDump is the name of the Secret or ConfigMap created for a dump target.
+	// +optional
+	CreatedDump string `json:"createdDump,omitempty"`
+
+	// CreatedPVC is the name of the PVC created for a newPVC target.
+	// +optional
+	CreatedPVC string `json:"createdPVC,omitempty"`
+
 	// JobRef references the restore job.
 	// +optional
 	JobRef *ObjectReference `json:"jobRef,omitempty"`
 
+	// StoppedWorkload is the workload currently scaled down for the restore.
+	// +optional
+	StoppedWorkload *WorkloadReference `json:"stoppedWorkload,omitempty"`
+
+	// CronJobRef references the CronJob of a restore drill.
+	// +optional
+	CronJobRef *ObjectReference `json:"cronJobRef,omitempty"`
+
+	// Drill is the status of a scheduled restore drill.
+	// +optional
+	Drill *RestoreDrillStatus `json:"drill,omitempty"`
+
 	// ObservedGeneration reflects the generation of the spec observed by the controller.
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
@@ -190,6 +552,7 @@ type ResticRestoreStatus struct {
 // +kubebuilder:resource:shortName=rres
 // +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
 // +kubebuilder:printcolumn:name="Snapshot",type="string",JSONPath=".status.restoredSnapshot"
+// +kubebuilder:printcolumn:name="Last Drill",type="string",JSONPath=".status.drill.lastResult",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // ResticRestore is the Schema for the resticrestores API.
@@ -0,0 +1,83 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResticSnapshotRefSpec defines the snapshot a PVC referencing the ResticSnapshotRef in
+// its dataSourceRef is populated from.
+type ResticSnapshotRefSpec struct {
+	// BackupRef references the ResticBackup whose repository holds the snapshot.
+	// +kubebuilder:validation:Required
+	BackupRef CrossNamespaceObjectReference `json:"backupRef"`
+
+	// SnapshotID specifies the exact snapshot to restore.
+	// +optional
+	SnapshotID string `json:"snapshotID,omitempty"`
+
+	// SnapshotSelector selects a snapshot if SnapshotID is not specified. Defaults to the
+	// latest snapshot of the backup's hostname.
+	// +optional
+	SnapshotSelector *SnapshotSelector `json:"snapshotSelector,omitempty"`
+
+	// IncludePaths specifies paths to restore. Defaults to all.
+	// +optional
+	IncludePaths []string `json:"includePaths,omitempty"`
+
+	// ExcludePaths specifies paths to exclude from restore.
+	// +optional
+	ExcludePaths []string `json:"excludePaths,omitempty"`
+
+	// Options configures restore behavior.
+	// +optional
+	Options *RestoreOptions `json:"options,omitempty"`
+
+	// JobConfig configures the restore jobs populating the PVCs.
+	// +optional
+	JobConfig *JobConfiguration `json:"jobConfig,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=rsnap
+// +kubebuilder:printcolumn:name="Backup",type="string",JSONPath=".spec.backupRef.name"
+// +kubebuilder:printcolumn:name="Snapshot",type="string",JSONPath=".spec.snapshotID"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ResticSnapshotRef is a volume data source: a PVC with a dataSourceRef to a
+// ResticSnapshotRef is provisioned pre-filled with the data of the snapshot, e.g. the
+// PVCs of new StatefulSet replicas from volumeClaimTemplates.
+type ResticSnapshotRef struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ResticSnapshotRefSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResticSnapshotRefList contains a list of ResticSnapshotRef.
+type ResticSnapshotRefList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResticSnapshotRef `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResticSnapshotRef{}, &ResticSnapshotRefList{})
+}
@@ -27,217 +27,246 @@ import (
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BackupHooks) DeepCopyInto(out *BackupHooks) {
+func (in *AutoTuneResources) DeepCopyInto(out *AutoTuneResources) {
 	*out = *in
-	if in.PreBackup != nil {
-		in, out := &in.PreBackup, &out.PreBackup
-		*out = new(Hook)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.PostBackup != nil {
-		in, out := &in.PostBackup, &out.PostBackup
-		*out = new(Hook)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.OnFailure != nil {
-		in, out := &in.OnFailure, &out.OnFailure
-		*out = new(Hook)
-		(*in).DeepCopyInto(*out)
-	}
+	out.MaxMemory = in.MaxMemory.DeepCopy()
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupHooks.
-func (in *BackupHooks) DeepCopy() *BackupHooks {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoTuneResources.
+func (in *AutoTuneResources) DeepCopy() *AutoTuneResources {
 	if in == nil {
 		return nil
 	}
-	out := new(BackupHooks)
+	out := new(AutoTuneResources)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BackupRunStatus) DeepCopyInto(out *BackupRunStatus) {
+func (in *BackupDataSample) DeepCopyInto(out *BackupDataSample) {
 	*out = *in
-	if in.StartTime != nil {
-		in, out := &in.StartTime, &out.StartTime
-		*out = (*in).DeepCopy()
-	}
-	if in.CompletionTime != nil {
-		in, out := &in.CompletionTime, &out.CompletionTime
-		*out = (*in).DeepCopy()
-	}
+	in.Time.DeepCopyInto(&out.Time)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRunStatus.
-func (in *BackupRunStatus) DeepCopy() *BackupRunStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupDataSample.
+func (in *BackupDataSample) DeepCopy() *BackupDataSample {
 	if in == nil {
 		return nil
 	}
-	out := new(BackupRunStatus)
+	out := new(BackupDataSample)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BackupSource) DeepCopyInto(out *BackupSource) {
+func (in *BackupHooks) DeepCopyInto(out *BackupHooks) {
 	*out = *in
-	if in.PVC != nil {
-		in, out := &in.PVC, &out.PVC
-		*out = new(PVCSource)
+	if in.PreBackup != nil {
+		in, out := &in.PreBackup, &out.PreBackup
+		*out = new(Hook)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.PodVolumeBackup != nil {
-		in, out := &in.PodVolumeBackup, &out.PodVolumeBackup
-		*out = new(PodVolumeBackupSource)
+	if in.PostBackup != nil {
+		in, out := &in.PostBackup, &out.PostBackup
+		*out = new(Hook)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.CustomSource != nil {
-		in, out := &in.CustomSource, &out.CustomSource
-		*out = new(CustomSource)
+	if in.OnFailure != nil {
+		in, out := &in.OnFailure, &out.OnFailure
+		*out = new(Hook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScaleDown != nil {
+		in, out := &in.ScaleDown, &out.ScaleDown
+		*out = new(ScaleDownHook)
 		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSource.
-func (in *BackupSource) DeepCopy() *BackupSource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupHooks.
+func (in *BackupHooks) DeepCopy() *BackupHooks {
 	if in == nil {
 		return nil
 	}
-	out := new(BackupSource)
+	out := new(BackupHooks)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BackupStatistics) DeepCopyInto(out *BackupStatistics) {
+func (in *BackupResourcesStatus) DeepCopyInto(out *BackupResourcesStatus) {
 	*out = *in
+	if in.LastOOMKill != nil {
+		in, out := &in.LastOOMKill, &out.LastOOMKill
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStatistics.
-func (in *BackupStatistics) DeepCopy() *BackupStatistics {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupResourcesStatus.
+func (in *BackupResourcesStatus) DeepCopy() *BackupResourcesStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(BackupStatistics)
+	out := new(BackupResourcesStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CacheConfig) DeepCopyInto(out *CacheConfig) {
+func (in *BackupRetentionReport) DeepCopyInto(out *BackupRetentionReport) {
 	*out = *in
+	if in.ExpectedSnapshots != nil {
+		in, out := &in.ExpectedSnapshots, &out.ExpectedSnapshots
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NewestSnapshot != nil {
+		in, out := &in.NewestSnapshot, &out.NewestSnapshot
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheConfig.
-func (in *CacheConfig) DeepCopy() *CacheConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRetentionReport.
+func (in *BackupRetentionReport) DeepCopy() *BackupRetentionReport {
 	if in == nil {
 		return nil
 	}
-	out := new(CacheConfig)
+	out := new(BackupRetentionReport)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CrossNamespaceObjectReference) DeepCopyInto(out *CrossNamespaceObjectReference) {
+func (in *BackupRetryPolicy) DeepCopyInto(out *BackupRetryPolicy) {
 	*out = *in
+	if in.Backoff != nil {
+		in, out := &in.Backoff, &out.Backoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrossNamespaceObjectReference.
-func (in *CrossNamespaceObjectReference) DeepCopy() *CrossNamespaceObjectReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRetryPolicy.
+func (in *BackupRetryPolicy) DeepCopy() *BackupRetryPolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(CrossNamespaceObjectReference)
+	out := new(BackupRetryPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CustomSource) DeepCopyInto(out *CustomSource) {
+func (in *BackupRetryStatus) DeepCopyInto(out *BackupRetryStatus) {
 	*out = *in
-	in.PodTemplate.DeepCopyInto(&out.PodTemplate)
+	if in.NextRetry != nil {
+		in, out := &in.NextRetry, &out.NextRetry
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomSource.
-func (in *CustomSource) DeepCopy() *CustomSource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRetryStatus.
+func (in *BackupRetryStatus) DeepCopy() *BackupRetryStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(CustomSource)
+	out := new(BackupRetryStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EmailNotificationConfig) DeepCopyInto(out *EmailNotificationConfig) {
+func (in *BackupRunStatus) DeepCopyInto(out *BackupRunStatus) {
 	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmailNotificationConfig.
-func (in *EmailNotificationConfig) DeepCopy() *EmailNotificationConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRunStatus.
+func (in *BackupRunStatus) DeepCopy() *BackupRunStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(EmailNotificationConfig)
+	out := new(BackupRunStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ExecHook) DeepCopyInto(out *ExecHook) {
+func (in *BackupSource) DeepCopyInto(out *BackupSource) {
 	*out = *in
-	in.PodSelector.DeepCopyInto(&out.PodSelector)
-	if in.Command != nil {
-		in, out := &in.Command, &out.Command
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.PVC != nil {
+		in, out := &in.PVC, &out.PVC
+		*out = new(PVCSource)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.Timeout != nil {
-		in, out := &in.Timeout, &out.Timeout
-		*out = new(v1.Duration)
-		**out = **in
+	if in.PVCs != nil {
+		in, out := &in.PVCs, &out.PVCs
+		*out = make([]PVCSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PVCSelector != nil {
+		in, out := &in.PVCSelector, &out.PVCSelector
+		*out = new(PVCSelectorSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodVolumeBackup != nil {
+		in, out := &in.PodVolumeBackup, &out.PodVolumeBackup
+		*out = new(PodVolumeBackupSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CustomSource != nil {
+		in, out := &in.CustomSource, &out.CustomSource
+		*out = new(CustomSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Database != nil {
+		in, out := &in.Database, &out.Database
+		*out = new(DatabaseSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(ResourcesSource)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecHook.
-func (in *ExecHook) DeepCopy() *ExecHook {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSource.
+func (in *BackupSource) DeepCopy() *BackupSource {
 	if in == nil {
 		return nil
 	}
-	out := new(ExecHook)
+	out := new(BackupSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GlobalRetentionNotificationConfig) DeepCopyInto(out *GlobalRetentionNotificationConfig) {
+func (in *BackupStatistics) DeepCopyInto(out *BackupStatistics) {
 	*out = *in
-	if in.Email != nil {
-		in, out := &in.Email, &out.Email
-		*out = new(EmailNotificationConfig)
-		**out = **in
-	}
-	if in.Ntfy != nil {
-		in, out := &in.Ntfy, &out.Ntfy
-		*out = new(NtfyConfig)
-		(*in).DeepCopyInto(*out)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionNotificationConfig.
-func (in *GlobalRetentionNotificationConfig) DeepCopy() *GlobalRetentionNotificationConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStatistics.
+func (in *BackupStatistics) DeepCopy() *BackupStatistics {
 	if in == nil {
 		return nil
 	}
-	out := new(GlobalRetentionNotificationConfig)
+	out := new(BackupStatistics)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GlobalRetentionPolicy) DeepCopyInto(out *GlobalRetentionPolicy) {
+func (in *BackupVerification) DeepCopyInto(out *BackupVerification) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -245,18 +274,18 @@ func (in *GlobalRetentionPolicy) DeepCopyInto(out *GlobalRetentionPolicy) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionPolicy.
-func (in *GlobalRetentionPolicy) DeepCopy() *GlobalRetentionPolicy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupVerification.
+func (in *BackupVerification) DeepCopy() *BackupVerification {
 	if in == nil {
 		return nil
 	}
-	out := new(GlobalRetentionPolicy)
+	out := new(BackupVerification)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GlobalRetentionPolicy) DeepCopyObject() runtime.Object {
+func (in *BackupVerification) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -264,31 +293,31 @@ func (in *GlobalRetentionPolicy) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GlobalRetentionPolicyList) DeepCopyInto(out *GlobalRetentionPolicyList) {
+func (in *BackupVerificationList) DeepCopyInto(out *BackupVerificationList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]GlobalRetentionPolicy, len(*in))
+		*out = make([]BackupVerification, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionPolicyList.
-func (in *GlobalRetentionPolicyList) DeepCopy() *GlobalRetentionPolicyList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupVerificationList.
+func (in *BackupVerificationList) DeepCopy() *BackupVerificationList {
 	if in == nil {
 		return nil
 	}
-	out := new(GlobalRetentionPolicyList)
+	out := new(BackupVerificationList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GlobalRetentionPolicyList) DeepCopyObject() runtime.Object {
+func (in *BackupVerificationList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -296,20 +325,17 @@ func (in *GlobalRetentionPolicyList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GlobalRetentionPolicySpec) DeepCopyInto(out *GlobalRetentionPolicySpec) {
+func (in *BackupVerificationSpec) DeepCopyInto(out *BackupVerificationSpec) {
 	*out = *in
-	out.RepositoryRef = in.RepositoryRef
-	if in.Policies != nil {
-		in, out := &in.Policies, &out.Policies
-		*out = make([]RetentionPolicyEntry, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.MinSizePercent != nil {
+		in, out := &in.MinSizePercent, &out.MinSizePercent
+		*out = new(int32)
+		**out = **in
 	}
-	if in.Notifications != nil {
-		in, out := &in.Notifications, &out.Notifications
-		*out = new(GlobalRetentionNotificationConfig)
-		(*in).DeepCopyInto(*out)
+	if in.MinFileCountPercent != nil {
+		in, out := &in.MinFileCountPercent, &out.MinFileCountPercent
+		*out = new(int32)
+		**out = **in
 	}
 	if in.JobConfig != nil {
 		in, out := &in.JobConfig, &out.JobConfig
@@ -318,18 +344,18 @@ func (in *GlobalRetentionPolicySpec) DeepCopyInto(out *GlobalRetentionPolicySpec
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionPolicySpec.
-func (in *GlobalRetentionPolicySpec) DeepCopy() *GlobalRetentionPolicySpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupVerificationSpec.
+func (in *BackupVerificationSpec) DeepCopy() *BackupVerificationSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GlobalRetentionPolicySpec)
+	out := new(BackupVerificationSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GlobalRetentionPolicyStatus) DeepCopyInto(out *GlobalRetentionPolicyStatus) {
+func (in *BackupVerificationStatus) DeepCopyInto(out *BackupVerificationStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -338,14 +364,19 @@ func (in *GlobalRetentionPolicyStatus) DeepCopyInto(out *GlobalRetentionPolicySt
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.LastRun != nil {
-		in, out := &in.LastRun, &out.LastRun
+	if in.LastVerification != nil {
+		in, out := &in.LastVerification, &out.LastVerification
 		*out = (*in).DeepCopy()
 	}
-	if in.NextRun != nil {
-		in, out := &in.NextRun, &out.NextRun
+	if in.NextVerification != nil {
+		in, out := &in.NextVerification, &out.NextVerification
 		*out = (*in).DeepCopy()
 	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = new(VerificationDrift)
+		**out = **in
+	}
 	if in.CronJobRef != nil {
 		in, out := &in.CronJobRef, &out.CronJobRef
 		*out = new(ObjectReference)
@@ -353,324 +384,2632 @@ func (in *GlobalRetentionPolicyStatus) DeepCopyInto(out *GlobalRetentionPolicySt
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionPolicyStatus.
-func (in *GlobalRetentionPolicyStatus) DeepCopy() *GlobalRetentionPolicyStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupVerificationStatus.
+func (in *BackupVerificationStatus) DeepCopy() *BackupVerificationStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(GlobalRetentionPolicyStatus)
+	out := new(BackupVerificationStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Hook) DeepCopyInto(out *Hook) {
+func (in *BackupWindow) DeepCopyInto(out *BackupWindow) {
 	*out = *in
-	if in.Exec != nil {
-		in, out := &in.Exec, &out.Exec
-		*out = new(ExecHook)
-		(*in).DeepCopyInto(*out)
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]Weekday, len(*in))
+		copy(*out, *in)
 	}
-	if in.Job != nil {
-		in, out := &in.Job, &out.Job
-		*out = new(JobHook)
-		(*in).DeepCopyInto(*out)
+	if in.Ranges != nil {
+		in, out := &in.Ranges, &out.Ranges
+		*out = make([]TimeRange, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hook.
-func (in *Hook) DeepCopy() *Hook {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupWindow.
+func (in *BackupWindow) DeepCopy() *BackupWindow {
 	if in == nil {
 		return nil
 	}
-	out := new(Hook)
+	out := new(BackupWindow)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IntegrityCheckConfig) DeepCopyInto(out *IntegrityCheckConfig) {
+func (in *BlackoutPeriod) DeepCopyInto(out *BlackoutPeriod) {
 	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntegrityCheckConfig.
-func (in *IntegrityCheckConfig) DeepCopy() *IntegrityCheckConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlackoutPeriod.
+func (in *BlackoutPeriod) DeepCopy() *BlackoutPeriod {
 	if in == nil {
 		return nil
 	}
-	out := new(IntegrityCheckConfig)
+	out := new(BlackoutPeriod)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *JobConfiguration) DeepCopyInto(out *JobConfiguration) {
+func (in *CacheConfig) DeepCopyInto(out *CacheConfig) {
 	*out = *in
-	if in.SuccessfulJobsHistoryLimit != nil {
-		in, out := &in.SuccessfulJobsHistoryLimit, &out.SuccessfulJobsHistoryLimit
+	if in.MaxAgeDays != nil {
+		in, out := &in.MaxAgeDays, &out.MaxAgeDays
 		*out = new(int32)
 		**out = **in
 	}
-	if in.FailedJobsHistoryLimit != nil {
-		in, out := &in.FailedJobsHistoryLimit, &out.FailedJobsHistoryLimit
-		*out = new(int32)
-		**out = **in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheConfig.
+func (in *CacheConfig) DeepCopy() *CacheConfig {
+	if in == nil {
+		return nil
 	}
-	if in.ActiveDeadlineSeconds != nil {
-		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
-		*out = new(int64)
-		**out = **in
+	out := new(CacheConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CacheStatus) DeepCopyInto(out *CacheStatus) {
+	*out = *in
+	if in.LastCleanup != nil {
+		in, out := &in.LastCleanup, &out.LastCleanup
+		*out = (*in).DeepCopy()
 	}
-	if in.BackoffLimit != nil {
-		in, out := &in.BackoffLimit, &out.BackoffLimit
-		*out = new(int32)
-		**out = **in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheStatus.
+func (in *CacheStatus) DeepCopy() *CacheStatus {
+	if in == nil {
+		return nil
 	}
-	if in.SecurityContext != nil {
-		in, out := &in.SecurityContext, &out.SecurityContext
-		*out = new(corev1.PodSecurityContext)
-		(*in).DeepCopyInto(*out)
+	out := new(CacheStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChecksumManifest) DeepCopyInto(out *ChecksumManifest) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(ConfigMapKeySelector)
+		**out = **in
 	}
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = new(corev1.ResourceRequirements)
-		(*in).DeepCopyInto(*out)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChecksumManifest.
+func (in *ChecksumManifest) DeepCopy() *ChecksumManifest {
+	if in == nil {
+		return nil
 	}
-	if in.NodeSelector != nil {
-		in, out := &in.NodeSelector, &out.NodeSelector
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	out := new(ChecksumManifest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterBackupPolicy) DeepCopyInto(out *ClusterBackupPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterBackupPolicy.
+func (in *ClusterBackupPolicy) DeepCopy() *ClusterBackupPolicy {
+	if in == nil {
+		return nil
 	}
-	if in.Tolerations != nil {
-		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]corev1.Toleration, len(*in))
+	out := new(ClusterBackupPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterBackupPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterBackupPolicyList) DeepCopyInto(out *ClusterBackupPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterBackupPolicy, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.Affinity != nil {
-		in, out := &in.Affinity, &out.Affinity
-		*out = new(corev1.Affinity)
-		(*in).DeepCopyInto(*out)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobConfiguration.
-func (in *JobConfiguration) DeepCopy() *JobConfiguration {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterBackupPolicyList.
+func (in *ClusterBackupPolicyList) DeepCopy() *ClusterBackupPolicyList {
 	if in == nil {
 		return nil
 	}
-	out := new(JobConfiguration)
+	out := new(ClusterBackupPolicyList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterBackupPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *JobHook) DeepCopyInto(out *JobHook) {
+func (in *ClusterBackupPolicySpec) DeepCopyInto(out *ClusterBackupPolicySpec) {
 	*out = *in
-	in.PodTemplate.DeepCopyInto(&out.PodTemplate)
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	in.PVCSelector.DeepCopyInto(&out.PVCSelector)
+	if in.Excludes != nil {
+		in, out := &in.Excludes, &out.Excludes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Template.DeepCopyInto(&out.Template)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobHook.
-func (in *JobHook) DeepCopy() *JobHook {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterBackupPolicySpec.
+func (in *ClusterBackupPolicySpec) DeepCopy() *ClusterBackupPolicySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(JobHook)
+	out := new(ClusterBackupPolicySpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NewPVCTarget) DeepCopyInto(out *NewPVCTarget) {
+func (in *ClusterBackupPolicyStatus) DeepCopyInto(out *ClusterBackupPolicyStatus) {
 	*out = *in
-	if in.AccessModes != nil {
-		in, out := &in.AccessModes, &out.AccessModes
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]DiscoveredPVC, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NewPVCTarget.
-func (in *NewPVCTarget) DeepCopy() *NewPVCTarget {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterBackupPolicyStatus.
+func (in *ClusterBackupPolicyStatus) DeepCopy() *ClusterBackupPolicyStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(NewPVCTarget)
+	out := new(ClusterBackupPolicyStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NotificationConfig) DeepCopyInto(out *NotificationConfig) {
+func (in *ClusterBackupTemplate) DeepCopyInto(out *ClusterBackupTemplate) {
 	*out = *in
-	if in.Pushgateway != nil {
-		in, out := &in.Pushgateway, &out.Pushgateway
-		*out = new(PushgatewayConfig)
-		**out = **in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
-	if in.Ntfy != nil {
-		in, out := &in.Ntfy, &out.Ntfy
-		*out = new(NtfyConfig)
-		(*in).DeepCopyInto(*out)
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
+	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationConfig.
-func (in *NotificationConfig) DeepCopy() *NotificationConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterBackupTemplate.
+func (in *ClusterBackupTemplate) DeepCopy() *ClusterBackupTemplate {
 	if in == nil {
 		return nil
 	}
-	out := new(NotificationConfig)
+	out := new(ClusterBackupTemplate)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NtfyConfig) DeepCopyInto(out *NtfyConfig) {
+func (in *ClusterBackupTemplateSpec) DeepCopyInto(out *ClusterBackupTemplateSpec) {
 	*out = *in
-	if in.CredentialsSecretRef != nil {
-		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
-		*out = new(NtfyCredentialsSecretRef)
+	out.RepositoryRef = in.RepositoryRef
+	if in.FallbackRepositoryRef != nil {
+		in, out := &in.FallbackRepositoryRef, &out.FallbackRepositoryRef
+		*out = new(CrossNamespaceObjectReference)
 		**out = **in
 	}
-	if in.Tags != nil {
-		in, out := &in.Tags, &out.Tags
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.FallbackAfter != nil {
+		in, out := &in.FallbackAfter, &out.FallbackAfter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Restic != nil {
+		in, out := &in.Restic, &out.Restic
+		*out = new(ResticConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(RetentionConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = new(NotificationConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobConfig != nil {
+		in, out := &in.JobConfig, &out.JobConfig
+		*out = new(JobConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BackupWindow != nil {
+		in, out := &in.BackupWindow, &out.BackupWindow
+		*out = new(BackupWindow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BlackoutPeriods != nil {
+		in, out := &in.BlackoutPeriods, &out.BlackoutPeriods
+		*out = make([]BlackoutPeriod, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AutoTuneResources != nil {
+		in, out := &in.AutoTuneResources, &out.AutoTuneResources
+		*out = new(AutoTuneResources)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(BackupRetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxStaleness != nil {
+		in, out := &in.MaxStaleness, &out.MaxStaleness
+		*out = new(v1.Duration)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NtfyConfig.
-func (in *NtfyConfig) DeepCopy() *NtfyConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterBackupTemplateSpec.
+func (in *ClusterBackupTemplateSpec) DeepCopy() *ClusterBackupTemplateSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(NtfyConfig)
+	out := new(ClusterBackupTemplateSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NtfyCredentialsSecretRef) DeepCopyInto(out *NtfyCredentialsSecretRef) {
+func (in *ConfigMapKeySelector) DeepCopyInto(out *ConfigMapKeySelector) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NtfyCredentialsSecretRef.
-func (in *NtfyCredentialsSecretRef) DeepCopy() *NtfyCredentialsSecretRef {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeySelector.
+func (in *ConfigMapKeySelector) DeepCopy() *ConfigMapKeySelector {
 	if in == nil {
 		return nil
 	}
-	out := new(NtfyCredentialsSecretRef)
+	out := new(ConfigMapKeySelector)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ObjectReference) DeepCopyInto(out *ObjectReference) {
+func (in *CredentialsKeyMapping) DeepCopyInto(out *CredentialsKeyMapping) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectReference.
-func (in *ObjectReference) DeepCopy() *ObjectReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialsKeyMapping.
+func (in *CredentialsKeyMapping) DeepCopy() *CredentialsKeyMapping {
 	if in == nil {
 		return nil
 	}
-	out := new(ObjectReference)
+	out := new(CredentialsKeyMapping)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PVCSource) DeepCopyInto(out *PVCSource) {
+func (in *CredentialsProvisioner) DeepCopyInto(out *CredentialsProvisioner) {
 	*out = *in
-	if in.Paths != nil {
-		in, out := &in.Paths, &out.Paths
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.Excludes != nil {
-		in, out := &in.Excludes, &out.Excludes
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCSource.
-func (in *PVCSource) DeepCopy() *PVCSource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialsProvisioner.
+func (in *CredentialsProvisioner) DeepCopy() *CredentialsProvisioner {
 	if in == nil {
 		return nil
 	}
-	out := new(PVCSource)
+	out := new(CredentialsProvisioner)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PVCTarget) DeepCopyInto(out *PVCTarget) {
+func (in *CrossNamespaceObjectReference) DeepCopyInto(out *CrossNamespaceObjectReference) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCTarget.
-func (in *PVCTarget) DeepCopy() *PVCTarget {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrossNamespaceObjectReference.
+func (in *CrossNamespaceObjectReference) DeepCopy() *CrossNamespaceObjectReference {
 	if in == nil {
 		return nil
 	}
-	out := new(PVCTarget)
+	out := new(CrossNamespaceObjectReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PodVolumeBackupSource) DeepCopyInto(out *PodVolumeBackupSource) {
+func (in *CustomSource) DeepCopyInto(out *CustomSource) {
 	*out = *in
-	in.Selector.DeepCopyInto(&out.Selector)
+	in.PodTemplate.DeepCopyInto(&out.PodTemplate)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodVolumeBackupSource.
-func (in *PodVolumeBackupSource) DeepCopy() *PodVolumeBackupSource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomSource.
+func (in *CustomSource) DeepCopy() *CustomSource {
 	if in == nil {
 		return nil
 	}
-	out := new(PodVolumeBackupSource)
+	out := new(CustomSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PushgatewayConfig) DeepCopyInto(out *PushgatewayConfig) {
+func (in *DatabaseSource) DeepCopyInto(out *DatabaseSource) {
 	*out = *in
+	out.ConnectionSecretRef = in.ConnectionSecretRef
+	if in.DumpArgs != nil {
+		in, out := &in.DumpArgs, &out.DumpArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PushgatewayConfig.
-func (in *PushgatewayConfig) DeepCopy() *PushgatewayConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseSource.
+func (in *DatabaseSource) DeepCopy() *DatabaseSource {
+	if in == nil {
+		return nil
+	}
+	out := new(DatabaseSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiscoveredPVC) DeepCopyInto(out *DiscoveredPVC) {
+	*out = *in
+	if in.LastBackup != nil {
+		in, out := &in.LastBackup, &out.LastBackup
+		*out = new(BackupRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiscoveredPVC.
+func (in *DiscoveredPVC) DeepCopy() *DiscoveredPVC {
+	if in == nil {
+		return nil
+	}
+	out := new(DiscoveredPVC)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DumpTarget) DeepCopyInto(out *DumpTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DumpTarget.
+func (in *DumpTarget) DeepCopy() *DumpTarget {
 	if in == nil {
 		return nil
 	}
-	out := new(PushgatewayConfig)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RepositoryStatistics) DeepCopyInto(out *RepositoryStatistics) {
-	*out = *in
+	out := new(DumpTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectiveRetention) DeepCopyInto(out *EffectiveRetention) {
+	*out = *in
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(RetentionPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GroupBy != nil {
+		in, out := &in.GroupBy, &out.GroupBy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectiveRetention.
+func (in *EffectiveRetention) DeepCopy() *EffectiveRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectiveRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmailConfig) DeepCopyInto(out *EmailConfig) {
+	*out = *in
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CredentialsSecretRef != nil {
+		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
+		*out = new(EmailCredentialsSecretRef)
+		**out = **in
+	}
+	if in.BatchWindow != nil {
+		in, out := &in.BatchWindow, &out.BatchWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmailConfig.
+func (in *EmailConfig) DeepCopy() *EmailConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EmailConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmailCredentialsSecretRef) DeepCopyInto(out *EmailCredentialsSecretRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmailCredentialsSecretRef.
+func (in *EmailCredentialsSecretRef) DeepCopy() *EmailCredentialsSecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(EmailCredentialsSecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmailNotificationConfig) DeepCopyInto(out *EmailNotificationConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmailNotificationConfig.
+func (in *EmailNotificationConfig) DeepCopy() *EmailNotificationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EmailNotificationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExecHook) DeepCopyInto(out *ExecHook) {
+	*out = *in
+	in.PodSelector.DeepCopyInto(&out.PodSelector)
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecHook.
+func (in *ExecHook) DeepCopy() *ExecHook {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FixOwnership) DeepCopyInto(out *FixOwnership) {
+	*out = *in
+	if in.GID != nil {
+		in, out := &in.GID, &out.GID
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Recursive != nil {
+		in, out := &in.Recursive, &out.Recursive
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FixOwnership.
+func (in *FixOwnership) DeepCopy() *FixOwnership {
+	if in == nil {
+		return nil
+	}
+	out := new(FixOwnership)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GlobalRetentionNotificationConfig) DeepCopyInto(out *GlobalRetentionNotificationConfig) {
+	*out = *in
+	if in.Email != nil {
+		in, out := &in.Email, &out.Email
+		*out = new(EmailNotificationConfig)
+		**out = **in
+	}
+	if in.Ntfy != nil {
+		in, out := &in.Ntfy, &out.Ntfy
+		*out = new(NtfyConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionNotificationConfig.
+func (in *GlobalRetentionNotificationConfig) DeepCopy() *GlobalRetentionNotificationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GlobalRetentionNotificationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GlobalRetentionPolicy) DeepCopyInto(out *GlobalRetentionPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionPolicy.
+func (in *GlobalRetentionPolicy) DeepCopy() *GlobalRetentionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(GlobalRetentionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GlobalRetentionPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GlobalRetentionPolicyList) DeepCopyInto(out *GlobalRetentionPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GlobalRetentionPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionPolicyList.
+func (in *GlobalRetentionPolicyList) DeepCopy() *GlobalRetentionPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(GlobalRetentionPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GlobalRetentionPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GlobalRetentionPolicySpec) DeepCopyInto(out *GlobalRetentionPolicySpec) {
+	*out = *in
+	out.RepositoryRef = in.RepositoryRef
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]RetentionPolicyEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = new(GlobalRetentionNotificationConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobConfig != nil {
+		in, out := &in.JobConfig, &out.JobConfig
+		*out = new(JobConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionPolicySpec.
+func (in *GlobalRetentionPolicySpec) DeepCopy() *GlobalRetentionPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GlobalRetentionPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GlobalRetentionPolicyStatus) DeepCopyInto(out *GlobalRetentionPolicyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastRun != nil {
+		in, out := &in.LastRun, &out.LastRun
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRun != nil {
+		in, out := &in.NextRun, &out.NextRun
+		*out = (*in).DeepCopy()
+	}
+	if in.CronJobRef != nil {
+		in, out := &in.CronJobRef, &out.CronJobRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+	if in.EntryCronJobRefs != nil {
+		in, out := &in.EntryCronJobRefs, &out.EntryCronJobRefs
+		*out = make([]ObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.ScheduleOverlaps != nil {
+		in, out := &in.ScheduleOverlaps, &out.ScheduleOverlaps
+		*out = make([]RetentionScheduleOverlap, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalRetentionPolicyStatus.
+func (in *GlobalRetentionPolicyStatus) DeepCopy() *GlobalRetentionPolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GlobalRetentionPolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeartbeatConfig) DeepCopyInto(out *HeartbeatConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeartbeatConfig.
+func (in *HeartbeatConfig) DeepCopy() *HeartbeatConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HeartbeatConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Hook) DeepCopyInto(out *Hook) {
+	*out = *in
+	if in.Exec != nil {
+		in, out := &in.Exec, &out.Exec
+		*out = new(ExecHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Job != nil {
+		in, out := &in.Job, &out.Job
+		*out = new(JobHook)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hook.
+func (in *Hook) DeepCopy() *Hook {
+	if in == nil {
+		return nil
+	}
+	out := new(Hook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HookStatus) DeepCopyInto(out *HookStatus) {
+	*out = *in
+	in.LastRun.DeepCopyInto(&out.LastRun)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HookStatus.
+func (in *HookStatus) DeepCopy() *HookStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HookStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IntegrityCheckConfig) DeepCopyInto(out *IntegrityCheckConfig) {
+	*out = *in
+	if in.ReadDataSubsets != nil {
+		in, out := &in.ReadDataSubsets, &out.ReadDataSubsets
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntegrityCheckConfig.
+func (in *IntegrityCheckConfig) DeepCopy() *IntegrityCheckConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IntegrityCheckConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IntermittentConfig) DeepCopyInto(out *IntermittentConfig) {
+	*out = *in
+	if in.OnlineWindow != nil {
+		in, out := &in.OnlineWindow, &out.OnlineWindow
+		*out = new(BackupWindow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxOfflineDuration != nil {
+		in, out := &in.MaxOfflineDuration, &out.MaxOfflineDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ProbeInterval != nil {
+		in, out := &in.ProbeInterval, &out.ProbeInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntermittentConfig.
+func (in *IntermittentConfig) DeepCopy() *IntermittentConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IntermittentConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobConfiguration) DeepCopyInto(out *JobConfiguration) {
+	*out = *in
+	if in.SuccessfulJobsHistoryLimit != nil {
+		in, out := &in.SuccessfulJobsHistoryLimit, &out.SuccessfulJobsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailedJobsHistoryLimit != nil {
+		in, out := &in.FailedJobsHistoryLimit, &out.FailedJobsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RuntimeClassName != nil {
+		in, out := &in.RuntimeClassName, &out.RuntimeClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(corev1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]corev1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Sidecars != nil {
+		in, out := &in.Sidecars, &out.Sidecars
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobConfiguration.
+func (in *JobConfiguration) DeepCopy() *JobConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(JobConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobHook) DeepCopyInto(out *JobHook) {
+	*out = *in
+	in.PodTemplate.DeepCopyInto(&out.PodTemplate)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobHook.
+func (in *JobHook) DeepCopy() *JobHook {
+	if in == nil {
+		return nil
+	}
+	out := new(JobHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LatencyProbeConfig) DeepCopyInto(out *LatencyProbeConfig) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LatencyProbeConfig.
+func (in *LatencyProbeConfig) DeepCopy() *LatencyProbeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LatencyProbeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceRestore) DeepCopyInto(out *NamespaceRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceRestore.
+func (in *NamespaceRestore) DeepCopy() *NamespaceRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceRestoreEntry) DeepCopyInto(out *NamespaceRestoreEntry) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceRestoreEntry.
+func (in *NamespaceRestoreEntry) DeepCopy() *NamespaceRestoreEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceRestoreEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceRestoreList) DeepCopyInto(out *NamespaceRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NamespaceRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceRestoreList.
+func (in *NamespaceRestoreList) DeepCopy() *NamespaceRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceRestorePVCTemplate) DeepCopyInto(out *NamespaceRestorePVCTemplate) {
+	*out = *in
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceRestorePVCTemplate.
+func (in *NamespaceRestorePVCTemplate) DeepCopy() *NamespaceRestorePVCTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceRestorePVCTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceRestoreSpec) DeepCopyInto(out *NamespaceRestoreSpec) {
+	*out = *in
+	if in.BackupSelector != nil {
+		in, out := &in.BackupSelector, &out.BackupSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SnapshotSelector != nil {
+		in, out := &in.SnapshotSelector, &out.SnapshotSelector
+		*out = new(SnapshotSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PVCTemplate != nil {
+		in, out := &in.PVCTemplate, &out.PVCTemplate
+		*out = new(NamespaceRestorePVCTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = new(RestoreOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobConfig != nil {
+		in, out := &in.JobConfig, &out.JobConfig
+		*out = new(JobConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceRestoreSpec.
+func (in *NamespaceRestoreSpec) DeepCopy() *NamespaceRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceRestoreStatus) DeepCopyInto(out *NamespaceRestoreStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Restores != nil {
+		in, out := &in.Restores, &out.Restores
+		*out = make([]NamespaceRestoreEntry, len(*in))
+		copy(*out, *in)
+	}
+	if in.Skipped != nil {
+		in, out := &in.Skipped, &out.Skipped
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceRestoreStatus.
+func (in *NamespaceRestoreStatus) DeepCopy() *NamespaceRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NewPVCTarget) DeepCopyInto(out *NewPVCTarget) {
+	*out = *in
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NewPVCTarget.
+func (in *NewPVCTarget) DeepCopy() *NewPVCTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(NewPVCTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationConfig) DeepCopyInto(out *NotificationConfig) {
+	*out = *in
+	if in.Pushgateway != nil {
+		in, out := &in.Pushgateway, &out.Pushgateway
+		*out = new(PushgatewayConfig)
+		**out = **in
+	}
+	if in.Ntfy != nil {
+		in, out := &in.Ntfy, &out.Ntfy
+		*out = new(NtfyConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Email != nil {
+		in, out := &in.Email, &out.Email
+		*out = new(EmailConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationConfig.
+func (in *NotificationConfig) DeepCopy() *NotificationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NtfyConfig) DeepCopyInto(out *NtfyConfig) {
+	*out = *in
+	if in.CredentialsSecretRef != nil {
+		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
+		*out = new(NtfyCredentialsSecretRef)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NtfyConfig.
+func (in *NtfyConfig) DeepCopy() *NtfyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NtfyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NtfyCredentialsSecretRef) DeepCopyInto(out *NtfyCredentialsSecretRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NtfyCredentialsSecretRef.
+func (in *NtfyCredentialsSecretRef) DeepCopy() *NtfyCredentialsSecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(NtfyCredentialsSecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectReference) DeepCopyInto(out *ObjectReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectReference.
+func (in *ObjectReference) DeepCopy() *ObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PVCSelectorSource) DeepCopyInto(out *PVCSelectorSource) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+	if in.Excludes != nil {
+		in, out := &in.Excludes, &out.Excludes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCSelectorSource.
+func (in *PVCSelectorSource) DeepCopy() *PVCSelectorSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PVCSelectorSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PVCSource) DeepCopyInto(out *PVCSource) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Excludes != nil {
+		in, out := &in.Excludes, &out.Excludes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCSource.
+func (in *PVCSource) DeepCopy() *PVCSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PVCSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PVCTarget) DeepCopyInto(out *PVCTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCTarget.
+func (in *PVCTarget) DeepCopy() *PVCTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PVCTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PauseStatus) DeepCopyInto(out *PauseStatus) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PauseStatus.
+func (in *PauseStatus) DeepCopy() *PauseStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PauseStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTarget) DeepCopyInto(out *PodTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTarget.
+func (in *PodTarget) DeepCopy() *PodTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodVolumeBackupSource) DeepCopyInto(out *PodVolumeBackupSource) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodVolumeBackupSource.
+func (in *PodVolumeBackupSource) DeepCopy() *PodVolumeBackupSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PodVolumeBackupSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PruneOptions) DeepCopyInto(out *PruneOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PruneOptions.
+func (in *PruneOptions) DeepCopy() *PruneOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PruneOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PushgatewayConfig) DeepCopyInto(out *PushgatewayConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PushgatewayConfig.
+func (in *PushgatewayConfig) DeepCopy() *PushgatewayConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PushgatewayConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantFrom) DeepCopyInto(out *ReferenceGrantFrom) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantFrom.
+func (in *ReferenceGrantFrom) DeepCopy() *ReferenceGrantFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantTo) DeepCopyInto(out *ReferenceGrantTo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantTo.
+func (in *ReferenceGrantTo) DeepCopy() *ReferenceGrantTo {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantTo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryFailure) DeepCopyInto(out *RepositoryFailure) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryFailure.
+func (in *RepositoryFailure) DeepCopy() *RepositoryFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryStatistics) DeepCopyInto(out *RepositoryStatistics) {
+	*out = *in
+	if in.LastUpdated != nil {
+		in, out := &in.LastUpdated, &out.LastUpdated
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryStatistics.
+func (in *RepositoryStatistics) DeepCopy() *RepositoryStatistics {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryStatistics)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryTemplate) DeepCopyInto(out *RepositoryTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryTemplate.
+func (in *RepositoryTemplate) DeepCopy() *RepositoryTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RepositoryTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryTemplateCredentials) DeepCopyInto(out *RepositoryTemplateCredentials) {
+	*out = *in
+	if in.SourceSecretRef != nil {
+		in, out := &in.SourceSecretRef, &out.SourceSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.GeneratePassword != nil {
+		in, out := &in.GeneratePassword, &out.GeneratePassword
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Provisioner != nil {
+		in, out := &in.Provisioner, &out.Provisioner
+		*out = new(CredentialsProvisioner)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryTemplateCredentials.
+func (in *RepositoryTemplateCredentials) DeepCopy() *RepositoryTemplateCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryTemplateCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryTemplateList) DeepCopyInto(out *RepositoryTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RepositoryTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryTemplateList.
+func (in *RepositoryTemplateList) DeepCopy() *RepositoryTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RepositoryTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryTemplateResource) DeepCopyInto(out *RepositoryTemplateResource) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryTemplateResource.
+func (in *RepositoryTemplateResource) DeepCopy() *RepositoryTemplateResource {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryTemplateResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryTemplateSpec) DeepCopyInto(out *RepositoryTemplateSpec) {
+	*out = *in
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	in.Credentials.DeepCopyInto(&out.Credentials)
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryTemplateSpec.
+func (in *RepositoryTemplateSpec) DeepCopy() *RepositoryTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryTemplateStatus) DeepCopyInto(out *RepositoryTemplateStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]TemplatedRepository, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryTemplateStatus.
+func (in *RepositoryTemplateStatus) DeepCopy() *RepositoryTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceKind) DeepCopyInto(out *ResourceKind) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceKind.
+func (in *ResourceKind) DeepCopy() *ResourceKind {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceKind)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourcesSource) DeepCopyInto(out *ResourcesSource) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]ResourceKind, len(*in))
+		copy(*out, *in)
+	}
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcesSource.
+func (in *ResourcesSource) DeepCopy() *ResourcesSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourcesSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticBackup) DeepCopyInto(out *ResticBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticBackup.
+func (in *ResticBackup) DeepCopy() *ResticBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticBackupList) DeepCopyInto(out *ResticBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResticBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticBackupList.
+func (in *ResticBackupList) DeepCopy() *ResticBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticBackupSpec) DeepCopyInto(out *ResticBackupSpec) {
+	*out = *in
+	out.RepositoryRef = in.RepositoryRef
+	if in.FallbackRepositoryRef != nil {
+		in, out := &in.FallbackRepositoryRef, &out.FallbackRepositoryRef
+		*out = new(CrossNamespaceObjectReference)
+		**out = **in
+	}
+	if in.FallbackAfter != nil {
+		in, out := &in.FallbackAfter, &out.FallbackAfter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	in.Source.DeepCopyInto(&out.Source)
+	if in.Restic != nil {
+		in, out := &in.Restic, &out.Restic
+		*out = new(ResticConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(BackupHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(RetentionConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = new(NotificationConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobConfig != nil {
+		in, out := &in.JobConfig, &out.JobConfig
+		*out = new(JobConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BackupWindow != nil {
+		in, out := &in.BackupWindow, &out.BackupWindow
+		*out = new(BackupWindow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BlackoutPeriods != nil {
+		in, out := &in.BlackoutPeriods, &out.BlackoutPeriods
+		*out = make([]BlackoutPeriod, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AutoTuneResources != nil {
+		in, out := &in.AutoTuneResources, &out.AutoTuneResources
+		*out = new(AutoTuneResources)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(BackupRetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxStaleness != nil {
+		in, out := &in.MaxStaleness, &out.MaxStaleness
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticBackupSpec.
+func (in *ResticBackupSpec) DeepCopy() *ResticBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticBackupStatus) DeepCopyInto(out *ResticBackupStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastBackup != nil {
+		in, out := &in.LastBackup, &out.LastBackup
+		*out = new(BackupRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastSuccessfulBackup != nil {
+		in, out := &in.LastSuccessfulBackup, &out.LastSuccessfulBackup
+		*out = (*in).DeepCopy()
+	}
+	if in.NextBackup != nil {
+		in, out := &in.NextBackup, &out.NextBackup
+		*out = (*in).DeepCopy()
+	}
+	if in.Statistics != nil {
+		in, out := &in.Statistics, &out.Statistics
+		*out = new(BackupStatistics)
+		**out = **in
+	}
+	if in.DataAddedHistory != nil {
+		in, out := &in.DataAddedHistory, &out.DataAddedHistory
+		*out = make([]BackupDataSample, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = make([]HookStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Pause != nil {
+		in, out := &in.Pause, &out.Pause
+		*out = new(PauseStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScaledDownWorkload != nil {
+		in, out := &in.ScaledDownWorkload, &out.ScaledDownWorkload
+		*out = new(WorkloadReference)
+		**out = **in
+	}
+	if in.EffectiveRetention != nil {
+		in, out := &in.EffectiveRetention, &out.EffectiveRetention
+		*out = new(EffectiveRetention)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SourcePVC != nil {
+		in, out := &in.SourcePVC, &out.SourcePVC
+		*out = new(SourcePVCStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastRetentionRun != nil {
+		in, out := &in.LastRetentionRun, &out.LastRetentionRun
+		*out = (*in).DeepCopy()
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(BackupResourcesStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(BackupRetryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DiscoveredPVCs != nil {
+		in, out := &in.DiscoveredPVCs, &out.DiscoveredPVCs
+		*out = make([]DiscoveredPVC, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CronJobRef != nil {
+		in, out := &in.CronJobRef, &out.CronJobRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+	if in.RenderedManifestRef != nil {
+		in, out := &in.RenderedManifestRef, &out.RenderedManifestRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticBackupStatus.
+func (in *ResticBackupStatus) DeepCopy() *ResticBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticCheck) DeepCopyInto(out *ResticCheck) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticCheck.
+func (in *ResticCheck) DeepCopy() *ResticCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticCheck) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticCheckList) DeepCopyInto(out *ResticCheckList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResticCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticCheckList.
+func (in *ResticCheckList) DeepCopy() *ResticCheckList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticCheckList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticCheckList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticCheckSpec) DeepCopyInto(out *ResticCheckSpec) {
+	*out = *in
+	out.RepositoryRef = in.RepositoryRef
+	if in.JobConfig != nil {
+		in, out := &in.JobConfig, &out.JobConfig
+		*out = new(JobConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticCheckSpec.
+func (in *ResticCheckSpec) DeepCopy() *ResticCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticCheckStatus) DeepCopyInto(out *ResticCheckStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastCheck != nil {
+		in, out := &in.LastCheck, &out.LastCheck
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulCheck != nil {
+		in, out := &in.LastSuccessfulCheck, &out.LastSuccessfulCheck
+		*out = (*in).DeepCopy()
+	}
+	if in.NextCheck != nil {
+		in, out := &in.NextCheck, &out.NextCheck
+		*out = (*in).DeepCopy()
+	}
+	if in.CronJobRef != nil {
+		in, out := &in.CronJobRef, &out.CronJobRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticCheckStatus.
+func (in *ResticCheckStatus) DeepCopy() *ResticCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticConfig) DeepCopyInto(out *ResticConfig) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExtraArgs != nil {
+		in, out := &in.ExtraArgs, &out.ExtraArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticConfig.
+func (in *ResticConfig) DeepCopy() *ResticConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticPrune) DeepCopyInto(out *ResticPrune) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticPrune.
+func (in *ResticPrune) DeepCopy() *ResticPrune {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticPrune)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticPrune) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticPruneList) DeepCopyInto(out *ResticPruneList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResticPrune, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticPruneList.
+func (in *ResticPruneList) DeepCopy() *ResticPruneList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticPruneList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticPruneList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticPruneSpec) DeepCopyInto(out *ResticPruneSpec) {
+	*out = *in
+	out.RepositoryRef = in.RepositoryRef
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = new(PruneOptions)
+		**out = **in
+	}
+	if in.JobConfig != nil {
+		in, out := &in.JobConfig, &out.JobConfig
+		*out = new(JobConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticPruneSpec.
+func (in *ResticPruneSpec) DeepCopy() *ResticPruneSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticPruneSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticPruneStatus) DeepCopyInto(out *ResticPruneStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.JobRef != nil {
+		in, out := &in.JobRef, &out.JobRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticPruneStatus.
+func (in *ResticPruneStatus) DeepCopy() *ResticPruneStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticPruneStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticReferenceGrant) DeepCopyInto(out *ResticReferenceGrant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticReferenceGrant.
+func (in *ResticReferenceGrant) DeepCopy() *ResticReferenceGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticReferenceGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticReferenceGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticReferenceGrantList) DeepCopyInto(out *ResticReferenceGrantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResticReferenceGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticReferenceGrantList.
+func (in *ResticReferenceGrantList) DeepCopy() *ResticReferenceGrantList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticReferenceGrantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticReferenceGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticReferenceGrantSpec) DeepCopyInto(out *ResticReferenceGrantSpec) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]ReferenceGrantFrom, len(*in))
+		copy(*out, *in)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]ReferenceGrantTo, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticReferenceGrantSpec.
+func (in *ResticReferenceGrantSpec) DeepCopy() *ResticReferenceGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticReferenceGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticReplication) DeepCopyInto(out *ResticReplication) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticReplication.
+func (in *ResticReplication) DeepCopy() *ResticReplication {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticReplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticReplication) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticReplicationList) DeepCopyInto(out *ResticReplicationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResticReplication, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticReplicationList.
+func (in *ResticReplicationList) DeepCopy() *ResticReplicationList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticReplicationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticReplicationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticReplicationSpec) DeepCopyInto(out *ResticReplicationSpec) {
+	*out = *in
+	out.SourceRepositoryRef = in.SourceRepositoryRef
+	out.DestinationRepositoryRef = in.DestinationRepositoryRef
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.JobConfig != nil {
+		in, out := &in.JobConfig, &out.JobConfig
+		*out = new(JobConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticReplicationSpec.
+func (in *ResticReplicationSpec) DeepCopy() *ResticReplicationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticReplicationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticReplicationStatus) DeepCopyInto(out *ResticReplicationStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastReplication != nil {
+		in, out := &in.LastReplication, &out.LastReplication
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulReplication != nil {
+		in, out := &in.LastSuccessfulReplication, &out.LastSuccessfulReplication
+		*out = (*in).DeepCopy()
+	}
+	if in.NextReplication != nil {
+		in, out := &in.NextReplication, &out.NextReplication
+		*out = (*in).DeepCopy()
+	}
+	if in.ReplicatedSnapshots != nil {
+		in, out := &in.ReplicatedSnapshots, &out.ReplicatedSnapshots
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CronJobRef != nil {
+		in, out := &in.CronJobRef, &out.CronJobRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticReplicationStatus.
+func (in *ResticReplicationStatus) DeepCopy() *ResticReplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticReplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticRepository) DeepCopyInto(out *ResticRepository) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRepository.
+func (in *ResticRepository) DeepCopy() *ResticRepository {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticRepository)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticRepository) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticRepositoryList) DeepCopyInto(out *ResticRepositoryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResticRepository, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRepositoryList.
+func (in *ResticRepositoryList) DeepCopy() *ResticRepositoryList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticRepositoryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResticRepositoryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticRepositorySpec) DeepCopyInto(out *ResticRepositorySpec) {
+	*out = *in
+	out.CredentialsSecretRef = in.CredentialsSecretRef
+	if in.CredentialsKeyMapping != nil {
+		in, out := &in.CredentialsKeyMapping, &out.CredentialsKeyMapping
+		*out = new(CredentialsKeyMapping)
+		**out = **in
+	}
+	if in.EnvFromSecret != nil {
+		in, out := &in.EnvFromSecret, &out.EnvFromSecret
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.CredentialsCheckInterval != nil {
+		in, out := &in.CredentialsCheckInterval, &out.CredentialsCheckInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.StatsInterval != nil {
+		in, out := &in.StatsInterval, &out.StatsInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.IntegrityCheck != nil {
+		in, out := &in.IntegrityCheck, &out.IntegrityCheck
+		*out = new(IntegrityCheckConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(CacheConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DefaultRetention != nil {
+		in, out := &in.DefaultRetention, &out.DefaultRetention
+		*out = new(RetentionConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxConcurrentBackups != nil {
+		in, out := &in.MaxConcurrentBackups, &out.MaxConcurrentBackups
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SpaceCheck != nil {
+		in, out := &in.SpaceCheck, &out.SpaceCheck
+		*out = new(SpaceCheckConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SnapshotListing != nil {
+		in, out := &in.SnapshotListing, &out.SnapshotListing
+		*out = new(SnapshotListingConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Heartbeat != nil {
+		in, out := &in.Heartbeat, &out.Heartbeat
+		*out = new(HeartbeatConfig)
+		**out = **in
+	}
+	if in.LatencyProbe != nil {
+		in, out := &in.LatencyProbe, &out.LatencyProbe
+		*out = new(LatencyProbeConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Intermittent != nil {
+		in, out := &in.Intermittent, &out.Intermittent
+		*out = new(IntermittentConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRepositorySpec.
+func (in *ResticRepositorySpec) DeepCopy() *ResticRepositorySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResticRepositorySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResticRepositoryStatus) DeepCopyInto(out *ResticRepositoryStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastCredentialsCheck != nil {
+		in, out := &in.LastCredentialsCheck, &out.LastCredentialsCheck
+		*out = (*in).DeepCopy()
+	}
+	if in.LastIntegrityCheck != nil {
+		in, out := &in.LastIntegrityCheck, &out.LastIntegrityCheck
+		*out = (*in).DeepCopy()
+	}
+	if in.Statistics != nil {
+		in, out := &in.Statistics, &out.Statistics
+		*out = new(RepositoryStatistics)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StatisticsUpdatedAt != nil {
+		in, out := &in.StatisticsUpdatedAt, &out.StatisticsUpdatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]SnapshotInfo, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RetentionReport != nil {
+		in, out := &in.RetentionReport, &out.RetentionReport
+		*out = new(RetentionReport)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(CacheStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastFailure != nil {
+		in, out := &in.LastFailure, &out.LastFailure
+		*out = new(RepositoryFailure)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryStatistics.
-func (in *RepositoryStatistics) DeepCopy() *RepositoryStatistics {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRepositoryStatus.
+func (in *ResticRepositoryStatus) DeepCopy() *ResticRepositoryStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(RepositoryStatistics)
+	out := new(ResticRepositoryStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticBackup) DeepCopyInto(out *ResticBackup) {
+func (in *ResticRestore) DeepCopyInto(out *ResticRestore) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -678,18 +3017,18 @@ func (in *ResticBackup) DeepCopyInto(out *ResticBackup) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticBackup.
-func (in *ResticBackup) DeepCopy() *ResticBackup {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRestore.
+func (in *ResticRestore) DeepCopy() *ResticRestore {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticBackup)
+	out := new(ResticRestore)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResticBackup) DeepCopyObject() runtime.Object {
+func (in *ResticRestore) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -697,31 +3036,31 @@ func (in *ResticBackup) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticBackupList) DeepCopyInto(out *ResticBackupList) {
+func (in *ResticRestoreList) DeepCopyInto(out *ResticRestoreList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ResticBackup, len(*in))
+		*out = make([]ResticRestore, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticBackupList.
-func (in *ResticBackupList) DeepCopy() *ResticBackupList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRestoreList.
+func (in *ResticRestoreList) DeepCopy() *ResticRestoreList {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticBackupList)
+	out := new(ResticRestoreList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResticBackupList) DeepCopyObject() runtime.Object {
+func (in *ResticRestoreList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -729,28 +3068,48 @@ func (in *ResticBackupList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticBackupSpec) DeepCopyInto(out *ResticBackupSpec) {
+func (in *ResticRestoreSpec) DeepCopyInto(out *ResticRestoreSpec) {
 	*out = *in
-	out.RepositoryRef = in.RepositoryRef
-	in.Source.DeepCopyInto(&out.Source)
-	if in.Restic != nil {
-		in, out := &in.Restic, &out.Restic
-		*out = new(ResticConfig)
+	out.BackupRef = in.BackupRef
+	if in.SnapshotSelector != nil {
+		in, out := &in.SnapshotSelector, &out.SnapshotSelector
+		*out = new(SnapshotSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Chain != nil {
+		in, out := &in.Chain, &out.Chain
+		*out = new(RestoreChain)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Target.DeepCopyInto(&out.Target)
+	if in.IncludePaths != nil {
+		in, out := &in.IncludePaths, &out.IncludePaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludePaths != nil {
+		in, out := &in.ExcludePaths, &out.ExcludePaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = new(RestoreOptions)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.Hooks != nil {
 		in, out := &in.Hooks, &out.Hooks
-		*out = new(BackupHooks)
+		*out = new(RestoreHooks)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.Retention != nil {
-		in, out := &in.Retention, &out.Retention
-		*out = new(RetentionConfig)
+	if in.StopWorkload != nil {
+		in, out := &in.StopWorkload, &out.StopWorkload
+		*out = new(ScaleDownHook)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.Notifications != nil {
-		in, out := &in.Notifications, &out.Notifications
-		*out = new(NotificationConfig)
+	if in.Assertions != nil {
+		in, out := &in.Assertions, &out.Assertions
+		*out = new(RestoreAssertions)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.JobConfig != nil {
@@ -760,18 +3119,18 @@ func (in *ResticBackupSpec) DeepCopyInto(out *ResticBackupSpec) {
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticBackupSpec.
-func (in *ResticBackupSpec) DeepCopy() *ResticBackupSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRestoreSpec.
+func (in *ResticRestoreSpec) DeepCopy() *ResticRestoreSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticBackupSpec)
+	out := new(ResticRestoreSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticBackupStatus) DeepCopyInto(out *ResticBackupStatus) {
+func (in *ResticRestoreStatus) DeepCopyInto(out *ResticRestoreStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -780,91 +3139,80 @@ func (in *ResticBackupStatus) DeepCopyInto(out *ResticBackupStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.LastBackup != nil {
-		in, out := &in.LastBackup, &out.LastBackup
-		*out = new(BackupRunStatus)
-		(*in).DeepCopyInto(*out)
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
 	}
-	if in.LastSuccessfulBackup != nil {
-		in, out := &in.LastSuccessfulBackup, &out.LastSuccessfulBackup
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
 		*out = (*in).DeepCopy()
 	}
-	if in.NextBackup != nil {
-		in, out := &in.NextBackup, &out.NextBackup
+	if in.RestoredSnapshots != nil {
+		in, out := &in.RestoredSnapshots, &out.RestoredSnapshots
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RestoredSnapshotTime != nil {
+		in, out := &in.RestoredSnapshotTime, &out.RestoredSnapshotTime
 		*out = (*in).DeepCopy()
 	}
-	if in.Statistics != nil {
-		in, out := &in.Statistics, &out.Statistics
-		*out = new(BackupStatistics)
+	if in.Assertions != nil {
+		in, out := &in.Assertions, &out.Assertions
+		*out = make([]RestoreAssertionResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.JobRef != nil {
+		in, out := &in.JobRef, &out.JobRef
+		*out = new(ObjectReference)
 		**out = **in
 	}
-	if in.LastRetentionRun != nil {
-		in, out := &in.LastRetentionRun, &out.LastRetentionRun
-		*out = (*in).DeepCopy()
+	if in.StoppedWorkload != nil {
+		in, out := &in.StoppedWorkload, &out.StoppedWorkload
+		*out = new(WorkloadReference)
+		**out = **in
 	}
 	if in.CronJobRef != nil {
 		in, out := &in.CronJobRef, &out.CronJobRef
 		*out = new(ObjectReference)
 		**out = **in
 	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticBackupStatus.
-func (in *ResticBackupStatus) DeepCopy() *ResticBackupStatus {
-	if in == nil {
-		return nil
-	}
-	out := new(ResticBackupStatus)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticConfig) DeepCopyInto(out *ResticConfig) {
-	*out = *in
-	if in.Tags != nil {
-		in, out := &in.Tags, &out.Tags
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.ExtraArgs != nil {
-		in, out := &in.ExtraArgs, &out.ExtraArgs
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.Drill != nil {
+		in, out := &in.Drill, &out.Drill
+		*out = new(RestoreDrillStatus)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticConfig.
-func (in *ResticConfig) DeepCopy() *ResticConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRestoreStatus.
+func (in *ResticRestoreStatus) DeepCopy() *ResticRestoreStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticConfig)
+	out := new(ResticRestoreStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticRepository) DeepCopyInto(out *ResticRepository) {
+func (in *ResticSnapshotRef) DeepCopyInto(out *ResticSnapshotRef) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRepository.
-func (in *ResticRepository) DeepCopy() *ResticRepository {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticSnapshotRef.
+func (in *ResticSnapshotRef) DeepCopy() *ResticSnapshotRef {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticRepository)
+	out := new(ResticSnapshotRef)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResticRepository) DeepCopyObject() runtime.Object {
+func (in *ResticSnapshotRef) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -872,31 +3220,31 @@ func (in *ResticRepository) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticRepositoryList) DeepCopyInto(out *ResticRepositoryList) {
+func (in *ResticSnapshotRefList) DeepCopyInto(out *ResticSnapshotRefList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ResticRepository, len(*in))
+		*out = make([]ResticSnapshotRef, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRepositoryList.
-func (in *ResticRepositoryList) DeepCopy() *ResticRepositoryList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticSnapshotRefList.
+func (in *ResticSnapshotRefList) DeepCopy() *ResticSnapshotRefList {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticRepositoryList)
+	out := new(ResticSnapshotRefList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResticRepositoryList) DeepCopyObject() runtime.Object {
+func (in *ResticSnapshotRefList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -904,199 +3252,143 @@ func (in *ResticRepositoryList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticRepositorySpec) DeepCopyInto(out *ResticRepositorySpec) {
+func (in *ResticSnapshotRefSpec) DeepCopyInto(out *ResticSnapshotRefSpec) {
 	*out = *in
-	out.CredentialsSecretRef = in.CredentialsSecretRef
-	if in.IntegrityCheck != nil {
-		in, out := &in.IntegrityCheck, &out.IntegrityCheck
-		*out = new(IntegrityCheckConfig)
-		**out = **in
-	}
-	if in.Cache != nil {
-		in, out := &in.Cache, &out.Cache
-		*out = new(CacheConfig)
-		**out = **in
+	out.BackupRef = in.BackupRef
+	if in.SnapshotSelector != nil {
+		in, out := &in.SnapshotSelector, &out.SnapshotSelector
+		*out = new(SnapshotSelector)
+		(*in).DeepCopyInto(*out)
 	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRepositorySpec.
-func (in *ResticRepositorySpec) DeepCopy() *ResticRepositorySpec {
-	if in == nil {
-		return nil
+	if in.IncludePaths != nil {
+		in, out := &in.IncludePaths, &out.IncludePaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	out := new(ResticRepositorySpec)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticRepositoryStatus) DeepCopyInto(out *ResticRepositoryStatus) {
-	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.ExcludePaths != nil {
+		in, out := &in.ExcludePaths, &out.ExcludePaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	if in.LastIntegrityCheck != nil {
-		in, out := &in.LastIntegrityCheck, &out.LastIntegrityCheck
-		*out = (*in).DeepCopy()
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = new(RestoreOptions)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.Statistics != nil {
-		in, out := &in.Statistics, &out.Statistics
-		*out = new(RepositoryStatistics)
-		**out = **in
+	if in.JobConfig != nil {
+		in, out := &in.JobConfig, &out.JobConfig
+		*out = new(JobConfiguration)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRepositoryStatus.
-func (in *ResticRepositoryStatus) DeepCopy() *ResticRepositoryStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticSnapshotRefSpec.
+func (in *ResticSnapshotRefSpec) DeepCopy() *ResticSnapshotRefSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticRepositoryStatus)
+	out := new(ResticSnapshotRefSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticRestore) DeepCopyInto(out *ResticRestore) {
+func (in *RestoreAssertionResult) DeepCopyInto(out *RestoreAssertionResult) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRestore.
-func (in *ResticRestore) DeepCopy() *ResticRestore {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreAssertionResult.
+func (in *RestoreAssertionResult) DeepCopy() *RestoreAssertionResult {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticRestore)
+	out := new(RestoreAssertionResult)
 	in.DeepCopyInto(out)
 	return out
 }
-
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResticRestore) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticRestoreList) DeepCopyInto(out *ResticRestoreList) {
-	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]ResticRestore, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreAssertions) DeepCopyInto(out *RestoreAssertions) {
+	*out = *in
+	if in.PathsExist != nil {
+		in, out := &in.PathsExist, &out.PathsExist
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MinFileCount != nil {
+		in, out := &in.MinFileCount, &out.MinFileCount
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ChecksumManifest != nil {
+		in, out := &in.ChecksumManifest, &out.ChecksumManifest
+		*out = new(ChecksumManifest)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRestoreList.
-func (in *ResticRestoreList) DeepCopy() *ResticRestoreList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreAssertions.
+func (in *RestoreAssertions) DeepCopy() *RestoreAssertions {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticRestoreList)
+	out := new(RestoreAssertions)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResticRestoreList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticRestoreSpec) DeepCopyInto(out *ResticRestoreSpec) {
+func (in *RestoreChain) DeepCopyInto(out *RestoreChain) {
 	*out = *in
-	out.BackupRef = in.BackupRef
-	if in.SnapshotSelector != nil {
-		in, out := &in.SnapshotSelector, &out.SnapshotSelector
-		*out = new(SnapshotSelector)
-		(*in).DeepCopyInto(*out)
-	}
-	in.Target.DeepCopyInto(&out.Target)
-	if in.IncludePaths != nil {
-		in, out := &in.IncludePaths, &out.IncludePaths
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.ExcludePaths != nil {
-		in, out := &in.ExcludePaths, &out.ExcludePaths
+	if in.SnapshotIDs != nil {
+		in, out := &in.SnapshotIDs, &out.SnapshotIDs
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.Options != nil {
-		in, out := &in.Options, &out.Options
-		*out = new(RestoreOptions)
-		**out = **in
-	}
-	if in.Hooks != nil {
-		in, out := &in.Hooks, &out.Hooks
-		*out = new(RestoreHooks)
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(SnapshotSelector)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.JobConfig != nil {
-		in, out := &in.JobConfig, &out.JobConfig
-		*out = new(JobConfiguration)
-		(*in).DeepCopyInto(*out)
+	if in.After != nil {
+		in, out := &in.After, &out.After
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRestoreSpec.
-func (in *ResticRestoreSpec) DeepCopy() *ResticRestoreSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreChain.
+func (in *RestoreChain) DeepCopy() *RestoreChain {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticRestoreSpec)
+	out := new(RestoreChain)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResticRestoreStatus) DeepCopyInto(out *ResticRestoreStatus) {
+func (in *RestoreDrillStatus) DeepCopyInto(out *RestoreDrillStatus) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.StartTime != nil {
-		in, out := &in.StartTime, &out.StartTime
+	if in.LastRunTime != nil {
+		in, out := &in.LastRunTime, &out.LastRunTime
 		*out = (*in).DeepCopy()
 	}
-	if in.CompletionTime != nil {
-		in, out := &in.CompletionTime, &out.CompletionTime
+	if in.LastSuccessTime != nil {
+		in, out := &in.LastSuccessTime, &out.LastSuccessTime
 		*out = (*in).DeepCopy()
 	}
-	if in.JobRef != nil {
-		in, out := &in.JobRef, &out.JobRef
-		*out = new(ObjectReference)
-		**out = **in
+	if in.NextRunTime != nil {
+		in, out := &in.NextRunTime, &out.NextRunTime
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResticRestoreStatus.
-func (in *ResticRestoreStatus) DeepCopy() *ResticRestoreStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreDrillStatus.
+func (in *RestoreDrillStatus) DeepCopy() *RestoreDrillStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ResticRestoreStatus)
+	out := new(RestoreDrillStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -1129,6 +3421,11 @@ func (in *RestoreHooks) DeepCopy() *RestoreHooks {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RestoreOptions) DeepCopyInto(out *RestoreOptions) {
 	*out = *in
+	if in.FixOwnership != nil {
+		in, out := &in.FixOwnership, &out.FixOwnership
+		*out = new(FixOwnership)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreOptions.
@@ -1154,6 +3451,16 @@ func (in *RestoreTarget) DeepCopyInto(out *RestoreTarget) {
 		*out = new(NewPVCTarget)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Pod != nil {
+		in, out := &in.Pod, &out.Pod
+		*out = new(PodTarget)
+		**out = **in
+	}
+	if in.Dump != nil {
+		in, out := &in.Dump, &out.Dump
+		*out = new(DumpTarget)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreTarget.
@@ -1253,6 +3560,52 @@ func (in *RetentionPolicyEntry) DeepCopy() *RetentionPolicyEntry {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetentionReport) DeepCopyInto(out *RetentionReport) {
+	*out = *in
+	if in.LastUpdated != nil {
+		in, out := &in.LastUpdated, &out.LastUpdated
+		*out = (*in).DeepCopy()
+	}
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]BackupRetentionReport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetentionReport.
+func (in *RetentionReport) DeepCopy() *RetentionReport {
+	if in == nil {
+		return nil
+	}
+	out := new(RetentionReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetentionScheduleOverlap) DeepCopyInto(out *RetentionScheduleOverlap) {
+	*out = *in
+	if in.OverlappingBackups != nil {
+		in, out := &in.OverlappingBackups, &out.OverlappingBackups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetentionScheduleOverlap.
+func (in *RetentionScheduleOverlap) DeepCopy() *RetentionScheduleOverlap {
+	if in == nil {
+		return nil
+	}
+	out := new(RetentionScheduleOverlap)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RetentionSelector) DeepCopyInto(out *RetentionSelector) {
 	*out = *in
@@ -1261,6 +3614,16 @@ func (in *RetentionSelector) DeepCopyInto(out *RetentionSelector) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeSnapshotIDs != nil {
+		in, out := &in.ExcludeSnapshotIDs, &out.ExcludeSnapshotIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetentionSelector.
@@ -1273,6 +3636,27 @@ func (in *RetentionSelector) DeepCopy() *RetentionSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScaleDownHook) DeepCopyInto(out *ScaleDownHook) {
+	*out = *in
+	out.WorkloadRef = in.WorkloadRef
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScaleDownHook.
+func (in *ScaleDownHook) DeepCopy() *ScaleDownHook {
+	if in == nil {
+		return nil
+	}
+	out := new(ScaleDownHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretKeySelector) DeepCopyInto(out *SecretKeySelector) {
 	*out = *in
@@ -1288,6 +3672,52 @@ func (in *SecretKeySelector) DeepCopy() *SecretKeySelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotInfo) DeepCopyInto(out *SnapshotInfo) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotInfo.
+func (in *SnapshotInfo) DeepCopy() *SnapshotInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotListingConfig) DeepCopyInto(out *SnapshotListingConfig) {
+	*out = *in
+	if in.MaxSnapshots != nil {
+		in, out := &in.MaxSnapshots, &out.MaxSnapshots
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotListingConfig.
+func (in *SnapshotListingConfig) DeepCopy() *SnapshotListingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotListingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SnapshotSelector) DeepCopyInto(out *SnapshotSelector) {
 	*out = *in
@@ -1296,6 +3726,11 @@ func (in *SnapshotSelector) DeepCopyInto(out *SnapshotSelector) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Before != nil {
 		in, out := &in.Before, &out.Before
 		*out = (*in).DeepCopy()
@@ -1311,3 +3746,118 @@ func (in *SnapshotSelector) DeepCopy() *SnapshotSelector {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SourcePVCStatus) DeepCopyInto(out *SourcePVCStatus) {
+	*out = *in
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourcePVCStatus.
+func (in *SourcePVCStatus) DeepCopy() *SourcePVCStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SourcePVCStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpaceCheckConfig) DeepCopyInto(out *SpaceCheckConfig) {
+	*out = *in
+	out.MinFreeSpace = in.MinFreeSpace.DeepCopy()
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpaceCheckConfig.
+func (in *SpaceCheckConfig) DeepCopy() *SpaceCheckConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SpaceCheckConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplatedRepository) DeepCopyInto(out *TemplatedRepository) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplatedRepository.
+func (in *TemplatedRepository) DeepCopy() *TemplatedRepository {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplatedRepository)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeRange) DeepCopyInto(out *TimeRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeRange.
+func (in *TimeRange) DeepCopy() *TimeRange {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VerificationDrift) DeepCopyInto(out *VerificationDrift) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerificationDrift.
+func (in *VerificationDrift) DeepCopy() *VerificationDrift {
+	if in == nil {
+		return nil
+	}
+	out := new(VerificationDrift)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadReference) DeepCopyInto(out *WorkloadReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadReference.
+func (in *WorkloadReference) DeepCopy() *WorkloadReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadReference)
+	in.DeepCopyInto(out)
+	return out
+}
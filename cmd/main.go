@@ -20,9 +20,11 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -31,10 +33,17 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
 	"github.com/madic-creates/restic-backup-operator/internal/controller"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+	"github.com/madic-creates/restic-backup-operator/internal/notifications"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+	"github.com/madic-creates/restic-backup-operator/internal/version"
+	webhookv1alpha1 "github.com/madic-creates/restic-backup-operator/internal/webhook/v1alpha1"
 )
 
 var (
@@ -54,6 +63,30 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var staleLockThreshold time.Duration
+	var maxConcurrentRestores int
+	var maxConcurrentRestoresPerNamespace int
+	var maxConcurrentBackups int
+	var repositoryConcurrency, backupConcurrency, restoreConcurrency, pruneConcurrency, checkConcurrency, retentionConcurrency, verificationConcurrency int
+	var namespaceRestoreConcurrency, replicationConcurrency, repositoryTemplateConcurrency, clusterBackupPolicyConcurrency int
+	var overloadDepthThreshold int
+	var statsWorkers, statsQueueSize int
+	var statsCooldown time.Duration
+	var snapshotCacheMaxAge, snapshotCacheRefreshInterval time.Duration
+	var resticMaxAttempts int
+	var resticRetryBackoff, resticMaxRetryBackoff time.Duration
+	var resticTimeouts string
+	var stateExportRepository string
+	var stateExportInterval time.Duration
+	var stateExportKeep int
+	var overloadLatencyThreshold time.Duration
+	var imageMirror, imageDigests string
+	var jobActiveDeadlines, jobBackoffLimits string
+	var annotatedPVCSchedule, annotatedPVCRepository string
+	var statusConfigMapName string
+	var enableWebhooks, denyCrossNamespaceReferences bool
+	var webhookCertDir, danglingReferencePolicy string
+	var webhookPort, maxBackupsPerNamespace, maxActiveRestoresPerNamespace int
+	featureGates := features.NewGate()
 
 	// Default stale lock threshold, can be overridden by env var
 	defaultStaleLockThreshold := 30 * time.Minute
@@ -76,6 +109,102 @@ func main() {
 	flag.DurationVar(&staleLockThreshold, "stale-lock-threshold", defaultStaleLockThreshold,
 		"Duration after which a repository lock is considered stale and can be removed automatically. "+
 			"Can also be set via STALE_LOCK_THRESHOLD environment variable. Example: 30m, 1h, 2h30m")
+	flag.IntVar(&maxConcurrentRestores, "max-concurrent-restores", 0,
+		"Maximum number of restore jobs running cluster-wide. Additional restores are queued. 0 means unlimited.")
+	flag.IntVar(&maxConcurrentRestoresPerNamespace, "max-concurrent-restores-per-namespace", 0,
+		"Maximum number of restore jobs running per namespace. Additional restores are queued. 0 means unlimited.")
+	flag.IntVar(&maxConcurrentBackups, "max-concurrent-backups", 0,
+		"Maximum number of backup jobs running cluster-wide. Additional backup jobs wait suspended. 0 means unlimited.")
+	flag.IntVar(&repositoryConcurrency, "repository-max-concurrent-reconciles", 1,
+		"Maximum number of ResticRepositories reconciled in parallel.")
+	flag.IntVar(&backupConcurrency, "backup-max-concurrent-reconciles", 1,
+		"Maximum number of ResticBackups reconciled in parallel.")
+	flag.IntVar(&restoreConcurrency, "restore-max-concurrent-reconciles", 1,
+		"Maximum number of ResticRestores reconciled in parallel.")
+	flag.IntVar(&pruneConcurrency, "prune-max-concurrent-reconciles", 1,
+		"Maximum number of ResticPrunes reconciled in parallel.")
+	flag.IntVar(&checkConcurrency, "check-max-concurrent-reconciles", 1,
+		"Maximum number of ResticChecks reconciled in parallel.")
+	flag.IntVar(&verificationConcurrency, "verification-max-concurrent-reconciles", 1,
+		"Maximum number of BackupVerifications reconciled in parallel.")
+	flag.IntVar(&retentionConcurrency, "retention-max-concurrent-reconciles", 1,
+		"Maximum number of GlobalRetentionPolicies reconciled in parallel.")
+	flag.IntVar(&namespaceRestoreConcurrency, "namespace-restore-max-concurrent-reconciles", 1,
+		"Maximum number of NamespaceRestores reconciled in parallel.")
+	flag.IntVar(&replicationConcurrency, "replication-max-concurrent-reconciles", 1,
+		"Maximum number of ResticReplications reconciled in parallel.")
+	flag.IntVar(&repositoryTemplateConcurrency, "repository-template-max-concurrent-reconciles", 1,
+		"Maximum number of RepositoryTemplates reconciled in parallel.")
+	flag.IntVar(&clusterBackupPolicyConcurrency, "cluster-backup-policy-max-concurrent-reconciles", 1,
+		"Maximum number of ClusterBackupPolicies reconciled in parallel.")
+	flag.IntVar(&overloadDepthThreshold, "overload-queue-depth-threshold", 100,
+		"Workqueue depth above which a controller is reported as overloaded. 0 disables the check.")
+	flag.DurationVar(&overloadLatencyThreshold, "overload-queue-latency-threshold", time.Minute,
+		"Average workqueue wait time above which a controller is reported as overloaded. 0 disables the check.")
+	flag.IntVar(&statsWorkers, "stats-workers", 2,
+		"Number of repositories whose statistics are gathered in parallel in the background.")
+	flag.IntVar(&statsQueueSize, "stats-queue-size", 100,
+		"Maximum number of repositories waiting for statistics collection. Further repositories are skipped until their next reconcile.")
+	flag.DurationVar(&statsCooldown, "stats-cooldown", 15*time.Minute,
+		"Minimum time between two statistics collections of the same repository.")
+	flag.DurationVar(&snapshotCacheMaxAge, "snapshot-cache-max-age", 10*time.Minute,
+		"Maximum age of the cached snapshot lists used to resolve restore snapshot selectors. 0 disables the cache.")
+	flag.DurationVar(&snapshotCacheRefreshInterval, "snapshot-cache-refresh-interval", 5*time.Minute,
+		"Interval in which the cached snapshot lists of recently used repositories are refreshed.")
+	flag.IntVar(&resticMaxAttempts, "restic-max-attempts", restic.DefaultRetryPolicy.MaxAttempts,
+		"Number of times restic commands of the operator are run when failing with a transient error. 1 disables retries.")
+	flag.DurationVar(&resticRetryBackoff, "restic-retry-backoff", restic.DefaultRetryPolicy.InitialBackoff,
+		"Wait before the first retry of a restic command, doubled with every retry.")
+	flag.DurationVar(&resticMaxRetryBackoff, "restic-max-retry-backoff", restic.DefaultRetryPolicy.MaxBackoff,
+		"Maximum wait between two attempts of a restic command.")
+	flag.StringVar(&resticTimeouts, "restic-timeouts", "",
+		"Comma-separated command=duration pairs overriding the timeout of restic commands run by the operator, "+
+			"e.g. check=1h,prune=12h. 0 removes the timeout of a command.")
+	flag.StringVar(&stateExportRepository, "state-export-repository", "",
+		"namespace/name of the ResticRepository the backup resources of the cluster are exported to. "+
+			"Empty disables the export.")
+	flag.DurationVar(&stateExportInterval, "state-export-interval", 24*time.Hour,
+		"Time between two exports of the backup resources of the cluster.")
+	flag.IntVar(&stateExportKeep, "state-export-keep", 30,
+		"Number of exports of the backup resources kept in the repository.")
+	flag.StringVar(&imageMirror, "image-mirror", "",
+		"Registry mirror replacing the registry of the default restic image, e.g. registry.example.com/ghcr.")
+	flag.StringVar(&imageDigests, "restic-image-digests", "",
+		"Comma-separated arch=digest pairs pinning the default restic image for pods selecting that "+
+			"architecture via jobConfig.nodeSelector, e.g. amd64=sha256:...,arm64=sha256:...")
+	flag.StringVar(&jobActiveDeadlines, "job-active-deadlines", "",
+		"Comma-separated operation=duration pairs overriding the default active deadline of backup, "+
+			"restore, retention, check, prune, verification and replication jobs, e.g. backup=12h,check=8h.")
+	flag.StringVar(&jobBackoffLimits, "job-backoff-limits", "",
+		"Comma-separated operation=limit pairs overriding the default backoff limit of 0 of backup, "+
+			"restore, retention, check, prune, verification and replication jobs, e.g. backup=2.")
+	flag.StringVar(&annotatedPVCSchedule, "annotated-pvc-default-schedule", controller.DefaultAnnotatedPVCSchedule,
+		"Schedule of the ResticBackups of annotated PVCs without schedule annotation (AnnotatedPVCBackups feature gate).")
+	flag.StringVar(&annotatedPVCRepository, "annotated-pvc-default-repository", controller.DefaultAnnotatedPVCRepository,
+		"Repository, name or namespace/name, of the ResticBackups of annotated PVCs without repository annotation "+
+			"(AnnotatedPVCBackups feature gate).")
+	flag.Var(featureGates, "feature-gates",
+		"Comma-separated Feature=bool pairs enabling or disabling optional capabilities. Options are:\n"+
+			strings.Join(features.Known(), "\n"))
+	flag.StringVar(&statusConfigMapName, "status-configmap-name", "restic-backup-operator-status",
+		"Name of the ConfigMap in the operator namespace publishing the build info, feature gates and "+
+			"controller health. Empty disables the ConfigMap.")
+
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"If set, the admission webhooks defaulting and validating the resources are served. "+
+			"Requires a serving certificate in --webhook-cert-dir.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs",
+		"Directory containing tls.crt and tls.key of the webhook server.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the webhook server listens on.")
+	flag.StringVar(&danglingReferencePolicy, "webhook-dangling-reference-policy", webhookv1alpha1.DanglingReferenceReject,
+		"How the webhooks handle references to resources that don't exist: Reject or Warn.")
+	flag.BoolVar(&denyCrossNamespaceReferences, "webhook-deny-cross-namespace-references", false,
+		"If set, the webhooks reject references to resources in other namespaces.")
+	flag.IntVar(&maxBackupsPerNamespace, "webhook-max-backups-per-namespace", 0,
+		"Maximum number of ResticBackups per namespace admitted by the webhook. 0 disables the limit.")
+	flag.IntVar(&maxActiveRestoresPerNamespace, "webhook-max-active-restores-per-namespace", 0,
+		"Maximum number of ResticRestores per namespace that haven't completed or failed, admitted by the webhook. "+
+			"0 disables the limit.")
 
 	opts := zap.Options{
 		Development: true,
@@ -107,6 +236,11 @@ func main() {
 			SecureServing: secureMetrics,
 			TLSOpts:       tlsOpts,
 		},
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Port:    webhookPort,
+			CertDir: webhookCertDir,
+			TLSOpts: tlsOpts,
+		}),
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "restic-backup-operator.backup.resticbackup.io",
@@ -116,45 +250,447 @@ func main() {
 		os.Exit(1)
 	}
 
+	setupLog.Info("using feature gates", "gates", featureGates.States())
 	setupLog.Info("using stale lock threshold", "threshold", staleLockThreshold)
 
+	digests, err := controller.ParseImageDigests(imageDigests)
+	if err != nil {
+		setupLog.Error(err, "invalid restic image digests")
+		os.Exit(1)
+	}
+	images := &controller.ImageConfig{Mirror: imageMirror, Digests: digests}
+	setupLog.Info("using default restic image", "image", images.Resolve("", nil))
+
+	activeDeadlines, err := controller.ParseJobActiveDeadlines(jobActiveDeadlines)
+	if err != nil {
+		setupLog.Error(err, "invalid job active deadlines")
+		os.Exit(1)
+	}
+	backoffLimits, err := controller.ParseJobBackoffLimits(jobBackoffLimits)
+	if err != nil {
+		setupLog.Error(err, "invalid job backoff limits")
+		os.Exit(1)
+	}
+	jobDefaults := &controller.JobDefaults{ActiveDeadlines: activeDeadlines, BackoffLimits: backoffLimits}
+
+	// Correct drifted CronJobs and Jobs before the controllers start working
+	startupAudit := controller.NewStartupAudit(mgr.GetClient(), mgr.GetScheme())
+	startupAudit.Images = images
+	startupAudit.JobDefaults = jobDefaults
+	startupAudit.FeatureGates = featureGates
+	startupAudit.MaxConcurrentBackups = maxConcurrentBackups
+	if err := mgr.Add(startupAudit); err != nil {
+		setupLog.Error(err, "unable to set up startup audit")
+		os.Exit(1)
+	}
+
+	// Restic commands run by the operator, retried on transient backend errors and
+	// killed when exceeding their timeout
+	timeouts, err := restic.ParseTimeouts(resticTimeouts)
+	if err != nil {
+		setupLog.Error(err, "invalid restic timeouts")
+		os.Exit(1)
+	}
+	resticExecutor := restic.NewExecutor(ctrl.Log.WithName("restic")).WithConfig(restic.ExecutorConfig{
+		Retry: restic.RetryPolicy{
+			MaxAttempts:    resticMaxAttempts,
+			InitialBackoff: resticRetryBackoff,
+			MaxBackoff:     resticMaxRetryBackoff,
+		},
+		Timeouts: timeouts,
+	})
+
+	// Probe the backend latency of repositories with a latency probe. Probes are not
+	// retried, so they measure a single read of the backend.
+	backendProbe := controller.NewBackendProbe(mgr.GetClient())
+	backendProbe.Executor = restic.NewExecutor(ctrl.Log.WithName("restic")).WithConfig(restic.ExecutorConfig{
+		Retry:    restic.RetryPolicy{MaxAttempts: 1},
+		Timeouts: timeouts,
+	})
+	if err := mgr.Add(backendProbe); err != nil {
+		setupLog.Error(err, "unable to set up backend probe")
+		os.Exit(1)
+	}
+
+	// Gather repository statistics in the background
+	statsCollector := controller.NewStatsCollector(mgr.GetClient(), statsQueueSize)
+	statsCollector.Executor = resticExecutor
+	statsCollector.Workers = statsWorkers
+	statsCollector.Cooldown = statsCooldown
+	if err := mgr.Add(statsCollector); err != nil {
+		setupLog.Error(err, "unable to set up stats collector")
+		os.Exit(1)
+	}
+
+	// Cache the snapshot lists of repositories for restores and retention selector checks
+	var snapshotCache *controller.SnapshotCache
+	if snapshotCacheMaxAge > 0 {
+		snapshotCache = controller.NewSnapshotCache(mgr.GetClient())
+		snapshotCache.Executor = resticExecutor
+		snapshotCache.MaxAge = snapshotCacheMaxAge
+		snapshotCache.RefreshInterval = snapshotCacheRefreshInterval
+		if err := mgr.Add(snapshotCache); err != nil {
+			setupLog.Error(err, "unable to set up snapshot cache")
+			os.Exit(1)
+		}
+	}
+
+	// Export the backup resources of the cluster so they survive the loss of the cluster
+	if stateExportRepository != "" {
+		namespace, name, ok := strings.Cut(stateExportRepository, "/")
+		if !ok || namespace == "" || name == "" {
+			setupLog.Error(nil, "invalid state export repository, expected namespace/name", "repository", stateExportRepository)
+			os.Exit(1)
+		}
+		stateExport := controller.NewStateExport(mgr.GetClient(), mgr.GetScheme(), types.NamespacedName{Namespace: namespace, Name: name})
+		stateExport.Interval = stateExportInterval
+		stateExport.KeepLast = stateExportKeep
+		stateExport.Images = images
+		stateExport.JobDefaults = jobDefaults
+		if err := mgr.Add(stateExport); err != nil {
+			setupLog.Error(err, "unable to set up state export")
+			os.Exit(1)
+		}
+	}
+
+	// Annotated PVCs only use their repository while the feature gate manages their backups
+	var repositoryOfAnnotatedPVCs string
+	if featureGates.Enabled(features.AnnotatedPVCBackups) {
+		repositoryOfAnnotatedPVCs = annotatedPVCRepository
+		if repositoryOfAnnotatedPVCs == "" {
+			repositoryOfAnnotatedPVCs = controller.DefaultAnnotatedPVCRepository
+		}
+	}
 	if err = (&controller.ResticRepositoryReconciler{
-		Client:             mgr.GetClient(),
-		Scheme:             mgr.GetScheme(),
-		Recorder:           mgr.GetEventRecorderFor("resticrepository-controller"),
-		StaleLockThreshold: staleLockThreshold,
+		Client:                  mgr.GetClient(),
+		Executor:                resticExecutor,
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("resticrepository-controller"),
+		StaleLockThreshold:      staleLockThreshold,
+		MaxConcurrentReconciles: repositoryConcurrency,
+		APIReader:               mgr.GetAPIReader(),
+		Images:                  images,
+		StatsCollector:          statsCollector,
+		JobDefaults:             jobDefaults,
+		AnnotatedPVCRepository:  repositoryOfAnnotatedPVCs,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ResticRepository")
 		os.Exit(1)
 	}
 
+	podExecutor, err := controller.NewPodExecutor(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to set up pod executor")
+		os.Exit(1)
+	}
+	podLogReader, err := controller.NewPodLogReader(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to set up pod log reader")
+		os.Exit(1)
+	}
+
+	// Batched emails are sent when the operator stops
+	notificationManager := notifications.NewManager(ctrl.Log.WithName("notifications"))
+	if err := mgr.Add(notificationManager); err != nil {
+		setupLog.Error(err, "unable to set up notifications")
+		os.Exit(1)
+	}
+
+	// Detect snapshots written with another hostname in the background
+	hostnameChecker := controller.NewHostnameChecker(mgr.GetClient(), mgr.GetEventRecorderFor("resticbackup-controller"), statsQueueSize)
+	hostnameChecker.Executor = resticExecutor
+	hostnameChecker.SnapshotCache = snapshotCache
+	if err := mgr.Add(hostnameChecker); err != nil {
+		setupLog.Error(err, "unable to set up hostname checker")
+		os.Exit(1)
+	}
+
 	if err = (&controller.ResticBackupReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("resticbackup-controller"),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("resticbackup-controller"),
+		StartupAudit:            startupAudit,
+		Notifications:           notificationManager,
+		APIReader:               mgr.GetAPIReader(),
+		PodExecutor:             podExecutor,
+		PodLogs:                 podLogReader,
+		MaxConcurrentReconciles: backupConcurrency,
+		Images:                  images,
+		JobDefaults:             jobDefaults,
+		HostnameChecker:         hostnameChecker,
+		SnapshotCache:           snapshotCache,
+		FeatureGates:            featureGates,
+		MaxConcurrentBackups:    maxConcurrentBackups,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ResticBackup")
 		os.Exit(1)
 	}
 
 	if err = (&controller.ResticRestoreReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("resticrestore-controller"),
+		Client:                            mgr.GetClient(),
+		Executor:                          resticExecutor,
+		Scheme:                            mgr.GetScheme(),
+		Recorder:                          mgr.GetEventRecorderFor("resticrestore-controller"),
+		APIReader:                         mgr.GetAPIReader(),
+		PodLogs:                           podLogReader,
+		MaxConcurrentRestores:             maxConcurrentRestores,
+		MaxConcurrentRestoresPerNamespace: maxConcurrentRestoresPerNamespace,
+		StartupAudit:                      startupAudit,
+		MaxConcurrentReconciles:           restoreConcurrency,
+		Images:                            images,
+		JobDefaults:                       jobDefaults,
+		SnapshotCache:                     snapshotCache,
+		FeatureGates:                      featureGates,
+		Notifications:                     notificationManager,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ResticRestore")
 		os.Exit(1)
 	}
 
+	if err = (&controller.ResticPruneReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("resticprune-controller"),
+		APIReader:               mgr.GetAPIReader(),
+		MaxConcurrentReconciles: pruneConcurrency,
+		Images:                  images,
+		JobDefaults:             jobDefaults,
+		FeatureGates:            featureGates,
+		SnapshotCache:           snapshotCache,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ResticPrune")
+		os.Exit(1)
+	}
+
+	if err = (&controller.ResticCheckReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("resticcheck-controller"),
+		APIReader:               mgr.GetAPIReader(),
+		StartupAudit:            startupAudit,
+		MaxConcurrentReconciles: checkConcurrency,
+		Images:                  images,
+		JobDefaults:             jobDefaults,
+		FeatureGates:            featureGates,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ResticCheck")
+		os.Exit(1)
+	}
+
+	if err = (&controller.ResticReplicationReconciler{
+		Client:                  mgr.GetClient(),
+		Executor:                resticExecutor,
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("resticreplication-controller"),
+		StartupAudit:            startupAudit,
+		MaxConcurrentReconciles: replicationConcurrency,
+		Images:                  images,
+		JobDefaults:             jobDefaults,
+		FeatureGates:            featureGates,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ResticReplication")
+		os.Exit(1)
+	}
+
+	if err = (&controller.BackupVerificationReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("backupverification-controller"),
+		APIReader:               mgr.GetAPIReader(),
+		MaxConcurrentReconciles: verificationConcurrency,
+		Images:                  images,
+		JobDefaults:             jobDefaults,
+		FeatureGates:            featureGates,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BackupVerification")
+		os.Exit(1)
+	}
+
 	if err = (&controller.GlobalRetentionPolicyReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("globalretentionpolicy-controller"),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("globalretentionpolicy-controller"),
+		StartupAudit:            startupAudit,
+		MaxConcurrentReconciles: retentionConcurrency,
+		Images:                  images,
+		JobDefaults:             jobDefaults,
+		APIReader:               mgr.GetAPIReader(),
+		FeatureGates:            featureGates,
+		SnapshotCache:           snapshotCache,
+		PodLogs:                 podLogReader,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GlobalRetentionPolicy")
 		os.Exit(1)
 	}
 
+	if err = (&controller.NamespaceRestoreReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("namespacerestore-controller"),
+		MaxConcurrentReconciles: namespaceRestoreConcurrency,
+		FeatureGates:            featureGates,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NamespaceRestore")
+		os.Exit(1)
+	}
+
+	if err = (&controller.RepositoryTemplateReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("repositorytemplate-controller"),
+		MaxConcurrentReconciles: repositoryTemplateConcurrency,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RepositoryTemplate")
+		os.Exit(1)
+	}
+
+	if err = (&controller.ClusterBackupPolicyReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("clusterbackuppolicy-controller"),
+		MaxConcurrentReconciles: clusterBackupPolicyConcurrency,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterBackupPolicy")
+		os.Exit(1)
+	}
+
+	if featureGates.Enabled(features.VolumePopulator) {
+		if err = (&controller.VolumePopulatorReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("volumepopulator-controller"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VolumePopulator")
+			os.Exit(1)
+		}
+	}
+
+	if featureGates.Enabled(features.JobEventRelay) {
+		if err = (&controller.JobEventRelayReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Recorder:  mgr.GetEventRecorderFor("jobeventrelay-controller"),
+			APIReader: mgr.GetAPIReader(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "JobEventRelay")
+			os.Exit(1)
+		}
+	}
+
+	if featureGates.Enabled(features.AnnotatedPVCBackups) {
+		if err = (&controller.AnnotatedPVCBackupReconciler{
+			Client:            mgr.GetClient(),
+			Scheme:            mgr.GetScheme(),
+			Recorder:          mgr.GetEventRecorderFor("annotatedpvcbackup-controller"),
+			DefaultSchedule:   annotatedPVCSchedule,
+			DefaultRepository: annotatedPVCRepository,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "AnnotatedPVCBackup")
+			os.Exit(1)
+		}
+	}
+
+	if enableWebhooks {
+		policy, err := webhookv1alpha1.ParseDanglingReferencePolicy(danglingReferencePolicy)
+		if err != nil {
+			setupLog.Error(err, "invalid webhook configuration")
+			os.Exit(1)
+		}
+		validator := &webhookv1alpha1.ReferenceValidator{
+			Reader:                       mgr.GetAPIReader(),
+			DanglingReferencePolicy:      policy,
+			DenyCrossNamespaceReferences: denyCrossNamespaceReferences,
+		}
+		defaulter := &webhookv1alpha1.Defaulter{}
+		quota := &webhookv1alpha1.NamespaceQuota{
+			Reader:                        mgr.GetClient(),
+			MaxBackupsPerNamespace:        maxBackupsPerNamespace,
+			MaxActiveRestoresPerNamespace: maxActiveRestoresPerNamespace,
+		}
+		if err := webhookv1alpha1.SetupResticBackupWebhookWithManager(mgr, validator, defaulter, quota); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ResticBackup")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupResticRestoreWebhookWithManager(mgr, validator, quota); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ResticRestore")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupGlobalRetentionPolicyWebhookWithManager(mgr, validator); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GlobalRetentionPolicy")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupResticCheckWebhookWithManager(mgr, defaulter); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ResticCheck")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupResticReplicationWebhookWithManager(mgr, validator, defaulter); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ResticReplication")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupBackupVerificationWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "BackupVerification")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupResticPruneWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ResticPrune")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupResticRepositoryWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ResticRepository")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupRepositoryTemplateWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "RepositoryTemplate")
+			os.Exit(1)
+		}
+		if err := webhookv1alpha1.SetupClusterBackupPolicyWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterBackupPolicy")
+			os.Exit(1)
+		}
+		setupLog.Info("serving admission webhooks", "danglingReferencePolicy", policy,
+			"maxBackupsPerNamespace", maxBackupsPerNamespace, "maxActiveRestoresPerNamespace", maxActiveRestoresPerNamespace)
+	}
+
+	// Report controllers that can't keep up with their workqueue
+	if err := mgr.Add(&controller.OverloadMonitor{
+		Gatherer:         metrics.Registry,
+		Recorder:         mgr.GetEventRecorderFor("restic-backup-operator"),
+		PodName:          os.Getenv("POD_NAME"),
+		PodNamespace:     os.Getenv("POD_NAMESPACE"),
+		DepthThreshold:   overloadDepthThreshold,
+		LatencyThreshold: overloadLatencyThreshold,
+	}); err != nil {
+		setupLog.Error(err, "unable to set up overload monitor")
+		os.Exit(1)
+	}
+
+	// Publish the build info and enabled capabilities for fleet auditing
+	capabilities := featureGates.States()
+	for name, enabled := range map[string]bool{
+		"LeaderElection":     enableLeaderElection,
+		"SecureMetrics":      secureMetrics,
+		"HTTP2":              enableHTTP2,
+		"RestoreThrottling":  maxConcurrentRestores > 0 || maxConcurrentRestoresPerNamespace > 0,
+		"BackupThrottling":   maxConcurrentBackups > 0,
+		"OverloadMonitor":    overloadDepthThreshold > 0 || overloadLatencyThreshold > 0,
+		"ImageMirror":        imageMirror != "",
+		"ImageDigestPinning": len(digests) > 0,
+		"Webhooks":           enableWebhooks,
+	} {
+		capabilities[name] = enabled
+	}
+	controller.RecordOperatorInfo(capabilities)
+	if err := mgr.Add(&controller.OperatorStatusReporter{
+		Client:       mgr.GetClient(),
+		Gatherer:     metrics.Registry,
+		Namespace:    os.Getenv("POD_NAMESPACE"),
+		Name:         statusConfigMapName,
+		FeatureGates: capabilities,
+	}); err != nil {
+		setupLog.Error(err, "unable to set up operator status reporter")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -163,8 +699,14 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up webhook ready check")
+			os.Exit(1)
+		}
+	}
 
-	setupLog.Info("starting manager")
+	setupLog.Info("starting manager", "version", version.Version, "commit", version.Commit())
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
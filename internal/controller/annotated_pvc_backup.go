@@ -0,0 +1,295 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// backupEnabledAnnotation opts a PVC into a ResticBackup managed by the operator
+	backupEnabledAnnotation = "backup.resticbackup.io/enabled"
+	// backupScheduleAnnotation overrides the schedule of the ResticBackup of a PVC
+	backupScheduleAnnotation = "backup.resticbackup.io/schedule"
+	// backupRepositoryAnnotation overrides the repository of the ResticBackup of a PVC,
+	// as name or namespace/name
+	backupRepositoryAnnotation = "backup.resticbackup.io/repository"
+	// annotatedPVCLabel marks the ResticBackup created for an annotated PVC with the
+	// name of the PVC. Adopted ResticBackups don't get it.
+	annotatedPVCLabel = "backup.resticbackup.io/annotated-pvc"
+
+	// DefaultAnnotatedPVCSchedule is the schedule of annotated PVCs without schedule annotation.
+	DefaultAnnotatedPVCSchedule = "0 2 * * *"
+	// DefaultAnnotatedPVCRepository is the repository of annotated PVCs without repository
+	// annotation, the default repository name of RepositoryTemplates.
+	DefaultAnnotatedPVCRepository = "restic-repository"
+)
+
+// AnnotatedPVCBackupReconciler manages a ResticBackup for every PVC annotated with
+// backup.resticbackup.io/enabled=true. The ResticBackup is owned by the PVC; an existing
+// ResticBackup of the PVC without owner is adopted instead of creating a second one.
+// Adopted ResticBackups are only changed by annotations present on the PVC and are
+// released instead of deleted when the PVC loses the enabled annotation.
+type AnnotatedPVCBackupReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// DefaultSchedule is the schedule of PVCs without schedule annotation. Defaults to
+	// DefaultAnnotatedPVCSchedule.
+	DefaultSchedule string
+	// DefaultRepository is the repository of PVCs without repository annotation. Defaults
+	// to DefaultAnnotatedPVCRepository.
+	DefaultRepository string
+}
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile creates, adopts, updates or deletes the ResticBackup of an annotated PVC.
+func (r *AnnotatedPVCBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, req.NamespacedName, pvc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// The ResticBackup is garbage collected with the PVC, its snapshots are kept
+	if !pvc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(pvc.Namespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list ResticBackups: %w", err)
+	}
+	owned, adoptable := findAnnotatedPVCBackup(backups.Items, pvc)
+
+	if !backupEnabled(pvc) {
+		if owned == nil || !owned.DeletionTimestamp.IsZero() {
+			return ctrl.Result{}, nil
+		}
+		if !createdForPVC(owned, pvc) {
+			return ctrl.Result{}, r.releaseBackup(ctx, pvc, owned)
+		}
+		if err := r.Delete(ctx, owned); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to delete ResticBackup %s: %w", owned.Name, err)
+		}
+		log.Info("Deleted ResticBackup of PVC without backup annotation", "backup", owned.Name)
+		r.Recorder.Event(pvc, corev1.EventTypeNormal, "BackupDeleted",
+			fmt.Sprintf("Deleted ResticBackup %s as the PVC is no longer annotated with %s=true", owned.Name, backupEnabledAnnotation))
+		return ctrl.Result{}, nil
+	}
+
+	repositoryRef, err := r.repositoryRef(pvc)
+	if err != nil {
+		r.Recorder.Event(pvc, corev1.EventTypeWarning, "InvalidBackupAnnotation", err.Error())
+		return ctrl.Result{}, nil
+	}
+	schedule := r.DefaultSchedule
+	if value := pvc.Annotations[backupScheduleAnnotation]; value != "" {
+		schedule = value
+	} else if schedule == "" {
+		schedule = DefaultAnnotatedPVCSchedule
+	}
+
+	switch {
+	case owned != nil:
+		return ctrl.Result{}, r.updateBackup(ctx, pvc, owned, repositoryRef, schedule, false)
+	case adoptable != nil:
+		return ctrl.Result{}, r.updateBackup(ctx, pvc, adoptable, repositoryRef, schedule, true)
+	}
+	return ctrl.Result{}, r.createBackup(ctx, pvc, repositoryRef, schedule)
+}
+
+// createBackup creates the ResticBackup of an annotated PVC.
+func (r *AnnotatedPVCBackupReconciler) createBackup(ctx context.Context, pvc *corev1.PersistentVolumeClaim, repositoryRef backupv1alpha1.CrossNamespaceObjectReference, schedule string) error {
+	backup := &backupv1alpha1.ResticBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      discoveredBackupName(pvc.Name, "backup"),
+			Namespace: pvc.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				annotatedPVCLabel:              pvc.Name,
+			},
+		},
+		Spec: backupv1alpha1.ResticBackupSpec{
+			RepositoryRef: repositoryRef,
+			Schedule:      schedule,
+			Timezone:      "UTC",
+			Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: pvc.Name}},
+		},
+	}
+	if err := controllerutil.SetControllerReference(pvc, backup, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	if err := r.Create(ctx, backup); err != nil {
+		switch {
+		case apierrors.IsAlreadyExists(err):
+			r.Recorder.Event(pvc, corev1.EventTypeWarning, "BackupConflict",
+				fmt.Sprintf("ResticBackup %s already exists and does not back up the PVC", backup.Name))
+			return nil
+		case apierrors.IsInvalid(err) || apierrors.IsForbidden(err):
+			r.Recorder.Event(pvc, corev1.EventTypeWarning, "InvalidBackupAnnotation",
+				fmt.Sprintf("Failed to create ResticBackup %s: %v", backup.Name, err))
+			return nil
+		}
+		return fmt.Errorf("failed to create ResticBackup %s: %w", backup.Name, err)
+	}
+	r.Recorder.Event(pvc, corev1.EventTypeNormal, "BackupCreated",
+		fmt.Sprintf("Created ResticBackup %s with schedule %q", backup.Name, schedule))
+	return nil
+}
+
+// updateBackup applies the annotations of a PVC to its ResticBackup. A ResticBackup
+// created for the PVC gets the default schedule and repository without annotation, an
+// adopted one keeps its own. With adopt, the PVC becomes the controller of the
+// ResticBackup. Other fields of the ResticBackup are kept, so they can be customized.
+func (r *AnnotatedPVCBackupReconciler) updateBackup(ctx context.Context, pvc *corev1.PersistentVolumeClaim, backup *backupv1alpha1.ResticBackup, repositoryRef backupv1alpha1.CrossNamespaceObjectReference, schedule string, adopt bool) error {
+	created := createdForPVC(backup, pvc)
+	changed := adopt
+	if (created || pvc.Annotations[backupScheduleAnnotation] != "") && backup.Spec.Schedule != schedule {
+		backup.Spec.Schedule = schedule
+		changed = true
+	}
+	if (created || pvc.Annotations[backupRepositoryAnnotation] != "") && !equality.Semantic.DeepEqual(backup.Spec.RepositoryRef, repositoryRef) {
+		backup.Spec.RepositoryRef = repositoryRef
+		changed = true
+	}
+	if adopt {
+		if err := controllerutil.SetControllerReference(pvc, backup, r.Scheme); err != nil {
+			return fmt.Errorf("failed to set owner reference: %w", err)
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := r.Update(ctx, backup); err != nil {
+		if apierrors.IsInvalid(err) || apierrors.IsForbidden(err) {
+			r.Recorder.Event(pvc, corev1.EventTypeWarning, "InvalidBackupAnnotation",
+				fmt.Sprintf("Failed to update ResticBackup %s: %v", backup.Name, err))
+			return nil
+		}
+		return fmt.Errorf("failed to update ResticBackup %s: %w", backup.Name, err)
+	}
+	if adopt {
+		r.Recorder.Event(pvc, corev1.EventTypeNormal, "BackupAdopted",
+			fmt.Sprintf("Adopted ResticBackup %s backing up the PVC", backup.Name))
+	}
+	return nil
+}
+
+// releaseBackup removes the owner reference of the PVC from an adopted ResticBackup, so
+// it is neither deleted with the annotation nor garbage collected with the PVC.
+func (r *AnnotatedPVCBackupReconciler) releaseBackup(ctx context.Context, pvc *corev1.PersistentVolumeClaim, backup *backupv1alpha1.ResticBackup) error {
+	if err := controllerutil.RemoveOwnerReference(pvc, backup, r.Scheme); err != nil {
+		return fmt.Errorf("failed to remove owner reference: %w", err)
+	}
+	if err := r.Update(ctx, backup); err != nil {
+		return fmt.Errorf("failed to update ResticBackup %s: %w", backup.Name, err)
+	}
+	r.Recorder.Event(pvc, corev1.EventTypeNormal, "BackupReleased",
+		fmt.Sprintf("Released ResticBackup %s as the PVC is no longer annotated with %s=true", backup.Name, backupEnabledAnnotation))
+	return nil
+}
+
+// createdForPVC reports whether the ResticBackup was created for the PVC rather than
+// adopted.
+func createdForPVC(backup *backupv1alpha1.ResticBackup, pvc *corev1.PersistentVolumeClaim) bool {
+	return backup.Labels[annotatedPVCLabel] == pvc.Name
+}
+
+// repositoryRef returns the repository of the ResticBackup of a PVC from its repository
+// annotation, name or namespace/name.
+func (r *AnnotatedPVCBackupReconciler) repositoryRef(pvc *corev1.PersistentVolumeClaim) (backupv1alpha1.CrossNamespaceObjectReference, error) {
+	value := pvc.Annotations[backupRepositoryAnnotation]
+	if value == "" {
+		value = r.DefaultRepository
+	}
+	if value == "" {
+		value = DefaultAnnotatedPVCRepository
+	}
+	namespace, name, found := strings.Cut(value, "/")
+	if !found {
+		return backupv1alpha1.CrossNamespaceObjectReference{Name: value}, nil
+	}
+	if namespace == "" || name == "" || strings.Contains(name, "/") {
+		return backupv1alpha1.CrossNamespaceObjectReference{},
+			fmt.Errorf("invalid %s annotation %q, expected name or namespace/name", backupRepositoryAnnotation, value)
+	}
+	return backupv1alpha1.CrossNamespaceObjectReference{Name: name, Namespace: namespace}, nil
+}
+
+// findAnnotatedPVCBackup returns the ResticBackup controlled by a PVC or, if there is
+// none, a ResticBackup that can be adopted: one without controller that backs up only the
+// PVC and was not created for a pvcSelector.
+func findAnnotatedPVCBackup(backups []backupv1alpha1.ResticBackup, pvc *corev1.PersistentVolumeClaim) (owned, adoptable *backupv1alpha1.ResticBackup) {
+	for i := range backups {
+		backup := &backups[i]
+		if metav1.IsControlledBy(backup, pvc) {
+			return backup, nil
+		}
+		if adoptable != nil || metav1.GetControllerOf(backup) != nil || backup.Labels[pvcSelectorLabel] != "" {
+			continue
+		}
+		if source := backup.Spec.Source.PVC; source != nil && source.ClaimName == pvc.Name {
+			adoptable = backup
+		}
+	}
+	return nil, adoptable
+}
+
+// backupEnabled reports whether a PVC is annotated for a ResticBackup.
+func backupEnabled(obj client.Object) bool {
+	return obj.GetAnnotations()[backupEnabledAnnotation] == "true"
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AnnotatedPVCBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// PVCs losing the annotation are reconciled to delete their ResticBackup
+	annotated := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return backupEnabled(e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return backupEnabled(e.ObjectOld) || backupEnabled(e.ObjectNew) },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return backupEnabled(e.Object) },
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("annotatedpvcbackup").
+		For(&corev1.PersistentVolumeClaim{}, builder.WithPredicates(annotated)).
+		Owns(&backupv1alpha1.ResticBackup{}).
+		Complete(r)
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("AnnotatedPVCBackup Controller", func() {
+	var (
+		ctx        context.Context
+		testScheme *runtime.Scheme
+		pvc        *corev1.PersistentVolumeClaim
+		objects    []client.Object
+		recorder   *record.FakeRecorder
+	)
+	key := types.NamespacedName{Name: "data", Namespace: "shop"}
+	backupKey := types.NamespacedName{Name: "data-backup", Namespace: "shop"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		pvc = &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Name: key.Name, Namespace: key.Namespace, UID: "pvc-uid",
+			Annotations: map[string]string{backupEnabledAnnotation: "true"},
+		}}
+		objects = []client.Object{pvc}
+	})
+
+	build := func() client.Client {
+		return fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).
+			WithStatusSubresource(&backupv1alpha1.ResticBackup{}).Build()
+	}
+	reconcile := func(c client.Client) {
+		reconciler := &AnnotatedPVCBackupReconciler{Client: c, Scheme: testScheme, Recorder: recorder}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("creates a ResticBackup owned by the annotated PVC with the default schedule and repository", func() {
+		c := build()
+		reconcile(c)
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, backupKey, backup)).To(Succeed())
+		Expect(backup.Spec.Source).To(Equal(backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}}))
+		Expect(backup.Spec.Schedule).To(Equal(DefaultAnnotatedPVCSchedule))
+		Expect(backup.Spec.RepositoryRef).To(Equal(backupv1alpha1.CrossNamespaceObjectReference{Name: DefaultAnnotatedPVCRepository}))
+		Expect(backup.Labels).To(HaveKeyWithValue(annotatedPVCLabel, "data"))
+		Expect(metav1.IsControlledBy(backup, pvc)).To(BeTrue())
+		Expect(recorder.Events).To(Receive(ContainSubstring("BackupCreated")))
+	})
+
+	It("applies the schedule and repository annotations", func() {
+		pvc.Annotations[backupScheduleAnnotation] = "30 * * * *"
+		pvc.Annotations[backupRepositoryAnnotation] = "backup-system/offsite"
+		c := build()
+		reconcile(c)
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, backupKey, backup)).To(Succeed())
+		Expect(backup.Spec.Schedule).To(Equal("30 * * * *"))
+		Expect(backup.Spec.RepositoryRef).To(Equal(backupv1alpha1.CrossNamespaceObjectReference{Name: "offsite", Namespace: "backup-system"}))
+
+		Expect(c.Get(ctx, key, pvc)).To(Succeed())
+		pvc.Annotations[backupScheduleAnnotation] = "0 4 * * *"
+		Expect(c.Update(ctx, pvc)).To(Succeed())
+		backup.Spec.Suspend = true
+		Expect(c.Update(ctx, backup)).To(Succeed())
+		reconcile(c)
+
+		Expect(c.Get(ctx, backupKey, backup)).To(Succeed())
+		Expect(backup.Spec.Schedule).To(Equal("0 4 * * *"))
+		Expect(backup.Spec.Suspend).To(BeTrue())
+	})
+
+	It("rejects an invalid repository annotation", func() {
+		pvc.Annotations[backupRepositoryAnnotation] = "a/b/c"
+		c := build()
+		reconcile(c)
+
+		Expect(c.Get(ctx, backupKey, &backupv1alpha1.ResticBackup{})).NotTo(Succeed())
+		Expect(recorder.Events).To(Receive(ContainSubstring("InvalidBackupAnnotation")))
+	})
+
+	It("adopts a ResticBackup of the PVC without owner", func() {
+		objects = append(objects, &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop-data", Namespace: key.Namespace},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "restic-repository"},
+				Schedule:      "0 * * * *",
+				Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		})
+		c := build()
+		reconcile(c)
+
+		Expect(c.Get(ctx, backupKey, &backupv1alpha1.ResticBackup{})).NotTo(Succeed())
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "shop-data", Namespace: key.Namespace}, backup)).To(Succeed())
+		Expect(metav1.IsControlledBy(backup, pvc)).To(BeTrue())
+		Expect(backup.Labels).NotTo(HaveKey(annotatedPVCLabel))
+		Expect(backup.Spec.Schedule).To(Equal("0 * * * *"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("BackupAdopted")))
+	})
+
+	It("applies only the present annotations to an adopted ResticBackup", func() {
+		pvc.Annotations[backupScheduleAnnotation] = "30 3 * * *"
+		objects = append(objects, &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop-data", Namespace: key.Namespace},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "offsite", Namespace: "backup"},
+				Schedule:      "0 * * * *",
+				Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		})
+		c := build()
+		reconcile(c)
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "shop-data", Namespace: key.Namespace}, backup)).To(Succeed())
+		Expect(backup.Spec.Schedule).To(Equal("30 3 * * *"))
+		Expect(backup.Spec.RepositoryRef).To(Equal(backupv1alpha1.CrossNamespaceObjectReference{Name: "offsite", Namespace: "backup"}))
+	})
+
+	It("releases an adopted ResticBackup when the annotation is removed", func() {
+		objects = append(objects, &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop-data", Namespace: key.Namespace},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "restic-repository"},
+				Schedule:      "0 * * * *",
+				Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		})
+		c := build()
+		reconcile(c)
+
+		Expect(c.Get(ctx, key, pvc)).To(Succeed())
+		delete(pvc.Annotations, backupEnabledAnnotation)
+		Expect(c.Update(ctx, pvc)).To(Succeed())
+		reconcile(c)
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "shop-data", Namespace: key.Namespace}, backup)).To(Succeed())
+		Expect(backup.OwnerReferences).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(ContainSubstring("BackupAdopted")))
+		Expect(recorder.Events).To(Receive(ContainSubstring("BackupReleased")))
+	})
+
+	It("does not adopt a ResticBackup created for a PVC selector", func() {
+		objects = append(objects, &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "databases-data", Namespace: key.Namespace,
+				Labels: map[string]string{pvcSelectorLabel: "databases"},
+			},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "restic-repository"},
+				Schedule:      "0 * * * *",
+				Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		})
+		c := build()
+		reconcile(c)
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "databases-data", Namespace: key.Namespace}, backup)).To(Succeed())
+		Expect(metav1.GetControllerOf(backup)).To(BeNil())
+		Expect(c.Get(ctx, backupKey, &backupv1alpha1.ResticBackup{})).To(Succeed())
+	})
+
+	It("reports a ResticBackup of the same name backing up another PVC", func() {
+		objects = append(objects, &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: backupKey.Name, Namespace: key.Namespace},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "restic-repository"},
+				Schedule:      "0 * * * *",
+				Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "other"}},
+			},
+		})
+		c := build()
+		reconcile(c)
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, backupKey, backup)).To(Succeed())
+		Expect(backup.Spec.Source.PVC.ClaimName).To(Equal("other"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("BackupConflict")))
+	})
+
+	It("deletes the ResticBackup when the annotation is removed", func() {
+		c := build()
+		reconcile(c)
+		Expect(c.Get(ctx, backupKey, &backupv1alpha1.ResticBackup{})).To(Succeed())
+
+		Expect(c.Get(ctx, key, pvc)).To(Succeed())
+		delete(pvc.Annotations, backupEnabledAnnotation)
+		Expect(c.Update(ctx, pvc)).To(Succeed())
+		reconcile(c)
+
+		Expect(c.Get(ctx, backupKey, &backupv1alpha1.ResticBackup{})).NotTo(Succeed())
+	})
+})
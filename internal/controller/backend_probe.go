@@ -0,0 +1,183 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	defaultBackendProbeInterval   = time.Minute
+	defaultBackendProbeTimeout    = 30 * time.Second
+	defaultBackendProbeResolution = 10 * time.Second
+)
+
+// BackendProbe times a restic cat config of the repositories with a latency probe on
+// their interval and exports the backend latency and availability as metrics. Probes
+// of different repositories run in parallel, so a hanging backend doesn't delay the
+// probes of the others.
+type BackendProbe struct {
+	client.Client
+	// Executor is optional - if nil, a default executor will be created. It should not
+	// retry failed commands, so a probe measures a single read of the backend.
+	Executor restic.Executor
+	// Resolution is how often the repositories are checked for due probes. Defaults to 10s.
+	Resolution time.Duration
+
+	mu sync.Mutex
+	// lastProbe holds the start of the last probe per repository
+	lastProbe map[types.NamespacedName]time.Time
+	// running holds the repositories being probed
+	running map[types.NamespacedName]bool
+}
+
+// NewBackendProbe creates a backend probe without probed repositories.
+func NewBackendProbe(c client.Client) *BackendProbe {
+	return &BackendProbe{
+		Client:    c,
+		lastProbe: map[types.NamespacedName]time.Time{},
+		running:   map[types.NamespacedName]bool{},
+	}
+}
+
+// Start probes the repositories until the context is cancelled. It implements
+// manager.Runnable.
+func (p *BackendProbe) Start(ctx context.Context) error {
+	resolution := p.Resolution
+	if resolution <= 0 {
+		resolution = defaultBackendProbeResolution
+	}
+	ticker := time.NewTicker(resolution)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			for _, repository := range p.due(ctx, time.Now()) {
+				wg.Go(func() {
+					p.probe(ctx, repository)
+				})
+			}
+		}
+	}
+}
+
+// NeedLeaderElection ensures that only the leader probes the backends, so the probes
+// are not multiplied by the operator replicas.
+func (p *BackendProbe) NeedLeaderElection() bool {
+	return true
+}
+
+// due returns the repositories whose probe is due and marks them as running. The
+// series of repositories that were deleted or no longer configure a probe are removed.
+func (p *BackendProbe) due(ctx context.Context, now time.Time) []*backupv1alpha1.ResticRepository {
+	repositories := &backupv1alpha1.ResticRepositoryList{}
+	if err := p.List(ctx, repositories); err != nil {
+		log.FromContext(ctx).WithName("backend-probe").Error(err, "Failed to list repositories")
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	probed := map[types.NamespacedName]bool{}
+	var due []*backupv1alpha1.ResticRepository
+	for i := range repositories.Items {
+		repository := &repositories.Items[i]
+		config := repository.Spec.LatencyProbe
+		if config == nil || !repository.DeletionTimestamp.IsZero() {
+			continue
+		}
+		key := client.ObjectKeyFromObject(repository)
+		probed[key] = true
+		if p.running[key] || now.Sub(p.lastProbe[key]) < durationOrDefault(config.Interval, defaultBackendProbeInterval) {
+			continue
+		}
+		p.running[key] = true
+		p.lastProbe[key] = now
+		due = append(due, repository)
+	}
+
+	for key := range p.lastProbe {
+		if !probed[key] && !p.running[key] {
+			delete(p.lastProbe, key)
+			deleteBackendProbeMetrics(key)
+		}
+	}
+	return due
+}
+
+// probe times a restic cat config of the repository and records the result.
+func (p *BackendProbe) probe(ctx context.Context, repository *backupv1alpha1.ResticRepository) {
+	key := client.ObjectKeyFromObject(repository)
+	log := log.FromContext(ctx).WithName("backend-probe").WithValues("repository", key)
+	defer func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.running, key)
+	}()
+
+	creds, err := repositoryCredentials(ctx, p.Client, repository)
+	if err != nil {
+		// Missing credentials are reported by the repository, not the backend
+		log.Error(err, "Failed to get credentials")
+		return
+	}
+
+	executor := p.Executor
+	if executor == nil {
+		executor = restic.NewExecutor(log)
+	}
+
+	timeout := durationOrDefault(repository.Spec.LatencyProbe.Timeout, defaultBackendProbeTimeout)
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err = executor.CatConfig(probeCtx, creds)
+	latency := time.Since(start)
+	if ctx.Err() != nil {
+		// The operator is shutting down
+		return
+	}
+	if err != nil {
+		log.Info("Backend probe failed", "latency", latency, "error", restic.StderrExcerpt(err, 1))
+	}
+	recordBackendProbe(key, repositoryScheme(repository), latency, err)
+}
+
+// durationOrDefault returns the duration, or the default if it is not set or not positive.
+func durationOrDefault(duration *metav1.Duration, defaultDuration time.Duration) time.Duration {
+	if duration != nil && duration.Duration > 0 {
+		return duration.Duration
+	}
+	return defaultDuration
+}
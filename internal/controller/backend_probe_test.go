@@ -0,0 +1,121 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// unavailableExecutor fails to read the repository config.
+type unavailableExecutor struct {
+	MockExecutor
+}
+
+func (e *unavailableExecutor) CatConfig(_ context.Context, _ restic.Credentials) (*restic.RepositoryConfig, error) {
+	return nil, &restic.CommandError{Stderr: "Fatal: unable to open config file: 503 Service Unavailable", Err: errors.New("exit status 1")}
+}
+
+var _ = Describe("Backend probe", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		repository *backupv1alpha1.ResticRepository
+		probe      *BackendProbe
+	)
+	key := types.NamespacedName{Name: "probed", Namespace: "backup-system"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+				LatencyProbe:         &backupv1alpha1.LatencyProbeConfig{Interval: &metav1.Duration{Duration: time.Minute}},
+			},
+		}
+		unprobed := &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "unprobed", Namespace: key.Namespace},
+			Spec:       repository.Spec,
+		}
+		unprobed.Spec.LatencyProbe = nil
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-credentials", Namespace: key.Namespace},
+			Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+		}
+		c = fake.NewClientBuilder().WithScheme(testScheme).WithObjects(repository, unprobed, secret).Build()
+		probe = NewBackendProbe(c)
+		probe.Executor = &MockExecutor{}
+		deleteBackendProbeMetrics(key)
+	})
+
+	It("should probe repositories with a latency probe on their interval", func() {
+		now := time.Now()
+		due := probe.due(ctx, now)
+		Expect(due).To(HaveLen(1))
+		Expect(client.ObjectKeyFromObject(due[0])).To(Equal(key))
+
+		// The probe is still running
+		Expect(probe.due(ctx, now.Add(2*time.Minute))).To(BeEmpty())
+
+		probe.probe(ctx, due[0])
+		Expect(probe.due(ctx, now.Add(30*time.Second))).To(BeEmpty())
+		Expect(probe.due(ctx, now.Add(time.Minute))).To(HaveLen(1))
+	})
+
+	It("should record the latency and availability of the backend", func() {
+		probe.probe(ctx, repository)
+		Expect(testutil.ToFloat64(repositoryBackendUp.WithLabelValues(key.Namespace, key.Name, "s3"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(repositoryBackendProbes.WithLabelValues(key.Namespace, key.Name, "s3", "success"))).To(Equal(1.0))
+		Expect(testutil.CollectAndCount(repositoryBackendLatency)).To(BeNumerically(">=", 1))
+
+		probe.Executor = &unavailableExecutor{}
+		probe.probe(ctx, repository)
+		Expect(testutil.ToFloat64(repositoryBackendUp.WithLabelValues(key.Namespace, key.Name, "s3"))).To(Equal(0.0))
+		Expect(testutil.ToFloat64(repositoryBackendProbes.WithLabelValues(key.Namespace, key.Name, "s3", "failure"))).To(Equal(1.0))
+	})
+
+	It("should remove the series when the probe is disabled", func() {
+		probe.probe(ctx, probe.due(ctx, time.Now())[0])
+		Expect(testutil.CollectAndCount(repositoryBackendUp)).To(BeNumerically(">=", 1))
+
+		repository.Spec.LatencyProbe = nil
+		Expect(c.Update(ctx, repository)).To(Succeed())
+		Expect(probe.due(ctx, time.Now())).To(BeEmpty())
+		Expect(probe.lastProbe).NotTo(HaveKey(key))
+		Expect(testutil.ToFloat64(repositoryBackendProbes.WithLabelValues(key.Namespace, key.Name, "s3", "success"))).To(Equal(0.0))
+	})
+})
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// limitsConcurrentBackups reports whether the backup Jobs of the repository are created
+// suspended and started by the operator to enforce a concurrency limit.
+func (r *ResticBackupReconciler) limitsConcurrentBackups(repository *backupv1alpha1.ResticRepository) bool {
+	return r.MaxConcurrentBackups > 0 || repository.Spec.MaxConcurrentBackups != nil
+}
+
+// backupSlotMessage returns a non-empty message if a backup Job of the repository must
+// wait because the cluster-wide or per-repository limit of running backups is reached.
+// Jobs are read with the API reader, so Jobs started by a previous call are counted.
+func (r *ResticBackupReconciler) backupSlotMessage(ctx context.Context, reader client.Reader, repository *backupv1alpha1.ResticRepository) (string, error) {
+	if !r.limitsConcurrentBackups(repository) {
+		return "", nil
+	}
+
+	jobs := &batchv1.JobList{}
+	if err := reader.List(ctx, jobs, client.HasLabels{resticBackupLabel}); err != nil {
+		return "", fmt.Errorf("failed to list backup jobs: %w", err)
+	}
+
+	// Backup Jobs are labeled with their ResticBackup, which references the repository
+	usesRepository := map[string]bool{}
+	if repository.Spec.MaxConcurrentBackups != nil {
+		backups := &backupv1alpha1.ResticBackupList{}
+		if err := r.List(ctx, backups); err != nil {
+			return "", fmt.Errorf("failed to list backups: %w", err)
+		}
+		for _, backup := range backups.Items {
+			if referencesRepository(backup.Spec.RepositoryRef, backup.Namespace, repository) {
+				usesRepository[backup.Namespace+"/"+backup.Name] = true
+			}
+		}
+	}
+
+	var clusterCount, repositoryCount int
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if !jobActive(job) {
+			continue
+		}
+		clusterCount++
+		if usesRepository[job.Namespace+"/"+job.Labels[resticBackupLabel]] {
+			repositoryCount++
+		}
+	}
+
+	if r.MaxConcurrentBackups > 0 && clusterCount >= r.MaxConcurrentBackups {
+		return fmt.Sprintf("Waiting for a free backup slot (%d/%d backups running cluster-wide)", clusterCount, r.MaxConcurrentBackups), nil
+	}
+	if limit := repository.Spec.MaxConcurrentBackups; limit != nil && repositoryCount >= int(*limit) {
+		return fmt.Sprintf("Waiting for a free backup slot (%d/%d backups of repository %s/%s running)",
+			repositoryCount, *limit, repository.Namespace, repository.Name), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Backup concurrency limits", func() {
+	var (
+		ctx        context.Context
+		repository *backupv1alpha1.ResticRepository
+		backup     *backupv1alpha1.ResticBackup
+	)
+
+	backupJob := func(namespace, backupName, name string, created time.Time, suspended bool) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				CreationTimestamp: metav1.NewTime(created),
+				Labels:            map[string]string{resticBackupLabel: backupName},
+			},
+			Spec: batchv1.JobSpec{Suspend: boolPtr(suspended)},
+		}
+	}
+
+	newReconciler := func(limit int, objects ...client.Object) *ResticBackupReconciler {
+		s := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(s)).To(Succeed())
+		return &ResticBackupReconciler{
+			Client:               fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build(),
+			Scheme:               s,
+			Recorder:             record.NewFakeRecorder(10),
+			MaxConcurrentBackups: limit,
+		}
+	}
+
+	suspended := func(r *ResticBackupReconciler, job *batchv1.Job) bool {
+		current := &batchv1.Job{}
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(job), current)).To(Succeed())
+		return *current.Spec.Suspend
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Schedule:      "0 2 * * *",
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"},
+				Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		}
+	})
+
+	It("should create backup jobs suspended while a limit applies", func() {
+		cronJob, err := newReconciler(0).buildCronJob(backup, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cronJob.Spec.JobTemplate.Spec.Suspend).To(BeNil())
+
+		cronJob, err = newReconciler(5).buildCronJob(backup, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*cronJob.Spec.JobTemplate.Spec.Suspend).To(BeTrue())
+
+		repository.Spec.MaxConcurrentBackups = int32Ptr(2)
+		cronJob, err = newReconciler(0).buildCronJob(backup, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*cronJob.Spec.JobTemplate.Spec.Suspend).To(BeTrue())
+	})
+
+	It("should start waiting jobs oldest first up to the repository limit", func() {
+		repository.Spec.MaxConcurrentBackups = int32Ptr(1)
+		now := time.Now()
+		newer := backupJob("media", "data", "data-2", now, true)
+		older := backupJob("media", "data", "data-1", now.Add(-time.Minute), true)
+		r := newReconciler(0, repository, backup, newer, older)
+
+		message, err := r.startSuspendedJobs(ctx, r.Client, backup, repository, []batchv1.Job{*newer, *older})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(Equal("Waiting for a free backup slot (1/1 backups of repository media/repo running)"))
+		Expect(suspended(r, older)).To(BeFalse())
+		Expect(suspended(r, newer)).To(BeTrue())
+	})
+
+	It("should not count the backups of other repositories against the repository limit", func() {
+		repository.Spec.MaxConcurrentBackups = int32Ptr(1)
+		other := &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "photos", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "other"},
+			},
+		}
+		running := backupJob("media", "photos", "photos-1", time.Now(), false)
+		waiting := backupJob("media", "data", "data-1", time.Now(), true)
+		r := newReconciler(0, repository, backup, other, running, waiting)
+
+		message, err := r.startSuspendedJobs(ctx, r.Client, backup, repository, []batchv1.Job{*waiting})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(BeEmpty())
+		Expect(suspended(r, waiting)).To(BeFalse())
+	})
+
+	It("should keep jobs waiting while the cluster-wide limit is reached", func() {
+		running := backupJob("apps", "db", "db-1", time.Now(), false)
+		waiting := backupJob("media", "data", "data-1", time.Now(), true)
+		r := newReconciler(1, repository, backup, running, waiting)
+
+		Expect(r.updateBackupStatus(ctx, backup, repository)).To(Succeed())
+		Expect(suspended(r, waiting)).To(BeTrue())
+		condition := meta.FindStatusCondition(backup.Status.Conditions, backupv1alpha1.ConditionWaitingForRepository)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("Waiting for a free backup slot (1/1 backups running cluster-wide)"))
+
+		// The running backup finished
+		running.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: "True"}}
+		Expect(r.Status().Update(ctx, running)).To(Succeed())
+
+		Expect(r.updateBackupStatus(ctx, backup, repository)).To(Succeed())
+		Expect(suspended(r, waiting)).To(BeFalse())
+		Expect(meta.IsStatusConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionWaitingForRepository)).To(BeFalse())
+	})
+})
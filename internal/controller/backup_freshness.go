@@ -0,0 +1,134 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+// missedBackupRuns is the number of scheduled runs without a successful backup after
+// which a backup without maxStaleness is missed.
+const missedBackupRuns = 2
+
+// updateBackupFreshness sets the Degraded condition with reason MissedBackup when the
+// last successful backup, or the creation of a backup that never succeeded, is older
+// than maxStaleness or two scheduled runs. This detects CronJobs that silently fail,
+// don't run or stay suspended. A warning event and a notification are sent when a
+// backup is missed.
+func (r *ResticBackupReconciler) updateBackupFreshness(ctx context.Context, backup *backupv1alpha1.ResticBackup, now time.Time) {
+	if backup.Spec.RenderOnly {
+		meta.RemoveStatusCondition(&backup.Status.Conditions, backupv1alpha1.ConditionDegraded)
+		backupMissed.DeleteLabelValues(backup.Namespace, backup.Name)
+		return
+	}
+
+	message := missedBackupMessage(backup, now)
+	if message == "" {
+		backupMissed.WithLabelValues(backup.Namespace, backup.Name).Set(0)
+		if conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionDegraded) {
+			conditions.SetCondition(&backup.Status.Conditions, conditions.NewCondition(backupv1alpha1.ConditionDegraded,
+				metav1.ConditionFalse, "BackupCurrent", "Last successful backup is within the expected interval"))
+		}
+		return
+	}
+
+	backupMissed.WithLabelValues(backup.Namespace, backup.Name).Set(1)
+	if !conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionDegraded) {
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "MissedBackup", message)
+		r.notifyMissedBackup(ctx, backup, message)
+	}
+	conditions.SetCondition(&backup.Status.Conditions, conditions.NewCondition(backupv1alpha1.ConditionDegraded,
+		metav1.ConditionTrue, "MissedBackup", message))
+}
+
+// missedBackupMessage describes why a backup is missed, empty if it is not.
+func missedBackupMessage(backup *backupv1alpha1.ResticBackup, now time.Time) string {
+	last := backup.CreationTimestamp.Time
+	if backup.Status.LastSuccessfulBackup != nil {
+		last = backup.Status.LastSuccessfulBackup.Time
+	}
+
+	var message string
+	if maxStaleness := backup.Spec.MaxStaleness; maxStaleness != nil && maxStaleness.Duration > 0 {
+		if now.Sub(last) <= maxStaleness.Duration {
+			return ""
+		}
+		message = fmt.Sprintf("No successful backup since %s, longer than maxStaleness %s",
+			last.UTC().Format(time.RFC3339), maxStaleness.Duration)
+	} else {
+		if scheduledRunsSince(backup, last, now, missedBackupRuns) < missedBackupRuns {
+			return ""
+		}
+		message = fmt.Sprintf("No successful backup since %s, %d scheduled runs passed",
+			last.UTC().Format(time.RFC3339), missedBackupRuns)
+	}
+	if backup.Spec.Suspend {
+		message += ", the backup is suspended"
+	}
+	return message
+}
+
+// scheduledRunsSince counts the runs of the backup schedule after since up to now, at
+// most limit. Runs outside of the backup window count once, when the window opens.
+// Invalid schedules have no runs.
+func scheduledRunsSince(backup *backupv1alpha1.ResticBackup, since, now time.Time, limit int) int {
+	schedule, err := scheduleParser.Parse(backup.Spec.Schedule)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for next := schedule.Next(since.In(backupLocation(backup))); !next.IsZero() && count < limit; next = schedule.Next(next) {
+		allowed := nextAllowedTime(backup, next)
+		if allowed == nil || allowed.After(now) {
+			break
+		}
+		count++
+		// Runs missed while the window is closed start together once it opens
+		next = allowed.In(next.Location())
+	}
+	return count
+}
+
+// notifyMissedBackup sends a notification about a missed backup to the ntfy and email
+// backends of the backup.
+func (r *ResticBackupReconciler) notifyMissedBackup(ctx context.Context, backup *backupv1alpha1.ResticBackup, message string) {
+	if r.Notifications == nil || backup.Spec.Notifications == nil {
+		return
+	}
+	log := log.FromContext(ctx)
+
+	config, err := r.notificationConfig(ctx, backup)
+	if err != nil {
+		log.Error(err, "Failed to resolve notification config")
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+	}
+	if err := r.Notifications.NotifyBackupMissed(ctx, config, backup.Name, backup.Namespace, message); err != nil {
+		log.Error(err, "Failed to send missed backup notification")
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+	}
+}
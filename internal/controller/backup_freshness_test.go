@@ -0,0 +1,110 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Backup freshness", func() {
+	var (
+		backup   *backupv1alpha1.ResticBackup
+		recorder *record.FakeRecorder
+		r        *ResticBackupReconciler
+	)
+
+	lastSuccess := time.Date(2024, 1, 15, 2, 5, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "app",
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(lastSuccess.Add(-30 * 24 * time.Hour)),
+			},
+			Spec: backupv1alpha1.ResticBackupSpec{Schedule: "0 2 * * *"},
+		}
+		successful := metav1.NewTime(lastSuccess)
+		backup.Status.LastSuccessfulBackup = &successful
+		recorder = record.NewFakeRecorder(10)
+		r = &ResticBackupReconciler{Recorder: recorder}
+	})
+
+	It("should report a missed backup after two scheduled runs without success", func() {
+		r.updateBackupFreshness(context.Background(), backup, lastSuccess.Add(47*time.Hour))
+		Expect(meta.FindStatusCondition(backup.Status.Conditions, backupv1alpha1.ConditionDegraded)).To(BeNil())
+
+		r.updateBackupFreshness(context.Background(), backup, lastSuccess.Add(48*time.Hour))
+		degraded := meta.FindStatusCondition(backup.Status.Conditions, backupv1alpha1.ConditionDegraded)
+		Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+		Expect(degraded.Reason).To(Equal("MissedBackup"))
+		Expect(degraded.Message).To(Equal("No successful backup since 2024-01-15T02:05:00Z, 2 scheduled runs passed"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("MissedBackup")))
+
+		// The event is recorded once
+		r.updateBackupFreshness(context.Background(), backup, lastSuccess.Add(72*time.Hour))
+		Expect(recorder.Events).NotTo(Receive())
+
+		successful := metav1.NewTime(lastSuccess.Add(72 * time.Hour))
+		backup.Status.LastSuccessfulBackup = &successful
+		r.updateBackupFreshness(context.Background(), backup, lastSuccess.Add(73*time.Hour))
+		degraded = meta.FindStatusCondition(backup.Status.Conditions, backupv1alpha1.ConditionDegraded)
+		Expect(degraded.Status).To(Equal(metav1.ConditionFalse))
+		Expect(degraded.Reason).To(Equal("BackupCurrent"))
+	})
+
+	It("should report a missed backup older than maxStaleness", func() {
+		backup.Spec.MaxStaleness = &metav1.Duration{Duration: 6 * time.Hour}
+		backup.Spec.Suspend = true
+		Expect(missedBackupMessage(backup, lastSuccess.Add(6*time.Hour))).To(BeEmpty())
+		Expect(missedBackupMessage(backup, lastSuccess.Add(7*time.Hour))).To(Equal(
+			"No successful backup since 2024-01-15T02:05:00Z, longer than maxStaleness 6h0m0s, the backup is suspended"))
+	})
+
+	It("should report a backup that never succeeded since its creation", func() {
+		backup.Status.LastSuccessfulBackup = nil
+		backup.CreationTimestamp = metav1.NewTime(lastSuccess)
+		Expect(missedBackupMessage(backup, lastSuccess.Add(24*time.Hour))).To(BeEmpty())
+		Expect(missedBackupMessage(backup, lastSuccess.Add(48*time.Hour))).To(ContainSubstring("2 scheduled runs passed"))
+	})
+
+	It("should count the runs missed outside of the backup window once", func() {
+		backup.Spec.Schedule = "0 * * * *"
+		backup.Spec.BackupWindow = &backupv1alpha1.BackupWindow{
+			Ranges: []backupv1alpha1.TimeRange{{Start: "01:00", End: "03:00"}},
+		}
+		Expect(scheduledRunsSince(backup, lastSuccess, lastSuccess.Add(22*time.Hour), 10)).To(BeZero())
+		Expect(scheduledRunsSince(backup, lastSuccess, lastSuccess.Add(23*time.Hour), 10)).To(Equal(1))
+		Expect(scheduledRunsSince(backup, lastSuccess, lastSuccess.Add(24*time.Hour), 10)).To(Equal(2))
+
+		backup.Spec.BackupWindow = nil
+		backup.Spec.Schedule = "@daily"
+		Expect(scheduledRunsSince(backup, lastSuccess, lastSuccess.Add(48*time.Hour), 10)).To(Equal(2))
+
+		backup.Spec.Schedule = "invalid"
+		Expect(scheduledRunsSince(backup, lastSuccess, lastSuccess.Add(48*time.Hour), 10)).To(BeZero())
+	})
+})
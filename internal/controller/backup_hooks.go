@@ -0,0 +1,272 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// Names of the backup hooks as reported in the status.
+const (
+	preBackupHook  = "preBackup"
+	postBackupHook = "postBackup"
+	onFailureHook  = "onFailure"
+)
+
+const (
+	// preBackupHookAnnotation records the pre-backup hook result on a backup Job that
+	// was started by the operator.
+	preBackupHookAnnotation = "backup.resticbackup.io/pre-backup-hook"
+	// completionHooksAnnotation records on a finished backup Job that its postBackup or
+	// onFailure hook ran, and the backup result after the hooks once it is known.
+	completionHooksAnnotation = "backup.resticbackup.io/completion-hooks"
+	// completionHooksRunning marks completion hooks that started without recorded result.
+	// The operator stopped or failed to record the result, so the backup counts as failed.
+	completionHooksRunning = "Running"
+	// defaultHookTimeout is used for exec hooks without timeout.
+	defaultHookTimeout = 60 * time.Second
+	// maxHookOutput limits the hook output in the status message.
+	maxHookOutput = 512
+)
+
+// usesPreBackupHook reports whether backup Jobs must wait for a pre-backup hook. Such
+// Jobs are created suspended and started by the operator once the hook ran.
+func usesPreBackupHook(backup *backupv1alpha1.ResticBackup) bool {
+	return backup.Spec.Hooks != nil && backup.Spec.Hooks.PreBackup != nil
+}
+
+// jobWaitingToStart reports whether a backup Job was created suspended and not started
+// by the operator yet.
+func jobWaitingToStart(job batchv1.Job) bool {
+	return job.Spec.Suspend != nil && *job.Spec.Suspend && job.Status.StartTime == nil && job.Annotations[preBackupHookAnnotation] == ""
+}
+
+// hookFailsBackup reports whether a failing hook fails the backup.
+func hookFailsBackup(hook *backupv1alpha1.Hook) bool {
+	return hook.OnError != "Continue"
+}
+
+// startSuspendedJobs scales down the workload and runs the pre-backup hook for backup
+// Jobs created suspended by the CronJob and starts them afterwards, oldest first. If
+// the workload does not scale down or the hook fails with onError Fail, the Job is
+// deleted and recorded as failed backup. If a concurrency limit is reached or pods of
+// the workload are still terminating, the remaining Jobs stay suspended and a message
+// describing the reason is returned.
+func (r *ResticBackupReconciler) startSuspendedJobs(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup,
+	repository *backupv1alpha1.ResticRepository, jobs []batchv1.Job) (string, error) {
+	waiting := make([]*batchv1.Job, 0, len(jobs))
+	for i := range jobs {
+		if jobWaitingToStart(jobs[i]) {
+			waiting = append(waiting, &jobs[i])
+		}
+	}
+	if len(waiting) == 0 {
+		return "", nil
+	}
+	slices.SortStableFunc(waiting, func(a, b *batchv1.Job) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
+	})
+
+	// Parallel reconciles must not start backups for the same free slot
+	if r.limitsConcurrentBackups(repository) {
+		r.backupSlots.Lock()
+		defer r.backupSlots.Unlock()
+	}
+
+	for _, job := range waiting {
+		message, err := r.backupSlotMessage(ctx, reader, repository)
+		if err != nil || message != "" {
+			return message, err
+		}
+
+		if usesScaleDown(backup) {
+			message, err := r.scaleDownWorkload(ctx, reader, backup, job)
+			if err != nil {
+				if err := r.failScaleDown(ctx, reader, backup, job, err); err != nil {
+					return "", err
+				}
+				continue
+			}
+			if message != "" {
+				return message, nil
+			}
+		}
+
+		result := backupResultSucceeded
+		if usesPreBackupHook(backup) {
+			hook := backup.Spec.Hooks.PreBackup
+			if err := r.runHook(ctx, reader, backup, preBackupHook, hook, job.Name); err != nil {
+				result = backupResultFailed
+				if hookFailsBackup(hook) {
+					if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+						return "", fmt.Errorf("failed to delete backup job %s: %w", job.Name, err)
+					}
+					recordBackupRun(&backup.Status, job, false, time.Now(), nil)
+					r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupFailed",
+						fmt.Sprintf("Backup job %s skipped, pre-backup hook failed", job.Name))
+					continue
+				}
+			}
+		}
+
+		// A prune or retention job may have acquired the repository while the workload
+		// scaled down or the hook ran. It waits for the Lease to settle before looking
+		// for running backups, so checking right before starting leaves no gap.
+		if repository.Spec.CoordinateJobs {
+			holder, err := repositoryLeaseHolder(ctx, reader, repository)
+			if err != nil {
+				return "", err
+			}
+			if holder != "" {
+				return fmt.Sprintf("Repository %s/%s is used by %s", repository.Namespace, repository.Name, holder), nil
+			}
+		}
+
+		patch := client.MergeFrom(job.DeepCopy())
+		job.Spec.Suspend = boolPtr(false)
+		if job.Annotations == nil {
+			job.Annotations = map[string]string{}
+		}
+		job.Annotations[preBackupHookAnnotation] = result
+		if err := r.Patch(ctx, job, patch); err != nil {
+			return "", fmt.Errorf("failed to start backup job %s: %w", job.Name, err)
+		}
+	}
+
+	return "", nil
+}
+
+// runHook runs a backup hook for a backup Job and records the result in the status.
+// A nil hook is skipped. Only exec hooks are supported.
+func (r *ResticBackupReconciler) runHook(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup, name string, hook *backupv1alpha1.Hook, jobName string) error {
+	if hook == nil {
+		return nil
+	}
+
+	hookStatus := backupv1alpha1.HookStatus{
+		Name:   name,
+		Job:    jobName,
+		Result: backupResultSucceeded,
+	}
+
+	pod, err := r.execHook(ctx, reader, backup, hook)
+	hookStatus.LastRun = metav1.Now()
+	hookStatus.Pod = pod
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Hook failed", "hook", name, "job", jobName)
+		hookStatus.Result = backupResultFailed
+		hookStatus.Message = err.Error()
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "HookFailed", fmt.Sprintf("Hook %s failed: %v", name, err))
+	} else {
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "HookSucceeded", fmt.Sprintf("Hook %s succeeded in pod %s", name, pod))
+	}
+
+	setHookStatus(&backup.Status, hookStatus)
+	return err
+}
+
+// execHook executes the command of an exec hook in the first running pod matching the
+// pod selector and returns the name of that pod. The pods are read with the given
+// reader, usually the API reader, to avoid caching all pods.
+func (r *ResticBackupReconciler) execHook(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup, hook *backupv1alpha1.Hook) (string, error) {
+	if hook.Exec == nil {
+		return "", errors.New("only exec hooks are supported")
+	}
+	if r.PodExecutor == nil {
+		return "", errors.New("pod executor not configured")
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&hook.Exec.PodSelector)
+	if err != nil {
+		return "", fmt.Errorf("invalid pod selector: %w", err)
+	}
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods, client.InNamespace(backup.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return "", fmt.Errorf("failed to list pods: %w", err)
+	}
+	pod := selectHookPod(pods.Items)
+	if pod == nil {
+		return "", fmt.Errorf("no running pod matches the pod selector")
+	}
+
+	timeout := defaultHookTimeout
+	if hook.Exec.Timeout != nil {
+		timeout = hook.Exec.Timeout.Duration
+	}
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := r.PodExecutor.Exec(execCtx, backup.Namespace, pod.Name, hook.Exec.Container, hook.Exec.Command)
+	if err != nil {
+		if errors.Is(execCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		if output = truncateHookOutput(output); output != "" {
+			err = fmt.Errorf("%w: %s", err, output)
+		}
+		return pod.Name, err
+	}
+	return pod.Name, nil
+}
+
+// selectHookPod returns the running pod with the lowest name, or nil if no pod runs.
+func selectHookPod(pods []corev1.Pod) *corev1.Pod {
+	var selected *corev1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if selected == nil || pod.Name < selected.Name {
+			selected = pod
+		}
+	}
+	return selected
+}
+
+// truncateHookOutput returns the end of the hook output, which usually contains the error.
+func truncateHookOutput(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxHookOutput {
+		output = "..." + output[len(output)-maxHookOutput:]
+	}
+	return output
+}
+
+// setHookStatus replaces the status of the hook with the same name.
+func setHookStatus(status *backupv1alpha1.ResticBackupStatus, hookStatus backupv1alpha1.HookStatus) {
+	for i := range status.Hooks {
+		if status.Hooks[i].Name == hookStatus.Name {
+			status.Hooks[i] = hookStatus
+			return
+		}
+	}
+	status.Hooks = append(status.Hooks, hookStatus)
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// fakePodExecutor records executed commands and fails the commands in failing.
+type fakePodExecutor struct {
+	failing  []string
+	executed []string
+}
+
+func (e *fakePodExecutor) Exec(_ context.Context, _, pod, _ string, command []string) (string, error) {
+	cmd := strings.Join(command, " ")
+	e.executed = append(e.executed, pod+": "+cmd)
+	if slices.Contains(e.failing, cmd) {
+		return "pg_dump: connection refused", errors.New("command terminated with exit code 1")
+	}
+	return "ok", nil
+}
+
+func hookPod(name string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "media", Labels: map[string]string{"app": "db"}},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func execHookFor(command ...string) *backupv1alpha1.Hook {
+	return &backupv1alpha1.Hook{
+		Exec: &backupv1alpha1.ExecHook{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Command:     command,
+		},
+	}
+}
+
+var _ = Describe("Backup hooks", func() {
+	var (
+		reconciler *ResticBackupReconciler
+		executor   *fakePodExecutor
+		backup     *backupv1alpha1.ResticBackup
+	)
+
+	BeforeEach(func() {
+		executor = &fakePodExecutor{}
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Hooks: &backupv1alpha1.BackupHooks{},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) {
+		reconciler = &ResticBackupReconciler{
+			Client:      fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build(),
+			Recorder:    record.NewFakeRecorder(10),
+			PodExecutor: executor,
+		}
+	}
+
+	Context("runHook", func() {
+		It("should execute the command in the first running pod", func() {
+			newReconciler(hookPod("db-1", corev1.PodRunning), hookPod("db-0", corev1.PodPending), hookPod("db-2", corev1.PodRunning))
+
+			Expect(reconciler.runHook(context.Background(), reconciler.Client, backup, preBackupHook, execHookFor("pg_dump"), "job-1")).To(Succeed())
+			Expect(executor.executed).To(ConsistOf("db-1: pg_dump"))
+			Expect(backup.Status.Hooks).To(ConsistOf(And(
+				HaveField("Name", "preBackup"),
+				HaveField("Job", "job-1"),
+				HaveField("Pod", "db-1"),
+				HaveField("Result", "Succeeded"),
+			)))
+		})
+
+		It("should report failing commands with their output", func() {
+			executor.failing = []string{"pg_dump"}
+			newReconciler(hookPod("db-0", corev1.PodRunning))
+
+			Expect(reconciler.runHook(context.Background(), reconciler.Client, backup, postBackupHook, execHookFor("pg_dump"), "job-1")).NotTo(Succeed())
+			Expect(backup.Status.Hooks).To(HaveLen(1))
+			Expect(backup.Status.Hooks[0].Result).To(Equal("Failed"))
+			Expect(backup.Status.Hooks[0].Message).To(ContainSubstring("connection refused"))
+		})
+
+		It("should read the pods with the given reader", func() {
+			newReconciler()
+			reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(hookPod("db-0", corev1.PodRunning)).Build()
+
+			Expect(reconciler.runHook(context.Background(), reader, backup, preBackupHook, execHookFor("pg_dump"), "job-1")).To(Succeed())
+			Expect(executor.executed).To(ConsistOf("db-0: pg_dump"))
+		})
+
+		It("should fail without running pod", func() {
+			newReconciler(hookPod("db-0", corev1.PodPending))
+
+			Expect(reconciler.runHook(context.Background(), reconciler.Client, backup, preBackupHook, execHookFor("pg_dump"), "job-1")).NotTo(Succeed())
+			Expect(executor.executed).To(BeEmpty())
+		})
+
+		It("should reject job hooks", func() {
+			newReconciler()
+
+			hook := &backupv1alpha1.Hook{Job: &backupv1alpha1.JobHook{}}
+			Expect(reconciler.runHook(context.Background(), reconciler.Client, backup, preBackupHook, hook, "job-1")).To(MatchError(ContainSubstring("only exec hooks")))
+		})
+	})
+
+	Context("startSuspendedJobs", func() {
+		var job *batchv1.Job
+
+		BeforeEach(func() {
+			job = &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "resticbackup-db-1", Namespace: "media"},
+				Spec:       batchv1.JobSpec{Suspend: boolPtr(true)},
+			}
+		})
+
+		It("should start the job after the pre-backup hook", func() {
+			backup.Spec.Hooks.PreBackup = execHookFor("pg_dump")
+			newReconciler(hookPod("db-0", corev1.PodRunning), job)
+
+			Expect(reconciler.startSuspendedJobs(context.Background(), reconciler.Client, backup, &backupv1alpha1.ResticRepository{}, []batchv1.Job{*job})).To(BeEmpty())
+
+			started := &batchv1.Job{}
+			Expect(reconciler.Get(context.Background(), client.ObjectKeyFromObject(job), started)).To(Succeed())
+			Expect(*started.Spec.Suspend).To(BeFalse())
+			Expect(started.Annotations).To(HaveKeyWithValue(preBackupHookAnnotation, "Succeeded"))
+		})
+
+		It("should skip the backup if the pre-backup hook fails", func() {
+			backup.Spec.Hooks.PreBackup = execHookFor("pg_dump")
+			executor.failing = []string{"pg_dump"}
+			newReconciler(hookPod("db-0", corev1.PodRunning), job)
+
+			Expect(reconciler.startSuspendedJobs(context.Background(), reconciler.Client, backup, &backupv1alpha1.ResticRepository{}, []batchv1.Job{*job})).To(BeEmpty())
+
+			err := reconciler.Get(context.Background(), client.ObjectKeyFromObject(job), &batchv1.Job{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			Expect(backup.Status.LastBackup.Result).To(Equal("Failed"))
+			Expect(backup.Status.Statistics.FailedBackups).To(Equal(int32(1)))
+		})
+
+		It("should start the job if the failing hook may continue", func() {
+			backup.Spec.Hooks.PreBackup = execHookFor("pg_dump")
+			executor.failing = []string{"pg_dump"}
+			backup.Spec.Hooks.PreBackup.OnError = "Continue"
+			newReconciler(hookPod("db-0", corev1.PodRunning), job)
+
+			Expect(reconciler.startSuspendedJobs(context.Background(), reconciler.Client, backup, &backupv1alpha1.ResticRepository{}, []batchv1.Job{*job})).To(BeEmpty())
+
+			started := &batchv1.Job{}
+			Expect(reconciler.Get(context.Background(), client.ObjectKeyFromObject(job), started)).To(Succeed())
+			Expect(*started.Spec.Suspend).To(BeFalse())
+			Expect(started.Annotations).To(HaveKeyWithValue(preBackupHookAnnotation, "Failed"))
+		})
+
+		It("should ignore jobs that already started", func() {
+			startTime := metav1.NewTime(time.Now())
+			job.Status.StartTime = &startTime
+			backup.Spec.Hooks.PreBackup = execHookFor("pg_dump")
+			newReconciler(hookPod("db-0", corev1.PodRunning), job)
+
+			Expect(reconciler.startSuspendedJobs(context.Background(), reconciler.Client, backup, &backupv1alpha1.ResticRepository{}, []batchv1.Job{*job})).To(BeEmpty())
+			Expect(executor.executed).To(BeEmpty())
+		})
+	})
+
+	Context("runCompletionHooks", func() {
+		var job *batchv1.Job
+
+		BeforeEach(func() {
+			job = &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "job-1", Namespace: backup.Namespace}}
+			newReconciler(hookPod("db-0", corev1.PodRunning), job)
+		})
+
+		It("should run the post-backup hook after a successful backup", func() {
+			backup.Spec.Hooks.PostBackup = execHookFor("notify")
+			backup.Spec.Hooks.OnFailure = execHookFor("alert")
+
+			Expect(reconciler.runCompletionHooks(context.Background(), reconciler.Client, backup, job, true)).To(BeTrue())
+			Expect(executor.executed).To(ConsistOf("db-0: notify"))
+		})
+
+		It("should fail the backup and run the failure hook if the post-backup hook fails", func() {
+			backup.Spec.Hooks.PostBackup = execHookFor("notify")
+			executor.failing = []string{"notify"}
+			backup.Spec.Hooks.OnFailure = execHookFor("alert")
+
+			Expect(reconciler.runCompletionHooks(context.Background(), reconciler.Client, backup, job, true)).To(BeFalse())
+			Expect(executor.executed).To(Equal([]string{"db-0: notify", "db-0: alert"}))
+		})
+
+		It("should run the hooks of a job once", func() {
+			backup.Spec.Hooks.PostBackup = execHookFor("notify")
+			executor.failing = []string{"notify"}
+			backup.Spec.Hooks.OnFailure = execHookFor("alert")
+			Expect(reconciler.runCompletionHooks(context.Background(), reconciler.Client, backup, job, true)).To(BeFalse())
+
+			// A failed status update processes the job again
+			recorded := &batchv1.Job{}
+			Expect(reconciler.Get(context.Background(), client.ObjectKeyFromObject(job), recorded)).To(Succeed())
+			Expect(recorded.Annotations).To(HaveKeyWithValue(completionHooksAnnotation, backupResultFailed))
+			Expect(reconciler.runCompletionHooks(context.Background(), reconciler.Client, backup, recorded, true)).To(BeFalse())
+			Expect(executor.executed).To(Equal([]string{"db-0: notify", "db-0: alert"}))
+		})
+
+		It("should fail the backup if the hook result was not recorded", func() {
+			backup.Spec.Hooks.PostBackup = execHookFor("notify")
+			job.Annotations = map[string]string{completionHooksAnnotation: completionHooksRunning}
+
+			Expect(reconciler.runCompletionHooks(context.Background(), reconciler.Client, backup, job, true)).To(BeFalse())
+			Expect(executor.executed).To(BeEmpty())
+		})
+
+		It("should run the failure hook after a failed backup", func() {
+			backup.Spec.Hooks.PostBackup = execHookFor("notify")
+			backup.Spec.Hooks.OnFailure = execHookFor("alert")
+
+			Expect(reconciler.runCompletionHooks(context.Background(), reconciler.Client, backup, job, false)).To(BeFalse())
+			Expect(executor.executed).To(ConsistOf("db-0: alert"))
+		})
+	})
+
+	Context("hook helper functions", func() {
+		It("should keep the end of long output", func() {
+			output := truncateHookOutput(strings.Repeat("a", 1000) + "error")
+			Expect(output).To(HavePrefix("..."))
+			Expect(output).To(HaveSuffix("error"))
+			Expect(len(output)).To(Equal(maxHookOutput + 3))
+		})
+
+		It("should replace the status of the same hook", func() {
+			status := &backupv1alpha1.ResticBackupStatus{}
+			setHookStatus(status, backupv1alpha1.HookStatus{Name: preBackupHook, Result: "Failed"})
+			setHookStatus(status, backupv1alpha1.HookStatus{Name: postBackupHook, Result: "Succeeded"})
+			setHookStatus(status, backupv1alpha1.HookStatus{Name: preBackupHook, Result: "Succeeded"})
+			Expect(status.Hooks).To(HaveLen(2))
+			Expect(status.Hooks[0].Result).To(Equal("Succeeded"))
+		})
+	})
+})
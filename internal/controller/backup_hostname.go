@@ -0,0 +1,298 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// normalizationDNS normalizes snapshot hostnames and tags to lowercase DNS-safe names.
+	normalizationDNS = "DNS"
+	// maxHostnameLength is the length of a DNS label, the limit of normalized hostnames.
+	maxHostnameLength = 63
+)
+
+// backupTag returns the snapshot tag identifying the ResticBackup that created a
+// snapshot, independent of the hostname.
+func backupTag(backup *backupv1alpha1.ResticBackup) string {
+	return fmt.Sprintf("backup=%s/%s", backup.Namespace, backup.Name)
+}
+
+// normalizeHostname normalizes a snapshot hostname as configured in the backup. With
+// DNS normalization it is converted to a lowercase DNS label of at most 63 characters.
+// A hostname without any valid character falls back to the normalized backup name.
+func normalizeHostname(backup *backupv1alpha1.ResticBackup, hostname string) string {
+	if !dnsNormalization(backup) {
+		return hostname
+	}
+	if normalized := normalizeName(hostname, "-.", maxHostnameLength); normalized != "" {
+		return normalized
+	}
+	return normalizeName(backup.Name, "-.", maxHostnameLength)
+}
+
+// normalizeTags normalizes the snapshot tags as configured in the backup. With DNS
+// normalization they are converted to lowercase DNS-safe names, keeping the separators
+// of key=value tags. Duplicates and empty tags are removed.
+func normalizeTags(backup *backupv1alpha1.ResticBackup, tags []string) []string {
+	if !dnsNormalization(backup) {
+		return tags
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeName(tag, "-._=/:", 0)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+func dnsNormalization(backup *backupv1alpha1.ResticBackup) bool {
+	return backup.Spec.Restic != nil && backup.Spec.Restic.Normalization == normalizationDNS
+}
+
+// normalizeName lowercases the name and replaces every character other than a-z, 0-9
+// and the allowed separators with a dash. Leading and trailing separators are removed.
+// A maxLength of 0 does not limit the length.
+func normalizeName(name, separators string, maxLength int) string {
+	var sb strings.Builder
+	for _, c := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', strings.ContainsRune(separators, c):
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune('-')
+		}
+	}
+
+	normalized := sb.String()
+	if maxLength > 0 && len(normalized) > maxLength {
+		normalized = normalized[:maxLength]
+	}
+	return strings.Trim(normalized, separators)
+}
+
+// HostnameChecker lists the snapshots of backups in a background worker and sets the
+// HostnameMismatch condition if some of them were taken with a different hostname, so
+// listing the snapshots of a remote repository does not block the reconciles of backups.
+// Retention groups snapshots by hostname, so snapshots of another hostname are never
+// forgotten.
+type HostnameChecker struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Executor is optional - if nil, a default executor will be created
+	Executor restic.Executor
+	// SnapshotCache, if set, is refreshed with the listed snapshots.
+	SnapshotCache *SnapshotCache
+
+	queue chan hostnameCheck
+	mu    sync.Mutex
+	// queued holds the backups waiting for or being checked
+	queued map[types.NamespacedName]bool
+}
+
+// hostnameCheck is a queued check of the snapshots of a backup in a repository.
+type hostnameCheck struct {
+	backup     types.NamespacedName
+	repository types.NamespacedName
+}
+
+// NewHostnameChecker creates a hostname checker with a queue of the given size. Backups
+// enqueued while the queue is full are skipped until their next snapshot.
+func NewHostnameChecker(c client.Client, recorder record.EventRecorder, queueSize int) *HostnameChecker {
+	if queueSize <= 0 {
+		queueSize = defaultStatsQueueSize
+	}
+	return &HostnameChecker{
+		Client:   c,
+		Recorder: recorder,
+		queue:    make(chan hostnameCheck, queueSize),
+		queued:   map[types.NamespacedName]bool{},
+	}
+}
+
+// Enqueue schedules the hostname check of the backup against the snapshots of the
+// repository. It returns false if the backup is already queued or the queue is full.
+func (h *HostnameChecker) Enqueue(backup, repository types.NamespacedName) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.queued[backup] {
+		return false
+	}
+
+	select {
+	case h.queue <- hostnameCheck{backup: backup, repository: repository}:
+		h.queued[backup] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// Start runs the worker until the context is cancelled. It implements manager.Runnable.
+func (h *HostnameChecker) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case check := <-h.queue:
+			h.check(ctx, check)
+		}
+	}
+}
+
+// NeedLeaderElection ensures that only the leader updates the backup status.
+func (h *HostnameChecker) NeedLeaderElection() bool {
+	return true
+}
+
+// check checks the hostnames of the snapshots of a backup and records the result in
+// its status.
+func (h *HostnameChecker) check(ctx context.Context, check hostnameCheck) {
+	log := log.FromContext(ctx).WithName("hostname-checker").WithValues("backup", check.backup)
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.queued, check.backup)
+		h.mu.Unlock()
+	}()
+
+	if err := h.checkSnapshotHostnames(ctx, check); err != nil {
+		log.Error(err, "Failed to check snapshot hostnames")
+	}
+}
+
+func (h *HostnameChecker) checkSnapshotHostnames(ctx context.Context, check hostnameCheck) error {
+	backup := &backupv1alpha1.ResticBackup{}
+	if err := h.Get(ctx, check.backup, backup); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	repository := &backupv1alpha1.ResticRepository{}
+	if err := h.Get(ctx, check.repository, repository); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	hostname, err := renderHostname(backup)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := h.listSnapshots(ctx, repository)
+	if err != nil {
+		return err
+	}
+	others := otherSnapshotHostnames(snapshots, backupTag(backup), hostname)
+
+	// The reconciler updates the status concurrently, retry on conflicts
+	var mismatchReported bool
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := h.Get(ctx, check.backup, backup); err != nil {
+			return err
+		}
+		mismatchReported = conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionHostnameMismatch)
+		conditions.SetCondition(&backup.Status.Conditions, hostnameCondition(hostname, others))
+		return h.Status().Update(ctx, backup)
+	})
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if len(others) > 0 && !mismatchReported {
+		h.Recorder.Event(backup, corev1.EventTypeWarning, "HostnameMismatch", hostnameCondition(hostname, others).Message)
+	}
+	return nil
+}
+
+// hostnameCondition returns the HostnameMismatch condition of a backup using hostname
+// whose snapshots were also taken with the other hostnames.
+func hostnameCondition(hostname string, others []string) metav1.Condition {
+	if len(others) == 0 {
+		return metav1.Condition{
+			Type:    backupv1alpha1.ConditionHostnameMismatch,
+			Status:  metav1.ConditionFalse,
+			Reason:  "HostnameConsistent",
+			Message: fmt.Sprintf("All snapshots of the backup use hostname %s", hostname),
+		}
+	}
+	return metav1.Condition{
+		Type:   backupv1alpha1.ConditionHostnameMismatch,
+		Status: metav1.ConditionTrue,
+		Reason: "SnapshotsWithOtherHostnames",
+		Message: fmt.Sprintf("Snapshots of the backup use hostname %s and %s, retention only applies to %s",
+			hostname, strings.Join(others, ", "), hostname),
+	}
+}
+
+// listSnapshots lists the snapshots of the repository. With a snapshot cache, the
+// listed snapshots are cached for restores.
+func (h *HostnameChecker) listSnapshots(ctx context.Context, repository *backupv1alpha1.ResticRepository) ([]restic.Snapshot, error) {
+	if h.SnapshotCache != nil {
+		return h.SnapshotCache.Refresh(ctx, repository)
+	}
+
+	creds, err := repositoryCredentials(ctx, h.Client, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	executor := h.Executor
+	if executor == nil {
+		executor = restic.NewExecutor(log.FromContext(ctx))
+	}
+
+	snapshots, err := executor.Snapshots(ctx, creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// otherSnapshotHostnames returns the sorted hostnames other than the given one of the
+// snapshots with the tag.
+func otherSnapshotHostnames(snapshots []restic.Snapshot, tag, hostname string) []string {
+	var others []string
+	for _, snapshot := range snapshots {
+		if snapshot.Hostname == hostname || !slices.Contains(snapshot.Tags, tag) {
+			continue
+		}
+		if !slices.Contains(others, snapshot.Hostname) {
+			others = append(others, snapshot.Hostname)
+		}
+	}
+	slices.Sort(others)
+	return others
+}
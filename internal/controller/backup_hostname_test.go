@@ -0,0 +1,171 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// snapshotsExecutor is a MockExecutor listing fixed snapshots.
+type snapshotsExecutor struct {
+	MockExecutor
+	snapshots []restic.Snapshot
+}
+
+func (e *snapshotsExecutor) Snapshots(_ context.Context, _ restic.Credentials) ([]restic.Snapshot, error) {
+	return e.snapshots, nil
+}
+
+var _ = Describe("Snapshot hostnames", func() {
+	var backup *backupv1alpha1.ResticBackup
+
+	BeforeEach(func() {
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Restic: &backupv1alpha1.ResticConfig{
+					Hostname:      "{{ .Namespace }}_{{ .Name }}.Example",
+					Normalization: "DNS",
+				},
+			},
+		}
+	})
+
+	It("should normalize the hostname to a DNS label", func() {
+		hostname, err := renderHostname(backup)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hostname).To(Equal("media-app.example"))
+
+		backup.Spec.Restic.Hostname = "-" + strings.Repeat("a", 80) + "-"
+		hostname, err = renderHostname(backup)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(hostname)).To(BeNumerically("<=", maxHostnameLength))
+	})
+
+	It("should keep the hostname without normalization", func() {
+		backup.Spec.Restic.Normalization = "None"
+		hostname, err := renderHostname(backup)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hostname).To(Equal("media_app.Example"))
+	})
+
+	It("should fall back to the backup name if nothing of the hostname is left", func() {
+		backup.Spec.Restic.Hostname = "___"
+		hostname, err := renderHostname(backup)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hostname).To(Equal("app"))
+
+		backup.Spec.Restic.Hostname = "{{ .PVC }}"
+		_, err = renderHostname(backup)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should queue a backup only once", func() {
+		checker := NewHostnameChecker(nil, nil, 2)
+		backupKey := client.ObjectKeyFromObject(backup)
+		repositoryKey := types.NamespacedName{Namespace: "media", Name: "repo"}
+		Expect(checker.Enqueue(backupKey, repositoryKey)).To(BeTrue())
+		Expect(checker.Enqueue(backupKey, repositoryKey)).To(BeFalse())
+	})
+
+	It("should normalize tags keeping key=value separators", func() {
+		Expect(normalizeTags(backup, []string{"Daily Backup", "daily-backup", "operator-version=v1.2.0", " ", backupTag(backup)})).
+			To(Equal([]string{"daily-backup", "operator-version=v1.2.0", "backup=media/app"}))
+
+		backup.Spec.Restic.Normalization = ""
+		Expect(normalizeTags(backup, []string{"Daily Backup"})).To(Equal([]string{"Daily Backup"}))
+	})
+
+	It("should tag snapshots with the backup", func() {
+		reconciler := &ResticBackupReconciler{}
+		backup.Spec.Source = backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}}
+		repository := &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+
+		cronJob, err := reconciler.buildCronJob(backup, repository)
+		Expect(err).NotTo(HaveOccurred())
+		script := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Args
+		Expect(script).To(ContainElement(And(ContainSubstring("'--tag' 'backup=media/app'"), ContainSubstring("'--host' 'media-app.example'"))))
+	})
+
+	It("should report snapshots of the backup with another hostname", func() {
+		snapshots := []restic.Snapshot{
+			{Hostname: "media-app.example", Tags: []string{"backup=media/app"}},
+			{Hostname: "Media_App.Example", Tags: []string{"backup=media/app"}},
+			{Hostname: "other", Tags: []string{"backup=media/other"}},
+		}
+		Expect(otherSnapshotHostnames(snapshots, "backup=media/app", "media-app.example")).To(Equal([]string{"Media_App.Example"}))
+
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-credentials", Namespace: "media"},
+			Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+		}
+		repository := &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		c := fake.NewClientBuilder().WithScheme(testScheme).
+			WithObjects(secret, repository, backup).
+			WithStatusSubresource(&backupv1alpha1.ResticBackup{}).
+			Build()
+		recorder := record.NewFakeRecorder(10)
+		checker := NewHostnameChecker(c, recorder, 1)
+		checker.Executor = &snapshotsExecutor{snapshots: snapshots}
+
+		check := hostnameCheck{backup: client.ObjectKeyFromObject(backup), repository: client.ObjectKeyFromObject(repository)}
+		Expect(checker.checkSnapshotHostnames(context.Background(), check)).To(Succeed())
+		Expect(c.Get(context.Background(), check.backup, backup)).To(Succeed())
+		Expect(conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionHostnameMismatch)).To(BeTrue())
+		Expect(recorder.Events).To(Receive(ContainSubstring("HostnameMismatch")))
+
+		// The warning event is only emitted once
+		Expect(checker.checkSnapshotHostnames(context.Background(), check)).To(Succeed())
+		Expect(recorder.Events).NotTo(Receive())
+
+		checker.Executor = &snapshotsExecutor{snapshots: snapshots[:1]}
+		Expect(checker.checkSnapshotHostnames(context.Background(), check)).To(Succeed())
+		Expect(c.Get(context.Background(), check.backup, backup)).To(Succeed())
+		Expect(conditions.IsConditionFalse(backup.Status.Conditions, backupv1alpha1.ConditionHostnameMismatch)).To(BeTrue())
+	})
+})
@@ -0,0 +1,190 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/notifications"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// notifiedAnnotation records on a finished backup or restore Job that its result was
+	// sent to the notification backends.
+	notifiedAnnotation = "backup.resticbackup.io/notified"
+	// defaultEmailBatchWindow combines the emails of backups finishing at the same time.
+	defaultEmailBatchWindow = 5 * time.Minute
+	defaultSMTPPort         = 587
+)
+
+// notificationConfig returns the enabled notification backends of the backup.
+func (r *ResticBackupReconciler) notificationConfig(ctx context.Context, backup *backupv1alpha1.ResticBackup) (notifications.Config, error) {
+	return backupNotificationConfig(ctx, r.Client, backup)
+}
+
+// backupNotificationConfig returns the enabled notification backends of the backup. The
+// ntfy and email credentials are read from the referenced secrets.
+func backupNotificationConfig(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup) (notifications.Config, error) {
+	config := notifications.Config{}
+	spec := backup.Spec.Notifications
+	if spec == nil {
+		return config, nil
+	}
+
+	if pushgateway := spec.Pushgateway; pushgateway != nil && pushgateway.Enabled {
+		config.Pushgateway = &notifications.PushgatewayConfig{
+			URL:     pushgateway.URL,
+			JobName: pushgateway.JobName,
+		}
+	}
+
+	// Send to the other backends if the credentials of a backend can't be read,
+	// the server would reject the request anyway
+	var errs []error
+	if ntfy := spec.Ntfy; ntfy != nil && ntfy.Enabled {
+		config.Ntfy = &notifications.NtfyConfig{
+			ServerURL:     ntfy.ServerURL,
+			Topic:         ntfy.Topic,
+			OnlyOnFailure: ntfy.OnlyOnFailure,
+			Priority:      ntfy.Priority,
+			Tags:          ntfy.Tags,
+		}
+		if ref := ntfy.CredentialsSecretRef; ref != nil {
+			secret, err := notificationSecret(ctx, reader, backup, ref.Name, ref.Namespace)
+			if err != nil {
+				config.Ntfy = nil
+				errs = append(errs, fmt.Errorf("failed to get ntfy credentials secret: %w", err))
+			} else {
+				config.Ntfy.Token = string(secret.Data["token"])
+				config.Ntfy.Username = string(secret.Data["username"])
+				config.Ntfy.Password = string(secret.Data["password"])
+			}
+		}
+	}
+
+	if email := spec.Email; email != nil && email.Enabled {
+		config.Email = &notifications.EmailConfig{
+			Host:               email.Host,
+			Port:               email.Port,
+			From:               email.From,
+			To:                 email.To,
+			TLS:                email.TLS,
+			InsecureSkipVerify: email.InsecureSkipVerify,
+			OnlyOnFailure:      email.OnlyOnFailure,
+			BatchWindow:        defaultEmailBatchWindow,
+		}
+		if config.Email.Port == 0 {
+			config.Email.Port = defaultSMTPPort
+		}
+		if email.BatchWindow != nil {
+			config.Email.BatchWindow = email.BatchWindow.Duration
+		}
+		if ref := email.CredentialsSecretRef; ref != nil {
+			secret, err := notificationSecret(ctx, reader, backup, ref.Name, "")
+			if err != nil {
+				config.Email = nil
+				errs = append(errs, fmt.Errorf("failed to get email credentials secret: %w", err))
+			} else {
+				config.Email.Username = string(secret.Data["username"])
+				config.Email.Password = string(secret.Data["password"])
+			}
+		}
+	}
+
+	return config, errors.Join(errs...)
+}
+
+// notificationSecret reads a credentials secret of a notification backend. The secret
+// must be in the namespace of the backup: the credentials are sent to a server chosen
+// by the author of the backup, who must be able to read them anyway.
+func notificationSecret(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup, name, namespace string) (*corev1.Secret, error) {
+	if namespace != "" && namespace != backup.Namespace {
+		return nil, fmt.Errorf("secret %s/%s is not in namespace %s of the backup", namespace, name, backup.Namespace)
+	}
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: name, Namespace: backup.Namespace}, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// notifyBackupRun sends the result of a finished backup job to the configured
+// notification backends. The notification is recorded on the Job before it is sent, so
+// it is sent once even if the status update fails afterwards. Errors sending it are
+// reported as events, they do not affect the backup.
+func (r *ResticBackupReconciler) notifyBackupRun(ctx context.Context, backup *backupv1alpha1.ResticBackup, job *batchv1.Job, result string, finishedAt time.Time, summary *restic.BackupResult) error {
+	if r.Notifications == nil || backup.Spec.Notifications == nil || job.Annotations[notifiedAnnotation] != "" {
+		return nil
+	}
+	if err := annotateJob(ctx, r.Client, job, notifiedAnnotation, result); err != nil {
+		return err
+	}
+	log := log.FromContext(ctx)
+
+	config, err := r.notificationConfig(ctx, backup)
+	if err != nil {
+		log.Error(err, "Failed to resolve notification config")
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+	}
+
+	var duration time.Duration
+	if job.Status.StartTime != nil {
+		duration = finishedAt.Sub(job.Status.StartTime.Time).Round(time.Second)
+	}
+
+	if result == backupResultSucceeded {
+		var snapshotID, size string
+		var files int64
+		if summary != nil {
+			snapshotID = summary.SnapshotID
+			size = formatBytes(summary.TotalBytes)
+			files = summary.TotalFiles
+		}
+		err = r.Notifications.NotifyBackupSuccess(ctx, config, backup.Name, backup.Namespace, snapshotID, size, files, duration)
+	} else {
+		err = r.Notifications.NotifyBackupFailure(ctx, config, backup.Name, backup.Namespace, backupFailureMessage(job, result, summary), duration)
+	}
+	if err != nil {
+		log.Error(err, "Failed to send backup notification", "job", job.Name)
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+	}
+	return nil
+}
+
+// backupFailureMessage describes why a backup job did not succeed.
+func backupFailureMessage(job *batchv1.Job, result string, summary *restic.BackupResult) string {
+	if result == backupResultPartiallyFailed && summary != nil {
+		return fmt.Sprintf("job %s created snapshot %s, but did not complete successfully", job.Name, summary.SnapshotID)
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue && condition.Message != "" {
+			return fmt.Sprintf("job %s failed: %s", job.Name, condition.Message)
+		}
+	}
+	return fmt.Sprintf("job %s failed", job.Name)
+}
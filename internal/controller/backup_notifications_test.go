@@ -0,0 +1,186 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/notifications"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+var _ = Describe("Backup notifications", func() {
+	var (
+		reconciler *ResticBackupReconciler
+		backup     *backupv1alpha1.ResticBackup
+	)
+
+	BeforeEach(func() {
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Notifications: &backupv1alpha1.NotificationConfig{
+					Pushgateway: &backupv1alpha1.PushgatewayConfig{URL: "http://pushgateway:9091"},
+					Ntfy: &backupv1alpha1.NtfyConfig{
+						Enabled:              true,
+						ServerURL:            "https://ntfy.example.com",
+						Topic:                "backups",
+						CredentialsSecretRef: &backupv1alpha1.NtfyCredentialsSecretRef{Name: "ntfy-credentials"},
+					},
+				},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		reconciler = &ResticBackupReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build(),
+			Recorder:      record.NewFakeRecorder(10),
+			Notifications: notifications.NewManager(logr.Discard()),
+		}
+	}
+
+	ntfySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ntfy-credentials", Namespace: "media"},
+		Data:       map[string][]byte{"token": []byte("tk_secret")},
+	}
+
+	It("should include enabled backends only and resolve the ntfy credentials", func() {
+		newReconciler(ntfySecret)
+		config, err := reconciler.notificationConfig(context.Background(), backup)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.Pushgateway).To(BeNil())
+		Expect(config.Ntfy).NotTo(BeNil())
+		Expect(config.Ntfy.Token).To(Equal("tk_secret"))
+	})
+
+	It("should skip ntfy if the credentials secret is missing", func() {
+		backup.Spec.Notifications.Pushgateway.Enabled = true
+		newReconciler()
+		config, err := reconciler.notificationConfig(context.Background(), backup)
+		Expect(err).To(HaveOccurred())
+		Expect(config.Ntfy).To(BeNil())
+		Expect(config.Pushgateway).NotTo(BeNil())
+	})
+
+	It("should not read credentials secrets of other namespaces", func() {
+		backup.Spec.Notifications.Ntfy.CredentialsSecretRef.Namespace = "backup-system"
+		newReconciler(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "ntfy-credentials", Namespace: "backup-system"},
+			Data:       map[string][]byte{"token": []byte("tk_secret")},
+		})
+		config, err := reconciler.notificationConfig(context.Background(), backup)
+		Expect(err).To(MatchError(ContainSubstring("not in namespace media")))
+		Expect(config.Ntfy).To(BeNil())
+	})
+
+	It("should resolve the email config with defaults and credentials", func() {
+		backup.Spec.Notifications.Ntfy.Enabled = false
+		backup.Spec.Notifications.Email = &backupv1alpha1.EmailConfig{
+			Enabled:              true,
+			Host:                 "smtp.example.com",
+			From:                 "backup@example.com",
+			To:                   []string{"admin@example.com"},
+			CredentialsSecretRef: &backupv1alpha1.EmailCredentialsSecretRef{Name: "smtp-credentials"},
+		}
+		newReconciler(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "smtp-credentials", Namespace: "media"},
+			Data:       map[string][]byte{"username": []byte("backup"), "password": []byte("secret")},
+		})
+
+		config, err := reconciler.notificationConfig(context.Background(), backup)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.Ntfy).To(BeNil())
+		Expect(config.Email).NotTo(BeNil())
+		Expect(config.Email.Port).To(Equal(int32(defaultSMTPPort)))
+		Expect(config.Email.BatchWindow).To(Equal(defaultEmailBatchWindow))
+		Expect(config.Email.Username).To(Equal("backup"))
+		Expect(config.Email.Password).To(Equal("secret"))
+
+		backup.Spec.Notifications.Email.BatchWindow = &metav1.Duration{}
+		config, err = reconciler.notificationConfig(context.Background(), backup)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.Email.BatchWindow).To(BeZero())
+	})
+
+	It("should send the backup result to ntfy", func() {
+		var received map[string]any
+		var authorization string
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			authorization = req.Header.Get("Authorization")
+			_ = json.NewDecoder(req.Body).Decode(&received)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		backup.Spec.Notifications.Ntfy.ServerURL = server.URL
+
+		start := metav1.NewTime(time.Now().Add(-5 * time.Minute))
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "resticbackup-app-1", Namespace: "media"},
+			Status:     batchv1.JobStatus{StartTime: &start},
+		}
+		newReconciler(ntfySecret, job)
+		summary := &restic.BackupResult{SnapshotID: "abc123", TotalBytes: 2048, TotalFiles: 12}
+
+		Expect(reconciler.notifyBackupRun(context.Background(), backup, job, backupResultSucceeded, time.Now(), summary)).To(Succeed())
+		Expect(authorization).To(Equal("Bearer tk_secret"))
+		Expect(received["title"]).To(Equal("media/app - Backup Succeeded"))
+		Expect(received["message"]).To(ContainSubstring("abc123"))
+		Expect(received["message"]).To(ContainSubstring("Size: 2.0 KiB"))
+
+		// A failed status update processes the job again
+		recorded := &batchv1.Job{}
+		Expect(reconciler.Get(context.Background(), client.ObjectKeyFromObject(job), recorded)).To(Succeed())
+		Expect(recorded.Annotations).To(HaveKeyWithValue(notifiedAnnotation, backupResultSucceeded))
+		Expect(reconciler.notifyBackupRun(context.Background(), backup, recorded, backupResultSucceeded, time.Now(), summary)).To(Succeed())
+		Expect(requests).To(Equal(1))
+	})
+
+	It("should describe failed jobs", func() {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "resticbackup-app-1"},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type:    batchv1.JobFailed,
+				Status:  corev1.ConditionTrue,
+				Message: "Job has reached the specified backoff limit",
+			}}},
+		}
+		Expect(backupFailureMessage(job, backupResultFailed, nil)).To(Equal("job resticbackup-app-1 failed: Job has reached the specified backoff limit"))
+		Expect(backupFailureMessage(job, backupResultPartiallyFailed, &restic.BackupResult{SnapshotID: "abc123"})).To(ContainSubstring("created snapshot abc123"))
+	})
+})
@@ -0,0 +1,114 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+// maxSkippedRuns bounds the counting of skipped runs of frequent schedules suspended
+// for a long time.
+const maxSkippedRuns = 100000
+
+// updatePauseStatus records since when a suspended backup is paused, how many scheduled
+// runs it skipped and how many backup jobs are still running. In the Drain suspend mode
+// the Paused condition turns True once no backup job is left. Events are recorded when
+// the backup is drained and resumed.
+func (r *ResticBackupReconciler) updatePauseStatus(ctx context.Context, backup *backupv1alpha1.ResticBackup, now time.Time) error {
+	if !backup.Spec.Suspend {
+		if backup.Status.Pause != nil {
+			r.Recorder.Event(backup, corev1.EventTypeNormal, "Resumed",
+				fmt.Sprintf("Scheduled backups are resumed after skipping %d runs", backup.Status.Pause.SkippedRuns))
+		}
+		backup.Status.Pause = nil
+		meta.RemoveStatusCondition(&backup.Status.Conditions, backupv1alpha1.ConditionPaused)
+		return nil
+	}
+
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs,
+		client.InNamespace(backup.Namespace),
+		client.MatchingLabels{resticBackupLabel: backup.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list backup jobs: %w", err)
+	}
+	var running int32
+	for i := range jobs.Items {
+		if finished, _, _ := jobFinished(&jobs.Items[i]); !finished {
+			running++
+		}
+	}
+
+	if backup.Status.Pause == nil {
+		backup.Status.Pause = &backupv1alpha1.PauseStatus{Since: metav1.NewTime(now)}
+	}
+	backup.Status.Pause.SkippedRuns = skippedRuns(backup, backup.Status.Pause.Since.Time, now)
+	backup.Status.Pause.RunningJobs = running
+
+	if backup.Spec.SuspendMode != backupv1alpha1.SuspendModeDrain {
+		meta.RemoveStatusCondition(&backup.Status.Conditions, backupv1alpha1.ConditionPaused)
+		return nil
+	}
+
+	if running > 0 {
+		conditions.SetCondition(&backup.Status.Conditions, metav1.Condition{
+			Type:    backupv1alpha1.ConditionPaused,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Draining",
+			Message: fmt.Sprintf("Waiting for %d running backup jobs to finish", running),
+		})
+		return nil
+	}
+
+	if !conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionPaused) {
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "Drained", "All backup jobs finished, backups are paused")
+	}
+	conditions.SetCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:   backupv1alpha1.ConditionPaused,
+		Status: metav1.ConditionTrue,
+		Reason: "Drained",
+		Message: fmt.Sprintf("Backups are paused since %s, no backup job is running",
+			backup.Status.Pause.Since.UTC().Format(time.RFC3339)),
+	})
+	return nil
+}
+
+// skippedRuns returns the number of runs of the backup schedule after since up to now.
+// Invalid schedules skip no runs.
+func skippedRuns(backup *backupv1alpha1.ResticBackup, since, now time.Time) int32 {
+	schedule, err := scheduleParser.Parse(backup.Spec.Schedule)
+	if err != nil {
+		return 0
+	}
+
+	var count int32
+	for next := schedule.Next(since.In(backupLocation(backup))); !next.After(now) && count < maxSkippedRuns; next = schedule.Next(next) {
+		count++
+	}
+	return count
+}
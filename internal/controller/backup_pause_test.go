@@ -0,0 +1,138 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Backup pause", func() {
+	var (
+		backup     *backupv1alpha1.ResticBackup
+		reconciler *ResticBackupReconciler
+		recorder   *record.FakeRecorder
+	)
+
+	now := time.Date(2024, 1, 15, 12, 30, 0, 0, time.UTC)
+
+	backupJob := func(name string, finished bool) *batchv1.Job {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "media",
+				Labels:    map[string]string{resticBackupLabel: "data"},
+			},
+		}
+		if finished {
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		}
+		return job
+	}
+
+	newReconciler := func(objects ...client.Object) {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		reconciler = &ResticBackupReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build(),
+			Recorder: recorder,
+		}
+	}
+
+	BeforeEach(func() {
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Schedule:    "0 * * * *",
+				Suspend:     true,
+				SuspendMode: backupv1alpha1.SuspendModeDrain,
+			},
+		}
+	})
+
+	It("should wait for running backup jobs before reporting the backup as paused", func() {
+		newReconciler(backupJob("data-1", true), backupJob("data-2", false))
+		Expect(reconciler.updatePauseStatus(context.Background(), backup, now)).To(Succeed())
+
+		Expect(backup.Status.Pause.Since.Time).To(Equal(now))
+		Expect(backup.Status.Pause.RunningJobs).To(Equal(int32(1)))
+		paused := meta.FindStatusCondition(backup.Status.Conditions, backupv1alpha1.ConditionPaused)
+		Expect(paused.Status).To(Equal(metav1.ConditionFalse))
+		Expect(paused.Reason).To(Equal("Draining"))
+
+		newReconciler(backupJob("data-1", true), backupJob("data-2", true))
+		Expect(reconciler.updatePauseStatus(context.Background(), backup, now.Add(3*time.Hour))).To(Succeed())
+
+		Expect(backup.Status.Pause.Since.Time).To(Equal(now))
+		Expect(backup.Status.Pause.SkippedRuns).To(Equal(int32(3)))
+		Expect(backup.Status.Pause.RunningJobs).To(BeZero())
+		Expect(meta.IsStatusConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionPaused)).To(BeTrue())
+		Expect(recorder.Events).To(Receive(ContainSubstring("Drained")))
+	})
+
+	It("should not set the Paused condition in the Schedule mode", func() {
+		backup.Spec.SuspendMode = backupv1alpha1.SuspendModeSchedule
+		newReconciler(backupJob("data-1", false))
+		Expect(reconciler.updatePauseStatus(context.Background(), backup, now)).To(Succeed())
+
+		Expect(backup.Status.Pause.RunningJobs).To(Equal(int32(1)))
+		Expect(meta.FindStatusCondition(backup.Status.Conditions, backupv1alpha1.ConditionPaused)).To(BeNil())
+	})
+
+	It("should clear the pause when the backup is resumed", func() {
+		newReconciler()
+		Expect(reconciler.updatePauseStatus(context.Background(), backup, now)).To(Succeed())
+		Expect(meta.IsStatusConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionPaused)).To(BeTrue())
+
+		backup.Spec.Suspend = false
+		Expect(reconciler.updatePauseStatus(context.Background(), backup, now.Add(time.Hour))).To(Succeed())
+		Expect(backup.Status.Pause).To(BeNil())
+		Expect(meta.FindStatusCondition(backup.Status.Conditions, backupv1alpha1.ConditionPaused)).To(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring("Drained")))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Resumed")))
+	})
+
+	It("should count the scheduled runs in the timezone of the backup", func() {
+		backup.Spec.Schedule = "0 2 * * *"
+		backup.Spec.Timezone = "Europe/Berlin"
+		// 01:00 UTC is 02:00 in Berlin
+		Expect(skippedRuns(backup, now, now.Add(48*time.Hour))).To(Equal(int32(2)))
+		Expect(skippedRuns(backup, now, now.Add(12*time.Hour))).To(Equal(int32(0)))
+		Expect(skippedRuns(backup, now, now.Add(13*time.Hour))).To(Equal(int32(1)))
+
+		backup.Spec.Schedule = "@daily"
+		Expect(skippedRuns(backup, now, now.Add(48*time.Hour))).To(Equal(int32(2)))
+
+		backup.Spec.Schedule = "invalid"
+		Expect(skippedRuns(backup, now, now.Add(48*time.Hour))).To(BeZero())
+	})
+})
@@ -0,0 +1,340 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+const (
+	// pvcSelectorLabel and pvcSelectorNamespaceLabel mark the ResticBackups created for
+	// the PVCs of a pvcSelector source with the name and namespace of the selecting
+	// ResticBackup.
+	pvcSelectorLabel          = "backup.resticbackup.io/pvc-selector"
+	pvcSelectorNamespaceLabel = "backup.resticbackup.io/pvc-selector-namespace"
+
+	// maxDiscoveredBackupNameLength keeps the name of the backup CronJob
+	// ("resticbackup-<name>") within the 52 character limit of CronJob names.
+	maxDiscoveredBackupNameLength = 39
+)
+
+// reconcilePVCSelector creates a ResticBackup for every PVC matching the pvcSelector
+// source of a backup, deletes those of PVCs that no longer match and records their
+// state in status.discoveredPVCs. The selecting backup runs no backup jobs itself.
+func (r *ResticBackupReconciler) reconcilePVCSelector(ctx context.Context, backup *backupv1alpha1.ResticBackup) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	source := backup.Spec.Source.PVCSelector
+	backup.Status.ObservedGeneration = backup.Generation
+
+	pvcSelector, err := metav1.LabelSelectorAsSelector(&source.Selector)
+	if err != nil {
+		msg := fmt.Sprintf("invalid PVC selector: %v", err)
+		r.setCondition(backup, conditions.NotReadyCondition("InvalidPVCSelector", msg))
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "InvalidPVCSelector", msg)
+		return ctrl.Result{}, r.Status().Update(ctx, backup)
+	}
+	pvcs, err := selectPVCs(ctx, r.Client, backup.Namespace, nil, pvcSelector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	discovered := make([]backupv1alpha1.DiscoveredPVC, 0, len(pvcs))
+	keep := map[types.NamespacedName]bool{}
+	failed := 0
+	for _, pvc := range pvcs {
+		entry, err := r.reconcileDiscoveredBackup(ctx, backup, pvc)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		keep[types.NamespacedName{Name: entry.Backup, Namespace: entry.Namespace}] = true
+		if entry.Message != "" {
+			failed++
+		}
+		discovered = append(discovered, entry)
+	}
+	if err := r.deleteDiscoveredBackups(ctx, backup, keep); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteSelectorCronJob(ctx, backup); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	backup.Status.DiscoveredPVCs = discovered
+	backup.Status.CronJobRef = nil
+	backup.Status.NextBackup = r.calculateNextBackup(backup)
+	message := fmt.Sprintf("Backing up %d PVCs", len(discovered)-failed)
+	if failed > 0 {
+		r.setCondition(backup, conditions.NotReadyCondition("PVCBackupsFailed", fmt.Sprintf("%s, %d not backed up", message, failed)))
+	} else {
+		r.setCondition(backup, conditions.ReadyCondition("PVCsDiscovered", message))
+	}
+	log.Info("Reconciled PVC selector", "pvcs", len(discovered), "failed", failed)
+
+	if err := r.Status().Update(ctx, backup); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+// selectPVCs returns the PVCs matching the selectors, sorted by namespace and name. PVCs
+// and namespaces being deleted are skipped. Without namespace selector, the PVCs of the
+// given namespace are selected.
+func selectPVCs(ctx context.Context, reader client.Reader, namespace string, namespaceSelector, pvcSelector labels.Selector) ([]corev1.PersistentVolumeClaim, error) {
+	listOptions := []client.ListOption{client.MatchingLabelsSelector{Selector: pvcSelector}}
+	var namespaces map[string]bool
+	if namespaceSelector != nil {
+		namespaceList := &corev1.NamespaceList{}
+		if err := reader.List(ctx, namespaceList, client.MatchingLabelsSelector{Selector: namespaceSelector}); err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		namespaces = map[string]bool{}
+		for _, ns := range namespaceList.Items {
+			if ns.DeletionTimestamp.IsZero() {
+				namespaces[ns.Name] = true
+			}
+		}
+	} else {
+		listOptions = append(listOptions, client.InNamespace(namespace))
+	}
+
+	// One list of the matching PVCs of all namespaces instead of one per namespace
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	if err := reader.List(ctx, pvcList, listOptions...); err != nil {
+		return nil, fmt.Errorf("failed to list PVCs: %w", err)
+	}
+	var pvcs []corev1.PersistentVolumeClaim
+	for _, pvc := range pvcList.Items {
+		if pvc.DeletionTimestamp.IsZero() && (namespaces == nil || namespaces[pvc.Namespace]) {
+			pvcs = append(pvcs, pvc)
+		}
+	}
+	sort.Slice(pvcs, func(i, j int) bool {
+		if pvcs[i].Namespace != pvcs[j].Namespace {
+			return pvcs[i].Namespace < pvcs[j].Namespace
+		}
+		return pvcs[i].Name < pvcs[j].Name
+	})
+	return pvcs, nil
+}
+
+// reconcileDiscoveredBackup creates or updates the ResticBackup of a selected PVC and
+// returns the state of its backup. The selecting backup owns it, so it is garbage
+// collected with it.
+func (r *ResticBackupReconciler) reconcileDiscoveredBackup(ctx context.Context, backup *backupv1alpha1.ResticBackup, pvc corev1.PersistentVolumeClaim) (backupv1alpha1.DiscoveredPVC, error) {
+	desired := buildDiscoveredBackup(backup, pvc.Name)
+	entry := backupv1alpha1.DiscoveredPVC{Namespace: pvc.Namespace, ClaimName: pvc.Name, Backup: desired.Name}
+	if err := controllerutil.SetControllerReference(backup, desired, r.Scheme); err != nil {
+		return entry, fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	current := &backupv1alpha1.ResticBackup{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(desired), current)
+	switch {
+	case apierrors.IsNotFound(err):
+		if err := r.Create(ctx, desired); err != nil {
+			if apierrors.IsInvalid(err) || apierrors.IsForbidden(err) {
+				entry.Message = fmt.Sprintf("Failed to create ResticBackup %s: %v", desired.Name, err)
+				return entry, nil
+			}
+			return entry, fmt.Errorf("failed to create ResticBackup %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "PVCBackupCreated",
+			fmt.Sprintf("Created ResticBackup %s/%s for PVC %s", desired.Namespace, desired.Name, pvc.Name))
+		return entry, nil
+	case err != nil:
+		return entry, fmt.Errorf("failed to get ResticBackup %s/%s: %w", desired.Namespace, desired.Name, err)
+	case !selectedBy(current, backup):
+		entry.Message = fmt.Sprintf("ResticBackup %s already exists and is not managed by this backup", desired.Name)
+		return entry, nil
+	}
+
+	// Labels set by others are kept
+	changed := !equality.Semantic.DeepEqual(current.Spec, desired.Spec)
+	current.Spec = desired.Spec
+	if !metav1.IsControlledBy(current, backup) {
+		if err := controllerutil.SetControllerReference(backup, current, r.Scheme); err != nil {
+			entry.Message = fmt.Sprintf("Failed to take ownership of ResticBackup %s: %v", current.Name, err)
+			return entry, nil
+		}
+		changed = true
+	}
+	if current.Labels == nil {
+		current.Labels = map[string]string{}
+	}
+	for key, value := range desired.Labels {
+		changed = changed || current.Labels[key] != value
+		current.Labels[key] = value
+	}
+	if changed {
+		if err := r.Update(ctx, current); err != nil {
+			return entry, fmt.Errorf("failed to update ResticBackup %s/%s: %w", current.Namespace, current.Name, err)
+		}
+	}
+
+	recordDiscoveredBackup(&entry, current)
+	return entry, nil
+}
+
+// recordDiscoveredBackup records the last backup of the ResticBackup created for a PVC
+// and, while it isn't ready, the reason.
+func recordDiscoveredBackup(entry *backupv1alpha1.DiscoveredPVC, backup *backupv1alpha1.ResticBackup) {
+	entry.LastBackup = backup.Status.LastBackup.DeepCopy()
+	if condition := conditions.GetCondition(backup.Status.Conditions, backupv1alpha1.ConditionReady); condition != nil && condition.Status == metav1.ConditionFalse {
+		entry.Message = condition.Message
+	}
+}
+
+// deleteDiscoveredBackups deletes the ResticBackups created for PVCs that are no longer
+// selected.
+func (r *ResticBackupReconciler) deleteDiscoveredBackups(ctx context.Context, backup *backupv1alpha1.ResticBackup, keep map[types.NamespacedName]bool) error {
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(backup.Namespace), client.MatchingLabels{
+		pvcSelectorLabel:          backup.Name,
+		pvcSelectorNamespaceLabel: backup.Namespace,
+	}); err != nil {
+		return fmt.Errorf("failed to list ResticBackups of PVC selector: %w", err)
+	}
+	for i := range backups.Items {
+		discovered := &backups.Items[i]
+		if keep[client.ObjectKeyFromObject(discovered)] || !discovered.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.Delete(ctx, discovered); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete ResticBackup %s/%s: %w", discovered.Namespace, discovered.Name, err)
+		}
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "PVCBackupDeleted",
+			fmt.Sprintf("Deleted ResticBackup %s/%s of a PVC that no longer matches", discovered.Namespace, discovered.Name))
+	}
+	return nil
+}
+
+// deleteSelectorCronJob deletes the CronJob created before the backup switched to a
+// pvcSelector source.
+func (r *ResticBackupReconciler) deleteSelectorCronJob(ctx context.Context, backup *backupv1alpha1.ResticBackup) error {
+	cronJob := &batchv1.CronJob{}
+	err := r.Get(ctx, types.NamespacedName{Name: fmt.Sprintf("resticbackup-%s", backup.Name), Namespace: backup.Namespace}, cronJob)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to get CronJob: %w", err)
+	}
+	if err != nil || !metav1.IsControlledBy(cronJob, backup) {
+		return nil
+	}
+	if err := r.Delete(ctx, cronJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete CronJob: %w", err)
+	}
+	r.Recorder.Event(backup, corev1.EventTypeNormal, "CronJobDeleted", fmt.Sprintf("Deleted CronJob %s, the PVCs are backed up by their own ResticBackups", cronJob.Name))
+	return nil
+}
+
+// buildDiscoveredBackup builds the ResticBackup of a selected PVC. It takes the spec and
+// labels of the selecting backup, with a pvc source.
+func buildDiscoveredBackup(backup *backupv1alpha1.ResticBackup, claimName string) *backupv1alpha1.ResticBackup {
+	spec := backup.Spec.DeepCopy()
+	spec.Source = backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{
+		ClaimName: claimName,
+		Excludes:  slices.Clone(backup.Spec.Source.PVCSelector.Excludes),
+	}}
+
+	labels := maps.Clone(backup.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	maps.Copy(labels, map[string]string{
+		"app.kubernetes.io/managed-by": "restic-backup-operator",
+		pvcSelectorLabel:               backup.Name,
+		pvcSelectorNamespaceLabel:      backup.Namespace,
+	})
+
+	return &backupv1alpha1.ResticBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      discoveredBackupName(backup.Name, claimName),
+			Namespace: backup.Namespace,
+			Labels:    labels,
+		},
+		Spec: *spec,
+	}
+}
+
+// discoveredBackupName returns the name of the ResticBackup created for a PVC. Long names
+// are shortened and suffixed with a hash to stay unique.
+func discoveredBackupName(backupName, claimName string) string {
+	name := backupName + "-" + claimName
+	if len(name) <= maxDiscoveredBackupNameLength {
+		return name
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name))
+	suffix := fmt.Sprintf("-%08x", hash.Sum32())
+	return name[:maxDiscoveredBackupNameLength-len(suffix)] + suffix
+}
+
+// selectedBy reports whether a ResticBackup was created for the pvcSelector of backup.
+func selectedBy(obj client.Object, backup *backupv1alpha1.ResticBackup) bool {
+	labels := obj.GetLabels()
+	return labels[pvcSelectorLabel] == backup.Name && labels[pvcSelectorNamespaceLabel] == backup.Namespace
+}
+
+// backupForDiscoveredBackup maps a ResticBackup created for a PVC to the selecting backup.
+func backupForDiscoveredBackup(_ context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if labels[pvcSelectorLabel] == "" || labels[pvcSelectorNamespaceLabel] == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Name:      labels[pvcSelectorLabel],
+		Namespace: labels[pvcSelectorNamespaceLabel],
+	}}}
+}
+
+// backupsForPVCSelector enqueues the ResticBackups with a pvcSelector source in the
+// namespace of a changed PVC, as any of them may select it.
+func (r *ResticBackupReconciler) backupsForPVCSelector(ctx context.Context, obj client.Object) []reconcile.Request {
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list ResticBackups")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, backup := range backups.Items {
+		if backup.Spec.Source.PVCSelector != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&backup)})
+		}
+	}
+	return requests
+}
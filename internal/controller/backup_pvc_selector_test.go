@@ -0,0 +1,186 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("PVC selector", func() {
+	var (
+		ctx     context.Context
+		backup  *backupv1alpha1.ResticBackup
+		objects []client.Object
+	)
+
+	pvc := func(namespace, name string, labels map[string]string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "databases", Namespace: "shop", Labels: map[string]string{"tier": "data"}},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"},
+				Schedule:      "0 2 * * *",
+				Source: backupv1alpha1.BackupSource{PVCSelector: &backupv1alpha1.PVCSelectorSource{
+					Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "postgres"}},
+					Excludes: []string{"*.tmp"},
+				}},
+			},
+		}
+		objects = []client.Object{
+			pvc("shop", "postgres-data", map[string]string{"app": "postgres"}),
+			pvc("shop", "postgres-wal", map[string]string{"app": "postgres"}),
+			pvc("shop", "cache", map[string]string{"app": "redis"}),
+			pvc("blog", "postgres-data", map[string]string{"app": "postgres"}),
+		}
+	})
+
+	newReconciler := func() *ResticBackupReconciler {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		return &ResticBackupReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(append(objects, backup)...).
+				WithStatusSubresource(&backupv1alpha1.ResticBackup{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(20),
+		}
+	}
+
+	getBackup := func(r *ResticBackupReconciler, namespace, name string) *backupv1alpha1.ResticBackup {
+		discovered := &backupv1alpha1.ResticBackup{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, discovered)).To(Succeed())
+		return discovered
+	}
+
+	It("should create a ResticBackup for every matching PVC of its namespace", func() {
+		r := newReconciler()
+		_, err := r.reconcilePVCSelector(ctx, backup)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(backup.Status.DiscoveredPVCs).To(Equal([]backupv1alpha1.DiscoveredPVC{
+			{Namespace: "shop", ClaimName: "postgres-data", Backup: "databases-postgres-data"},
+			{Namespace: "shop", ClaimName: "postgres-wal", Backup: "databases-postgres-wal"},
+		}))
+		Expect(apimeta.IsStatusConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+
+		discovered := getBackup(r, "shop", "databases-postgres-data")
+		Expect(discovered.Spec.Source).To(Equal(backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{
+			ClaimName: "postgres-data",
+			Excludes:  []string{"*.tmp"},
+		}}))
+		Expect(discovered.Spec.RepositoryRef).To(Equal(backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"}))
+		Expect(discovered.Spec.Schedule).To(Equal("0 2 * * *"))
+		Expect(discovered.Labels).To(HaveKeyWithValue("tier", "data"))
+		Expect(selectedBy(discovered, backup)).To(BeTrue())
+		Expect(metav1.IsControlledBy(discovered, backup)).To(BeTrue())
+
+		backups := &backupv1alpha1.ResticBackupList{}
+		Expect(r.List(ctx, backups, client.InNamespace("blog"))).To(Succeed())
+		Expect(backups.Items).To(BeEmpty())
+	})
+
+	It("should take ownership of a ResticBackup created without owner reference", func() {
+		objects = append(objects, buildDiscoveredBackup(backup, "postgres-data"))
+		r := newReconciler()
+		_, err := r.reconcilePVCSelector(ctx, backup)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(metav1.IsControlledBy(getBackup(r, "shop", "databases-postgres-data"), backup)).To(BeTrue())
+	})
+
+	It("should delete the ResticBackup of a PVC that no longer matches", func() {
+		r := newReconciler()
+		_, err := r.reconcilePVCSelector(ctx, backup)
+		Expect(err).NotTo(HaveOccurred())
+
+		claim := &corev1.PersistentVolumeClaim{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: "postgres-wal", Namespace: "shop"}, claim)).To(Succeed())
+		claim.Labels = nil
+		Expect(r.Update(ctx, claim)).To(Succeed())
+		_, err = r.reconcilePVCSelector(ctx, backup)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(backup.Status.DiscoveredPVCs).To(HaveLen(1))
+		err = r.Get(ctx, client.ObjectKey{Name: "databases-postgres-wal", Namespace: "shop"}, &backupv1alpha1.ResticBackup{})
+		Expect(client.IgnoreNotFound(err)).To(Succeed())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should update the ResticBackups when the spec changes", func() {
+		r := newReconciler()
+		_, err := r.reconcilePVCSelector(ctx, backup)
+		Expect(err).NotTo(HaveOccurred())
+
+		backup.Spec.Schedule = "0 4 * * *"
+		_, err = r.reconcilePVCSelector(ctx, backup)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getBackup(r, "shop", "databases-postgres-data").Spec.Schedule).To(Equal("0 4 * * *"))
+	})
+
+	It("should record the last backup of each PVC", func() {
+		r := newReconciler()
+		_, err := r.reconcilePVCSelector(ctx, backup)
+		Expect(err).NotTo(HaveOccurred())
+
+		discovered := getBackup(r, "shop", "databases-postgres-data")
+		discovered.Status.LastBackup = &backupv1alpha1.BackupRunStatus{SnapshotID: "abc123", Result: backupResultSucceeded}
+		Expect(r.Status().Update(ctx, discovered)).To(Succeed())
+		_, err = r.reconcilePVCSelector(ctx, backup)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(backup.Status.DiscoveredPVCs[0].LastBackup).To(Equal(discovered.Status.LastBackup))
+	})
+
+	It("should not take over a ResticBackup it didn't create", func() {
+		objects = append(objects, &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "databases-postgres-data", Namespace: "shop"},
+		})
+		r := newReconciler()
+		_, err := r.reconcilePVCSelector(ctx, backup)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(backup.Status.DiscoveredPVCs[0].Message).To(ContainSubstring("not managed by this backup"))
+		Expect(apimeta.IsStatusConditionFalse(backup.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+		Expect(getBackup(r, "shop", "databases-postgres-data").Spec.Source.PVC).To(BeNil())
+	})
+
+	It("should shorten long names", func() {
+		name := discoveredBackupName("nightly-database-backups", "data-postgres-cluster-0")
+		Expect(len(name)).To(Equal(maxDiscoveredBackupNameLength))
+		Expect(name).NotTo(Equal(discoveredBackupName("nightly-database-backups", "data-postgres-cluster-1")))
+		Expect(discoveredBackupName("db", "data")).To(Equal("db-data"))
+	})
+})
@@ -0,0 +1,154 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// renderedCronJobKey is the ConfigMap key holding the rendered CronJob.
+const renderedCronJobKey = "cronjob.yaml"
+
+func renderedManifestName(backup *backupv1alpha1.ResticBackup) string {
+	return fmt.Sprintf("resticbackup-%s-rendered", backup.Name)
+}
+
+// renderCronJob stores the CronJob of a renderOnly backup in a ConfigMap instead of
+// creating it. A CronJob created before renderOnly was enabled is deleted.
+func (r *ResticBackupReconciler) renderCronJob(ctx context.Context, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) error {
+	log := log.FromContext(ctx)
+
+	cronJob, err := r.buildCronJob(backup, repository)
+	if err != nil {
+		return err
+	}
+	if err := controllerutil.SetControllerReference(backup, cronJob, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	manifest, err := renderCronJobManifest(cronJob)
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      renderedManifestName(backup),
+			Namespace: backup.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "backup",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				resticBackupLabel:              backup.Name,
+			},
+		},
+		Data: map[string]string{renderedCronJobKey: manifest},
+	}
+	if err := controllerutil.SetControllerReference(backup, configMap, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	// ConfigMaps are not cached, so read them from the API server
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+
+	existing := &corev1.ConfigMap{}
+	err = reader.Get(ctx, client.ObjectKeyFromObject(configMap), existing)
+	switch {
+	case apierrors.IsNotFound(err):
+		log.Info("Rendering CronJob", "configMap", configMap.Name)
+		if err := r.Create(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to create ConfigMap: %w", err)
+		}
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "CronJobRendered", fmt.Sprintf("Rendered CronJob %s into ConfigMap %s", cronJob.Name, configMap.Name))
+	case err != nil:
+		return fmt.Errorf("failed to get ConfigMap: %w", err)
+	case existing.Data[renderedCronJobKey] != manifest:
+		existing.Data = configMap.Data
+		if err := r.Update(ctx, existing); err != nil {
+			return fmt.Errorf("failed to update ConfigMap: %w", err)
+		}
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "CronJobRendered", fmt.Sprintf("Rendered CronJob %s into ConfigMap %s", cronJob.Name, configMap.Name))
+	}
+
+	// Make sure nothing runs while the backup is render-only
+	existingCronJob := &batchv1.CronJob{}
+	err = r.Get(ctx, types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, existingCronJob)
+	if err == nil && metav1.IsControlledBy(existingCronJob, backup) {
+		log.Info("Deleting CronJob of render-only backup", "name", existingCronJob.Name)
+		if err := r.Delete(ctx, existingCronJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete CronJob: %w", err)
+		}
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "CronJobDeleted", fmt.Sprintf("Deleted CronJob %s of render-only backup", existingCronJob.Name))
+	} else if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to get CronJob: %w", err)
+	}
+
+	backup.Status.CronJobRef = nil
+	backup.Status.NextBackup = nil
+	backup.Status.RenderedManifestRef = &backupv1alpha1.ObjectReference{
+		Name:      configMap.Name,
+		Namespace: configMap.Namespace,
+	}
+	return nil
+}
+
+// renderCronJobManifest returns the CronJob as it would be applied, in YAML.
+func renderCronJobManifest(cronJob *batchv1.CronJob) (string, error) {
+	rendered := cronJob.DeepCopy()
+	rendered.TypeMeta = metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: "CronJob"}
+
+	manifest, err := yaml.Marshal(rendered)
+	if err != nil {
+		return "", fmt.Errorf("failed to render CronJob: %w", err)
+	}
+	return string(manifest), nil
+}
+
+// deleteRenderedManifest removes the ConfigMap of a backup that is no longer render-only.
+func (r *ResticBackupReconciler) deleteRenderedManifest(ctx context.Context, backup *backupv1alpha1.ResticBackup) error {
+	if backup.Status.RenderedManifestRef == nil {
+		return nil
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backup.Status.RenderedManifestRef.Name,
+			Namespace: backup.Namespace,
+		},
+	}
+	if err := r.Delete(ctx, configMap); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete ConfigMap: %w", err)
+	}
+	backup.Status.RenderedManifestRef = nil
+	return nil
+}
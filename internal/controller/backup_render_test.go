@@ -0,0 +1,120 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Render-only backups", func() {
+	var (
+		reconciler *ResticBackupReconciler
+		backup     *backupv1alpha1.ResticBackup
+		repository *backupv1alpha1.ResticRepository
+	)
+
+	BeforeEach(func() {
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media", UID: "backup-uid"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"},
+				Schedule:      "0 2 * * *",
+				Source: backupv1alpha1.BackupSource{
+					PVC: &backupv1alpha1.PVCSource{ClaimName: "data"},
+				},
+				RenderOnly: true,
+			},
+		}
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "local:/tmp/test-repo",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "test-credentials"},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		reconciler = &ResticBackupReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+		}
+	}
+
+	It("should render the CronJob into a ConfigMap without creating it", func() {
+		newReconciler()
+		Expect(reconciler.renderCronJob(context.Background(), backup, repository)).To(Succeed())
+		Expect(backup.Status.RenderedManifestRef).NotTo(BeNil())
+		Expect(backup.Status.RenderedManifestRef.Name).To(Equal("resticbackup-app-rendered"))
+
+		configMap := &corev1.ConfigMap{}
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "resticbackup-app-rendered", Namespace: "media"}, configMap)).To(Succeed())
+		Expect(configMap.Data[renderedCronJobKey]).To(ContainSubstring("kind: CronJob"))
+		Expect(configMap.Data[renderedCronJobKey]).To(ContainSubstring("schedule: 0 2 * * *"))
+
+		err := reconciler.Get(context.Background(), types.NamespacedName{Name: "resticbackup-app", Namespace: "media"}, &batchv1.CronJob{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should delete the CronJob created before renderOnly was enabled", func() {
+		cronJob := &batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "resticbackup-app", Namespace: "media"},
+		}
+		testScheme := runtime.NewScheme()
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		Expect(controllerutil.SetControllerReference(backup, cronJob, testScheme)).To(Succeed())
+		backup.Status.CronJobRef = &backupv1alpha1.ObjectReference{Name: cronJob.Name, Namespace: cronJob.Namespace}
+
+		newReconciler(cronJob)
+		Expect(reconciler.renderCronJob(context.Background(), backup, repository)).To(Succeed())
+		Expect(backup.Status.CronJobRef).To(BeNil())
+
+		err := reconciler.Get(context.Background(), client.ObjectKeyFromObject(cronJob), &batchv1.CronJob{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should delete the ConfigMap once the backup is enabled", func() {
+		newReconciler()
+		Expect(reconciler.renderCronJob(context.Background(), backup, repository)).To(Succeed())
+
+		Expect(reconciler.deleteRenderedManifest(context.Background(), backup)).To(Succeed())
+		Expect(backup.Status.RenderedManifestRef).To(BeNil())
+
+		err := reconciler.Get(context.Background(), types.NamespacedName{Name: "resticbackup-app-rendered", Namespace: "media"}, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,182 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// defaultMemoryIncreasePercent is by how much the memory limit is raised after an
+	// OOM kill if autoTuneResources doesn't set it
+	defaultMemoryIncreasePercent = 50
+	// mebibyte is the unit suggested memory limits are rounded up to
+	mebibyte = 1024 * 1024
+)
+
+// configuredMemoryLimit returns the memory limit of jobConfig.resources of a backup.
+func configuredMemoryLimit(backup *backupv1alpha1.ResticBackup) (resource.Quantity, bool) {
+	if backup.Spec.JobConfig == nil || backup.Spec.JobConfig.Resources == nil {
+		return resource.Quantity{}, false
+	}
+	limit, ok := backup.Spec.JobConfig.Resources.Limits[corev1.ResourceMemory]
+	return limit, ok
+}
+
+// tunedMemoryLimit returns the memory limit raised by autoTuneResources, if it is
+// enabled and the raised limit is higher than the configured one. It is capped at
+// maxMemory, which may have been lowered since the limit was raised.
+func tunedMemoryLimit(backup *backupv1alpha1.ResticBackup) (resource.Quantity, bool) {
+	tune := backup.Spec.AutoTuneResources
+	status := backup.Status.Resources
+	if tune == nil || status == nil || status.MemoryLimit == "" {
+		return resource.Quantity{}, false
+	}
+	limit, err := resource.ParseQuantity(status.MemoryLimit)
+	if err != nil {
+		return resource.Quantity{}, false
+	}
+	if limit.Cmp(tune.MaxMemory) > 0 {
+		limit = tune.MaxMemory.DeepCopy()
+	}
+	if configured, ok := configuredMemoryLimit(backup); ok && configured.Cmp(limit) >= 0 {
+		return resource.Quantity{}, false
+	}
+	return limit, true
+}
+
+// backupResources returns the resources of the backup containers: those of
+// jobConfig.resources with the memory limit raised by autoTuneResources.
+func backupResources(backup *backupv1alpha1.ResticBackup) corev1.ResourceRequirements {
+	resources := corev1.ResourceRequirements{}
+	if backup.Spec.JobConfig != nil && backup.Spec.JobConfig.Resources != nil {
+		resources = *backup.Spec.JobConfig.Resources.DeepCopy()
+	}
+	if limit, ok := tunedMemoryLimit(backup); ok {
+		if resources.Limits == nil {
+			resources.Limits = corev1.ResourceList{}
+		}
+		resources.Limits[corev1.ResourceMemory] = limit
+	}
+	return resources
+}
+
+// suggestMemoryLimit raises a memory limit by the given percentage, rounded up to MiB.
+func suggestMemoryLimit(current resource.Quantity, percent int32) resource.Quantity {
+	raised := current.Value() + current.Value()*int64(percent)/100
+	raised = (raised + mebibyte - 1) / mebibyte * mebibyte
+	return *resource.NewQuantity(raised, resource.BinarySI)
+}
+
+// recordOOMKill records an OOMKilled backup job in status.resources with a suggested
+// memory limit. With autoTuneResources, the memory limit is raised up to maxMemory and
+// the backup is retried with a Job created from the updated CronJob.
+func (r *ResticBackupReconciler) recordOOMKill(ctx context.Context, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository,
+	job *batchv1.Job, finishedAt time.Time) error {
+	if backup.Status.Resources == nil {
+		backup.Status.Resources = &backupv1alpha1.BackupResourcesStatus{}
+	}
+	status := backup.Status.Resources
+	killedAt := metav1.NewTime(finishedAt)
+	status.OOMKills++
+	status.LastOOMKill = &killedAt
+	status.LastOOMKilledJob = job.Name
+
+	current, ok := backupResources(backup).Limits[corev1.ResourceMemory]
+	if !ok {
+		status.SuggestedMemoryLimit = ""
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupOOMKilled",
+			fmt.Sprintf("Backup job %s ran out of memory, the node has too little memory for restic", job.Name))
+		return nil
+	}
+
+	tune := backup.Spec.AutoTuneResources
+	percent := int32(defaultMemoryIncreasePercent)
+	if tune != nil && tune.MemoryIncreasePercent > 0 {
+		percent = tune.MemoryIncreasePercent
+	}
+	suggested := suggestMemoryLimit(current, percent)
+	status.SuggestedMemoryLimit = suggested.String()
+
+	if tune == nil || current.Cmp(tune.MaxMemory) >= 0 {
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupOOMKilled",
+			fmt.Sprintf("Backup job %s ran out of memory with a limit of %s, consider raising it to %s", job.Name, current.String(), suggested.String()))
+		return nil
+	}
+
+	if suggested.Cmp(tune.MaxMemory) > 0 {
+		suggested = tune.MaxMemory.DeepCopy()
+	}
+	status.MemoryLimit = suggested.String()
+	r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupOOMKilled",
+		fmt.Sprintf("Backup job %s ran out of memory with a limit of %s, retrying with %s", job.Name, current.String(), suggested.String()))
+
+	return r.retryBackup(ctx, backup, repository)
+}
+
+// retryBackup runs the backup again with a Job created from the CronJob of the backup.
+// The Job is named after the number of OOM kills, so a retried reconcile adopts it.
+func (r *ResticBackupReconciler) retryBackup(ctx context.Context, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) error {
+	name, created, err := r.createBackupJob(ctx, backup, repository, fmt.Sprintf("oom-%d", backup.Status.Resources.OOMKills), nil)
+	if err != nil {
+		return err
+	}
+	if created {
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupRetried", fmt.Sprintf("Created job %s retrying the backup", name))
+	}
+	return nil
+}
+
+// createBackupJob creates a Job from the CronJob of the backup, named after the CronJob
+// and the given suffix. An existing Job of that name is adopted. It returns the name of
+// the Job and whether it was created.
+func (r *ResticBackupReconciler) createBackupJob(ctx context.Context, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository,
+	suffix string, annotations map[string]string) (string, bool, error) {
+	cronJob, err := r.buildCronJob(backup, repository)
+	if err != nil {
+		return "", false, err
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-%s", cronJob.Name, suffix),
+			Namespace:   backup.Namespace,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+			Annotations: annotations,
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return "", false, fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	created, err := createOrAdopt(ctx, r.Client, backup, job, &batchv1.Job{})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create retry job: %w", err)
+	}
+	return job.Name, created, nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Backup resource tuning", func() {
+	var (
+		backup     *backupv1alpha1.ResticBackup
+		repository *backupv1alpha1.ResticRepository
+		recorder   *record.FakeRecorder
+		r          *ResticBackupReconciler
+	)
+
+	memoryLimit := func(resources corev1.ResourceRequirements) string {
+		limit := resources.Limits[corev1.ResourceMemory]
+		return limit.String()
+	}
+	suggested := func(current string, percent int32) string {
+		limit := suggestMemoryLimit(resource.MustParse(current), percent)
+		return limit.String()
+	}
+
+	oomKilledJob := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "resticbackup-app-29480160", Namespace: "default"}}
+
+	BeforeEach(func() {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: "backup-uid"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Schedule: "0 2 * * *",
+				Source:   backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+				JobConfig: &backupv1alpha1.JobConfiguration{
+					Resources: &corev1.ResourceRequirements{Limits: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("256Mi"),
+					}},
+				},
+			},
+		}
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "default"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		recorder = record.NewFakeRecorder(10)
+		r = &ResticBackupReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(testScheme).Build(),
+			Scheme:   testScheme,
+			Recorder: recorder,
+		}
+	})
+
+	It("should round suggested memory limits up to MiB", func() {
+		Expect(suggested("256Mi", 50)).To(Equal("384Mi"))
+		Expect(suggested("1G", 10)).To(Equal("1050Mi"))
+	})
+
+	It("should apply the raised memory limit only with autoTuneResources and below the cap", func() {
+		backup.Status.Resources = &backupv1alpha1.BackupResourcesStatus{MemoryLimit: "1Gi"}
+		Expect(memoryLimit(backupResources(backup))).To(Equal("256Mi"))
+
+		backup.Spec.AutoTuneResources = &backupv1alpha1.AutoTuneResources{MaxMemory: resource.MustParse("768Mi")}
+		Expect(memoryLimit(backupResources(backup))).To(Equal("768Mi"))
+		Expect(memoryLimit(*backup.Spec.JobConfig.Resources)).To(Equal("256Mi"))
+
+		backup.Spec.JobConfig.Resources.Limits[corev1.ResourceMemory] = resource.MustParse("2Gi")
+		Expect(memoryLimit(backupResources(backup))).To(Equal("2Gi"))
+	})
+
+	It("should only suggest a memory limit without autoTuneResources", func() {
+		Expect(r.recordOOMKill(context.Background(), backup, repository, oomKilledJob, time.Now())).To(Succeed())
+
+		Expect(backup.Status.Resources.OOMKills).To(Equal(int32(1)))
+		Expect(backup.Status.Resources.LastOOMKilledJob).To(Equal("resticbackup-app-29480160"))
+		Expect(backup.Status.Resources.SuggestedMemoryLimit).To(Equal("384Mi"))
+		Expect(backup.Status.Resources.MemoryLimit).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(ContainSubstring("consider raising it to 384Mi")))
+
+		jobs := &batchv1.JobList{}
+		Expect(r.List(context.Background(), jobs)).To(Succeed())
+		Expect(jobs.Items).To(BeEmpty())
+	})
+
+	It("should raise the memory limit up to the cap and retry the backup", func() {
+		backup.Spec.AutoTuneResources = &backupv1alpha1.AutoTuneResources{
+			MaxMemory:             resource.MustParse("512Mi"),
+			MemoryIncreasePercent: 100,
+		}
+
+		Expect(r.recordOOMKill(context.Background(), backup, repository, oomKilledJob, time.Now())).To(Succeed())
+		Expect(backup.Status.Resources.MemoryLimit).To(Equal("512Mi"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("retrying with 512Mi")))
+		Expect(recorder.Events).To(Receive(ContainSubstring("BackupRetried")))
+
+		retry := &batchv1.Job{}
+		Expect(r.Get(context.Background(), client.ObjectKey{Name: "resticbackup-app-oom-1", Namespace: "default"}, retry)).To(Succeed())
+		Expect(retry.Labels).To(HaveKeyWithValue(resticBackupLabel, "app"))
+		Expect(memoryLimit(retry.Spec.Template.Spec.Containers[0].Resources)).To(Equal("512Mi"))
+
+		// At the cap, the backup is not retried again
+		Expect(r.recordOOMKill(context.Background(), backup, repository, retry, time.Now())).To(Succeed())
+		Expect(backup.Status.Resources.OOMKills).To(Equal(int32(2)))
+		Expect(recorder.Events).To(Receive(ContainSubstring("consider raising it to 1Gi")))
+		jobs := &batchv1.JobList{}
+		Expect(r.List(context.Background(), jobs)).To(Succeed())
+		Expect(jobs.Items).To(HaveLen(1))
+	})
+})
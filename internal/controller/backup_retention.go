@@ -0,0 +1,192 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	retentionSourceBackup     = "ResticBackup"
+	retentionSourceRepository = "ResticRepository"
+
+	// retentionSummaryType is the message type of the line reporting the snapshots
+	// left after retention in the termination message of a backup job.
+	retentionSummaryType = "retention"
+
+	// retentionPruneLabel marks the ResticPrunes created to prune a repository after
+	// the retention of its backups with the name of the repository.
+	retentionPruneLabel = "backup.resticbackup.io/retention-prune"
+
+	// retentionPruneInterval is the minimum time between two prunes of a repository
+	// requested by the retention of its backups.
+	retentionPruneInterval = 24 * time.Hour
+)
+
+// retentionSummary is the line reporting the snapshots left after retention.
+type retentionSummary struct {
+	MessageType    string `json:"message_type"`
+	SnapshotsAfter *int32 `json:"snapshots_after"`
+}
+
+// effectiveRetention returns the retention applied after each backup. A backup
+// without its own retention inherits the default retention of the repository; an
+// own retention, even a disabled one, always takes precedence. It returns nil if
+// snapshots are never forgotten.
+func effectiveRetention(backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) *backupv1alpha1.EffectiveRetention {
+	retention, source := backup.Spec.Retention, retentionSourceBackup
+	if retention == nil && repository != nil {
+		retention, source = repository.Spec.DefaultRetention, retentionSourceRepository
+	}
+	if retention == nil || !retention.Enabled || retention.Policy == nil {
+		return nil
+	}
+
+	return &backupv1alpha1.EffectiveRetention{
+		Source:  source,
+		Policy:  retention.Policy.DeepCopy(),
+		Prune:   retention.Prune,
+		GroupBy: append([]string(nil), retention.GroupBy...),
+	}
+}
+
+// buildForgetCommand builds the restic forget command applying the retention to the
+// snapshots of the backup host. It returns nil without retention. The repository is
+// never pruned in the backup job, see requestRetentionPrune.
+func buildForgetCommand(retention *backupv1alpha1.EffectiveRetention, hostname string) []string {
+	if retention == nil {
+		return nil
+	}
+
+	groupBy := "host"
+	if len(retention.GroupBy) > 0 {
+		groupBy = strings.Join(retention.GroupBy, ",")
+	}
+
+	cmd := restic.NewCommand("forget").
+		WithHost(hostname).
+		WithGroupBy(groupBy).
+		WithKeepTag(retentionProtectedTag).
+		WithKeepLast(int32Value(retention.Policy.KeepLast)).
+		WithKeepHourly(int32Value(retention.Policy.KeepHourly)).
+		WithKeepDaily(int32Value(retention.Policy.KeepDaily)).
+		WithKeepWeekly(int32Value(retention.Policy.KeepWeekly)).
+		WithKeepMonthly(int32Value(retention.Policy.KeepMonthly)).
+		WithKeepYearly(int32Value(retention.Policy.KeepYearly)).
+		WithKeepWithin(retention.Policy.KeepWithin).
+		WithKeepWithinHourly(retention.Policy.KeepWithinHourly).
+		WithKeepWithinDaily(retention.Policy.KeepWithinDaily).
+		WithKeepWithinWeekly(retention.Policy.KeepWithinWeekly).
+		WithKeepWithinMonthly(retention.Policy.KeepWithinMonthly).
+		WithKeepWithinYearly(retention.Policy.KeepWithinYearly)
+
+	return append([]string{"restic"}, cmd.Build()...)
+}
+
+// requestRetentionPrune prunes the repository after the retention of a backup forgot
+// snapshots. Prune needs an exclusive lock of the repository, so instead of every backup
+// job it runs in one ResticPrune per repository, which waits for the backups of a
+// repository coordinating its jobs. A new ResticPrune is created at most once per
+// retentionPruneInterval and replaces the finished one.
+func (r *ResticBackupReconciler) requestRetentionPrune(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) error {
+	// Backups of the repository finishing at the same time must not create two prunes
+	r.retentionPrunes.Lock()
+	defer r.retentionPrunes.Unlock()
+
+	prunes := &backupv1alpha1.ResticPruneList{}
+	if err := reader.List(ctx, prunes, client.InNamespace(repository.Namespace),
+		client.MatchingLabels{retentionPruneLabel: repository.Name}); err != nil {
+		return fmt.Errorf("failed to list retention prunes: %w", err)
+	}
+	for i := range prunes.Items {
+		prune := &prunes.Items[i]
+		if !metav1.IsControlledBy(prune, repository) || !prune.DeletionTimestamp.IsZero() {
+			continue
+		}
+		finished := prune.Status.Phase == backupv1alpha1.PrunePhaseCompleted || prune.Status.Phase == backupv1alpha1.PrunePhaseFailed
+		if !finished || prune.Status.CompletionTime == nil || time.Since(prune.Status.CompletionTime.Time) < retentionPruneInterval {
+			return nil
+		}
+		if err := r.Delete(ctx, prune); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete ResticPrune %s: %w", prune.Name, err)
+		}
+	}
+
+	prune := &backupv1alpha1.ResticPrune{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: repository.Name + "-retention-prune-",
+			Namespace:    repository.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				retentionPruneLabel:            repository.Name,
+			},
+		},
+		Spec: backupv1alpha1.ResticPruneSpec{
+			RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: repository.Name},
+		},
+	}
+	if err := controllerutil.SetControllerReference(repository, prune, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+	if err := r.Create(ctx, prune); err != nil {
+		return fmt.Errorf("failed to create ResticPrune: %w", err)
+	}
+	r.Recorder.Event(backup, corev1.EventTypeNormal, "RetentionPruneRequested",
+		fmt.Sprintf("Created ResticPrune %s/%s to prune repository %s after retention", prune.Namespace, prune.Name, repository.Name))
+	return nil
+}
+
+// buildSnapshotCountCommand builds the restic command listing the snapshots of the
+// backup host, whose number is reported after retention.
+func buildSnapshotCountCommand(hostname string) []string {
+	return append([]string{"restic"}, restic.NewCommand("snapshots").WithHost(hostname).WithJSON().Build()...)
+}
+
+// parseRetentionSummary returns the number of snapshots left after retention from
+// the termination message of a backup job.
+func parseRetentionSummary(message string) (int32, bool) {
+	for _, line := range strings.Split(message, "\n") {
+		var summary retentionSummary
+		if err := json.Unmarshal([]byte(line), &summary); err != nil {
+			continue
+		}
+		if summary.MessageType == retentionSummaryType && summary.SnapshotsAfter != nil {
+			return *summary.SnapshotsAfter, true
+		}
+	}
+	return 0, false
+}
+
+// int32Value returns the value of an optional int32, or 0 if it is unset.
+func int32Value(v *int32) int {
+	if v == nil {
+		return 0
+	}
+	return int(*v)
+}
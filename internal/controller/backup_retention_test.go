@@ -0,0 +1,194 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Backup retention", func() {
+	var (
+		backup     *backupv1alpha1.ResticBackup
+		repository *backupv1alpha1.ResticRepository
+		keepDaily  int32
+	)
+
+	BeforeEach(func() {
+		keepDaily = 7
+		backup = &backupv1alpha1.ResticBackup{}
+		repository = &backupv1alpha1.ResticRepository{
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				DefaultRetention: &backupv1alpha1.RetentionConfig{
+					Enabled: true,
+					Policy:  &backupv1alpha1.RetentionPolicy{KeepDaily: &keepDaily},
+				},
+			},
+		}
+	})
+
+	Context("effectiveRetention helper function", func() {
+		It("should inherit the default retention of the repository", func() {
+			retention := effectiveRetention(backup, repository)
+			Expect(retention).NotTo(BeNil())
+			Expect(retention.Source).To(Equal("ResticRepository"))
+			Expect(*retention.Policy.KeepDaily).To(Equal(int32(7)))
+		})
+
+		It("should prefer the retention of the backup", func() {
+			keepLast := int32(3)
+			backup.Spec.Retention = &backupv1alpha1.RetentionConfig{
+				Enabled: true,
+				Policy:  &backupv1alpha1.RetentionPolicy{KeepLast: &keepLast},
+				Prune:   true,
+			}
+
+			retention := effectiveRetention(backup, repository)
+			Expect(retention.Source).To(Equal("ResticBackup"))
+			Expect(retention.Policy.KeepDaily).To(BeNil())
+			Expect(retention.Prune).To(BeTrue())
+		})
+
+		It("should not inherit if the backup disables its retention", func() {
+			backup.Spec.Retention = &backupv1alpha1.RetentionConfig{Enabled: false}
+			Expect(effectiveRetention(backup, repository)).To(BeNil())
+		})
+
+		It("should return nil without any retention", func() {
+			repository.Spec.DefaultRetention = nil
+			Expect(effectiveRetention(backup, repository)).To(BeNil())
+		})
+	})
+
+	Context("buildForgetCommand helper function", func() {
+		It("should forget the snapshots of the backup host", func() {
+			cmd := buildForgetCommand(effectiveRetention(backup, repository), "app-data")
+			Expect(cmd).To(Equal([]string{
+				"restic", "forget", "--host", "app-data", "--group-by", "host", "--keep-tag", "retention-protected", "--keep-daily", "7",
+			}))
+		})
+
+		It("should apply the grouping and never prune", func() {
+			repository.Spec.DefaultRetention.GroupBy = []string{"host", "paths"}
+			repository.Spec.DefaultRetention.Prune = true
+
+			cmd := buildForgetCommand(effectiveRetention(backup, repository), "app-data")
+			Expect(cmd).To(ContainElement("host,paths"))
+			Expect(cmd).NotTo(ContainElement("--prune"))
+		})
+
+		It("should keep the snapshots within the durations", func() {
+			repository.Spec.DefaultRetention.Policy = &backupv1alpha1.RetentionPolicy{KeepWithin: "2d", KeepWithinMonthly: "1y6m"}
+
+			cmd := buildForgetCommand(effectiveRetention(backup, repository), "app-data")
+			Expect(cmd).To(Equal([]string{
+				"restic", "forget", "--host", "app-data", "--group-by", "host", "--keep-tag", "retention-protected", "--keep-within", "2d", "--keep-within-monthly", "1y6m",
+			}))
+		})
+
+		It("should return nil without retention", func() {
+			Expect(buildForgetCommand(nil, "app-data")).To(BeNil())
+		})
+	})
+
+	Context("HasKeepRule", func() {
+		It("should require a keep rule", func() {
+			zero := int32(0)
+			Expect((&backupv1alpha1.RetentionPolicy{}).HasKeepRule()).To(BeFalse())
+			Expect((&backupv1alpha1.RetentionPolicy{KeepLast: &zero}).HasKeepRule()).To(BeFalse())
+			Expect((&backupv1alpha1.RetentionPolicy{KeepDaily: &keepDaily}).HasKeepRule()).To(BeTrue())
+			Expect((&backupv1alpha1.RetentionPolicy{KeepWithinYearly: "5y"}).HasKeepRule()).To(BeTrue())
+		})
+	})
+
+	Context("requestRetentionPrune", func() {
+		var reconciler *ResticBackupReconciler
+
+		BeforeEach(func() {
+			repository.ObjectMeta = metav1.ObjectMeta{Name: "nas", Namespace: "backup-system", UID: "repository-uid"}
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			reconciler = &ResticBackupReconciler{
+				Client: fake.NewClientBuilder().WithScheme(testScheme).
+					WithStatusSubresource(&backupv1alpha1.ResticPrune{}).Build(),
+				Scheme:   testScheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+		})
+
+		listPrunes := func() []backupv1alpha1.ResticPrune {
+			prunes := &backupv1alpha1.ResticPruneList{}
+			Expect(reconciler.List(context.Background(), prunes, client.MatchingLabels{retentionPruneLabel: "nas"})).To(Succeed())
+			return prunes.Items
+		}
+
+		It("should prune each repository once at a time", func() {
+			Expect(reconciler.requestRetentionPrune(context.Background(), reconciler.Client, backup, repository)).To(Succeed())
+			Expect(reconciler.requestRetentionPrune(context.Background(), reconciler.Client, backup, repository)).To(Succeed())
+
+			prunes := listPrunes()
+			Expect(prunes).To(HaveLen(1))
+			Expect(prunes[0].Namespace).To(Equal("backup-system"))
+			Expect(prunes[0].Spec.RepositoryRef.Name).To(Equal("nas"))
+			Expect(metav1.IsControlledBy(&prunes[0], repository)).To(BeTrue())
+		})
+
+		It("should replace a prune finished before the interval", func() {
+			Expect(reconciler.requestRetentionPrune(context.Background(), reconciler.Client, backup, repository)).To(Succeed())
+			prune := &listPrunes()[0]
+			prune.Status.Phase = backupv1alpha1.PrunePhaseCompleted
+			prune.Status.CompletionTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			Expect(reconciler.Status().Update(context.Background(), prune)).To(Succeed())
+
+			Expect(reconciler.requestRetentionPrune(context.Background(), reconciler.Client, backup, repository)).To(Succeed())
+			Expect(listPrunes()).To(ConsistOf(HaveField("Name", prune.Name)))
+
+			prune.Status.CompletionTime = &metav1.Time{Time: time.Now().Add(-retentionPruneInterval)}
+			Expect(reconciler.Status().Update(context.Background(), prune)).To(Succeed())
+			Expect(reconciler.requestRetentionPrune(context.Background(), reconciler.Client, backup, repository)).To(Succeed())
+			prunes := listPrunes()
+			Expect(prunes).To(HaveLen(1))
+			Expect(prunes[0].Name).NotTo(Equal(prune.Name))
+		})
+	})
+
+	Context("parseRetentionSummary helper function", func() {
+		It("should read the snapshots left after retention", func() {
+			message := `{"message_type":"summary","snapshot_id":"abc"}` + "\n" + `{"message_type":"retention","snapshots_after":12}`
+			snapshots, ok := parseRetentionSummary(message)
+			Expect(ok).To(BeTrue())
+			Expect(snapshots).To(Equal(int32(12)))
+		})
+
+		It("should report a missing retention summary", func() {
+			_, ok := parseRetentionSummary(`{"message_type":"summary","snapshot_id":"abc"}`)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
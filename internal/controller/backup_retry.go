@@ -0,0 +1,166 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// retryAttemptAnnotation is the retry attempt of a Job retrying a failed backup job.
+	retryAttemptAnnotation = "backup.resticbackup.io/retry-attempt"
+	// defaultMaxRetries is the number of retries if retry.maxRetries isn't set
+	defaultMaxRetries = 3
+	// defaultRetryBackoff is the wait before the first retry if retry.backoff isn't set
+	defaultRetryBackoff = 5 * time.Minute
+	// maxRetryBackoff caps the backoff doubled for every retry
+	maxRetryBackoff = time.Hour
+)
+
+// retryBackoff returns the wait before the given retry attempt, starting at 1: the
+// configured backoff, doubled for every previous attempt up to maxRetryBackoff.
+func retryBackoff(policy *backupv1alpha1.BackupRetryPolicy, attempt int32) time.Duration {
+	backoff := durationOrDefault(policy.Backoff, defaultRetryBackoff)
+	for i := int32(1); i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxRetryBackoff)
+}
+
+// retryAttempt returns the retry attempt of a backup job, 0 for a scheduled job.
+func retryAttempt(job *batchv1.Job) int32 {
+	attempt, err := strconv.ParseInt(job.Annotations[retryAttemptAnnotation], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(attempt)
+}
+
+// scheduleRetry records in status.retry when a failed backup job is retried. Any other
+// result ends the retries. No retry is scheduled once maxRetries retries of the job
+// failed, or if the next scheduled backup starts before the retry would.
+func (r *ResticBackupReconciler) scheduleRetry(backup *backupv1alpha1.ResticBackup, job *batchv1.Job, result string, finishedAt time.Time) {
+	policy := backup.Spec.Retry
+	if policy == nil || result != backupResultFailed {
+		backup.Status.Retry = nil
+		return
+	}
+
+	attempt := retryAttempt(job)
+	if attempt == 0 || backup.Status.Retry == nil {
+		backup.Status.Retry = &backupv1alpha1.BackupRetryStatus{FailedJob: job.Name}
+	}
+	status := backup.Status.Retry
+	status.Attempts = attempt
+	status.NextRetry = nil
+
+	maxRetries := policy.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	if attempt >= maxRetries {
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupRetriesExhausted",
+			fmt.Sprintf("Backup job %s failed after %d retries, waiting for the next scheduled backup", status.FailedJob, attempt))
+		return
+	}
+
+	retryAt := finishedAt.Add(retryBackoff(policy, attempt+1))
+	if next := backup.Status.NextBackup; next != nil && !next.After(retryAt) {
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupRetrySkipped",
+			fmt.Sprintf("Not retrying backup job %s, the next scheduled backup starts at %s", status.FailedJob, next.UTC().Format(time.RFC3339)))
+		return
+	}
+	nextRetry := metav1.NewTime(retryAt)
+	status.NextRetry = &nextRetry
+}
+
+// runDueRetry creates the Job retrying a failed backup job once its backoff passed. It
+// returns the time until the pending retry is due, zero if there is none. A due retry
+// is skipped while the backup is suspended, outside of its backup window or while
+// another backup job runs. The Job is named after the number of retried backups, so a
+// retried reconcile adopts it.
+func (r *ResticBackupReconciler) runDueRetry(ctx context.Context, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository,
+	window *backupWindowState, now time.Time) (time.Duration, error) {
+	status := backup.Status.Retry
+	if backup.Spec.Retry == nil {
+		backup.Status.Retry = nil
+		return 0, nil
+	}
+	if status == nil || status.NextRetry == nil {
+		return 0, nil
+	}
+	if wait := status.NextRetry.Sub(now); wait > 0 {
+		return wait, nil
+	}
+
+	skipped := ""
+	switch {
+	case backup.Spec.Suspend || backup.Spec.RenderOnly:
+		skipped = "the backup is suspended"
+	case window != nil && window.suspended:
+		skipped = "scheduled backups are suspended by the backup window"
+	default:
+		jobs := &batchv1.JobList{}
+		if err := r.List(ctx, jobs,
+			client.InNamespace(backup.Namespace),
+			client.MatchingLabels{resticBackupLabel: backup.Name},
+		); err != nil {
+			return 0, fmt.Errorf("failed to list backup jobs: %w", err)
+		}
+		for i := range jobs.Items {
+			if finished, _, _ := jobFinished(&jobs.Items[i]); !finished {
+				skipped = fmt.Sprintf("backup job %s is running", jobs.Items[i].Name)
+				break
+			}
+		}
+	}
+	if skipped != "" {
+		status.NextRetry = nil
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupRetrySkipped",
+			fmt.Sprintf("Not retrying backup job %s, %s", status.FailedJob, skipped))
+		return 0, nil
+	}
+
+	if backup.Status.Statistics == nil {
+		backup.Status.Statistics = &backupv1alpha1.BackupStatistics{}
+	}
+	attempt := status.Attempts + 1
+	name, created, err := r.createBackupJob(ctx, backup, repository,
+		fmt.Sprintf("retry-%d", backup.Status.Statistics.RetriedBackups+1),
+		map[string]string{retryAttemptAnnotation: strconv.Itoa(int(attempt))})
+	if err != nil {
+		return 0, err
+	}
+	backup.Status.Statistics.RetriedBackups++
+	status.Attempts = attempt
+	status.NextRetry = nil
+	if created {
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupRetried",
+			fmt.Sprintf("Created job %s, retry %d of backup job %s", name, attempt, status.FailedJob))
+	}
+	return 0, nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Backup retry", func() {
+	var (
+		backup     *backupv1alpha1.ResticBackup
+		repository *backupv1alpha1.ResticRepository
+		recorder   *record.FakeRecorder
+		r          *ResticBackupReconciler
+	)
+
+	now := time.Date(2024, 1, 15, 2, 10, 0, 0, time.UTC)
+
+	failedJob := func(name string, attempt string) *batchv1.Job {
+		job := finishedJob(name, false, now)
+		job.Namespace = "default"
+		job.Labels = map[string]string{resticBackupLabel: "app"}
+		if attempt != "" {
+			job.Annotations = map[string]string{retryAttemptAnnotation: attempt}
+		}
+		return &job
+	}
+
+	newReconciler := func(objects ...client.Object) {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		r = &ResticBackupReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build(),
+			Scheme:   testScheme,
+			Recorder: recorder,
+		}
+	}
+
+	BeforeEach(func() {
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: "backup-uid"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Schedule: "0 2 * * *",
+				Source:   backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+				Retry:    &backupv1alpha1.BackupRetryPolicy{MaxRetries: 2, Backoff: &metav1.Duration{Duration: 10 * time.Minute}},
+			},
+		}
+		nextBackup := metav1.NewTime(now.Add(24 * time.Hour))
+		backup.Status.NextBackup = &nextBackup
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "default"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		newReconciler()
+	})
+
+	It("should double the backoff for every retry up to an hour", func() {
+		policy := &backupv1alpha1.BackupRetryPolicy{}
+		Expect(retryBackoff(policy, 1)).To(Equal(5 * time.Minute))
+		Expect(retryBackoff(policy, 3)).To(Equal(20 * time.Minute))
+		Expect(retryBackoff(policy, 10)).To(Equal(time.Hour))
+	})
+
+	It("should schedule retries of a failed job until they are exhausted", func() {
+		r.scheduleRetry(backup, failedJob("resticbackup-app-1", ""), backupResultFailed, now)
+		Expect(backup.Status.Retry.FailedJob).To(Equal("resticbackup-app-1"))
+		Expect(backup.Status.Retry.Attempts).To(BeZero())
+		Expect(backup.Status.Retry.NextRetry.Time).To(Equal(now.Add(10 * time.Minute)))
+
+		r.scheduleRetry(backup, failedJob("resticbackup-app-retry-1", "1"), backupResultFailed, now)
+		Expect(backup.Status.Retry.FailedJob).To(Equal("resticbackup-app-1"))
+		Expect(backup.Status.Retry.Attempts).To(Equal(int32(1)))
+		Expect(backup.Status.Retry.NextRetry.Time).To(Equal(now.Add(20 * time.Minute)))
+
+		r.scheduleRetry(backup, failedJob("resticbackup-app-retry-2", "2"), backupResultFailed, now)
+		Expect(backup.Status.Retry.NextRetry).To(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring("BackupRetriesExhausted")))
+
+		r.scheduleRetry(backup, failedJob("resticbackup-app-2", ""), backupResultSucceeded, now)
+		Expect(backup.Status.Retry).To(BeNil())
+	})
+
+	It("should not retry before the next scheduled backup", func() {
+		nextBackup := metav1.NewTime(now.Add(5 * time.Minute))
+		backup.Status.NextBackup = &nextBackup
+		r.scheduleRetry(backup, failedJob("resticbackup-app-1", ""), backupResultFailed, now)
+		Expect(backup.Status.Retry.NextRetry).To(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring("BackupRetrySkipped")))
+	})
+
+	It("should create the retry job once the backoff passed", func() {
+		r.scheduleRetry(backup, failedJob("resticbackup-app-1", ""), backupResultFailed, now)
+		Expect(r.runDueRetry(context.Background(), backup, repository, nil, now)).To(Equal(10 * time.Minute))
+
+		Expect(r.runDueRetry(context.Background(), backup, repository, nil, now.Add(10*time.Minute))).To(BeZero())
+		Expect(backup.Status.Retry.Attempts).To(Equal(int32(1)))
+		Expect(backup.Status.Retry.NextRetry).To(BeNil())
+		Expect(backup.Status.Statistics.RetriedBackups).To(Equal(int32(1)))
+		Expect(recorder.Events).To(Receive(ContainSubstring("retry 1 of backup job resticbackup-app-1")))
+
+		retry := &batchv1.Job{}
+		Expect(r.Get(context.Background(), client.ObjectKey{Name: "resticbackup-app-retry-1", Namespace: "default"}, retry)).To(Succeed())
+		Expect(retry.Labels).To(HaveKeyWithValue(resticBackupLabel, "app"))
+		Expect(retryAttempt(retry)).To(Equal(int32(1)))
+	})
+
+	It("should skip a due retry while another backup job runs", func() {
+		running := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:      "resticbackup-app-2",
+			Namespace: "default",
+			Labels:    map[string]string{resticBackupLabel: "app"},
+		}}
+		newReconciler(running)
+		r.scheduleRetry(backup, failedJob("resticbackup-app-1", ""), backupResultFailed, now)
+
+		Expect(r.runDueRetry(context.Background(), backup, repository, nil, now.Add(time.Hour))).To(BeZero())
+		Expect(backup.Status.Retry.NextRetry).To(BeNil())
+		Expect(backup.Status.Statistics).To(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring("backup job resticbackup-app-2 is running")))
+	})
+})
@@ -0,0 +1,172 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// Names of the scale down steps as reported in the hook status.
+const (
+	scaleDownHook = "scaleDown"
+	scaleUpHook   = "scaleUp"
+)
+
+// usesScaleDown reports whether the workload of the backup is scaled down during
+// backups. Such Jobs are created suspended and started by the operator once all pods
+// of the workload terminated.
+func usesScaleDown(backup *backupv1alpha1.ResticBackup) bool {
+	return backup.Spec.Hooks != nil && backup.Spec.Hooks.ScaleDown != nil
+}
+
+// scaleDownWorkload scales the workload of the backup to zero replicas for a backup
+// Job. A message is returned while pods of the workload are still running or the
+// workload is scaled down for something else, and an error if the pods did not
+// terminate within the timeout.
+func (r *ResticBackupReconciler) scaleDownWorkload(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup, job *batchv1.Job) (string, error) {
+	hook := backup.Spec.Hooks.ScaleDown
+	ref := hook.WorkloadRef
+	workload, err := getWorkload(ctx, reader, backup.Namespace, ref)
+	if err != nil {
+		return "", err
+	}
+
+	owner := backup.Name + "/" + job.Name
+	switch scaledDownFor := workloadScaledDownFor(workload); scaledDownFor {
+	case owner:
+	case "":
+		replicas, err := scaleDownWorkloadFor(ctx, r.Client, workload, owner)
+		if err != nil {
+			return "", err
+		}
+		backup.Status.ScaledDownWorkload = ref.DeepCopy()
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "WorkloadScaledDown",
+			fmt.Sprintf("Scaled %s %s down from %d replicas for backup job %s", ref.Kind, ref.Name, replicas, job.Name))
+	default:
+		return fmt.Sprintf("Waiting for %s %s, which is scaled down for %s", ref.Kind, ref.Name, scaledDownFor), nil
+	}
+
+	running, err := countWorkloadPods(ctx, reader, workload)
+	if err != nil {
+		return "", err
+	}
+	if running == 0 {
+		setHookStatus(&backup.Status, backupv1alpha1.HookStatus{
+			Name:    scaleDownHook,
+			Job:     job.Name,
+			LastRun: metav1.Now(),
+			Result:  backupResultSucceeded,
+			Message: fmt.Sprintf("Scaled down from %s replicas", workload.GetAnnotations()[scaledDownReplicasAnnotation]),
+		})
+		return "", nil
+	}
+
+	timeout := scaleDownTimeout(hook)
+	if since := workloadScaledDownSince(workload); !since.IsZero() && time.Since(since) > timeout {
+		return "", fmt.Errorf("%d pods of %s %s still running after %s", running, ref.Kind, ref.Name, timeout)
+	}
+	return fmt.Sprintf("Waiting for %d pods of %s %s to terminate", running, ref.Kind, ref.Name), nil
+}
+
+// failScaleDown records a backup Job whose workload could not be scaled down as failed
+// backup, deletes the Job and scales the workload up again.
+func (r *ResticBackupReconciler) failScaleDown(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup, job *batchv1.Job, scaleDownErr error) error {
+	log.FromContext(ctx).Error(scaleDownErr, "Failed to scale down workload", "job", job.Name)
+	setHookStatus(&backup.Status, backupv1alpha1.HookStatus{
+		Name:    scaleDownHook,
+		Job:     job.Name,
+		LastRun: metav1.Now(),
+		Result:  backupResultFailed,
+		Message: scaleDownErr.Error(),
+	})
+	if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete backup job %s: %w", job.Name, err)
+	}
+	recordBackupRun(&backup.Status, job, false, time.Now(), nil)
+	r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupFailed",
+		fmt.Sprintf("Backup job %s skipped, scaling down the workload failed: %v", job.Name, scaleDownErr))
+	return r.restoreScaledDownWorkload(ctx, reader, backup, nil)
+}
+
+// restoreScaledDownWorkload scales the workload scaled down by the backup up to its
+// previous replicas once the backup Job it was scaled down for finished or is gone.
+// Jobs lists the backup Jobs; nil treats all Jobs as gone.
+func (r *ResticBackupReconciler) restoreScaledDownWorkload(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup, jobs []batchv1.Job) error {
+	ref := backup.Status.ScaledDownWorkload
+	if ref == nil && usesScaleDown(backup) {
+		ref = &backup.Spec.Hooks.ScaleDown.WorkloadRef
+	}
+	if ref == nil {
+		return nil
+	}
+	workload, err := getWorkload(ctx, reader, backup.Namespace, *ref)
+	if apierrors.IsNotFound(err) {
+		backup.Status.ScaledDownWorkload = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Restores record "ResticRestore/<name>", which is never the name of a backup
+	backupName, jobName, _ := strings.Cut(workloadScaledDownFor(workload), "/")
+	if backupName != backup.Name {
+		backup.Status.ScaledDownWorkload = nil
+		return nil
+	}
+	for i := range jobs {
+		if jobs[i].Name != jobName || jobs[i].DeletionTimestamp != nil {
+			continue
+		}
+		if done, _, _ := jobFinished(&jobs[i]); !done {
+			return nil
+		}
+	}
+
+	hookStatus := backupv1alpha1.HookStatus{
+		Name:    scaleUpHook,
+		Job:     jobName,
+		LastRun: metav1.Now(),
+		Result:  backupResultSucceeded,
+	}
+	replicas, err := scaleUpWorkload(ctx, r.Client, workload)
+	if err != nil {
+		hookStatus.Result = backupResultFailed
+		hookStatus.Message = err.Error()
+		setHookStatus(&backup.Status, hookStatus)
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "ScaleUpFailed", err.Error())
+		return err
+	}
+	hookStatus.Message = fmt.Sprintf("Scaled up to %d replicas", replicas)
+	setHookStatus(&backup.Status, hookStatus)
+	r.Recorder.Event(backup, corev1.EventTypeNormal, "WorkloadScaledUp",
+		fmt.Sprintf("Scaled %s %s up to %d replicas after backup job %s", ref.Kind, ref.Name, replicas, jobName))
+	backup.Status.ScaledDownWorkload = nil
+	return nil
+}
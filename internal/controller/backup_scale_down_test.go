@@ -0,0 +1,197 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Backup scale down", func() {
+	var (
+		ctx        context.Context
+		reconciler *ResticBackupReconciler
+		backup     *backupv1alpha1.ResticBackup
+		repository *backupv1alpha1.ResticRepository
+		database   *appsv1.StatefulSet
+		job        *batchv1.Job
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Hooks: &backupv1alpha1.BackupHooks{
+					ScaleDown: &backupv1alpha1.ScaleDownHook{
+						WorkloadRef: backupv1alpha1.WorkloadReference{Kind: "StatefulSet", Name: "db"},
+						Timeout:     &metav1.Duration{Duration: time.Minute},
+					},
+				},
+			},
+		}
+		repository = &backupv1alpha1.ResticRepository{ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"}}
+		database = &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media"},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: int32Ptr(2),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			},
+		}
+		job = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "resticbackup-db-1", Namespace: "media", Labels: map[string]string{resticBackupLabel: "db"}},
+			Spec:       batchv1.JobSpec{Suspend: boolPtr(true)},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) {
+		reconciler = &ResticBackupReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build(),
+			Recorder: record.NewFakeRecorder(10),
+		}
+	}
+
+	getDatabase := func() *appsv1.StatefulSet {
+		statefulSet := &appsv1.StatefulSet{}
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(database), statefulSet)).To(Succeed())
+		return statefulSet
+	}
+
+	getJob := func() *batchv1.Job {
+		current := &batchv1.Job{}
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(job), current)).To(Succeed())
+		return current
+	}
+
+	It("should create backup jobs suspended", func() {
+		cronJob, err := (&ResticBackupReconciler{}).buildCronJob(backup, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cronJob.Spec.JobTemplate.Spec.Suspend).To(HaveValue(BeTrue()))
+	})
+
+	It("should start the backup job after the pods of the workload terminated", func() {
+		pod := hookPod("db-0", corev1.PodRunning)
+		newReconciler(database, job, pod)
+
+		message, err := reconciler.startSuspendedJobs(ctx, reconciler.Client, backup, repository, []batchv1.Job{*job})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(Equal("Waiting for 1 pods of StatefulSet db to terminate"))
+		Expect(getDatabase().Spec.Replicas).To(HaveValue(BeEquivalentTo(0)))
+		Expect(getDatabase().Annotations).To(HaveKeyWithValue(scaledDownByAnnotation, "db/resticbackup-db-1"))
+		Expect(getDatabase().Annotations).To(HaveKeyWithValue(scaledDownReplicasAnnotation, "2"))
+		Expect(backup.Status.ScaledDownWorkload).To(HaveValue(HaveField("Name", "db")))
+		Expect(getJob().Spec.Suspend).To(HaveValue(BeTrue()))
+
+		Expect(reconciler.Delete(ctx, pod)).To(Succeed())
+		message, err = reconciler.startSuspendedJobs(ctx, reconciler.Client, backup, repository, []batchv1.Job{*getJob()})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(BeEmpty())
+		Expect(getJob().Spec.Suspend).To(HaveValue(BeFalse()))
+		Expect(backup.Status.Hooks).To(ConsistOf(And(HaveField("Name", "scaleDown"), HaveField("Result", "Succeeded"))))
+	})
+
+	It("should fail the backup and scale the workload up if the pods do not terminate in time", func() {
+		database.Annotations = map[string]string{
+			scaledDownByAnnotation:       "db/resticbackup-db-1",
+			scaledDownReplicasAnnotation: "2",
+			scaledDownAtAnnotation:       time.Now().Add(-2 * time.Minute).UTC().Format(time.RFC3339),
+		}
+		database.Spec.Replicas = int32Ptr(0)
+		newReconciler(database, job, hookPod("db-0", corev1.PodRunning))
+
+		message, err := reconciler.startSuspendedJobs(ctx, reconciler.Client, backup, repository, []batchv1.Job{*job})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(BeEmpty())
+		Expect(apierrors.IsNotFound(reconciler.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{}))).To(BeTrue())
+		Expect(backup.Status.LastBackup.Result).To(Equal(backupResultFailed))
+		Expect(getDatabase().Spec.Replicas).To(HaveValue(BeEquivalentTo(2)))
+		Expect(getDatabase().Annotations).NotTo(HaveKey(scaledDownByAnnotation))
+		Expect(backup.Status.ScaledDownWorkload).To(BeNil())
+	})
+
+	It("should wait while the workload is scaled down for another backup job", func() {
+		database.Annotations = map[string]string{scaledDownByAnnotation: "db/resticbackup-db-0"}
+		newReconciler(database, job)
+
+		message, err := reconciler.startSuspendedJobs(ctx, reconciler.Client, backup, repository, []batchv1.Job{*job})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(ContainSubstring("scaled down for db/resticbackup-db-0"))
+		Expect(getDatabase().Spec.Replicas).To(HaveValue(BeEquivalentTo(2)))
+	})
+
+	Context("restoreScaledDownWorkload", func() {
+		BeforeEach(func() {
+			database.Annotations = map[string]string{
+				scaledDownByAnnotation:       "db/resticbackup-db-1",
+				scaledDownReplicasAnnotation: "2",
+			}
+			database.Spec.Replicas = int32Ptr(0)
+			backup.Status.ScaledDownWorkload = &backupv1alpha1.WorkloadReference{Kind: "StatefulSet", Name: "db"}
+		})
+
+		It("should keep the workload scaled down while the backup job runs", func() {
+			job.Spec.Suspend = boolPtr(false)
+			newReconciler(database, job)
+
+			Expect(reconciler.restoreScaledDownWorkload(ctx, reconciler.Client, backup, []batchv1.Job{*job})).To(Succeed())
+			Expect(getDatabase().Spec.Replicas).To(HaveValue(BeEquivalentTo(0)))
+			Expect(backup.Status.ScaledDownWorkload).NotTo(BeNil())
+		})
+
+		It("should scale the workload up after the backup job failed", func() {
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+			newReconciler(database, job)
+
+			Expect(reconciler.restoreScaledDownWorkload(ctx, reconciler.Client, backup, []batchv1.Job{*job})).To(Succeed())
+			Expect(getDatabase().Spec.Replicas).To(HaveValue(BeEquivalentTo(2)))
+			Expect(getDatabase().Annotations).To(BeEmpty())
+			Expect(backup.Status.ScaledDownWorkload).To(BeNil())
+			Expect(backup.Status.Hooks).To(ConsistOf(And(HaveField("Name", "scaleUp"), HaveField("Job", "resticbackup-db-1"))))
+		})
+
+		It("should scale the workload up when the backup is deleted", func() {
+			backup.Spec.Hooks = nil
+			newReconciler(database, job)
+
+			Expect(reconciler.restoreScaledDownWorkload(ctx, reconciler.Client, backup, nil)).To(Succeed())
+			Expect(getDatabase().Spec.Replicas).To(HaveValue(BeEquivalentTo(2)))
+		})
+
+		It("should leave workloads scaled down for other backups alone", func() {
+			database.Annotations[scaledDownByAnnotation] = "other/resticbackup-other-1"
+			newReconciler(database)
+
+			Expect(reconciler.restoreScaledDownWorkload(ctx, reconciler.Client, backup, nil)).To(Succeed())
+			Expect(getDatabase().Spec.Replicas).To(HaveValue(BeEquivalentTo(0)))
+			Expect(backup.Status.ScaledDownWorkload).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,341 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+const (
+	// sourceMountPath is where the source PVC is mounted in the backup container. The
+	// PVCs of a pvcs source are mounted in directories named after the claims below it.
+	sourceMountPath = "/backup"
+	// volumeLocationField indexes PVs by the location of their shared storage
+	volumeLocationField = "spec.volumeLocation"
+	// sourceClaimsField indexes ResticBackups by the names of their source PVCs
+	sourceClaimsField = "spec.source.claimNames"
+)
+
+// pvcSources returns the PVCs a backup reads: the pvc source or the pvcs source.
+func pvcSources(backup *backupv1alpha1.ResticBackup) []backupv1alpha1.PVCSource {
+	if backup.Spec.Source.PVC != nil {
+		return []backupv1alpha1.PVCSource{*backup.Spec.Source.PVC}
+	}
+	return backup.Spec.Source.PVCs
+}
+
+// sourceMountPathFor returns where a source PVC is mounted in the backup container.
+// The single PVC of a pvc source keeps the mount path of earlier snapshots.
+func sourceMountPathFor(backup *backupv1alpha1.ResticBackup, claimName string) string {
+	if backup.Spec.Source.PVC != nil {
+		return sourceMountPath
+	}
+	return path.Join(sourceMountPath, claimName)
+}
+
+// validatePVCSources checks that a backup does not use the pvc and pvcs sources
+// together or list a PVC twice, which would mount two volumes at the same path. The
+// paths of the sources are validated by the webhook.
+func validatePVCSources(backup *backupv1alpha1.ResticBackup) error {
+	if backup.Spec.Source.PVC != nil && len(backup.Spec.Source.PVCs) > 0 {
+		return errors.New("pvc and pvcs sources are mutually exclusive")
+	}
+	seen := map[string]bool{}
+	for _, source := range pvcSources(backup) {
+		if seen[source.ClaimName] {
+			return fmt.Errorf("PVC %s is listed twice", source.ClaimName)
+		}
+		seen[source.ClaimName] = true
+	}
+	return nil
+}
+
+// sourceExcludes returns the exclude patterns of all source PVCs.
+func sourceExcludes(backup *backupv1alpha1.ResticBackup) []string {
+	var excludes []string
+	for _, source := range pvcSources(backup) {
+		excludes = append(excludes, source.Excludes...)
+	}
+	return excludes
+}
+
+// backupSourcePaths returns the paths in the backup container the source PVCs back up.
+func backupSourcePaths(backup *backupv1alpha1.ResticBackup) []string {
+	var paths []string
+	for _, source := range pvcSources(backup) {
+		paths = append(paths, sourcePaths(sourceMountPathFor(backup, source.ClaimName), &source)...)
+	}
+	return paths
+}
+
+// sourceVolumes returns the read-only volumes and mounts of the source PVCs.
+func sourceVolumes(backup *backupv1alpha1.ResticBackup) ([]corev1.Volume, []corev1.VolumeMount) {
+	var volumes []corev1.Volume
+	var mounts []corev1.VolumeMount
+	for i, source := range pvcSources(backup) {
+		name := "backup-source"
+		if backup.Spec.Source.PVC == nil {
+			name = fmt.Sprintf("backup-source-%d", i)
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: source.ClaimName,
+					ReadOnly:  true,
+				},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: sourceMountPathFor(backup, source.ClaimName),
+			ReadOnly:  true,
+		})
+	}
+	return volumes, mounts
+}
+
+// sourcePaths returns the paths in the backup container a PVC mounted at mountPath
+// backs up. Without configured paths the whole volume is backed up.
+func sourcePaths(mountPath string, source *backupv1alpha1.PVCSource) []string {
+	if len(source.Paths) == 0 {
+		return []string{mountPath}
+	}
+
+	paths := make([]string, 0, len(source.Paths))
+	for _, p := range source.Paths {
+		paths = append(paths, path.Join(mountPath, cleanSourcePath(p)))
+	}
+	return paths
+}
+
+// cleanSourcePath returns the clean form of a source path, e.g. "/data" for "/data/".
+// The path is rooted before cleaning, so it can't escape the volume.
+func cleanSourcePath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// requiredSourcePaths returns the configured source paths the backup job checks for
+// before running restic.
+func requiredSourcePaths(backup *backupv1alpha1.ResticBackup) []string {
+	var paths []string
+	for _, source := range pvcSources(backup) {
+		if len(source.Paths) > 0 {
+			paths = append(paths, sourcePaths(sourceMountPathFor(backup, source.ClaimName), &source)...)
+		}
+	}
+	return paths
+}
+
+// pathContains reports whether child is parent or a path below it.
+func pathContains(parent, child string) bool {
+	return parent == "/" || child == parent || strings.HasPrefix(child, parent+"/")
+}
+
+// volumeLocation identifies the shared storage behind a PV and the directory of the
+// storage the PV exposes. Several PVs exporting the same NFS share or CSI volume, e.g.
+// one per namespace for a shared RWX volume, have the same location. Other PVs are
+// bound by a single PVC and can't be shared across namespaces, their location is empty.
+func volumeLocation(pv *corev1.PersistentVolume) (string, string) {
+	switch {
+	case pv.Spec.NFS != nil:
+		return "nfs:" + pv.Spec.NFS.Server, path.Clean("/" + pv.Spec.NFS.Path)
+	case pv.Spec.CSI != nil:
+		root := "/"
+		if subDir := pv.Spec.CSI.VolumeAttributes["subDir"]; subDir != "" {
+			root = path.Clean("/" + subDir)
+		}
+		return "csi:" + pv.Spec.CSI.Driver + ":" + pv.Spec.CSI.VolumeHandle, root
+	default:
+		return "", ""
+	}
+}
+
+// indexVolumeLocation indexes PVs by the location of their shared storage.
+func indexVolumeLocation(obj client.Object) []string {
+	pv, ok := obj.(*corev1.PersistentVolume)
+	if !ok {
+		return nil
+	}
+	if location, _ := volumeLocation(pv); location != "" {
+		return []string{location}
+	}
+	return nil
+}
+
+// indexSourceClaims indexes ResticBackups by the names of their source PVCs.
+func indexSourceClaims(obj client.Object) []string {
+	backup, ok := obj.(*backupv1alpha1.ResticBackup)
+	if !ok {
+		return nil
+	}
+	var claims []string
+	for _, source := range pvcSources(backup) {
+		claims = append(claims, source.ClaimName)
+	}
+	return claims
+}
+
+// locationPaths returns the paths on the storage location a source PVC on a PV
+// with the root directory root reads.
+func locationPaths(root string, source *backupv1alpha1.PVCSource) []string {
+	if len(source.Paths) == 0 {
+		return []string{root}
+	}
+	paths := make([]string, 0, len(source.Paths))
+	for _, p := range source.Paths {
+		paths = append(paths, path.Join(root, cleanSourcePath(p)))
+	}
+	return paths
+}
+
+// checkOverlappingBackups sets the OverlappingBackup condition if a ResticBackup in
+// another namespace backs up overlapping paths of the same volume, which stores the
+// data twice. Backups in the same namespace are left alone, they are owned by the
+// same team. The condition doesn't name the other backups, so it doesn't disclose the
+// backups of other namespaces.
+func (r *ResticBackupReconciler) checkOverlappingBackups(ctx context.Context, backup *backupv1alpha1.ResticBackup) error {
+	if len(pvcSources(backup)) == 0 {
+		conditions.RemoveCondition(&backup.Status.Conditions, backupv1alpha1.ConditionOverlappingBackup)
+		return nil
+	}
+
+	overlapping, err := r.overlapsOtherNamespace(ctx, backup)
+	if err != nil {
+		return err
+	}
+	if !overlapping {
+		r.setCondition(backup, metav1.Condition{
+			Type:    backupv1alpha1.ConditionOverlappingBackup,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoOverlap",
+			Message: "No backup in another namespace backs up the same paths of the volume",
+		})
+		return nil
+	}
+
+	message := "Backups in other namespaces back up overlapping paths of the same volume, the data is stored multiple times"
+	if !conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionOverlappingBackup) {
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "OverlappingBackup", message)
+	}
+	r.setCondition(backup, metav1.Condition{
+		Type:    backupv1alpha1.ConditionOverlappingBackup,
+		Status:  metav1.ConditionTrue,
+		Reason:  "OverlappingPaths",
+		Message: message,
+	})
+	return nil
+}
+
+// overlapsOtherNamespace reports whether a ResticBackup in another namespace backs up
+// overlapping paths of a volume the backup reads. Only the backups of the PVCs bound
+// to the PVs of the same storage location are compared, looked up by the
+// volumeLocationField and sourceClaimsField indexes.
+func (r *ResticBackupReconciler) overlapsOtherNamespace(ctx context.Context, backup *backupv1alpha1.ResticBackup) (bool, error) {
+	for _, source := range pvcSources(backup) {
+		location, paths, err := r.sourceLocation(ctx, backup.Namespace, &source)
+		if err != nil {
+			return false, err
+		}
+		if location == "" {
+			continue
+		}
+
+		pvs := &corev1.PersistentVolumeList{}
+		if err := r.List(ctx, pvs, client.MatchingFields{volumeLocationField: location}); err != nil {
+			return false, fmt.Errorf("failed to list PVs: %w", err)
+		}
+		for i := range pvs.Items {
+			pv := &pvs.Items[i]
+			claim := pv.Spec.ClaimRef
+			if claim == nil || claim.Namespace == backup.Namespace {
+				continue
+			}
+			_, root := volumeLocation(pv)
+
+			backups := &backupv1alpha1.ResticBackupList{}
+			if err := r.List(ctx, backups, client.InNamespace(claim.Namespace),
+				client.MatchingFields{sourceClaimsField: claim.Name}); err != nil {
+				return false, fmt.Errorf("failed to list backups: %w", err)
+			}
+			for j := range backups.Items {
+				for _, other := range pvcSources(&backups.Items[j]) {
+					if other.ClaimName == claim.Name && pathsOverlap(paths, locationPaths(root, &other)) {
+						return true, nil
+					}
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// sourceLocation returns the storage location and the paths on it a source PVC
+// backup reads. It returns an empty location if the PVC isn't bound yet or its PV
+// can't be shared.
+func (r *ResticBackupReconciler) sourceLocation(ctx context.Context, namespace string, source *backupv1alpha1.PVCSource) (string, []string, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	key := types.NamespacedName{Name: source.ClaimName, Namespace: namespace}
+	if err := r.Get(ctx, key, pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil, nil
+		}
+		return "", nil, fmt.Errorf("failed to get PVC %s/%s: %w", key.Namespace, key.Name, err)
+	}
+	if pvc.Spec.VolumeName == "" {
+		return "", nil, nil
+	}
+
+	pv := &corev1.PersistentVolume{}
+	if err := r.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, pv); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil, nil
+		}
+		return "", nil, fmt.Errorf("failed to get PV %s: %w", pvc.Spec.VolumeName, err)
+	}
+
+	location, root := volumeLocation(pv)
+	if location == "" {
+		return "", nil, nil
+	}
+	return location, locationPaths(root, source), nil
+}
+
+// pathsOverlap reports whether a path of one list is or contains a path of the other.
+func pathsOverlap(paths, others []string) bool {
+	for _, p := range paths {
+		for _, other := range others {
+			if pathContains(p, other) || pathContains(other, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+// sharedVolume returns a PVC bound to a PV exporting the NFS path.
+func sharedVolume(namespace, nfsPath string) (*corev1.PersistentVolumeClaim, *corev1.PersistentVolume) {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared-" + namespace},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				NFS: &corev1.NFSVolumeSource{Server: "nas.local", Path: nfsPath},
+			},
+			ClaimRef: &corev1.ObjectReference{Name: "shared", Namespace: namespace},
+		},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: namespace},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: pv.Name},
+	}
+	return pvc, pv
+}
+
+// sharedBackup returns a backup of the shared PVC in the namespace.
+func sharedBackup(namespace string, paths ...string) *backupv1alpha1.ResticBackup {
+	return &backupv1alpha1.ResticBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+		Spec: backupv1alpha1.ResticBackupSpec{
+			Source: backupv1alpha1.BackupSource{
+				PVC: &backupv1alpha1.PVCSource{ClaimName: "shared", Paths: paths},
+			},
+		},
+	}
+}
+
+var _ = Describe("Source paths", func() {
+	It("should clean the paths and keep them in the volume", func() {
+		backup := sharedBackup("media", "/media/", "/../etc")
+		Expect(backupSourcePaths(backup)).To(Equal([]string{"/backup/media", "/backup/etc"}))
+	})
+
+	It("should fail the backup job if a path doesn't exist", func() {
+		backup := sharedBackup("media", "/media", "/it's")
+		script := backupScript{required: requiredSourcePaths(backup), backup: []string{"restic", "backup"}}.build()
+		Expect(script).To(ContainSubstring("[ -e '/backup/media' ] || { echo 'Source path does not exist:' '/backup/media' >&2; exit 1; }"))
+		Expect(script).To(ContainSubstring(`[ -e '/backup/it'\''s' ]`))
+
+		Expect(requiredSourcePaths(sharedBackup("media"))).To(BeEmpty())
+	})
+
+	It("should resolve the location of NFS and CSI volumes", func() {
+		_, pv := sharedVolume("media", "/exports/shared/")
+		location, root := volumeLocation(pv)
+		Expect(location).To(Equal("nfs:nas.local"))
+		Expect(root).To(Equal("/exports/shared"))
+
+		pv.Spec.NFS = nil
+		pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{
+			Driver:           "nfs.csi.k8s.io",
+			VolumeHandle:     "nas.local#exports#shared",
+			VolumeAttributes: map[string]string{"subDir": "media"},
+		}
+		location, root = volumeLocation(pv)
+		Expect(location).To(Equal("csi:nfs.csi.k8s.io:nas.local#exports#shared"))
+		Expect(root).To(Equal("/media"))
+
+		// Other PVs are bound by a single PVC and can't be shared
+		pv.Spec.CSI = nil
+		pv.Spec.HostPath = &corev1.HostPathVolumeSource{Path: "/srv/media"}
+		location, _ = volumeLocation(pv)
+		Expect(location).To(BeEmpty())
+	})
+
+	Context("several PVCs", func() {
+		var backup *backupv1alpha1.ResticBackup
+
+		BeforeEach(func() {
+			backup = &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					Source: backupv1alpha1.BackupSource{
+						PVCs: []backupv1alpha1.PVCSource{
+							{ClaimName: "config", Excludes: []string{"*.log"}},
+							{ClaimName: "data", Paths: []string{"/library"}, Excludes: []string{"cache"}},
+						},
+					},
+					Restic: &backupv1alpha1.ResticConfig{Hostname: "{{ .Name }}-{{ .PVC }}"},
+				},
+			}
+		})
+
+		It("should mount each PVC below the source mount path", func() {
+			volumes, mounts := sourceVolumes(backup)
+			Expect(volumes).To(HaveLen(2))
+			Expect(volumes[0].Name).To(Equal("backup-source-0"))
+			Expect(volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("config"))
+			Expect(volumes[1].PersistentVolumeClaim.ClaimName).To(Equal("data"))
+			Expect(volumes[1].PersistentVolumeClaim.ReadOnly).To(BeTrue())
+			Expect(mounts[0].MountPath).To(Equal("/backup/config"))
+			Expect(mounts[1].MountPath).To(Equal("/backup/data"))
+			Expect(mounts[1].ReadOnly).To(BeTrue())
+
+			Expect(requiredSourcePaths(backup)).To(Equal([]string{"/backup/data/library"}))
+		})
+
+		It("should back up all PVCs into one snapshot", func() {
+			hostname, err := renderHostname(backup)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hostname).To(Equal("app-config-data"))
+
+			cmd := (&ResticBackupReconciler{}).buildBackupCommand(backup, hostname, nil)
+			Expect(cmd).To(Equal([]string{
+				"restic", "backup", "--json", "--host", "app-config-data",
+				"--exclude", "*.log", "--exclude", "cache",
+				"/backup/config", "/backup/data/library",
+			}))
+		})
+
+		It("should keep the mount path of a single PVC", func() {
+			volumes, mounts := sourceVolumes(sharedBackup("media"))
+			Expect(volumes).To(HaveLen(1))
+			Expect(volumes[0].Name).To(Equal("backup-source"))
+			Expect(mounts[0].MountPath).To(Equal(sourceMountPath))
+		})
+
+		It("should reject invalid PVC lists", func() {
+			Expect(validatePVCSources(backup)).To(Succeed())
+
+			backup.Spec.Source.PVCs[1] = backup.Spec.Source.PVCs[0]
+			Expect(validatePVCSources(backup)).To(MatchError(ContainSubstring("listed twice")))
+
+			backup.Spec.Source.PVCs = backup.Spec.Source.PVCs[:1]
+			backup.Spec.Source.PVC = &backupv1alpha1.PVCSource{ClaimName: "data"}
+			Expect(validatePVCSources(backup)).To(MatchError(ContainSubstring("mutually exclusive")))
+		})
+	})
+
+	Context("overlapping backups", func() {
+		var (
+			recorder   *record.FakeRecorder
+			reconciler *ResticBackupReconciler
+			backup     *backupv1alpha1.ResticBackup
+		)
+
+		newReconciler := func(objects ...client.Object) {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			recorder = record.NewFakeRecorder(10)
+			reconciler = &ResticBackupReconciler{
+				Client: fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).
+					WithIndex(&corev1.PersistentVolume{}, volumeLocationField, indexVolumeLocation).
+					WithIndex(&backupv1alpha1.ResticBackup{}, sourceClaimsField, indexSourceClaims).
+					Build(),
+				Recorder: recorder,
+			}
+		}
+
+		BeforeEach(func() {
+			backup = sharedBackup("media", "/media")
+		})
+
+		It("should warn about backups in other namespaces of the same paths", func() {
+			mediaPVC, mediaPV := sharedVolume("media", "/exports/shared")
+			photosPVC, photosPV := sharedVolume("photos", "/exports/shared")
+			newReconciler(backup, mediaPVC, mediaPV, photosPVC, photosPV, sharedBackup("photos"))
+
+			Expect(reconciler.checkOverlappingBackups(context.Background(), backup)).To(Succeed())
+			condition := conditions.GetCondition(backup.Status.Conditions, backupv1alpha1.ConditionOverlappingBackup)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Message).NotTo(ContainSubstring("photos"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("OverlappingBackup")))
+
+			// The warning event is only emitted once
+			Expect(reconciler.checkOverlappingBackups(context.Background(), backup)).To(Succeed())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should detect overlaps of PVs exporting nested directories", func() {
+			mediaPVC, mediaPV := sharedVolume("media", "/exports/shared")
+			photosPVC, photosPV := sharedVolume("photos", "/exports/shared/media/thumbnails")
+			newReconciler(backup, mediaPVC, mediaPV, photosPVC, photosPV, sharedBackup("photos"))
+
+			Expect(reconciler.checkOverlappingBackups(context.Background(), backup)).To(Succeed())
+			Expect(conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionOverlappingBackup)).To(BeTrue())
+		})
+
+		It("should detect overlaps with backups of several PVCs", func() {
+			mediaPVC, mediaPV := sharedVolume("media", "/exports/shared")
+			photosPVC, photosPV := sharedVolume("photos", "/exports/shared")
+			photos := sharedBackup("photos")
+			photos.Spec.Source.PVC = nil
+			photos.Spec.Source.PVCs = []backupv1alpha1.PVCSource{{ClaimName: "config"}, {ClaimName: "shared", Paths: []string{"/media"}}}
+			newReconciler(backup, mediaPVC, mediaPV, photosPVC, photosPV, photos)
+
+			Expect(reconciler.checkOverlappingBackups(context.Background(), backup)).To(Succeed())
+			Expect(conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionOverlappingBackup)).To(BeTrue())
+		})
+
+		It("should accept backups of disjoint paths", func() {
+			mediaPVC, mediaPV := sharedVolume("media", "/exports/shared")
+			photosPVC, photosPV := sharedVolume("photos", "/exports/shared")
+			newReconciler(backup, mediaPVC, mediaPV, photosPVC, photosPV, sharedBackup("photos", "/photos"), sharedBackup("media-other"))
+
+			Expect(reconciler.checkOverlappingBackups(context.Background(), backup)).To(Succeed())
+			Expect(conditions.IsConditionFalse(backup.Status.Conditions, backupv1alpha1.ConditionOverlappingBackup)).To(BeTrue())
+		})
+	})
+})
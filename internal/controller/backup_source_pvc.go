@@ -0,0 +1,142 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// updateSourcePVC records the storage class, capacity, access modes and metadata of the
+// source PVC in the status. A missing PVC keeps the last recorded values, as the backup job
+// reports the error.
+func (r *ResticBackupReconciler) updateSourcePVC(ctx context.Context, backup *backupv1alpha1.ResticBackup) error {
+	if backup.Spec.Source.PVC == nil {
+		backup.Status.SourcePVC = nil
+		return nil
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	key := types.NamespacedName{Name: backup.Spec.Source.PVC.ClaimName, Namespace: backup.Namespace}
+	if err := r.Get(ctx, key, pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.FromContext(ctx).Info("Source PVC not found", "pvc", key.Name)
+			return nil
+		}
+		return fmt.Errorf("failed to get source PVC: %w", err)
+	}
+
+	backup.Status.SourcePVC = sourcePVCStatus(pvc)
+	return nil
+}
+
+// sourcePVCStatus describes the PVC. The capacity is taken from the bound volume,
+// falling back to the requested size.
+func sourcePVCStatus(pvc *corev1.PersistentVolumeClaim) *backupv1alpha1.SourcePVCStatus {
+	status := &backupv1alpha1.SourcePVCStatus{ClaimName: pvc.Name}
+	if pvc.Spec.StorageClassName != nil {
+		status.StorageClassName = *pvc.Spec.StorageClassName
+	}
+
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		status.Capacity = capacity.String()
+	} else if request, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+		status.Capacity = request.String()
+	}
+
+	for _, mode := range pvc.Spec.AccessModes {
+		status.AccessModes = append(status.AccessModes, string(mode))
+	}
+	status.Labels = pvcMetadata(pvc.Labels)
+	status.Annotations = pvcMetadata(pvc.Annotations)
+	return status
+}
+
+// systemPVCMetadataPrefixes are the prefixes of the labels and annotations set by
+// Kubernetes and the operator. They describe the PVC in this cluster and are neither
+// recorded nor copied to restored PVCs.
+var systemPVCMetadataPrefixes = []string{
+	"backup.resticbackup.io/",
+	"pv.kubernetes.io/",
+	"volume.kubernetes.io/",
+	"volume.beta.kubernetes.io/",
+	"kubectl.kubernetes.io/",
+}
+
+// pvcMetadata returns the labels or annotations of a PVC without the system ones.
+func pvcMetadata(metadata map[string]string) map[string]string {
+	var result map[string]string
+	for key, value := range metadata {
+		if isSystemPVCMetadata(key) {
+			continue
+		}
+		if result == nil {
+			result = map[string]string{}
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// isSystemPVCMetadata reports whether the label or annotation key is set by Kubernetes
+// or the operator.
+func isSystemPVCMetadata(key string) bool {
+	for _, prefix := range systemPVCMetadataPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourcePVCTags returns the snapshot tags recording the source PVC, so an equivalent
+// PVC can be created from the repository alone. Tags are separated by commas, so the
+// annotations, whose values may contain them, are only recorded in the status.
+func sourcePVCTags(pvc *backupv1alpha1.SourcePVCStatus) []string {
+	if pvc == nil {
+		return nil
+	}
+
+	var tags []string
+	if pvc.StorageClassName != "" {
+		tags = append(tags, fmt.Sprintf("pvc-storage-class=%s", pvc.StorageClassName))
+	}
+	if pvc.Capacity != "" {
+		tags = append(tags, fmt.Sprintf("pvc-capacity=%s", pvc.Capacity))
+	}
+	for _, mode := range pvc.AccessModes {
+		tags = append(tags, fmt.Sprintf("pvc-access-mode=%s", mode))
+	}
+	keys := make([]string, 0, len(pvc.Labels))
+	for key := range pvc.Labels {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		tags = append(tags, fmt.Sprintf("pvc-label=%s=%s", key, pvc.Labels[key]))
+	}
+	return tags
+}
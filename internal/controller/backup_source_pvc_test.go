@@ -0,0 +1,106 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Source PVC", func() {
+	storageClass := "longhorn"
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "data",
+			Namespace: "media",
+			Labels:    map[string]string{"app": "jellyfin", "tier": "media", annotatedPVCLabel: "true"},
+			Annotations: map[string]string{
+				"example.com/owner":                        "team-a",
+				"pv.kubernetes.io/bind-completed":          "yes",
+				"volume.kubernetes.io/storage-provisioner": "driver.longhorn.io",
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass,
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("8Gi")},
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+		},
+	}
+
+	It("should prefer the capacity of the bound volume", func() {
+		Expect(sourcePVCStatus(pvc)).To(Equal(&backupv1alpha1.SourcePVCStatus{
+			ClaimName:        "data",
+			StorageClassName: "longhorn",
+			Capacity:         "10Gi",
+			AccessModes:      []string{"ReadWriteOnce"},
+			Labels:           map[string]string{"app": "jellyfin", "tier": "media"},
+			Annotations:      map[string]string{"example.com/owner": "team-a"},
+		}))
+
+		pending := pvc.DeepCopy()
+		pending.Status.Capacity = nil
+		Expect(sourcePVCStatus(pending).Capacity).To(Equal("8Gi"))
+	})
+
+	It("should record the PVC as snapshot tags", func() {
+		Expect(sourcePVCTags(sourcePVCStatus(pvc))).To(Equal([]string{
+			"pvc-storage-class=longhorn",
+			"pvc-capacity=10Gi",
+			"pvc-access-mode=ReadWriteOnce",
+			"pvc-label=app=jellyfin",
+			"pvc-label=tier=media",
+		}))
+		Expect(sourcePVCTags(nil)).To(BeEmpty())
+	})
+
+	It("should record the source PVC of PVC backups only", func() {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		reconciler := &ResticBackupReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).WithObjects(pvc.DeepCopy()).Build(),
+		}
+		backup := &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Source: backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		}
+
+		Expect(reconciler.updateSourcePVC(context.Background(), backup)).To(Succeed())
+		Expect(backup.Status.SourcePVC).NotTo(BeNil())
+		Expect(backup.Status.SourcePVC.StorageClassName).To(Equal("longhorn"))
+
+		backup.Spec.Source = backupv1alpha1.BackupSource{Database: &backupv1alpha1.DatabaseSource{}}
+		Expect(reconciler.updateSourcePVC(context.Background(), backup)).To(Succeed())
+		Expect(backup.Status.SourcePVC).To(BeNil())
+	})
+})
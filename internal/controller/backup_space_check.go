@@ -0,0 +1,160 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+// spaceCheckSummaryType is the message type of the termination message line written
+// by a backup job that found too little free space on the repository backend.
+const spaceCheckSummaryType = "backend_full"
+
+// spaceCheckSummary is the termination message line of a failed space check.
+type spaceCheckSummary struct {
+	MessageType  string `json:"message_type"`
+	FreeBytes    int64  `json:"free_bytes"`
+	MinFreeBytes int64  `json:"min_free_bytes"`
+}
+
+// buildSpaceCheckCommands returns the shell commands checking the free space of the
+// repository backend before the backup. The job fails with a space check summary in
+// the termination message if there is too little. If the free space can't be probed,
+// the backup runs anyway. It returns nil if the repository has no space check or its
+// backend can't be probed.
+func buildSpaceCheckCommands(repository *backupv1alpha1.ResticRepository) []string {
+	check := repository.Spec.SpaceCheck
+	if check == nil {
+		return nil
+	}
+
+	probe := spaceProbe(repository)
+	if probe == "" {
+		return nil
+	}
+
+	minFree := check.MinFreeSpace.Value()
+	minFreeKiB := (minFree + 1023) / 1024
+	return []string{
+		fmt.Sprintf("free=$(%s) || free=", probe),
+		"if [ -z \"$free\" ]; then echo 'Failed to determine the free space of the repository backend' >&2",
+		fmt.Sprintf("elif [ \"$free\" -lt %d ]; then "+
+			`echo '{"message_type":"%s","free_bytes":'$((free * 1024))',"min_free_bytes":%d}' > /dev/termination-log; `+
+			`echo "Repository backend has $((free / 1024)) MiB free, %s required" >&2; exit 1; fi`,
+			minFreeKiB, spaceCheckSummaryType, minFree, check.MinFreeSpace.String()),
+	}
+}
+
+// spaceProbe returns the shell command printing the free space of the repository
+// backend in KiB. With a capacity it is computed from the size of the repository
+// data, otherwise the file system of local and sftp backends is queried.
+func spaceProbe(repository *backupv1alpha1.ResticRepository) string {
+	options := repositoryOptions(repository)
+
+	if capacity := repository.Spec.SpaceCheck.Capacity; capacity != nil {
+		stats := append([]string{"restic", "stats", "--mode", "raw-data", "--json"}, options...)
+		return fmt.Sprintf(`size=$(%s | grep -o '"total_size":[0-9]*' | cut -d: -f2) && [ -n "$size" ] && echo $(((%d - size) / 1024))`,
+			shellQuoteArgs(stats), capacity.Value())
+	}
+
+	_, location, _ := strings.Cut(repository.Spec.RepositoryURL, ":")
+	switch repositoryScheme(repository) {
+	case "local":
+		return fmt.Sprintf("df -Pk %s | awk 'NR == 2 { print $4 }'", shellQuoteArgs([]string{location}))
+	case "sftp":
+		host, port, dir := parseSFTPLocation(location)
+		sftp := []string{"sftp", "-b", "-",
+			"-i", path.Join(credentialsMountPath, sshPrivateKeyFile),
+			"-o", "UserKnownHostsFile=" + path.Join(credentialsMountPath, sshKnownHostsFile)}
+		if port != "" {
+			sftp = append(sftp, "-P", port)
+		}
+		sftp = append(sftp, host)
+		// sftp df prints the size, used and available space in KiB
+		return fmt.Sprintf("echo %s | %s | awk '$1 ~ /^[0-9]+$/ { print $3; exit }'",
+			shellQuoteArgs([]string{`df "` + dir + `"`}), shellQuoteArgs(sftp))
+	}
+	return ""
+}
+
+// parseSFTPLocation splits the location of an sftp repository URL, either
+// "user@host:/path" or "//user@host:port//path", into the host, port and path.
+func parseSFTPLocation(location string) (host, port, dir string) {
+	if strings.HasPrefix(location, "//") {
+		u, err := url.Parse("sftp:" + location)
+		if err != nil {
+			return "", "", ""
+		}
+		host = u.Hostname()
+		if u.User != nil {
+			host = u.User.Username() + "@" + host
+		}
+		dir = strings.TrimPrefix(u.Path, "/")
+		if dir == "" {
+			dir = "."
+		}
+		return host, u.Port(), dir
+	}
+
+	host, dir, _ = strings.Cut(location, ":")
+	if dir == "" {
+		dir = "."
+	}
+	return host, "", dir
+}
+
+// parseSpaceCheckSummary returns the failed space check of a backup termination message.
+func parseSpaceCheckSummary(message string) (*spaceCheckSummary, bool) {
+	for _, line := range strings.Split(message, "\n") {
+		var summary spaceCheckSummary
+		if err := json.Unmarshal([]byte(line), &summary); err != nil {
+			continue
+		}
+		if summary.MessageType == spaceCheckSummaryType {
+			return &summary, true
+		}
+	}
+	return nil, false
+}
+
+// setBackendFull sets the BackendFull condition for a failed space check, or clears it
+// after a successful backup if it was set.
+func setBackendFull(list *[]metav1.Condition, summary *spaceCheckSummary) {
+	if summary == nil {
+		if conditions.IsConditionTrue(*list, backupv1alpha1.ConditionBackendFull) {
+			conditions.SetCondition(list, conditions.NewCondition(backupv1alpha1.ConditionBackendFull,
+				metav1.ConditionFalse, "SpaceAvailable", "Repository backend has enough free space"))
+		}
+		return
+	}
+	conditions.SetCondition(list, conditions.NewCondition(backupv1alpha1.ConditionBackendFull,
+		metav1.ConditionTrue, "InsufficientSpace", backendFullMessage(summary)))
+}
+
+func backendFullMessage(summary *spaceCheckSummary) string {
+	return fmt.Sprintf("Repository backend has %s free, %s required",
+		formatBytes(uint64(max(summary.FreeBytes, 0))), formatBytes(uint64(summary.MinFreeBytes)))
+}
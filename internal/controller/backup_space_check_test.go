@@ -0,0 +1,98 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("Backup space check", func() {
+	var repository *backupv1alpha1.ResticRepository
+
+	BeforeEach(func() {
+		repository = &backupv1alpha1.ResticRepository{
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL: "local:/srv/restic",
+				SpaceCheck:    &backupv1alpha1.SpaceCheckConfig{MinFreeSpace: resource.MustParse("10Gi")},
+			},
+		}
+	})
+
+	It("should fail the backup if the backend has too little free space", func() {
+		commands := buildSpaceCheckCommands(repository)
+		Expect(commands).To(HaveLen(3))
+		Expect(commands[0]).To(Equal("free=$(df -Pk '/srv/restic' | awk 'NR == 2 { print $4 }') || free="))
+		Expect(commands[2]).To(HavePrefix(`elif [ "$free" -lt 10485760 ]; then echo '{"message_type":"backend_full","free_bytes":'$((free * 1024))',"min_free_bytes":10737418240}'`))
+		Expect(commands[2]).To(HaveSuffix("exit 1; fi"))
+
+		script := backupScript{spaceCheck: commands, backup: []string{"restic", "backup"}}.build()
+		Expect(script).To(ContainSubstring(commands[2] + "\nmkfifo"))
+	})
+
+	It("should query the free space of sftp backends", func() {
+		repository.Spec.RepositoryURL = "sftp://backup@nas:2222//srv/restic"
+		Expect(spaceProbe(repository)).To(Equal(`echo 'df "/srv/restic"' | 'sftp' '-b' '-' '-i' '/etc/restic/credentials/id_ssh' ` +
+			`'-o' 'UserKnownHostsFile=/etc/restic/credentials/known_hosts' '-P' '2222' 'backup@nas' | awk '$1 ~ /^[0-9]+$/ { print $3; exit }'`))
+	})
+
+	It("should parse sftp repository locations", func() {
+		host, port, dir := parseSFTPLocation("backup@nas:/srv/restic")
+		Expect([]string{host, port, dir}).To(Equal([]string{"backup@nas", "", "/srv/restic"}))
+
+		host, port, dir = parseSFTPLocation("//nas/restic")
+		Expect([]string{host, port, dir}).To(Equal([]string{"nas", "", "restic"}))
+	})
+
+	It("should compute the free space from the capacity", func() {
+		capacity := resource.MustParse("1Ti")
+		repository.Spec.RepositoryURL = "rest:https://restic.example.com/repo"
+		repository.Spec.SpaceCheck.Capacity = &capacity
+		Expect(spaceProbe(repository)).To(Equal(`size=$('restic' 'stats' '--mode' 'raw-data' '--json' | grep -o '"total_size":[0-9]*' | cut -d: -f2) ` +
+			`&& [ -n "$size" ] && echo $(((1099511627776 - size) / 1024))`))
+	})
+
+	It("should skip backends whose free space can't be queried", func() {
+		repository.Spec.RepositoryURL = "s3:s3.amazonaws.com/bucket"
+		Expect(buildSpaceCheckCommands(repository)).To(BeNil())
+
+		repository.Spec.SpaceCheck = nil
+		Expect(buildSpaceCheckCommands(repository)).To(BeNil())
+	})
+
+	It("should set the BackendFull condition from the termination message", func() {
+		summary, ok := parseSpaceCheckSummary(`{"message_type":"backend_full","free_bytes":1048576,"min_free_bytes":10737418240}` + "\n")
+		Expect(ok).To(BeTrue())
+
+		var list []metav1.Condition
+		setBackendFull(&list, summary)
+		condition := conditions.GetCondition(list, backupv1alpha1.ConditionBackendFull)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("Repository backend has 1.0 MiB free, 10.0 GiB required"))
+
+		setBackendFull(&list, nil)
+		Expect(conditions.IsConditionFalse(list, backupv1alpha1.ConditionBackendFull)).To(BeTrue())
+
+		_, ok = parseSpaceCheckSummary(`{"message_type":"summary","snapshot_id":"abc"}`)
+		Expect(ok).To(BeFalse())
+	})
+})
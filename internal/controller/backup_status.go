@@ -0,0 +1,376 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// Results of a backup run as reported in BackupRunStatus.Result.
+const (
+	backupResultSucceeded       = "Succeeded"
+	backupResultFailed          = "Failed"
+	backupResultPartiallyFailed = "PartiallyFailed"
+)
+
+// backupSummaryPattern matches the JSON summary line of restic backup.
+const backupSummaryPattern = `"message_type":"summary"`
+
+// maxDataAddedHistory is the number of backup runs kept in the data-added history.
+const maxDataAddedHistory = 48
+
+// backupScript holds the commands run by the backup container.
+type backupScript struct {
+	// required are the source paths that must exist.
+	required []string
+	// identityCheck, if set, are the shell commands checking that the backend serves
+	// the expected repository before the backup.
+	identityCheck []string
+	// unlock, if set, removes stale locks before the backup.
+	unlock []string
+	// spaceCheck, if set, are the shell commands checking the free space of the
+	// repository backend before the backup.
+	spaceCheck []string
+	// backup is the restic backup command.
+	backup []string
+	// forget, if set, applies the retention after a successful backup.
+	forget []string
+	// count, if set, lists the snapshots left after retention.
+	count []string
+}
+
+// interruptibleRunner is the shell preamble running restic in the background, so the
+// shell, which is PID 1 of the container and ignores signals without a handler, can
+// forward SIGTERM of a pod termination as SIGINT. restic then exits cleanly and
+// releases its repository lock instead of being killed after the grace period.
+var interruptibleRunner = []string{
+	"pid=",
+	`trap '[ -n "$pid" ] && kill -INT $pid 2>/dev/null' TERM INT`,
+	`run() { "$@" & pid=$!; wait $pid; status=$?; while kill -0 $pid 2>/dev/null; do wait $pid; status=$?; done; pid=; return $status; }`,
+}
+
+// build builds the shell script run by the backup container. Only the JSON summary of
+// restic reaches the pod log, its status messages are dropped. The summary is also
+// written to the termination message so the operator can record the backup
+// statistics without access to pod logs. The forget command runs only after a
+// successful backup and fails the job if it fails. After it, the count command lists
+// the remaining snapshots and their number is appended to the termination message.
+// The job fails before running restic if one of the required paths doesn't exist, the
+// backend serves another repository or the repository backend has too little free space.
+func (s backupScript) build() string {
+	commands := append([]string{"set -o pipefail"}, interruptibleRunner...)
+	for _, p := range s.required {
+		quoted := shellQuoteArgs([]string{p})
+		commands = append(commands, fmt.Sprintf("[ -e %s ] || { echo 'Source path does not exist:' %s >&2; exit 1; }", quoted, quoted))
+	}
+	commands = append(commands, s.identityCheck...)
+	if len(s.unlock) > 0 {
+		commands = append(commands, fmt.Sprintf("run %s || echo 'Failed to remove stale locks' >&2", shellQuoteArgs(s.unlock)))
+	}
+	commands = append(commands, s.spaceCheck...)
+	commands = append(commands,
+		"mkfifo /tmp/backup.fifo",
+		fmt.Sprintf("grep '%s' < /tmp/backup.fifo | tee /tmp/backup.log &", backupSummaryPattern),
+		fmt.Sprintf("run %s > /tmp/backup.fifo", shellQuoteArgs(s.backup)),
+		"rc=$?",
+		"wait",
+		fmt.Sprintf("grep '%s' /tmp/backup.log | tail -n 1 > /dev/termination-log || true", backupSummaryPattern),
+	)
+	if len(s.forget) > 0 {
+		commands = append(commands, fmt.Sprintf("if [ $rc -eq 0 ]; then run %s || rc=$?; fi", shellQuoteArgs(s.forget)))
+		if len(s.count) > 0 {
+			commands = append(commands, fmt.Sprintf(
+				`if [ $rc -eq 0 ] && n=$(%s | grep -o '"id":' | wc -l); then echo '{"message_type":"%s","snapshots_after":'$n'}' >> /dev/termination-log; fi`,
+				shellQuoteArgs(s.count), retentionSummaryType))
+		}
+	}
+	commands = append(commands, "exit $rc")
+
+	return strings.Join(commands, "\n")
+}
+
+// updateBackupStatus scales up workloads scaled down for finished backup jobs, starts
+// backup jobs waiting for the workload to scale down, the pre-backup hook, the repository
+// or a free backup slot and records all backup jobs that finished after the last recorded backup in LastBackup,
+// LastSuccessfulBackup and Statistics. Jobs are recorded in the order they finished, so
+// runs between two reconciles are counted as well. The postBackup or onFailure hook
+// runs and a notification is sent for every newly finished job. The end of the log of a
+// failed job is recorded in LastBackup and attached to its event, and a retry of the job
+// is scheduled.
+func (r *ResticBackupReconciler) updateBackupStatus(ctx context.Context, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) error {
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs,
+		client.InNamespace(backup.Namespace),
+		client.MatchingLabels{resticBackupLabel: backup.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list backup jobs: %w", err)
+	}
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+
+	// Scale the workload up again once the backup job it was scaled down for is done
+	if err := r.restoreScaledDownWorkload(ctx, reader, backup, jobs.Items); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to scale up workload")
+	}
+
+	// Backups of an intermittent backend wait until it is reachable, backups of a
+	// coordinated repository while a prune or retention job holds it
+	waiting := ""
+	if backendOffline(repository) && slices.ContainsFunc(jobs.Items, jobWaitingToStart) {
+		waiting = fmt.Sprintf("Backend of repository %s/%s is offline, waiting for a successful connectivity probe",
+			repository.Namespace, repository.Name)
+	}
+	if waiting == "" && repository.Spec.CoordinateJobs && slices.ContainsFunc(jobs.Items, jobWaitingToStart) {
+		holder, err := repositoryLeaseHolder(ctx, reader, repository)
+		if err != nil {
+			return err
+		}
+		if holder != "" {
+			waiting = fmt.Sprintf("Repository %s/%s is used by %s", repository.Namespace, repository.Name, holder)
+		}
+	}
+	if waiting == "" {
+		message, err := r.startSuspendedJobs(ctx, reader, backup, repository, jobs.Items)
+		if err != nil {
+			return err
+		}
+		waiting = message
+	}
+	setWaitingForRepository(&backup.Status.Conditions, waiting)
+
+	var since time.Time
+	if backup.Status.LastBackup != nil && backup.Status.LastBackup.CompletionTime != nil {
+		since = backup.Status.LastBackup.CompletionTime.Time
+	}
+
+	for _, job := range finishedJobsSince(jobs.Items, since) {
+		_, succeeded, finishedAt := jobFinished(&job)
+		succeeded, err := r.runCompletionHooks(ctx, reader, backup, &job, succeeded)
+		if err != nil {
+			return err
+		}
+
+		var summary *restic.BackupResult
+		message, err := jobTerminationMessage(ctx, reader, &job)
+		if err != nil {
+			// The pod may already be gone, record the run without statistics
+			log.FromContext(ctx).Error(err, "Failed to read backup summary", "job", job.Name)
+		} else if summary, err = restic.ParseBackupSummary(message); err != nil {
+			summary = nil
+		}
+
+		result := recordBackupRun(&backup.Status, &job, succeeded, finishedAt, summary)
+		var logs string
+		autoTuned := false
+		if result != backupResultSucceeded {
+			if logs, err = jobFailureLogs(ctx, reader, r.PodLogs, &job); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to read backup job logs", "job", job.Name)
+			}
+			backup.Status.LastBackup.FailureMessage = logs
+			if logs == "" {
+				backup.Status.LastBackup.FailureMessage = backupFailureMessage(&job, result, summary)
+			}
+			if oomKilled, err := jobOOMKilled(ctx, reader, &job); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to check backup job for OOM kills", "job", job.Name)
+			} else if oomKilled {
+				autoTuned = backup.Spec.AutoTuneResources != nil
+				if err := r.recordOOMKill(ctx, backup, repository, &job, finishedAt); err != nil {
+					log.FromContext(ctx).Error(err, "Failed to retry OOMKilled backup", "job", job.Name)
+				}
+			}
+		}
+		if autoTuned {
+			// autoTuneResources retries the backup with a raised memory limit
+			backup.Status.Retry = nil
+		} else {
+			r.scheduleRetry(backup, &job, result, finishedAt)
+		}
+		if full, ok := parseSpaceCheckSummary(message); ok {
+			setBackendFull(&backup.Status.Conditions, full)
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "BackendFull", fmt.Sprintf("Backup job %s failed: %s", job.Name, backendFullMessage(full)))
+		} else if result == backupResultSucceeded {
+			setBackendFull(&backup.Status.Conditions, nil)
+		}
+		if snapshots, ok := parseRetentionSummary(message); ok && result == backupResultSucceeded {
+			backup.Status.SnapshotsAfterRetention = snapshots
+			if retention := effectiveRetention(backup, repository); retention != nil && retention.Prune {
+				// The next backup requests the prune again
+				if err := r.requestRetentionPrune(ctx, reader, backup, repository); err != nil {
+					log.FromContext(ctx).Error(err, "Failed to request prune after retention", "job", job.Name)
+				}
+			}
+		}
+		switch result {
+		case backupResultSucceeded:
+			r.Recorder.Event(backup, corev1.EventTypeNormal, "BackupSucceeded",
+				fmt.Sprintf("Backup job %s created snapshot %s", job.Name, backup.Status.LastBackup.SnapshotID))
+		case backupResultPartiallyFailed:
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupPartiallyFailed", withJobLogs(
+				fmt.Sprintf("Backup job %s created snapshot %s, but did not complete successfully", job.Name, backup.Status.LastBackup.SnapshotID), logs))
+		default:
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupFailed", withJobLogs(fmt.Sprintf("Backup job %s failed", job.Name), logs))
+		}
+		if err := r.notifyBackupRun(ctx, backup, &job, result, finishedAt, summary); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runCompletionHooks runs the postBackup hook after a successful backup job and the
+// onFailure hook after a failed one. It returns whether the backup is successful, which
+// is no longer the case if the postBackup hook failed with onError Fail. The hooks are
+// recorded on the Job before they run, so they run once per Job even if the status
+// update fails afterwards. A postBackup hook without recorded result counts as failed.
+func (r *ResticBackupReconciler) runCompletionHooks(ctx context.Context, reader client.Reader, backup *backupv1alpha1.ResticBackup, job *batchv1.Job, succeeded bool) (bool, error) {
+	hooks := backup.Spec.Hooks
+	if hooks == nil || (succeeded && hooks.PostBackup == nil) || (!succeeded && hooks.OnFailure == nil) {
+		return succeeded, nil
+	}
+	if result, ran := job.Annotations[completionHooksAnnotation]; ran {
+		return succeeded && result == backupResultSucceeded, nil
+	}
+	if err := annotateJob(ctx, r.Client, job, completionHooksAnnotation, completionHooksRunning); err != nil {
+		return succeeded, err
+	}
+
+	if succeeded {
+		result := backupResultSucceeded
+		if err := r.runHook(ctx, reader, backup, postBackupHook, hooks.PostBackup, job.Name); err != nil && hookFailsBackup(hooks.PostBackup) {
+			succeeded = false
+			result = backupResultFailed
+		}
+		if err := annotateJob(ctx, r.Client, job, completionHooksAnnotation, result); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to record postBackup hook result", "job", job.Name)
+		}
+	}
+	if !succeeded {
+		// The onFailure hook only reports errors, the backup already failed
+		_ = r.runHook(ctx, reader, backup, onFailureHook, hooks.OnFailure, job.Name)
+	}
+	return succeeded, nil
+}
+
+// finishedJobsSince returns the Jobs that finished after the given time, ordered by the
+// time they finished.
+func finishedJobsSince(jobs []batchv1.Job, since time.Time) []batchv1.Job {
+	var finished []batchv1.Job
+	for _, job := range jobs {
+		if done, _, finishedAt := jobFinished(&job); done && finishedAt.After(since) {
+			finished = append(finished, job)
+		}
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		_, _, a := jobFinished(&finished[i])
+		_, _, b := jobFinished(&finished[j])
+		return a.Before(b)
+	})
+
+	return finished
+}
+
+// recordBackupRun records a finished backup job in the status and returns its result.
+// A failed job that still created a snapshot, e.g. because some files could not be
+// read or the postBackup hook failed, is reported as PartiallyFailed. The summary is
+// nil if restic did not report one.
+func recordBackupRun(status *backupv1alpha1.ResticBackupStatus, job *batchv1.Job, succeeded bool, finishedAt time.Time, summary *restic.BackupResult) string {
+	completionTime := metav1.NewTime(finishedAt)
+	run := &backupv1alpha1.BackupRunStatus{
+		StartTime:      job.Status.StartTime,
+		CompletionTime: &completionTime,
+		Result:         backupResultFailed,
+	}
+	if job.Status.StartTime != nil {
+		run.Duration = finishedAt.Sub(job.Status.StartTime.Time).Round(time.Second).String()
+	}
+
+	if status.Statistics == nil {
+		status.Statistics = &backupv1alpha1.BackupStatistics{}
+	}
+	stats := status.Statistics
+	stats.TotalBackups++
+
+	if summary != nil && summary.SnapshotID != "" {
+		run.SnapshotID = summary.SnapshotID
+		stats.LastBackupSize = formatBytes(summary.TotalBytes)
+		stats.LastBackupFiles = summary.TotalFiles
+		if !succeeded {
+			run.Result = backupResultPartiallyFailed
+		}
+	}
+
+	if succeeded {
+		run.Result = backupResultSucceeded
+		status.LastSuccessfulBackup = &completionTime
+		if status.EffectiveRetention != nil {
+			status.LastRetentionRun = &completionTime
+		}
+		stats.SuccessfulBackups++
+		stats.ConsecutiveFailures = 0
+		if summary != nil {
+			recordDataAdded(status, finishedAt, int64(summary.DataAdded))
+		}
+	} else {
+		stats.FailedBackups++
+		stats.ConsecutiveFailures++
+	}
+
+	status.LastBackup = run
+	return run.Result
+}
+
+// recordDataAdded appends a successful backup run to the data-added history of the
+// backup status and drops the oldest samples beyond maxDataAddedHistory.
+func recordDataAdded(status *backupv1alpha1.ResticBackupStatus, completionTime time.Time, dataAdded int64) {
+	status.DataAddedHistory = append(status.DataAddedHistory, backupv1alpha1.BackupDataSample{
+		Time:      metav1.NewTime(completionTime),
+		DataAdded: dataAdded,
+	})
+	if excess := len(status.DataAddedHistory) - maxDataAddedHistory; excess > 0 {
+		status.DataAddedHistory = status.DataAddedHistory[excess:]
+	}
+}
+
+// backupForJob maps a backup Job to the ResticBackup it was created for.
+func backupForJob(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[resticBackupLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}}}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+	"github.com/madic-creates/restic-backup-operator/internal/restic/fakerestic"
+)
+
+var _ = Describe("Backup status", func() {
+	Context("backupScript", func() {
+		It("should write the restic summary to the termination message", func() {
+			script := backupScript{backup: []string{"restic", "backup", "--json", "--exclude", "it's", "/backup"}}.build()
+			Expect(script).To(HavePrefix("set -o pipefail\n"))
+			Expect(script).To(ContainSubstring(`grep '"message_type":"summary"' < /tmp/backup.fifo | tee /tmp/backup.log &` + "\n" +
+				`run 'restic' 'backup' '--json' '--exclude' 'it'\''s' '/backup' > /tmp/backup.fifo`))
+			Expect(script).To(ContainSubstring(`grep '"message_type":"summary"' /tmp/backup.log | tail -n 1 > /dev/termination-log`))
+			Expect(script).To(HaveSuffix("exit $rc"))
+		})
+
+		It("should forward SIGTERM to restic as SIGINT", func() {
+			script := backupScript{backup: []string{"restic", "backup", "/backup"}}.build()
+			Expect(script).To(ContainSubstring(`trap '[ -n "$pid" ] && kill -INT $pid 2>/dev/null' TERM INT`))
+			Expect(script).NotTo(ContainSubstring("unlock"))
+		})
+
+		It("should remove stale locks before the backup", func() {
+			script := backupScript{unlock: []string{"restic", "unlock"}, backup: []string{"restic", "backup", "/backup"}}.build()
+			Expect(script).To(ContainSubstring("run 'restic' 'unlock' || echo 'Failed to remove stale locks' >&2"))
+			Expect(strings.Index(script, "'unlock'")).To(BeNumerically("<", strings.Index(script, "'backup'")))
+		})
+
+		It("should forget snapshots only after a successful backup", func() {
+			script := backupScript{backup: []string{"restic", "backup", "/backup"}, forget: []string{"restic", "forget", "--keep-last", "7"}}.build()
+			Expect(script).To(ContainSubstring("if [ $rc -eq 0 ]; then run 'restic' 'forget' '--keep-last' '7' || rc=$?; fi\nexit $rc"))
+		})
+
+		It("should report the snapshots left after retention", func() {
+			script := backupScript{
+				backup: []string{"restic", "backup", "/backup"},
+				forget: []string{"restic", "forget", "--keep-last", "7"},
+				count:  buildSnapshotCountCommand("app-data"),
+			}.build()
+			Expect(script).To(ContainSubstring(`n=$('restic' 'snapshots' '--host' 'app-data' '--json' | grep -o '"id":' | wc -l)`))
+			Expect(script).To(ContainSubstring(`echo '{"message_type":"retention","snapshots_after":'$n'}' >> /dev/termination-log`))
+			Expect(strings.Index(script, "'forget'")).To(BeNumerically("<", strings.Index(script, "'snapshots'")))
+		})
+	})
+
+	Context("updateBackupStatus", func() {
+		It("should record the snapshot of a finished backup job", func() {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			repository := &backupv1alpha1.ResticRepository{ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"}}
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "media"},
+				Spec:       backupv1alpha1.ResticBackupSpec{RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"}},
+			}
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+				Name:      "resticbackup-data-1",
+				Namespace: "media",
+				Labels:    map[string]string{resticBackupLabel: "data"},
+			}}
+			r := &ResticBackupReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(repository, backup, job).Build(),
+				Scheme:   testScheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			summary := fakerestic.BackupSummary(restic.BackupResult{SnapshotID: "4f2a9c81", TotalFiles: 12, TotalBytes: 2048})
+			Expect(fakerestic.FinishJob(ctx, r.Client, job, true, summary)).To(Succeed())
+			Expect(r.updateBackupStatus(ctx, backup, repository)).To(Succeed())
+			Expect(backup.Status.LastBackup.Result).To(Equal(backupResultSucceeded))
+			Expect(backup.Status.LastBackup.SnapshotID).To(Equal("4f2a9c81"))
+			Expect(backup.Status.Statistics.LastBackupFiles).To(Equal(int64(12)))
+			Expect(backup.Status.LastBackup.FailureMessage).To(BeEmpty())
+		})
+
+		It("should record the end of the log of a failed backup job", func() {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			repository := &backupv1alpha1.ResticRepository{ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"}}
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "media"},
+				Spec:       backupv1alpha1.ResticBackupSpec{RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"}},
+			}
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+				Name:      "resticbackup-data-1",
+				Namespace: "media",
+				Labels:    map[string]string{resticBackupLabel: "data"},
+			}}
+			recorder := record.NewFakeRecorder(10)
+			r := &ResticBackupReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(repository, backup, job).Build(),
+				Scheme:   testScheme,
+				Recorder: recorder,
+				PodLogs:  &fakePodLogReader{logs: "Fatal: wrong password or no key found\n"},
+			}
+
+			Expect(fakerestic.FinishJob(ctx, r.Client, job, false, "")).To(Succeed())
+			Expect(r.updateBackupStatus(ctx, backup, repository)).To(Succeed())
+			Expect(backup.Status.LastBackup.Result).To(Equal(backupResultFailed))
+			Expect(backup.Status.LastBackup.FailureMessage).To(Equal("Fatal: wrong password or no key found"))
+			Expect(recorder.Events).To(Receive(Equal(
+				"Warning BackupFailed Backup job resticbackup-data-1 failed:\nFatal: wrong password or no key found")))
+		})
+	})
+
+	Context("finishedJobsSince helper function", func() {
+		It("should return jobs finished after the given time in order", func() {
+			now := time.Now().Truncate(time.Second)
+			jobs := []batchv1.Job{
+				finishedJob("newest", true, now),
+				finishedJob("recorded", true, now.Add(-2*time.Hour)),
+				{ObjectMeta: metav1.ObjectMeta{Name: "running"}},
+				finishedJob("new", false, now.Add(-time.Hour)),
+			}
+
+			finished := finishedJobsSince(jobs, now.Add(-2*time.Hour))
+			Expect(finished).To(HaveLen(2))
+			Expect(finished[0].Name).To(Equal("new"))
+			Expect(finished[1].Name).To(Equal("newest"))
+		})
+	})
+
+	Context("recordBackupRun helper function", func() {
+		var (
+			job        *batchv1.Job
+			finishedAt time.Time
+		)
+
+		BeforeEach(func() {
+			finishedAt = time.Now().Truncate(time.Second)
+			startTime := metav1.NewTime(finishedAt.Add(-90 * time.Second))
+			job = &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "resticbackup-test-1"},
+				Status:     batchv1.JobStatus{StartTime: &startTime},
+			}
+		})
+
+		It("should record a successful backup", func() {
+			status := &backupv1alpha1.ResticBackupStatus{}
+			result := recordBackupRun(status, job, true, finishedAt, &restic.BackupResult{
+				SnapshotID: "abc123",
+				DataAdded:  2048,
+				TotalFiles: 42,
+				TotalBytes: 1536,
+			})
+
+			Expect(result).To(Equal("Succeeded"))
+			Expect(status.LastBackup.SnapshotID).To(Equal("abc123"))
+			Expect(status.LastBackup.Duration).To(Equal("1m30s"))
+			Expect(status.LastBackup.CompletionTime.Time).To(BeTemporally("==", finishedAt))
+			Expect(status.LastSuccessfulBackup.Time).To(BeTemporally("==", finishedAt))
+			Expect(status.Statistics.TotalBackups).To(Equal(int32(1)))
+			Expect(status.Statistics.SuccessfulBackups).To(Equal(int32(1)))
+			Expect(status.Statistics.LastBackupSize).To(Equal("1.5 KiB"))
+			Expect(status.Statistics.LastBackupFiles).To(Equal(int64(42)))
+			Expect(status.DataAddedHistory).To(ConsistOf(HaveField("DataAdded", int64(2048))))
+		})
+
+		It("should record a failed backup", func() {
+			status := &backupv1alpha1.ResticBackupStatus{
+				Statistics: &backupv1alpha1.BackupStatistics{TotalBackups: 3, SuccessfulBackups: 3},
+			}
+			result := recordBackupRun(status, job, false, finishedAt, nil)
+
+			Expect(result).To(Equal("Failed"))
+			Expect(status.LastBackup.SnapshotID).To(BeEmpty())
+			Expect(status.LastSuccessfulBackup).To(BeNil())
+			Expect(status.Statistics.TotalBackups).To(Equal(int32(4)))
+			Expect(status.Statistics.FailedBackups).To(Equal(int32(1)))
+			Expect(status.DataAddedHistory).To(BeEmpty())
+		})
+
+		It("should record a failed backup with snapshot as partially failed", func() {
+			status := &backupv1alpha1.ResticBackupStatus{}
+			result := recordBackupRun(status, job, false, finishedAt, &restic.BackupResult{SnapshotID: "abc123"})
+
+			Expect(result).To(Equal("PartiallyFailed"))
+			Expect(status.LastBackup.SnapshotID).To(Equal("abc123"))
+			Expect(status.Statistics.FailedBackups).To(Equal(int32(1)))
+		})
+
+		It("should record the retention run of a successful backup", func() {
+			status := &backupv1alpha1.ResticBackupStatus{
+				EffectiveRetention: &backupv1alpha1.EffectiveRetention{Source: "ResticRepository"},
+			}
+			recordBackupRun(status, job, true, finishedAt, nil)
+			Expect(status.LastRetentionRun.Time).To(BeTemporally("==", finishedAt))
+
+			recordBackupRun(status, job, false, finishedAt.Add(time.Hour), nil)
+			Expect(status.LastRetentionRun.Time).To(BeTemporally("==", finishedAt))
+		})
+	})
+
+	Context("recordDataAdded helper function", func() {
+		It("should keep only the most recent samples", func() {
+			status := &backupv1alpha1.ResticBackupStatus{}
+			for i := range maxDataAddedHistory + 5 {
+				recordDataAdded(status, time.Now(), int64(i))
+			}
+			Expect(status.DataAddedHistory).To(HaveLen(maxDataAddedHistory))
+			Expect(status.DataAddedHistory[0].DataAdded).To(Equal(int64(5)))
+		})
+	})
+
+	Context("backupForJob helper function", func() {
+		It("should map backup Jobs to their ResticBackup", func() {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "resticbackup-daily-1",
+					Namespace: "default",
+					Labels:    map[string]string{resticBackupLabel: "daily"},
+				},
+			}
+			Expect(backupForJob(context.Background(), job)).To(ConsistOf(HaveField("NamespacedName", types.NamespacedName{Name: "daily", Namespace: "default"})))
+			Expect(backupForJob(context.Background(), &batchv1.Job{})).To(BeEmpty())
+		})
+	})
+})
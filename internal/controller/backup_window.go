@@ -0,0 +1,206 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+// maxWindowSearch bounds the search for the next time scheduled backups are allowed,
+// e.g. for windows on no day at all.
+const maxWindowSearch = 100
+
+// backupWindowState is the state of the backup window and blackout periods of a backup
+// at a point in time.
+type backupWindowState struct {
+	// suspended reports whether scheduled backups are suspended.
+	suspended bool
+	// reason and message describe the state in the SuspendedByWindow condition.
+	reason  string
+	message string
+	// next is the next time the state may change, zero if it never changes.
+	next time.Time
+}
+
+// evaluateBackupWindow returns the state of the backup window and blackout periods of
+// the backup at the given time, nil if the backup has neither.
+func evaluateBackupWindow(backup *backupv1alpha1.ResticBackup, now time.Time) *backupWindowState {
+	if backup.Spec.BackupWindow == nil && len(backup.Spec.BlackoutPeriods) == 0 {
+		return nil
+	}
+
+	state := &backupWindowState{
+		reason:  "WithinBackupWindow",
+		message: "Scheduled backups are allowed",
+	}
+	updateNext := func(t time.Time) {
+		if t.After(now) && (state.next.IsZero() || t.Before(state.next)) {
+			state.next = t
+		}
+	}
+
+	if window := backup.Spec.BackupWindow; window != nil {
+		inWindow := false
+		var nextStart time.Time
+		for _, r := range windowRanges(window, backupLocation(backup), now) {
+			if !now.Before(r.start) && now.Before(r.end) {
+				inWindow = true
+			}
+			if r.start.After(now) && (nextStart.IsZero() || r.start.Before(nextStart)) {
+				nextStart = r.start
+			}
+			updateNext(r.start)
+			updateNext(r.end)
+		}
+		if !inWindow {
+			state.suspended = true
+			state.reason = "OutsideBackupWindow"
+			state.message = "Scheduled backups are suspended outside of the backup window"
+			if !nextStart.IsZero() {
+				state.message += fmt.Sprintf(" until %s", nextStart.Format(time.RFC3339))
+			}
+		}
+	}
+
+	for _, period := range backup.Spec.BlackoutPeriods {
+		if !now.Before(period.Start.Time) && now.Before(period.End.Time) {
+			name := period.Name
+			if name == "" {
+				name = period.Start.UTC().Format(time.RFC3339)
+			}
+			state.suspended = true
+			state.reason = "BlackoutPeriod"
+			state.message = fmt.Sprintf("Scheduled backups are suspended by blackout period %s until %s",
+				name, period.End.UTC().Format(time.RFC3339))
+		}
+		updateNext(period.Start.Time)
+		updateNext(period.End.Time)
+	}
+
+	return state
+}
+
+// timeWindow is a time range of a backup window on a specific day.
+type timeWindow struct {
+	start, end time.Time
+}
+
+// windowRanges returns the ranges of the backup window from the day before to a week
+// after the given time, so ranges spanning midnight and the next range are included.
+func windowRanges(window *backupv1alpha1.BackupWindow, location *time.Location, now time.Time) []timeWindow {
+	local := now.In(location)
+	var ranges []timeWindow
+	for offset := -1; offset <= 7; offset++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, location)
+		if len(window.Days) > 0 && !slices.Contains(window.Days, backupv1alpha1.Weekday(day.Weekday().String())) {
+			continue
+		}
+		for _, r := range window.Ranges {
+			startHour, startMinute, ok := parseClock(r.Start)
+			if !ok {
+				continue
+			}
+			endHour, endMinute, ok := parseClock(r.End)
+			if !ok {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), startHour, startMinute, 0, 0, location)
+			end := time.Date(day.Year(), day.Month(), day.Day(), endHour, endMinute, 0, 0, location)
+			if !end.After(start) {
+				// The range ends on the following day
+				end = time.Date(day.Year(), day.Month(), day.Day()+1, endHour, endMinute, 0, 0, location)
+			}
+			ranges = append(ranges, timeWindow{start: start, end: end})
+		}
+	}
+	return ranges
+}
+
+// parseClock parses a time of day formatted as HH:MM.
+func parseClock(value string) (int, int, bool) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, false
+	}
+	return t.Hour(), t.Minute(), true
+}
+
+// backupLocation returns the timezone of the backup schedule.
+func backupLocation(backup *backupv1alpha1.ResticBackup) *time.Location {
+	if backup.Spec.Timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(backup.Spec.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// nextAllowedTime returns the first time at or after t at which scheduled backups are
+// allowed by the backup window and blackout periods, nil if none was found.
+func nextAllowedTime(backup *backupv1alpha1.ResticBackup, t time.Time) *time.Time {
+	for range maxWindowSearch {
+		state := evaluateBackupWindow(backup, t)
+		if state == nil || !state.suspended {
+			return &t
+		}
+		if state.next.IsZero() {
+			return nil
+		}
+		t = state.next
+	}
+	return nil
+}
+
+// setWindowCondition reflects the backup window and blackout periods in the
+// SuspendedByWindow condition and records an event when backups are suspended or
+// resumed.
+func (r *ResticBackupReconciler) setWindowCondition(backup *backupv1alpha1.ResticBackup, state *backupWindowState) {
+	if state == nil {
+		meta.RemoveStatusCondition(&backup.Status.Conditions, backupv1alpha1.ConditionSuspendedByWindow)
+		return
+	}
+
+	status := metav1.ConditionFalse
+	if state.suspended {
+		status = metav1.ConditionTrue
+	}
+	previous := meta.FindStatusCondition(backup.Status.Conditions, backupv1alpha1.ConditionSuspendedByWindow)
+	switch {
+	case state.suspended && (previous == nil || previous.Status != metav1.ConditionTrue || previous.Reason != state.reason):
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "SuspendedByWindow", state.message)
+	case !state.suspended && previous != nil && previous.Status == metav1.ConditionTrue:
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "ResumedByWindow", "Scheduled backups are resumed")
+	}
+
+	conditions.SetCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:    backupv1alpha1.ConditionSuspendedByWindow,
+		Status:  status,
+		Reason:  state.reason,
+		Message: state.message,
+	})
+}
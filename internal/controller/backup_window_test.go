@@ -0,0 +1,170 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Backup windows", func() {
+	var backup *backupv1alpha1.ResticBackup
+
+	// Monday, 2024-01-15
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, 15+day, hour, minute, 0, 0, time.UTC)
+	}
+
+	BeforeEach(func() {
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Schedule: "0 * * * *",
+				Source:   backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		}
+	})
+
+	It("should not evaluate backups without window and blackout periods", func() {
+		Expect(evaluateBackupWindow(backup, at(0, 12, 0))).To(BeNil())
+	})
+
+	It("should allow backups within a range spanning midnight", func() {
+		backup.Spec.BackupWindow = &backupv1alpha1.BackupWindow{
+			Ranges: []backupv1alpha1.TimeRange{{Start: "22:00", End: "06:00"}},
+		}
+
+		state := evaluateBackupWindow(backup, at(0, 3, 0))
+		Expect(state.suspended).To(BeFalse())
+		Expect(state.next).To(Equal(at(0, 6, 0)))
+
+		state = evaluateBackupWindow(backup, at(0, 12, 0))
+		Expect(state.suspended).To(BeTrue())
+		Expect(state.reason).To(Equal("OutsideBackupWindow"))
+		Expect(state.message).To(ContainSubstring("until 2024-01-15T22:00:00Z"))
+		Expect(state.next).To(Equal(at(0, 22, 0)))
+	})
+
+	It("should apply the ranges only on the configured days in the timezone of the backup", func() {
+		backup.Spec.Timezone = "Europe/Berlin"
+		backup.Spec.BackupWindow = &backupv1alpha1.BackupWindow{
+			Days:   []backupv1alpha1.Weekday{"Saturday", "Sunday"},
+			Ranges: []backupv1alpha1.TimeRange{{Start: "01:00", End: "05:00"}},
+		}
+
+		// Saturday 02:00 in Berlin
+		Expect(evaluateBackupWindow(backup, at(5, 1, 0)).suspended).To(BeFalse())
+		// Saturday 05:30 in Berlin
+		Expect(evaluateBackupWindow(backup, at(5, 4, 30)).suspended).To(BeTrue())
+		// Monday 02:00 in Berlin
+		state := evaluateBackupWindow(backup, at(0, 1, 0))
+		Expect(state.suspended).To(BeTrue())
+		Expect(state.next).To(BeTemporally("==", at(5, 0, 0)))
+	})
+
+	It("should suspend backups during blackout periods", func() {
+		backup.Spec.BackupWindow = &backupv1alpha1.BackupWindow{
+			Ranges: []backupv1alpha1.TimeRange{{Start: "00:00", End: "00:00"}},
+		}
+		backup.Spec.BlackoutPeriods = []backupv1alpha1.BlackoutPeriod{{
+			Name:  "storage-migration",
+			Start: metav1.NewTime(at(1, 8, 0)),
+			End:   metav1.NewTime(at(1, 18, 0)),
+		}}
+
+		Expect(evaluateBackupWindow(backup, at(1, 7, 0)).suspended).To(BeFalse())
+		state := evaluateBackupWindow(backup, at(1, 9, 0))
+		Expect(state.suspended).To(BeTrue())
+		Expect(state.reason).To(Equal("BlackoutPeriod"))
+		Expect(state.message).To(ContainSubstring("storage-migration"))
+		Expect(state.next).To(Equal(at(1, 18, 0)))
+		Expect(evaluateBackupWindow(backup, at(1, 18, 0)).suspended).To(BeFalse())
+	})
+
+	It("should find the next time backups are allowed", func() {
+		backup.Spec.BackupWindow = &backupv1alpha1.BackupWindow{
+			Ranges: []backupv1alpha1.TimeRange{{Start: "22:00", End: "06:00"}},
+		}
+		backup.Spec.BlackoutPeriods = []backupv1alpha1.BlackoutPeriod{{
+			Start: metav1.NewTime(at(0, 20, 0)),
+			End:   metav1.NewTime(at(1, 2, 0)),
+		}}
+
+		Expect(*nextAllowedTime(backup, at(0, 12, 0))).To(Equal(at(1, 2, 0)))
+		Expect(*nextAllowedTime(backup, at(1, 3, 0))).To(Equal(at(1, 3, 0)))
+
+		backup.Spec.BackupWindow.Days = []backupv1alpha1.Weekday{}
+		backup.Spec.BackupWindow.Ranges = nil
+		Expect(nextAllowedTime(backup, at(0, 12, 0))).To(BeNil())
+	})
+
+	It("should suspend the CronJob outside of the backup window", func() {
+		reconciler := &ResticBackupReconciler{}
+		repository := &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		backup.Spec.BlackoutPeriods = []backupv1alpha1.BlackoutPeriod{{
+			Start: metav1.NewTime(time.Now().Add(-time.Hour)),
+			End:   metav1.NewTime(time.Now().Add(time.Hour)),
+		}}
+
+		cronJob, err := reconciler.buildCronJob(backup, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*cronJob.Spec.Suspend).To(BeTrue())
+
+		backup.Spec.BlackoutPeriods[0].Start = metav1.NewTime(time.Now().Add(time.Hour))
+		backup.Spec.BlackoutPeriods[0].End = metav1.NewTime(time.Now().Add(2 * time.Hour))
+		cronJob, err = reconciler.buildCronJob(backup, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*cronJob.Spec.Suspend).To(BeFalse())
+	})
+
+	It("should reflect the window in the SuspendedByWindow condition", func() {
+		recorder := record.NewFakeRecorder(10)
+		reconciler := &ResticBackupReconciler{Recorder: recorder}
+		backup.Spec.BackupWindow = &backupv1alpha1.BackupWindow{
+			Ranges: []backupv1alpha1.TimeRange{{Start: "22:00", End: "06:00"}},
+		}
+
+		reconciler.setWindowCondition(backup, evaluateBackupWindow(backup, at(0, 12, 0)))
+		Expect(meta.IsStatusConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionSuspendedByWindow)).To(BeTrue())
+		Expect(recorder.Events).To(Receive(ContainSubstring("SuspendedByWindow")))
+
+		// No event while the state is unchanged
+		reconciler.setWindowCondition(backup, evaluateBackupWindow(backup, at(0, 13, 0)))
+		Expect(recorder.Events).NotTo(Receive())
+
+		reconciler.setWindowCondition(backup, evaluateBackupWindow(backup, at(0, 23, 0)))
+		Expect(meta.IsStatusConditionFalse(backup.Status.Conditions, backupv1alpha1.ConditionSuspendedByWindow)).To(BeTrue())
+		Expect(recorder.Events).To(Receive(ContainSubstring("ResumedByWindow")))
+
+		backup.Spec.BackupWindow = nil
+		reconciler.setWindowCondition(backup, evaluateBackupWindow(backup, at(0, 23, 0)))
+		Expect(meta.FindStatusCondition(backup.Status.Conditions, backupv1alpha1.ConditionSuspendedByWindow)).To(BeNil())
+	})
+})
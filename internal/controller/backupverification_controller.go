@@ -0,0 +1,650 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// backupVerificationLabel links verification CronJobs and their Jobs to the BackupVerification
+	backupVerificationLabel = "backup.resticbackup.io/verification"
+	// verificationSnapshotType is the message type of the termination message line
+	// describing the verified snapshot.
+	verificationSnapshotType = "verification_snapshot"
+	// defaultVerificationMinPercent is the default minimum size and file count of the
+	// snapshot relative to the source.
+	defaultVerificationMinPercent = 50
+)
+
+// BackupVerificationReconciler reconciles a BackupVerification object
+type BackupVerificationReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader reads verification pods directly from the API server to avoid caching all pods.
+	// Falls back to Client if not set.
+	APIReader client.Reader
+	// MaxConcurrentReconciles is the number of BackupVerifications reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+	// Images resolves the default restic image, e.g. to a private mirror.
+	Images *ImageConfig
+	// JobDefaults sets the default active deadline and backoff limit of the jobs.
+	JobDefaults *JobDefaults
+	// FeatureGates enables optional capabilities. If nil, the feature defaults apply.
+	FeatureGates *features.Gate
+}
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=backupverifications,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=backupverifications/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticbackups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop.
+func (r *BackupVerificationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciling BackupVerification")
+
+	// Fetch the BackupVerification instance
+	verification := &backupv1alpha1.BackupVerification{}
+	if err := r.Get(ctx, req.NamespacedName, verification); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("BackupVerification resource not found, ignoring")
+			deleteVerificationMetrics(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get BackupVerification")
+		return ctrl.Result{}, err
+	}
+
+	// Get the backup and its repository
+	backup, repository, err := r.getBackupAndRepository(ctx, verification)
+	setReferenceDenied(&verification.Status.Conditions, err)
+	if err != nil {
+		log.Error(err, "Failed to get backup")
+		reason := referenceErrorReason(err, "BackupNotFound")
+		return r.notReady(ctx, verification, reason, err.Error())
+	}
+
+	// Only PVC sources can be compared with the snapshot
+	if len(pvcSources(backup)) == 0 {
+		return r.notReady(ctx, verification, "UnsupportedSource",
+			fmt.Sprintf("Backup %s has no PVC source, only PVC backups can be verified", backup.Name))
+	}
+
+	// Reject sidecars the job pods can't be created with
+	if err := validateSidecars(verificationJobConfig(verification, backup)); err != nil {
+		return r.notReady(ctx, verification, "InvalidSidecars", err.Error())
+	}
+
+	// Check repository is ready
+	if !conditions.IsConditionTrue(repository.Status.Conditions, "Ready") {
+		log.Info("Repository not ready, requeuing")
+		r.setCondition(verification, conditions.NotReadyCondition("RepositoryNotReady", "Referenced repository is not ready"))
+		if err := r.Status().Update(ctx, verification); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	// Reconcile CronJob
+	if err := r.reconcileCronJob(ctx, verification, backup, repository); err != nil {
+		log.Error(err, "Failed to reconcile CronJob")
+		return r.notReady(ctx, verification, "CronJobFailed", err.Error())
+	}
+
+	// Report the result of the latest finished verification job
+	if err := r.updateLastVerification(ctx, verification); err != nil {
+		log.Error(err, "Failed to evaluate verification jobs")
+	}
+
+	// Calculate next verification time
+	verification.Status.NextVerification = nextVerificationRun(verification, time.Now())
+
+	// Set Ready condition
+	r.setCondition(verification, conditions.ReadyCondition("VerificationConfigured", "Verification CronJob is configured"))
+	verification.Status.ObservedGeneration = verification.Generation
+
+	if err := r.Status().Update(ctx, verification); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+// notReady marks the verification as not ready and requeues it.
+func (r *BackupVerificationReconciler) notReady(ctx context.Context, verification *backupv1alpha1.BackupVerification, reason, message string) (ctrl.Result, error) {
+	r.setCondition(verification, conditions.NotReadyCondition(reason, message))
+	r.Recorder.Event(verification, corev1.EventTypeWarning, reason, message)
+	if err := r.Status().Update(ctx, verification); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+}
+
+// getBackupAndRepository returns the verified backup and its repository. The
+// repository is accessed on behalf of the backup.
+func (r *BackupVerificationReconciler) getBackupAndRepository(ctx context.Context, verification *backupv1alpha1.BackupVerification) (*backupv1alpha1.ResticBackup, *backupv1alpha1.ResticRepository, error) {
+	backup := &backupv1alpha1.ResticBackup{}
+	if err := r.Get(ctx, types.NamespacedName{Name: verification.Spec.BackupName, Namespace: verification.Namespace}, backup); err != nil {
+		return nil, nil, fmt.Errorf("failed to get backup: %w", err)
+	}
+
+	ns := backup.Spec.RepositoryRef.Namespace
+	if ns == "" {
+		ns = backup.Namespace
+	}
+	if err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "ResticBackup", backup.Namespace, "ResticRepository", ns, backup.Spec.RepositoryRef.Name); err != nil {
+		return nil, nil, err
+	}
+	repository := &backupv1alpha1.ResticRepository{}
+	if err := r.Get(ctx, types.NamespacedName{Name: backup.Spec.RepositoryRef.Name, Namespace: ns}, repository); err != nil {
+		return nil, nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	return backup, repository, nil
+}
+
+func (r *BackupVerificationReconciler) reconcileCronJob(ctx context.Context, verification *backupv1alpha1.BackupVerification, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) error {
+	log := log.FromContext(ctx)
+
+	cronJob, err := r.buildCronJob(verification, backup, repository)
+	if err != nil {
+		return err
+	}
+
+	// Set owner reference
+	if err := controllerutil.SetControllerReference(verification, cronJob, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	// Update status with CronJob reference
+	verification.Status.CronJobRef = &backupv1alpha1.ObjectReference{
+		Name:      cronJob.Name,
+		Namespace: cronJob.Namespace,
+	}
+
+	// Check if CronJob exists
+	existingCronJob := &batchv1.CronJob{}
+	err = r.Get(ctx, types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, existingCronJob)
+
+	if apierrors.IsNotFound(err) {
+		log.Info("Creating CronJob", "name", cronJob.Name)
+		created, err := createOrAdopt(ctx, r.Client, verification, cronJob, existingCronJob)
+		if err != nil {
+			return fmt.Errorf("failed to create CronJob: %w", err)
+		}
+		if created {
+			r.Recorder.Event(verification, corev1.EventTypeNormal, "CronJobCreated", fmt.Sprintf("Created CronJob %s", cronJob.Name))
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get CronJob: %w", err)
+	}
+
+	// Update existing CronJob
+	existingCronJob.Spec = cronJob.Spec
+	if err := r.Update(ctx, existingCronJob); err != nil {
+		return fmt.Errorf("failed to update CronJob: %w", err)
+	}
+
+	return nil
+}
+
+// updateLastVerification evaluates the most recently finished verification job. Each
+// job is reported only once, so events are emitted once per verification run.
+func (r *BackupVerificationReconciler) updateLastVerification(ctx context.Context, verification *backupv1alpha1.BackupVerification) error {
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs,
+		client.InNamespace(verification.Namespace),
+		client.MatchingLabels{backupVerificationLabel: verification.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list verification jobs: %w", err)
+	}
+
+	latest, latestSucceeded, latestFinishedAt := latestFinishedJob(jobs.Items)
+	if latest == nil || latest.Name == verification.Status.LastVerificationJob {
+		return nil
+	}
+
+	finishedAt := metav1.NewTime(latestFinishedAt)
+	verification.Status.LastVerification = &finishedAt
+	verification.Status.LastVerificationJob = latest.Name
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	message, err := jobTerminationMessage(ctx, reader, latest)
+	if err != nil {
+		// The pod may already be gone, report the failure without details
+		log.FromContext(ctx).Error(err, "Failed to read verification output")
+	}
+
+	condition := metav1.Condition{
+		Type:    backupv1alpha1.ConditionSnapshotVerified,
+		Status:  metav1.ConditionFalse,
+		Reason:  "VerificationFailed",
+		Message: fmt.Sprintf("Verification job %s failed", latest.Name),
+	}
+	if latestSucceeded {
+		result, err := parseVerificationOutput(message)
+		if err != nil {
+			condition.Message = fmt.Sprintf("Failed to read the result of verification job %s: %s", latest.Name, err)
+		} else {
+			verification.Status.Drift = result.drift()
+			condition.Reason, condition.Message = result.evaluate(verification)
+			if condition.Reason == "Verified" {
+				condition.Status = metav1.ConditionTrue
+			}
+			recordVerificationRatios(verification, result)
+		}
+	}
+	conditions.SetCondition(&verification.Status.Conditions, condition)
+
+	if condition.Status == metav1.ConditionTrue {
+		verification.Status.LastVerificationResult = "Passed"
+		r.Recorder.Event(verification, corev1.EventTypeNormal, "VerificationPassed", condition.Message)
+	} else {
+		verification.Status.LastVerificationResult = "Failed"
+		r.Recorder.Event(verification, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	}
+	recordVerificationResult(verification, condition.Status == metav1.ConditionTrue)
+
+	return nil
+}
+
+// verificationResult is the outcome of a verification job.
+type verificationResult struct {
+	// snapshotID is the ID of the latest snapshot, empty if the backup has no snapshot.
+	snapshotID    string
+	snapshotFiles int64
+	snapshotBytes uint64
+	// source is the summary of the dry-run backup of the source.
+	source *restic.BackupResult
+}
+
+// verificationSnapshot is the termination message line describing the verified snapshot.
+type verificationSnapshot struct {
+	MessageType string `json:"message_type"`
+	SnapshotID  string `json:"snapshot_id"`
+	Stats       struct {
+		TotalSize      uint64 `json:"total_size"`
+		TotalFileCount int64  `json:"total_file_count"`
+	} `json:"stats"`
+}
+
+// parseVerificationOutput parses the termination message of a verification job.
+func parseVerificationOutput(message string) (*verificationResult, error) {
+	var snapshot *verificationSnapshot
+	for _, line := range strings.Split(message, "\n") {
+		var s verificationSnapshot
+		if err := json.Unmarshal([]byte(line), &s); err == nil && s.MessageType == verificationSnapshotType {
+			snapshot = &s
+		}
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("no snapshot statistics found in verification output")
+	}
+
+	result := &verificationResult{
+		snapshotID:    snapshot.SnapshotID,
+		snapshotFiles: snapshot.Stats.TotalFileCount,
+		snapshotBytes: snapshot.Stats.TotalSize,
+	}
+	if result.snapshotID == "" {
+		return result, nil
+	}
+
+	source, err := restic.ParseBackupSummary(message)
+	if err != nil {
+		return nil, err
+	}
+	result.source = source
+	return result, nil
+}
+
+// drift returns the status comparing the snapshot with the source.
+func (v *verificationResult) drift() *backupv1alpha1.VerificationDrift {
+	drift := &backupv1alpha1.VerificationDrift{
+		SnapshotID:    v.snapshotID,
+		SnapshotFiles: v.snapshotFiles,
+		SnapshotSize:  formatBytes(v.snapshotBytes),
+	}
+	if v.source != nil {
+		drift.SourceFiles = v.source.TotalFiles
+		drift.SourceSize = formatBytes(v.source.TotalBytes)
+		drift.FilesNew = v.source.FilesNew
+		drift.FilesChanged = v.source.FilesChanged
+		drift.FilesUnmodified = v.source.FilesUnmodified
+		drift.DataMissing = formatBytes(v.source.DataAdded)
+	}
+	return drift
+}
+
+// sizeRatio returns the size of the snapshot relative to the source. An empty source
+// yields 1.
+func (v *verificationResult) sizeRatio() float64 {
+	if v.source == nil || v.source.TotalBytes == 0 {
+		return 1
+	}
+	return float64(v.snapshotBytes) / float64(v.source.TotalBytes)
+}
+
+// fileCountRatio returns the number of files of the snapshot relative to the source.
+// An empty source yields 1.
+func (v *verificationResult) fileCountRatio() float64 {
+	if v.source == nil || v.source.TotalFiles == 0 {
+		return 1
+	}
+	return float64(v.snapshotFiles) / float64(v.source.TotalFiles)
+}
+
+// evaluate returns the condition reason and message of the verification. A missing or
+// empty snapshot is reported as SnapshotEmpty, a snapshot below the minimum size or file
+// count as SnapshotTooSmall.
+func (v *verificationResult) evaluate(verification *backupv1alpha1.BackupVerification) (string, string) {
+	backup := verification.Spec.BackupName
+	switch {
+	case v.snapshotID == "":
+		return "SnapshotEmpty", fmt.Sprintf("Backup %s has no snapshot", backup)
+	case v.snapshotFiles == 0 && v.source.TotalFiles > 0:
+		return "SnapshotEmpty", fmt.Sprintf("Latest snapshot %s of backup %s is empty while the source has %d files",
+			shortSnapshotID(v.snapshotID), backup, v.source.TotalFiles)
+	}
+
+	minSize := verificationMinPercent(verification.Spec.MinSizePercent)
+	if ratio := v.sizeRatio(); ratio*100 < float64(minSize) {
+		return "SnapshotTooSmall", fmt.Sprintf("Latest snapshot %s of backup %s has %s, %.0f%% of the %s in the source (minimum %d%%)",
+			shortSnapshotID(v.snapshotID), backup, formatBytes(v.snapshotBytes), ratio*100, formatBytes(v.source.TotalBytes), minSize)
+	}
+	minFiles := verificationMinPercent(verification.Spec.MinFileCountPercent)
+	if ratio := v.fileCountRatio(); ratio*100 < float64(minFiles) {
+		return "SnapshotTooSmall", fmt.Sprintf("Latest snapshot %s of backup %s has %d files, %.0f%% of the %d files in the source (minimum %d%%)",
+			shortSnapshotID(v.snapshotID), backup, v.snapshotFiles, ratio*100, v.source.TotalFiles, minFiles)
+	}
+
+	return "Verified", fmt.Sprintf("Latest snapshot %s of backup %s matches the source: %d new, %d changed files, %s not in the repository",
+		shortSnapshotID(v.snapshotID), backup, v.source.FilesNew, v.source.FilesChanged, formatBytes(v.source.DataAdded))
+}
+
+// verificationMinPercent returns the configured minimum percentage or the default.
+func verificationMinPercent(percent *int32) int32 {
+	if percent == nil {
+		return defaultVerificationMinPercent
+	}
+	return *percent
+}
+
+// shortSnapshotID returns the first 8 characters of a snapshot ID, like restic prints them.
+func shortSnapshotID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// buildVerificationScript builds the shell script run by the verification container.
+// It looks up the latest snapshot of the backup, writes its statistics to the
+// termination message and compares it with the source by a dry-run backup using the
+// snapshot as parent, whose JSON summary is appended to the termination message. The
+// Content mode rereads all files, so the summary reports the source data missing in
+// the repository. Options are the extended options of the repository backend.
+func buildVerificationScript(verification *backupv1alpha1.BackupVerification, backup *backupv1alpha1.ResticBackup, options []string) (string, error) {
+	hostname, err := renderHostname(backup)
+	if err != nil {
+		return "", err
+	}
+	tag := normalizeTags(backup, []string{backupTag(backup)})[0]
+
+	snapshots := slices.Concat([]string{"restic", "snapshots"}, options, []string{"--json", "--latest", "1", "--tag", tag})
+	stats := slices.Concat([]string{"restic", "stats"}, options, []string{"--json", "--mode", "restore-size"})
+
+	dryRun := slices.Concat([]string{"restic", "backup"}, options, []string{"--dry-run", "--json", "--host", hostname, "--tag", tag})
+	for _, exclude := range sourceExcludes(backup) {
+		dryRun = append(dryRun, "--exclude", exclude)
+	}
+	parent := ` --parent "$id"`
+	if verification.Spec.Mode == backupv1alpha1.VerificationModeContent {
+		dryRun = append(dryRun, "--force")
+		parent = ""
+	}
+	dryRun = append(dryRun, backupSourcePaths(backup)...)
+
+	commands := []string{
+		"set -o pipefail",
+		fmt.Sprintf("snapshots=$(%s) || exit 1", shellQuoteArgs(snapshots)),
+		`id=$(echo "$snapshots" | grep -o '"id":"[0-9a-f]*"' | head -n 1 | cut -d '"' -f 4)`,
+		fmt.Sprintf(`if [ -z "$id" ]; then echo '{"message_type":"%s"}' > /dev/termination-log; echo 'No snapshot found' >&2; exit 0; fi`, verificationSnapshotType),
+		fmt.Sprintf(`stats=$(%s "$id") || exit 1`, shellQuoteArgs(stats)),
+		fmt.Sprintf(`echo '{"message_type":"%s","snapshot_id":"'"$id"'","stats":'"$stats"'}' > /dev/termination-log`, verificationSnapshotType),
+		fmt.Sprintf("%s%s > /tmp/verification.log || exit 1", shellQuoteArgs(dryRun), parent),
+		fmt.Sprintf("grep '%s' /tmp/verification.log | tail -n 1 >> /dev/termination-log", backupSummaryPattern),
+	}
+
+	return strings.Join(commands, "\n"), nil
+}
+
+// verificationJobConfig returns the job configuration of the verification jobs. The
+// verification runs like the backup unless configured otherwise.
+func verificationJobConfig(verification *backupv1alpha1.BackupVerification, backup *backupv1alpha1.ResticBackup) *backupv1alpha1.JobConfiguration {
+	if verification.Spec.JobConfig != nil {
+		return verification.Spec.JobConfig
+	}
+	return backup.Spec.JobConfig
+}
+
+func (r *BackupVerificationReconciler) buildCronJob(verification *backupv1alpha1.BackupVerification, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) (*batchv1.CronJob, error) {
+	cronJobName := fmt.Sprintf("backupverification-%s", verification.Name)
+
+	script, err := buildVerificationScript(verification, backup, repositoryOptions(repository))
+	if err != nil {
+		return nil, err
+	}
+
+	jobConfig := verificationJobConfig(verification, backup)
+	image := verification.Spec.Image
+	if image == "" && backup.Spec.Restic != nil {
+		image = backup.Spec.Restic.Image
+	}
+	resticImage := r.Images.Resolve(image, jobConfig)
+
+	var successLimit, failLimit int32 = 3, 3
+	backoffLimit := r.JobDefaults.BackoffLimit(JobOperationVerification, jobConfig)
+	activeDeadline := r.JobDefaults.ActiveDeadlineSeconds(JobOperationVerification, jobConfig)
+
+	if jobConfig != nil {
+		if jobConfig.SuccessfulJobsHistoryLimit != nil {
+			successLimit = *jobConfig.SuccessfulJobsHistoryLimit
+		}
+		if jobConfig.FailedJobsHistoryLimit != nil {
+			failLimit = *jobConfig.FailedJobsHistoryLimit
+		}
+	}
+
+	volumes, volumeMounts := sourceVolumes(backup)
+	securityContext := &corev1.PodSecurityContext{
+		RunAsNonRoot: boolPtr(true),
+		RunAsUser:    int64Ptr(65532),
+		FSGroup:      int64Ptr(65532),
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+	resources := corev1.ResourceRequirements{}
+	if jobConfig != nil {
+		if jobConfig.SecurityContext != nil {
+			securityContext = jobConfig.SecurityContext
+		}
+		if jobConfig.Resources != nil {
+			resources = *jobConfig.Resources
+		}
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "restic-backup-operator",
+		"app.kubernetes.io/component": "verification",
+		backupVerificationLabel:       verification.Name,
+	}
+
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cronJobName,
+			Namespace: verification.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "verification",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				backupVerificationLabel:        verification.Name,
+			},
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   verification.Spec.Schedule,
+			Suspend:                    &verification.Spec.Suspend,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &successLimit,
+			FailedJobsHistoryLimit:     &failLimit,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit:          &backoffLimit,
+					ActiveDeadlineSeconds: &activeDeadline,
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: labels,
+						},
+						Spec: corev1.PodSpec{
+							RestartPolicy:   corev1.RestartPolicyNever,
+							SecurityContext: securityContext,
+							Volumes:         volumes,
+							Containers: []corev1.Container{
+								{
+									Name:            "restic",
+									Image:           resticImage,
+									ImagePullPolicy: corev1.PullIfNotPresent,
+									Command:         []string{"/bin/sh", "-c"},
+									Args:            []string{script},
+									Env:             repositoryEnvVars(repository),
+									VolumeMounts:    volumeMounts,
+									Resources:       resources,
+									SecurityContext: &corev1.SecurityContext{
+										AllowPrivilegeEscalation: boolPtr(false),
+										ReadOnlyRootFilesystem:   boolPtr(false),
+										RunAsNonRoot:             boolPtr(true),
+										Capabilities: &corev1.Capabilities{
+											Drop: []corev1.Capability{"ALL"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// Add timezone if specified
+	if verification.Spec.Timezone != "" && verification.Spec.Timezone != "UTC" {
+		cronJob.Spec.TimeZone = &verification.Spec.Timezone
+	}
+
+	// Apply scheduling and networking settings
+	podSpec := &cronJob.Spec.JobTemplate.Spec.Template.Spec
+	applyRepositoryCache(podSpec, repository, verification.Namespace)
+	applyRepositoryCredentials(podSpec, repository)
+	applyJobConfiguration(podSpec, jobConfig)
+	applyPodMetadata(&cronJob.Spec.JobTemplate.Spec.Template.ObjectMeta, jobConfig)
+
+	return cronJob, nil
+}
+
+// nextVerificationRun returns the next scheduled verification after now, or nil if the
+// verification is suspended or its schedule is invalid.
+func nextVerificationRun(verification *backupv1alpha1.BackupVerification, now time.Time) *metav1.Time {
+	if verification.Spec.Suspend {
+		return nil
+	}
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	schedule, err := parser.Parse(verification.Spec.Schedule)
+	if err != nil {
+		return nil
+	}
+	if verification.Spec.Timezone != "" {
+		if location, err := time.LoadLocation(verification.Spec.Timezone); err == nil {
+			now = now.In(location)
+		}
+	}
+	return &metav1.Time{Time: schedule.Next(now)}
+}
+
+func (r *BackupVerificationReconciler) setCondition(verification *backupv1alpha1.BackupVerification, condition metav1.Condition) {
+	conditions.SetCondition(&verification.Status.Conditions, condition)
+}
+
+// verificationForJob maps a verification Job to its BackupVerification. The Jobs are
+// owned by the CronJob, so they are matched by label instead of owner reference.
+func verificationForJob(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[backupVerificationLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BackupVerificationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&backupv1alpha1.BackupVerification{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Owns(&batchv1.CronJob{}).
+		Watches(&batchv1.Job{}, handler.EnqueueRequestsFromMapFunc(verificationForJob)).
+		Complete(r)
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("BackupVerification Controller", func() {
+	var (
+		verification *backupv1alpha1.BackupVerification
+		backup       *backupv1alpha1.ResticBackup
+		repository   *backupv1alpha1.ResticRepository
+	)
+
+	BeforeEach(func() {
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+			Status: backupv1alpha1.ResticRepositoryStatus{
+				Conditions: []metav1.Condition{conditions.ReadyCondition("RepositoryAccessible", "ready")},
+			},
+		}
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "emby", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"},
+				Schedule:      "0 2 * * *",
+				Source: backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{
+					ClaimName: "emby-config",
+					Paths:     []string{"/data"},
+					Excludes:  []string{"cache"},
+				}},
+			},
+		}
+		verification = &backupv1alpha1.BackupVerification{
+			ObjectMeta: metav1.ObjectMeta{Name: "emby-verify", Namespace: "media"},
+			Spec: backupv1alpha1.BackupVerificationSpec{
+				BackupName: "emby",
+				Schedule:   "0 6 * * *",
+			},
+		}
+	})
+
+	It("should compare the latest snapshot with the mounted source", func() {
+		cronJob, err := (&BackupVerificationReconciler{}).buildCronJob(verification, backup, repository)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cronJob.Name).To(Equal("backupverification-emby-verify"))
+		Expect(cronJob.Spec.JobTemplate.Labels).To(HaveKeyWithValue(backupVerificationLabel, "emby-verify"))
+		podSpec := cronJob.Spec.JobTemplate.Spec.Template.Spec
+		Expect(podSpec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("emby-config"))
+		Expect(podSpec.Volumes[0].PersistentVolumeClaim.ReadOnly).To(BeTrue())
+
+		script := podSpec.Containers[0].Args[0]
+		Expect(script).To(ContainSubstring("'restic' 'snapshots' '--json' '--latest' '1' '--tag' 'backup=media/emby'"))
+		Expect(script).To(ContainSubstring(`'restic' 'stats' '--json' '--mode' 'restore-size' "$id"`))
+		Expect(script).To(ContainSubstring(`'restic' 'backup' '--dry-run' '--json' '--host' 'emby' '--tag' 'backup=media/emby' '--exclude' 'cache' '/backup/data' --parent "$id"`))
+		Expect(script).NotTo(ContainSubstring("--force"))
+
+		verification.Spec.Mode = backupv1alpha1.VerificationModeContent
+		script, err = buildVerificationScript(verification, backup, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(script).To(ContainSubstring("'--force' '/backup/data' > /tmp/verification.log"))
+		Expect(script).NotTo(ContainSubstring("--parent"))
+	})
+
+	It("should fail an empty or dramatically smaller snapshot", func() {
+		parse := func(snapshotFiles, snapshotBytes, sourceFiles, sourceBytes int) (string, string) {
+			result, err := parseVerificationOutput(strings.Join([]string{
+				`{"message_type":"verification_snapshot","snapshot_id":"4f2a9c81d3e5","stats":{"total_size":` + strconv.Itoa(snapshotBytes) + `,"total_file_count":` + strconv.Itoa(snapshotFiles) + `,"snapshots_count":1}}`,
+				`{"message_type":"summary","files_new":2,"files_changed":1,"files_unmodified":7,"data_added":2048,"total_files_processed":` + strconv.Itoa(sourceFiles) + `,"total_bytes_processed":` + strconv.Itoa(sourceBytes) + `}`,
+			}, "\n"))
+			Expect(err).NotTo(HaveOccurred())
+			return result.evaluate(verification)
+		}
+
+		reason, message := parse(10, 1000, 10, 1100)
+		Expect(reason).To(Equal("Verified"))
+		Expect(message).To(ContainSubstring("snapshot 4f2a9c81 of backup emby matches the source: 2 new, 1 changed files"))
+
+		reason, _ = parse(0, 0, 10, 1100)
+		Expect(reason).To(Equal("SnapshotEmpty"))
+
+		reason, message = parse(10, 100, 10, 1000)
+		Expect(reason).To(Equal("SnapshotTooSmall"))
+		Expect(message).To(ContainSubstring("10% of the 1000 B in the source (minimum 50%)"))
+
+		reason, _ = parse(4, 1000, 10, 1000)
+		Expect(reason).To(Equal("SnapshotTooSmall"))
+
+		verification.Spec.MinFileCountPercent = int32Ptr(30)
+		reason, _ = parse(4, 1000, 10, 1000)
+		Expect(reason).To(Equal("Verified"))
+
+		result, err := parseVerificationOutput(`{"message_type":"verification_snapshot"}`)
+		Expect(err).NotTo(HaveOccurred())
+		reason, message = result.evaluate(verification)
+		Expect(reason).To(Equal("SnapshotEmpty"))
+		Expect(message).To(Equal("Backup emby has no snapshot"))
+
+		_, err = parseVerificationOutput("")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should record the drift of a finished verification once", func() {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backupverification-emby-verify-29001",
+				Namespace: "media",
+				Labels:    map[string]string{backupVerificationLabel: "emby-verify"},
+			},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type:               batchv1.JobComplete,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+			}}},
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backupverification-emby-verify-29001-abcde",
+				Namespace: "media",
+				Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+			},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "restic",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Message: `{"message_type":"verification_snapshot","snapshot_id":"4f2a9c81d3e5","stats":{"total_size":0,"total_file_count":0,"snapshots_count":1}}` + "\n" +
+						`{"message_type":"summary","files_new":120,"total_files_processed":120,"total_bytes_processed":5242880,"data_added":5242880}` + "\n",
+				}},
+			}}},
+		}
+		recorder := record.NewFakeRecorder(10)
+		reconciler := &BackupVerificationReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(verification, backup, repository, job, pod).
+				WithStatusSubresource(&backupv1alpha1.BackupVerification{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: recorder,
+		}
+		key := types.NamespacedName{Name: "emby-verify", Namespace: "media"}
+		DeferCleanup(deleteVerificationMetrics, key)
+
+		for range 2 {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		updated := &backupv1alpha1.BackupVerification{}
+		Expect(reconciler.Get(ctx, key, updated)).To(Succeed())
+		Expect(updated.Status.LastVerificationResult).To(Equal("Failed"))
+		Expect(updated.Status.LastVerificationJob).To(Equal(job.Name))
+		Expect(updated.Status.NextVerification).NotTo(BeNil())
+		Expect(updated.Status.Drift).To(HaveValue(And(
+			HaveField("SnapshotFiles", int64(0)),
+			HaveField("SourceFiles", int64(120)),
+			HaveField("SourceSize", "5.0 MiB"),
+			HaveField("DataMissing", "5.0 MiB"),
+		)))
+		verified := conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionSnapshotVerified)
+		Expect(verified.Status).To(Equal(metav1.ConditionFalse))
+		Expect(verified.Reason).To(Equal("SnapshotEmpty"))
+		Expect(conditions.IsConditionTrue(updated.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+		Expect(reconciler.Get(ctx, types.NamespacedName{Name: "backupverification-emby-verify", Namespace: "media"}, &batchv1.CronJob{})).To(Succeed())
+
+		var emptyEvents []string
+		for len(recorder.Events) > 0 {
+			if event := <-recorder.Events; strings.Contains(event, "SnapshotEmpty") {
+				emptyEvents = append(emptyEvents, event)
+			}
+		}
+		Expect(emptyEvents).To(ConsistOf(ContainSubstring("is empty while the source has 120 files")))
+	})
+
+	It("should not verify backups without a PVC source", func() {
+		backup.Spec.Source = backupv1alpha1.BackupSource{CustomSource: &backupv1alpha1.CustomSource{}}
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		reconciler := &BackupVerificationReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(verification, backup, repository).
+				WithStatusSubresource(&backupv1alpha1.BackupVerification{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+		}
+		key := types.NamespacedName{Name: "emby-verify", Namespace: "media"}
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		updated := &backupv1alpha1.BackupVerification{}
+		Expect(reconciler.Get(ctx, key, updated)).To(Succeed())
+		Expect(conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionReady).Reason).To(Equal("UnsupportedSource"))
+	})
+})
@@ -0,0 +1,388 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+// clusterBackupPolicyLabel marks the ResticBackups created for a ClusterBackupPolicy
+// with its name.
+const clusterBackupPolicyLabel = "backup.resticbackup.io/cluster-backup-policy"
+
+// ClusterBackupPolicyReconciler reconciles a ClusterBackupPolicy object
+type ClusterBackupPolicyReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// MaxConcurrentReconciles is the number of ClusterBackupPolicies reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=clusterbackuppolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=clusterbackuppolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=clusterbackuppolicies/finalizers,verbs=update
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop.
+func (r *ClusterBackupPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciling ClusterBackupPolicy")
+
+	policy := &backupv1alpha1.ClusterBackupPolicy{}
+	if err := r.Get(ctx, req.NamespacedName, policy); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("ClusterBackupPolicy resource not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get ClusterBackupPolicy")
+		return ctrl.Result{}, err
+	}
+
+	// The created ResticBackups are owned by the policy and garbage collected with it,
+	// their snapshots are kept
+	if !policy.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	policy.Status.ObservedGeneration = policy.Generation
+	namespaceSelector, err := metav1.LabelSelectorAsSelector(&policy.Spec.NamespaceSelector)
+	if err != nil {
+		r.setCondition(policy, conditions.NotReadyCondition("InvalidSelector", fmt.Sprintf("Invalid namespace selector: %v", err)))
+		return ctrl.Result{}, r.Status().Update(ctx, policy)
+	}
+	pvcSelector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PVCSelector)
+	if err != nil {
+		r.setCondition(policy, conditions.NotReadyCondition("InvalidSelector", fmt.Sprintf("Invalid PVC selector: %v", err)))
+		return ctrl.Result{}, r.Status().Update(ctx, policy)
+	}
+
+	pvcs, err := selectPVCs(ctx, r.Client, "", namespaceSelector, pvcSelector)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	backups := make([]backupv1alpha1.DiscoveredPVC, 0, len(pvcs))
+	keep := map[types.NamespacedName]bool{}
+	namespaceBackups := map[string][]backupv1alpha1.ResticBackup{}
+	var skipped, failed int32
+	for _, pvc := range pvcs {
+		existing, ok := namespaceBackups[pvc.Namespace]
+		if !ok {
+			list := &backupv1alpha1.ResticBackupList{}
+			if err := r.List(ctx, list, client.InNamespace(pvc.Namespace)); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to list ResticBackups of namespace %s: %w", pvc.Namespace, err)
+			}
+			existing = list.Items
+			namespaceBackups[pvc.Namespace] = existing
+		}
+		if backedUpByOthers(existing, policy, pvc.Name) {
+			skipped++
+			continue
+		}
+
+		entry, err := r.reconcileBackup(ctx, policy, pvc)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		keep[types.NamespacedName{Name: entry.Backup, Namespace: entry.Namespace}] = true
+		if entry.Message != "" {
+			failed++
+		}
+		backups = append(backups, entry)
+	}
+	if err := r.deleteStaleBackups(ctx, policy, keep); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	policy.Status.Backups = backups
+	policy.Status.SelectedPVCs = int32(len(backups))
+	policy.Status.SkippedPVCs = skipped
+	message := fmt.Sprintf("%d PVCs selected, %d skipped", len(backups), skipped)
+	if failed > 0 {
+		r.setCondition(policy, conditions.NotReadyCondition("BackupsFailed", fmt.Sprintf("%s, %d not backed up", message, failed)))
+	} else {
+		r.setCondition(policy, conditions.ReadyCondition("BackupsConfigured", message))
+	}
+	return ctrl.Result{}, r.Status().Update(ctx, policy)
+}
+
+// reconcileBackup creates or updates the ResticBackup of a selected PVC and returns the
+// state of its backup.
+func (r *ClusterBackupPolicyReconciler) reconcileBackup(ctx context.Context, policy *backupv1alpha1.ClusterBackupPolicy, pvc corev1.PersistentVolumeClaim) (backupv1alpha1.DiscoveredPVC, error) {
+	desired := buildPolicyBackup(policy, pvc.Namespace, pvc.Name)
+	entry := backupv1alpha1.DiscoveredPVC{Namespace: pvc.Namespace, ClaimName: pvc.Name, Backup: desired.Name}
+	if err := controllerutil.SetControllerReference(policy, desired, r.Scheme); err != nil {
+		return entry, fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	current := &backupv1alpha1.ResticBackup{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(desired), current)
+	switch {
+	case apierrors.IsNotFound(err):
+		if err := r.Create(ctx, desired); err != nil {
+			if apierrors.IsInvalid(err) || apierrors.IsForbidden(err) {
+				entry.Message = fmt.Sprintf("Failed to create ResticBackup %s: %v", desired.Name, err)
+				return entry, nil
+			}
+			return entry, fmt.Errorf("failed to create ResticBackup %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+		r.Recorder.Event(policy, corev1.EventTypeNormal, "BackupCreated",
+			fmt.Sprintf("Created ResticBackup %s/%s for PVC %s", desired.Namespace, desired.Name, pvc.Name))
+		return entry, nil
+	case err != nil:
+		return entry, fmt.Errorf("failed to get ResticBackup %s/%s: %w", desired.Namespace, desired.Name, err)
+	case !metav1.IsControlledBy(current, policy):
+		entry.Message = fmt.Sprintf("ResticBackup %s already exists and is not managed by the policy", desired.Name)
+		return entry, nil
+	}
+
+	// Labels and annotations set by others are kept
+	changed := !equality.Semantic.DeepEqual(current.Spec, desired.Spec)
+	current.Spec = desired.Spec
+	if current.Labels == nil {
+		current.Labels = map[string]string{}
+	}
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+	for key, value := range desired.Labels {
+		changed = changed || current.Labels[key] != value
+		current.Labels[key] = value
+	}
+	for key, value := range desired.Annotations {
+		changed = changed || current.Annotations[key] != value
+		current.Annotations[key] = value
+	}
+	if changed {
+		if err := r.Update(ctx, current); err != nil {
+			return entry, fmt.Errorf("failed to update ResticBackup %s/%s: %w", current.Namespace, current.Name, err)
+		}
+	}
+
+	recordDiscoveredBackup(&entry, current)
+	return entry, nil
+}
+
+// deleteStaleBackups deletes the ResticBackups of the policy whose PVC is no longer
+// selected or is backed up by another ResticBackup.
+func (r *ClusterBackupPolicyReconciler) deleteStaleBackups(ctx context.Context, policy *backupv1alpha1.ClusterBackupPolicy, keep map[types.NamespacedName]bool) error {
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := r.List(ctx, backups, client.MatchingLabels{clusterBackupPolicyLabel: policy.Name}); err != nil {
+		return fmt.Errorf("failed to list ResticBackups of policy: %w", err)
+	}
+	for i := range backups.Items {
+		backup := &backups.Items[i]
+		if keep[client.ObjectKeyFromObject(backup)] || !metav1.IsControlledBy(backup, policy) || !backup.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.Delete(ctx, backup); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete ResticBackup %s/%s: %w", backup.Namespace, backup.Name, err)
+		}
+		r.Recorder.Event(policy, corev1.EventTypeNormal, "BackupDeleted",
+			fmt.Sprintf("Deleted ResticBackup %s/%s of a PVC that is no longer selected", backup.Namespace, backup.Name))
+	}
+	return nil
+}
+
+// backedUpByOthers reports whether a ResticBackup not created by the policy backs up
+// the PVC.
+func backedUpByOthers(backups []backupv1alpha1.ResticBackup, policy *backupv1alpha1.ClusterBackupPolicy, claimName string) bool {
+	for i := range backups {
+		if metav1.IsControlledBy(&backups[i], policy) {
+			continue
+		}
+		for _, source := range pvcSources(&backups[i]) {
+			if source.ClaimName == claimName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// buildPolicyBackup builds the ResticBackup of a PVC selected by a policy. Fields the API
+// server defaults are set, so an unchanged ResticBackup compares equal.
+func buildPolicyBackup(policy *backupv1alpha1.ClusterBackupPolicy, namespace, claimName string) *backupv1alpha1.ResticBackup {
+	template := policy.Spec.Template.Spec.DeepCopy()
+	if template.FallbackAfter == nil {
+		template.FallbackAfter = &metav1.Duration{Duration: 30 * time.Minute}
+	}
+
+	labels := maps.Clone(policy.Spec.Template.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	maps.Copy(labels, map[string]string{
+		"app.kubernetes.io/managed-by": "restic-backup-operator",
+		clusterBackupPolicyLabel:       policy.Name,
+	})
+
+	return &backupv1alpha1.ResticBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        discoveredBackupName(policy.Name, claimName),
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: maps.Clone(policy.Spec.Template.Annotations),
+		},
+		Spec: backupv1alpha1.ResticBackupSpec{
+			RepositoryRef:         template.RepositoryRef,
+			FallbackRepositoryRef: template.FallbackRepositoryRef,
+			FallbackAfter:         template.FallbackAfter,
+			Schedule:              template.Schedule,
+			Timezone:              template.Timezone,
+			Source: backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{
+				ClaimName: claimName,
+				Excludes:  slices.Clone(policy.Spec.Excludes),
+			}},
+			Restic:            template.Restic,
+			Retention:         template.Retention,
+			Notifications:     template.Notifications,
+			JobConfig:         template.JobConfig,
+			Suspend:           template.Suspend,
+			SuspendMode:       backupv1alpha1.SuspendModeSchedule,
+			BackupWindow:      template.BackupWindow,
+			BlackoutPeriods:   template.BlackoutPeriods,
+			AutoTuneResources: template.AutoTuneResources,
+			Retry:             template.Retry,
+			MaxStaleness:      template.MaxStaleness,
+		},
+	}
+}
+
+func (r *ClusterBackupPolicyReconciler) setCondition(policy *backupv1alpha1.ClusterBackupPolicy, condition metav1.Condition) {
+	conditions.SetCondition(&policy.Status.Conditions, condition)
+}
+
+// policiesSelecting returns the ClusterBackupPolicies whose namespace selector matches
+// the namespace labels and whose PVC selector matches any of the PVC labels. Without
+// PVC labels, the namespace selector alone decides.
+func (r *ClusterBackupPolicyReconciler) policiesSelecting(ctx context.Context, namespaceLabels labels.Set, pvcLabels []labels.Set) []reconcile.Request {
+	policies := &backupv1alpha1.ClusterBackupPolicyList{}
+	if err := r.List(ctx, policies); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list cluster backup policies")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		namespaceSelector, err := metav1.LabelSelectorAsSelector(&policy.Spec.NamespaceSelector)
+		if err != nil || !namespaceSelector.Matches(namespaceLabels) {
+			continue
+		}
+		pvcSelector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PVCSelector)
+		if err != nil {
+			continue
+		}
+		if pvcLabels == nil || slices.ContainsFunc(pvcLabels, func(set labels.Set) bool { return pvcSelector.Matches(set) }) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policy)})
+		}
+	}
+	return requests
+}
+
+// namespaceLabels returns the labels of a namespace, false if it doesn't exist.
+func (r *ClusterBackupPolicyReconciler) namespaceLabels(ctx context.Context, name string) (labels.Set, bool) {
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, namespace); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "Failed to get namespace", "namespace", name)
+		}
+		return nil, false
+	}
+	return namespace.Labels, true
+}
+
+// policiesForNamespace enqueues the ClusterBackupPolicies selecting a namespace. Both the
+// old and the new namespace are mapped, so policies no longer selecting it remove their
+// ResticBackups.
+func (r *ClusterBackupPolicyReconciler) policiesForNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.policiesSelecting(ctx, obj.GetLabels(), nil)
+}
+
+// policiesForPVC enqueues the ClusterBackupPolicies selecting a PVC.
+func (r *ClusterBackupPolicyReconciler) policiesForPVC(ctx context.Context, obj client.Object) []reconcile.Request {
+	namespaceLabels, ok := r.namespaceLabels(ctx, obj.GetNamespace())
+	if !ok {
+		return nil
+	}
+	return r.policiesSelecting(ctx, namespaceLabels, []labels.Set{obj.GetLabels()})
+}
+
+// policiesForBackup enqueues the ClusterBackupPolicy that created a ResticBackup. For
+// other ResticBackups, it enqueues the policies selecting a PVC they back up, as these
+// skip that PVC.
+func (r *ClusterBackupPolicyReconciler) policiesForBackup(ctx context.Context, obj client.Object) []reconcile.Request {
+	if owner := metav1.GetControllerOf(obj); owner != nil && owner.Kind == "ClusterBackupPolicy" {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: owner.Name}}}
+	}
+	backup, ok := obj.(*backupv1alpha1.ResticBackup)
+	if !ok {
+		return nil
+	}
+	var pvcLabels []labels.Set
+	for _, source := range pvcSources(backup) {
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := r.Get(ctx, types.NamespacedName{Name: source.ClaimName, Namespace: backup.Namespace}, pvc); err == nil {
+			pvcLabels = append(pvcLabels, pvc.Labels)
+		}
+	}
+	if len(pvcLabels) == 0 {
+		return nil
+	}
+	namespaceLabels, ok := r.namespaceLabels(ctx, backup.Namespace)
+	if !ok {
+		return nil
+	}
+	return r.policiesSelecting(ctx, namespaceLabels, pvcLabels)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterBackupPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&backupv1alpha1.ClusterBackupPolicy{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&backupv1alpha1.ResticBackup{}, handler.EnqueueRequestsFromMapFunc(r.policiesForBackup)).
+		Watches(&corev1.PersistentVolumeClaim{}, handler.EnqueueRequestsFromMapFunc(r.policiesForPVC)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.policiesForNamespace)).
+		Complete(r)
+}
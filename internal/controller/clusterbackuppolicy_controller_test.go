@@ -0,0 +1,249 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("ClusterBackupPolicy Controller", func() {
+	var (
+		ctx        context.Context
+		testScheme *runtime.Scheme
+		policy     *backupv1alpha1.ClusterBackupPolicy
+		objects    []client.Object
+		recorder   *record.FakeRecorder
+	)
+	key := types.NamespacedName{Name: "labeled-pvcs"}
+
+	pvc := func(namespace, name string, labels map[string]string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		recorder = record.NewFakeRecorder(20)
+		policy = &backupv1alpha1.ClusterBackupPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, UID: "policy-uid"},
+			Spec: backupv1alpha1.ClusterBackupPolicySpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"backup": "enabled"}},
+				PVCSelector:       metav1.LabelSelector{MatchLabels: map[string]string{"backup": "true"}},
+				Excludes:          []string{"*.tmp"},
+				Template: backupv1alpha1.ClusterBackupTemplate{
+					Labels: map[string]string{"team": "platform"},
+					Spec: backupv1alpha1.ClusterBackupTemplateSpec{
+						RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "restic-repository"},
+						Schedule:      "0 2 * * *",
+						Timezone:      "UTC",
+					},
+				},
+			},
+		}
+		objects = []client.Object{
+			policy,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"backup": "enabled"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "blog", Labels: map[string]string{"backup": "enabled"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sandbox"}},
+			pvc("shop", "data", map[string]string{"backup": "true"}),
+			pvc("shop", "cache", nil),
+			pvc("blog", "uploads", map[string]string{"backup": "true"}),
+			pvc("sandbox", "data", map[string]string{"backup": "true"}),
+		}
+	})
+
+	build := func() client.Client {
+		return fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).
+			WithStatusSubresource(&backupv1alpha1.ClusterBackupPolicy{}, &backupv1alpha1.ResticBackup{}).Build()
+	}
+	reconcile := func(c client.Client) {
+		reconciler := &ClusterBackupPolicyReconciler{Client: c, Scheme: testScheme, Recorder: recorder}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(ctx, key, policy)).To(Succeed())
+	}
+
+	It("creates a ResticBackup for every selected PVC in its namespace", func() {
+		c := build()
+		reconcile(c)
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "labeled-pvcs-data", Namespace: "shop"}, backup)).To(Succeed())
+		Expect(backup.Spec.Source).To(Equal(backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{
+			ClaimName: "data",
+			Excludes:  []string{"*.tmp"},
+		}}))
+		Expect(backup.Spec.RepositoryRef).To(Equal(backupv1alpha1.CrossNamespaceObjectReference{Name: "restic-repository"}))
+		Expect(backup.Spec.Schedule).To(Equal("0 2 * * *"))
+		Expect(backup.Labels).To(HaveKeyWithValue("team", "platform"))
+		Expect(backup.Labels).To(HaveKeyWithValue(clusterBackupPolicyLabel, key.Name))
+		Expect(metav1.IsControlledBy(backup, policy)).To(BeTrue())
+
+		backups := &backupv1alpha1.ResticBackupList{}
+		Expect(c.List(ctx, backups, client.InNamespace("sandbox"))).To(Succeed())
+		Expect(backups.Items).To(BeEmpty())
+
+		Expect(policy.Status.Backups).To(Equal([]backupv1alpha1.DiscoveredPVC{
+			{Namespace: "blog", ClaimName: "uploads", Backup: "labeled-pvcs-uploads"},
+			{Namespace: "shop", ClaimName: "data", Backup: "labeled-pvcs-data"},
+		}))
+		Expect(policy.Status.SelectedPVCs).To(Equal(int32(2)))
+		condition := conditions.GetCondition(policy.Status.Conditions, backupv1alpha1.ConditionReady)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("BackupsConfigured"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("BackupCreated")))
+	})
+
+	It("skips PVCs backed up by another ResticBackup of their namespace", func() {
+		objects = append(objects, &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop-data", Namespace: "shop"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "restic-repository"},
+				Schedule:      "0 * * * *",
+				Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		})
+		c := build()
+		reconcile(c)
+
+		Expect(c.Get(ctx, types.NamespacedName{Name: "labeled-pvcs-data", Namespace: "shop"}, &backupv1alpha1.ResticBackup{})).NotTo(Succeed())
+		Expect(policy.Status.SelectedPVCs).To(Equal(int32(1)))
+		Expect(policy.Status.SkippedPVCs).To(Equal(int32(1)))
+	})
+
+	It("deletes the ResticBackups of PVCs that are no longer selected", func() {
+		c := build()
+		reconcile(c)
+
+		claim := &corev1.PersistentVolumeClaim{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "uploads", Namespace: "blog"}, claim)).To(Succeed())
+		claim.Labels = nil
+		Expect(c.Update(ctx, claim)).To(Succeed())
+		reconcile(c)
+
+		Expect(c.Get(ctx, types.NamespacedName{Name: "labeled-pvcs-uploads", Namespace: "blog"}, &backupv1alpha1.ResticBackup{})).NotTo(Succeed())
+		Expect(c.Get(ctx, types.NamespacedName{Name: "labeled-pvcs-data", Namespace: "shop"}, &backupv1alpha1.ResticBackup{})).To(Succeed())
+		Expect(policy.Status.SelectedPVCs).To(Equal(int32(1)))
+	})
+
+	It("updates the ResticBackups when the template changes", func() {
+		c := build()
+		reconcile(c)
+
+		policy.Spec.Template.Spec.Schedule = "30 3 * * *"
+		Expect(c.Update(ctx, policy)).To(Succeed())
+		reconcile(c)
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "labeled-pvcs-data", Namespace: "shop"}, backup)).To(Succeed())
+		Expect(backup.Spec.Schedule).To(Equal("30 3 * * *"))
+	})
+
+	It("reports a ResticBackup of the same name that it does not manage", func() {
+		objects = append(objects, &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "labeled-pvcs-uploads", Namespace: "blog"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "restic-repository"},
+				Schedule:      "0 * * * *",
+				Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "other"}},
+			},
+		})
+		c := build()
+		reconcile(c)
+
+		Expect(policy.Status.Backups).To(ContainElement(backupv1alpha1.DiscoveredPVC{
+			Namespace: "blog", ClaimName: "uploads", Backup: "labeled-pvcs-uploads",
+			Message: "ResticBackup labeled-pvcs-uploads already exists and is not managed by the policy",
+		}))
+		condition := conditions.GetCondition(policy.Status.Conditions, backupv1alpha1.ConditionReady)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("BackupsFailed"))
+	})
+
+	It("doesn't update unchanged ResticBackups", func() {
+		c := build()
+		reconcile(c)
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "labeled-pvcs-data", Namespace: "shop"}, backup)).To(Succeed())
+		Expect(backup.Spec.SuspendMode).To(Equal(backupv1alpha1.SuspendModeSchedule))
+		resourceVersion := backup.ResourceVersion
+		reconcile(c)
+
+		Expect(c.Get(ctx, types.NamespacedName{Name: "labeled-pvcs-data", Namespace: "shop"}, backup)).To(Succeed())
+		Expect(backup.ResourceVersion).To(Equal(resourceVersion))
+	})
+
+	It("maps PVCs, namespaces and ResticBackups only to the policies selecting them", func() {
+		other := policy.DeepCopy()
+		other.Name = "media"
+		other.Spec.PVCSelector = metav1.LabelSelector{MatchLabels: map[string]string{"tier": "media"}}
+		objects = append(objects, other, &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop-data", Namespace: "shop"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Source: backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		})
+		c := build()
+		reconciler := &ClusterBackupPolicyReconciler{Client: c, Scheme: testScheme, Recorder: recorder}
+		request := ctrl.Request{NamespacedName: key}
+
+		Expect(reconciler.policiesForPVC(ctx, pvc("shop", "data", map[string]string{"backup": "true"}))).To(ConsistOf(request))
+		Expect(reconciler.policiesForPVC(ctx, pvc("sandbox", "data", map[string]string{"backup": "true"}))).To(BeEmpty())
+		Expect(reconciler.policiesForPVC(ctx, pvc("shop", "cache", nil))).To(BeEmpty())
+		Expect(reconciler.policiesForNamespace(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sandbox"}})).To(BeEmpty())
+		Expect(reconciler.policiesForNamespace(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "blog", Labels: map[string]string{"backup": "enabled"},
+		}})).To(HaveLen(2))
+
+		backup := &backupv1alpha1.ResticBackup{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "shop-data", Namespace: "shop"}, backup)).To(Succeed())
+		Expect(reconciler.policiesForBackup(ctx, backup)).To(ConsistOf(request))
+		reconcile(c)
+		Expect(c.Get(ctx, types.NamespacedName{Name: "labeled-pvcs-uploads", Namespace: "blog"}, backup)).To(Succeed())
+		Expect(reconciler.policiesForBackup(ctx, backup)).To(ConsistOf(request))
+	})
+
+	It("rejects an invalid PVC selector", func() {
+		policy.Spec.PVCSelector.MatchExpressions = []metav1.LabelSelectorRequirement{{Key: "backup", Operator: "Near"}}
+		c := build()
+		reconcile(c)
+
+		condition := conditions.GetCondition(policy.Status.Conditions, backupv1alpha1.ConditionReady)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal("InvalidSelector"))
+	})
+})
@@ -0,0 +1,271 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// credentialKeys holds the keys of the repository credentials in the credentials secret.
+type credentialKeys struct {
+	Password           string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+}
+
+// repositoryCredentialKeys returns the keys of the repository credentials. The password
+// key is taken from the key mapping, then from credentialsSecretRef.key.
+func repositoryCredentialKeys(repository *backupv1alpha1.ResticRepository) credentialKeys {
+	keys := credentialKeys{
+		Password:           "RESTIC_PASSWORD",
+		AWSAccessKeyID:     "AWS_ACCESS_KEY_ID",
+		AWSSecretAccessKey: "AWS_SECRET_ACCESS_KEY",
+	}
+	if repository.Spec.CredentialsSecretRef.Key != "" {
+		keys.Password = repository.Spec.CredentialsSecretRef.Key
+	}
+
+	mapping := repository.Spec.CredentialsKeyMapping
+	if mapping == nil {
+		return keys
+	}
+	if mapping.Password != "" {
+		keys.Password = mapping.Password
+	}
+	if mapping.AWSAccessKeyID != "" {
+		keys.AWSAccessKeyID = mapping.AWSAccessKeyID
+	}
+	if mapping.AWSSecretAccessKey != "" {
+		keys.AWSSecretAccessKey = mapping.AWSSecretAccessKey
+	}
+	return keys
+}
+
+const (
+	// credentialsVolumeName is the volume holding credential files of the repository backend.
+	credentialsVolumeName = "restic-credentials"
+	// credentialsMountPath is where credential files are mounted in the restic container.
+	credentialsMountPath = "/etc/restic/credentials"
+
+	googleCredentialsKey  = "GOOGLE_APPLICATION_CREDENTIALS"
+	googleCredentialsFile = "gcs-credentials.json"
+	sshPrivateKeyKey      = "SSH_PRIVATE_KEY"
+	sshPrivateKeyFile     = "id_ssh"
+	sshKnownHostsKey      = "SSH_KNOWN_HOSTS"
+	sshKnownHostsFile     = "known_hosts"
+)
+
+// backendEnvKeys lists the optional credentials secret keys passed as environment
+// variables of the same name, per repository URL scheme.
+var backendEnvKeys = map[string][]string{
+	"azure": {"AZURE_ACCOUNT_NAME", "AZURE_ACCOUNT_KEY", "AZURE_ACCOUNT_SAS"},
+	"gs":    {"GOOGLE_PROJECT_ID"},
+	"b2":    {"B2_ACCOUNT_ID", "B2_ACCOUNT_KEY"},
+	"rest":  {"RESTIC_REST_USERNAME", "RESTIC_REST_PASSWORD"},
+}
+
+// repositoryScheme returns the backend of the repository URL, e.g. "s3" or "sftp".
+func repositoryScheme(repository *backupv1alpha1.ResticRepository) string {
+	scheme, _, _ := strings.Cut(repository.Spec.RepositoryURL, ":")
+	return scheme
+}
+
+// repositoryEnvVars returns the environment variables restic needs to access the
+// repository. Credentials are read from the credentials secret; the AWS credentials
+// and the credentials of the other backends are optional, so only keys present in
+// the secret are set.
+func repositoryEnvVars(repository *backupv1alpha1.ResticRepository) []corev1.EnvVar {
+	keys := repositoryCredentialKeys(repository)
+	secretKeyRef := func(key string, optional bool) *corev1.EnvVarSource {
+		selector := &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: repository.Spec.CredentialsSecretRef.Name,
+			},
+			Key: key,
+		}
+		if optional {
+			selector.Optional = boolPtr(true)
+		}
+		return &corev1.EnvVarSource{SecretKeyRef: selector}
+	}
+
+	envVars := []corev1.EnvVar{
+		{
+			Name:  "RESTIC_REPOSITORY",
+			Value: repository.Spec.RepositoryURL,
+		},
+		{
+			Name:      "RESTIC_PASSWORD",
+			ValueFrom: secretKeyRef(keys.Password, false),
+		},
+		{
+			Name:      "AWS_ACCESS_KEY_ID",
+			ValueFrom: secretKeyRef(keys.AWSAccessKeyID, true),
+		},
+		{
+			Name:      "AWS_SECRET_ACCESS_KEY",
+			ValueFrom: secretKeyRef(keys.AWSSecretAccessKey, true),
+		},
+	}
+
+	scheme := repositoryScheme(repository)
+	for _, key := range backendEnvKeys[scheme] {
+		envVars = append(envVars, corev1.EnvVar{Name: key, ValueFrom: secretKeyRef(key, true)})
+	}
+	if scheme == "gs" {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  googleCredentialsKey,
+			Value: path.Join(credentialsMountPath, googleCredentialsFile),
+		})
+	}
+	if scheme == "sftp" {
+		// ssh runs as the user of the pod, which has no home directory in the image
+		envVars = append(envVars, corev1.EnvVar{Name: "HOME", Value: "/tmp"})
+	}
+
+	return envVars
+}
+
+// repositoryCredentialFiles returns the credentials secret keys mounted as files, as
+// the GCS and SFTP backends read their credentials from files.
+func repositoryCredentialFiles(repository *backupv1alpha1.ResticRepository) []corev1.KeyToPath {
+	switch repositoryScheme(repository) {
+	case "gs":
+		return []corev1.KeyToPath{{Key: googleCredentialsKey, Path: googleCredentialsFile}}
+	case "sftp":
+		return []corev1.KeyToPath{
+			{Key: sshPrivateKeyKey, Path: sshPrivateKeyFile},
+			{Key: sshKnownHostsKey, Path: sshKnownHostsFile},
+		}
+	}
+	return nil
+}
+
+// applyRepositoryCredentials passes the envFromSecret of the repository to the restic
+// containers of the pod and mounts the credential files of the repository backend.
+func applyRepositoryCredentials(podSpec *corev1.PodSpec, repository *backupv1alpha1.ResticRepository) {
+	if ref := repository.Spec.EnvFromSecret; ref != nil && ref.Name != "" {
+		for i := range podSpec.Containers {
+			container := &podSpec.Containers[i]
+			if container.Name != "restic" {
+				continue
+			}
+			container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: *ref},
+			})
+		}
+	}
+
+	items := repositoryCredentialFiles(repository)
+	if len(items) == 0 {
+		return
+	}
+
+	// The volume is optional so that missing keys fail in restic with a clear
+	// error instead of blocking the pod start
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: credentialsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  repository.Spec.CredentialsSecretRef.Name,
+				Items:       items,
+				DefaultMode: int32Ptr(0o400),
+				Optional:    boolPtr(true),
+			},
+		},
+	})
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != "restic" {
+			continue
+		}
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      credentialsVolumeName,
+			MountPath: credentialsMountPath,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// repositoryOptions returns the extended restic options the repository backend needs.
+// SFTP repositories use the mounted private key and known hosts.
+func repositoryOptions(repository *backupv1alpha1.ResticRepository) []string {
+	if repositoryScheme(repository) != "sftp" {
+		return nil
+	}
+	sshArgs := fmt.Sprintf("-i %s -o UserKnownHostsFile=%s",
+		path.Join(credentialsMountPath, sshPrivateKeyFile),
+		path.Join(credentialsMountPath, sshKnownHostsFile))
+	return []string{"-o", "sftp.args=" + sshArgs}
+}
+
+// repositoryCredentials reads the credentials of the repository for restic commands run
+// by the operator itself.
+func repositoryCredentials(ctx context.Context, reader client.Reader, repository *backupv1alpha1.ResticRepository) (restic.Credentials, error) {
+	secret := &corev1.Secret{}
+	secretName := types.NamespacedName{
+		Name:      repository.Spec.CredentialsSecretRef.Name,
+		Namespace: repository.Namespace,
+	}
+
+	if err := reader.Get(ctx, secretName, secret); err != nil {
+		return restic.Credentials{}, fmt.Errorf("failed to get credentials secret: %w", err)
+	}
+
+	keys := repositoryCredentialKeys(repository)
+	password, ok := secret.Data[keys.Password]
+	if !ok {
+		return restic.Credentials{}, fmt.Errorf("%s not found in secret", keys.Password)
+	}
+
+	creds := restic.Credentials{
+		Repository: repository.Spec.RepositoryURL,
+		Password:   string(password),
+	}
+
+	// Optional backend credentials
+	optional := map[string]*string{
+		keys.AWSAccessKeyID:     &creds.AWSAccessKeyID,
+		keys.AWSSecretAccessKey: &creds.AWSSecretAccessKey,
+		"AZURE_ACCOUNT_NAME":    &creds.AzureAccountName,
+		"AZURE_ACCOUNT_KEY":     &creds.AzureAccountKey,
+		"AZURE_ACCOUNT_SAS":     &creds.AzureAccountSAS,
+		"GOOGLE_PROJECT_ID":     &creds.GoogleProjectID,
+		googleCredentialsKey:    &creds.GoogleApplicationCredentials,
+		"B2_ACCOUNT_ID":         &creds.B2AccountID,
+		"B2_ACCOUNT_KEY":        &creds.B2AccountKey,
+		"RESTIC_REST_USERNAME":  &creds.RESTUsername,
+		"RESTIC_REST_PASSWORD":  &creds.RESTPassword,
+	}
+	for key, value := range optional {
+		if data, ok := secret.Data[key]; ok {
+			*value = string(data)
+		}
+	}
+
+	return creds, nil
+}
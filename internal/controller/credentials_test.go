@@ -0,0 +1,182 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Repository credentials", func() {
+	var repository *backupv1alpha1.ResticRepository
+
+	BeforeEach(func() {
+		repository = &backupv1alpha1.ResticRepository{
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL: "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{
+					Name: "credentials",
+				},
+			},
+		}
+	})
+
+	Context("repositoryCredentialKeys helper function", func() {
+		It("should default to the restic environment variable names", func() {
+			Expect(repositoryCredentialKeys(repository)).To(Equal(credentialKeys{
+				Password:           "RESTIC_PASSWORD",
+				AWSAccessKeyID:     "AWS_ACCESS_KEY_ID",
+				AWSSecretAccessKey: "AWS_SECRET_ACCESS_KEY",
+			}))
+		})
+
+		It("should use the key of the secret reference for the password", func() {
+			repository.Spec.CredentialsSecretRef.Key = "password"
+			Expect(repositoryCredentialKeys(repository).Password).To(Equal("password"))
+		})
+
+		It("should prefer the key mapping", func() {
+			repository.Spec.CredentialsSecretRef.Key = "password"
+			repository.Spec.CredentialsKeyMapping = &backupv1alpha1.CredentialsKeyMapping{
+				Password:       "restic-password",
+				AWSAccessKeyID: "accessKey",
+			}
+			Expect(repositoryCredentialKeys(repository)).To(Equal(credentialKeys{
+				Password:           "restic-password",
+				AWSAccessKeyID:     "accessKey",
+				AWSSecretAccessKey: "AWS_SECRET_ACCESS_KEY",
+			}))
+		})
+	})
+
+	Context("repositoryEnvVars helper function", func() {
+		It("should read the mapped keys from the credentials secret", func() {
+			repository.Spec.CredentialsKeyMapping = &backupv1alpha1.CredentialsKeyMapping{
+				AWSAccessKeyID:     "accessKey",
+				AWSSecretAccessKey: "secretKey",
+			}
+
+			envVars := repositoryEnvVars(repository)
+			Expect(envVars).To(HaveLen(4))
+			Expect(envVars[0].Value).To(Equal("s3:s3.amazonaws.com/bucket"))
+
+			password := envVars[1].ValueFrom.SecretKeyRef
+			Expect(envVars[1].Name).To(Equal("RESTIC_PASSWORD"))
+			Expect(password.Name).To(Equal("credentials"))
+			Expect(password.Key).To(Equal("RESTIC_PASSWORD"))
+			Expect(password.Optional).To(BeNil())
+
+			Expect(envVars[2].Name).To(Equal("AWS_ACCESS_KEY_ID"))
+			Expect(envVars[2].ValueFrom.SecretKeyRef.Key).To(Equal("accessKey"))
+			Expect(*envVars[2].ValueFrom.SecretKeyRef.Optional).To(BeTrue())
+			Expect(envVars[3].Name).To(Equal("AWS_SECRET_ACCESS_KEY"))
+			Expect(envVars[3].ValueFrom.SecretKeyRef.Key).To(Equal("secretKey"))
+		})
+	})
+
+	Context("backend credentials", func() {
+		envNames := func(envVars []corev1.EnvVar) []string {
+			names := make([]string, 0, len(envVars))
+			for _, env := range envVars {
+				names = append(names, env.Name)
+			}
+			return names
+		}
+
+		It("should pass the optional keys of the repository backend", func() {
+			repository.Spec.RepositoryURL = "azure:backups:/"
+			Expect(envNames(repositoryEnvVars(repository))).To(ContainElements("AZURE_ACCOUNT_NAME", "AZURE_ACCOUNT_KEY", "AZURE_ACCOUNT_SAS"))
+			Expect(envNames(repositoryEnvVars(repository))).NotTo(ContainElement("B2_ACCOUNT_ID"))
+
+			repository.Spec.RepositoryURL = "rest:https://backup.example.com/"
+			Expect(envNames(repositoryEnvVars(repository))).To(ContainElements("RESTIC_REST_USERNAME", "RESTIC_REST_PASSWORD"))
+		})
+
+		It("should mount the GCS service account key", func() {
+			repository.Spec.RepositoryURL = "gs:bucket:/"
+			Expect(repositoryEnvVars(repository)).To(ContainElement(corev1.EnvVar{
+				Name:  "GOOGLE_APPLICATION_CREDENTIALS",
+				Value: "/etc/restic/credentials/gcs-credentials.json",
+			}))
+
+			podSpec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "restic"}}}
+			applyRepositoryCredentials(podSpec, repository)
+			Expect(podSpec.Volumes).To(HaveLen(1))
+			Expect(podSpec.Volumes[0].Secret.SecretName).To(Equal("credentials"))
+			Expect(podSpec.Volumes[0].Secret.Items).To(ConsistOf(corev1.KeyToPath{Key: "GOOGLE_APPLICATION_CREDENTIALS", Path: "gcs-credentials.json"}))
+			Expect(podSpec.Containers[0].VolumeMounts).To(ConsistOf(HaveField("MountPath", "/etc/restic/credentials")))
+			Expect(repositoryOptions(repository)).To(BeEmpty())
+		})
+
+		It("should mount the SSH key of SFTP repositories", func() {
+			repository.Spec.RepositoryURL = "sftp:backup@nas:/restic"
+			podSpec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "restic"}}}
+			applyRepositoryCredentials(podSpec, repository)
+			Expect(podSpec.Volumes[0].Secret.Items).To(HaveLen(2))
+			Expect(repositoryOptions(repository)).To(Equal([]string{
+				"-o", "sftp.args=-i /etc/restic/credentials/id_ssh -o UserKnownHostsFile=/etc/restic/credentials/known_hosts",
+			}))
+			Expect(repositoryEnvVars(repository)).To(ContainElement(corev1.EnvVar{Name: "HOME", Value: "/tmp"}))
+		})
+
+		It("should pass all keys of the envFromSecret to the restic containers", func() {
+			repository.Spec.EnvFromSecret = &corev1.LocalObjectReference{Name: "restic-env"}
+			podSpec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "restic"}, {Name: "sidecar"}}}
+			applyRepositoryCredentials(podSpec, repository)
+			Expect(podSpec.Containers[0].EnvFrom).To(ConsistOf(corev1.EnvFromSource{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "restic-env"}},
+			}))
+			Expect(podSpec.Containers[1].EnvFrom).To(BeEmpty())
+		})
+
+		It("should not mount files for other backends", func() {
+			podSpec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "restic"}}}
+			applyRepositoryCredentials(podSpec, repository)
+			Expect(podSpec.Volumes).To(BeEmpty())
+		})
+
+		It("should read the backend keys present in the secret", func() {
+			repository.Namespace = "default"
+			repository.Spec.RepositoryURL = "b2:bucket:/"
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			reader := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+				Data: map[string][]byte{
+					"RESTIC_PASSWORD": []byte("password"),
+					"B2_ACCOUNT_ID":   []byte("b2-id"),
+					"B2_ACCOUNT_KEY":  []byte("b2-key"),
+				},
+			}).Build()
+
+			creds, err := repositoryCredentials(context.Background(), reader, repository)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(creds.B2AccountID).To(Equal("b2-id"))
+			Expect(creds.B2AccountKey).To(Equal("b2-key"))
+			Expect(creds.AzureAccountName).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// dumpVolumeName is the shared volume the dump container writes the dump to.
+	dumpVolumeName = "dump"
+	// dumpMountPath is the path of the dump volume in the dump and restic containers.
+	dumpMountPath = "/dump"
+)
+
+// defaultDumpImages are the dump tool images used if a database source sets no image.
+var defaultDumpImages = map[backupv1alpha1.DatabaseEngine]string{
+	backupv1alpha1.DatabaseEnginePostgres: "postgres:17-alpine",
+	backupv1alpha1.DatabaseEngineMySQL:    "mysql:8.4",
+	backupv1alpha1.DatabaseEngineMongoDB:  "mongo:7.0",
+}
+
+// dumpEnvKeys maps the environment variables of the dump container to the keys of the
+// connection Secret. The variables are the ones read by the dump tool where available.
+var dumpEnvKeys = map[backupv1alpha1.DatabaseEngine][][2]string{
+	backupv1alpha1.DatabaseEnginePostgres: {
+		{"PGHOST", "host"}, {"PGPORT", "port"}, {"PGUSER", "username"}, {"PGPASSWORD", "password"}, {"PGDATABASE", "database"},
+	},
+	backupv1alpha1.DatabaseEngineMySQL: {
+		{"MYSQL_HOST", "host"}, {"MYSQL_TCP_PORT", "port"}, {"DB_USER", "username"}, {"MYSQL_PWD", "password"}, {"DB_NAME", "database"},
+	},
+	backupv1alpha1.DatabaseEngineMongoDB: {
+		{"MONGODB_URI", "uri"},
+	},
+}
+
+// buildDumpScript builds the shell script run by the dump container. The dump is written
+// as a plain file, so that restic can deduplicate unchanged data between dumps.
+func buildDumpScript(source *backupv1alpha1.DatabaseSource) string {
+	args := shellQuoteArgs(source.DumpArgs)
+	switch source.Engine {
+	case backupv1alpha1.DatabaseEngineMySQL:
+		// Dump all databases if no database is configured
+		return fmt.Sprintf(`exec mysqldump ${DB_USER:+--user="$DB_USER"} --single-transaction --routines --result-file=%s/dump.sql %s "${DB_NAME:---all-databases}"`,
+			dumpMountPath, args)
+	case backupv1alpha1.DatabaseEngineMongoDB:
+		return fmt.Sprintf(`exec mongodump --uri="$MONGODB_URI" --archive=%s/dump.archive %s`, dumpMountPath, args)
+	default:
+		return fmt.Sprintf(`exec pg_dump --file=%s/dump.sql %s`, dumpMountPath, args)
+	}
+}
+
+// buildDumpContainer builds the container dumping a database to the dump volume. It runs
+// as init container, so restic only starts after the dump completed successfully.
+func buildDumpContainer(source *backupv1alpha1.DatabaseSource, securityContext *corev1.SecurityContext, resources corev1.ResourceRequirements) corev1.Container {
+	image := source.Image
+	if image == "" {
+		image = defaultDumpImages[source.Engine]
+	}
+
+	keys := dumpEnvKeys[source.Engine]
+	env := make([]corev1.EnvVar, 0, len(keys))
+	for _, key := range keys {
+		env = append(env, corev1.EnvVar{
+			Name: key[0],
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: source.ConnectionSecretRef,
+					Key:                  key[1],
+					// The MongoDB URI is the only connection setting of mongodump
+					Optional: boolPtr(source.Engine != backupv1alpha1.DatabaseEngineMongoDB),
+				},
+			},
+		})
+	}
+
+	return corev1.Container{
+		Name:            "dump",
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"/bin/sh", "-c"},
+		Args:            []string{buildDumpScript(source)},
+		Env:             env,
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      dumpVolumeName,
+			MountPath: dumpMountPath,
+		}},
+		SecurityContext: securityContext,
+		Resources:       resources,
+	}
+}
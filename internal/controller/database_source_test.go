@@ -0,0 +1,107 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Database source", func() {
+	var (
+		reconciler *ResticBackupReconciler
+		repository *backupv1alpha1.ResticRepository
+		backup     *backupv1alpha1.ResticBackup
+	)
+
+	BeforeEach(func() {
+		reconciler = &ResticBackupReconciler{}
+		repository = &backupv1alpha1.ResticRepository{
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:https://s3.example.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "restic-credentials"},
+			},
+		}
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Source: backupv1alpha1.BackupSource{
+					Database: &backupv1alpha1.DatabaseSource{
+						Engine:              backupv1alpha1.DatabaseEnginePostgres,
+						ConnectionSecretRef: corev1.LocalObjectReference{Name: "db-app"},
+						DumpArgs:            []string{"--exclude-table", "audit log"},
+					},
+				},
+			},
+		}
+	})
+
+	It("should dump the database into a shared volume before the backup", func() {
+		podSpec := reconciler.buildPodSpec(backup, repository, "restic", backupScript{})
+
+		Expect(podSpec.Spec.Volumes).To(ContainElement(HaveField("Name", dumpVolumeName)))
+		Expect(podSpec.Spec.InitContainers).To(HaveLen(1))
+		dump := podSpec.Spec.InitContainers[0]
+		Expect(dump.Name).To(Equal("dump"))
+		Expect(dump.Image).To(Equal("postgres:17-alpine"))
+		Expect(dump.Args[0]).To(Equal(`exec pg_dump --file=/dump/dump.sql '--exclude-table' 'audit log'`))
+		Expect(dump.Env).To(ContainElement(HaveField("Name", "PGPASSWORD")))
+		Expect(dump.Env[0].ValueFrom.SecretKeyRef.Name).To(Equal("db-app"))
+		Expect(podSpec.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name: dumpVolumeName, MountPath: dumpMountPath, ReadOnly: true,
+		}))
+	})
+
+	It("should back up the dump volume", func() {
+		cmd := reconciler.buildBackupCommand(backup, "db", nil)
+		Expect(cmd[len(cmd)-1]).To(Equal(dumpMountPath))
+	})
+
+	It("should start sidecars before the dump", func() {
+		backup.Spec.JobConfig = &backupv1alpha1.JobConfiguration{
+			Sidecars: []corev1.Container{{Name: "egress-proxy"}},
+		}
+
+		podSpec := reconciler.buildPodSpec(backup, repository, "restic", backupScript{})
+		Expect(podSpec.Spec.InitContainers).To(HaveLen(2))
+		Expect(podSpec.Spec.InitContainers[0].Name).To(Equal("egress-proxy"))
+		Expect(podSpec.Spec.InitContainers[1].Name).To(Equal("dump"))
+	})
+
+	It("should dump all MySQL databases without configured database", func() {
+		script := buildDumpScript(&backupv1alpha1.DatabaseSource{Engine: backupv1alpha1.DatabaseEngineMySQL})
+		Expect(script).To(ContainSubstring("mysqldump"))
+		Expect(script).To(ContainSubstring(`"${DB_NAME:---all-databases}"`))
+	})
+
+	It("should require the MongoDB connection URI", func() {
+		source := &backupv1alpha1.DatabaseSource{
+			Engine:              backupv1alpha1.DatabaseEngineMongoDB,
+			ConnectionSecretRef: corev1.LocalObjectReference{Name: "mongo"},
+			Image:               "mongo:6.0",
+		}
+		container := buildDumpContainer(source, nil, corev1.ResourceRequirements{})
+		Expect(container.Image).To(Equal("mongo:6.0"))
+		Expect(container.Args[0]).To(ContainSubstring(`mongodump --uri="$MONGODB_URI" --archive=/dump/dump.archive`))
+		Expect(container.Env).To(HaveLen(1))
+		Expect(container.Env[0].ValueFrom.SecretKeyRef.Optional).To(HaveValue(BeFalse()))
+	})
+})
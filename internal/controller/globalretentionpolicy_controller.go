@@ -19,6 +19,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -32,15 +33,27 @@ import (
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
 	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
 )
 
 const (
 	globalRetentionPolicyFinalizer = "backup.resticbackup.io/globalretentionpolicy-finalizer"
+
+	// retentionProtectedTag marks snapshots excluded from retention. Every forget of the
+	// operator keeps them.
+	retentionProtectedTag = "retention-protected"
+
+	// retentionPolicyLabel is the label referencing the GlobalRetentionPolicy of a retention job.
+	retentionPolicyLabel = "backup.resticbackup.io/retentionpolicy"
 )
 
 // GlobalRetentionPolicyReconciler reconciles a GlobalRetentionPolicy object
@@ -48,12 +61,36 @@ type GlobalRetentionPolicyReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	// StartupAudit, if set, delays reconciles until existing child objects are corrected.
+	StartupAudit *StartupAudit
+	// MaxConcurrentReconciles is the number of GlobalRetentionPolicies reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+	// Images resolves the default restic image, e.g. to a private mirror.
+	Images *ImageConfig
+	// JobDefaults sets the default active deadline and backoff limit of the jobs.
+	JobDefaults *JobDefaults
+	// APIReader reads repository Leases directly from the API server to avoid caching all
+	// Leases. Falls back to Client if not set.
+	APIReader client.Reader
+	// FeatureGates enables optional capabilities. If nil, the feature defaults apply.
+	FeatureGates *features.Gate
+	// SnapshotCache, if set, serves the snapshots to find selectors matching no snapshots.
+	SnapshotCache *SnapshotCache
+	// Executor, if set, lists the snapshots to find selectors matching no snapshots when
+	// no SnapshotCache is set, on every reconcile.
+	Executor restic.Executor
+	// PodLogs reads the end of the logs of failed retention jobs for the status and events.
+	// If nil, failures are reported without logs.
+	PodLogs PodLogReader
 }
 
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=globalretentionpolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=globalretentionpolicies/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=globalretentionpolicies/finalizers,verbs=update
 // +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop.
@@ -61,6 +98,11 @@ func (r *GlobalRetentionPolicyReconciler) Reconcile(ctx context.Context, req ctr
 	log := log.FromContext(ctx)
 	log.Info("Reconciling GlobalRetentionPolicy")
 
+	// Wait for the startup audit to correct existing child objects
+	if err := r.StartupAudit.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Fetch the GlobalRetentionPolicy instance
 	policy := &backupv1alpha1.GlobalRetentionPolicy{}
 	if err := r.Get(ctx, req.NamespacedName, policy); err != nil {
@@ -85,12 +127,38 @@ func (r *GlobalRetentionPolicyReconciler) Reconcile(ctx context.Context, req ctr
 		}
 	}
 
+	// Reject policies restic would refuse to apply
+	for i := range policy.Spec.Policies {
+		if !policy.Spec.Policies[i].Retention.HasKeepRule() {
+			message := fmt.Sprintf("policy %d: at least one keep rule must be set", i+1)
+			log.Info("Invalid retention policy", "policy", i+1)
+			r.setCondition(policy, conditions.NotReadyCondition("InvalidRetentionPolicy", message))
+			r.Recorder.Event(policy, corev1.EventTypeWarning, "InvalidRetentionPolicy", message)
+			if updateErr := r.Status().Update(ctx, policy); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// Reject sidecars the job pods can't be created with
+	if err := validateSidecars(policy.Spec.JobConfig); err != nil {
+		r.setCondition(policy, conditions.NotReadyCondition("InvalidSidecars", err.Error()))
+		r.Recorder.Event(policy, corev1.EventTypeWarning, "InvalidSidecars", err.Error())
+		if updateErr := r.Status().Update(ctx, policy); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// Get the repository
 	repository, err := r.getRepository(ctx, policy)
+	setReferenceDenied(&policy.Status.Conditions, err)
 	if err != nil {
 		log.Error(err, "Failed to get repository")
-		r.setCondition(policy, conditions.NotReadyCondition("RepositoryNotFound", err.Error()))
-		r.Recorder.Event(policy, corev1.EventTypeWarning, "RepositoryNotFound", err.Error())
+		reason := referenceErrorReason(err, "RepositoryNotFound")
+		r.setCondition(policy, conditions.NotReadyCondition(reason, err.Error()))
+		r.Recorder.Event(policy, corev1.EventTypeWarning, reason, err.Error())
 		if updateErr := r.Status().Update(ctx, policy); updateErr != nil {
 			return ctrl.Result{}, updateErr
 		}
@@ -107,6 +175,16 @@ func (r *GlobalRetentionPolicyReconciler) Reconcile(ctx context.Context, req ctr
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
 	}
 
+	// Detect retention runs overlapping with backups, moving the schedule if requested
+	if err := r.updateScheduleOverlaps(ctx, policy, repository); err != nil {
+		log.Error(err, "Failed to check for overlapping backups")
+	}
+
+	// Detect selectors that no longer match any snapshot, e.g. after a tag was renamed
+	if err := r.updateSelectorCoverage(ctx, policy, repository); err != nil {
+		log.Error(err, "Failed to check the selectors against the snapshots")
+	}
+
 	// Reconcile CronJob
 	if err := r.reconcileCronJob(ctx, policy, repository); err != nil {
 		log.Error(err, "Failed to reconcile CronJob")
@@ -118,6 +196,21 @@ func (r *GlobalRetentionPolicyReconciler) Reconcile(ctx context.Context, req ctr
 		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
 	}
 
+	// Start retention jobs once no other job uses the repository
+	waiting := ""
+	if repository.Spec.CoordinateJobs {
+		waiting, err = r.startRetentionJobs(ctx, policy, repository)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	setWaitingForRepository(&policy.Status.Conditions, waiting)
+
+	// Record the results of finished retention jobs
+	if err := r.recordRetentionRuns(ctx, policy, repository); err != nil {
+		log.Error(err, "Failed to record retention runs")
+	}
+
 	// Calculate next run time
 	nextRun := r.calculateNextRun(policy)
 	if nextRun != nil {
@@ -135,6 +228,9 @@ func (r *GlobalRetentionPolicyReconciler) Reconcile(ctx context.Context, req ctr
 
 	r.Recorder.Event(policy, corev1.EventTypeNormal, "ReconcileSuccess", "Retention policy reconciled successfully")
 
+	if waiting != "" {
+		return ctrl.Result{RequeueAfter: waitingForRepositoryInterval}, nil
+	}
 	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
 }
 
@@ -144,6 +240,12 @@ func (r *GlobalRetentionPolicyReconciler) handleDeletion(ctx context.Context, po
 	if controllerutil.ContainsFinalizer(policy, globalRetentionPolicyFinalizer) {
 		log.Info("Performing finalizer cleanup for GlobalRetentionPolicy")
 
+		if repository, err := r.getRepository(ctx, policy); err == nil && repository.Spec.CoordinateJobs {
+			if err := releaseRepositoryLease(ctx, r.Client, r.apiReader(), repository, r.leaseHolder(policy)); err != nil {
+				log.Error(err, "Failed to release repository lease")
+			}
+		}
+
 		controllerutil.RemoveFinalizer(policy, globalRetentionPolicyFinalizer)
 		if err := r.Update(ctx, policy); err != nil {
 			return ctrl.Result{}, err
@@ -165,6 +267,9 @@ func (r *GlobalRetentionPolicyReconciler) getRepository(ctx context.Context, pol
 		Namespace: ns,
 	}
 
+	if err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "GlobalRetentionPolicy", policy.Namespace, "ResticRepository", ns, name.Name); err != nil {
+		return nil, err
+	}
 	if err := r.Get(ctx, name, repository); err != nil {
 		return nil, fmt.Errorf("failed to get repository: %w", err)
 	}
@@ -172,10 +277,93 @@ func (r *GlobalRetentionPolicyReconciler) getRepository(ctx context.Context, pol
 	return repository, nil
 }
 
+// startRetentionJobs starts the retention jobs created suspended by the CronJob once the
+// repository Lease is acquired and no backup job runs. The Lease is held while a retention
+// job runs and released afterwards. It returns a message describing what the jobs wait
+// for, or an empty string if none waits.
+func (r *GlobalRetentionPolicyReconciler) startRetentionJobs(ctx context.Context, policy *backupv1alpha1.GlobalRetentionPolicy,
+	repository *backupv1alpha1.ResticRepository) (string, error) {
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs, client.InNamespace(policy.Namespace), client.MatchingLabels{retentionPolicyLabel: policy.Name}); err != nil {
+		return "", fmt.Errorf("failed to list retention jobs: %w", err)
+	}
+
+	var pending []*batchv1.Job
+	active := false
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		switch {
+		case jobActive(job):
+			active = true
+		case job.Spec.Suspend != nil && *job.Spec.Suspend && job.Status.StartTime == nil:
+			pending = append(pending, job)
+		}
+	}
+
+	holder := r.leaseHolder(policy)
+	if !active && len(pending) == 0 {
+		return "", releaseRepositoryLease(ctx, r.Client, r.apiReader(), repository, holder)
+	}
+
+	duration := time.Duration(r.JobDefaults.ActiveDeadlineSeconds(JobOperationRetention, policy.Spec.JobConfig)) * time.Second
+	message, err := acquireRepositoryForExclusiveJob(ctx, r.Client, r.apiReader(), r.Scheme, repository, holder, duration)
+	if err != nil || message != "" || active {
+		return message, err
+	}
+
+	for _, job := range pending {
+		patch := client.MergeFrom(job.DeepCopy())
+		job.Spec.Suspend = boolPtr(false)
+		if err := r.Patch(ctx, job, patch); err != nil {
+			return "", fmt.Errorf("failed to start retention job %s: %w", job.Name, err)
+		}
+		r.Recorder.Event(policy, corev1.EventTypeNormal, "RetentionStarted", fmt.Sprintf("Retention job %s started", job.Name))
+	}
+	return "", nil
+}
+
+// leaseHolder returns the identity of the policy in the repository Lease.
+func (r *GlobalRetentionPolicyReconciler) leaseHolder(policy *backupv1alpha1.GlobalRetentionPolicy) string {
+	return "GlobalRetentionPolicy/" + policy.Namespace + "/" + policy.Name
+}
+
+func (r *GlobalRetentionPolicyReconciler) apiReader() client.Reader {
+	if r.APIReader == nil {
+		return r.Client
+	}
+	return r.APIReader
+}
+
 func (r *GlobalRetentionPolicyReconciler) reconcileCronJob(ctx context.Context, policy *backupv1alpha1.GlobalRetentionPolicy, repository *backupv1alpha1.ResticRepository) error {
+	schedules := retentionSchedules(policy)
+	desired := make(map[string]bool, len(schedules))
+	var entryRefs []backupv1alpha1.ObjectReference
+
+	for i, schedule := range schedules {
+		if err := r.reconcileScheduleCronJob(ctx, policy, repository, schedule); err != nil {
+			return err
+		}
+		desired[schedule.name] = true
+
+		ref := backupv1alpha1.ObjectReference{Name: schedule.name, Namespace: policy.Namespace}
+		if i == 0 {
+			// Update status with CronJob reference
+			policy.Status.CronJobRef = &ref
+		} else {
+			entryRefs = append(entryRefs, ref)
+		}
+	}
+	policy.Status.EntryCronJobRefs = entryRefs
+
+	return r.deleteStaleCronJobs(ctx, policy, desired)
+}
+
+// reconcileScheduleCronJob creates or updates the CronJob running the entries of a schedule.
+func (r *GlobalRetentionPolicyReconciler) reconcileScheduleCronJob(ctx context.Context, policy *backupv1alpha1.GlobalRetentionPolicy,
+	repository *backupv1alpha1.ResticRepository, schedule retentionSchedule) error {
 	log := log.FromContext(ctx)
 
-	cronJob := r.buildCronJob(policy, repository)
+	cronJob := r.buildCronJob(policy, repository, schedule)
 
 	// Set owner reference
 	if err := controllerutil.SetControllerReference(policy, cronJob, r.Scheme); err != nil {
@@ -188,14 +376,15 @@ func (r *GlobalRetentionPolicyReconciler) reconcileCronJob(ctx context.Context,
 
 	if apierrors.IsNotFound(err) {
 		log.Info("Creating CronJob", "name", cronJob.Name)
-		if err := r.Create(ctx, cronJob); err != nil {
+		created, err := createOrAdopt(ctx, r.Client, policy, cronJob, existingCronJob)
+		if err != nil {
 			return fmt.Errorf("failed to create CronJob: %w", err)
 		}
-		r.Recorder.Event(policy, corev1.EventTypeNormal, "CronJobCreated", fmt.Sprintf("Created CronJob %s", cronJob.Name))
-		return nil
-	}
-
-	if err != nil {
+		if created {
+			r.Recorder.Event(policy, corev1.EventTypeNormal, "CronJobCreated", fmt.Sprintf("Created CronJob %s", cronJob.Name))
+			return nil
+		}
+	} else if err != nil {
 		return fmt.Errorf("failed to get CronJob: %w", err)
 	}
 
@@ -205,67 +394,44 @@ func (r *GlobalRetentionPolicyReconciler) reconcileCronJob(ctx context.Context,
 		return fmt.Errorf("failed to update CronJob: %w", err)
 	}
 
-	// Update status with CronJob reference
-	policy.Status.CronJobRef = &backupv1alpha1.ObjectReference{
-		Name:      cronJob.Name,
-		Namespace: cronJob.Namespace,
+	return nil
+}
+
+// deleteStaleCronJobs deletes the CronJobs of the policy whose schedule is no longer used.
+func (r *GlobalRetentionPolicyReconciler) deleteStaleCronJobs(ctx context.Context, policy *backupv1alpha1.GlobalRetentionPolicy, desired map[string]bool) error {
+	cronJobs := &batchv1.CronJobList{}
+	if err := r.List(ctx, cronJobs, client.InNamespace(policy.Namespace), client.MatchingLabels{retentionPolicyLabel: policy.Name}); err != nil {
+		return fmt.Errorf("failed to list CronJobs: %w", err)
 	}
 
+	for i := range cronJobs.Items {
+		cronJob := &cronJobs.Items[i]
+		if desired[cronJob.Name] || !metav1.IsControlledBy(cronJob, policy) {
+			continue
+		}
+		if err := r.Delete(ctx, cronJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete CronJob %s: %w", cronJob.Name, err)
+		}
+		r.Recorder.Event(policy, corev1.EventTypeNormal, "CronJobDeleted", fmt.Sprintf("Deleted CronJob %s of a removed schedule", cronJob.Name))
+	}
 	return nil
 }
 
-func (r *GlobalRetentionPolicyReconciler) buildCronJob(policy *backupv1alpha1.GlobalRetentionPolicy, repository *backupv1alpha1.ResticRepository) *batchv1.CronJob {
-	cronJobName := fmt.Sprintf("globalretention-%s", policy.Name)
-
-	// Build the retention script
-	script := r.buildRetentionScript(policy)
+func (r *GlobalRetentionPolicyReconciler) buildCronJob(policy *backupv1alpha1.GlobalRetentionPolicy, repository *backupv1alpha1.ResticRepository,
+	schedule retentionSchedule) *batchv1.CronJob {
+	// Build the retention script, the heartbeat is only written if all commands succeeded
+	options := repositoryOptions(repository)
+	script := r.buildScheduleScript(policy, schedule, options)
+	if heartbeat := buildHeartbeatCommands(repository, "retention", policy.Namespace+"/"+policy.Name, options); heartbeat != nil {
+		script += "\n" + strings.Join(heartbeat, "\n")
+	}
 
 	// Build environment variables
-	envVars := []corev1.EnvVar{
-		{
-			Name:  "RESTIC_REPOSITORY",
-			Value: repository.Spec.RepositoryURL,
-		},
-		{
-			Name: "RESTIC_PASSWORD",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: repository.Spec.CredentialsSecretRef.Name,
-					},
-					Key: "RESTIC_PASSWORD",
-				},
-			},
-		},
-		{
-			Name: "AWS_ACCESS_KEY_ID",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: repository.Spec.CredentialsSecretRef.Name,
-					},
-					Key:      "AWS_ACCESS_KEY_ID",
-					Optional: boolPtr(true),
-				},
-			},
-		},
-		{
-			Name: "AWS_SECRET_ACCESS_KEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: repository.Spec.CredentialsSecretRef.Name,
-					},
-					Key:      "AWS_SECRET_ACCESS_KEY",
-					Optional: boolPtr(true),
-				},
-			},
-		},
-	}
+	envVars := repositoryEnvVars(repository)
 
 	var successLimit, failLimit int32 = 3, 3
-	var backoffLimit int32 = 0
-	var activeDeadline int64 = 7200 // 2 hours for retention
+	backoffLimit := r.JobDefaults.BackoffLimit(JobOperationRetention, policy.Spec.JobConfig)
+	activeDeadline := r.JobDefaults.ActiveDeadlineSeconds(JobOperationRetention, policy.Spec.JobConfig)
 
 	if policy.Spec.JobConfig != nil {
 		if policy.Spec.JobConfig.SuccessfulJobsHistoryLimit != nil {
@@ -274,27 +440,21 @@ func (r *GlobalRetentionPolicyReconciler) buildCronJob(policy *backupv1alpha1.Gl
 		if policy.Spec.JobConfig.FailedJobsHistoryLimit != nil {
 			failLimit = *policy.Spec.JobConfig.FailedJobsHistoryLimit
 		}
-		if policy.Spec.JobConfig.BackoffLimit != nil {
-			backoffLimit = *policy.Spec.JobConfig.BackoffLimit
-		}
-		if policy.Spec.JobConfig.ActiveDeadlineSeconds != nil {
-			activeDeadline = *policy.Spec.JobConfig.ActiveDeadlineSeconds
-		}
 	}
 
 	cronJob := &batchv1.CronJob{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      cronJobName,
+			Name:      schedule.name,
 			Namespace: policy.Namespace,
 			Labels: map[string]string{
-				"app.kubernetes.io/name":                 "restic-backup-operator",
-				"app.kubernetes.io/component":            "retention",
-				"app.kubernetes.io/managed-by":           "restic-backup-operator",
-				"backup.resticbackup.io/retentionpolicy": policy.Name,
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "retention",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				retentionPolicyLabel:           policy.Name,
 			},
 		},
 		Spec: batchv1.CronJobSpec{
-			Schedule:                   policy.Spec.Schedule,
+			Schedule:                   schedule.schedule,
 			Suspend:                    &policy.Spec.Suspend,
 			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
 			SuccessfulJobsHistoryLimit: &successLimit,
@@ -302,9 +462,9 @@ func (r *GlobalRetentionPolicyReconciler) buildCronJob(policy *backupv1alpha1.Gl
 			JobTemplate: batchv1.JobTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: map[string]string{
-						"app.kubernetes.io/name":                 "restic-backup-operator",
-						"app.kubernetes.io/component":            "retention",
-						"backup.resticbackup.io/retentionpolicy": policy.Name,
+						"app.kubernetes.io/name":      "restic-backup-operator",
+						"app.kubernetes.io/component": "retention",
+						retentionPolicyLabel:          policy.Name,
 					},
 				},
 				Spec: batchv1.JobSpec{
@@ -313,9 +473,9 @@ func (r *GlobalRetentionPolicyReconciler) buildCronJob(policy *backupv1alpha1.Gl
 					Template: corev1.PodTemplateSpec{
 						ObjectMeta: metav1.ObjectMeta{
 							Labels: map[string]string{
-								"app.kubernetes.io/name":                 "restic-backup-operator",
-								"app.kubernetes.io/component":            "retention",
-								"backup.resticbackup.io/retentionpolicy": policy.Name,
+								"app.kubernetes.io/name":      "restic-backup-operator",
+								"app.kubernetes.io/component": "retention",
+								retentionPolicyLabel:          policy.Name,
 							},
 						},
 						Spec: corev1.PodSpec{
@@ -331,7 +491,7 @@ func (r *GlobalRetentionPolicyReconciler) buildCronJob(policy *backupv1alpha1.Gl
 							Containers: []corev1.Container{
 								{
 									Name:            "restic",
-									Image:           "ghcr.io/restic/restic:0.18.0",
+									Image:           r.Images.Resolve("", policy.Spec.JobConfig),
 									ImagePullPolicy: corev1.PullIfNotPresent,
 									Command:         []string{"/bin/sh", "-c"},
 									Args:            []string{script},
@@ -353,13 +513,33 @@ func (r *GlobalRetentionPolicyReconciler) buildCronJob(policy *backupv1alpha1.Gl
 		},
 	}
 
+	// Apply scheduling and networking settings
+	applyRepositoryCredentials(&cronJob.Spec.JobTemplate.Spec.Template.Spec, repository)
+	applyJobConfiguration(&cronJob.Spec.JobTemplate.Spec.Template.Spec, policy.Spec.JobConfig)
+	applyPodMetadata(&cronJob.Spec.JobTemplate.Spec.Template.ObjectMeta, policy.Spec.JobConfig)
+
+	// Jobs wait for the operator to acquire the repository
+	if repository.Spec.CoordinateJobs {
+		cronJob.Spec.JobTemplate.Spec.Suspend = boolPtr(true)
+	}
+
 	return cronJob
 }
 
-func (r *GlobalRetentionPolicyReconciler) buildRetentionScript(policy *backupv1alpha1.GlobalRetentionPolicy) string {
-	// Pre-allocate: 2 header + 2 per policy + 2 optional prune + 1 footer
-	capacity := 3 + 2*len(policy.Spec.Policies)
-	if policy.Spec.Prune {
+// buildRetentionScript builds the shell script applying the retention policies run on the
+// schedule of the policy. Options are the extended options of the repository backend.
+func (r *GlobalRetentionPolicyReconciler) buildRetentionScript(policy *backupv1alpha1.GlobalRetentionPolicy, options []string) string {
+	return r.buildScheduleScript(policy, retentionSchedules(policy)[0], options)
+}
+
+// buildScheduleScript builds the shell script applying the retention policies of a schedule.
+func (r *GlobalRetentionPolicyReconciler) buildScheduleScript(policy *backupv1alpha1.GlobalRetentionPolicy, schedule retentionSchedule, options []string) string {
+	// Pre-allocate: 2 header + 1 optional wait + 2 per policy + 1 per protected snapshot + 2 optional prune + 1 footer
+	capacity := 4 + 2*len(schedule.entries)
+	for _, i := range schedule.entries {
+		capacity += len(policy.Spec.Policies[i].Selector.ExcludeSnapshotIDs)
+	}
+	if schedule.prune {
 		capacity += 2
 	}
 	commands := make([]string, 0, capacity)
@@ -367,8 +547,22 @@ func (r *GlobalRetentionPolicyReconciler) buildRetentionScript(policy *backupv1a
 	commands = append(commands, "set -e")
 	commands = append(commands, "echo 'Starting retention policy execution'")
 
-	for i, p := range policy.Spec.Policies {
+	// The CronJobs of the schedules of a policy may run at the same time, so every
+	// schedule waits for the others to release the repository. With the Wait overlap
+	// policy, it also waits for running backups.
+	lockWait := retentionScheduleLockWait
+	if schedule.waitForBackups {
+		commands = append(commands, fmt.Sprintf("echo 'Waiting up to %s for backups to release the repository'", retentionLockWait))
+		lockWait = retentionLockWait
+	}
+	options = append(slices.Clone(options), "--retry-lock", lockWait)
+
+	for _, i := range schedule.entries {
+		p := policy.Spec.Policies[i]
 		cmd := "restic forget"
+		if len(options) > 0 {
+			cmd += " " + shellQuoteArgs(options)
+		}
 
 		// Add tag filter
 		for _, tag := range p.Selector.Tags {
@@ -380,6 +574,14 @@ func (r *GlobalRetentionPolicyReconciler) buildRetentionScript(policy *backupv1a
 			cmd += fmt.Sprintf(" --host %s", p.Selector.Hostname)
 		}
 
+		// Add path filter
+		for _, path := range p.Selector.Paths {
+			cmd += " " + shellQuoteArgs([]string{"--path", path})
+		}
+
+		// Keep the protected snapshots, including those protected by other entries
+		cmd += " " + shellQuoteArgs([]string{"--keep-tag", retentionProtectedTag})
+
 		// Add retention rules
 		if p.Retention.KeepLast != nil && *p.Retention.KeepLast > 0 {
 			cmd += fmt.Sprintf(" --keep-last %d", *p.Retention.KeepLast)
@@ -399,15 +601,33 @@ func (r *GlobalRetentionPolicyReconciler) buildRetentionScript(policy *backupv1a
 		if p.Retention.KeepYearly != nil && *p.Retention.KeepYearly > 0 {
 			cmd += fmt.Sprintf(" --keep-yearly %d", *p.Retention.KeepYearly)
 		}
+		durations := []struct{ flag, value string }{
+			{"--keep-within", p.Retention.KeepWithin},
+			{"--keep-within-hourly", p.Retention.KeepWithinHourly},
+			{"--keep-within-daily", p.Retention.KeepWithinDaily},
+			{"--keep-within-weekly", p.Retention.KeepWithinWeekly},
+			{"--keep-within-monthly", p.Retention.KeepWithinMonthly},
+			{"--keep-within-yearly", p.Retention.KeepWithinYearly},
+		}
+		for _, d := range durations {
+			if d.value != "" {
+				cmd += " " + shellQuoteArgs([]string{d.flag, d.value})
+			}
+		}
 
 		commands = append(commands, fmt.Sprintf("echo 'Executing policy %d'", i+1))
+		commands = append(commands, protectSnapshotsScript(p.Selector.ExcludeSnapshotIDs, options)...)
 		commands = append(commands, cmd)
 	}
 
 	// Add prune if enabled
-	if policy.Spec.Prune {
+	if schedule.prune {
 		commands = append(commands, "echo 'Running prune'")
-		commands = append(commands, "restic prune")
+		cmd := "restic prune"
+		if len(options) > 0 {
+			cmd += " " + shellQuoteArgs(options)
+		}
+		commands = append(commands, cmd)
 	}
 
 	commands = append(commands, "echo 'Retention policy execution completed'")
@@ -415,15 +635,41 @@ func (r *GlobalRetentionPolicyReconciler) buildRetentionScript(policy *backupv1a
 	return strings.Join(commands, "\n")
 }
 
+// protectSnapshotsScript returns the commands tagging the snapshots with
+// retentionProtectedTag so that forget --keep-tag keeps them. Tagging rewrites a
+// snapshot under a new ID, so a snapshot counts as tagged if its ID or the ID of the
+// snapshot it was rewritten from matches.
+func protectSnapshotsScript(ids, options []string) []string {
+	args := ""
+	if len(options) > 0 {
+		args = " " + shellQuoteArgs(options)
+	}
+
+	commands := make([]string, 0, len(ids))
+	for _, id := range ids {
+		commands = append(commands, fmt.Sprintf(
+			"restic snapshots%s --json --tag %s | grep -qE '\"(id|original)\":\"%s' || restic tag%s --add %s",
+			args, shellQuoteArgs([]string{retentionProtectedTag}), id, args, shellQuoteArgs([]string{retentionProtectedTag, id})))
+	}
+	return commands
+}
+
+// calculateNextRun returns the next run of any of the schedules of the policy.
 func (r *GlobalRetentionPolicyReconciler) calculateNextRun(policy *backupv1alpha1.GlobalRetentionPolicy) *metav1.Time {
 	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-	schedule, err := parser.Parse(policy.Spec.Schedule)
-	if err != nil {
-		return nil
-	}
+	now := time.Now()
 
-	next := schedule.Next(time.Now())
-	return &metav1.Time{Time: next}
+	var next *metav1.Time
+	for _, s := range retentionSchedules(policy) {
+		schedule, err := parser.Parse(s.schedule)
+		if err != nil {
+			continue
+		}
+		if t := schedule.Next(now); next == nil || t.Before(next.Time) {
+			next = &metav1.Time{Time: t}
+		}
+	}
+	return next
 }
 
 func (r *GlobalRetentionPolicyReconciler) setCondition(policy *backupv1alpha1.GlobalRetentionPolicy, condition metav1.Condition) {
@@ -434,6 +680,17 @@ func (r *GlobalRetentionPolicyReconciler) setCondition(policy *backupv1alpha1.Gl
 func (r *GlobalRetentionPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&backupv1alpha1.GlobalRetentionPolicy{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Owns(&batchv1.CronJob{}).
+		Watches(&batchv1.Job{}, handler.EnqueueRequestsFromMapFunc(retentionPolicyForJob)).
 		Complete(r)
 }
+
+// retentionPolicyForJob maps a retention job to its GlobalRetentionPolicy.
+func retentionPolicyForJob(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[retentionPolicyLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}}}
+}
@@ -37,7 +37,7 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 		interval = time.Millisecond * 250
 	)
 
-	Context("When creating a GlobalRetentionPolicy", func() {
+	Context("When creating a GlobalRetentionPolicy", Label(envtestLabel), func() {
 		var (
 			testNamespace string
 			policyKey     types.NamespacedName
@@ -342,7 +342,7 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 
 			// Verify CronJob has correct labels
 			Expect(cronJob.Labels["app.kubernetes.io/name"]).To(Equal("restic-backup-operator"))
-			Expect(cronJob.Labels["backup.resticbackup.io/retentionpolicy"]).To(Equal(policyKey.Name))
+			Expect(cronJob.Labels[retentionPolicyLabel]).To(Equal(policyKey.Name))
 		})
 
 		It("should calculate next run time", func() {
@@ -499,7 +499,7 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 				},
 			}
 
-			script := reconciler.buildRetentionScript(policy)
+			script := reconciler.buildRetentionScript(policy, nil)
 			Expect(script).To(ContainSubstring("set -e"))
 			Expect(script).To(ContainSubstring("restic forget"))
 			Expect(script).To(ContainSubstring("--tag daily"))
@@ -522,7 +522,7 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 				},
 			}
 
-			script := reconciler.buildRetentionScript(policy)
+			script := reconciler.buildRetentionScript(policy, nil)
 			Expect(script).To(ContainSubstring("restic prune"))
 		})
 
@@ -542,7 +542,7 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 				},
 			}
 
-			script := reconciler.buildRetentionScript(policy)
+			script := reconciler.buildRetentionScript(policy, nil)
 			Expect(script).NotTo(ContainSubstring("restic prune"))
 		})
 
@@ -563,7 +563,7 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 				},
 			}
 
-			script := reconciler.buildRetentionScript(policy)
+			script := reconciler.buildRetentionScript(policy, nil)
 			Expect(script).To(ContainSubstring("--host my-host"))
 		})
 
@@ -593,7 +593,7 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 				},
 			}
 
-			script := reconciler.buildRetentionScript(policy)
+			script := reconciler.buildRetentionScript(policy, nil)
 			Expect(script).To(ContainSubstring("--keep-last 5"))
 			Expect(script).To(ContainSubstring("--keep-hourly 24"))
 			Expect(script).To(ContainSubstring("--keep-daily 7"))
@@ -602,6 +602,33 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 			Expect(script).To(ContainSubstring("--keep-yearly 3"))
 		})
 
+		It("should include the keep-within retention options", func() {
+			policy := &backupv1alpha1.GlobalRetentionPolicy{
+				Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+					Policies: []backupv1alpha1.RetentionPolicyEntry{
+						{
+							Retention: backupv1alpha1.RetentionPolicy{
+								KeepWithin:        "7d",
+								KeepWithinHourly:  "2d",
+								KeepWithinDaily:   "1m",
+								KeepWithinWeekly:  "3m",
+								KeepWithinMonthly: "1y",
+								KeepWithinYearly:  "10y",
+							},
+						},
+					},
+				},
+			}
+
+			script := reconciler.buildRetentionScript(policy, nil)
+			Expect(script).To(ContainSubstring("'--keep-within' '7d'"))
+			Expect(script).To(ContainSubstring("'--keep-within-hourly' '2d'"))
+			Expect(script).To(ContainSubstring("'--keep-within-daily' '1m'"))
+			Expect(script).To(ContainSubstring("'--keep-within-weekly' '3m'"))
+			Expect(script).To(ContainSubstring("'--keep-within-monthly' '1y'"))
+			Expect(script).To(ContainSubstring("'--keep-within-yearly' '10y'"))
+		})
+
 		It("should handle multiple policies", func() {
 			keepLast := int32(10)
 			keepDaily := int32(7)
@@ -628,7 +655,7 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 				},
 			}
 
-			script := reconciler.buildRetentionScript(policy)
+			script := reconciler.buildRetentionScript(policy, nil)
 			Expect(strings.Count(script, "restic forget")).To(Equal(2))
 			Expect(script).To(ContainSubstring("--tag app1"))
 			Expect(script).To(ContainSubstring("--tag app2"))
@@ -653,11 +680,77 @@ var _ = Describe("GlobalRetentionPolicy Controller", func() {
 				},
 			}
 
-			script := reconciler.buildRetentionScript(policy)
+			script := reconciler.buildRetentionScript(policy, nil)
 			Expect(script).To(ContainSubstring("--tag tag1"))
 			Expect(script).To(ContainSubstring("--tag tag2"))
 			Expect(script).To(ContainSubstring("--tag tag3"))
 		})
+
+		It("should include path filters", func() {
+			keepLast := int32(10)
+			policy := &backupv1alpha1.GlobalRetentionPolicy{
+				Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+					Policies: []backupv1alpha1.RetentionPolicyEntry{
+						{
+							Selector: backupv1alpha1.RetentionSelector{
+								Paths: []string{"/backup/data", "/backup/config"},
+							},
+							Retention: backupv1alpha1.RetentionPolicy{
+								KeepLast: &keepLast,
+							},
+						},
+					},
+				},
+			}
+
+			script := reconciler.buildRetentionScript(policy, nil)
+			Expect(script).To(ContainSubstring("'--path' '/backup/data' '--path' '/backup/config'"))
+		})
+
+		It("should protect excluded snapshots from forget", func() {
+			keepLast := int32(10)
+			policy := &backupv1alpha1.GlobalRetentionPolicy{
+				Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+					Policies: []backupv1alpha1.RetentionPolicyEntry{
+						{
+							Selector: backupv1alpha1.RetentionSelector{
+								ExcludeSnapshotIDs: []string{"1a2b3c4d"},
+							},
+							Retention: backupv1alpha1.RetentionPolicy{
+								KeepLast: &keepLast,
+							},
+						},
+					},
+				},
+			}
+
+			script := reconciler.buildRetentionScript(policy, []string{"-o", "s3.region=eu"})
+			lines := strings.Split(script, "\n")
+			Expect(lines).To(ContainElement("restic snapshots '-o' 's3.region=eu' '--retry-lock' '30m' --json --tag 'retention-protected' | " +
+				"grep -qE '\"(id|original)\":\"1a2b3c4d' || " +
+				"restic tag '-o' 's3.region=eu' '--retry-lock' '30m' --add 'retention-protected' '1a2b3c4d'"))
+			Expect(script).To(ContainSubstring("'--keep-tag' 'retention-protected'"))
+			Expect(strings.Index(script, "restic tag")).To(BeNumerically("<", strings.Index(script, "restic forget")))
+		})
+
+		It("should keep tagged snapshots in entries without exclusions", func() {
+			keepLast := int32(10)
+			policy := &backupv1alpha1.GlobalRetentionPolicy{
+				Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+					Policies: []backupv1alpha1.RetentionPolicyEntry{
+						{
+							Retention: backupv1alpha1.RetentionPolicy{
+								KeepLast: &keepLast,
+							},
+						},
+					},
+				},
+			}
+
+			script := reconciler.buildRetentionScript(policy, nil)
+			Expect(script).To(ContainSubstring("'--keep-tag' 'retention-protected' --keep-last 10"))
+			Expect(script).NotTo(ContainSubstring("restic tag"))
+		})
 	})
 
 	Context("calculateNextRun helper function", func() {
@@ -0,0 +1,94 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// DefaultResticImage is the restic image used by generated pods if a resource does not
+// set an image.
+const DefaultResticImage = "ghcr.io/restic/restic:0.18.0"
+
+// ImageConfig configures how the default restic image is resolved, e.g. for air-gapped
+// clusters pulling from a private mirror. Images set explicitly on a resource are used
+// unchanged. A nil ImageConfig resolves to DefaultResticImage.
+type ImageConfig struct {
+	// Mirror replaces the registry of the default image, e.g. "registry.example.com/ghcr"
+	// yields "registry.example.com/ghcr/restic/restic:0.18.0".
+	Mirror string
+	// Digests pins the default image by CPU architecture (e.g. "amd64") for pods whose
+	// jobConfig.nodeSelector selects that architecture.
+	Digests map[string]string
+}
+
+// Resolve returns the restic image for a pod. An empty image or DefaultResticImage,
+// which older CRD versions set as default, selects the default image.
+func (c *ImageConfig) Resolve(image string, jobConfig *backupv1alpha1.JobConfiguration) string {
+	if image != "" && image != DefaultResticImage {
+		return image
+	}
+	if c == nil {
+		return DefaultResticImage
+	}
+
+	resolved := DefaultResticImage
+	if c.Mirror != "" {
+		resolved = mirrorImage(resolved, c.Mirror)
+	}
+	if jobConfig != nil {
+		if digest := c.Digests[jobConfig.NodeSelector[corev1.LabelArchStable]]; digest != "" {
+			// Keep the tag for readability, the digest takes precedence
+			resolved = resolved + "@" + digest
+		}
+	}
+	return resolved
+}
+
+// mirrorImage replaces the registry of an image reference with the mirror.
+func mirrorImage(image, mirror string) string {
+	mirror = strings.TrimSuffix(mirror, "/")
+	registry, path, found := strings.Cut(image, "/")
+	if !found || !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		// Docker Hub image without registry
+		return mirror + "/" + image
+	}
+	return mirror + "/" + path
+}
+
+// ParseImageDigests parses a comma-separated list of arch=digest pairs, e.g.
+// "amd64=sha256:abc...,arm64=sha256:def...".
+func ParseImageDigests(value string) (map[string]string, error) {
+	digests := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		arch, digest, found := strings.Cut(pair, "=")
+		if !found || arch == "" || !strings.HasPrefix(digest, "sha256:") {
+			return nil, fmt.Errorf("invalid image digest %q, expected arch=sha256:<digest>", pair)
+		}
+		digests[arch] = digest
+	}
+	return digests, nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Image resolution", func() {
+	It("should use the default image without configuration", func() {
+		var images *ImageConfig
+		Expect(images.Resolve("", nil)).To(Equal(DefaultResticImage))
+	})
+
+	It("should keep explicitly configured images", func() {
+		images := &ImageConfig{Mirror: "registry.example.com/ghcr"}
+		Expect(images.Resolve("restic/restic:0.17.0", nil)).To(Equal("restic/restic:0.17.0"))
+	})
+
+	It("should rewrite the default image to the mirror", func() {
+		images := &ImageConfig{Mirror: "registry.example.com/ghcr/"}
+		Expect(images.Resolve("", nil)).To(Equal("registry.example.com/ghcr/restic/restic:0.18.0"))
+		Expect(images.Resolve(DefaultResticImage, nil)).To(Equal("registry.example.com/ghcr/restic/restic:0.18.0"))
+	})
+
+	It("should pin the digest for the selected architecture", func() {
+		images := &ImageConfig{Digests: map[string]string{"arm64": "sha256:abc"}}
+		arm := &backupv1alpha1.JobConfiguration{NodeSelector: map[string]string{corev1.LabelArchStable: "arm64"}}
+		amd := &backupv1alpha1.JobConfiguration{NodeSelector: map[string]string{corev1.LabelArchStable: "amd64"}}
+
+		Expect(images.Resolve("", arm)).To(Equal(DefaultResticImage + "@sha256:abc"))
+		Expect(images.Resolve("", amd)).To(Equal(DefaultResticImage))
+		Expect(images.Resolve("", nil)).To(Equal(DefaultResticImage))
+	})
+
+	It("should mirror Docker Hub images", func() {
+		Expect(mirrorImage("restic/restic:0.18.0", "mirror.local")).To(Equal("mirror.local/restic/restic:0.18.0"))
+		Expect(mirrorImage("localhost/restic:dev", "mirror.local")).To(Equal("mirror.local/restic:dev"))
+	})
+
+	It("should parse image digests", func() {
+		digests, err := ParseImageDigests("amd64=sha256:aaa, arm64=sha256:bbb,")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digests).To(Equal(map[string]string{"amd64": "sha256:aaa", "arm64": "sha256:bbb"}))
+
+		digests, err = ParseImageDigests("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digests).To(BeEmpty())
+
+		_, err = ParseImageDigests("amd64")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseImageDigests("amd64=latest")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,54 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errNotControlled reports an existing object with the name of an object to be created
+// that is not controlled by the creating resource.
+var errNotControlled = errors.New("already exists and is not controlled by")
+
+// createOrAdopt creates obj, whose controller reference must be set to owner. Child
+// names are deterministic, so an object of the same name may exist already, e.g. created
+// by an earlier reconcile whose status update failed with a conflict, or missing from the
+// cache yet. It is read into existing and adopted if owner controls it, anyone else's
+// object is never taken over. created reports whether obj was created by this call.
+func createOrAdopt(ctx context.Context, c client.Client, owner, obj, existing client.Object) (bool, error) {
+	err := c.Create(ctx, obj)
+	if err == nil {
+		return true, nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return false, fmt.Errorf("failed to get existing %s: %w", obj.GetName(), err)
+	}
+	if !metav1.IsControlledBy(existing, owner) {
+		return false, fmt.Errorf("%s %w %s", obj.GetName(), errNotControlled, owner.GetName())
+	}
+	return false, nil
+}
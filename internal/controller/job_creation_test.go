@@ -0,0 +1,244 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// newSnapshotExecutor lists a newer snapshot on every call, like a repository receiving
+// backups while a restore is reconciled.
+type newSnapshotExecutor struct {
+	MockExecutor
+	calls int
+}
+
+func (e *newSnapshotExecutor) Snapshots(_ context.Context, _ restic.Credentials) ([]restic.Snapshot, error) {
+	e.calls++
+	id := fmt.Sprintf("snapshot%d", e.calls)
+	return []restic.Snapshot{{ID: id, ShortID: id, Time: time.Now()}}, nil
+}
+
+// conflictingStatusUpdates fails the status updates with the given numbers (1-based)
+// with a conflict, as if another writer updated the resource in between.
+func conflictingStatusUpdates(failing ...int) interceptor.Funcs {
+	updates := 0
+	return interceptor.Funcs{
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			updates++
+			for _, n := range failing {
+				if updates == n {
+					return apierrors.NewConflict(schema.GroupResource{Group: backupv1alpha1.GroupVersion.Group}, obj.GetName(), errors.New("the object has been modified"))
+				}
+			}
+			return c.SubResource(subResource).Update(ctx, obj, opts...)
+		},
+	}
+}
+
+var _ = Describe("Job creation under reconcile races", func() {
+	var testScheme *runtime.Scheme
+
+	BeforeEach(func() {
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+	})
+
+	Context("createOrAdopt", func() {
+		var (
+			c     client.Client
+			owner *backupv1alpha1.ResticPrune
+		)
+
+		newJob := func() *batchv1.Job {
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "resticprune-weekly", Namespace: "default"}}
+			Expect(controllerutil.SetControllerReference(owner, job, testScheme)).To(Succeed())
+			return job
+		}
+
+		BeforeEach(func() {
+			owner = &backupv1alpha1.ResticPrune{ObjectMeta: metav1.ObjectMeta{Name: "weekly", Namespace: "default", UID: "prune-uid"}}
+			c = fake.NewClientBuilder().WithScheme(testScheme).Build()
+		})
+
+		It("should create missing objects", func() {
+			created, err := createOrAdopt(context.Background(), c, owner, newJob(), &batchv1.Job{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(BeTrue())
+		})
+
+		It("should adopt objects created by an earlier reconcile", func() {
+			Expect(c.Create(context.Background(), newJob())).To(Succeed())
+
+			existing := &batchv1.Job{}
+			created, err := createOrAdopt(context.Background(), c, owner, newJob(), existing)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(BeFalse())
+			Expect(existing.Name).To(Equal("resticprune-weekly"))
+		})
+
+		It("should not take over objects of anyone else", func() {
+			Expect(c.Create(context.Background(), &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "resticprune-weekly", Namespace: "default"},
+			})).To(Succeed())
+
+			_, err := createOrAdopt(context.Background(), c, owner, newJob(), &batchv1.Job{})
+			Expect(errors.Is(err, errNotControlled)).To(BeTrue())
+		})
+	})
+
+	Context("ResticRestore", func() {
+		var (
+			restore  *backupv1alpha1.ResticRestore
+			executor *newSnapshotExecutor
+			recorder *record.FakeRecorder
+			r        *ResticRestoreReconciler
+		)
+
+		BeforeEach(func() {
+			restore = &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: "restore-uid"},
+				Spec: backupv1alpha1.ResticRestoreSpec{
+					BackupRef:        backupv1alpha1.CrossNamespaceObjectReference{Name: "app"},
+					SnapshotSelector: &backupv1alpha1.SnapshotSelector{Latest: true},
+					Target: backupv1alpha1.RestoreTarget{
+						PVC: &backupv1alpha1.PVCTarget{ClaimName: "app-data"},
+					},
+				},
+				Status: backupv1alpha1.ResticRestoreStatus{Phase: backupv1alpha1.RestorePhasePending},
+			}
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"},
+				},
+			}
+			repository := &backupv1alpha1.ResticRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "default"},
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					RepositoryURL:        "local:/tmp/test-repo",
+					CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+				},
+			}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "repo-credentials", Namespace: "default"},
+				Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+			}
+			executor = &newSnapshotExecutor{}
+			recorder = record.NewFakeRecorder(10)
+			r = &ResticRestoreReconciler{
+				Client: fake.NewClientBuilder().WithScheme(testScheme).
+					WithObjects(restore, backup, repository, secret).
+					WithStatusSubresource(&backupv1alpha1.ResticRestore{}).
+					WithInterceptorFuncs(conflictingStatusUpdates(2)).
+					Build(),
+				Scheme:   testScheme,
+				Recorder: recorder,
+				Executor: executor,
+			}
+		})
+
+		It("should adopt the job of a reconcile whose status update conflicted", func() {
+			_, err := r.handlePending(context.Background(), restore.DeepCopy())
+			Expect(apierrors.IsConflict(err)).To(BeTrue())
+
+			// The retry reads the recorded job and snapshot
+			current := &backupv1alpha1.ResticRestore{}
+			Expect(r.Get(context.Background(), client.ObjectKeyFromObject(restore), current)).To(Succeed())
+			Expect(current.Status.Phase).To(Equal(backupv1alpha1.RestorePhasePending))
+			_, err = r.handlePending(context.Background(), current)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r.Get(context.Background(), client.ObjectKeyFromObject(restore), current)).To(Succeed())
+			Expect(current.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseInProgress))
+			Expect(current.Status.RestoredSnapshot).To(Equal("snapshot1"))
+			Expect(executor.calls).To(Equal(1))
+
+			jobs := &batchv1.JobList{}
+			Expect(r.List(context.Background(), jobs)).To(Succeed())
+			Expect(jobs.Items).To(HaveLen(1))
+			Expect(jobs.Items[0].Spec.Template.Spec.Containers[0].Command).To(ContainElement("snapshot1"))
+			Expect(recorder.Events).To(HaveLen(1))
+		})
+	})
+
+	Context("ResticPrune", func() {
+		It("should adopt the job of a reconcile whose status update conflicted", func() {
+			prune := &backupv1alpha1.ResticPrune{
+				ObjectMeta: metav1.ObjectMeta{Name: "weekly", Namespace: "default", UID: "prune-uid"},
+				Spec: backupv1alpha1.ResticPruneSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"},
+				},
+				Status: backupv1alpha1.ResticPruneStatus{Phase: backupv1alpha1.PrunePhasePending},
+			}
+			repository := &backupv1alpha1.ResticRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "default"},
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					RepositoryURL:        "local:/tmp/test-repo",
+					CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &ResticPruneReconciler{
+				Client: fake.NewClientBuilder().WithScheme(testScheme).
+					WithObjects(prune, repository).
+					WithStatusSubresource(&backupv1alpha1.ResticPrune{}).
+					WithInterceptorFuncs(conflictingStatusUpdates(2)).
+					Build(),
+				Scheme:   testScheme,
+				Recorder: recorder,
+			}
+
+			_, err := r.handlePending(context.Background(), prune.DeepCopy())
+			Expect(apierrors.IsConflict(err)).To(BeTrue())
+
+			current := &backupv1alpha1.ResticPrune{}
+			Expect(r.Get(context.Background(), client.ObjectKeyFromObject(prune), current)).To(Succeed())
+			_, err = r.handlePending(context.Background(), current)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r.Get(context.Background(), client.ObjectKeyFromObject(prune), current)).To(Succeed())
+			Expect(current.Status.Phase).To(Equal(backupv1alpha1.PrunePhaseInProgress))
+			jobs := &batchv1.JobList{}
+			Expect(r.List(context.Background(), jobs)).To(Succeed())
+			Expect(jobs.Items).To(HaveLen(1))
+			Expect(recorder.Events).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,138 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// Operation types of generated Jobs with their own defaults.
+const (
+	JobOperationBackup    = "backup"
+	JobOperationRestore   = "restore"
+	JobOperationRetention = "retention"
+	JobOperationCheck     = "check"
+	JobOperationPrune     = "prune"
+	// JobOperationVerification compares the latest snapshot of a backup with its source.
+	JobOperationVerification = "verification"
+	// JobOperationReplication copies snapshots to another repository.
+	JobOperationReplication = "replication"
+)
+
+// defaultActiveDeadlines are the built-in active deadlines per operation type.
+var defaultActiveDeadlines = map[string]time.Duration{
+	JobOperationBackup:    time.Hour,
+	JobOperationRestore:   time.Hour,
+	JobOperationRetention: 2 * time.Hour,
+	JobOperationCheck:     4 * time.Hour, // reading data is slow
+	JobOperationPrune:     2 * time.Hour,
+	// Content verifications reread the whole source
+	JobOperationVerification: 2 * time.Hour,
+	JobOperationReplication:  4 * time.Hour, // copying transfers the snapshot data
+}
+
+// JobDefaults configures the cluster-wide active deadline and backoff limit of generated
+// Jobs per operation type. The jobConfig of a resource takes precedence. A nil
+// JobDefaults uses the built-in defaults: no retries and deadlines between one hour for
+// backups and four hours for checks.
+type JobDefaults struct {
+	// ActiveDeadlines overrides the built-in active deadline per operation type.
+	ActiveDeadlines map[string]time.Duration
+	// BackoffLimits overrides the built-in backoff limit of 0 per operation type.
+	BackoffLimits map[string]int32
+}
+
+// ActiveDeadlineSeconds returns the active deadline of a Job of the operation type.
+func (d *JobDefaults) ActiveDeadlineSeconds(operation string, jobConfig *backupv1alpha1.JobConfiguration) int64 {
+	if jobConfig != nil && jobConfig.ActiveDeadlineSeconds != nil {
+		return *jobConfig.ActiveDeadlineSeconds
+	}
+	deadline := defaultActiveDeadlines[operation]
+	if d != nil {
+		if override, ok := d.ActiveDeadlines[operation]; ok {
+			deadline = override
+		}
+	}
+	return int64(deadline.Seconds())
+}
+
+// BackoffLimit returns the backoff limit of a Job of the operation type.
+func (d *JobDefaults) BackoffLimit(operation string, jobConfig *backupv1alpha1.JobConfiguration) int32 {
+	if jobConfig != nil && jobConfig.BackoffLimit != nil {
+		return *jobConfig.BackoffLimit
+	}
+	if d != nil {
+		return d.BackoffLimits[operation]
+	}
+	return 0
+}
+
+// ParseJobActiveDeadlines parses a comma-separated list of operation=duration pairs,
+// e.g. "backup=12h,check=8h".
+func ParseJobActiveDeadlines(value string) (map[string]time.Duration, error) {
+	deadlines := map[string]time.Duration{}
+	err := parseOperationPairs(value, func(operation, raw string) error {
+		deadline, err := time.ParseDuration(raw)
+		if err != nil || deadline <= 0 {
+			return fmt.Errorf("invalid active deadline %q for %s, expected a positive duration", raw, operation)
+		}
+		deadlines[operation] = deadline
+		return nil
+	})
+	return deadlines, err
+}
+
+// ParseJobBackoffLimits parses a comma-separated list of operation=limit pairs, e.g.
+// "backup=2,restore=1".
+func ParseJobBackoffLimits(value string) (map[string]int32, error) {
+	limits := map[string]int32{}
+	err := parseOperationPairs(value, func(operation, raw string) error {
+		limit, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid backoff limit %q for %s, expected a non-negative integer", raw, operation)
+		}
+		limits[operation] = int32(limit)
+		return nil
+	})
+	return limits, err
+}
+
+// parseOperationPairs calls set for every operation=value pair of a comma-separated list.
+func parseOperationPairs(value string, set func(operation, value string) error) error {
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		operation, raw, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("invalid job default %q, expected operation=value", pair)
+		}
+		if _, known := defaultActiveDeadlines[operation]; !known {
+			return fmt.Errorf("unknown operation %q, expected one of backup, restore, retention, check, prune, verification, replication", operation)
+		}
+		if err := set(operation, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
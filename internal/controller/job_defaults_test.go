@@ -0,0 +1,93 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Job defaults", func() {
+	It("should use the built-in defaults without configuration", func() {
+		var defaults *JobDefaults
+		Expect(defaults.ActiveDeadlineSeconds(JobOperationBackup, nil)).To(Equal(int64(3600)))
+		Expect(defaults.ActiveDeadlineSeconds(JobOperationCheck, nil)).To(Equal(int64(14400)))
+		Expect(defaults.BackoffLimit(JobOperationBackup, nil)).To(Equal(int32(0)))
+	})
+
+	It("should override the built-in defaults per operation type", func() {
+		defaults := &JobDefaults{
+			ActiveDeadlines: map[string]time.Duration{JobOperationBackup: 24 * time.Hour},
+			BackoffLimits:   map[string]int32{JobOperationRestore: 2},
+		}
+		Expect(defaults.ActiveDeadlineSeconds(JobOperationBackup, nil)).To(Equal(int64(86400)))
+		Expect(defaults.ActiveDeadlineSeconds(JobOperationRestore, nil)).To(Equal(int64(3600)))
+		Expect(defaults.BackoffLimit(JobOperationRestore, nil)).To(Equal(int32(2)))
+		Expect(defaults.BackoffLimit(JobOperationBackup, nil)).To(Equal(int32(0)))
+	})
+
+	It("should prefer the job configuration of the resource", func() {
+		defaults := &JobDefaults{
+			ActiveDeadlines: map[string]time.Duration{JobOperationBackup: 24 * time.Hour},
+			BackoffLimits:   map[string]int32{JobOperationBackup: 2},
+		}
+		deadline, backoff := int64(600), int32(5)
+		jobConfig := &backupv1alpha1.JobConfiguration{ActiveDeadlineSeconds: &deadline, BackoffLimit: &backoff}
+		Expect(defaults.ActiveDeadlineSeconds(JobOperationBackup, jobConfig)).To(Equal(int64(600)))
+		Expect(defaults.BackoffLimit(JobOperationBackup, jobConfig)).To(Equal(int32(5)))
+
+		// A job configuration without limits keeps the defaults
+		jobConfig = &backupv1alpha1.JobConfiguration{}
+		Expect(defaults.ActiveDeadlineSeconds(JobOperationBackup, jobConfig)).To(Equal(int64(86400)))
+	})
+
+	It("should keep an explicit job config equal to the built-in defaults", func() {
+		defaults := &JobDefaults{
+			ActiveDeadlines: map[string]time.Duration{JobOperationBackup: 24 * time.Hour},
+			BackoffLimits:   map[string]int32{JobOperationBackup: 2},
+		}
+		deadline, backoff := int64(3600), int32(0)
+		jobConfig := &backupv1alpha1.JobConfiguration{ActiveDeadlineSeconds: &deadline, BackoffLimit: &backoff}
+		Expect(defaults.ActiveDeadlineSeconds(JobOperationBackup, jobConfig)).To(Equal(int64(3600)))
+		Expect(defaults.BackoffLimit(JobOperationBackup, jobConfig)).To(Equal(int32(0)))
+	})
+
+	It("should parse active deadlines and backoff limits", func() {
+		deadlines, err := ParseJobActiveDeadlines("backup=12h, check=8h,")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deadlines).To(Equal(map[string]time.Duration{"backup": 12 * time.Hour, "check": 8 * time.Hour}))
+
+		limits, err := ParseJobBackoffLimits("restore=1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(limits).To(Equal(map[string]int32{"restore": 1}))
+	})
+
+	It("should reject invalid job defaults", func() {
+		_, err := ParseJobActiveDeadlines("backup")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseJobActiveDeadlines("snapshot=1h")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseJobActiveDeadlines("backup=-1h")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseJobBackoffLimits("backup=-1")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,253 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// eventRelayWindow is the maximum age of the pod events and OOM kills relayed, so a
+	// restarted operator does not relay old failures again
+	eventRelayWindow = 5 * time.Minute
+	// eventRelayInterval is how often the pods of a running Job are checked for new
+	// events
+	eventRelayInterval = 30 * time.Second
+	// oomKilledReason is the termination reason of containers exceeding their memory limit
+	oomKilledReason = "OOMKilled"
+	// involvedObjectUIDField selects the events of an object by its UID
+	involvedObjectUIDField = "involvedObject.uid"
+)
+
+// JobEventRelayReconciler relays the Warning events of the pods of the operator's Jobs,
+// e.g. ImagePullBackOff or FailedMount, and OOM kills of their containers to the
+// resource owning the Job, so the failure reason shows up on the resource users look at.
+// It watches the Jobs and reads their pods and the events of each pod with the API
+// reader, so the operator doesn't cache all Pods and Events of the cluster.
+type JobEventRelayReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader reads pods and events directly from the API server. Falls back to
+	// Client if not set.
+	APIReader client.Reader
+
+	mu sync.Mutex
+	// relayed holds per Job the relayed event counts by pod and event UID, and the
+	// relayed OOM kills by pod UID and container name
+	relayed map[types.NamespacedName]map[string]int32
+}
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch
+
+// Reconcile relays the new Warning events and OOM kills of the pods of a Job. Running
+// Jobs are checked again every eventRelayInterval.
+func (r *JobEventRelayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, req.NamespacedName, job); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.forget(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	finished, _, finishedAt := jobFinished(job)
+	now := time.Now()
+	if finished && now.Sub(finishedAt) > eventRelayWindow {
+		r.forget(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	owner, err := r.owningResource(ctx, job)
+	if err != nil || owner == nil {
+		return ctrl.Result{}, err
+	}
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{batchv1.JobNameLabel: job.Name}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list pods of job %s: %w", job.Name, err)
+	}
+	for i := range pods.Items {
+		if err := r.relayPod(ctx, reader, req.NamespacedName, owner, &pods.Items[i], now); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if finished {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: eventRelayInterval}, nil
+}
+
+// relayPod relays the new Warning events and OOM kills of a pod of a Job.
+func (r *JobEventRelayReconciler) relayPod(ctx context.Context, reader client.Reader, job types.NamespacedName,
+	owner *corev1.ObjectReference, pod *corev1.Pod, now time.Time) error {
+	events := &corev1.EventList{}
+	if err := reader.List(ctx, events, client.InNamespace(pod.Namespace),
+		client.MatchingFields{involvedObjectUIDField: string(pod.UID)}); err != nil {
+		return fmt.Errorf("failed to list events of pod %s: %w", pod.Name, err)
+	}
+	for i := range events.Items {
+		event := &events.Items[i]
+		if event.InvolvedObject.UID != pod.UID || !isPodWarning(event) || now.Sub(eventLastSeen(event)) > eventRelayWindow {
+			continue
+		}
+		count := max(event.Count, 1)
+		if !r.markRelayed(job, string(pod.UID)+"/"+string(event.UID), count) {
+			continue
+		}
+		r.Recorder.Event(owner, corev1.EventTypeWarning, event.Reason,
+			fmt.Sprintf("Pod %s of job %s: %s", pod.Name, job.Name, event.Message))
+	}
+
+	for _, status := range slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses) {
+		terminated := status.State.Terminated
+		if terminated == nil || terminated.Reason != oomKilledReason || now.Sub(terminated.FinishedAt.Time) > eventRelayWindow {
+			continue
+		}
+		if !r.markRelayed(job, string(pod.UID)+"/"+oomKilledReason+"/"+status.Name, 1) {
+			continue
+		}
+		message := fmt.Sprintf("Container %s of pod %s of job %s ran out of memory", status.Name, pod.Name, job.Name)
+		if limit := containerMemoryLimit(pod, status.Name); limit != "" {
+			message += fmt.Sprintf(" (limit %s)", limit)
+		}
+		r.Recorder.Event(owner, corev1.EventTypeWarning, oomKilledReason, message)
+	}
+	return nil
+}
+
+// owningResource returns the resource of this operator controlling a Job, directly or
+// through a CronJob. It returns nil for other Jobs.
+func (r *JobEventRelayReconciler) owningResource(ctx context.Context, job *batchv1.Job) (*corev1.ObjectReference, error) {
+	owner := metav1.GetControllerOf(job)
+	if owner != nil && owner.Kind == "CronJob" {
+		cronJob := &batchv1.CronJob{}
+		if err := r.Get(ctx, types.NamespacedName{Name: owner.Name, Namespace: job.Namespace}, cronJob); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		owner = metav1.GetControllerOf(cronJob)
+	}
+	if owner == nil {
+		return nil, nil
+	}
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil || gv.Group != backupv1alpha1.GroupVersion.Group {
+		return nil, nil
+	}
+
+	return &corev1.ObjectReference{
+		APIVersion: owner.APIVersion,
+		Kind:       owner.Kind,
+		Name:       owner.Name,
+		Namespace:  job.Namespace,
+		UID:        owner.UID,
+	}, nil
+}
+
+// markRelayed records that an event of a pod of a Job was relayed with the given count.
+// It returns false if the event was already relayed with this count.
+func (r *JobEventRelayReconciler) markRelayed(job types.NamespacedName, key string, count int32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.relayed == nil {
+		r.relayed = map[types.NamespacedName]map[string]int32{}
+	}
+	if r.relayed[job] == nil {
+		r.relayed[job] = map[string]int32{}
+	}
+	if r.relayed[job][key] >= count {
+		return false
+	}
+	r.relayed[job][key] = count
+	return true
+}
+
+// forget drops the relayed events of a deleted or long finished Job.
+func (r *JobEventRelayReconciler) forget(job types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.relayed, job)
+}
+
+// isPodWarning reports whether an event is a Warning about a pod.
+func isPodWarning(event *corev1.Event) bool {
+	return event.Type == corev1.EventTypeWarning && event.InvolvedObject.Kind == "Pod"
+}
+
+// eventLastSeen returns when an event last occurred.
+func eventLastSeen(event *corev1.Event) time.Time {
+	if event.Series != nil {
+		return event.Series.LastObservedTime.Time
+	}
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// containerMemoryLimit returns the memory limit of a container of a pod, empty if unset.
+func containerMemoryLimit(pod *corev1.Pod, name string) string {
+	for _, container := range slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers) {
+		if container.Name != name {
+			continue
+		}
+		if limit, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+			return limit.String()
+		}
+	}
+	return ""
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *JobEventRelayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	controlledJobs := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return metav1.GetControllerOf(obj) != nil
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("jobeventrelay").
+		For(&batchv1.Job{}, builder.WithPredicates(controlledJobs)).
+		Complete(r)
+}
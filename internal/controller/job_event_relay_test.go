@@ -0,0 +1,175 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Job event relay", func() {
+	var (
+		testScheme *runtime.Scheme
+		recorder   *record.FakeRecorder
+	)
+
+	controllerRef := func(apiVersion, kind, name string) []metav1.OwnerReference {
+		isController := true
+		return []metav1.OwnerReference{{
+			APIVersion: apiVersion, Kind: kind, Name: name, UID: types.UID(name + "-uid"), Controller: &isController,
+		}}
+	}
+
+	podWarning := func(name, reason, message string, lastSeen time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+			InvolvedObject: corev1.ObjectReference{
+				Kind: "Pod", Name: "app-job-abcde", Namespace: "default", UID: "pod-uid",
+			},
+			Type:          corev1.EventTypeWarning,
+			Reason:        reason,
+			Message:       message,
+			Count:         1,
+			LastTimestamp: metav1.NewTime(lastSeen),
+		}
+	}
+
+	newReconciler := func(objects ...client.Object) *JobEventRelayReconciler {
+		c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).
+			WithIndex(&corev1.Event{}, involvedObjectUIDField, func(obj client.Object) []string {
+				return []string{string(obj.(*corev1.Event).InvolvedObject.UID)}
+			}).Build()
+		return &JobEventRelayReconciler{Client: c, Scheme: testScheme, Recorder: recorder, APIReader: c}
+	}
+
+	reconcileJob := func(r *JobEventRelayReconciler, name string) ctrl.Result {
+		result, err := r.Reconcile(context.Background(), ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: name, Namespace: "default"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+
+	jobPod := func(jobName string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: "app-job-abcde", Namespace: "default", UID: "pod-uid",
+			Labels:          map[string]string{batchv1.JobNameLabel: jobName},
+			OwnerReferences: controllerRef("batch/v1", "Job", jobName),
+		}}
+	}
+
+	BeforeEach(func() {
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	It("should relay pod warnings to the resource owning the job once", func() {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name: "restore-app", Namespace: "default",
+			OwnerReferences: controllerRef(backupv1alpha1.GroupVersion.String(), "ResticRestore", "app"),
+		}}
+		otherPod := podWarning("other", "FailedMount", "MountVolume.SetUp failed", time.Now())
+		otherPod.InvolvedObject.UID = "other-pod-uid"
+		r := newReconciler(job, jobPod("restore-app"), otherPod,
+			podWarning("mount", "FailedMount", "MountVolume.SetUp failed for volume \"data\"", time.Now()),
+			podWarning("stale", "FailedScheduling", "0/3 nodes are available", time.Now().Add(-time.Hour)))
+
+		// Running jobs are checked again for new events
+		Expect(reconcileJob(r, "restore-app").RequeueAfter).To(Equal(eventRelayInterval))
+		Expect(recorder.Events).To(Receive(Equal(
+			"Warning FailedMount Pod app-job-abcde of job restore-app: MountVolume.SetUp failed for volume \"data\"")))
+		Expect(recorder.Events).To(BeEmpty())
+
+		// Relayed events are not relayed again
+		reconcileJob(r, "restore-app")
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should relay OOM kills to the resource owning the CronJob of the job", func() {
+		cronJob := &batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{
+			Name: "resticbackup-app", Namespace: "default",
+			OwnerReferences: controllerRef(backupv1alpha1.GroupVersion.String(), "ResticBackup", "app"),
+		}}
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name: "resticbackup-app-29480160", Namespace: "default",
+			OwnerReferences: controllerRef("batch/v1", "CronJob", "resticbackup-app"),
+		}}
+		pod := jobPod("resticbackup-app-29480160")
+		pod.Spec = corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "restic",
+			Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			}},
+		}}}
+		pod.Status = corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name: "restic",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Reason: oomKilledReason, ExitCode: 137, FinishedAt: metav1.Now(),
+			}},
+		}}}
+		r := newReconciler(cronJob, job, pod)
+
+		reconcileJob(r, "resticbackup-app-29480160")
+		Expect(recorder.Events).To(Receive(Equal(
+			"Warning OOMKilled Container restic of pod app-job-abcde of job resticbackup-app-29480160 ran out of memory (limit 256Mi)")))
+
+		reconcileJob(r, "resticbackup-app-29480160")
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should ignore pods of jobs not owned by the operator", func() {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "restore-app", Namespace: "default"}}
+		r := newReconciler(job, jobPod("restore-app"), podWarning("mount", "FailedMount", "MountVolume.SetUp failed", time.Now()))
+
+		reconcileJob(r, "restore-app")
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should not check jobs finished before the relay window again", func() {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "restore-app", Namespace: "default",
+				OwnerReferences: controllerRef(backupv1alpha1.GroupVersion.String(), "ResticRestore", "app"),
+			},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+			}}},
+		}
+		r := newReconciler(job, jobPod("restore-app"), podWarning("mount", "FailedMount", "MountVolume.SetUp failed", time.Now()))
+
+		Expect(reconcileJob(r, "restore-app").RequeueAfter).To(BeZero())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+})
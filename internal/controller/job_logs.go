@@ -0,0 +1,147 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// jobLogTailLines is the number of log lines read from a failed job pod. restic
+	// prints many progress lines, which are dropped before the excerpt is taken.
+	jobLogTailLines = 200
+	// jobFailureLogLines is the number of log lines kept in a failure excerpt.
+	jobFailureLogLines = 20
+)
+
+// jobFailureLogs returns the end of the log of the restic container of the most recently
+// terminated pod of a failed Job, without restic's progress lines and with the secrets
+// of the container redacted. The excerpt keeps the error messages, which restic prints
+// last, so the reason of a failure is known after the pod was deleted. It returns an
+// empty string if logs is nil or the Job has no terminated pod.
+func jobFailureLogs(ctx context.Context, reader client.Reader, logs PodLogReader, job *batchv1.Job) (string, error) {
+	if logs == nil {
+		return "", nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.JobNameLabel: job.Name},
+	); err != nil {
+		return "", fmt.Errorf("failed to list pods of job %s: %w", job.Name, err)
+	}
+
+	var latest *corev1.Pod
+	var latestFinish *corev1.ContainerStateTerminated
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if status.Name != "restic" || terminated == nil {
+				continue
+			}
+			if latestFinish == nil || terminated.FinishedAt.After(latestFinish.FinishedAt.Time) {
+				latest, latestFinish = pod, terminated
+			}
+		}
+	}
+	if latest == nil {
+		return "", nil
+	}
+
+	output, err := logs.TailLogs(ctx, latest.Namespace, latest.Name, "restic", jobLogTailLines)
+	if err != nil {
+		return "", err
+	}
+	secrets, err := containerSecrets(ctx, reader, latest)
+	if err != nil {
+		return "", err
+	}
+	return restic.Excerpt(output, jobFailureLogLines, secrets...), nil
+}
+
+// containerSecrets returns the values of the Secret keys the restic container of a pod
+// reads into its environment, so they can be redacted from its log.
+func containerSecrets(ctx context.Context, reader client.Reader, pod *corev1.Pod) ([]string, error) {
+	secrets := map[string]*corev1.Secret{}
+	getSecret := func(name string) (*corev1.Secret, error) {
+		if secret, ok := secrets[name]; ok {
+			return secret, nil
+		}
+		secret := &corev1.Secret{}
+		if err := reader.Get(ctx, types.NamespacedName{Name: name, Namespace: pod.Namespace}, secret); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get secret %s: %w", name, err)
+			}
+			secret = nil
+		}
+		secrets[name] = secret
+		return secret, nil
+	}
+
+	var values []string
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "restic" {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil {
+				continue
+			}
+			secret, err := getSecret(env.ValueFrom.SecretKeyRef.Name)
+			if err != nil {
+				return nil, err
+			}
+			if secret != nil {
+				values = append(values, string(secret.Data[env.ValueFrom.SecretKeyRef.Key]))
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef == nil {
+				continue
+			}
+			secret, err := getSecret(envFrom.SecretRef.Name)
+			if err != nil {
+				return nil, err
+			}
+			if secret != nil {
+				for _, value := range secret.Data {
+					values = append(values, string(value))
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+// withJobLogs appends the log excerpt of a failed job to an event message.
+func withJobLogs(message, logs string) string {
+	if logs == "" {
+		return message
+	}
+	return message + ":\n" + logs
+}
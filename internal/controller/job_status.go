@@ -0,0 +1,110 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// jobFinished reports whether a Job has finished, whether it succeeded and when it finished.
+func jobFinished(job *batchv1.Job) (finished bool, succeeded bool, finishedAt time.Time) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, true, condition.LastTransitionTime.Time
+		case batchv1.JobFailed:
+			return true, false, condition.LastTransitionTime.Time
+		}
+	}
+	return false, false, time.Time{}
+}
+
+// annotateJob sets an annotation on a Job. The operator records side effects of a
+// finished Job this way before running them, so they don't repeat if the following
+// status update fails.
+func annotateJob(ctx context.Context, c client.Client, job *batchv1.Job, key, value string) error {
+	patch := client.MergeFrom(job.DeepCopy())
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[key] = value
+	if err := c.Patch(ctx, job, patch); err != nil {
+		return fmt.Errorf("failed to annotate job %s: %w", job.Name, err)
+	}
+	return nil
+}
+
+// jobTerminationMessage returns the termination message of the restic container of the
+// most recently terminated pod of a Job. The generated jobs write a summary of the restic
+// output to the termination message, so the operator needs no access to pod logs.
+func jobTerminationMessage(ctx context.Context, reader client.Reader, job *batchv1.Job) (string, error) {
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.JobNameLabel: job.Name},
+	); err != nil {
+		return "", fmt.Errorf("failed to list pods of job %s: %w", job.Name, err)
+	}
+
+	var latest *corev1.ContainerStateTerminated
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if status.Name != "restic" || terminated == nil {
+				continue
+			}
+			if latest == nil || terminated.FinishedAt.After(latest.FinishedAt.Time) {
+				latest = terminated
+			}
+		}
+	}
+
+	if latest == nil {
+		return "", fmt.Errorf("no terminated pod found for job %s", job.Name)
+	}
+	return latest.Message, nil
+}
+
+// jobOOMKilled reports whether a container of a pod of a Job was killed for exceeding
+// its memory limit.
+func jobOOMKilled(ctx context.Context, reader client.Reader, job *batchv1.Job) (bool, error) {
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.JobNameLabel: job.Name},
+	); err != nil {
+		return false, fmt.Errorf("failed to list pods of job %s: %w", job.Name, err)
+	}
+
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil && terminated.Reason == oomKilledReason {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
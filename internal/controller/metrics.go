@@ -0,0 +1,201 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// startupAuditCorrections counts child objects corrected by the startup audit.
+	startupAuditCorrections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restic_operator_startup_audit_corrections_total",
+		Help: "Number of owned CronJobs and Jobs corrected by the startup audit",
+	}, []string{"kind"})
+
+	// operatorOverloaded reports controllers whose workqueue exceeds the overload thresholds.
+	operatorOverloaded = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_operator_overloaded",
+		Help: "Whether a controller's workqueue exceeds the overload thresholds (1 = overloaded, 0 = ok)",
+	}, []string{"controller"})
+
+	// operatorInfo reports the operator build info and the enabled feature gates.
+	operatorInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_operator_info",
+		Help: "Build information and enabled feature gates of the operator (always 1)",
+	}, []string{"version", "git_commit", "feature_gates"})
+
+	// backupDataChangeRate reports the average data added per day by a backup.
+	backupDataChangeRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_backup_data_change_rate_bytes_per_day",
+		Help: "Average number of bytes added to the repository per day by a backup",
+	}, []string{"namespace", "name"})
+
+	// backupScheduleRecommendation reports backups whose schedule doesn't match their data-change rate.
+	backupScheduleRecommendation = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_backup_schedule_recommendation",
+		Help: "Whether a schedule change is recommended for a backup based on its data-change rate (1 = recommended, 0 = schedule fits)",
+	}, []string{"namespace", "name"})
+
+	// backupLastSuccessTimestamp reports when a backup last completed successfully.
+	backupLastSuccessTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_backup_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful run of a backup",
+	}, []string{"namespace", "name"})
+
+	// backupLastDuration reports how long the last run of a backup took.
+	backupLastDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_backup_last_duration_seconds",
+		Help: "Duration of the last run of a backup in seconds",
+	}, []string{"namespace", "name"})
+
+	// backupConsecutiveFailures reports the runs of a backup that failed since the last success.
+	backupConsecutiveFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_backup_consecutive_failures",
+		Help: "Number of runs of a backup that failed since the last successful one",
+	}, []string{"namespace", "name"})
+
+	// backupMissed reports backups whose last successful run is older than allowed.
+	backupMissed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_backup_missed",
+		Help: "Whether the last successful run of a backup is older than allowed (1 = missed, 0 = current)",
+	}, []string{"namespace", "name"})
+
+	// repositorySnapshots reports the number of snapshots in a repository.
+	repositorySnapshots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_repository_snapshots",
+		Help: "Number of snapshots in a repository",
+	}, []string{"namespace", "name"})
+
+	// repositorySize reports the restore size of all snapshots in a repository.
+	repositorySize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_repository_size_bytes",
+		Help: "Total restore size of all snapshots in a repository in bytes",
+	}, []string{"namespace", "name"})
+
+	// repositoryStatisticsUpdated reports when the statistics of a repository were gathered.
+	repositoryStatisticsUpdated = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_repository_statistics_updated_timestamp_seconds",
+		Help: "Unix timestamp of the last time the statistics of a repository were gathered",
+	}, []string{"namespace", "name"})
+
+	// retentionExpectedSnapshots reports the number of snapshots the schedule and retention
+	// policy of a backup should keep.
+	retentionExpectedSnapshots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_repository_backup_expected_snapshots",
+		Help: "Number of snapshots the schedule and retention policy of a backup should keep",
+	}, []string{"namespace", "name", "backup"})
+
+	// retentionActualSnapshots reports the number of snapshots of a backup in a repository.
+	retentionActualSnapshots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_repository_backup_snapshots",
+		Help: "Number of snapshots of a backup in a repository",
+	}, []string{"namespace", "name", "backup"})
+
+	// retentionStatus reports the retention report status of a backup.
+	retentionStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_repository_retention_status",
+		Help: "Retention report status of a backup (1 for the current status, 0 otherwise)",
+	}, []string{"namespace", "name", "backup", "status"})
+
+	// restorePhase reports the current phase of a restore.
+	restorePhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_restore_phase",
+		Help: "Current phase of a restore (1 for the current phase, 0 otherwise)",
+	}, []string{"namespace", "name", "phase"})
+
+	// verificationPassed reports whether the last backup verification passed.
+	verificationPassed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_backup_verification_passed",
+		Help: "Whether the last verification of the latest snapshot against its source passed (1) or failed (0)",
+	}, []string{"namespace", "name"})
+
+	// verificationSizeRatio reports the size of the verified snapshot relative to its source.
+	verificationSizeRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_backup_verification_size_ratio",
+		Help: "Size of the latest snapshot relative to the size of its source at the last verification",
+	}, []string{"namespace", "name"})
+
+	// verificationFileCountRatio reports the number of files of the verified snapshot
+	// relative to its source.
+	verificationFileCountRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_backup_verification_file_count_ratio",
+		Help: "Number of files of the latest snapshot relative to its source at the last verification",
+	}, []string{"namespace", "name"})
+
+	// snapshotCacheRequests counts snapshot lookups served from the snapshot cache or
+	// listed from the repository.
+	snapshotCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restic_snapshot_cache_requests_total",
+		Help: "Number of snapshot lookups by result (hit = served from the cache, miss = listed from the repository)",
+	}, []string{"result"})
+
+	// snapshotCacheEntries reports the number of repositories with cached snapshots.
+	snapshotCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "restic_snapshot_cache_entries",
+		Help: "Number of repositories whose snapshots are cached",
+	})
+
+	// repositoryBackendLatency observes the duration of the backend latency probes of a repository.
+	repositoryBackendLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "restic_repository_backend_latency_seconds",
+		Help:    "Duration of the backend latency probes of a repository (restic cat config)",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"namespace", "name", "backend"})
+
+	// repositoryBackendProbes counts the backend latency probes of a repository by result.
+	repositoryBackendProbes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restic_repository_backend_probes_total",
+		Help: "Number of backend latency probes of a repository by result (success or failure)",
+	}, []string{"namespace", "name", "backend", "result"})
+
+	// repositoryBackendUp reports whether the last backend latency probe of a repository succeeded.
+	repositoryBackendUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restic_repository_backend_up",
+		Help: "Whether the last backend latency probe of a repository succeeded (1 = available, 0 = unavailable)",
+	}, []string{"namespace", "name", "backend"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		startupAuditCorrections,
+		operatorOverloaded,
+		operatorInfo,
+		backupDataChangeRate,
+		backupScheduleRecommendation,
+		backupLastSuccessTimestamp,
+		backupLastDuration,
+		backupConsecutiveFailures,
+		backupMissed,
+		repositorySnapshots,
+		repositorySize,
+		repositoryStatisticsUpdated,
+		retentionExpectedSnapshots,
+		retentionActualSnapshots,
+		retentionStatus,
+		restorePhase,
+		verificationPassed,
+		verificationSizeRatio,
+		verificationFileCountRatio,
+		snapshotCacheRequests,
+		snapshotCacheEntries,
+		repositoryBackendLatency,
+		repositoryBackendProbes,
+		repositoryBackendUp,
+	)
+}
@@ -0,0 +1,491 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+)
+
+const (
+	// namespaceRestoreLabel marks the ResticRestores and PVCs created for a NamespaceRestore.
+	namespaceRestoreLabel = "backup.resticbackup.io/namespace-restore"
+
+	// defaultNamespaceRestorePVCSize is the size of created PVCs without PVC template.
+	defaultNamespaceRestorePVCSize = "10Gi"
+
+	// maxRestoreNameLength keeps the name of the restore job ("resticrestore-<name>")
+	// within the 63 character limit of label values.
+	maxRestoreNameLength = 49
+)
+
+// NamespaceRestoreReconciler reconciles a NamespaceRestore object
+type NamespaceRestoreReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// MaxConcurrentReconciles is the number of NamespaceRestores reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+	// FeatureGates enables optional capabilities. If nil, the feature defaults apply.
+	FeatureGates *features.Gate
+}
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=namespacerestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=namespacerestores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=namespacerestores/finalizers,verbs=update
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticbackups,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop.
+func (r *NamespaceRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciling NamespaceRestore")
+
+	// Fetch the NamespaceRestore instance
+	nsRestore := &backupv1alpha1.NamespaceRestore{}
+	if err := r.Get(ctx, req.NamespacedName, nsRestore); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("NamespaceRestore resource not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get NamespaceRestore")
+		return ctrl.Result{}, err
+	}
+
+	// The created ResticRestores are owned by the NamespaceRestore and garbage
+	// collected with it, restored PVCs are kept
+	if !nsRestore.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	// Handle the namespace restore based on phase
+	switch nsRestore.Status.Phase {
+	case "", backupv1alpha1.NamespaceRestorePhasePending:
+		return r.handlePending(ctx, nsRestore)
+	case backupv1alpha1.NamespaceRestorePhaseInProgress:
+		return r.handleInProgress(ctx, nsRestore)
+	}
+
+	// Nothing to do for finished namespace restores
+	return ctrl.Result{}, nil
+}
+
+// handlePending selects the ResticBackups to restore and records a restore entry for each
+// of them. The selection is made once, later changes to the ResticBackups are ignored.
+func (r *NamespaceRestoreReconciler) handlePending(ctx context.Context, nsRestore *backupv1alpha1.NamespaceRestore) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	sourceNamespace := namespaceRestoreSource(nsRestore)
+	nsRestore.Status.ObservedGeneration = nsRestore.Generation
+
+	selector := labels.Everything()
+	if nsRestore.Spec.BackupSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(nsRestore.Spec.BackupSelector); err != nil {
+			return r.fail(ctx, nsRestore, "InvalidBackupSelector", fmt.Sprintf("Invalid backup selector: %v", err))
+		}
+	}
+
+	// The backups of the source namespace are listed, so the restores created for them
+	// must be permitted to reference all of them
+	err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "ResticRestore", nsRestore.Namespace, "ResticBackup", sourceNamespace, "")
+	setReferenceDenied(&nsRestore.Status.Conditions, err)
+	var denied *referenceDeniedError
+	if errors.As(err, &denied) {
+		return r.fail(ctx, nsRestore, reasonReferenceDenied, err.Error())
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	backups, skipped, err := r.selectBackups(ctx, sourceNamespace, selector)
+	if err != nil {
+		log.Error(err, "Failed to select backups")
+		return ctrl.Result{}, err
+	}
+	nsRestore.Status.Skipped = skipped
+	if len(backups) == 0 {
+		return r.fail(ctx, nsRestore, "NoBackupsFound",
+			fmt.Sprintf("No ResticBackup with a PVC source found in namespace %s", sourceNamespace))
+	}
+
+	now := metav1.NewTime(time.Now())
+	nsRestore.Status.Phase = backupv1alpha1.NamespaceRestorePhaseInProgress
+	nsRestore.Status.StartTime = &now
+	nsRestore.Status.Restores = make([]backupv1alpha1.NamespaceRestoreEntry, 0, len(backups))
+	for _, backup := range backups {
+		nsRestore.Status.Restores = append(nsRestore.Status.Restores, backupv1alpha1.NamespaceRestoreEntry{
+			Backup:  backup.Name,
+			Restore: namespaceRestoreChildName(nsRestore.Name, backup.Name),
+			PVC:     backup.Spec.Source.PVC.ClaimName,
+		})
+	}
+	r.setCondition(nsRestore, conditions.UnknownCondition("RestoreInProgress",
+		fmt.Sprintf("Restoring %d backups from namespace %s", len(backups), sourceNamespace)))
+	if err := r.Status().Update(ctx, nsRestore); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.Recorder.Event(nsRestore, corev1.EventTypeNormal, "RestoreStarted",
+		fmt.Sprintf("Restoring %d backups from namespace %s, skipped %d", len(backups), sourceNamespace, len(skipped)))
+
+	return r.handleInProgress(ctx, nsRestore)
+}
+
+// handleInProgress creates the missing target PVCs and ResticRestores and aggregates the
+// phases of the ResticRestores.
+func (r *NamespaceRestoreReconciler) handleInProgress(ctx context.Context, nsRestore *backupv1alpha1.NamespaceRestore) (ctrl.Result, error) {
+	for i := range nsRestore.Status.Restores {
+		entry := &nsRestore.Status.Restores[i]
+		if err := r.reconcileEntry(ctx, nsRestore, entry); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	completed, failed := countRestorePhases(nsRestore.Status.Restores)
+	nsRestore.Status.Completed = completed
+	nsRestore.Status.Failed = failed
+	total := int32(len(nsRestore.Status.Restores))
+
+	if completed+failed < total {
+		r.setCondition(nsRestore, conditions.UnknownCondition("RestoreInProgress",
+			fmt.Sprintf("%d of %d restores finished", completed+failed, total)))
+		if err := r.Status().Update(ctx, nsRestore); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	now := metav1.NewTime(time.Now())
+	nsRestore.Status.CompletionTime = &now
+	message := fmt.Sprintf("%d of %d restores completed", completed, total)
+	switch {
+	case failed == 0:
+		nsRestore.Status.Phase = backupv1alpha1.NamespaceRestorePhaseCompleted
+		r.setCondition(nsRestore, conditions.ReadyCondition("RestoreCompleted", message))
+		r.Recorder.Event(nsRestore, corev1.EventTypeNormal, "RestoreCompleted", message)
+	case completed == 0:
+		nsRestore.Status.Phase = backupv1alpha1.NamespaceRestorePhaseFailed
+		r.setCondition(nsRestore, conditions.NotReadyCondition("RestoreFailed", message))
+		r.Recorder.Event(nsRestore, corev1.EventTypeWarning, "RestoreFailed", message)
+	default:
+		nsRestore.Status.Phase = backupv1alpha1.NamespaceRestorePhasePartiallyFailed
+		r.setCondition(nsRestore, conditions.NotReadyCondition("RestorePartiallyFailed", message))
+		r.Recorder.Event(nsRestore, corev1.EventTypeWarning, "RestorePartiallyFailed", message)
+	}
+
+	return ctrl.Result{}, r.Status().Update(ctx, nsRestore)
+}
+
+// reconcileEntry creates the target PVC and ResticRestore of an entry if they do not
+// exist yet and updates the phase of the entry from the ResticRestore.
+func (r *NamespaceRestoreReconciler) reconcileEntry(ctx context.Context, nsRestore *backupv1alpha1.NamespaceRestore, entry *backupv1alpha1.NamespaceRestoreEntry) error {
+	if isFinishedRestorePhase(entry.Phase) {
+		return nil
+	}
+
+	restore := &backupv1alpha1.ResticRestore{}
+	err := r.Get(ctx, types.NamespacedName{Name: entry.Restore, Namespace: nsRestore.Namespace}, restore)
+	if err == nil {
+		entry.Phase = restore.Status.Phase
+		if entry.Phase == "" {
+			entry.Phase = backupv1alpha1.RestorePhasePending
+		}
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	if entry.Phase != "" {
+		// The ResticRestore was deleted while running
+		entry.Phase = backupv1alpha1.RestorePhaseFailed
+		r.Recorder.Event(nsRestore, corev1.EventTypeWarning, "RestoreNotFound",
+			fmt.Sprintf("ResticRestore %s was deleted before it finished", entry.Restore))
+		return nil
+	}
+
+	backup := &backupv1alpha1.ResticBackup{}
+	if err := r.Get(ctx, types.NamespacedName{Name: entry.Backup, Namespace: namespaceRestoreSource(nsRestore)}, backup); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		entry.Phase = backupv1alpha1.RestorePhaseFailed
+		r.Recorder.Event(nsRestore, corev1.EventTypeWarning, "BackupNotFound",
+			fmt.Sprintf("ResticBackup %s was deleted before it was restored", entry.Backup))
+		return nil
+	}
+
+	created, err := r.ensurePVC(ctx, nsRestore, entry.PVC)
+	if err != nil {
+		if apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) {
+			entry.Phase = backupv1alpha1.RestorePhaseFailed
+			r.Recorder.Event(nsRestore, corev1.EventTypeWarning, "PVCCreationFailed", err.Error())
+			return nil
+		}
+		return err
+	}
+	entry.PVCCreated = entry.PVCCreated || created
+
+	restore = buildNamespaceRestoreChild(nsRestore, entry, backup)
+	if err := controllerutil.SetControllerReference(nsRestore, restore, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+	if _, err := createOrAdopt(ctx, r.Client, nsRestore, restore, &backupv1alpha1.ResticRestore{}); err != nil {
+		if errors.Is(err, errNotControlled) {
+			entry.Phase = backupv1alpha1.RestorePhaseFailed
+			r.Recorder.Event(nsRestore, corev1.EventTypeWarning, "RestoreConflict",
+				fmt.Sprintf("ResticRestore %s already exists and belongs to another resource", restore.Name))
+			return nil
+		}
+		return fmt.Errorf("failed to create ResticRestore %s: %w", restore.Name, err)
+	}
+	entry.Phase = backupv1alpha1.RestorePhasePending
+	return nil
+}
+
+// fail marks a namespace restore as failed before any restore was created.
+func (r *NamespaceRestoreReconciler) fail(ctx context.Context, nsRestore *backupv1alpha1.NamespaceRestore, reason, message string) (ctrl.Result, error) {
+	now := metav1.NewTime(time.Now())
+	nsRestore.Status.Phase = backupv1alpha1.NamespaceRestorePhaseFailed
+	nsRestore.Status.CompletionTime = &now
+	r.setCondition(nsRestore, conditions.NotReadyCondition(reason, message))
+	r.Recorder.Event(nsRestore, corev1.EventTypeWarning, reason, message)
+	return ctrl.Result{}, r.Status().Update(ctx, nsRestore)
+}
+
+// selectBackups returns the ResticBackups with a PVC source matching the selector, sorted
+// by name, and the names of the matching ResticBackups that can't be restored: those
+// without PVC source and those backing up the same PVC as a ResticBackup selected before.
+func (r *NamespaceRestoreReconciler) selectBackups(ctx context.Context, namespace string, selector labels.Selector) ([]backupv1alpha1.ResticBackup, []string, error) {
+	backupList := &backupv1alpha1.ResticBackupList{}
+	if err := r.List(ctx, backupList,
+		client.InNamespace(namespace),
+		client.MatchingLabelsSelector{Selector: selector},
+	); err != nil {
+		return nil, nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	sort.Slice(backupList.Items, func(i, j int) bool {
+		return backupList.Items[i].Name < backupList.Items[j].Name
+	})
+
+	var backups []backupv1alpha1.ResticBackup
+	var skipped []string
+	claims := map[string]bool{}
+	for _, backup := range backupList.Items {
+		// Two restores into the same PVC would run at the same time
+		if backup.Spec.Source.PVC == nil || claims[backup.Spec.Source.PVC.ClaimName] {
+			skipped = append(skipped, backup.Name)
+			continue
+		}
+		claims[backup.Spec.Source.PVC.ClaimName] = true
+		backups = append(backups, backup)
+	}
+	return backups, skipped, nil
+}
+
+// ensurePVC creates the target PVC from the PVC template if it does not exist. It returns
+// whether the PVC was created. Created PVCs are not owned by the NamespaceRestore, so that
+// the restored data is kept when the NamespaceRestore is deleted.
+func (r *NamespaceRestoreReconciler) ensurePVC(ctx context.Context, nsRestore *backupv1alpha1.NamespaceRestore, name string) (bool, error) {
+	existing := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: nsRestore.Namespace}, existing)
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	pvc, err := buildNamespaceRestorePVC(nsRestore, name)
+	if err != nil {
+		return false, apierrors.NewBadRequest(err.Error())
+	}
+	if err := r.Create(ctx, pvc); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	r.Recorder.Event(nsRestore, corev1.EventTypeNormal, "PVCCreated", fmt.Sprintf("Created PVC %s", name))
+	return true, nil
+}
+
+// buildNamespaceRestorePVC builds a target PVC from the PVC template of a NamespaceRestore.
+func buildNamespaceRestorePVC(nsRestore *backupv1alpha1.NamespaceRestore, name string) (*corev1.PersistentVolumeClaim, error) {
+	size := defaultNamespaceRestorePVCSize
+	accessModes := []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	var storageClassName *string
+	if template := nsRestore.Spec.PVCTemplate; template != nil {
+		if template.Size != "" {
+			size = template.Size
+		}
+		if len(template.AccessModes) > 0 {
+			accessModes = make([]corev1.PersistentVolumeAccessMode, 0, len(template.AccessModes))
+			for _, mode := range template.AccessModes {
+				accessModes = append(accessModes, corev1.PersistentVolumeAccessMode(mode))
+			}
+		}
+		if template.StorageClassName != "" {
+			storageClassName = &template.StorageClassName
+		}
+	}
+
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PVC size %q: %w", size, err)
+	}
+
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: nsRestore.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				namespaceRestoreLabel:          nsRestore.Name,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			StorageClassName: storageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: quantity},
+			},
+		},
+	}, nil
+}
+
+// buildNamespaceRestoreChild builds the ResticRestore of a namespace restore entry. Without
+// explicit snapshot selector, the latest snapshot of the backup's hostname is restored.
+func buildNamespaceRestoreChild(nsRestore *backupv1alpha1.NamespaceRestore, entry *backupv1alpha1.NamespaceRestoreEntry, backup *backupv1alpha1.ResticBackup) *backupv1alpha1.ResticRestore {
+	selector := &backupv1alpha1.SnapshotSelector{Latest: true}
+	if nsRestore.Spec.SnapshotSelector != nil {
+		selector = nsRestore.Spec.SnapshotSelector.DeepCopy()
+	}
+	// Backups usually share a repository, so restrict the snapshots to the backup
+	if selector.Hostname == "" {
+		if hostname, err := renderHostname(backup); err == nil {
+			selector.Hostname = hostname
+		}
+	}
+
+	restore := &backupv1alpha1.ResticRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      entry.Restore,
+			Namespace: nsRestore.Namespace,
+			Labels: map[string]string{
+				namespaceRestoreLabel: nsRestore.Name,
+			},
+		},
+		Spec: backupv1alpha1.ResticRestoreSpec{
+			BackupRef: backupv1alpha1.CrossNamespaceObjectReference{
+				Name:      entry.Backup,
+				Namespace: namespaceRestoreSource(nsRestore),
+			},
+			SnapshotSelector: selector,
+			Target: backupv1alpha1.RestoreTarget{
+				PVC: &backupv1alpha1.PVCTarget{ClaimName: entry.PVC},
+			},
+		},
+	}
+	if nsRestore.Spec.Options != nil {
+		restore.Spec.Options = nsRestore.Spec.Options.DeepCopy()
+	}
+	if nsRestore.Spec.JobConfig != nil {
+		restore.Spec.JobConfig = nsRestore.Spec.JobConfig.DeepCopy()
+	}
+	return restore
+}
+
+// namespaceRestoreSource returns the namespace of the ResticBackups to restore.
+func namespaceRestoreSource(nsRestore *backupv1alpha1.NamespaceRestore) string {
+	if nsRestore.Spec.SourceNamespace != "" {
+		return nsRestore.Spec.SourceNamespace
+	}
+	return nsRestore.Namespace
+}
+
+// namespaceRestoreChildName returns the name of the ResticRestore created for a backup.
+// Long names are shortened and suffixed with a hash to stay unique.
+func namespaceRestoreChildName(nsRestoreName, backupName string) string {
+	name := nsRestoreName + "-" + backupName
+	if len(name) <= maxRestoreNameLength {
+		return name
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name))
+	suffix := fmt.Sprintf("-%08x", hash.Sum32())
+	return name[:maxRestoreNameLength-len(suffix)] + suffix
+}
+
+// countRestorePhases returns the number of completed and failed restore entries.
+func countRestorePhases(entries []backupv1alpha1.NamespaceRestoreEntry) (int32, int32) {
+	var completed, failed int32
+	for _, entry := range entries {
+		switch entry.Phase {
+		case backupv1alpha1.RestorePhaseCompleted:
+			completed++
+		case backupv1alpha1.RestorePhaseFailed:
+			failed++
+		}
+	}
+	return completed, failed
+}
+
+// isFinishedRestorePhase reports whether a restore reached a final phase.
+func isFinishedRestorePhase(phase backupv1alpha1.RestorePhase) bool {
+	return phase == backupv1alpha1.RestorePhaseCompleted || phase == backupv1alpha1.RestorePhaseFailed
+}
+
+func (r *NamespaceRestoreReconciler) setCondition(nsRestore *backupv1alpha1.NamespaceRestore, condition metav1.Condition) {
+	conditions.SetCondition(&nsRestore.Status.Conditions, condition)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NamespaceRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&backupv1alpha1.NamespaceRestore{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Owns(&backupv1alpha1.ResticRestore{}).
+		Complete(r)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+func pvcBackup(name, claimName string) *backupv1alpha1.ResticBackup {
+	return &backupv1alpha1.ResticBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "media", Labels: map[string]string{"tier": "data"}},
+		Spec: backupv1alpha1.ResticBackupSpec{
+			Source: backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: claimName}},
+		},
+	}
+}
+
+var _ = Describe("NamespaceRestore Controller", func() {
+	var (
+		reconciler *NamespaceRestoreReconciler
+		nsRestore  *backupv1alpha1.NamespaceRestore
+	)
+
+	BeforeEach(func() {
+		nsRestore = &backupv1alpha1.NamespaceRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "dr", Namespace: "media"},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		reconciler = &NamespaceRestoreReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(append(objects, nsRestore)...).
+				WithStatusSubresource(&backupv1alpha1.NamespaceRestore{}, &backupv1alpha1.ResticRestore{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(20),
+		}
+	}
+
+	reconcile := func() *backupv1alpha1.NamespaceRestore {
+		key := types.NamespacedName{Name: nsRestore.Name, Namespace: nsRestore.Namespace}
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		current := &backupv1alpha1.NamespaceRestore{}
+		Expect(reconciler.Get(context.Background(), key, current)).To(Succeed())
+		return current
+	}
+
+	setRestorePhase := func(name string, phase backupv1alpha1.RestorePhase) {
+		restore := &backupv1alpha1.ResticRestore{}
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "media"}, restore)).To(Succeed())
+		restore.Status.Phase = phase
+		Expect(reconciler.Status().Update(context.Background(), restore)).To(Succeed())
+	}
+
+	It("should create a restore and missing PVC for each backup with PVC source", func() {
+		existing := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "db-data", Namespace: "media"}}
+		custom := &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Source: backupv1alpha1.BackupSource{CustomSource: &backupv1alpha1.CustomSource{}},
+			},
+		}
+		newReconciler(pvcBackup("db", "db-data"), pvcBackup("files", "files-data"), custom, existing)
+
+		current := reconcile()
+		Expect(current.Status.Phase).To(Equal(backupv1alpha1.NamespaceRestorePhaseInProgress))
+		Expect(current.Status.Skipped).To(ConsistOf("custom"))
+		Expect(current.Status.Restores).To(HaveExactElements(
+			backupv1alpha1.NamespaceRestoreEntry{Backup: "db", Restore: "dr-db", PVC: "db-data", Phase: backupv1alpha1.RestorePhasePending},
+			backupv1alpha1.NamespaceRestoreEntry{Backup: "files", Restore: "dr-files", PVC: "files-data", PVCCreated: true, Phase: backupv1alpha1.RestorePhasePending},
+		))
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "files-data", Namespace: "media"}, pvc)).To(Succeed())
+		Expect(pvc.OwnerReferences).To(BeEmpty())
+		Expect(pvc.Spec.Resources.Requests[corev1.ResourceStorage]).To(Equal(resource.MustParse("10Gi")))
+
+		restore := &backupv1alpha1.ResticRestore{}
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "dr-db", Namespace: "media"}, restore)).To(Succeed())
+		Expect(restore.Spec.BackupRef.Name).To(Equal("db"))
+		Expect(restore.Spec.Target.PVC.ClaimName).To(Equal("db-data"))
+		Expect(restore.Spec.SnapshotSelector.Hostname).To(Equal("db"))
+		Expect(restore.OwnerReferences).To(HaveLen(1))
+	})
+
+	It("should only restore backups matching the backup selector", func() {
+		nsRestore.Spec.BackupSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "data"}}
+		other := pvcBackup("cache", "cache-data")
+		other.Labels = nil
+		newReconciler(pvcBackup("db", "db-data"), other)
+
+		current := reconcile()
+		Expect(current.Status.Restores).To(ConsistOf(HaveField("Backup", "db")))
+	})
+
+	It("should fail without backups to restore", func() {
+		newReconciler()
+
+		current := reconcile()
+		Expect(current.Status.Phase).To(Equal(backupv1alpha1.NamespaceRestorePhaseFailed))
+		Expect(current.Status.Conditions).To(ContainElement(HaveField("Reason", "NoBackupsFound")))
+	})
+
+	It("should restore a PVC backed up by several backups only once", func() {
+		newReconciler(pvcBackup("db", "db-data"), pvcBackup("db-hourly", "db-data"))
+
+		current := reconcile()
+		Expect(current.Status.Restores).To(ConsistOf(HaveField("Backup", "db")))
+		Expect(current.Status.Skipped).To(ConsistOf("db-hourly"))
+	})
+
+	It("should require a grant to restore the backups of another namespace", func() {
+		nsRestore.Namespace = "restore"
+		nsRestore.Spec.SourceNamespace = "media"
+		newReconciler(pvcBackup("db", "db-data"))
+
+		current := reconcile()
+		Expect(current.Status.Phase).To(Equal(backupv1alpha1.NamespaceRestorePhaseFailed))
+		Expect(current.Status.Restores).To(BeEmpty())
+		Expect(current.Status.Conditions).To(ContainElement(HaveField("Reason", reasonReferenceDenied)))
+		Expect(current.Status.Conditions).NotTo(ContainElement(HaveField("Message", ContainSubstring("db"))))
+
+		nsRestore.Status = backupv1alpha1.NamespaceRestoreStatus{}
+		newReconciler(pvcBackup("db", "db-data"), &backupv1alpha1.ResticReferenceGrant{
+			ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "media"},
+			Spec: backupv1alpha1.ResticReferenceGrantSpec{
+				From: []backupv1alpha1.ReferenceGrantFrom{{Kind: "ResticRestore", Namespace: "restore"}},
+				To:   []backupv1alpha1.ReferenceGrantTo{{Kind: "ResticBackup"}},
+			},
+		})
+		current = reconcile()
+		Expect(current.Status.Restores).To(ConsistOf(HaveField("Backup", "db")))
+	})
+
+	It("should aggregate the phases of the restores", func() {
+		newReconciler(pvcBackup("db", "db-data"), pvcBackup("files", "files-data"))
+		reconcile()
+
+		setRestorePhase("dr-db", backupv1alpha1.RestorePhaseCompleted)
+		current := reconcile()
+		Expect(current.Status.Phase).To(Equal(backupv1alpha1.NamespaceRestorePhaseInProgress))
+		Expect(current.Status.Completed).To(Equal(int32(1)))
+
+		setRestorePhase("dr-files", backupv1alpha1.RestorePhaseFailed)
+		current = reconcile()
+		Expect(current.Status.Phase).To(Equal(backupv1alpha1.NamespaceRestorePhasePartiallyFailed))
+		Expect(current.Status.Completed).To(Equal(int32(1)))
+		Expect(current.Status.Failed).To(Equal(int32(1)))
+		Expect(current.Status.CompletionTime).NotTo(BeNil())
+	})
+
+	It("should shorten long restore names", func() {
+		name := namespaceRestoreChildName("disaster-recovery-2024", strings.Repeat("backup", 10))
+		Expect(len(name)).To(Equal(maxRestoreNameLength))
+		Expect(name).NotTo(Equal(namespaceRestoreChildName("disaster-recovery-2024", strings.Repeat("backup", 9)+"x")))
+		Expect(namespaceRestoreChildName("dr", "db")).To(Equal("dr-db"))
+	})
+})
@@ -0,0 +1,222 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/madic-creates/restic-backup-operator/internal/version"
+)
+
+const (
+	reconcileTotalMetric     = "controller_runtime_reconcile_total"
+	reconcileErrorsMetric    = "controller_runtime_reconcile_errors_total"
+	operatorOverloadedMetric = "restic_operator_overloaded"
+
+	defaultStatusReportInterval = time.Minute
+
+	controllerHealthy    = "Healthy"
+	controllerOverloaded = "Overloaded"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch
+
+// controllerHealth is the health of a single controller published in the status ConfigMap.
+type controllerHealth struct {
+	Health     string `json:"health"`
+	Reconciles int64  `json:"reconciles"`
+	Errors     int64  `json:"errors"`
+}
+
+// RecordOperatorInfo sets the restic_operator_info metric from the build info and the
+// enabled feature gates.
+func RecordOperatorInfo(featureGates map[string]bool) {
+	operatorInfo.Reset()
+	operatorInfo.WithLabelValues(version.Version, version.Commit(), strings.Join(enabledFeatureGates(featureGates), ",")).Set(1)
+}
+
+// OperatorStatusReporter maintains a ConfigMap with the operator build info, the
+// enabled feature gates and the health of each controller, so fleet management
+// tooling can audit which clusters run which operator capabilities.
+type OperatorStatusReporter struct {
+	client.Client
+	// Gatherer provides the controller-runtime reconcile metrics.
+	Gatherer prometheus.Gatherer
+	// Namespace and Name of the ConfigMap. The reporter does nothing if either is unset.
+	Namespace string
+	Name      string
+	// FeatureGates are the optional operator features and whether they are enabled.
+	FeatureGates map[string]bool
+	// Interval between updates. Defaults to 1m.
+	Interval time.Duration
+}
+
+// Start updates the ConfigMap until the context is cancelled. It implements manager.Runnable.
+func (r *OperatorStatusReporter) Start(ctx context.Context) error {
+	if r.Namespace == "" || r.Name == "" {
+		return nil
+	}
+
+	interval := r.Interval
+	if interval <= 0 {
+		interval = defaultStatusReportInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.update(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to update operator status ConfigMap")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection ensures only the leader, whose controllers are running, reports their health.
+func (r *OperatorStatusReporter) NeedLeaderElection() bool {
+	return true
+}
+
+// update writes the current operator status to the ConfigMap.
+func (r *OperatorStatusReporter) update(ctx context.Context) error {
+	data, err := r.statusData()
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: r.Name, Namespace: r.Namespace}}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+		if configMap.Labels == nil {
+			configMap.Labels = map[string]string{}
+		}
+		configMap.Labels["app.kubernetes.io/name"] = "restic-backup-operator"
+		configMap.Labels["app.kubernetes.io/managed-by"] = "restic-backup-operator"
+		configMap.Data = data
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s/%s: %w", r.Namespace, r.Name, err)
+	}
+	return nil
+}
+
+// statusData returns the data of the status ConfigMap.
+func (r *OperatorStatusReporter) statusData() (map[string]string, error) {
+	health, err := r.controllerHealth()
+	if err != nil {
+		return nil, err
+	}
+	controllers, err := json.Marshal(health)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode controller health: %w", err)
+	}
+
+	gates := make([]string, 0, len(r.FeatureGates))
+	for name, enabled := range r.FeatureGates {
+		gates = append(gates, fmt.Sprintf("%s=%t", name, enabled))
+	}
+	slices.Sort(gates)
+
+	return map[string]string{
+		"version":      version.Version,
+		"gitCommit":    version.Commit(),
+		"featureGates": strings.Join(gates, ","),
+		"controllers":  string(controllers),
+		"lastUpdated":  time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// controllerHealth reads the reconcile counts and overload state of each controller.
+func (r *OperatorStatusReporter) controllerHealth() (map[string]controllerHealth, error) {
+	families, err := r.Gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	health := map[string]controllerHealth{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			name := metricLabel(metric, "controller")
+			if name == "" {
+				continue
+			}
+
+			h := health[name]
+			switch family.GetName() {
+			case reconcileTotalMetric:
+				h.Reconciles += int64(metric.GetCounter().GetValue())
+			case reconcileErrorsMetric:
+				h.Errors = int64(metric.GetCounter().GetValue())
+			case operatorOverloadedMetric:
+				if metric.GetGauge().GetValue() > 0 {
+					h.Health = controllerOverloaded
+				}
+			default:
+				continue
+			}
+			health[name] = h
+		}
+	}
+
+	for name, h := range health {
+		if h.Health == "" {
+			h.Health = controllerHealthy
+			health[name] = h
+		}
+	}
+	return health, nil
+}
+
+// enabledFeatureGates returns the sorted names of the enabled feature gates.
+func enabledFeatureGates(featureGates map[string]bool) []string {
+	var enabled []string
+	for name, on := range featureGates {
+		if on {
+			enabled = append(enabled, name)
+		}
+	}
+	slices.Sort(enabled)
+	return enabled
+}
+
+func metricLabel(metric *dto.Metric, name string) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
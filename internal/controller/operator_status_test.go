@@ -0,0 +1,108 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/madic-creates/restic-backup-operator/internal/version"
+)
+
+var _ = Describe("OperatorStatusReporter", func() {
+	var (
+		registry   *prometheus.Registry
+		reconciles *prometheus.CounterVec
+		errors     *prometheus.CounterVec
+		overloaded *prometheus.GaugeVec
+		reporter   *OperatorStatusReporter
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+		reconciles = prometheus.NewCounterVec(prometheus.CounterOpts{Name: reconcileTotalMetric}, []string{"controller", "result"})
+		errors = prometheus.NewCounterVec(prometheus.CounterOpts{Name: reconcileErrorsMetric}, []string{"controller"})
+		overloaded = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: operatorOverloadedMetric}, []string{"controller"})
+		registry.MustRegister(reconciles, errors, overloaded)
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		reporter = &OperatorStatusReporter{
+			Client:       fake.NewClientBuilder().WithScheme(scheme).Build(),
+			Gatherer:     registry,
+			Namespace:    "restic-system",
+			Name:         "restic-backup-operator-status",
+			FeatureGates: map[string]bool{"LeaderElection": true, "ImageMirror": false},
+		}
+	})
+
+	It("should publish build info, feature gates and controller health", func() {
+		reconciles.WithLabelValues("resticbackup", "success").Add(5)
+		reconciles.WithLabelValues("resticbackup", "error").Add(2)
+		errors.WithLabelValues("resticbackup").Add(2)
+		reconciles.WithLabelValues("resticrestore", "success").Add(1)
+		overloaded.WithLabelValues("resticrestore").Set(1)
+
+		Expect(reporter.update(context.Background())).To(Succeed())
+
+		configMap := &corev1.ConfigMap{}
+		Expect(reporter.Get(context.Background(), types.NamespacedName{
+			Namespace: "restic-system", Name: "restic-backup-operator-status",
+		}, configMap)).To(Succeed())
+		Expect(configMap.Data).To(HaveKeyWithValue("version", version.Version))
+		Expect(configMap.Data).To(HaveKeyWithValue("gitCommit", version.Commit()))
+		Expect(configMap.Data).To(HaveKeyWithValue("featureGates", "ImageMirror=false,LeaderElection=true"))
+		Expect(configMap.Data).To(HaveKey("lastUpdated"))
+
+		var health map[string]controllerHealth
+		Expect(json.Unmarshal([]byte(configMap.Data["controllers"]), &health)).To(Succeed())
+		Expect(health).To(Equal(map[string]controllerHealth{
+			"resticbackup":  {Health: controllerHealthy, Reconciles: 7, Errors: 2},
+			"resticrestore": {Health: controllerOverloaded, Reconciles: 1},
+		}))
+	})
+
+	It("should update an existing ConfigMap", func() {
+		Expect(reporter.update(context.Background())).To(Succeed())
+		reporter.FeatureGates = map[string]bool{"ImageMirror": true}
+		Expect(reporter.update(context.Background())).To(Succeed())
+
+		configMap := &corev1.ConfigMap{}
+		Expect(reporter.Get(context.Background(), types.NamespacedName{
+			Namespace: "restic-system", Name: "restic-backup-operator-status",
+		}, configMap)).To(Succeed())
+		Expect(configMap.Data).To(HaveKeyWithValue("featureGates", "ImageMirror=true"))
+	})
+
+	It("should export the enabled feature gates in the info metric", func() {
+		RecordOperatorInfo(map[string]bool{"SecureMetrics": true, "HTTP2": false, "LeaderElection": true})
+
+		Expect(testutil.ToFloat64(operatorInfo.WithLabelValues(
+			version.Version, version.Commit(), "LeaderElection,SecureMetrics"))).To(Equal(1.0))
+		Expect(testutil.CollectAndCount(operatorInfo)).To(Equal(1))
+	})
+})
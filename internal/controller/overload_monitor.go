@@ -0,0 +1,203 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	workqueueDepthMetric   = "workqueue_depth"
+	workqueueLatencyMetric = "workqueue_queue_duration_seconds"
+
+	defaultOverloadCheckInterval = 30 * time.Second
+)
+
+// OverloadMonitor watches the controller workqueues and reports when the operator
+// can't keep up with the number of resources, so admins know when to raise
+// MaxConcurrentReconciles or scale the operator.
+type OverloadMonitor struct {
+	// Gatherer provides the controller-runtime workqueue metrics.
+	Gatherer prometheus.Gatherer
+	// Recorder emits OperatorOverloaded events on the operator pod.
+	Recorder record.EventRecorder
+	// PodName and PodNamespace identify the operator pod. Events are skipped if unset.
+	PodName      string
+	PodNamespace string
+	// DepthThreshold is the queue depth above which a controller is overloaded. Zero disables the check.
+	DepthThreshold int
+	// LatencyThreshold is the average queue wait time above which a controller is overloaded. Zero disables the check.
+	LatencyThreshold time.Duration
+	// Interval between checks. Defaults to 30s.
+	Interval time.Duration
+
+	// overloaded tracks the last reported state per controller
+	overloaded map[string]bool
+	// lastLatency holds the queue duration histogram sum and count of the previous check
+	lastLatency map[string][2]float64
+}
+
+// workqueueStats holds the workqueue state of a single controller.
+type workqueueStats struct {
+	depth          float64
+	averageLatency time.Duration
+}
+
+// Start runs the monitor until the context is cancelled. It implements manager.Runnable.
+func (m *OverloadMonitor) Start(ctx context.Context) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = defaultOverloadCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := m.check(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to check workqueue metrics")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection ensures only the leader, which processes the queues, reports overload.
+func (m *OverloadMonitor) NeedLeaderElection() bool {
+	return true
+}
+
+func (m *OverloadMonitor) check(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("overload-monitor")
+
+	stats, err := m.collect()
+	if err != nil {
+		return err
+	}
+
+	if m.overloaded == nil {
+		m.overloaded = map[string]bool{}
+	}
+
+	for name, s := range stats {
+		reason := m.overloadReason(s)
+		overloaded := reason != ""
+
+		value := 0.0
+		if overloaded {
+			value = 1
+		}
+		operatorOverloaded.WithLabelValues(name).Set(value)
+
+		if overloaded == m.overloaded[name] {
+			continue
+		}
+		m.overloaded[name] = overloaded
+
+		if !overloaded {
+			log.Info("Controller recovered from overload", "controller", name)
+			continue
+		}
+
+		log.Info("Controller is overloaded", "controller", name, "reason", reason)
+		if m.Recorder != nil && m.PodName != "" && m.PodNamespace != "" {
+			m.Recorder.Event(&corev1.ObjectReference{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       m.PodName,
+				Namespace:  m.PodNamespace,
+			}, corev1.EventTypeWarning, "OperatorOverloaded",
+				fmt.Sprintf("Controller %s is overloaded: %s. Consider raising its max concurrent reconciles", name, reason))
+		}
+	}
+
+	return nil
+}
+
+// overloadReason returns why a controller is overloaded, or an empty string if it isn't.
+func (m *OverloadMonitor) overloadReason(s workqueueStats) string {
+	if m.DepthThreshold > 0 && s.depth > float64(m.DepthThreshold) {
+		return fmt.Sprintf("queue depth %.0f exceeds %d", s.depth, m.DepthThreshold)
+	}
+	if m.LatencyThreshold > 0 && s.averageLatency > m.LatencyThreshold {
+		return fmt.Sprintf("average queue latency %s exceeds %s", s.averageLatency.Round(time.Millisecond), m.LatencyThreshold)
+	}
+	return ""
+}
+
+// collect reads the workqueue metrics per controller. The average latency covers
+// the items dequeued since the previous call.
+func (m *OverloadMonitor) collect() (map[string]workqueueStats, error) {
+	families, err := m.Gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	if m.lastLatency == nil {
+		m.lastLatency = map[string][2]float64{}
+	}
+
+	stats := map[string]workqueueStats{}
+	for _, family := range families {
+		switch family.GetName() {
+		case workqueueDepthMetric:
+			for _, metric := range family.GetMetric() {
+				name := workqueueName(metric)
+				s := stats[name]
+				s.depth = metric.GetGauge().GetValue()
+				stats[name] = s
+			}
+		case workqueueLatencyMetric:
+			for _, metric := range family.GetMetric() {
+				name := workqueueName(metric)
+				sum := metric.GetHistogram().GetSampleSum()
+				count := float64(metric.GetHistogram().GetSampleCount())
+
+				last := m.lastLatency[name]
+				m.lastLatency[name] = [2]float64{sum, count}
+
+				s := stats[name]
+				if count > last[1] {
+					s.averageLatency = time.Duration((sum - last[0]) / (count - last[1]) * float64(time.Second))
+				}
+				stats[name] = s
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+func workqueueName(metric *dto.Metric) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == "name" {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("OverloadMonitor", func() {
+	var (
+		registry *prometheus.Registry
+		depth    *prometheus.GaugeVec
+		latency  *prometheus.HistogramVec
+		recorder *record.FakeRecorder
+		monitor  *OverloadMonitor
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+		depth = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: workqueueDepthMetric}, []string{"name"})
+		latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: workqueueLatencyMetric}, []string{"name"})
+		registry.MustRegister(depth, latency)
+
+		recorder = record.NewFakeRecorder(10)
+		monitor = &OverloadMonitor{
+			Gatherer:         registry,
+			Recorder:         recorder,
+			PodName:          "operator",
+			PodNamespace:     "restic-system",
+			DepthThreshold:   10,
+			LatencyThreshold: time.Second,
+		}
+	})
+
+	It("should not report healthy controllers", func() {
+		depth.WithLabelValues("resticbackup").Set(2)
+		latency.WithLabelValues("resticbackup").Observe(0.1)
+
+		Expect(monitor.check(context.Background())).To(Succeed())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should emit a single event when the queue depth exceeds the threshold", func() {
+		depth.WithLabelValues("resticbackup").Set(50)
+
+		Expect(monitor.check(context.Background())).To(Succeed())
+		Expect(monitor.check(context.Background())).To(Succeed())
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(<-recorder.Events).To(ContainSubstring("OperatorOverloaded"))
+	})
+
+	It("should use the latency of items dequeued since the last check", func() {
+		latency.WithLabelValues("resticrestore").Observe(5)
+		Expect(monitor.check(context.Background())).To(Succeed())
+		Expect(monitor.overloaded["resticrestore"]).To(BeTrue())
+
+		latency.WithLabelValues("resticrestore").Observe(0.1)
+		Expect(monitor.check(context.Background())).To(Succeed())
+		Expect(monitor.overloaded["resticrestore"]).To(BeFalse())
+	})
+})
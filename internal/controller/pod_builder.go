@@ -0,0 +1,235 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// applyJobConfiguration applies the pod-level scheduling and networking settings of a
+// JobConfiguration to a pod spec. It is shared by all controllers that create pods so
+// that all generated pods honor the same settings. Tolerations for dedicated GPU and
+// spot node taints are dropped unless explicitly allowed.
+func applyJobConfiguration(podSpec *corev1.PodSpec, jobConfig *backupv1alpha1.JobConfiguration) {
+	if jobConfig == nil {
+		return
+	}
+
+	// Add node selector
+	if jobConfig.NodeSelector != nil {
+		podSpec.NodeSelector = jobConfig.NodeSelector
+	}
+
+	// Add tolerations, dropping those for dedicated GPU/spot nodes unless allowed
+	guardedKeys := guardedTaintKeys(jobConfig)
+	if jobConfig.Tolerations != nil {
+		podSpec.Tolerations = filterTolerations(jobConfig.Tolerations, guardedKeys)
+	}
+
+	// Add affinity
+	if jobConfig.Affinity != nil {
+		podSpec.Affinity = jobConfig.Affinity
+	}
+
+	// A toleration without key tolerates every taint, and node affinity can't select
+	// on taints, so keep such pods away from the labels of GPU/spot nodes instead
+	if hasWildcardToleration(podSpec.Tolerations) {
+		if requirements := guardedNodeLabels(jobConfig); len(requirements) > 0 {
+			podSpec.Affinity = requireNodeLabels(podSpec.Affinity, requirements)
+		}
+	}
+
+	// Give restic time to release its lock when the pod is terminated
+	if jobConfig.TerminationGracePeriodSeconds != nil {
+		podSpec.TerminationGracePeriodSeconds = jobConfig.TerminationGracePeriodSeconds
+	}
+
+	// Add runtime class
+	if jobConfig.RuntimeClassName != nil {
+		podSpec.RuntimeClassName = jobConfig.RuntimeClassName
+	}
+
+	// Add service account
+	if jobConfig.ServiceAccountName != "" {
+		podSpec.ServiceAccountName = jobConfig.ServiceAccountName
+	}
+
+	// Add DNS settings for repositories only resolvable with custom DNS
+	if jobConfig.DNSPolicy != "" {
+		podSpec.DNSPolicy = jobConfig.DNSPolicy
+	}
+	if jobConfig.DNSConfig != nil {
+		podSpec.DNSConfig = jobConfig.DNSConfig
+	}
+
+	// Add /etc/hosts entries
+	if jobConfig.HostAliases != nil {
+		podSpec.HostAliases = jobConfig.HostAliases
+	}
+
+	// Add sidecars as native sidecars, which are stopped once the main containers finished.
+	// They start before other init containers, e.g. to provide mesh egress for a dump.
+	if len(jobConfig.Sidecars) > 0 {
+		initContainers := make([]corev1.Container, 0, len(jobConfig.Sidecars)+len(podSpec.InitContainers))
+		for _, sidecar := range jobConfig.Sidecars {
+			sidecar = *sidecar.DeepCopy()
+			restartPolicy := corev1.ContainerRestartPolicyAlways
+			sidecar.RestartPolicy = &restartPolicy
+			initContainers = append(initContainers, sidecar)
+		}
+		podSpec.InitContainers = append(initContainers, podSpec.InitContainers...)
+	}
+}
+
+// validateSidecars checks that no sidecar uses the name of a container the operator
+// adds to the job pods or of another sidecar, the job could never be created.
+func validateSidecars(jobConfig *backupv1alpha1.JobConfiguration) error {
+	if name := jobConfig.SidecarNameConflict(); name != "" {
+		return fmt.Errorf("sidecar name %q is used by another container of the job pods", name)
+	}
+	return nil
+}
+
+// nativeMeshSidecarAnnotations request service meshes to inject their proxy as a
+// native sidecar. An injected regular proxy container keeps running after restic
+// finished and the job would never complete.
+var nativeMeshSidecarAnnotations = map[string]string{
+	"sidecar.istio.io/nativeSidecar":                      "true",
+	"config.alpha.linkerd.io/proxy-enable-native-sidecar": "true",
+}
+
+// applyPodMetadata applies the pod template metadata settings of a JobConfiguration.
+func applyPodMetadata(meta *metav1.ObjectMeta, jobConfig *backupv1alpha1.JobConfiguration) {
+	if jobConfig == nil || !jobConfig.NativeMeshSidecars {
+		return
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	for key, value := range nativeMeshSidecarAnnotations {
+		meta.Annotations[key] = value
+	}
+}
+
+// gpuTaintKeys are taint keys commonly used for dedicated GPU nodes.
+var gpuTaintKeys = []string{
+	"nvidia.com/gpu",
+	"amd.com/gpu",
+}
+
+// spotTaintKeys are taint keys commonly used for spot/preemptible nodes.
+var spotTaintKeys = []string{
+	"cloud.google.com/gke-spot",
+	"cloud.google.com/gke-preemptible",
+	"kubernetes.azure.com/scalesetpriority",
+}
+
+// gpuNodeLabels exclude nodes by labels commonly set on GPU nodes.
+var gpuNodeLabels = []corev1.NodeSelectorRequirement{
+	{Key: "nvidia.com/gpu.present", Operator: corev1.NodeSelectorOpDoesNotExist},
+	{Key: "cloud.google.com/gke-accelerator", Operator: corev1.NodeSelectorOpDoesNotExist},
+}
+
+// spotNodeLabels exclude nodes by labels commonly set on spot/preemptible nodes.
+var spotNodeLabels = []corev1.NodeSelectorRequirement{
+	{Key: "cloud.google.com/gke-spot", Operator: corev1.NodeSelectorOpDoesNotExist},
+	{Key: "cloud.google.com/gke-preemptible", Operator: corev1.NodeSelectorOpDoesNotExist},
+	{Key: "kubernetes.azure.com/scalesetpriority", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"spot"}},
+	{Key: "eks.amazonaws.com/capacityType", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"SPOT"}},
+	{Key: "karpenter.sh/capacity-type", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"spot"}},
+}
+
+// guardedNodeLabels returns the node label requirements that keep pods off GPU/spot nodes.
+func guardedNodeLabels(jobConfig *backupv1alpha1.JobConfiguration) []corev1.NodeSelectorRequirement {
+	var requirements []corev1.NodeSelectorRequirement
+	if !jobConfig.AllowGPUNodes {
+		requirements = append(requirements, gpuNodeLabels...)
+	}
+	if !jobConfig.AllowSpotNodes {
+		requirements = append(requirements, spotNodeLabels...)
+	}
+	// Don't share the values of the package-level requirements with pod specs
+	for i := range requirements {
+		requirements[i] = *requirements[i].DeepCopy()
+	}
+	return requirements
+}
+
+// guardedTaintKeys returns the GPU/spot taint keys that pods must not tolerate.
+func guardedTaintKeys(jobConfig *backupv1alpha1.JobConfiguration) []string {
+	var keys []string
+	if !jobConfig.AllowGPUNodes {
+		keys = append(keys, gpuTaintKeys...)
+	}
+	if !jobConfig.AllowSpotNodes {
+		keys = append(keys, spotTaintKeys...)
+	}
+	return keys
+}
+
+// filterTolerations returns the tolerations without those for the guarded taint keys.
+func filterTolerations(tolerations []corev1.Toleration, guardedKeys []string) []corev1.Toleration {
+	filtered := make([]corev1.Toleration, 0, len(tolerations))
+	for _, toleration := range tolerations {
+		if slices.Contains(guardedKeys, toleration.Key) {
+			continue
+		}
+		filtered = append(filtered, toleration)
+	}
+	return filtered
+}
+
+// hasWildcardToleration reports whether a toleration matches all taint keys.
+func hasWildcardToleration(tolerations []corev1.Toleration) bool {
+	for _, toleration := range tolerations {
+		if toleration.Key == "" && toleration.Operator == corev1.TolerationOpExists {
+			return true
+		}
+	}
+	return false
+}
+
+// requireNodeLabels returns a copy of the affinity that additionally requires the
+// node label requirements. They select on node labels, not on taints.
+func requireNodeLabels(affinity *corev1.Affinity, requirements []corev1.NodeSelectorRequirement) *corev1.Affinity {
+	result := affinity.DeepCopy()
+	if result == nil {
+		result = &corev1.Affinity{}
+	}
+	if result.NodeAffinity == nil {
+		result.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	required := result.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if required == nil || len(required.NodeSelectorTerms) == 0 {
+		result.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: requirements}},
+		}
+		return result
+	}
+
+	// Node selector terms are ORed, so every term needs the requirements
+	for i := range required.NodeSelectorTerms {
+		required.NodeSelectorTerms[i].MatchExpressions = append(required.NodeSelectorTerms[i].MatchExpressions, requirements...)
+	}
+	return result
+}
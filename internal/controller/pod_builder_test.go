@@ -0,0 +1,181 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Pod builder", func() {
+	Context("applyJobConfiguration helper function", func() {
+		It("should leave the pod spec untouched without job configuration", func() {
+			podSpec := corev1.PodSpec{}
+			applyJobConfiguration(&podSpec, nil)
+			Expect(podSpec).To(Equal(corev1.PodSpec{}))
+		})
+
+		It("should apply scheduling settings", func() {
+			podSpec := corev1.PodSpec{}
+			applyJobConfiguration(&podSpec, &backupv1alpha1.JobConfiguration{
+				NodeSelector:       map[string]string{"kubernetes.io/arch": "amd64"},
+				Tolerations:        []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+				ServiceAccountName: "backup-sa",
+			})
+			Expect(podSpec.NodeSelector).To(HaveKeyWithValue("kubernetes.io/arch", "amd64"))
+			Expect(podSpec.Tolerations).To(HaveLen(1))
+			Expect(podSpec.ServiceAccountName).To(Equal("backup-sa"))
+		})
+
+		It("should apply DNS settings and host aliases", func() {
+			podSpec := corev1.PodSpec{}
+			applyJobConfiguration(&podSpec, &backupv1alpha1.JobConfiguration{
+				DNSPolicy: corev1.DNSNone,
+				DNSConfig: &corev1.PodDNSConfig{
+					Nameservers: []string{"10.0.0.53"},
+				},
+				HostAliases: []corev1.HostAlias{
+					{IP: "10.0.0.10", Hostnames: []string{"minio.backup.internal"}},
+				},
+			})
+			Expect(podSpec.DNSPolicy).To(Equal(corev1.DNSNone))
+			Expect(podSpec.DNSConfig.Nameservers).To(ConsistOf("10.0.0.53"))
+			Expect(podSpec.HostAliases).To(HaveLen(1))
+			Expect(podSpec.HostAliases[0].Hostnames).To(ConsistOf("minio.backup.internal"))
+		})
+
+		It("should set the runtime class", func() {
+			podSpec := corev1.PodSpec{}
+			runtimeClass := "gvisor"
+			applyJobConfiguration(&podSpec, &backupv1alpha1.JobConfiguration{
+				RuntimeClassName: &runtimeClass,
+			})
+			Expect(*podSpec.RuntimeClassName).To(Equal("gvisor"))
+		})
+
+		It("should drop GPU and spot tolerations by default", func() {
+			podSpec := corev1.PodSpec{}
+			applyJobConfiguration(&podSpec, &backupv1alpha1.JobConfiguration{
+				Tolerations: []corev1.Toleration{
+					{Key: "dedicated", Operator: corev1.TolerationOpExists},
+					{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists},
+					{Key: "cloud.google.com/gke-spot", Operator: corev1.TolerationOpEqual, Value: "true"},
+				},
+			})
+			Expect(podSpec.Tolerations).To(ConsistOf(HaveField("Key", "dedicated")))
+			Expect(podSpec.Affinity).To(BeNil())
+		})
+
+		It("should keep GPU and spot tolerations when allowed", func() {
+			podSpec := corev1.PodSpec{}
+			applyJobConfiguration(&podSpec, &backupv1alpha1.JobConfiguration{
+				Tolerations: []corev1.Toleration{
+					{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists},
+					{Key: "cloud.google.com/gke-spot", Operator: corev1.TolerationOpExists},
+				},
+				AllowGPUNodes:  true,
+				AllowSpotNodes: true,
+			})
+			Expect(podSpec.Tolerations).To(HaveLen(2))
+		})
+
+		It("should exclude GPU and spot nodes for wildcard tolerations", func() {
+			podSpec := corev1.PodSpec{}
+			affinity := &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{MatchExpressions: []corev1.NodeSelectorRequirement{
+								{Key: "kubernetes.io/arch", Operator: corev1.NodeSelectorOpIn, Values: []string{"amd64"}},
+							}},
+						},
+					},
+				},
+			}
+			applyJobConfiguration(&podSpec, &backupv1alpha1.JobConfiguration{
+				Tolerations:   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+				Affinity:      affinity,
+				AllowGPUNodes: true,
+			})
+
+			expressions := podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions
+			Expect(expressions).To(ContainElement(HaveField("Key", "kubernetes.io/arch")))
+			Expect(expressions).To(ContainElement(corev1.NodeSelectorRequirement{
+				Key:      "cloud.google.com/gke-spot",
+				Operator: corev1.NodeSelectorOpDoesNotExist,
+			}))
+			Expect(expressions).To(ContainElement(corev1.NodeSelectorRequirement{
+				Key:      "eks.amazonaws.com/capacityType",
+				Operator: corev1.NodeSelectorOpNotIn,
+				Values:   []string{"SPOT"},
+			}))
+			Expect(expressions).NotTo(ContainElement(HaveField("Key", "nvidia.com/gpu.present")))
+			// The configured affinity must not be modified
+			Expect(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions).To(HaveLen(1))
+		})
+
+		It("should add sidecars as native sidecars", func() {
+			podSpec := corev1.PodSpec{}
+			jobConfig := &backupv1alpha1.JobConfiguration{
+				Sidecars: []corev1.Container{{Name: "fluent-bit", Image: "fluent/fluent-bit:3.0"}},
+			}
+			applyJobConfiguration(&podSpec, jobConfig)
+
+			Expect(podSpec.InitContainers).To(HaveLen(1))
+			Expect(podSpec.InitContainers[0].Name).To(Equal("fluent-bit"))
+			Expect(podSpec.InitContainers[0].RestartPolicy).To(HaveValue(Equal(corev1.ContainerRestartPolicyAlways)))
+			// The configured sidecar must not be modified
+			Expect(jobConfig.Sidecars[0].RestartPolicy).To(BeNil())
+		})
+
+		It("should reject sidecars named like another container", func() {
+			Expect(validateSidecars(nil)).To(Succeed())
+			jobConfig := &backupv1alpha1.JobConfiguration{
+				Sidecars: []corev1.Container{{Name: "fluent-bit"}, {Name: "egress-proxy"}},
+			}
+			Expect(validateSidecars(jobConfig)).To(Succeed())
+
+			jobConfig.Sidecars[1].Name = "dump"
+			Expect(validateSidecars(jobConfig)).To(MatchError(ContainSubstring(`"dump"`)))
+
+			jobConfig.Sidecars[1].Name = "fluent-bit"
+			Expect(validateSidecars(jobConfig)).To(MatchError(ContainSubstring(`"fluent-bit"`)))
+		})
+	})
+
+	Context("applyPodMetadata helper function", func() {
+		It("should request native mesh sidecars", func() {
+			meta := metav1.ObjectMeta{Annotations: map[string]string{"existing": "value"}}
+			applyPodMetadata(&meta, &backupv1alpha1.JobConfiguration{NativeMeshSidecars: true})
+
+			Expect(meta.Annotations).To(HaveKeyWithValue("existing", "value"))
+			Expect(meta.Annotations).To(HaveKeyWithValue("sidecar.istio.io/nativeSidecar", "true"))
+			Expect(meta.Annotations).To(HaveKeyWithValue("config.alpha.linkerd.io/proxy-enable-native-sidecar", "true"))
+		})
+
+		It("should leave the metadata untouched by default", func() {
+			meta := metav1.ObjectMeta{}
+			applyPodMetadata(&meta, &backupv1alpha1.JobConfiguration{})
+			applyPodMetadata(&meta, nil)
+			Expect(meta.Annotations).To(BeNil())
+		})
+	})
+})
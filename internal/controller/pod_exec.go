@@ -0,0 +1,84 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// PodExecutor executes commands in running pods.
+type PodExecutor interface {
+	// Exec runs the command in the container of the pod and returns its output.
+	// An empty container selects the default container of the pod.
+	Exec(ctx context.Context, namespace, pod, container string, command []string) (string, error)
+}
+
+// remotePodExecutor executes commands through the pods/exec subresource of the API server.
+type remotePodExecutor struct {
+	config    *rest.Config
+	clientset kubernetes.Interface
+}
+
+// NewPodExecutor returns a PodExecutor using the given API server configuration.
+func NewPodExecutor(config *rest.Config) (PodExecutor, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	return &remotePodExecutor{config: config, clientset: clientset}, nil
+}
+
+// Exec implements PodExecutor.
+func (e *remotePodExecutor) Exec(ctx context.Context, namespace, pod, container string, command []string) (string, error) {
+	req := e.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(e.config, http.MethodPost, req.URL())
+	if err != nil {
+		return "", fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	// Stdout and stderr are copied concurrently, so they need separate buffers
+	var stdout, stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	output := stdout.String() + stderr.String()
+	if err != nil {
+		return output, fmt.Errorf("command failed in pod %s: %w", pod, err)
+	}
+	return output, nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// PodLogReader reads the logs of pods.
+type PodLogReader interface {
+	// TailLogs returns the last lines of the log of the container of the pod.
+	TailLogs(ctx context.Context, namespace, pod, container string, lines int64) (string, error)
+}
+
+// remotePodLogReader reads logs through the pods/log subresource of the API server.
+type remotePodLogReader struct {
+	clientset kubernetes.Interface
+}
+
+// NewPodLogReader returns a PodLogReader using the given API server configuration.
+func NewPodLogReader(config *rest.Config) (PodLogReader, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	return &remotePodLogReader{clientset: clientset}, nil
+}
+
+// TailLogs implements PodLogReader.
+func (r *remotePodLogReader) TailLogs(ctx context.Context, namespace, pod, container string, lines int64) (string, error) {
+	logs, err := r.clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		TailLines: &lines,
+	}).DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read logs of pod %s: %w", pod, err)
+	}
+	return string(logs), nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+)
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticreferencegrants,verbs=get;list;watch
+
+// reasonReferenceDenied is the condition reason of references denied by missing grants.
+const reasonReferenceDenied = "ReferenceDenied"
+
+// referenceDeniedError reports a reference to another namespace that no
+// ResticReferenceGrant permits.
+type referenceDeniedError struct {
+	fromKind      string
+	fromNamespace string
+	toKind        string
+	toNamespace   string
+	toName        string
+}
+
+func (e *referenceDeniedError) Error() string {
+	if e.toName == "" {
+		return fmt.Sprintf("%s in namespace %s may not reference the %ss of namespace %s: no ResticReferenceGrant in namespace %s permits it",
+			e.fromKind, e.fromNamespace, e.toKind, e.toNamespace, e.toNamespace)
+	}
+	return fmt.Sprintf("%s in namespace %s may not reference %s %s/%s: no ResticReferenceGrant in namespace %s permits it",
+		e.fromKind, e.fromNamespace, e.toKind, e.toNamespace, e.toName, e.toNamespace)
+}
+
+// checkReferenceGrant returns a referenceDeniedError if no ResticReferenceGrant in
+// toNamespace permits fromKind resources in fromNamespace to reference the toKind toName.
+// An empty toName requires a grant for all toKind resources of the namespace.
+// References within a namespace are always permitted.
+func checkReferenceGrant(ctx context.Context, reader client.Reader, gate *features.Gate,
+	fromKind, fromNamespace, toKind, toNamespace, toName string) error {
+	if fromNamespace == toNamespace || !gate.Enabled(features.ReferenceGrants) {
+		return nil
+	}
+
+	grants := &backupv1alpha1.ResticReferenceGrantList{}
+	if err := reader.List(ctx, grants, client.InNamespace(toNamespace)); err != nil {
+		return fmt.Errorf("failed to list ResticReferenceGrants: %w", err)
+	}
+	for i := range grants.Items {
+		spec := &grants.Items[i].Spec
+		from := slices.ContainsFunc(spec.From, func(f backupv1alpha1.ReferenceGrantFrom) bool {
+			return f.Kind == fromKind && f.Namespace == fromNamespace
+		})
+		to := slices.ContainsFunc(spec.To, func(t backupv1alpha1.ReferenceGrantTo) bool {
+			return t.Kind == toKind && (t.Name == "" || t.Name == toName)
+		})
+		if from && to {
+			return nil
+		}
+	}
+
+	return &referenceDeniedError{
+		fromKind:      fromKind,
+		fromNamespace: fromNamespace,
+		toKind:        toKind,
+		toNamespace:   toNamespace,
+		toName:        toName,
+	}
+}
+
+// referenceErrorReason returns the condition reason of a failed reference lookup:
+// ReferenceDenied for denied references, reason otherwise.
+func referenceErrorReason(err error, reason string) string {
+	var denied *referenceDeniedError
+	if errors.As(err, &denied) {
+		return reasonReferenceDenied
+	}
+	return reason
+}
+
+// setReferenceDenied sets the ReferenceDenied condition if err is a denied reference
+// and clears a previously set condition once the reference resolves.
+func setReferenceDenied(list *[]metav1.Condition, err error) {
+	var denied *referenceDeniedError
+	if errors.As(err, &denied) {
+		conditions.SetCondition(list, conditions.NewCondition(backupv1alpha1.ConditionReferenceDenied,
+			metav1.ConditionTrue, "NoReferenceGrant", err.Error()))
+		return
+	}
+	if err == nil && conditions.IsConditionTrue(*list, backupv1alpha1.ConditionReferenceDenied) {
+		conditions.SetCondition(list, conditions.NewCondition(backupv1alpha1.ConditionReferenceDenied,
+			metav1.ConditionFalse, "ReferenceGranted", "References are permitted"))
+	}
+}
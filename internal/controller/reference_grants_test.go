@@ -0,0 +1,132 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+)
+
+var _ = Describe("Reference grants", func() {
+	var (
+		ctx        context.Context
+		testScheme *runtime.Scheme
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+	})
+
+	newClient := func(objects ...client.Object) client.Client {
+		return fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build()
+	}
+
+	grant := func(fromKind, fromNamespace, toName string) *backupv1alpha1.ResticReferenceGrant {
+		return &backupv1alpha1.ResticReferenceGrant{
+			ObjectMeta: metav1.ObjectMeta{Name: "grant-" + fromNamespace, Namespace: "backup-system"},
+			Spec: backupv1alpha1.ResticReferenceGrantSpec{
+				From: []backupv1alpha1.ReferenceGrantFrom{{Kind: fromKind, Namespace: fromNamespace}},
+				To:   []backupv1alpha1.ReferenceGrantTo{{Kind: "ResticRepository", Name: toName}},
+			},
+		}
+	}
+
+	Context("checkReferenceGrant", func() {
+		It("should permit references within a namespace", func() {
+			err := checkReferenceGrant(ctx, newClient(), nil, "ResticBackup", "media", "ResticRepository", "media", "nas")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should deny references to other namespaces without a grant", func() {
+			err := checkReferenceGrant(ctx, newClient(), nil, "ResticBackup", "media", "ResticRepository", "backup-system", "nas")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("no ResticReferenceGrant in namespace backup-system"))
+			Expect(referenceErrorReason(err, "RepositoryNotFound")).To(Equal("ReferenceDenied"))
+		})
+
+		It("should permit references granted for the namespace and kind", func() {
+			c := newClient(grant("ResticBackup", "media", ""))
+
+			Expect(checkReferenceGrant(ctx, c, nil, "ResticBackup", "media", "ResticRepository", "backup-system", "nas")).To(Succeed())
+			Expect(checkReferenceGrant(ctx, c, nil, "ResticCheck", "media", "ResticRepository", "backup-system", "nas")).NotTo(Succeed())
+			Expect(checkReferenceGrant(ctx, c, nil, "ResticBackup", "photos", "ResticRepository", "backup-system", "nas")).NotTo(Succeed())
+		})
+
+		It("should restrict grants with a name to that resource", func() {
+			c := newClient(grant("ResticBackup", "media", "nas"))
+
+			Expect(checkReferenceGrant(ctx, c, nil, "ResticBackup", "media", "ResticRepository", "backup-system", "nas")).To(Succeed())
+			Expect(checkReferenceGrant(ctx, c, nil, "ResticBackup", "media", "ResticRepository", "backup-system", "s3")).NotTo(Succeed())
+		})
+
+		It("should permit all references with the feature gate disabled", func() {
+			gate := features.NewGate()
+			Expect(gate.Set("ReferenceGrants=false")).To(Succeed())
+
+			err := checkReferenceGrant(ctx, newClient(), gate, "ResticBackup", "media", "ResticRepository", "backup-system", "nas")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("setReferenceDenied", func() {
+		It("should set and clear the ReferenceDenied condition", func() {
+			var list []metav1.Condition
+			err := checkReferenceGrant(ctx, newClient(), nil, "ResticBackup", "media", "ResticRepository", "backup-system", "nas")
+
+			setReferenceDenied(&list, err)
+			Expect(conditions.IsConditionTrue(list, backupv1alpha1.ConditionReferenceDenied)).To(BeTrue())
+
+			setReferenceDenied(&list, nil)
+			Expect(conditions.IsConditionFalse(list, backupv1alpha1.ConditionReferenceDenied)).To(BeTrue())
+		})
+	})
+
+	Context("ResticBackupReconciler.getRepository", func() {
+		It("should deny repositories in other namespaces without a grant", func() {
+			repository := &backupv1alpha1.ResticRepository{ObjectMeta: metav1.ObjectMeta{Name: "nas", Namespace: "backup-system"}}
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas", Namespace: "backup-system"},
+				},
+			}
+
+			reconciler := &ResticBackupReconciler{Client: newClient(repository)}
+			_, err := reconciler.getRepository(ctx, backup)
+			Expect(err).To(HaveOccurred())
+
+			reconciler.Client = newClient(repository, grant("ResticBackup", "media", "nas"))
+			found, err := reconciler.getRepository(ctx, backup)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.Name).To(Equal("nas"))
+		})
+	})
+})
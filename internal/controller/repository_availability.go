@@ -0,0 +1,162 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// defaultConnectivityProbeInterval is the probe interval of intermittent backends.
+	defaultConnectivityProbeInterval = 5 * time.Minute
+	// defaultMaxOfflineDuration is how long an intermittent backend may be unreachable
+	// while it is expected to be online.
+	defaultMaxOfflineDuration = time.Hour
+)
+
+// expectsIntermittentBackend reports whether the backend of the repository is only
+// reachable at times.
+func expectsIntermittentBackend(repository *backupv1alpha1.ResticRepository) bool {
+	return repository.Spec.ExpectedAvailability == backupv1alpha1.ExpectedAvailabilityIntermittent
+}
+
+// backendOffline reports whether backup jobs of the repository must wait because its
+// intermittent backend was unreachable at the last connectivity probe.
+func backendOffline(repository *backupv1alpha1.ResticRepository) bool {
+	return expectsIntermittentBackend(repository) &&
+		!conditions.IsConditionTrue(repository.Status.Conditions, backupv1alpha1.ConditionReachable)
+}
+
+// backendUnreachable reports whether a failed probe means the backend could not be
+// reached, as opposed to e.g. a missing repository or wrong credentials.
+func backendUnreachable(err error) bool {
+	return restic.IsRetryable(err) && !strings.Contains(strings.ToLower(err.Error()), "lock")
+}
+
+// connectivityProbeInterval returns the probe interval of an intermittent backend.
+func connectivityProbeInterval(repository *backupv1alpha1.ResticRepository) time.Duration {
+	if config := repository.Spec.Intermittent; config != nil && config.ProbeInterval != nil && config.ProbeInterval.Duration > 0 {
+		return config.ProbeInterval.Duration
+	}
+	return defaultConnectivityProbeInterval
+}
+
+// maxOfflineDuration returns how long an intermittent backend may be unreachable while
+// it is expected to be online.
+func maxOfflineDuration(repository *backupv1alpha1.ResticRepository) time.Duration {
+	if config := repository.Spec.Intermittent; config != nil && config.MaxOfflineDuration != nil {
+		return config.MaxOfflineDuration.Duration
+	}
+	return defaultMaxOfflineDuration
+}
+
+// expectedOnlineSince returns the start of the online window containing now, or the
+// zero time if the backend is expected to be online at any time. ok is false outside
+// of the online window.
+func expectedOnlineSince(repository *backupv1alpha1.ResticRepository, now time.Time) (since time.Time, ok bool) {
+	config := repository.Spec.Intermittent
+	if config == nil || config.OnlineWindow == nil {
+		return time.Time{}, true
+	}
+	location := time.UTC
+	if config.Timezone != "" {
+		if loaded, err := time.LoadLocation(config.Timezone); err == nil {
+			location = loaded
+		}
+	}
+	for _, r := range windowRanges(config.OnlineWindow, location, now) {
+		if !now.Before(r.start) && now.Before(r.end) && (!ok || r.start.Before(since)) {
+			since, ok = r.start, true
+		}
+	}
+	return since, ok
+}
+
+// handleUnreachableBackend records a failed connectivity probe of an intermittent
+// backend. The repository stays ready unless the backend has been unreachable for
+// longer than the max offline duration while it is expected to be online.
+func (r *ResticRepositoryReconciler) handleUnreachableBackend(ctx context.Context, repository *backupv1alpha1.ResticRepository, probeErr error) (ctrl.Result, error) {
+	now := time.Now()
+	excerpt := restic.StderrExcerpt(probeErr, 1)
+	if excerpt == "" {
+		excerpt = probeErr.Error()
+	}
+	log.FromContext(ctx).Info("Intermittent backend is unreachable, deferring backup jobs", "error", excerpt)
+
+	if !conditions.IsConditionFalse(repository.Status.Conditions, backupv1alpha1.ConditionReachable) {
+		r.Recorder.Event(repository, corev1.EventTypeNormal, "BackendOffline",
+			"Backend is unreachable, backup jobs are deferred until it is reachable again")
+	}
+	r.setCondition(repository, conditions.NewCondition(backupv1alpha1.ConditionReachable,
+		metav1.ConditionFalse, "BackendOffline", fmt.Sprintf("Backend is unreachable: %s", excerpt)))
+	offlineSince := conditions.GetCondition(repository.Status.Conditions, backupv1alpha1.ConditionReachable).LastTransitionTime.Time
+
+	// Only the time offline within the current online window counts
+	windowStart, online := expectedOnlineSince(repository, now)
+	if windowStart.After(offlineSince) {
+		offlineSince = windowStart
+	}
+	ready := conditions.GetCondition(repository.Status.Conditions, backupv1alpha1.ConditionReady)
+	switch {
+	case online && now.Sub(offlineSince) >= maxOfflineDuration(repository):
+		message := fmt.Sprintf("Backend has been unreachable since %s while it is expected to be online", offlineSince.UTC().Format(time.RFC3339))
+		if ready == nil || ready.Reason != "BackendUnreachable" {
+			r.Recorder.Event(repository, corev1.EventTypeWarning, "BackendUnreachable", message)
+		}
+		r.setCondition(repository, conditions.NotReadyCondition("BackendUnreachable", message))
+		recordRepositoryFailure(repository, repositoryOperationProbe, probeErr)
+	case repository.Status.LastCredentialsCheck != nil:
+		// The repository was accessible before, backups are deferred instead of failing
+		r.setCondition(repository, conditions.ReadyCondition("BackendOffline",
+			"Backend is offline, backup jobs are deferred until it is reachable again"))
+	default:
+		r.setCondition(repository, conditions.UnknownCondition("BackendOffline",
+			"Backend has not been reachable yet"))
+	}
+
+	if err := r.Status().Update(ctx, repository); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: connectivityProbeInterval(repository)}, nil
+}
+
+// setReachable records a successful connectivity probe. The Reachable condition is
+// only kept for intermittent backends.
+func (r *ResticRepositoryReconciler) setReachable(repository *backupv1alpha1.ResticRepository) {
+	if !expectsIntermittentBackend(repository) {
+		conditions.RemoveCondition(&repository.Status.Conditions, backupv1alpha1.ConditionReachable)
+		return
+	}
+	if conditions.IsConditionFalse(repository.Status.Conditions, backupv1alpha1.ConditionReachable) {
+		r.Recorder.Event(repository, corev1.EventTypeNormal, "BackendOnline",
+			"Backend is reachable again, deferred backup jobs are started")
+	}
+	r.setCondition(repository, conditions.NewCondition(backupv1alpha1.ConditionReachable,
+		metav1.ConditionTrue, "BackendReachable", "Connectivity probe succeeded"))
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+var _ = Describe("Intermittent repositories", func() {
+	var (
+		ctx        context.Context
+		testScheme *runtime.Scheme
+		repository *backupv1alpha1.ResticRepository
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		checked := metav1.NewTime(time.Now().Add(-6 * time.Hour))
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "offsite",
+				Namespace:  "backup",
+				Finalizers: []string{resticRepositoryFinalizer},
+			},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "rest:http://nas.vpn:8000/",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "offsite-credentials"},
+				ExpectedAvailability: backupv1alpha1.ExpectedAvailabilityIntermittent,
+			},
+			Status: backupv1alpha1.ResticRepositoryStatus{
+				Conditions:           []metav1.Condition{conditions.ReadyCondition("RepositoryAccessible", "Repository is initialized and accessible")},
+				LastCredentialsCheck: &checked,
+			},
+		}
+	})
+
+	reconcile := func(executor restic.Executor) (*ResticRepositoryReconciler, ctrl.Result) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "offsite-credentials", Namespace: "backup"},
+			Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+		}
+		r := &ResticRepositoryReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).WithObjects(repository, secret).
+				WithStatusSubresource(&backupv1alpha1.ResticRepository{}).Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(20),
+			Executor: executor,
+		}
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(repository)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(repository), repository)).To(Succeed())
+		return r, result
+	}
+
+	offlineFor := func(d time.Duration) {
+		condition := conditions.NewCondition(backupv1alpha1.ConditionReachable, metav1.ConditionFalse, "BackendOffline", "Backend is unreachable")
+		condition.LastTransitionTime = metav1.NewTime(time.Now().Add(-d))
+		repository.Status.Conditions = append(repository.Status.Conditions, condition)
+	}
+
+	It("should stay ready and defer backups while the backend is offline", func() {
+		r, result := reconcile(&unavailableExecutor{})
+		Expect(result.RequeueAfter).To(Equal(defaultConnectivityProbeInterval))
+		Expect(conditions.IsConditionFalse(repository.Status.Conditions, backupv1alpha1.ConditionReachable)).To(BeTrue())
+		ready := conditions.GetCondition(repository.Status.Conditions, backupv1alpha1.ConditionReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionTrue))
+		Expect(ready.Reason).To(Equal("BackendOffline"))
+		Expect(backendOffline(repository)).To(BeTrue())
+		Expect(r.Recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("BackendOffline")))
+	})
+
+	It("should report the repository as not ready once the backend is offline for too long", func() {
+		offlineFor(2 * time.Hour)
+		reconcile(&unavailableExecutor{})
+		ready := conditions.GetCondition(repository.Status.Conditions, backupv1alpha1.ConditionReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal("BackendUnreachable"))
+		Expect(repository.Status.LastFailure.Operation).To(Equal(repositoryOperationProbe))
+	})
+
+	It("should not report an offline backend outside of its online window", func() {
+		offlineFor(20 * time.Hour)
+		now := time.Now().UTC()
+		repository.Spec.Intermittent = &backupv1alpha1.IntermittentConfig{
+			OnlineWindow: &backupv1alpha1.BackupWindow{Ranges: []backupv1alpha1.TimeRange{{
+				Start: now.Add(2 * time.Hour).Format("15:04"),
+				End:   now.Add(4 * time.Hour).Format("15:04"),
+			}}},
+		}
+		reconcile(&unavailableExecutor{})
+		Expect(conditions.IsConditionTrue(repository.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+	})
+
+	It("should only count the time offline within the current online window", func() {
+		offlineFor(20 * time.Hour)
+		now := time.Now().UTC()
+		repository.Spec.Intermittent = &backupv1alpha1.IntermittentConfig{
+			OnlineWindow: &backupv1alpha1.BackupWindow{Ranges: []backupv1alpha1.TimeRange{{
+				Start: now.Add(-30 * time.Minute).Format("15:04"),
+				End:   now.Add(4 * time.Hour).Format("15:04"),
+			}}},
+		}
+		reconcile(&unavailableExecutor{})
+		Expect(conditions.IsConditionTrue(repository.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+
+		repository.Spec.Intermittent.MaxOfflineDuration = &metav1.Duration{Duration: 15 * time.Minute}
+		reconcile(&unavailableExecutor{})
+		Expect(conditions.IsConditionFalse(repository.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+	})
+
+	It("should mark the backend as reachable once the probe succeeds", func() {
+		offlineFor(time.Hour)
+		r, result := reconcile(&MockExecutor{})
+		Expect(result.RequeueAfter).To(BeNumerically("<=", defaultConnectivityProbeInterval))
+		Expect(conditions.IsConditionTrue(repository.Status.Conditions, backupv1alpha1.ConditionReachable)).To(BeTrue())
+		Expect(backendOffline(repository)).To(BeFalse())
+		Expect(r.Recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("BackendOnline")))
+	})
+
+	It("should keep backup jobs suspended while the backend is offline", func() {
+		offlineFor(time.Minute)
+		backup := &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "backup"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Schedule:      "0 2 * * *",
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "offsite"},
+				Source:        backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+			},
+		}
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "data-1", Namespace: "backup", Labels: map[string]string{resticBackupLabel: "data"}},
+			Spec:       batchv1.JobSpec{Suspend: boolPtr(true)},
+		}
+		r := &ResticBackupReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(repository, backup, job).Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+		}
+
+		cronJob, err := r.buildCronJob(backup, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*cronJob.Spec.JobTemplate.Spec.Suspend).To(BeTrue())
+
+		Expect(r.updateBackupStatus(ctx, backup, repository)).To(Succeed())
+		waiting := conditions.GetCondition(backup.Status.Conditions, backupv1alpha1.ConditionWaitingForRepository)
+		Expect(waiting.Message).To(ContainSubstring("offline"))
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(job), job)).To(Succeed())
+		Expect(*job.Spec.Suspend).To(BeTrue())
+	})
+})
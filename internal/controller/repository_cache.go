@@ -0,0 +1,395 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// resticCacheCleanupLabel links cache cleanup CronJobs and their Jobs to the ResticRepository
+	resticCacheCleanupLabel = "backup.resticbackup.io/cache-cleanup"
+
+	cacheVolumeName             = "restic-cache"
+	cacheMountPath              = "/cache"
+	defaultCacheSize            = "5Gi"
+	defaultCacheCleanupSchedule = "@daily"
+	defaultCacheMaxAgeDays      = 30
+)
+
+// cacheEnabled reports whether the repository uses a cache PVC.
+func cacheEnabled(repository *backupv1alpha1.ResticRepository) bool {
+	return repository != nil && repository.Spec.Cache != nil && repository.Spec.Cache.Enabled
+}
+
+func cachePVCName(repository *backupv1alpha1.ResticRepository) string {
+	return fmt.Sprintf("restic-cache-%s", repository.Name)
+}
+
+func cacheCleanupCronJobName(repository *backupv1alpha1.ResticRepository) string {
+	return fmt.Sprintf("restic-cache-cleanup-%s", repository.Name)
+}
+
+// applyRepositoryCache mounts the cache PVC of the repository into the restic container
+// and points RESTIC_CACHE_DIR to it. Only pods in the namespace of the repository can
+// mount the PVC; pods in other namespaces keep the cache in the container.
+func applyRepositoryCache(podSpec *corev1.PodSpec, repository *backupv1alpha1.ResticRepository, namespace string) {
+	if !cacheEnabled(repository) || repository.Namespace != namespace {
+		return
+	}
+
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: cacheVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: cachePVCName(repository),
+			},
+		},
+	})
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != "restic" {
+			continue
+		}
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      cacheVolumeName,
+			MountPath: cacheMountPath,
+		})
+		container.Env = append(container.Env, corev1.EnvVar{Name: "RESTIC_CACHE_DIR", Value: cacheMountPath})
+	}
+}
+
+// reconcileCache creates the cache PVC and the cache cleanup CronJob of the repository
+// and records the result of the last cleanup. Both are deleted when the cache is disabled.
+// Cleanup Jobs are not watched, because every reconcile checks the repository; their
+// result is recorded on the next periodic reconcile.
+func (r *ResticRepositoryReconciler) reconcileCache(ctx context.Context, repository *backupv1alpha1.ResticRepository) error {
+	if !cacheEnabled(repository) {
+		if repository.Status.Cache == nil {
+			return nil
+		}
+		return r.deleteCache(ctx, repository)
+	}
+
+	if repository.Status.Cache == nil {
+		repository.Status.Cache = &backupv1alpha1.CacheStatus{}
+	}
+	repository.Status.Cache.PVCName = cachePVCName(repository)
+
+	if err := r.reconcileCachePVC(ctx, repository); err != nil {
+		return err
+	}
+	if err := r.reconcileCacheCleanupCronJob(ctx, repository); err != nil {
+		return err
+	}
+	return r.updateCacheStatus(ctx, repository)
+}
+
+// reconcileCachePVC creates the cache PVC. The access mode of a claim is immutable and
+// the claim may have been customized, a claim with another access mode is kept and
+// reported.
+func (r *ResticRepositoryReconciler) reconcileCachePVC(ctx context.Context, repository *backupv1alpha1.ResticRepository) error {
+	existing := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, types.NamespacedName{Name: cachePVCName(repository), Namespace: repository.Namespace}, existing)
+	if err == nil {
+		// The PVC spec is immutable apart from resizing, keep the existing claim
+		accessMode := cacheAccessMode(repository.Spec.Cache)
+		if existing.DeletionTimestamp.IsZero() && !slices.Contains(existing.Spec.AccessModes, accessMode) {
+			r.Recorder.Event(repository, corev1.EventTypeWarning, "CachePVCAccessModeMismatch",
+				fmt.Sprintf("Cache PVC %s does not have access mode %s, delete it to recreate it", existing.Name, accessMode))
+		}
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get cache PVC: %w", err)
+	}
+
+	pvc, err := buildCachePVC(repository)
+	if err != nil {
+		return err
+	}
+	if err := controllerutil.SetControllerReference(repository, pvc, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+	if err := r.Create(ctx, pvc); err != nil {
+		return fmt.Errorf("failed to create cache PVC: %w", err)
+	}
+	r.Recorder.Event(repository, corev1.EventTypeNormal, "CachePVCCreated", fmt.Sprintf("Created cache PVC %s", pvc.Name))
+	return nil
+}
+
+func (r *ResticRepositoryReconciler) reconcileCacheCleanupCronJob(ctx context.Context, repository *backupv1alpha1.ResticRepository) error {
+	cronJob := buildCacheCleanupCronJob(repository, r.Images.Resolve("", nil))
+	if err := controllerutil.SetControllerReference(repository, cronJob, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	existing := &batchv1.CronJob{}
+	err := r.Get(ctx, types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, existing)
+	if apierrors.IsNotFound(err) {
+		log.FromContext(ctx).Info("Creating cache cleanup CronJob", "name", cronJob.Name)
+		created, err := createOrAdopt(ctx, r.Client, repository, cronJob, existing)
+		if err != nil {
+			return fmt.Errorf("failed to create cache cleanup CronJob: %w", err)
+		}
+		if created {
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get cache cleanup CronJob: %w", err)
+	}
+
+	existing.Spec = cronJob.Spec
+	if err := r.Update(ctx, existing); err != nil {
+		return fmt.Errorf("failed to update cache cleanup CronJob: %w", err)
+	}
+	return nil
+}
+
+// updateCacheStatus records the cache size measured by the most recently finished
+// cleanup Job. Each Job is evaluated only once.
+func (r *ResticRepositoryReconciler) updateCacheStatus(ctx context.Context, repository *backupv1alpha1.ResticRepository) error {
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs,
+		client.InNamespace(repository.Namespace),
+		client.MatchingLabels{resticCacheCleanupLabel: repository.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list cache cleanup jobs: %w", err)
+	}
+
+	status := repository.Status.Cache
+	latest, succeeded, finishedAt := latestFinishedJob(jobs.Items)
+	if latest == nil || latest.Name == status.LastCleanupJob {
+		return nil
+	}
+	status.LastCleanupJob = latest.Name
+
+	if !succeeded {
+		r.Recorder.Event(repository, corev1.EventTypeWarning, "CacheCleanupFailed",
+			fmt.Sprintf("Cache cleanup job %s failed", latest.Name))
+		return nil
+	}
+
+	lastCleanup := metav1.NewTime(finishedAt)
+	status.LastCleanup = &lastCleanup
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	message, err := jobTerminationMessage(ctx, reader, latest)
+	if err != nil {
+		// The pod may already be gone, keep the previously reported size
+		return err
+	}
+	size, err := parseCacheSize(message)
+	if err != nil {
+		return err
+	}
+	status.Size = size
+	return nil
+}
+
+// deleteCache deletes the cache PVC and the cache cleanup CronJob of a repository whose
+// cache was disabled.
+func (r *ResticRepositoryReconciler) deleteCache(ctx context.Context, repository *backupv1alpha1.ResticRepository) error {
+	cronJob := &batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: cacheCleanupCronJobName(repository), Namespace: repository.Namespace}}
+	if err := r.Delete(ctx, cronJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete cache cleanup CronJob: %w", err)
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: cachePVCName(repository), Namespace: repository.Namespace}}
+	if err := r.Delete(ctx, pvc); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete cache PVC: %w", err)
+	}
+
+	repository.Status.Cache = nil
+	return nil
+}
+
+// cacheAccessMode returns the access mode of the cache PVC, ReadWriteOnce by default.
+func cacheAccessMode(cache *backupv1alpha1.CacheConfig) corev1.PersistentVolumeAccessMode {
+	if cache.AccessMode != "" {
+		return cache.AccessMode
+	}
+	return corev1.ReadWriteOnce
+}
+
+// buildCachePVC builds the cache PVC of a repository.
+func buildCachePVC(repository *backupv1alpha1.ResticRepository) (*corev1.PersistentVolumeClaim, error) {
+	cache := repository.Spec.Cache
+	size := defaultCacheSize
+	if cache.Size != "" {
+		size = cache.Size
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache size %q: %w", size, err)
+	}
+
+	var storageClassName *string
+	if cache.StorageClassName != "" {
+		storageClassName = &cache.StorageClassName
+	}
+
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cachePVCName(repository),
+			Namespace: repository.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "cache",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{cacheAccessMode(cache)},
+			StorageClassName: storageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: quantity},
+			},
+		},
+	}, nil
+}
+
+// buildCacheCleanupScript builds the shell script run by the cache cleanup container.
+// The cache size in KiB after the cleanup is written to the termination message.
+func buildCacheCleanupScript(cache *backupv1alpha1.CacheConfig) string {
+	maxAgeDays := int32(defaultCacheMaxAgeDays)
+	if cache.MaxAgeDays != nil {
+		maxAgeDays = *cache.MaxAgeDays
+	}
+	cmd := restic.NewCommand("cache").
+		WithArg("--cleanup").
+		WithArgs([]string{"--max-age", strconv.Itoa(int(maxAgeDays))})
+
+	commands := []string{
+		"set -e",
+		fmt.Sprintf("restic %s", shellQuoteArgs(cmd.Build())),
+		fmt.Sprintf("du -sk %s | cut -f1 > /dev/termination-log", cacheMountPath),
+	}
+
+	return strings.Join(commands, "\n")
+}
+
+// buildCacheCleanupCronJob builds the CronJob removing stale data from the cache PVC.
+func buildCacheCleanupCronJob(repository *backupv1alpha1.ResticRepository, image string) *batchv1.CronJob {
+	schedule := repository.Spec.Cache.CleanupSchedule
+	if schedule == "" {
+		schedule = defaultCacheCleanupSchedule
+	}
+
+	var successLimit, failLimit, backoffLimit int32 = 1, 1, 0
+	var activeDeadline int64 = 600
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "restic-backup-operator",
+		"app.kubernetes.io/component": "cache-cleanup",
+		resticCacheCleanupLabel:       repository.Name,
+	}
+
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cacheCleanupCronJobName(repository),
+			Namespace: repository.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "cache-cleanup",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				resticCacheCleanupLabel:        repository.Name,
+			},
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   schedule,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &successLimit,
+			FailedJobsHistoryLimit:     &failLimit,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit:          &backoffLimit,
+					ActiveDeadlineSeconds: &activeDeadline,
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: labels,
+						},
+						Spec: corev1.PodSpec{
+							RestartPolicy: corev1.RestartPolicyNever,
+							SecurityContext: &corev1.PodSecurityContext{
+								RunAsNonRoot: boolPtr(true),
+								RunAsUser:    int64Ptr(65532),
+								FSGroup:      int64Ptr(65532),
+								SeccompProfile: &corev1.SeccompProfile{
+									Type: corev1.SeccompProfileTypeRuntimeDefault,
+								},
+							},
+							Containers: []corev1.Container{
+								{
+									Name:            "restic",
+									Image:           image,
+									ImagePullPolicy: corev1.PullIfNotPresent,
+									Command:         []string{"/bin/sh", "-c"},
+									Args:            []string{buildCacheCleanupScript(repository.Spec.Cache)},
+									SecurityContext: &corev1.SecurityContext{
+										AllowPrivilegeEscalation: boolPtr(false),
+										ReadOnlyRootFilesystem:   boolPtr(false),
+										RunAsNonRoot:             boolPtr(true),
+										Capabilities: &corev1.Capabilities{
+											Drop: []corev1.Capability{"ALL"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	applyRepositoryCache(&cronJob.Spec.JobTemplate.Spec.Template.Spec, repository, repository.Namespace)
+
+	return cronJob
+}
+
+// parseCacheSize parses the cache size in KiB reported by the cache cleanup container.
+func parseCacheSize(message string) (string, error) {
+	kib, err := strconv.ParseUint(strings.TrimSpace(message), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid cache size %q: %w", message, err)
+	}
+	return formatBytes(kib * 1024), nil
+}
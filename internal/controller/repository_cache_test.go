@@ -0,0 +1,156 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Repository cache", func() {
+	var (
+		reconciler *ResticRepositoryReconciler
+		repository *backupv1alpha1.ResticRepository
+	)
+
+	BeforeEach(func() {
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "s3", Namespace: "backup", UID: "repo-uid"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				Cache: &backupv1alpha1.CacheConfig{Enabled: true, Size: "2Gi", StorageClassName: "fast"},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		reconciler = &ResticRepositoryReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(20),
+		}
+	}
+
+	Context("reconcileCache", func() {
+		It("should create the cache PVC and cleanup CronJob", func() {
+			newReconciler()
+			Expect(reconciler.reconcileCache(context.Background(), repository)).To(Succeed())
+			Expect(repository.Status.Cache.PVCName).To(Equal("restic-cache-s3"))
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "restic-cache-s3", Namespace: "backup"}, pvc)).To(Succeed())
+			Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("2Gi"))
+			Expect(*pvc.Spec.StorageClassName).To(Equal("fast"))
+			Expect(pvc.Spec.AccessModes).To(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}))
+			Expect(pvc.OwnerReferences).To(ConsistOf(HaveField("Name", "s3")))
+
+			cronJob := &batchv1.CronJob{}
+			Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "restic-cache-cleanup-s3", Namespace: "backup"}, cronJob)).To(Succeed())
+			Expect(cronJob.Spec.Schedule).To(Equal("@daily"))
+			container := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal(DefaultResticImage))
+			Expect(container.Args[0]).To(ContainSubstring("'cache' '--cleanup' '--max-age' '30'"))
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "RESTIC_CACHE_DIR", Value: "/cache"}))
+		})
+
+		It("should keep a cache PVC with another access mode", func() {
+			repository.Spec.Cache.AccessMode = corev1.ReadWriteMany
+			existing, err := buildCachePVC(repository)
+			Expect(err).NotTo(HaveOccurred())
+			existing.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+			newReconciler(existing)
+
+			Expect(reconciler.reconcileCache(context.Background(), repository)).To(Succeed())
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "restic-cache-s3", Namespace: "backup"}, pvc)).To(Succeed())
+			Expect(pvc.Spec.AccessModes).To(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}))
+			Expect(reconciler.Recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("CachePVCAccessModeMismatch")))
+		})
+
+		It("should record the cache size of the last cleanup", func() {
+			finishedAt := time.Now().Truncate(time.Second)
+			job := finishedJob("restic-cache-cleanup-s3-1", true, finishedAt)
+			job.Namespace = "backup"
+			job.Labels = map[string]string{resticCacheCleanupLabel: "s3"}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "restic-cache-cleanup-s3-1-abcde",
+					Namespace: "backup",
+					Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+				},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "restic",
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: "2048\n"}},
+					}},
+				},
+			}
+			newReconciler(&job, pod)
+
+			Expect(reconciler.reconcileCache(context.Background(), repository)).To(Succeed())
+			Expect(repository.Status.Cache.Size).To(Equal("2.0 MiB"))
+			Expect(repository.Status.Cache.LastCleanupJob).To(Equal(job.Name))
+			Expect(repository.Status.Cache.LastCleanup.Time).To(BeTemporally("==", finishedAt))
+		})
+
+		It("should delete the cache when it is disabled", func() {
+			newReconciler()
+			Expect(reconciler.reconcileCache(context.Background(), repository)).To(Succeed())
+
+			repository.Spec.Cache.Enabled = false
+			Expect(reconciler.reconcileCache(context.Background(), repository)).To(Succeed())
+			Expect(repository.Status.Cache).To(BeNil())
+
+			err := reconciler.Get(context.Background(), types.NamespacedName{Name: "restic-cache-s3", Namespace: "backup"}, &corev1.PersistentVolumeClaim{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			err = reconciler.Get(context.Background(), types.NamespacedName{Name: "restic-cache-cleanup-s3", Namespace: "backup"}, &batchv1.CronJob{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("applyRepositoryCache helper function", func() {
+		It("should mount the cache only into pods in the repository namespace", func() {
+			podSpec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "restic"}, {Name: "other"}}}
+			applyRepositoryCache(podSpec, repository, "backup")
+			Expect(podSpec.Volumes).To(ConsistOf(HaveField("PersistentVolumeClaim.ClaimName", "restic-cache-s3")))
+			Expect(podSpec.Containers[0].VolumeMounts).To(ConsistOf(HaveField("MountPath", "/cache")))
+			Expect(podSpec.Containers[1].VolumeMounts).To(BeEmpty())
+
+			podSpec = &corev1.PodSpec{Containers: []corev1.Container{{Name: "restic"}}}
+			applyRepositoryCache(podSpec, repository, "media")
+			Expect(podSpec.Volumes).To(BeEmpty())
+			Expect(podSpec.Containers[0].Env).To(BeEmpty())
+		})
+	})
+})
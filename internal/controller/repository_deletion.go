@@ -0,0 +1,487 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	resticRepositoryFinalizer = "backup.resticbackup.io/resticrepository-finalizer"
+	// resticRepositoryWipeLabel is set on the Job deleting the data of a repository.
+	resticRepositoryWipeLabel = "backup.resticbackup.io/repository-wipe"
+	// deletionPolicyDelete deletes the repository data with the ResticRepository.
+	deletionPolicyDelete = "Delete"
+)
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrepositories/finalizers,verbs=update
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=globalretentionpolicies;resticchecks;resticprunes;resticreplications;clusterbackuppolicies;resticrestores;backupverifications;namespacerestores,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+
+// handleDeletion blocks the deletion of a repository while other resources reference it
+// and deletes the repository data with deletionPolicy Delete before removing the finalizer.
+func (r *ResticRepositoryReconciler) handleDeletion(ctx context.Context, repository *backupv1alpha1.ResticRepository) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(repository, resticRepositoryFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	references, err := repositoryReferences(ctx, r.Client, repository, r.AnnotatedPVCRepository)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(references) > 0 {
+		message := fmt.Sprintf("Repository is referenced by %s", strings.Join(references, ", "))
+		log.Info("Deletion blocked", "references", references)
+		if !conditions.IsConditionTrue(repository.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked) {
+			r.Recorder.Event(repository, corev1.EventTypeWarning, "DeletionBlocked", message)
+		}
+		r.setCondition(repository, conditions.NewCondition(backupv1alpha1.ConditionDeletionBlocked, metav1.ConditionTrue, "RepositoryInUse", message))
+		if err := r.Status().Update(ctx, repository); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+	}
+
+	if repository.Spec.DeletionPolicy == deletionPolicyDelete {
+		// The data of a backend shared with other repositories is deleted too
+		if repository.Spec.DeletionConfirmation != repository.Spec.RepositoryURL {
+			message := "Set deletionConfirmation to the repositoryURL to delete the repository data, or deletionPolicy to Retain to keep it"
+			if condition := conditions.GetCondition(repository.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked); condition == nil || condition.Reason != "DeletionNotConfirmed" {
+				r.Recorder.Event(repository, corev1.EventTypeWarning, "DeletionNotConfirmed", message)
+			}
+			r.setCondition(repository, conditions.NewCondition(backupv1alpha1.ConditionDeletionBlocked, metav1.ConditionTrue, "DeletionNotConfirmed", message))
+			return ctrl.Result{}, r.Status().Update(ctx, repository)
+		}
+		// Other ResticRepositories of the same backend would lose their snapshots too
+		shared, err := sharedRepositories(ctx, r.Client, repository)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if len(shared) > 0 {
+			message := fmt.Sprintf("Repository data is shared with ResticRepository %s, set deletionPolicy to Retain to keep it", strings.Join(shared, ", "))
+			if condition := conditions.GetCondition(repository.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked); condition == nil || condition.Reason != "RepositoryShared" {
+				r.Recorder.Event(repository, corev1.EventTypeWarning, "RepositoryShared", message)
+			}
+			r.setCondition(repository, conditions.NewCondition(backupv1alpha1.ConditionDeletionBlocked, metav1.ConditionTrue, "RepositoryShared", message))
+			if err := r.Status().Update(ctx, repository); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+		}
+		deleted, err := r.deleteRepositoryData(ctx, repository)
+		if err != nil || !deleted {
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+		}
+	}
+
+	log.Info("Performing finalizer cleanup for ResticRepository")
+	controllerutil.RemoveFinalizer(repository, resticRepositoryFinalizer)
+	if err := r.Update(ctx, repository); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// deleteRepositoryData runs the Job forgetting all snapshots and pruning the repository.
+// It reports whether the Job succeeded.
+func (r *ResticRepositoryReconciler) deleteRepositoryData(ctx context.Context, repository *backupv1alpha1.ResticRepository) (bool, error) {
+	job := &batchv1.Job{}
+	name := fmt.Sprintf("resticrepository-wipe-%s", repository.Name)
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: repository.Namespace}, job)
+	if apierrors.IsNotFound(err) {
+		job = r.buildWipeJob(repository, name)
+		if err := controllerutil.SetControllerReference(repository, job, r.Scheme); err != nil {
+			return false, fmt.Errorf("failed to set owner reference: %w", err)
+		}
+		desired := job
+		job = &batchv1.Job{}
+		created, err := createOrAdopt(ctx, r.Client, repository, desired, job)
+		if err != nil {
+			return false, fmt.Errorf("failed to create wipe job: %w", err)
+		}
+		if created {
+			message := fmt.Sprintf("Job %s deletes the repository data", desired.Name)
+			r.Recorder.Event(repository, corev1.EventTypeNormal, "DeletingRepositoryData", message)
+			r.setCondition(repository, conditions.NewCondition(backupv1alpha1.ConditionDeletionBlocked, metav1.ConditionTrue, "DeletingRepositoryData", message))
+			return false, r.Status().Update(ctx, repository)
+		}
+	} else if err != nil {
+		return false, fmt.Errorf("failed to get wipe job: %w", err)
+	}
+
+	finished, succeeded, _ := jobFinished(job)
+	if !finished {
+		return false, nil
+	}
+	if !succeeded {
+		message := fmt.Sprintf("Job %s failed to delete the repository data, set deletionPolicy to Retain to delete the ResticRepository without its data", job.Name)
+		if condition := conditions.GetCondition(repository.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked); condition == nil || condition.Reason != "DeletionFailed" {
+			r.Recorder.Event(repository, corev1.EventTypeWarning, "DeletionFailed", message)
+		}
+		r.setCondition(repository, conditions.NewCondition(backupv1alpha1.ConditionDeletionBlocked, metav1.ConditionTrue, "DeletionFailed", message))
+		return false, r.Status().Update(ctx, repository)
+	}
+	return true, nil
+}
+
+// repositoryReferences returns the resources referencing the repository as kind namespace/name.
+// annotatedPVCRepository is the repository of annotated PVCs without repository annotation,
+// empty if the ResticBackups of annotated PVCs are disabled.
+func repositoryReferences(ctx context.Context, c client.Client, repository *backupv1alpha1.ResticRepository, annotatedPVCRepository string) ([]string, error) {
+	var references []string
+
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := c.List(ctx, backups); err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	// Restores and verifications access the repository through these backups
+	usingBackups := map[types.NamespacedName]*backupv1alpha1.ResticBackup{}
+	for i, backup := range backups.Items {
+		fallback := backup.Spec.FallbackRepositoryRef
+		if referencesRepository(backup.Spec.RepositoryRef, backup.Namespace, repository) ||
+			(fallback != nil && referencesRepository(*fallback, backup.Namespace, repository)) {
+			references = append(references, "ResticBackup "+backup.Namespace+"/"+backup.Name)
+			usingBackups[types.NamespacedName{Namespace: backup.Namespace, Name: backup.Name}] = &backups.Items[i]
+		}
+	}
+
+	checks := &backupv1alpha1.ResticCheckList{}
+	if err := c.List(ctx, checks); err != nil {
+		return nil, fmt.Errorf("failed to list checks: %w", err)
+	}
+	for _, check := range checks.Items {
+		if referencesRepository(check.Spec.RepositoryRef, check.Namespace, repository) {
+			references = append(references, "ResticCheck "+check.Namespace+"/"+check.Name)
+		}
+	}
+
+	replications := &backupv1alpha1.ResticReplicationList{}
+	if err := c.List(ctx, replications); err != nil {
+		return nil, fmt.Errorf("failed to list replications: %w", err)
+	}
+	for _, replication := range replications.Items {
+		if referencesRepository(replication.Spec.SourceRepositoryRef, replication.Namespace, repository) ||
+			referencesRepository(replication.Spec.DestinationRepositoryRef, replication.Namespace, repository) {
+			references = append(references, "ResticReplication "+replication.Namespace+"/"+replication.Name)
+		}
+	}
+
+	prunes := &backupv1alpha1.ResticPruneList{}
+	if err := c.List(ctx, prunes); err != nil {
+		return nil, fmt.Errorf("failed to list prunes: %w", err)
+	}
+	for _, prune := range prunes.Items {
+		// Prunes requested by retention are garbage collected with the repository
+		if referencesRepository(prune.Spec.RepositoryRef, prune.Namespace, repository) && !metav1.IsControlledBy(&prune, repository) {
+			references = append(references, "ResticPrune "+prune.Namespace+"/"+prune.Name)
+		}
+	}
+
+	policies := &backupv1alpha1.GlobalRetentionPolicyList{}
+	if err := c.List(ctx, policies); err != nil {
+		return nil, fmt.Errorf("failed to list retention policies: %w", err)
+	}
+	for _, policy := range policies.Items {
+		if referencesRepository(policy.Spec.RepositoryRef, policy.Namespace, repository) {
+			references = append(references, "GlobalRetentionPolicy "+policy.Namespace+"/"+policy.Name)
+		}
+	}
+
+	backupReferences, err := backupUserReferences(ctx, c, usingBackups)
+	if err != nil {
+		return nil, err
+	}
+	references = append(references, backupReferences...)
+
+	// Cluster backup policies and annotated PVCs recreate the ResticBackups of their PVCs
+	clusterPolicies, err := clusterPolicyReferences(ctx, c, repository)
+	if err != nil {
+		return nil, err
+	}
+	references = append(references, clusterPolicies...)
+
+	if annotatedPVCRepository != "" {
+		pvcs := &corev1.PersistentVolumeClaimList{}
+		if err := c.List(ctx, pvcs); err != nil {
+			return nil, fmt.Errorf("failed to list PVCs: %w", err)
+		}
+		for _, pvc := range pvcs.Items {
+			if backupEnabled(&pvc) && pvc.DeletionTimestamp.IsZero() &&
+				annotatedPVCReferencesRepository(&pvc, annotatedPVCRepository, repository) {
+				references = append(references, "PersistentVolumeClaim "+pvc.Namespace+"/"+pvc.Name)
+			}
+		}
+	}
+
+	return references, nil
+}
+
+// backupUserReferences returns the unfinished ResticRestores and NamespaceRestores and the
+// BackupVerifications using one of the backups.
+func backupUserReferences(ctx context.Context, c client.Client, backups map[types.NamespacedName]*backupv1alpha1.ResticBackup) ([]string, error) {
+	if len(backups) == 0 {
+		return nil, nil
+	}
+	var references []string
+
+	restores := &backupv1alpha1.ResticRestoreList{}
+	if err := c.List(ctx, restores); err != nil {
+		return nil, fmt.Errorf("failed to list restores: %w", err)
+	}
+	for _, restore := range restores.Items {
+		namespace := restore.Spec.BackupRef.Namespace
+		if namespace == "" {
+			namespace = restore.Namespace
+		}
+		if !isFinishedRestorePhase(restore.Status.Phase) && backups[types.NamespacedName{Namespace: namespace, Name: restore.Spec.BackupRef.Name}] != nil {
+			references = append(references, "ResticRestore "+restore.Namespace+"/"+restore.Name)
+		}
+	}
+
+	verifications := &backupv1alpha1.BackupVerificationList{}
+	if err := c.List(ctx, verifications); err != nil {
+		return nil, fmt.Errorf("failed to list backup verifications: %w", err)
+	}
+	for _, verification := range verifications.Items {
+		if backups[types.NamespacedName{Namespace: verification.Namespace, Name: verification.Spec.BackupName}] != nil {
+			references = append(references, "BackupVerification "+verification.Namespace+"/"+verification.Name)
+		}
+	}
+
+	nsRestores := &backupv1alpha1.NamespaceRestoreList{}
+	if err := c.List(ctx, nsRestores); err != nil {
+		return nil, fmt.Errorf("failed to list namespace restores: %w", err)
+	}
+	for i := range nsRestores.Items {
+		if namespaceRestoreUsesBackups(&nsRestores.Items[i], backups) {
+			references = append(references, "NamespaceRestore "+nsRestores.Items[i].Namespace+"/"+nsRestores.Items[i].Name)
+		}
+	}
+	return references, nil
+}
+
+// namespaceRestoreUsesBackups reports whether an unfinished NamespaceRestore restores or
+// may select one of the backups.
+func namespaceRestoreUsesBackups(nsRestore *backupv1alpha1.NamespaceRestore, backups map[types.NamespacedName]*backupv1alpha1.ResticBackup) bool {
+	source := namespaceRestoreSource(nsRestore)
+	switch nsRestore.Status.Phase {
+	case backupv1alpha1.NamespaceRestorePhaseInProgress:
+		for _, entry := range nsRestore.Status.Restores {
+			if backups[types.NamespacedName{Namespace: source, Name: entry.Backup}] != nil {
+				return true
+			}
+		}
+		return false
+	case "", backupv1alpha1.NamespaceRestorePhasePending:
+		selector := labels.Everything()
+		if nsRestore.Spec.BackupSelector != nil {
+			var err error
+			if selector, err = metav1.LabelSelectorAsSelector(nsRestore.Spec.BackupSelector); err != nil {
+				return false
+			}
+		}
+		for key, backup := range backups {
+			if key.Namespace == source && selector.Matches(labels.Set(backup.Labels)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sharedRepositories returns the other ResticRepositories with the same repository URL
+// as namespace/name.
+func sharedRepositories(ctx context.Context, c client.Client, repository *backupv1alpha1.ResticRepository) ([]string, error) {
+	repositories := &backupv1alpha1.ResticRepositoryList{}
+	if err := c.List(ctx, repositories); err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+	url := strings.TrimSuffix(repository.Spec.RepositoryURL, "/")
+	var shared []string
+	for _, other := range repositories.Items {
+		if other.Namespace == repository.Namespace && other.Name == repository.Name {
+			continue
+		}
+		if strings.TrimSuffix(other.Spec.RepositoryURL, "/") == url {
+			shared = append(shared, other.Namespace+"/"+other.Name)
+		}
+	}
+	return shared, nil
+}
+
+// clusterPolicyReferences returns the ClusterBackupPolicies referencing the repository.
+// A reference without namespace points to the repository of that name in every
+// namespace selected by the policy.
+func clusterPolicyReferences(ctx context.Context, c client.Client, repository *backupv1alpha1.ResticRepository) ([]string, error) {
+	policies := &backupv1alpha1.ClusterBackupPolicyList{}
+	if err := c.List(ctx, policies); err != nil {
+		return nil, fmt.Errorf("failed to list cluster backup policies: %w", err)
+	}
+	if len(policies.Items) == 0 {
+		return nil, nil
+	}
+	namespace := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: repository.Namespace}, namespace); client.IgnoreNotFound(err) != nil {
+		return nil, fmt.Errorf("failed to get namespace %s: %w", repository.Namespace, err)
+	}
+
+	var references []string
+	for _, policy := range policies.Items {
+		refs := []backupv1alpha1.CrossNamespaceObjectReference{policy.Spec.Template.Spec.RepositoryRef}
+		if fallback := policy.Spec.Template.Spec.FallbackRepositoryRef; fallback != nil {
+			refs = append(refs, *fallback)
+		}
+		for _, ref := range refs {
+			if ref.Name != repository.Name {
+				continue
+			}
+			if ref.Namespace == "" {
+				selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.NamespaceSelector)
+				if err != nil || !selector.Matches(labels.Set(namespace.Labels)) {
+					continue
+				}
+			} else if ref.Namespace != repository.Namespace {
+				continue
+			}
+			references = append(references, "ClusterBackupPolicy "+policy.Name)
+			break
+		}
+	}
+	return references, nil
+}
+
+// annotatedPVCReferencesRepository reports whether the ResticBackup of an annotated PVC
+// uses the repository, named by the repository annotation or the default repository.
+func annotatedPVCReferencesRepository(pvc *corev1.PersistentVolumeClaim, defaultRepository string, repository *backupv1alpha1.ResticRepository) bool {
+	value := pvc.Annotations[backupRepositoryAnnotation]
+	if value == "" {
+		value = defaultRepository
+	}
+	namespace, name, found := strings.Cut(value, "/")
+	if !found {
+		namespace, name = pvc.Namespace, value
+	}
+	return name == repository.Name && namespace == repository.Namespace
+}
+
+// referencesRepository reports whether a reference of a resource in namespace points to the repository.
+func referencesRepository(ref backupv1alpha1.CrossNamespaceObjectReference, namespace string, repository *backupv1alpha1.ResticRepository) bool {
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+	return ref.Name == repository.Name && namespace == repository.Namespace
+}
+
+// buildWipeScript builds the shell script forgetting all snapshots and pruning the
+// repository. Options are the extended options of the repository backend.
+func buildWipeScript(options []string) string {
+	snapshots := restic.NewCommand("snapshots").WithArgs(options).WithJSON()
+	forget := restic.NewCommand("forget").WithArgs(options)
+	prune := restic.NewCommand("prune").WithArgs(options)
+
+	commands := []string{
+		"set -eo pipefail",
+		fmt.Sprintf(`ids=$(restic %s | grep -o '"id":"[0-9a-f]*"' | cut -d'"' -f4)`, shellQuoteArgs(snapshots.Build())),
+		fmt.Sprintf(`if [ -n "$ids" ]; then restic %s $ids; fi`, shellQuoteArgs(forget.Build())),
+		fmt.Sprintf("restic %s", shellQuoteArgs(prune.Build())),
+	}
+
+	return strings.Join(commands, "\n")
+}
+
+// buildWipeJob builds the Job deleting the data of the repository.
+func (r *ResticRepositoryReconciler) buildWipeJob(repository *backupv1alpha1.ResticRepository, name string) *batchv1.Job {
+	backoffLimit := r.JobDefaults.BackoffLimit(JobOperationPrune, nil)
+	activeDeadline := r.JobDefaults.ActiveDeadlineSeconds(JobOperationPrune, nil)
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "restic-backup-operator",
+		"app.kubernetes.io/component": "repository-wipe",
+		resticRepositoryWipeLabel:     repository.Name,
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: repository.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &activeDeadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot: boolPtr(true),
+						RunAsUser:    int64Ptr(65532),
+						FSGroup:      int64Ptr(65532),
+						SeccompProfile: &corev1.SeccompProfile{
+							Type: corev1.SeccompProfileTypeRuntimeDefault,
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:            "restic",
+							Image:           r.Images.Resolve("", nil),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Command:         []string{"/bin/sh", "-c"},
+							Args:            []string{buildWipeScript(repositoryOptions(repository))},
+							Env:             repositoryEnvVars(repository),
+							SecurityContext: &corev1.SecurityContext{
+								AllowPrivilegeEscalation: boolPtr(false),
+								ReadOnlyRootFilesystem:   boolPtr(false),
+								RunAsNonRoot:             boolPtr(true),
+								Capabilities: &corev1.Capabilities{
+									Drop: []corev1.Capability{"ALL"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	applyRepositoryCredentials(&job.Spec.Template.Spec, repository)
+
+	return job
+}
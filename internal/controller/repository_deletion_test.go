@@ -0,0 +1,277 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("ResticRepository deletion", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		reconciler *ResticRepositoryReconciler
+		repository *backupv1alpha1.ResticRepository
+		key        types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		now := metav1.Now()
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "nas",
+				Namespace:         "backup",
+				Finalizers:        []string{resticRepositoryFinalizer},
+				DeletionTimestamp: &now,
+			},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		key = client.ObjectKeyFromObject(repository)
+	})
+
+	newReconciler := func(objects ...client.Object) {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		c = fake.NewClientBuilder().WithScheme(testScheme).
+			WithObjects(append(objects, repository)...).
+			WithStatusSubresource(&backupv1alpha1.ResticRepository{}, &batchv1.Job{}).
+			Build()
+		reconciler = &ResticRepositoryReconciler{
+			Client:   c,
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+		}
+	}
+
+	reconcile := func() ctrl.Result {
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+
+	It("should block the deletion while resources reference the repository", func() {
+		backup := &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef:         backupv1alpha1.CrossNamespaceObjectReference{Name: "s3", Namespace: "backup"},
+				FallbackRepositoryRef: &backupv1alpha1.CrossNamespaceObjectReference{Name: "nas", Namespace: "backup"},
+			},
+		}
+		check := &backupv1alpha1.ResticCheck{
+			ObjectMeta: metav1.ObjectMeta{Name: "weekly", Namespace: "backup"},
+			Spec:       backupv1alpha1.ResticCheckSpec{RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas"}},
+		}
+		newReconciler(backup, check)
+
+		Expect(reconcile().RequeueAfter).To(Equal(errorRequeueInterval))
+
+		updated := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(ctx, key, updated)).To(Succeed())
+		condition := conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal("RepositoryInUse"))
+		Expect(condition.Message).To(Equal("Repository is referenced by ResticBackup media/db, ResticCheck backup/weekly"))
+	})
+
+	It("should block the deletion while policies and annotated PVCs recreate backups", func() {
+		prune := &backupv1alpha1.ResticPrune{
+			ObjectMeta: metav1.ObjectMeta{Name: "monthly", Namespace: "backup"},
+			Spec:       backupv1alpha1.ResticPruneSpec{RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas"}},
+		}
+		policy := &backupv1alpha1.ClusterBackupPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "databases"},
+			Spec: backupv1alpha1.ClusterBackupPolicySpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"backup": "enabled"}},
+				Template: backupv1alpha1.ClusterBackupTemplate{Spec: backupv1alpha1.ClusterBackupTemplateSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas"},
+				}},
+			},
+		}
+		otherPolicy := policy.DeepCopy()
+		otherPolicy.Name = "media"
+		otherPolicy.Spec.NamespaceSelector = metav1.LabelSelector{MatchLabels: map[string]string{"tier": "media"}}
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "backup", Labels: map[string]string{"backup": "enabled"}}}
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Name:        "data",
+			Namespace:   "shop",
+			Annotations: map[string]string{backupEnabledAnnotation: "true", backupRepositoryAnnotation: "backup/nas"},
+		}}
+		newReconciler(prune, policy, otherPolicy, namespace, pvc)
+		reconciler.AnnotatedPVCRepository = DefaultAnnotatedPVCRepository
+
+		Expect(reconcile().RequeueAfter).To(Equal(errorRequeueInterval))
+
+		updated := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(ctx, key, updated)).To(Succeed())
+		condition := conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Message).To(Equal("Repository is referenced by ResticPrune backup/monthly, ClusterBackupPolicy databases, PersistentVolumeClaim shop/data"))
+	})
+
+	It("should block the deletion while restores and verifications use its backups", func() {
+		backup := &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media", Labels: map[string]string{"tier": "db"}},
+			Spec:       backupv1alpha1.ResticBackupSpec{RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas", Namespace: "backup"}},
+		}
+		restore := &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-restore", Namespace: "media"},
+			Spec:       backupv1alpha1.ResticRestoreSpec{BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "db"}},
+		}
+		finished := &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "old-restore", Namespace: "media"},
+			Spec:       backupv1alpha1.ResticRestoreSpec{BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "db"}},
+			Status:     backupv1alpha1.ResticRestoreStatus{Phase: backupv1alpha1.RestorePhaseCompleted},
+		}
+		verification := &backupv1alpha1.BackupVerification{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-weekly", Namespace: "media"},
+			Spec:       backupv1alpha1.BackupVerificationSpec{BackupName: "db"},
+		}
+		nsRestore := &backupv1alpha1.NamespaceRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "media-dr", Namespace: "media-dr"},
+			Spec: backupv1alpha1.NamespaceRestoreSpec{
+				SourceNamespace: "media",
+				BackupSelector:  &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "db"}},
+			},
+		}
+		newReconciler(backup, restore, finished, verification, nsRestore)
+
+		Expect(reconcile().RequeueAfter).To(Equal(errorRequeueInterval))
+
+		updated := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(ctx, key, updated)).To(Succeed())
+		condition := conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Message).To(Equal("Repository is referenced by ResticBackup media/db, ResticRestore media/db-restore, " +
+			"BackupVerification media/db-weekly, NamespaceRestore media-dr/media-dr"))
+	})
+
+	It("should retain the repository data by default", func() {
+		newReconciler()
+
+		reconcile()
+
+		Expect(apierrors.IsNotFound(c.Get(ctx, key, &backupv1alpha1.ResticRepository{}))).To(BeTrue())
+		jobs := &batchv1.JobList{}
+		Expect(c.List(ctx, jobs)).To(Succeed())
+		Expect(jobs.Items).To(BeEmpty())
+	})
+
+	It("should not delete the repository data without confirmation", func() {
+		repository.Spec.DeletionPolicy = deletionPolicyDelete
+		repository.Spec.DeletionConfirmation = "s3:s3.amazonaws.com/other-bucket"
+		newReconciler()
+
+		reconcile()
+
+		updated := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(ctx, key, updated)).To(Succeed())
+		condition := conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal("DeletionNotConfirmed"))
+		jobs := &batchv1.JobList{}
+		Expect(c.List(ctx, jobs)).To(Succeed())
+		Expect(jobs.Items).To(BeEmpty())
+	})
+
+	It("should delete the repository data before removing the finalizer", func() {
+		repository.Spec.DeletionPolicy = deletionPolicyDelete
+		repository.Spec.DeletionConfirmation = repository.Spec.RepositoryURL
+		newReconciler()
+
+		reconcile()
+
+		job := &batchv1.Job{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "resticrepository-wipe-nas", Namespace: "backup"}, job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.Containers[0].Args[0]).To(ContainSubstring("'prune'"))
+		Expect(c.Get(ctx, key, &backupv1alpha1.ResticRepository{})).To(Succeed())
+
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(c.Status().Update(ctx, job)).To(Succeed())
+		reconcile()
+
+		Expect(apierrors.IsNotFound(c.Get(ctx, key, &backupv1alpha1.ResticRepository{}))).To(BeTrue())
+	})
+
+	It("should not delete the data of a backend shared with another repository", func() {
+		repository.Spec.DeletionPolicy = deletionPolicyDelete
+		repository.Spec.DeletionConfirmation = repository.Spec.RepositoryURL
+		other := &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "nas", Namespace: "media"},
+			Spec:       backupv1alpha1.ResticRepositorySpec{RepositoryURL: repository.Spec.RepositoryURL + "/"},
+		}
+		newReconciler(other)
+
+		Expect(reconcile().RequeueAfter).To(Equal(errorRequeueInterval))
+
+		updated := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(ctx, key, updated)).To(Succeed())
+		condition := conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal("RepositoryShared"))
+		Expect(condition.Message).To(ContainSubstring("media/nas"))
+		jobs := &batchv1.JobList{}
+		Expect(c.List(ctx, jobs)).To(Succeed())
+		Expect(jobs.Items).To(BeEmpty())
+	})
+
+	It("should keep the finalizer if deleting the data failed", func() {
+		repository.Spec.DeletionPolicy = deletionPolicyDelete
+		repository.Spec.DeletionConfirmation = repository.Spec.RepositoryURL
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "resticrepository-wipe-nas", Namespace: "backup"},
+			Status:     batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}},
+		}
+		newReconciler(job)
+
+		reconcile()
+
+		updated := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(ctx, key, updated)).To(Succeed())
+		condition := conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionDeletionBlocked)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal("DeletionFailed"))
+	})
+
+	It("should forget all snapshots before pruning", func() {
+		script := buildWipeScript(nil)
+		Expect(script).To(ContainSubstring(`ids=$(restic 'snapshots' '--json' | grep -o '"id":"[0-9a-f]*"' | cut -d'"' -f4)`))
+		Expect(script).To(ContainSubstring(`if [ -n "$ids" ]; then restic 'forget' $ids; fi`))
+		Expect(script).To(HaveSuffix("restic 'prune'"))
+	})
+})
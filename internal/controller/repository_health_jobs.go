@@ -0,0 +1,397 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// repositoryHealthLabel links the check Jobs and the stats and probe Jobs of the Job
+	// check strategy to the ResticRepository.
+	repositoryHealthLabel = "backup.resticbackup.io/repository-health"
+	// readDataSubsetAnnotation records the data subset read by a check Job.
+	readDataSubsetAnnotation = "backup.resticbackup.io/read-data-subset"
+
+	healthJobCheck = "check"
+	healthJobStats = "stats"
+	healthJobProbe = "probe"
+)
+
+func repositoryHealthJobName(repository *backupv1alpha1.ResticRepository, operation string) string {
+	return fmt.Sprintf("restic-%s-%s", operation, repository.Name)
+}
+
+// reconcileStatsJob records the statistics gathered by a finished stats Job of the Job
+// check strategy and starts a new one when the statistics are due. Like the check Job,
+// a finished Job is evaluated from its termination message and deleted.
+func (r *ResticRepositoryReconciler) reconcileStatsJob(ctx context.Context, repository *backupv1alpha1.ResticRepository) error {
+	job, err := r.getHealthJob(ctx, repository, healthJobStats)
+	if err != nil {
+		return err
+	}
+
+	if job == nil {
+		if !statisticsDue(repository) {
+			return nil
+		}
+		return r.createHealthJob(ctx, repository, healthJobStats, buildRepositoryStatsScript(repositoryOptions(repository)), nil)
+	}
+
+	finished, succeeded, finishedAt := jobFinished(job)
+	if !finished {
+		return nil
+	}
+	message, err := jobTerminationMessage(ctx, r.apiReader(), job)
+	if err != nil {
+		// The pod may be gone already, gather the statistics again
+		log.FromContext(ctx).Error(err, "Failed to read the statistics", "job", job.Name)
+		return r.deleteHealthJob(ctx, job)
+	}
+	if succeeded {
+		stats, err := restic.ParseStatsOutput(message)
+		if err != nil {
+			return err
+		}
+		setRepositoryStatistics(repository, stats, finishedAt)
+		if err := r.Status().Update(ctx, repository); err != nil {
+			return fmt.Errorf("failed to update status with statistics: %w", err)
+		}
+	} else {
+		r.Recorder.Event(repository, corev1.EventTypeWarning, "StatisticsFailed",
+			fmt.Sprintf("Statistics job %s failed: %s", job.Name, strings.TrimSpace(message)))
+	}
+	return r.deleteHealthJob(ctx, job)
+}
+
+// reconcileCheckJob records the result of a finished check Job and starts a new one
+// when the scheduled integrity check is due, so the operator never runs restic check
+// itself. With ReadDataSubsets set, every run reads the subset following the one
+// recorded in the status. It returns the time of the next integrity check.
+func (r *ResticRepositoryReconciler) reconcileCheckJob(ctx context.Context, repository *backupv1alpha1.ResticRepository) (*time.Time, error) {
+	schedule, err := integrityCheckSchedule(repository)
+	if schedule == nil || err != nil {
+		return nil, err
+	}
+
+	job, err := r.getHealthJob(ctx, repository, healthJobCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	if job != nil {
+		finished, succeeded, _ := jobFinished(job)
+		if !finished {
+			return nil, nil
+		}
+		var checkErr error
+		reason := "CheckFailed"
+		if !succeeded {
+			message, err := jobTerminationMessage(ctx, r.apiReader(), job)
+			if err != nil {
+				log.FromContext(ctx).Error(err, "Failed to read the check errors", "job", job.Name)
+			}
+			var summary string
+			reason, summary = checkFailure(job.Name, message)
+			checkErr = &restic.CommandError{Err: errors.New(summary), Stderr: message}
+		}
+		subset, _ := strconv.ParseInt(job.Annotations[readDataSubsetAnnotation], 10, 32)
+		r.setIntegrityCheckResult(repository, job.CreationTimestamp.Time, int32(subset), reason, checkErr)
+		if err := r.Status().Update(ctx, repository); err != nil {
+			return nil, fmt.Errorf("failed to update integrity check status: %w", err)
+		}
+		if err := r.deleteHealthJob(ctx, job); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if last := repository.Status.LastIntegrityCheck; last != nil {
+		if next := schedule.Next(last.Time); now.Before(next) {
+			return &next, nil
+		}
+	}
+	if job != nil {
+		// The deleted Job may still exist until its deletion completes
+		return nil, nil
+	}
+
+	check := repository.Spec.IntegrityCheck
+	subset := nextDataSubset(check, repository.Status.LastIntegrityCheckSubset)
+	readDataSubset := ""
+	if subset > 0 {
+		readDataSubset = fmt.Sprintf("%d/%d", subset, *check.ReadDataSubsets)
+	}
+	log.FromContext(ctx).Info("Starting scheduled integrity check job", "readDataSubset", readDataSubset)
+	annotations := map[string]string{readDataSubsetAnnotation: strconv.Itoa(int(subset))}
+	script := buildRepositoryCheckScript(readDataSubset, repositoryOptions(repository))
+	if err := r.createHealthJob(ctx, repository, healthJobCheck, script, annotations); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// probedByJob reports whether the credentials probe of the repository runs in a Job.
+// The operator image has no SSH client, so SFTP repositories can't be probed in the
+// operator.
+func probedByJob(repository *backupv1alpha1.ResticRepository) bool {
+	return repositoryScheme(repository) == "sftp"
+}
+
+// reconcileProbeJob probes a repository the operator can't access itself in a Job. It
+// returns the config read by a finished probe Job, or the result to return with while
+// no probe result is available or the probe failed. Both are nil while the last
+// successful probe is recent.
+func (r *ResticRepositoryReconciler) reconcileProbeJob(ctx context.Context, repository *backupv1alpha1.ResticRepository) (*restic.RepositoryConfig, *ctrl.Result, error) {
+	job, err := r.getHealthJob(ctx, repository, healthJobProbe)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if job == nil {
+		now := time.Now()
+		if !credentialsProbeDue(repository, now) {
+			return nil, nil, nil
+		}
+		// Failed probes are retried after errorRequeueInterval, not on every reconcile
+		if failure := repository.Status.LastFailure; failure != nil && failure.Operation == repositoryOperationProbe &&
+			now.Sub(failure.Time.Time) < errorRequeueInterval {
+			return nil, &ctrl.Result{RequeueAfter: errorRequeueInterval - now.Sub(failure.Time.Time)}, nil
+		}
+		script := buildRepositoryProbeScript(expectedRepositoryID(repository) == "", repositoryOptions(repository))
+		if err := r.createHealthJob(ctx, repository, healthJobProbe, script, nil); err != nil {
+			return nil, nil, err
+		}
+		// The finished Job triggers the next reconcile
+		return nil, &ctrl.Result{}, nil
+	}
+
+	finished, succeeded, _ := jobFinished(job)
+	if !finished {
+		return nil, &ctrl.Result{}, nil
+	}
+	message, err := jobTerminationMessage(ctx, r.apiReader(), job)
+	if deleteErr := r.deleteHealthJob(ctx, job); deleteErr != nil {
+		return nil, nil, deleteErr
+	}
+	if err != nil {
+		// The pod may be gone already, probe again
+		log.FromContext(ctx).Error(err, "Failed to read the probe result", "job", job.Name)
+		return nil, &ctrl.Result{}, nil
+	}
+	if succeeded {
+		config := &restic.RepositoryConfig{}
+		if err := json.Unmarshal([]byte(message), config); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse repository config: %w", err)
+		}
+		return config, nil, nil
+	}
+
+	probeErr := &restic.CommandError{Err: fmt.Errorf("probe job %s failed", job.Name), Stderr: message}
+	summary := fmt.Sprintf("Failed to access the repository: %s", strings.TrimSpace(message))
+	if expectedRepositoryID(repository) != "" {
+		summary = fmt.Sprintf("Failed to read the config of repository %s, not initializing a new repository: %s",
+			expectedRepositoryID(repository), strings.TrimSpace(message))
+	}
+	r.setCredentialsInvalid(repository, "ProbeFailed", summary)
+	recordRepositoryFailure(repository, repositoryOperationProbe, probeErr)
+	r.Recorder.Event(repository, corev1.EventTypeWarning, "ProbeFailed", summary)
+	if err := r.Status().Update(ctx, repository); err != nil {
+		return nil, nil, err
+	}
+	return nil, &ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+}
+
+// getHealthJob returns the check, stats or probe Job of the repository, or nil if none exists.
+func (r *ResticRepositoryReconciler) getHealthJob(ctx context.Context, repository *backupv1alpha1.ResticRepository, operation string) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: repositoryHealthJobName(repository, operation), Namespace: repository.Namespace}, job)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s job: %w", operation, err)
+	}
+	if !metav1.IsControlledBy(job, repository) {
+		return nil, fmt.Errorf("%s job %s %w %s", operation, job.Name, errNotControlled, repository.Name)
+	}
+	return job, nil
+}
+
+func (r *ResticRepositoryReconciler) createHealthJob(ctx context.Context, repository *backupv1alpha1.ResticRepository, operation, script string,
+	annotations map[string]string) error {
+	job := r.buildHealthJob(repository, operation, script)
+	job.Annotations = annotations
+	if err := controllerutil.SetControllerReference(repository, job, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+	if _, err := createOrAdopt(ctx, r.Client, repository, job, &batchv1.Job{}); err != nil {
+		return fmt.Errorf("failed to create %s job: %w", operation, err)
+	}
+	return nil
+}
+
+// deleteHealthJob deletes an evaluated Job together with its pods.
+func (r *ResticRepositoryReconciler) deleteHealthJob(ctx context.Context, job *batchv1.Job) error {
+	if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete job %s: %w", job.Name, err)
+	}
+	return nil
+}
+
+func (r *ResticRepositoryReconciler) apiReader() client.Reader {
+	if r.APIReader == nil {
+		return r.Client
+	}
+	return r.APIReader
+}
+
+// buildRepositoryStatsScript builds the shell script of the stats container. The JSON
+// statistics, or the restic errors if the command fails, are written to the termination
+// message.
+func buildRepositoryStatsScript(options []string) string {
+	cmd := restic.NewCommand("stats").WithJSON().WithMode("restore-size").WithArgs(options)
+	return strings.Join([]string{
+		fmt.Sprintf("restic %s > /tmp/stats.json 2> /tmp/stats.log", shellQuoteArgs(cmd.Build())),
+		"rc=$?",
+		"if [ $rc -eq 0 ]; then cp /tmp/stats.json /dev/termination-log; else tail -n 20 /tmp/stats.log > /dev/termination-log; fi",
+		"exit $rc",
+	}, "\n")
+}
+
+// buildRepositoryProbeScript builds the shell script of the probe container. It reads
+// the repository config, initializing the repository first if it can't be read and init
+// is set. The JSON config, or the restic errors if the probe fails, are written to the
+// termination message.
+func buildRepositoryProbeScript(init bool, options []string) string {
+	catConfig := fmt.Sprintf("restic %s > /tmp/config.json 2>> /tmp/probe.log",
+		shellQuoteArgs(restic.NewCommand("cat").WithArg("config").WithArgs(options).Build()))
+	probe := catConfig
+	if init {
+		probe = fmt.Sprintf("%s || { restic %s >> /tmp/probe.log 2>&1 && %s; }",
+			catConfig, shellQuoteArgs(restic.NewCommand("init").WithArgs(options).Build()), catConfig)
+	}
+	return strings.Join([]string{
+		probe,
+		"rc=$?",
+		"if [ $rc -eq 0 ]; then cp /tmp/config.json /dev/termination-log; else tail -n 20 /tmp/probe.log > /dev/termination-log; fi",
+		"exit $rc",
+	}, "\n")
+}
+
+// buildRepositoryCheckScript builds the shell script of the check container. Like the
+// ResticCheck jobs, it writes the errors reported by restic to the termination message.
+func buildRepositoryCheckScript(readDataSubset string, options []string) string {
+	cmd := restic.NewCommand("check").WithArgs(options)
+	if readDataSubset != "" {
+		cmd.WithReadDataSubset(readDataSubset)
+	}
+	return strings.Join([]string{
+		"set -o pipefail",
+		fmt.Sprintf("restic %s 2>&1 | tee /tmp/check.log", shellQuoteArgs(cmd.Build())),
+		"rc=$?",
+		fmt.Sprintf("grep -E '%s' /tmp/check.log | tail -n 20 > /dev/termination-log || true", checkSummaryPattern),
+		"exit $rc",
+	}, "\n")
+}
+
+// buildHealthJob builds the check, stats or probe Job of a repository.
+func (r *ResticRepositoryReconciler) buildHealthJob(repository *backupv1alpha1.ResticRepository, operation, script string) *batchv1.Job {
+	backoffLimit := r.JobDefaults.BackoffLimit(JobOperationCheck, nil)
+	activeDeadline := r.JobDefaults.ActiveDeadlineSeconds(JobOperationCheck, nil)
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "restic-backup-operator",
+		"app.kubernetes.io/component": "repository-" + operation,
+		repositoryHealthLabel:         repository.Name,
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      repositoryHealthJobName(repository, operation),
+			Namespace: repository.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "repository-" + operation,
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				repositoryHealthLabel:          repository.Name,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &activeDeadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot: boolPtr(true),
+						RunAsUser:    int64Ptr(65532),
+						FSGroup:      int64Ptr(65532),
+						SeccompProfile: &corev1.SeccompProfile{
+							Type: corev1.SeccompProfileTypeRuntimeDefault,
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:            "restic",
+							Image:           r.Images.Resolve("", nil),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Command:         []string{"/bin/sh", "-c"},
+							Args:            []string{script},
+							Env:             repositoryEnvVars(repository),
+							SecurityContext: &corev1.SecurityContext{
+								AllowPrivilegeEscalation: boolPtr(false),
+								ReadOnlyRootFilesystem:   boolPtr(false),
+								RunAsNonRoot:             boolPtr(true),
+								Capabilities: &corev1.Capabilities{
+									Drop: []corev1.Capability{"ALL"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	applyRepositoryCache(&job.Spec.Template.Spec, repository, repository.Namespace)
+	applyRepositoryCredentials(&job.Spec.Template.Spec, repository)
+
+	return job
+}
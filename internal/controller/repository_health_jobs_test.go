@@ -0,0 +1,222 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("Repository health jobs", func() {
+	var (
+		testScheme *runtime.Scheme
+		repository *backupv1alpha1.ResticRepository
+		recorder   *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		subsets := int32(4)
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "backup", UID: "repo-uid"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.example.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "creds"},
+				CheckStrategy:        backupv1alpha1.CheckStrategyJob,
+				IntegrityCheck:       &backupv1alpha1.IntegrityCheckConfig{Enabled: true, Schedule: "0 3 * * *", ReadDataSubsets: &subsets},
+			},
+		}
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	newReconciler := func(objects ...client.Object) *ResticRepositoryReconciler {
+		c := fake.NewClientBuilder().WithScheme(testScheme).
+			WithObjects(append(objects, repository)...).
+			WithStatusSubresource(&backupv1alpha1.ResticRepository{}).
+			Build()
+		return &ResticRepositoryReconciler{Client: c, Scheme: testScheme, Recorder: recorder}
+	}
+
+	// finishedHealthJob returns a finished Job of the repository and its pod terminated
+	// with the message.
+	finishedHealthJob := func(operation string, succeeded bool, message string) (*batchv1.Job, *corev1.Pod) {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:              repositoryHealthJobName(repository, operation),
+			Namespace:         repository.Namespace,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		}}
+		Expect(controllerutil.SetControllerReference(repository, job, testScheme)).To(Succeed())
+		conditionType := batchv1.JobComplete
+		if !succeeded {
+			conditionType = batchv1.JobFailed
+		}
+		job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Now()}}
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: job.Name + "-x7k2p", Namespace: job.Namespace, Labels: map[string]string{batchv1.JobNameLabel: job.Name}},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "restic",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message, FinishedAt: metav1.Now()}},
+			}}},
+		}
+		return job, pod
+	}
+
+	It("should start the stats and check jobs when they are due", func() {
+		reconciler := newReconciler()
+		Expect(reconciler.reconcileStatsJob(ctx, repository)).To(Succeed())
+		_, err := reconciler.reconcileCheckJob(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+
+		stats := &batchv1.Job{}
+		Expect(reconciler.Get(ctx, client.ObjectKey{Name: "restic-stats-repo", Namespace: "backup"}, stats)).To(Succeed())
+		Expect(metav1.IsControlledBy(stats, repository)).To(BeTrue())
+		Expect(stats.Spec.Template.Spec.Containers[0].Args[0]).To(ContainSubstring("'stats' '--json' '--mode' 'restore-size'"))
+
+		check := &batchv1.Job{}
+		Expect(reconciler.Get(ctx, client.ObjectKey{Name: "restic-check-repo", Namespace: "backup"}, check)).To(Succeed())
+		Expect(check.Spec.Template.Spec.Containers[0].Args[0]).To(ContainSubstring("'--read-data-subset' '1/4'"))
+		Expect(check.Annotations).To(HaveKeyWithValue(readDataSubsetAnnotation, "1"))
+	})
+
+	It("should record the statistics of a finished stats job and delete it", func() {
+		repository.Spec.IntegrityCheck = nil
+		job, pod := finishedHealthJob(healthJobStats, true, `{"total_size":2048,"total_file_count":3,"snapshots_count":2}`)
+		reconciler := newReconciler(job, pod)
+
+		Expect(reconciler.reconcileStatsJob(ctx, repository)).To(Succeed())
+
+		Expect(repository.Status.Statistics).NotTo(BeNil())
+		Expect(repository.Status.Statistics.SnapshotCount).To(Equal(int32(2)))
+		Expect(repository.Status.Statistics.TotalFileCount).To(Equal(int64(3)))
+		err := reconciler.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should probe sftp repositories in a job", func() {
+		repository.Spec.RepositoryURL = "sftp:backup@nas:/srv/restic"
+		reconciler := newReconciler()
+
+		config, result, err := reconciler.reconcileProbeJob(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(BeNil())
+		Expect(result).NotTo(BeNil())
+
+		probe := &batchv1.Job{}
+		Expect(reconciler.Get(ctx, client.ObjectKey{Name: "restic-probe-repo", Namespace: "backup"}, probe)).To(Succeed())
+		script := probe.Spec.Template.Spec.Containers[0].Args[0]
+		Expect(script).To(ContainSubstring("restic 'cat' 'config' '-o' 'sftp.args=-i /etc/restic/credentials/id_ssh"))
+		Expect(script).To(ContainSubstring("restic 'init'"))
+		Expect(probe.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "HOME", Value: "/tmp"}))
+
+		// A recently probed repository isn't probed again
+		repository.Status.LastCredentialsCheck = &metav1.Time{Time: time.Now()}
+		repository.Status.Conditions = []metav1.Condition{conditions.ReadyCondition("RepositoryAccessible", "")}
+		Expect(reconciler.Delete(ctx, probe)).To(Succeed())
+		config, result, err = reconciler.reconcileProbeJob(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(BeNil())
+		Expect(result).To(BeNil())
+	})
+
+	It("should not initialize a known repository in the probe job", func() {
+		repository.Spec.RepositoryURL = "sftp:backup@nas:/srv/restic"
+		repository.Status.RepositoryID = "5c6a1e2f"
+		reconciler := newReconciler()
+
+		_, _, err := reconciler.reconcileProbeJob(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		probe := &batchv1.Job{}
+		Expect(reconciler.Get(ctx, client.ObjectKey{Name: "restic-probe-repo", Namespace: "backup"}, probe)).To(Succeed())
+		Expect(probe.Spec.Template.Spec.Containers[0].Args[0]).NotTo(ContainSubstring("'init'"))
+	})
+
+	It("should return the config read by a finished probe job", func() {
+		repository.Spec.RepositoryURL = "sftp:backup@nas:/srv/restic"
+		job, pod := finishedHealthJob(healthJobProbe, true, `{"version":2,"id":"5c6a1e2f"}`)
+		reconciler := newReconciler(job, pod)
+
+		config, result, err := reconciler.reconcileProbeJob(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(BeNil())
+		Expect(config.ID).To(Equal("5c6a1e2f"))
+		err = reconciler.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should mark the credentials invalid if the probe job fails", func() {
+		repository.Spec.RepositoryURL = "sftp:backup@nas:/srv/restic"
+		job, pod := finishedHealthJob(healthJobProbe, false, "Fatal: unable to open repository: Host key verification failed")
+		reconciler := newReconciler(job, pod)
+
+		config, result, err := reconciler.reconcileProbeJob(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(BeNil())
+		Expect(result.RequeueAfter).To(Equal(errorRequeueInterval))
+		Expect(conditions.IsConditionFalse(repository.Status.Conditions, backupv1alpha1.ConditionCredentialsValid)).To(BeTrue())
+		Expect(repository.Status.LastFailure.Operation).To(Equal(repositoryOperationProbe))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Host key verification failed")))
+
+		// The failed probe is retried after the error requeue interval
+		_, result, err = reconciler.reconcileProbeJob(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		err = reconciler.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should report damaged data found by a failed check job", func() {
+		repository.Status.Statistics = &backupv1alpha1.RepositoryStatistics{LastUpdated: &metav1.Time{Time: time.Now()}}
+		job, pod := finishedHealthJob(healthJobCheck, false, "error: pack 1a2b: repository contains errors")
+		job.Annotations = map[string]string{readDataSubsetAnnotation: "3"}
+		repository.Spec.IntegrityCheck.Schedule = "@yearly"
+		reconciler := newReconciler(job, pod)
+
+		next, err := reconciler.reconcileCheckJob(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).NotTo(BeNil())
+
+		Expect(repository.Status.LastIntegrityCheckResult).To(Equal("Failed"))
+		Expect(repository.Status.LastIntegrityCheckSubset).To(Equal(int32(3)))
+		condition := conditions.GetCondition(repository.Status.Conditions, backupv1alpha1.ConditionIntegrityCheckSucceeded)
+		Expect(condition.Reason).To(Equal("CorruptionDetected"))
+		Expect(repository.Status.LastFailure.Stderr).To(ContainSubstring("repository contains errors"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("RepositoryUnhealthy")))
+
+		// The evaluated job is deleted and no new one is started before the next run
+		err = reconciler.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
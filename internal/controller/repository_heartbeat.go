@@ -0,0 +1,51 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// heartbeatTag tags the heartbeat snapshots written into repositories.
+const heartbeatTag = "operator-heartbeat"
+
+// buildHeartbeatCommands returns the shell commands writing the heartbeat of a successful
+// run into the repository: a snapshot of health/<clusterID>.json read from stdin, of
+// which only the newest is kept. A failed heartbeat doesn't fail the run. Run is the
+// namespace/name of the resource the job belongs to, options are the extended options
+// of the repository backend. Returns nil without heartbeat configuration.
+func buildHeartbeatCommands(repository *backupv1alpha1.ResticRepository, operation, run string, options []string) []string {
+	heartbeat := repository.Spec.Heartbeat
+	if heartbeat == nil {
+		return nil
+	}
+
+	clusterID := heartbeat.ClusterID
+	backup := restic.NewCommand("backup").WithArgs(options).WithHost(clusterID).WithTag(heartbeatTag).
+		WithArgs([]string{"--stdin", "--stdin-filename", fmt.Sprintf("health/%s.json", clusterID)})
+	forget := restic.NewCommand("forget").WithArgs(options).WithHost(clusterID).WithTag(heartbeatTag).WithKeepTag(retentionProtectedTag).WithKeepLast(1)
+	content := fmt.Sprintf(`{"clusterID":%q,"operation":%q,"run":%q,"time":"%%s"}\n`, clusterID, operation, run)
+
+	return []string{
+		fmt.Sprintf(`printf %s "$(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ)" | restic %s || echo 'Failed to write the heartbeat' >&2`,
+			shellQuoteArgs([]string{content}), shellQuoteArgs(backup.Build())),
+		fmt.Sprintf("restic %s || echo 'Failed to forget old heartbeats' >&2", shellQuoteArgs(forget.Build())),
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Repository heartbeat", func() {
+	var repository *backupv1alpha1.ResticRepository
+
+	BeforeEach(func() {
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "nas", Namespace: "backup"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL: "s3:s3.amazonaws.com/bucket",
+				Heartbeat:     &backupv1alpha1.HeartbeatConfig{ClusterID: "prod-eu1"},
+			},
+		}
+	})
+
+	It("should write a heartbeat snapshot and keep only the newest", func() {
+		commands := buildHeartbeatCommands(repository, "check", "backup/weekly", []string{"-o", "s3.region=eu"})
+		Expect(commands).To(HaveLen(2))
+		Expect(commands[0]).To(HavePrefix(`printf '{"clusterID":"prod-eu1","operation":"check","run":"backup/weekly","time":"%s"}\n' "$(date -u +%Y-%m-%dT%H:%M:%SZ)" | restic 'backup' '-o' 's3.region=eu' '--host' 'prod-eu1' '--tag' 'operator-heartbeat' '--stdin' '--stdin-filename' 'health/prod-eu1.json' ||`))
+		Expect(commands[0]).To(HaveSuffix("|| echo 'Failed to write the heartbeat' >&2"))
+		Expect(commands[1]).To(HavePrefix("restic 'forget' '-o' 's3.region=eu' '--host' 'prod-eu1' '--tag' 'operator-heartbeat' '--keep-tag' 'retention-protected' '--keep-last' '1' ||"))
+
+		repository.Spec.Heartbeat = nil
+		Expect(buildHeartbeatCommands(repository, "check", "backup/weekly", nil)).To(BeNil())
+	})
+
+	It("should write the heartbeat after successful checks only", func() {
+		check := &backupv1alpha1.ResticCheck{ObjectMeta: metav1.ObjectMeta{Name: "weekly", Namespace: "backup"}}
+		cronJob := (&ResticCheckReconciler{}).buildCronJob(check, repository)
+		script := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Args[0]
+		Expect(script).To(ContainSubstring("if [ $rc -eq 0 ]; then\nprintf "))
+		Expect(script).To(ContainSubstring(`"operation":"check","run":"backup/weekly"`))
+		Expect(script).To(HaveSuffix("fi\nexit $rc"))
+	})
+
+	It("should write the heartbeat at the end of retention runs", func() {
+		keepLast := int32(7)
+		policy := &backupv1alpha1.GlobalRetentionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "daily", Namespace: "backup"},
+			Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+				Schedule: "0 3 * * *",
+				Policies: []backupv1alpha1.RetentionPolicyEntry{
+					{Retention: backupv1alpha1.RetentionPolicy{KeepLast: &keepLast}},
+				},
+			},
+		}
+		reconciler := &GlobalRetentionPolicyReconciler{}
+		cronJob := reconciler.buildCronJob(policy, repository, retentionSchedules(policy)[0])
+		script := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Args[0]
+		Expect(script).To(ContainSubstring("echo 'Retention policy execution completed'\nprintf "))
+		Expect(script).To(ContainSubstring(`"operation":"retention","run":"backup/daily"`))
+		Expect(script).To(HaveSuffix("|| echo 'Failed to forget old heartbeats' >&2"))
+	})
+})
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+// expectedRepositoryID returns the ID of the restic repository the backend of a
+// ResticRepository must serve: the pinned ID, or the ID recorded at an earlier probe.
+// It returns an empty string if the identity isn't known yet.
+func expectedRepositoryID(repository *backupv1alpha1.ResticRepository) string {
+	if repository.Spec.RepositoryID != "" {
+		return repository.Spec.RepositoryID
+	}
+	return repository.Status.RepositoryID
+}
+
+// repositoryIdentityChanged reports whether the backend served another restic
+// repository than the expected one at the last probe.
+func repositoryIdentityChanged(repository *backupv1alpha1.ResticRepository) bool {
+	return conditions.IsConditionTrue(repository.Status.Conditions, backupv1alpha1.ConditionRepositoryIdentityChanged)
+}
+
+// confirmRepositoryIdentity compares the ID of the repository served by the backend
+// with the expected one. A matching or first seen ID is recorded in the status. A
+// different ID sets the RepositoryIdentityChanged condition and marks the repository
+// not ready, so no backup writes into and no restore reads from the wrong repository.
+// It reports whether the identity changed.
+func (r *ResticRepositoryReconciler) confirmRepositoryIdentity(repository *backupv1alpha1.ResticRepository, id string) bool {
+	expected := expectedRepositoryID(repository)
+	if id == "" {
+		return false
+	}
+	if expected == "" || id == expected {
+		repository.Status.RepositoryID = id
+		r.setCondition(repository, metav1.Condition{
+			Type:    backupv1alpha1.ConditionRepositoryIdentityChanged,
+			Status:  metav1.ConditionFalse,
+			Reason:  "RepositoryIDConfirmed",
+			Message: fmt.Sprintf("The backend serves repository %s", id),
+		})
+		return false
+	}
+
+	message := fmt.Sprintf("The backend serves repository %s instead of %s. Set spec.repositoryID to %s if the repository was replaced on purpose",
+		id, expected, id)
+	if !repositoryIdentityChanged(repository) {
+		r.Recorder.Event(repository, corev1.EventTypeWarning, "RepositoryIdentityChanged", message)
+	}
+	r.setCondition(repository, metav1.Condition{
+		Type:    backupv1alpha1.ConditionRepositoryIdentityChanged,
+		Status:  metav1.ConditionTrue,
+		Reason:  "RepositoryIDMismatch",
+		Message: message,
+	})
+	r.setCondition(repository, conditions.NotReadyCondition("RepositoryIdentityChanged", message))
+	return true
+}
+
+// buildIdentityCheckCommands returns the shell commands failing a job before it uses
+// the repository if the backend serves another restic repository than the expected
+// one, e.g. because the bucket was swapped after the last probe. It returns nil if the
+// identity isn't known yet.
+func buildIdentityCheckCommands(repository *backupv1alpha1.ResticRepository) []string {
+	id := expectedRepositoryID(repository)
+	if id == "" {
+		return nil
+	}
+
+	catConfig := append([]string{"restic", "cat", "config"}, repositoryOptions(repository)...)
+	return []string{
+		fmt.Sprintf("config=$(%s) || exit 1", shellQuoteArgs(catConfig)),
+		fmt.Sprintf(`echo "$config" | grep -q %s || { echo %s >&2; exit 1; }`,
+			shellQuoteArgs([]string{fmt.Sprintf(`"id": *"%s"`, id)}),
+			shellQuoteArgs([]string{"The backend serves another repository than " + id})),
+	}
+}
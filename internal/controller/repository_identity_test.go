@@ -0,0 +1,141 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic/fakerestic"
+)
+
+const (
+	testRepositoryID  = "5d41402abc4b2a76b9719d911017c5925d41402abc4b2a76b9719d911017c592"
+	otherRepositoryID = "7e2f0c43f1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c"
+)
+
+var _ = Describe("Repository identity", func() {
+	var repository *backupv1alpha1.ResticRepository
+
+	BeforeEach(func() {
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "db"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "local:/tmp/test-repo",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+	})
+
+	It("should not check the identity of a repository that wasn't probed yet", func() {
+		Expect(buildIdentityCheckCommands(repository)).To(BeNil())
+	})
+
+	It("should prefer the pinned repository ID", func() {
+		repository.Status.RepositoryID = testRepositoryID
+		Expect(expectedRepositoryID(repository)).To(Equal(testRepositoryID))
+		repository.Spec.RepositoryID = otherRepositoryID
+		Expect(expectedRepositoryID(repository)).To(Equal(otherRepositoryID))
+	})
+
+	It("should fail the job if the backend serves another repository", func() {
+		repository.Status.RepositoryID = testRepositoryID
+		Expect(buildIdentityCheckCommands(repository)).To(Equal([]string{
+			"config=$('restic' 'cat' 'config') || exit 1",
+			`echo "$config" | grep -q '"id": *"` + testRepositoryID + `"' || { echo 'The backend serves another repository than ` + testRepositoryID + `' >&2; exit 1; }`,
+		}))
+	})
+
+	It("should check the identity before the backup", func() {
+		repository.Status.RepositoryID = testRepositoryID
+		identityCheck := buildIdentityCheckCommands(repository)
+		script := backupScript{identityCheck: identityCheck, backup: []string{"restic", "backup", "/backup"}}.build()
+		Expect(script).To(ContainSubstring(identityCheck[1]))
+		Expect(strings.Index(script, identityCheck[1])).To(BeNumerically("<", strings.Index(script, "'restic' 'backup'")))
+	})
+
+	It("should check the identity before the restore", func() {
+		repository.Status.RepositoryID = testRepositoryID
+		restore := &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "postgres", Namespace: "db"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				Target: backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "postgres-data"}},
+			},
+		}
+		backup := &backupv1alpha1.ResticBackup{ObjectMeta: metav1.ObjectMeta{Name: "postgres", Namespace: "db"}}
+
+		job := (&ResticRestoreReconciler{}).buildRestoreJob(restore, backup, repository, "latest")
+		container := job.Spec.Template.Spec.Containers[0]
+		Expect(container.Command).To(Equal([]string{"/bin/sh", "-c"}))
+		Expect(container.Args).To(HaveLen(1))
+		Expect(container.Args[0]).To(HavePrefix(strings.Join(buildIdentityCheckCommands(repository), "\n")))
+		Expect(container.Args[0]).To(HaveSuffix("'restic' 'restore' 'latest' '--target' '/restore' '--json' || exit $?"))
+	})
+
+	It("should not restore from a repository whose identity changed", func() {
+		apimeta.SetStatusCondition(&repository.Status.Conditions, metav1.Condition{
+			Type:   backupv1alpha1.ConditionRepositoryIdentityChanged,
+			Status: metav1.ConditionTrue,
+			Reason: "RepositoryIDMismatch",
+		})
+		restore := &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "postgres", Namespace: "db"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "postgres"},
+				Target:    backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "postgres-data"}},
+			},
+		}
+		backup := &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "postgres", Namespace: "db"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"},
+			},
+		}
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		recorder := record.NewFakeRecorder(10)
+		executor := fakerestic.New()
+		r := &ResticRestoreReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(restore, backup, repository).
+				WithStatusSubresource(&backupv1alpha1.ResticRestore{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: recorder,
+			Executor: executor,
+		}
+
+		_, err := r.handlePending(context.Background(), restore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restore.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseFailed))
+		Expect(restore.Status.JobRef).To(BeNil())
+		Expect(executor.Calls("Snapshots")).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(ContainSubstring(corev1.EventTypeWarning + " RepositoryIdentityChanged")))
+	})
+})
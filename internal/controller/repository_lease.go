@@ -0,0 +1,230 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+const (
+	// waitingForRepositoryInterval is the requeue interval of jobs waiting for the repository.
+	waitingForRepositoryInterval = 30 * time.Second
+	// repositoryLeaseMargin is added to the active deadline of the job holding the lease,
+	// so the lease of a job that was never released expires eventually.
+	repositoryLeaseMargin = 10 * time.Minute
+	// repositoryLeaseSettleTime is how long an exclusive job waits after acquiring the
+	// lease before it looks for running backups. A backup that found the lease free just
+	// before it was acquired has started its job by then.
+	repositoryLeaseSettleTime = 10 * time.Second
+)
+
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;patch;delete
+
+// repositoryLeaseName returns the name of the Lease coordinating the jobs of a repository.
+func repositoryLeaseName(repository *backupv1alpha1.ResticRepository) string {
+	return "resticrepository-" + repository.Name
+}
+
+// repositoryLeaseHolder returns the holder of the repository Lease, or an empty string if
+// the Lease is free or expired. Leases are read with the API reader, so the operator
+// doesn't cache all Leases of the cluster.
+func repositoryLeaseHolder(ctx context.Context, reader client.Reader, repository *backupv1alpha1.ResticRepository) (string, error) {
+	lease := &coordinationv1.Lease{}
+	key := types.NamespacedName{Name: repositoryLeaseName(repository), Namespace: repository.Namespace}
+	if err := reader.Get(ctx, key, lease); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get repository lease: %w", err)
+	}
+	if leaseExpired(lease, time.Now()) {
+		return "", nil
+	}
+	return stringValue(lease.Spec.HolderIdentity), nil
+}
+
+// acquireRepositoryLease takes or renews the repository Lease for holder. It returns the
+// current holder if the Lease is held by someone else, or an empty string and the time
+// the Lease was acquired by holder.
+func acquireRepositoryLease(ctx context.Context, c client.Client, reader client.Reader, scheme *runtime.Scheme,
+	repository *backupv1alpha1.ResticRepository, holder string, duration time.Duration) (string, time.Time, error) {
+	lease := &coordinationv1.Lease{}
+	key := types.NamespacedName{Name: repositoryLeaseName(repository), Namespace: repository.Namespace}
+	err := reader.Get(ctx, key, lease)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", time.Time{}, fmt.Errorf("failed to get repository lease: %w", err)
+	}
+
+	now := metav1.NewMicroTime(time.Now())
+	if current := stringValue(lease.Spec.HolderIdentity); current != "" && current != holder && !leaseExpired(lease, now.Time) {
+		return current, time.Time{}, nil
+	}
+	if stringValue(lease.Spec.HolderIdentity) != holder {
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.RenewTime = &now
+	lease.Spec.LeaseDurationSeconds = int32Ptr(int32(duration / time.Second))
+
+	if apierrors.IsNotFound(err) {
+		lease.Name = key.Name
+		lease.Namespace = key.Namespace
+		if err := controllerutil.SetOwnerReference(repository, lease, scheme); err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to set owner reference: %w", err)
+		}
+		err = c.Create(ctx, lease)
+	} else {
+		err = c.Update(ctx, lease)
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to acquire repository lease: %w", err)
+	}
+	var acquiredAt time.Time
+	if lease.Spec.AcquireTime != nil {
+		acquiredAt = lease.Spec.AcquireTime.Time
+	}
+	return "", acquiredAt, nil
+}
+
+// releaseRepositoryLease releases the repository Lease if it is held by holder.
+func releaseRepositoryLease(ctx context.Context, c client.Client, reader client.Reader, repository *backupv1alpha1.ResticRepository, holder string) error {
+	lease := &coordinationv1.Lease{}
+	key := types.NamespacedName{Name: repositoryLeaseName(repository), Namespace: repository.Namespace}
+	if err := reader.Get(ctx, key, lease); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if stringValue(lease.Spec.HolderIdentity) != holder {
+		return nil
+	}
+
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.AcquireTime = nil
+	lease.Spec.RenewTime = nil
+	if err := c.Update(ctx, lease); err != nil {
+		return fmt.Errorf("failed to release repository lease: %w", err)
+	}
+	return nil
+}
+
+// acquireRepositoryForExclusiveJob takes the repository Lease for a prune or retention
+// job and checks that no backup job of the repository is running. The Lease is kept
+// while waiting for the backups, so no new backup jobs start. Backup jobs, including
+// the forget of their retention, are looked for with the API reader once the Lease
+// settled, so a job started right before the Lease was acquired is not missed. It
+// returns a message describing what the job waits for, or an empty string if it can run.
+func acquireRepositoryForExclusiveJob(ctx context.Context, c client.Client, reader client.Reader, scheme *runtime.Scheme,
+	repository *backupv1alpha1.ResticRepository, holder string, duration time.Duration) (string, error) {
+	current, acquiredAt, err := acquireRepositoryLease(ctx, c, reader, scheme, repository, holder, duration+repositoryLeaseMargin)
+	if err != nil {
+		return "", err
+	}
+	if current != "" {
+		return fmt.Sprintf("Repository %s/%s is used by %s", repository.Namespace, repository.Name, current), nil
+	}
+	if time.Since(acquiredAt) < repositoryLeaseSettleTime {
+		return fmt.Sprintf("Acquired repository %s/%s, waiting for backups starting at the same time", repository.Namespace, repository.Name), nil
+	}
+
+	running, err := activeBackupJobs(ctx, reader, repository)
+	if err != nil {
+		return "", err
+	}
+	if len(running) > 0 {
+		return fmt.Sprintf("Waiting for backup jobs %s to finish", strings.Join(running, ", ")), nil
+	}
+	return "", nil
+}
+
+// activeBackupJobs returns the started and unfinished backup jobs of the ResticBackups
+// using the repository as namespace/name.
+func activeBackupJobs(ctx context.Context, reader client.Reader, repository *backupv1alpha1.ResticRepository) ([]string, error) {
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := reader.List(ctx, backups); err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var running []string
+	for _, backup := range backups.Items {
+		if !referencesRepository(backup.Spec.RepositoryRef, backup.Namespace, repository) {
+			continue
+		}
+
+		jobs := &batchv1.JobList{}
+		if err := reader.List(ctx, jobs, client.InNamespace(backup.Namespace), client.MatchingLabels{resticBackupLabel: backup.Name}); err != nil {
+			return nil, fmt.Errorf("failed to list backup jobs: %w", err)
+		}
+		for _, job := range jobs.Items {
+			if jobActive(&job) {
+				running = append(running, job.Namespace+"/"+job.Name)
+			}
+		}
+	}
+	return running, nil
+}
+
+// jobActive reports whether a job was started and did not finish yet.
+func jobActive(job *batchv1.Job) bool {
+	if job.Spec.Suspend != nil && *job.Spec.Suspend {
+		return false
+	}
+	finished, _, _ := jobFinished(job)
+	return !finished
+}
+
+// setWaitingForRepository sets the WaitingForRepository condition. An empty message
+// means the job no longer waits.
+func setWaitingForRepository(list *[]metav1.Condition, message string) {
+	if message == "" {
+		if conditions.IsConditionTrue(*list, backupv1alpha1.ConditionWaitingForRepository) {
+			conditions.SetCondition(list, conditions.NewCondition(backupv1alpha1.ConditionWaitingForRepository,
+				metav1.ConditionFalse, "RepositoryAvailable", "Repository is available"))
+		}
+		return
+	}
+	conditions.SetCondition(list, conditions.NewCondition(backupv1alpha1.ConditionWaitingForRepository,
+		metav1.ConditionTrue, "RepositoryBusy", message))
+}
+
+// leaseExpired reports whether a Lease was not renewed within its duration.
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return now.After(lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
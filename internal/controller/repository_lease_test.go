@@ -0,0 +1,292 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("Repository lease", func() {
+	var (
+		ctx        context.Context
+		c          client.Client
+		testScheme *runtime.Scheme
+		repository *backupv1alpha1.ResticRepository
+		backup     *backupv1alpha1.ResticBackup
+	)
+
+	backupJob := func(name string, suspended bool) *batchv1.Job {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "media", Labels: map[string]string{resticBackupLabel: backup.Name}},
+			Spec:       batchv1.JobSpec{Suspend: boolPtr(suspended)},
+		}
+		if !suspended {
+			startTime := metav1.NewTime(time.Now())
+			job.Status.StartTime = &startTime
+		}
+		return job
+	}
+
+	newClient := func(objects ...client.Object) {
+		c = fake.NewClientBuilder().WithScheme(testScheme).
+			WithObjects(append(objects, repository, backup)...).
+			WithStatusSubresource(&backupv1alpha1.ResticBackup{}, &backupv1alpha1.ResticPrune{}, &batchv1.Job{}).
+			Build()
+	}
+
+	// settledLease is a repository Lease acquired by holder long enough ago to look for
+	// running backups
+	settledLease := func(holder string) *coordinationv1.Lease {
+		acquireTime := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+		renewTime := metav1.NewMicroTime(time.Now())
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "resticrepository-nas", Namespace: "backup"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity: &holder, AcquireTime: &acquireTime, RenewTime: &renewTime,
+				LeaseDurationSeconds: int32Ptr(3600),
+			},
+		}
+	}
+
+	getLease := func() *coordinationv1.Lease {
+		lease := &coordinationv1.Lease{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "resticrepository-nas", Namespace: "backup"}, lease)).To(Succeed())
+		return lease
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "nas", Namespace: "backup", UID: "repo-uid"},
+			Spec:       backupv1alpha1.ResticRepositorySpec{CoordinateJobs: true},
+		}
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas", Namespace: "backup"},
+			},
+		}
+	})
+
+	Context("acquireRepositoryLease", func() {
+		BeforeEach(func() {
+			newClient()
+		})
+
+		It("should create the lease owned by the repository", func() {
+			current, _, err := acquireRepositoryLease(ctx, c, c, testScheme, repository, "ResticPrune/backup/weekly", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(current).To(BeEmpty())
+
+			lease := getLease()
+			Expect(*lease.Spec.HolderIdentity).To(Equal("ResticPrune/backup/weekly"))
+			Expect(*lease.Spec.LeaseDurationSeconds).To(Equal(int32(3600)))
+			Expect(lease.OwnerReferences).To(ConsistOf(HaveField("Name", "nas")))
+		})
+
+		It("should return the holder of a lease held by someone else", func() {
+			_, _, err := acquireRepositoryLease(ctx, c, c, testScheme, repository, "ResticPrune/backup/weekly", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+
+			current, _, err := acquireRepositoryLease(ctx, c, c, testScheme, repository, "GlobalRetentionPolicy/backup/daily", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(current).To(Equal("ResticPrune/backup/weekly"))
+		})
+
+		It("should take over an expired lease", func() {
+			_, _, err := acquireRepositoryLease(ctx, c, c, testScheme, repository, "ResticPrune/backup/weekly", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			lease := getLease()
+			renewTime := metav1.NewMicroTime(time.Now().Add(-2 * time.Hour))
+			lease.Spec.RenewTime = &renewTime
+			Expect(c.Update(ctx, lease)).To(Succeed())
+
+			current, _, err := acquireRepositoryLease(ctx, c, c, testScheme, repository, "GlobalRetentionPolicy/backup/daily", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(current).To(BeEmpty())
+			Expect(*getLease().Spec.HolderIdentity).To(Equal("GlobalRetentionPolicy/backup/daily"))
+		})
+
+		It("should only release the lease of the holder", func() {
+			_, _, err := acquireRepositoryLease(ctx, c, c, testScheme, repository, "ResticPrune/backup/weekly", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(releaseRepositoryLease(ctx, c, c, repository, "GlobalRetentionPolicy/backup/daily")).To(Succeed())
+			Expect(repositoryLeaseHolder(ctx, c, repository)).To(Equal("ResticPrune/backup/weekly"))
+
+			Expect(releaseRepositoryLease(ctx, c, c, repository, "ResticPrune/backup/weekly")).To(Succeed())
+			Expect(repositoryLeaseHolder(ctx, c, repository)).To(BeEmpty())
+		})
+	})
+
+	Context("acquireRepositoryForExclusiveJob", func() {
+		It("should wait for backups starting at the same time before looking for them", func() {
+			newClient(backupJob("resticbackup-db-1", false))
+
+			message, err := acquireRepositoryForExclusiveJob(ctx, c, c, testScheme, repository, "ResticPrune/backup/weekly", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(message).To(ContainSubstring("waiting for backups starting at the same time"))
+			Expect(repositoryLeaseHolder(ctx, c, repository)).To(Equal("ResticPrune/backup/weekly"))
+		})
+
+		It("should wait for running backup jobs while holding the lease", func() {
+			newClient(settledLease("ResticPrune/backup/weekly"), backupJob("resticbackup-db-1", false), backupJob("resticbackup-db-2", true))
+
+			message, err := acquireRepositoryForExclusiveJob(ctx, c, c, testScheme, repository, "ResticPrune/backup/weekly", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(message).To(Equal("Waiting for backup jobs media/resticbackup-db-1 to finish"))
+			Expect(repositoryLeaseHolder(ctx, c, repository)).To(Equal("ResticPrune/backup/weekly"))
+		})
+
+		It("should ignore backups of other repositories", func() {
+			backup.Spec.RepositoryRef.Name = "s3"
+			newClient(settledLease("ResticPrune/backup/weekly"), backupJob("resticbackup-db-1", false))
+
+			message, err := acquireRepositoryForExclusiveJob(ctx, c, c, testScheme, repository, "ResticPrune/backup/weekly", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(message).To(BeEmpty())
+		})
+	})
+
+	Context("updateBackupStatus", func() {
+		It("should keep backup jobs suspended while the repository is held", func() {
+			job := backupJob("resticbackup-db-1", true)
+			newClient(job)
+			_, _, err := acquireRepositoryLease(ctx, c, c, testScheme, repository, "ResticPrune/backup/weekly", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			reconciler := &ResticBackupReconciler{Client: c, Recorder: record.NewFakeRecorder(10)}
+
+			Expect(reconciler.updateBackupStatus(ctx, backup, repository)).To(Succeed())
+			Expect(conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionWaitingForRepository)).To(BeTrue())
+			waiting := &batchv1.Job{}
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(job), waiting)).To(Succeed())
+			Expect(*waiting.Spec.Suspend).To(BeTrue())
+
+			Expect(releaseRepositoryLease(ctx, c, c, repository, "ResticPrune/backup/weekly")).To(Succeed())
+			Expect(reconciler.updateBackupStatus(ctx, backup, repository)).To(Succeed())
+			Expect(conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionWaitingForRepository)).To(BeFalse())
+			started := &batchv1.Job{}
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(job), started)).To(Succeed())
+			Expect(*started.Spec.Suspend).To(BeFalse())
+		})
+	})
+
+	Context("startRetentionJobs", func() {
+		var (
+			policy     *backupv1alpha1.GlobalRetentionPolicy
+			reconciler *GlobalRetentionPolicyReconciler
+			job        *batchv1.Job
+		)
+
+		BeforeEach(func() {
+			policy = &backupv1alpha1.GlobalRetentionPolicy{ObjectMeta: metav1.ObjectMeta{Name: "daily", Namespace: "backup"}}
+			job = &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "globalretention-daily-1", Namespace: "backup", Labels: map[string]string{retentionPolicyLabel: "daily"}},
+				Spec:       batchv1.JobSpec{Suspend: boolPtr(true)},
+			}
+		})
+
+		newRetentionReconciler := func(objects ...client.Object) {
+			newClient(objects...)
+			reconciler = &GlobalRetentionPolicyReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(10)}
+		}
+
+		It("should wait for running backup jobs", func() {
+			newRetentionReconciler(job, settledLease("GlobalRetentionPolicy/backup/daily"), backupJob("resticbackup-db-1", false))
+
+			message, err := reconciler.startRetentionJobs(ctx, policy, repository)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(message).To(ContainSubstring("media/resticbackup-db-1"))
+			waiting := &batchv1.Job{}
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(job), waiting)).To(Succeed())
+			Expect(*waiting.Spec.Suspend).To(BeTrue())
+		})
+
+		It("should start the job and release the repository once it finished", func() {
+			newRetentionReconciler(job, settledLease("GlobalRetentionPolicy/backup/daily"))
+
+			message, err := reconciler.startRetentionJobs(ctx, policy, repository)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(message).To(BeEmpty())
+			started := &batchv1.Job{}
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(job), started)).To(Succeed())
+			Expect(*started.Spec.Suspend).To(BeFalse())
+			Expect(repositoryLeaseHolder(ctx, c, repository)).To(Equal("GlobalRetentionPolicy/backup/daily"))
+
+			started.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: "True"}}
+			Expect(c.Status().Update(ctx, started)).To(Succeed())
+			_, err = reconciler.startRetentionJobs(ctx, policy, repository)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repositoryLeaseHolder(ctx, c, repository)).To(BeEmpty())
+		})
+	})
+
+	Context("handleInProgress", func() {
+		It("should keep the repository while the prune job retries failed pods", func() {
+			prune := &backupv1alpha1.ResticPrune{
+				ObjectMeta: metav1.ObjectMeta{Name: "weekly", Namespace: "backup"},
+				Spec:       backupv1alpha1.ResticPruneSpec{RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas"}},
+				Status: backupv1alpha1.ResticPruneStatus{
+					Phase:  backupv1alpha1.PrunePhaseInProgress,
+					JobRef: &backupv1alpha1.ObjectReference{Name: "resticprune-weekly", Namespace: "backup"},
+				},
+			}
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "resticprune-weekly", Namespace: "backup"},
+				Status:     batchv1.JobStatus{Failed: 1},
+			}
+			newClient(prune, job, settledLease("ResticPrune/backup/weekly"))
+			reconciler := &ResticPruneReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(10), SnapshotCache: NewSnapshotCache(c)}
+			reconciler.SnapshotCache.store(client.ObjectKeyFromObject(repository), nil, time.Now())
+
+			_, err := reconciler.handleInProgress(ctx, prune)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prune.Status.Phase).To(Equal(backupv1alpha1.PrunePhaseInProgress))
+			Expect(repositoryLeaseHolder(ctx, c, repository)).To(Equal("ResticPrune/backup/weekly"))
+			Expect(reconciler.SnapshotCache.entries).To(HaveKey(client.ObjectKeyFromObject(repository)))
+
+			job.Status.Failed = 2
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+			Expect(c.Status().Update(ctx, job)).To(Succeed())
+			_, err = reconciler.handleInProgress(ctx, prune)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prune.Status.Phase).To(Equal(backupv1alpha1.PrunePhaseFailed))
+			Expect(repositoryLeaseHolder(ctx, c, repository)).To(BeEmpty())
+			Expect(reconciler.SnapshotCache.entries).NotTo(HaveKey(client.ObjectKeyFromObject(repository)))
+		})
+	})
+})
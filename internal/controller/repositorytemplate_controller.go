@@ -0,0 +1,526 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+const (
+	// repositoryTemplateLabel and repositoryTemplateNamespaceLabel mark the ResticRepositories
+	// and Secrets created for a RepositoryTemplate with its name and namespace.
+	repositoryTemplateLabel          = "backup.resticbackup.io/repository-template"
+	repositoryTemplateNamespaceLabel = "backup.resticbackup.io/repository-template-namespace"
+
+	// repositoryTemplateNamespacePlaceholder is replaced by the tenant namespace in the
+	// repository URL of a RepositoryTemplate.
+	repositoryTemplateNamespacePlaceholder = "{{namespace}}"
+
+	// repositoryTemplateRequeueInterval is the requeue interval while repositories are
+	// provisioned, as Secrets created by a provisioner are not watched.
+	repositoryTemplateRequeueInterval = 30 * time.Second
+
+	// provisionerJobTTL keeps finished provisioner Jobs for a day. A failed provisioner
+	// runs again once its Job was deleted.
+	provisionerJobTTL = int32(86400)
+	// provisionerActiveDeadline stops hanging provisioner Jobs.
+	provisionerActiveDeadline = int64(900)
+	// maxProvisionerJobNameLength keeps the job-name label of the provisioner pods
+	// within the 63 character limit of label values.
+	maxProvisionerJobNameLength = 63
+	// generatedPasswordBytes is the number of random bytes of a generated repository password.
+	generatedPasswordBytes = 32
+)
+
+// errSourceSecretNotFound is returned if the source Secret of a RepositoryTemplate is missing.
+var errSourceSecretNotFound = errors.New("source Secret not found")
+
+// RepositoryTemplateReconciler reconciles a RepositoryTemplate object
+type RepositoryTemplateReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// MaxConcurrentReconciles is the number of RepositoryTemplates reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=repositorytemplates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=repositorytemplates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=repositorytemplates/finalizers,verbs=update
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrepositories,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop.
+func (r *RepositoryTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciling RepositoryTemplate")
+
+	template := &backupv1alpha1.RepositoryTemplate{}
+	if err := r.Get(ctx, req.NamespacedName, template); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("RepositoryTemplate resource not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get RepositoryTemplate")
+		return ctrl.Result{}, err
+	}
+
+	// The created repositories and credentials are kept when the template is deleted,
+	// the tenant data must not be lost with it
+	if !template.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	template.Status.ObservedGeneration = template.Generation
+	selector, err := metav1.LabelSelectorAsSelector(&template.Spec.NamespaceSelector)
+	if err != nil {
+		r.setCondition(template, conditions.NotReadyCondition("InvalidNamespaceSelector", fmt.Sprintf("Invalid namespace selector: %v", err)))
+		return ctrl.Result{}, r.Status().Update(ctx, template)
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	sort.Slice(namespaces.Items, func(i, j int) bool {
+		return namespaces.Items[i].Name < namespaces.Items[j].Name
+	})
+
+	repositories := make([]backupv1alpha1.TemplatedRepository, 0, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		if !namespace.DeletionTimestamp.IsZero() {
+			continue
+		}
+		entry, err := r.reconcileTenant(ctx, template, namespace.Name)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		repositories = append(repositories, entry)
+	}
+
+	var ready, failed int32
+	for _, entry := range repositories {
+		switch entry.Phase {
+		case backupv1alpha1.TemplatedRepositoryReady:
+			ready++
+		case backupv1alpha1.TemplatedRepositoryFailed:
+			failed++
+		}
+	}
+	total := int32(len(repositories))
+	template.Status.Repositories = repositories
+	template.Status.ReadyRepositories = ready
+	template.Status.TotalRepositories = total
+
+	message := fmt.Sprintf("%d of %d repositories ready", ready, total)
+	var result ctrl.Result
+	switch {
+	case failed > 0:
+		r.setCondition(template, conditions.NotReadyCondition("ProvisioningFailed", fmt.Sprintf("%s, %d failed", message, failed)))
+		result.RequeueAfter = repositoryTemplateRequeueInterval
+	case ready < total:
+		r.setCondition(template, conditions.UnknownCondition("Provisioning", message))
+		result.RequeueAfter = repositoryTemplateRequeueInterval
+	default:
+		r.setCondition(template, conditions.ReadyCondition("RepositoriesReady", message))
+	}
+
+	return result, r.Status().Update(ctx, template)
+}
+
+// reconcileTenant provisions the credentials Secret and the ResticRepository of a tenant
+// namespace and returns the state of its repository.
+func (r *RepositoryTemplateReconciler) reconcileTenant(ctx context.Context, template *backupv1alpha1.RepositoryTemplate, namespace string) (backupv1alpha1.TemplatedRepository, error) {
+	entry := backupv1alpha1.TemplatedRepository{Namespace: namespace}
+	repository := buildTemplatedRepository(template, namespace)
+	secretKey := types.NamespacedName{Name: repository.Spec.CredentialsSecretRef.Name, Namespace: namespace}
+
+	var secret *corev1.Secret
+	existing := &corev1.Secret{}
+	err := r.Get(ctx, secretKey, existing)
+	switch {
+	case err == nil:
+		secret = existing
+	case !apierrors.IsNotFound(err):
+		return entry, fmt.Errorf("failed to get credentials Secret %s: %w", secretKey, err)
+	case template.Spec.Credentials.Provisioner != nil:
+		// The provisioner creates the Secret
+		phase, message, err := r.runProvisioner(ctx, template, namespace, secretKey.Name)
+		if err != nil || phase != "" {
+			entry.Phase, entry.Message = phase, message
+			return entry, err
+		}
+		if err := r.Get(ctx, secretKey, existing); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return entry, fmt.Errorf("failed to get credentials Secret %s: %w", secretKey, err)
+			}
+			entry.Phase = backupv1alpha1.TemplatedRepositoryFailed
+			entry.Message = fmt.Sprintf("Provisioner finished without creating Secret %s", secretKey.Name)
+			return entry, nil
+		}
+		secret = existing
+	}
+
+	if err := r.ensureCredentials(ctx, template, repository, secret); err != nil {
+		if errors.Is(err, errSourceSecretNotFound) {
+			entry.Phase = backupv1alpha1.TemplatedRepositoryFailed
+			entry.Message = err.Error()
+			return entry, nil
+		}
+		return entry, err
+	}
+
+	current := &backupv1alpha1.ResticRepository{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(repository), current)
+	switch {
+	case apierrors.IsNotFound(err):
+		if err := r.Create(ctx, repository); err != nil {
+			return entry, fmt.Errorf("failed to create ResticRepository %s/%s: %w", namespace, repository.Name, err)
+		}
+		r.Recorder.Event(template, corev1.EventTypeNormal, "RepositoryCreated",
+			fmt.Sprintf("Created ResticRepository %s/%s", namespace, repository.Name))
+		entry.Phase = backupv1alpha1.TemplatedRepositoryPending
+		return entry, nil
+	case err != nil:
+		return entry, fmt.Errorf("failed to get ResticRepository %s/%s: %w", namespace, repository.Name, err)
+	case !managedByTemplate(current, template):
+		entry.Phase = backupv1alpha1.TemplatedRepositoryFailed
+		entry.Message = fmt.Sprintf("ResticRepository %s already exists and is not managed by the template", repository.Name)
+		return entry, nil
+	}
+
+	// Labels and annotations set by others are kept
+	changed := !equality.Semantic.DeepEqual(current.Spec, repository.Spec)
+	current.Spec = repository.Spec
+	if current.Labels == nil {
+		current.Labels = map[string]string{}
+	}
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+	for key, value := range repository.Labels {
+		changed = changed || current.Labels[key] != value
+		current.Labels[key] = value
+	}
+	for key, value := range repository.Annotations {
+		changed = changed || current.Annotations[key] != value
+		current.Annotations[key] = value
+	}
+	if changed {
+		if err := r.Update(ctx, current); err != nil {
+			return entry, fmt.Errorf("failed to update ResticRepository %s/%s: %w", namespace, repository.Name, err)
+		}
+	}
+
+	entry.Phase = backupv1alpha1.TemplatedRepositoryPending
+	if condition := conditions.GetCondition(current.Status.Conditions, backupv1alpha1.ConditionReady); condition != nil {
+		if condition.Status == metav1.ConditionTrue {
+			entry.Phase = backupv1alpha1.TemplatedRepositoryReady
+		} else {
+			entry.Message = condition.Message
+		}
+	}
+	return entry, nil
+}
+
+// runProvisioner creates the provisioner Job of a tenant namespace and returns the
+// phase and message of the tenant while the Job runs or after it failed. Both are empty
+// once it succeeded.
+func (r *RepositoryTemplateReconciler) runProvisioner(ctx context.Context, template *backupv1alpha1.RepositoryTemplate,
+	namespace, secretName string) (backupv1alpha1.TemplatedRepositoryPhase, string, error) {
+	job := buildProvisionerJob(template, namespace, secretName)
+	if err := controllerutil.SetControllerReference(template, job, r.Scheme); err != nil {
+		return "", "", fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	existing := &batchv1.Job{}
+	created, err := createOrAdopt(ctx, r.Client, template, job, existing)
+	if err != nil {
+		if errors.Is(err, errNotControlled) {
+			return backupv1alpha1.TemplatedRepositoryFailed, fmt.Sprintf("Job %s already exists and belongs to another resource", job.Name), nil
+		}
+		return "", "", fmt.Errorf("failed to create provisioner Job %s: %w", job.Name, err)
+	}
+	if created {
+		r.Recorder.Event(template, corev1.EventTypeNormal, "ProvisionerStarted",
+			fmt.Sprintf("Provisioning the credentials of namespace %s, see job %s", namespace, job.Name))
+		return backupv1alpha1.TemplatedRepositoryProvisioning, fmt.Sprintf("Provisioner Job %s is running", job.Name), nil
+	}
+
+	finished, succeeded, _ := jobFinished(existing)
+	switch {
+	case !finished:
+		return backupv1alpha1.TemplatedRepositoryProvisioning, fmt.Sprintf("Provisioner Job %s is running", job.Name), nil
+	case !succeeded:
+		return backupv1alpha1.TemplatedRepositoryFailed, fmt.Sprintf("Provisioner Job %s failed", job.Name), nil
+	}
+	return "", "", nil
+}
+
+// ensureCredentials creates or updates the credentials Secret of a tenant repository.
+// Keys of the source Secret are kept in sync, the password is only set if the Secret
+// has none, so the encryption key of an existing repository never changes.
+func (r *RepositoryTemplateReconciler) ensureCredentials(ctx context.Context, template *backupv1alpha1.RepositoryTemplate,
+	repository *backupv1alpha1.ResticRepository, secret *corev1.Secret) error {
+	credentials := template.Spec.Credentials
+	passwordKey := repositoryCredentialKeys(repository).Password
+
+	var source map[string][]byte
+	if ref := credentials.SourceSecretRef; ref != nil {
+		sourceSecret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: template.Namespace}, sourceSecret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return fmt.Errorf("%w: %s", errSourceSecretNotFound, ref.Name)
+			}
+			return fmt.Errorf("failed to get source Secret %s: %w", ref.Name, err)
+		}
+		source = sourceSecret.Data
+	}
+
+	create := secret == nil
+	if create {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      repository.Spec.CredentialsSecretRef.Name,
+				Namespace: repository.Namespace,
+				Labels:    repositoryTemplateLabels(template),
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+
+	changed := false
+	for key, value := range source {
+		if current, ok := secret.Data[key]; bytes.Equal(current, value) || (key == passwordKey && ok) {
+			continue
+		}
+		secret.Data[key] = value
+		changed = true
+	}
+	if _, ok := secret.Data[passwordKey]; !ok && (credentials.GeneratePassword == nil || *credentials.GeneratePassword) {
+		password, err := generatePassword()
+		if err != nil {
+			return err
+		}
+		secret.Data[passwordKey] = []byte(password)
+		changed = true
+	}
+
+	switch {
+	case create:
+		if err := r.Create(ctx, secret); err != nil {
+			return fmt.Errorf("failed to create credentials Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		r.Recorder.Event(template, corev1.EventTypeNormal, "CredentialsCreated",
+			fmt.Sprintf("Created credentials Secret %s/%s", secret.Namespace, secret.Name))
+	case changed:
+		if err := r.Update(ctx, secret); err != nil {
+			return fmt.Errorf("failed to update credentials Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+	}
+	return nil
+}
+
+// buildTemplatedRepository builds the ResticRepository of a tenant namespace.
+func buildTemplatedRepository(template *backupv1alpha1.RepositoryTemplate, namespace string) *backupv1alpha1.ResticRepository {
+	labels := maps.Clone(template.Spec.Template.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	maps.Copy(labels, repositoryTemplateLabels(template))
+
+	spec := template.Spec.Template.Spec.DeepCopy()
+	spec.RepositoryURL = strings.ReplaceAll(spec.RepositoryURL, repositoryTemplateNamespacePlaceholder, namespace)
+	spec.DeletionConfirmation = strings.ReplaceAll(spec.DeletionConfirmation, repositoryTemplateNamespacePlaceholder, namespace)
+
+	return &backupv1alpha1.ResticRepository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        repositoryTemplateName(template),
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: maps.Clone(template.Spec.Template.Annotations),
+		},
+		Spec: *spec,
+	}
+}
+
+// buildProvisionerJob builds the Job provisioning the credentials Secret of a tenant.
+func buildProvisionerJob(template *backupv1alpha1.RepositoryTemplate, namespace, secretName string) *batchv1.Job {
+	provisioner := template.Spec.Credentials.Provisioner
+	backoffLimit := int32(2)
+	activeDeadline := provisionerActiveDeadline
+	ttl := provisionerJobTTL
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":       "restic-backup-operator",
+		"app.kubernetes.io/component":  "credentials-provisioner",
+		"app.kubernetes.io/managed-by": "restic-backup-operator",
+		repositoryTemplateLabel:        template.Name,
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      provisionerJobName(template.Name, namespace),
+			Namespace: template.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &activeDeadline,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: provisioner.ServiceAccountName,
+					Containers: []corev1.Container{{
+						Name:    "provisioner",
+						Image:   provisioner.Image,
+						Command: provisioner.Command,
+						Args:    provisioner.Args,
+						Env: []corev1.EnvVar{
+							{Name: "TENANT_NAMESPACE", Value: namespace},
+							{Name: "CREDENTIALS_SECRET", Value: secretName},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// provisionerJobName returns the name of the provisioner Job of a tenant namespace.
+// Long names are shortened and suffixed with a hash to stay unique.
+func provisionerJobName(templateName, namespace string) string {
+	name := "provision-" + templateName + "-" + namespace
+	if len(name) <= maxProvisionerJobNameLength {
+		return name
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name))
+	suffix := fmt.Sprintf("-%08x", hash.Sum32())
+	return name[:maxProvisionerJobNameLength-len(suffix)] + suffix
+}
+
+// repositoryTemplateName returns the name of the ResticRepositories of a template.
+func repositoryTemplateName(template *backupv1alpha1.RepositoryTemplate) string {
+	if template.Spec.RepositoryName != "" {
+		return template.Spec.RepositoryName
+	}
+	return "restic-repository"
+}
+
+// repositoryTemplateLabels returns the labels marking the objects created for a template.
+func repositoryTemplateLabels(template *backupv1alpha1.RepositoryTemplate) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by":   "restic-backup-operator",
+		repositoryTemplateLabel:          template.Name,
+		repositoryTemplateNamespaceLabel: template.Namespace,
+	}
+}
+
+// managedByTemplate reports whether an object was created for the template.
+func managedByTemplate(obj client.Object, template *backupv1alpha1.RepositoryTemplate) bool {
+	labels := obj.GetLabels()
+	return labels[repositoryTemplateLabel] == template.Name && labels[repositoryTemplateNamespaceLabel] == template.Namespace
+}
+
+// generatePassword returns a random repository password.
+func generatePassword() (string, error) {
+	buf := make([]byte, generatedPasswordBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func (r *RepositoryTemplateReconciler) setCondition(template *backupv1alpha1.RepositoryTemplate, condition metav1.Condition) {
+	conditions.SetCondition(&template.Status.Conditions, condition)
+}
+
+// templateForRepository maps a ResticRepository created for a template to the template.
+func templateForRepository(_ context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if labels[repositoryTemplateLabel] == "" || labels[repositoryTemplateNamespaceLabel] == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Name:      labels[repositoryTemplateLabel],
+		Namespace: labels[repositoryTemplateNamespaceLabel],
+	}}}
+}
+
+// templatesForNamespace enqueues all RepositoryTemplates when a namespace changes, as
+// any of them may select it.
+func (r *RepositoryTemplateReconciler) templatesForNamespace(ctx context.Context, _ client.Object) []reconcile.Request {
+	templates := &backupv1alpha1.RepositoryTemplateList{}
+	if err := r.List(ctx, templates); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list repository templates")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(templates.Items))
+	for _, template := range templates.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&template)})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RepositoryTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&backupv1alpha1.RepositoryTemplate{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Owns(&batchv1.Job{}).
+		Watches(&backupv1alpha1.ResticRepository{}, handler.EnqueueRequestsFromMapFunc(templateForRepository)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.templatesForNamespace)).
+		Complete(r)
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("RepositoryTemplate Controller", func() {
+	var (
+		ctx        context.Context
+		testScheme *runtime.Scheme
+		template   *backupv1alpha1.RepositoryTemplate
+		objects    []client.Object
+	)
+	key := types.NamespacedName{Name: "tenants", Namespace: "backup-system"}
+	tenantKey := types.NamespacedName{Name: "restic-repository", Namespace: "team-a"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		template = &backupv1alpha1.RepositoryTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: backupv1alpha1.RepositoryTemplateSpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"backup": "enabled"}},
+				RepositoryName:    tenantKey.Name,
+				Credentials: backupv1alpha1.RepositoryTemplateCredentials{
+					SourceSecretRef: &corev1.LocalObjectReference{Name: "s3-credentials"},
+				},
+				Template: backupv1alpha1.RepositoryTemplateResource{
+					Labels: map[string]string{"team": "platform"},
+					Spec: backupv1alpha1.ResticRepositorySpec{
+						RepositoryURL:        "s3:s3.amazonaws.com/backups/{{namespace}}",
+						CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "restic-credentials"},
+						DeletionPolicy:       "Delete",
+						DeletionConfirmation: "s3:s3.amazonaws.com/backups/{{namespace}}",
+					},
+				},
+			},
+		}
+		objects = []client.Object{
+			template,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"backup": "enabled"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "s3-credentials", Namespace: key.Namespace},
+				Data: map[string][]byte{
+					"AWS_ACCESS_KEY_ID":     []byte("access"),
+					"AWS_SECRET_ACCESS_KEY": []byte("secret"),
+				},
+			},
+		}
+	})
+
+	reconcile := func(c client.Client) ctrl.Result {
+		reconciler := &RepositoryTemplateReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(10)}
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+	build := func() client.Client {
+		return fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).
+			WithStatusSubresource(&backupv1alpha1.RepositoryTemplate{}, &backupv1alpha1.ResticRepository{}).Build()
+	}
+
+	It("creates the credentials and the repository of every selected namespace", func() {
+		c := build()
+		result := reconcile(c)
+		Expect(result.RequeueAfter).To(Equal(repositoryTemplateRequeueInterval))
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "restic-credentials", Namespace: "team-a"}, secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("AWS_ACCESS_KEY_ID", []byte("access")))
+		Expect(secret.Data["RESTIC_PASSWORD"]).To(HaveLen(43))
+		Expect(secret.Labels).To(HaveKeyWithValue(repositoryTemplateLabel, key.Name))
+
+		repository := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(ctx, tenantKey, repository)).To(Succeed())
+		Expect(repository.Spec.RepositoryURL).To(Equal("s3:s3.amazonaws.com/backups/team-a"))
+		Expect(repository.Spec.DeletionConfirmation).To(Equal(repository.Spec.RepositoryURL))
+		Expect(repository.Labels).To(HaveKeyWithValue("team", "platform"))
+		Expect(repository.Labels).To(HaveKeyWithValue(repositoryTemplateNamespaceLabel, key.Namespace))
+
+		Expect(c.Get(ctx, types.NamespacedName{Name: tenantKey.Name, Namespace: "team-b"}, &backupv1alpha1.ResticRepository{})).NotTo(Succeed())
+
+		Expect(c.Get(ctx, key, template)).To(Succeed())
+		Expect(template.Status.TotalRepositories).To(Equal(int32(1)))
+		Expect(template.Status.Repositories).To(ConsistOf(backupv1alpha1.TemplatedRepository{
+			Namespace: "team-a", Phase: backupv1alpha1.TemplatedRepositoryPending,
+		}))
+		condition := conditions.GetCondition(template.Status.Conditions, backupv1alpha1.ConditionReady)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal("Provisioning"))
+	})
+
+	It("keeps the password of an existing credentials Secret", func() {
+		objects = append(objects, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "restic-credentials", Namespace: "team-a"},
+			Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("existing")},
+		})
+		c := build()
+		reconcile(c)
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "restic-credentials", Namespace: "team-a"}, secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("RESTIC_PASSWORD", []byte("existing")))
+		Expect(secret.Data).To(HaveKeyWithValue("AWS_SECRET_ACCESS_KEY", []byte("secret")))
+	})
+
+	It("runs the provisioner when the credentials Secret is missing", func() {
+		template.Spec.Credentials = backupv1alpha1.RepositoryTemplateCredentials{
+			Provisioner: &backupv1alpha1.CredentialsProvisioner{Image: "vault:1.17", Command: []string{"/provision.sh"}},
+		}
+		c := build()
+		reconcile(c)
+
+		job := &batchv1.Job{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: provisionerJobName(key.Name, "team-a"), Namespace: key.Namespace}, job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElements(
+			corev1.EnvVar{Name: "TENANT_NAMESPACE", Value: "team-a"},
+			corev1.EnvVar{Name: "CREDENTIALS_SECRET", Value: "restic-credentials"},
+		))
+		Expect(c.Get(ctx, tenantKey, &backupv1alpha1.ResticRepository{})).NotTo(Succeed())
+
+		Expect(c.Get(ctx, key, template)).To(Succeed())
+		Expect(template.Status.Repositories[0].Phase).To(Equal(backupv1alpha1.TemplatedRepositoryProvisioning))
+	})
+
+	It("does not take over a repository it did not create", func() {
+		objects = append(objects, &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: tenantKey.Name, Namespace: tenantKey.Namespace},
+			Spec:       backupv1alpha1.ResticRepositorySpec{RepositoryURL: "local:/data"},
+		})
+		c := build()
+		reconcile(c)
+
+		repository := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(ctx, tenantKey, repository)).To(Succeed())
+		Expect(repository.Spec.RepositoryURL).To(Equal("local:/data"))
+
+		Expect(c.Get(ctx, key, template)).To(Succeed())
+		Expect(template.Status.Repositories[0].Phase).To(Equal(backupv1alpha1.TemplatedRepositoryFailed))
+		condition := conditions.GetCondition(template.Status.Conditions, backupv1alpha1.ConditionReady)
+		Expect(condition.Reason).To(Equal("ProvisioningFailed"))
+	})
+
+	It("becomes ready once every repository is ready", func() {
+		c := build()
+		reconcile(c)
+
+		repository := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(ctx, tenantKey, repository)).To(Succeed())
+		conditions.SetCondition(&repository.Status.Conditions, conditions.ReadyCondition("Initialized", "Repository is ready"))
+		Expect(c.Status().Update(ctx, repository)).To(Succeed())
+
+		Expect(reconcile(c).RequeueAfter).To(BeZero())
+		Expect(c.Get(ctx, key, template)).To(Succeed())
+		Expect(template.Status.ReadyRepositories).To(Equal(int32(1)))
+		condition := conditions.GetCondition(template.Status.Conditions, backupv1alpha1.ConditionReady)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+	})
+})
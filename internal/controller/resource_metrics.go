@@ -0,0 +1,178 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// restorePhases are the phases reported by the restic_restore_phase metric.
+var restorePhases = []backupv1alpha1.RestorePhase{
+	backupv1alpha1.RestorePhasePending,
+	backupv1alpha1.RestorePhaseQueued,
+	backupv1alpha1.RestorePhaseInProgress,
+	backupv1alpha1.RestorePhaseCompleted,
+	backupv1alpha1.RestorePhaseFailed,
+	backupv1alpha1.RestorePhaseScheduled,
+}
+
+// recordBackupMetrics updates the metrics of a backup from its status.
+// Series without data yet, e.g. before the first successful run, are not exported.
+func recordBackupMetrics(backup *backupv1alpha1.ResticBackup) {
+	status := backup.Status
+	if status.LastSuccessfulBackup != nil {
+		backupLastSuccessTimestamp.WithLabelValues(backup.Namespace, backup.Name).Set(float64(status.LastSuccessfulBackup.Unix()))
+	}
+	if last := status.LastBackup; last != nil && last.StartTime != nil && last.CompletionTime != nil {
+		backupLastDuration.WithLabelValues(backup.Namespace, backup.Name).Set(last.CompletionTime.Sub(last.StartTime.Time).Seconds())
+	}
+	if status.Statistics != nil {
+		backupConsecutiveFailures.WithLabelValues(backup.Namespace, backup.Name).Set(float64(status.Statistics.ConsecutiveFailures))
+	}
+}
+
+// deleteBackupMetrics removes the series of a backup.
+func deleteBackupMetrics(backup *backupv1alpha1.ResticBackup) {
+	backupLastSuccessTimestamp.DeleteLabelValues(backup.Namespace, backup.Name)
+	backupLastDuration.DeleteLabelValues(backup.Namespace, backup.Name)
+	backupConsecutiveFailures.DeleteLabelValues(backup.Namespace, backup.Name)
+	backupMissed.DeleteLabelValues(backup.Namespace, backup.Name)
+}
+
+// recordRepositoryMetrics updates the metrics of a repository from freshly gathered statistics.
+func recordRepositoryMetrics(key types.NamespacedName, stats *restic.RepoStats, at time.Time) {
+	repositorySnapshots.WithLabelValues(key.Namespace, key.Name).Set(float64(stats.SnapshotCount))
+	repositorySize.WithLabelValues(key.Namespace, key.Name).Set(float64(stats.TotalSize))
+	repositoryStatisticsUpdated.WithLabelValues(key.Namespace, key.Name).Set(float64(at.Unix()))
+}
+
+// deleteRepositoryMetrics removes the series of a repository.
+func deleteRepositoryMetrics(key types.NamespacedName) {
+	repositorySnapshots.DeleteLabelValues(key.Namespace, key.Name)
+	repositorySize.DeleteLabelValues(key.Namespace, key.Name)
+	repositoryStatisticsUpdated.DeleteLabelValues(key.Namespace, key.Name)
+	deleteRetentionReportMetrics(key)
+	deleteBackendProbeMetrics(key)
+}
+
+// retentionReportStatuses are the statuses reported by the restic_repository_retention_status metric.
+var retentionReportStatuses = []backupv1alpha1.RetentionReportStatus{
+	backupv1alpha1.RetentionReportOK,
+	backupv1alpha1.RetentionReportOverRetention,
+	backupv1alpha1.RetentionReportUnderRetention,
+	backupv1alpha1.RetentionReportUnknown,
+}
+
+// recordRetentionReportMetrics replaces the retention report series of a repository, so
+// that series of removed backups disappear.
+func recordRetentionReportMetrics(key types.NamespacedName, report *backupv1alpha1.RetentionReport) {
+	deleteRetentionReportMetrics(key)
+	for _, entry := range report.Backups {
+		retentionActualSnapshots.WithLabelValues(key.Namespace, key.Name, entry.Backup).Set(float64(entry.ActualSnapshots))
+		if entry.ExpectedSnapshots != nil {
+			retentionExpectedSnapshots.WithLabelValues(key.Namespace, key.Name, entry.Backup).Set(float64(*entry.ExpectedSnapshots))
+		}
+		for _, status := range retentionReportStatuses {
+			value := 0.0
+			if status == entry.Status {
+				value = 1
+			}
+			retentionStatus.WithLabelValues(key.Namespace, key.Name, entry.Backup, string(status)).Set(value)
+		}
+	}
+}
+
+// deleteRetentionReportMetrics removes the retention report series of a repository.
+func deleteRetentionReportMetrics(key types.NamespacedName) {
+	labels := prometheus.Labels{"namespace": key.Namespace, "name": key.Name}
+	retentionExpectedSnapshots.DeletePartialMatch(labels)
+	retentionActualSnapshots.DeletePartialMatch(labels)
+	retentionStatus.DeletePartialMatch(labels)
+}
+
+// recordBackendProbe records the result of a backend latency probe of a repository.
+func recordBackendProbe(key types.NamespacedName, backend string, latency time.Duration, err error) {
+	result, up := "success", 1.0
+	if err != nil {
+		result, up = "failure", 0
+	} else {
+		repositoryBackendLatency.WithLabelValues(key.Namespace, key.Name, backend).Observe(latency.Seconds())
+	}
+	repositoryBackendProbes.WithLabelValues(key.Namespace, key.Name, backend, result).Inc()
+	repositoryBackendUp.WithLabelValues(key.Namespace, key.Name, backend).Set(up)
+}
+
+// deleteBackendProbeMetrics removes the backend latency probe series of a repository.
+func deleteBackendProbeMetrics(key types.NamespacedName) {
+	labels := prometheus.Labels{"namespace": key.Namespace, "name": key.Name}
+	repositoryBackendLatency.DeletePartialMatch(labels)
+	repositoryBackendProbes.DeletePartialMatch(labels)
+	repositoryBackendUp.DeletePartialMatch(labels)
+}
+
+// recordRestorePhase sets the phase series of a restore, 1 for its current phase and 0 for the others.
+func recordRestorePhase(restore *backupv1alpha1.ResticRestore) {
+	if restore.Status.Phase == "" {
+		return
+	}
+	for _, phase := range restorePhases {
+		value := 0.0
+		if phase == restore.Status.Phase {
+			value = 1
+		}
+		restorePhase.WithLabelValues(restore.Namespace, restore.Name, string(phase)).Set(value)
+	}
+}
+
+// deleteRestoreMetrics removes the phase series of a restore.
+func deleteRestoreMetrics(key types.NamespacedName) {
+	restorePhase.DeletePartialMatch(prometheus.Labels{"namespace": key.Namespace, "name": key.Name})
+}
+
+// recordVerificationResult sets whether the last verification passed.
+func recordVerificationResult(verification *backupv1alpha1.BackupVerification, passed bool) {
+	value := 0.0
+	if passed {
+		value = 1
+	}
+	verificationPassed.WithLabelValues(verification.Namespace, verification.Name).Set(value)
+}
+
+// recordVerificationRatios sets the size and file count of the verified snapshot
+// relative to its source. A verification without snapshot removes the series.
+func recordVerificationRatios(verification *backupv1alpha1.BackupVerification, result *verificationResult) {
+	if result.snapshotID == "" {
+		verificationSizeRatio.DeleteLabelValues(verification.Namespace, verification.Name)
+		verificationFileCountRatio.DeleteLabelValues(verification.Namespace, verification.Name)
+		return
+	}
+	verificationSizeRatio.WithLabelValues(verification.Namespace, verification.Name).Set(result.sizeRatio())
+	verificationFileCountRatio.WithLabelValues(verification.Namespace, verification.Name).Set(result.fileCountRatio())
+}
+
+// deleteVerificationMetrics removes the series of a backup verification.
+func deleteVerificationMetrics(key types.NamespacedName) {
+	verificationPassed.DeleteLabelValues(key.Namespace, key.Name)
+	verificationSizeRatio.DeleteLabelValues(key.Namespace, key.Name)
+	verificationFileCountRatio.DeleteLabelValues(key.Namespace, key.Name)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+var _ = Describe("Resource metrics", func() {
+	It("should count consecutive backup failures until the next success", func() {
+		backup := &backupv1alpha1.ResticBackup{ObjectMeta: metav1.ObjectMeta{Name: "metrics", Namespace: "media"}}
+		finishedAt := time.Now()
+		job := &batchv1.Job{Status: batchv1.JobStatus{StartTime: &metav1.Time{Time: finishedAt.Add(-90 * time.Second)}}}
+		defer deleteBackupMetrics(backup)
+
+		recordBackupRun(&backup.Status, job, false, finishedAt, nil)
+		recordBackupRun(&backup.Status, job, false, finishedAt, nil)
+		recordBackupMetrics(backup)
+		Expect(backup.Status.Statistics.ConsecutiveFailures).To(Equal(int32(2)))
+		Expect(testutil.ToFloat64(backupConsecutiveFailures.WithLabelValues("media", "metrics"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(backupLastDuration.WithLabelValues("media", "metrics"))).To(Equal(90.0))
+
+		recordBackupRun(&backup.Status, job, true, finishedAt, nil)
+		recordBackupMetrics(backup)
+		Expect(backup.Status.Statistics.ConsecutiveFailures).To(BeZero())
+		Expect(testutil.ToFloat64(backupConsecutiveFailures.WithLabelValues("media", "metrics"))).To(BeZero())
+		Expect(testutil.ToFloat64(backupLastSuccessTimestamp.WithLabelValues("media", "metrics"))).To(Equal(float64(finishedAt.Unix())))
+
+		deleteBackupMetrics(backup)
+		Expect(testutil.CollectAndCount(backupConsecutiveFailures)).To(BeZero())
+	})
+
+	It("should export repository statistics", func() {
+		// Reconciles in other specs record statistics of their repositories
+		repositorySnapshots.Reset()
+		key := types.NamespacedName{Namespace: "backup", Name: "metrics"}
+		updatedAt := time.Date(2024, 1, 14, 2, 0, 0, 0, time.UTC)
+		recordRepositoryMetrics(key, &restic.RepoStats{TotalSize: 4096, SnapshotCount: 12}, updatedAt)
+		Expect(testutil.ToFloat64(repositorySnapshots.WithLabelValues("backup", "metrics"))).To(Equal(12.0))
+		Expect(testutil.ToFloat64(repositorySize.WithLabelValues("backup", "metrics"))).To(Equal(4096.0))
+		Expect(testutil.ToFloat64(repositoryStatisticsUpdated.WithLabelValues("backup", "metrics"))).To(Equal(float64(updatedAt.Unix())))
+
+		deleteRepositoryMetrics(key)
+		Expect(testutil.CollectAndCount(repositorySnapshots)).To(BeZero())
+	})
+
+	It("should report only the current restore phase", func() {
+		restore := &backupv1alpha1.ResticRestore{ObjectMeta: metav1.ObjectMeta{Name: "metrics", Namespace: "media"}}
+		restore.Status.Phase = backupv1alpha1.RestorePhaseInProgress
+		recordRestorePhase(restore)
+		Expect(testutil.ToFloat64(restorePhase.WithLabelValues("media", "metrics", "InProgress"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(restorePhase.WithLabelValues("media", "metrics", "Pending"))).To(BeZero())
+
+		restore.Status.Phase = backupv1alpha1.RestorePhaseCompleted
+		recordRestorePhase(restore)
+		Expect(testutil.ToFloat64(restorePhase.WithLabelValues("media", "metrics", "InProgress"))).To(BeZero())
+		Expect(testutil.ToFloat64(restorePhase.WithLabelValues("media", "metrics", "Completed"))).To(Equal(1.0))
+
+		deleteRestoreMetrics(types.NamespacedName{Namespace: "media", Name: "metrics"})
+		Expect(testutil.CollectAndCount(restorePhase)).To(BeZero())
+	})
+})
@@ -0,0 +1,167 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// resourcesDumpPath is where the resources container writes the objects, one file
+	// per namespace and kind.
+	resourcesDumpPath = dumpMountPath + "/resources"
+	// resourcesTokenVolumeName is the volume with the API token of the resources
+	// ServiceAccount, mounted only into the resources container.
+	resourcesTokenVolumeName = "resources-token"
+	// serviceAccountTokenPath is where kubectl looks for the in-cluster credentials.
+	serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// defaultKubectlImage is the kubectl image used if a resources source sets no image.
+	defaultKubectlImage = "alpine/kubectl:1.34.1"
+)
+
+// defaultResourceKinds are the kinds dumped if a resources source lists none: the
+// manifests of a typical application, without Secrets.
+var defaultResourceKinds = []backupv1alpha1.ResourceKind{
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "apps", Kind: "StatefulSet"},
+	{Group: "apps", Kind: "DaemonSet"},
+	{Group: "batch", Kind: "CronJob"},
+	{Kind: "Service"},
+	{Kind: "ConfigMap"},
+	{Kind: "PersistentVolumeClaim"},
+	{Kind: "ServiceAccount"},
+	{Group: "networking.k8s.io", Kind: "Ingress"},
+}
+
+// resourcesServiceAccountName returns the ServiceAccount the resources of a backup are
+// dumped with.
+func resourcesServiceAccountName(backup *backupv1alpha1.ResticBackup) string {
+	return serviceAccountName(backup) + "-resources"
+}
+
+// resourceKindArg returns the kind as accepted by kubectl get, e.g. Deployment.v1.apps.
+func resourceKindArg(kind backupv1alpha1.ResourceKind) string {
+	switch {
+	case kind.Group == "":
+		return kind.Kind
+	case kind.Version == "":
+		return kind.Kind + "." + kind.Group
+	default:
+		return kind.Kind + "." + kind.Version + "." + kind.Group
+	}
+}
+
+// validateResourcesSource checks the label selector of a resources source.
+func validateResourcesSource(backup *backupv1alpha1.ResticBackup) error {
+	source := backup.Spec.Source.Resources
+	if source == nil || source.LabelSelector == nil {
+		return nil
+	}
+	if _, err := metav1.LabelSelectorAsSelector(source.LabelSelector); err != nil {
+		return fmt.Errorf("invalid label selector of the resources source: %w", err)
+	}
+	return nil
+}
+
+// buildResourcesScript builds the shell script dumping the selected objects to
+// /dump/resources/<namespace>/<kind>.yaml. A kind kubectl can't list fails the dump.
+func buildResourcesScript(backup *backupv1alpha1.ResticBackup) string {
+	source := backup.Spec.Source.Resources
+	namespaces := source.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{backup.Namespace}
+	}
+	kinds := source.Kinds
+	if len(kinds) == 0 {
+		kinds = defaultResourceKinds
+	}
+	var selector []string
+	if source.LabelSelector != nil {
+		if parsed, err := metav1.LabelSelectorAsSelector(source.LabelSelector); err == nil && !parsed.Empty() {
+			selector = []string{"--selector", parsed.String()}
+		}
+	}
+
+	lines := []string{"set -e"}
+	for _, namespace := range namespaces {
+		dir := path.Join(resourcesDumpPath, namespace)
+		lines = append(lines, "mkdir -p "+shellQuoteArgs([]string{dir}))
+		for _, kind := range kinds {
+			arg := resourceKindArg(kind)
+			args := append([]string{"kubectl", "get", arg, "--namespace", namespace}, selector...)
+			args = append(args, "--output", "yaml")
+			lines = append(lines, fmt.Sprintf("%s > %s", shellQuoteArgs(args), shellQuoteArgs([]string{path.Join(dir, arg+".yaml")})))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// buildResourcesContainer builds the container dumping the selected objects to the dump
+// volume. It runs as init container with the token of the resources ServiceAccount, the
+// restic container gets no token.
+func buildResourcesContainer(backup *backupv1alpha1.ResticBackup, securityContext *corev1.SecurityContext, resources corev1.ResourceRequirements) corev1.Container {
+	image := backup.Spec.Source.Resources.Image
+	if image == "" {
+		image = defaultKubectlImage
+	}
+	return corev1.Container{
+		Name:            "resources",
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"/bin/sh", "-c"},
+		Args:            []string{buildResourcesScript(backup)},
+		// kubectl caches the API discovery in the home directory
+		Env: []corev1.EnvVar{{Name: "HOME", Value: "/tmp"}},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: dumpVolumeName, MountPath: dumpMountPath},
+			{Name: resourcesTokenVolumeName, MountPath: serviceAccountTokenPath, ReadOnly: true},
+		},
+		SecurityContext: securityContext,
+		Resources:       resources,
+	}
+}
+
+// resourcesTokenVolume returns the projected volume with the in-cluster credentials of
+// the pod's ServiceAccount, like the volume Kubernetes mounts into every container when
+// the token is automounted.
+func resourcesTokenVolume() corev1.Volume {
+	return corev1.Volume{
+		Name: resourcesTokenVolumeName,
+		VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+			Sources: []corev1.VolumeProjection{
+				{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token", ExpirationSeconds: int64Ptr(3600)}},
+				{ConfigMap: &corev1.ConfigMapProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "kube-root-ca.crt"},
+					Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+				}},
+				{DownwardAPI: &corev1.DownwardAPIProjection{
+					Items: []corev1.DownwardAPIVolumeFile{{
+						Path:     "namespace",
+						FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "metadata.namespace"},
+					}},
+				}},
+			},
+		}},
+	}
+}
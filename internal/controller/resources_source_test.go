@@ -0,0 +1,121 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Resources source", func() {
+	var (
+		reconciler *ResticBackupReconciler
+		repository *backupv1alpha1.ResticRepository
+		backup     *backupv1alpha1.ResticBackup
+	)
+
+	BeforeEach(func() {
+		reconciler = &ResticBackupReconciler{}
+		repository = &backupv1alpha1.ResticRepository{
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:https://s3.example.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "restic-credentials"},
+			},
+		}
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "nextcloud", Namespace: "cloud"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Source: backupv1alpha1.BackupSource{
+					PVC:       &backupv1alpha1.PVCSource{ClaimName: "nextcloud-data"},
+					Resources: &backupv1alpha1.ResourcesSource{},
+				},
+			},
+		}
+	})
+
+	It("should dump the default kinds of the backup namespace", func() {
+		script := buildResourcesScript(backup)
+		Expect(script).To(HavePrefix("set -e\nmkdir -p '/dump/resources/cloud'\n"))
+		Expect(script).To(ContainSubstring(
+			"'kubectl' 'get' 'Deployment.apps' '--namespace' 'cloud' '--output' 'yaml' > '/dump/resources/cloud/Deployment.apps.yaml'"))
+		Expect(script).To(ContainSubstring("'ConfigMap'"))
+		Expect(script).NotTo(ContainSubstring("Secret"))
+	})
+
+	It("should dump the selected kinds of the selected namespaces", func() {
+		backup.Spec.Source.Resources = &backupv1alpha1.ResourcesSource{
+			Namespaces:    []string{"cloud", "cloud-db"},
+			Kinds:         []backupv1alpha1.ResourceKind{{Kind: "Secret"}, {Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}},
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nextcloud"}},
+		}
+		script := buildResourcesScript(backup)
+		Expect(script).To(ContainSubstring(
+			"'kubectl' 'get' 'Secret' '--namespace' 'cloud-db' '--selector' 'app=nextcloud' '--output' 'yaml' > '/dump/resources/cloud-db/Secret.yaml'"))
+		Expect(script).To(ContainSubstring("'Certificate.v1.cert-manager.io' '--namespace' 'cloud'"))
+		Expect(script).NotTo(ContainSubstring("Deployment"))
+	})
+
+	It("should give only the resources container the API token", func() {
+		podSpec := reconciler.buildPodSpec(backup, repository, "restic", backupScript{})
+
+		Expect(podSpec.Spec.ServiceAccountName).To(Equal("resticbackup-nextcloud-resources"))
+		Expect(podSpec.Spec.AutomountServiceAccountToken).To(HaveValue(BeFalse()))
+		Expect(podSpec.Spec.Volumes).To(ContainElement(HaveField("Name", resourcesTokenVolumeName)))
+		Expect(podSpec.Spec.InitContainers).To(HaveLen(1))
+		dump := podSpec.Spec.InitContainers[0]
+		Expect(dump.Name).To(Equal("resources"))
+		Expect(dump.Image).To(Equal(defaultKubectlImage))
+		Expect(dump.VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name: resourcesTokenVolumeName, MountPath: serviceAccountTokenPath, ReadOnly: true,
+		}))
+		restic := podSpec.Spec.Containers[0]
+		Expect(restic.VolumeMounts).NotTo(ContainElement(HaveField("Name", resourcesTokenVolumeName)))
+		Expect(restic.VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name: dumpVolumeName, MountPath: dumpMountPath, ReadOnly: true,
+		}))
+	})
+
+	It("should back up the dump together with the PVC", func() {
+		cmd := reconciler.buildBackupCommand(backup, "nextcloud", nil)
+		Expect(cmd[len(cmd)-2:]).To(Equal([]string{sourceMountPath, dumpMountPath}))
+	})
+
+	It("should create the resources ServiceAccount without token", func() {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(testScheme).Build()
+		reconciler = &ResticBackupReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(10)}
+
+		Expect(reconciler.reconcileServiceAccount(context.Background(), backup)).To(Succeed())
+		serviceAccount := &corev1.ServiceAccount{}
+		Expect(c.Get(context.Background(), types.NamespacedName{Name: "resticbackup-nextcloud-resources", Namespace: "cloud"}, serviceAccount)).To(Succeed())
+		Expect(serviceAccount.AutomountServiceAccountToken).To(HaveValue(BeFalse()))
+		Expect(metav1.IsControlledBy(serviceAccount, backup)).To(BeTrue())
+	})
+})
@@ -19,27 +19,42 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/robfig/cron/v3"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
 	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+	"github.com/madic-creates/restic-backup-operator/internal/notifications"
+	"github.com/madic-creates/restic-backup-operator/internal/version"
 )
 
 const (
 	resticBackupFinalizer = "backup.resticbackup.io/resticbackup-finalizer"
+	// resticBackupLabel is set on all objects created for a ResticBackup.
+	resticBackupLabel = "backup.resticbackup.io/backup"
+	// backupTerminationGracePeriodSeconds gives restic time to finish writing and
+	// release its lock when a backup pod is terminated.
+	backupTerminationGracePeriodSeconds = 120
 )
 
 // ResticBackupReconciler reconciles a ResticBackup object
@@ -47,16 +62,54 @@ type ResticBackupReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	// StartupAudit, if set, delays reconciles until existing child objects are corrected.
+	StartupAudit *StartupAudit
+	// Notifications is used to clean up Pushgateway metrics of deleted backups.
+	Notifications *notifications.Manager
+	// APIReader reads the pods of backup jobs, which are not cached. Defaults to Client.
+	APIReader client.Reader
+	// PodExecutor executes exec hooks in application pods.
+	PodExecutor PodExecutor
+	// PodLogs reads the end of the logs of failed backup jobs for the status and events.
+	// If nil, failures are reported without logs.
+	PodLogs PodLogReader
+	// MaxConcurrentReconciles is the number of ResticBackups reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+	// Images resolves the default restic image, e.g. to a private mirror.
+	Images *ImageConfig
+	// JobDefaults sets the default active deadline and backoff limit of the jobs.
+	JobDefaults *JobDefaults
+	// HostnameChecker, if set, lists the repository snapshots after each new snapshot in
+	// the background to detect hostname mismatches.
+	HostnameChecker *HostnameChecker
+	// SnapshotCache, if set, is invalidated after each new snapshot.
+	SnapshotCache *SnapshotCache
+	// FeatureGates enables optional capabilities. If nil, the feature defaults apply.
+	FeatureGates *features.Gate
+	// MaxConcurrentBackups limits the number of backup jobs running cluster-wide. 0 means unlimited.
+	MaxConcurrentBackups int
+
+	// backupSlots serializes starting backup jobs while a concurrency limit applies.
+	backupSlots sync.Mutex
+	// retentionPrunes serializes creating the ResticPrunes requested by retention.
+	retentionPrunes sync.Mutex
 }
 
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticbackups,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticbackups/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticbackups/finalizers,verbs=update
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticprunes,verbs=get;list;watch;create;delete
 // +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;patch
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
 // +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop.
@@ -64,6 +117,11 @@ func (r *ResticBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	log := log.FromContext(ctx)
 	log.Info("Reconciling ResticBackup")
 
+	// Wait for the startup audit to correct existing child objects
+	if err := r.StartupAudit.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Fetch the ResticBackup instance
 	backup := &backupv1alpha1.ResticBackup{}
 	if err := r.Get(ctx, req.NamespacedName, backup); err != nil {
@@ -88,27 +146,69 @@ func (r *ResticBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 	}
 
+	// Back up the selected PVCs with a ResticBackup each
+	if backup.Spec.Source.PVCSelector != nil {
+		return r.reconcilePVCSelector(ctx, backup)
+	}
+
+	// Reject PVC sources that would mount two volumes at the same path
+	if err := validatePVCSources(backup); err != nil {
+		log.Error(err, "Invalid source paths")
+		r.setCondition(backup, conditions.NotReadyCondition("InvalidSourcePaths", err.Error()))
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "InvalidSourcePaths", err.Error())
+		if updateErr := r.Status().Update(ctx, backup); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := validateResourcesSource(backup); err != nil {
+		r.setCondition(backup, conditions.NotReadyCondition("InvalidResourcesSource", err.Error()))
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "InvalidResourcesSource", err.Error())
+		if updateErr := r.Status().Update(ctx, backup); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := validateSidecars(backup.Spec.JobConfig); err != nil {
+		r.setCondition(backup, conditions.NotReadyCondition("InvalidSidecars", err.Error()))
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "InvalidSidecars", err.Error())
+		if updateErr := r.Status().Update(ctx, backup); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// Validate and get referenced repository
 	repository, err := r.getRepository(ctx, backup)
+	setReferenceDenied(&backup.Status.Conditions, err)
 	if err != nil {
 		log.Error(err, "Failed to get repository")
-		r.setCondition(backup, conditions.NotReadyCondition("RepositoryNotFound", err.Error()))
-		r.Recorder.Event(backup, corev1.EventTypeWarning, "RepositoryNotFound", err.Error())
+		reason := referenceErrorReason(err, "RepositoryNotFound")
+		r.setCondition(backup, conditions.NotReadyCondition(reason, err.Error()))
+		r.Recorder.Event(backup, corev1.EventTypeWarning, reason, err.Error())
 		if updateErr := r.Status().Update(ctx, backup); updateErr != nil {
 			return ctrl.Result{}, updateErr
 		}
 		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
 	}
 
-	// Check repository is ready
+	// Check repository is ready, switching to the fallback repository if the
+	// primary has been down for too long
+	usedFallback := false
 	if !conditions.IsConditionTrue(repository.Status.Conditions, "Ready") {
-		log.Info("Repository not ready, requeuing")
-		r.setCondition(backup, conditions.NotReadyCondition("RepositoryNotReady", "Referenced repository is not ready"))
-		if err := r.Status().Update(ctx, backup); err != nil {
-			return ctrl.Result{}, err
+		fallback := r.getFallbackRepository(ctx, backup, repository)
+		if fallback == nil {
+			log.Info("Repository not ready, requeuing")
+			r.setCondition(backup, conditions.NotReadyCondition("RepositoryNotReady", "Referenced repository is not ready"))
+			if err := r.Status().Update(ctx, backup); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
 		}
-		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		repository = fallback
+		usedFallback = true
 	}
+	r.setFallbackCondition(backup, repository, usedFallback)
 
 	// Set RepositoryReady condition
 	conditions.SetCondition(&backup.Status.Conditions, metav1.Condition{
@@ -118,8 +218,41 @@ func (r *ResticBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		Message: "Referenced repository is ready",
 	})
 
-	// Reconcile CronJob
-	if err := r.reconcileCronJob(ctx, backup, repository); err != nil {
+	// Reconcile dedicated ServiceAccount
+	if err := r.reconcileServiceAccount(ctx, backup); err != nil {
+		log.Error(err, "Failed to reconcile ServiceAccount")
+		r.setCondition(backup, conditions.NotReadyCondition("ServiceAccountFailed", err.Error()))
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "ServiceAccountFailed", err.Error())
+		if updateErr := r.Status().Update(ctx, backup); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+	}
+
+	// Record the retention the backup jobs apply
+	backup.Status.EffectiveRetention = effectiveRetention(backup, repository)
+
+	// Record the source PVC for restores into other clusters
+	if err := r.updateSourcePVC(ctx, backup); err != nil {
+		log.Error(err, "Failed to record source PVC")
+	}
+
+	// Warn about backups in other namespaces storing the same data of a shared volume
+	if r.FeatureGates.Enabled(features.OverlappingBackupDetection) {
+		if err := r.checkOverlappingBackups(ctx, backup); err != nil {
+			log.Error(err, "Failed to check for overlapping backups")
+		}
+	} else {
+		meta.RemoveStatusCondition(&backup.Status.Conditions, backupv1alpha1.ConditionOverlappingBackup)
+	}
+
+	// Reconcile CronJob, or only render it for review
+	if backup.Spec.RenderOnly {
+		err = r.renderCronJob(ctx, backup, repository)
+	} else if err = r.reconcileCronJob(ctx, backup, repository); err == nil {
+		err = r.deleteRenderedManifest(ctx, backup)
+	}
+	if err != nil {
 		log.Error(err, "Failed to reconcile CronJob")
 		r.setCondition(backup, conditions.NotReadyCondition("CronJobFailed", err.Error()))
 		r.Recorder.Event(backup, corev1.EventTypeWarning, "CronJobFailed", err.Error())
@@ -129,14 +262,59 @@ func (r *ResticBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
 	}
 
+	// Reflect the backup window and blackout periods
+	window := evaluateBackupWindow(backup, time.Now())
+	r.setWindowCondition(backup, window)
+
 	// Calculate next backup time
 	nextBackup := r.calculateNextBackup(backup)
-	if nextBackup != nil {
+	if nextBackup != nil && !backup.Spec.RenderOnly {
 		backup.Status.NextBackup = nextBackup
 	}
 
+	// Run hooks and record the results of finished backup jobs
+	lastBackup := backup.Status.LastBackup
+	if err := r.updateBackupStatus(ctx, backup, repository); err != nil {
+		log.Error(err, "Failed to evaluate backup jobs")
+	}
+	recordBackupMetrics(backup)
+
+	// Record the suspension and wait for the running jobs of a drained backup
+	if err := r.updatePauseStatus(ctx, backup, time.Now()); err != nil {
+		log.Error(err, "Failed to update pause status")
+	}
+
+	// Retry a failed backup job once its backoff passed
+	retryAfter, err := r.runDueRetry(ctx, backup, repository, window, time.Now())
+	if err != nil {
+		log.Error(err, "Failed to retry backup")
+	}
+
+	// Report backups whose last success is too old, e.g. of a CronJob that doesn't run
+	r.updateBackupFreshness(ctx, backup, time.Now())
+
+	// Detect snapshots of the backup written with another hostname after each new snapshot
+	if last := backup.Status.LastBackup; last != lastBackup && last != nil && last.SnapshotID != "" {
+		r.SnapshotCache.Invalidate(client.ObjectKeyFromObject(repository))
+		if r.FeatureGates.Enabled(features.SnapshotHostnameCheck) && r.HostnameChecker != nil {
+			r.HostnameChecker.Enqueue(req.NamespacedName, client.ObjectKeyFromObject(repository))
+		}
+	}
+
+	// Suggest a schedule matching the data-change rate (advisory only)
+	if r.FeatureGates.Enabled(features.ScheduleRecommendations) {
+		r.updateScheduleRecommendation(backup)
+	} else {
+		deleteScheduleAdvisorMetrics(backup)
+		backup.Status.ScheduleRecommendation = ""
+	}
+
 	// Set Ready condition
-	r.setCondition(backup, conditions.ReadyCondition("BackupConfigured", "Backup CronJob is configured and running"))
+	if backup.Spec.RenderOnly {
+		r.setCondition(backup, conditions.ReadyCondition("RenderOnly", fmt.Sprintf("Backup CronJob is rendered into ConfigMap %s and not created", renderedManifestName(backup))))
+	} else {
+		r.setCondition(backup, conditions.ReadyCondition("BackupConfigured", "Backup CronJob is configured and running"))
+	}
 	backup.Status.ObservedGeneration = backup.Generation
 
 	if err := r.Status().Update(ctx, backup); err != nil {
@@ -146,7 +324,18 @@ func (r *ResticBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request
 
 	r.Recorder.Event(backup, corev1.EventTypeNormal, "ReconcileSuccess", "Backup reconciled successfully")
 
-	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+	requeueAfter := 5 * time.Minute
+	if conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionWaitingForRepository) {
+		requeueAfter = waitingForRepositoryInterval
+	}
+	// Suspend or resume the CronJob when the backup window opens or closes
+	if window != nil && !window.next.IsZero() && time.Until(window.next) < requeueAfter {
+		requeueAfter = max(time.Until(window.next), time.Second)
+	}
+	if retryAfter > 0 && retryAfter < requeueAfter {
+		requeueAfter = max(retryAfter, time.Second)
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func (r *ResticBackupReconciler) handleDeletion(ctx context.Context, backup *backupv1alpha1.ResticBackup) (ctrl.Result, error) {
@@ -155,7 +344,26 @@ func (r *ResticBackupReconciler) handleDeletion(ctx context.Context, backup *bac
 	if controllerutil.ContainsFinalizer(backup, resticBackupFinalizer) {
 		log.Info("Performing finalizer cleanup for ResticBackup")
 
-		// CronJob will be garbage collected due to owner reference
+		// CronJob and the ResticBackups of selected PVCs will be garbage collected due
+		// to owner reference
+
+		// Remove Pushgateway series so dashboards don't show stale results.
+		// Failures must not block the deletion of the backup.
+		if err := r.deletePushgatewayMetrics(ctx, backup); err != nil {
+			log.Error(err, "Failed to delete Pushgateway metrics")
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "MetricsCleanupFailed", err.Error())
+		}
+		deleteScheduleAdvisorMetrics(backup)
+		deleteBackupMetrics(backup)
+
+		// The backup jobs are deleted with the backup, never leave the workload scaled down
+		reader := r.APIReader
+		if reader == nil {
+			reader = r.Client
+		}
+		if err := r.restoreScaledDownWorkload(ctx, reader, backup, nil); err != nil {
+			return ctrl.Result{}, err
+		}
 
 		controllerutil.RemoveFinalizer(backup, resticBackupFinalizer)
 		if err := r.Update(ctx, backup); err != nil {
@@ -166,6 +374,26 @@ func (r *ResticBackupReconciler) handleDeletion(ctx context.Context, backup *bac
 	return ctrl.Result{}, nil
 }
 
+func (r *ResticBackupReconciler) deletePushgatewayMetrics(ctx context.Context, backup *backupv1alpha1.ResticBackup) error {
+	if r.Notifications == nil || backup.Spec.Notifications == nil {
+		return nil
+	}
+
+	pushgateway := backup.Spec.Notifications.Pushgateway
+	if pushgateway == nil || !pushgateway.Enabled {
+		return nil
+	}
+
+	config := notifications.Config{
+		Pushgateway: &notifications.PushgatewayConfig{
+			URL:     pushgateway.URL,
+			JobName: pushgateway.JobName,
+		},
+	}
+
+	return r.Notifications.DeleteBackupMetrics(ctx, config, backup.Name, backup.Namespace)
+}
+
 func (r *ResticBackupReconciler) getRepository(ctx context.Context, backup *backupv1alpha1.ResticBackup) (*backupv1alpha1.ResticRepository, error) {
 	repository := &backupv1alpha1.ResticRepository{}
 	ns := backup.Spec.RepositoryRef.Namespace
@@ -178,6 +406,9 @@ func (r *ResticBackupReconciler) getRepository(ctx context.Context, backup *back
 		Namespace: ns,
 	}
 
+	if err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "ResticBackup", backup.Namespace, "ResticRepository", ns, name.Name); err != nil {
+		return nil, err
+	}
 	if err := r.Get(ctx, name, repository); err != nil {
 		return nil, fmt.Errorf("failed to get repository: %w", err)
 	}
@@ -185,10 +416,104 @@ func (r *ResticBackupReconciler) getRepository(ctx context.Context, backup *back
 	return repository, nil
 }
 
+// getFallbackRepository returns the fallback repository if the primary repository has
+// not been ready for longer than FallbackAfter and the fallback is ready, nil otherwise.
+func (r *ResticBackupReconciler) getFallbackRepository(ctx context.Context, backup *backupv1alpha1.ResticBackup, primary *backupv1alpha1.ResticRepository) *backupv1alpha1.ResticRepository {
+	log := log.FromContext(ctx)
+
+	if backup.Spec.FallbackRepositoryRef == nil {
+		return nil
+	}
+
+	threshold := 30 * time.Minute
+	if backup.Spec.FallbackAfter != nil {
+		threshold = backup.Spec.FallbackAfter.Duration
+	}
+
+	// A repository without Ready condition has never been reconciled, treat it as down since creation
+	downSince := primary.CreationTimestamp.Time
+	if cond := conditions.GetCondition(primary.Status.Conditions, "Ready"); cond != nil {
+		downSince = cond.LastTransitionTime.Time
+	}
+	if time.Since(downSince) < threshold {
+		return nil
+	}
+
+	ns := backup.Spec.FallbackRepositoryRef.Namespace
+	if ns == "" {
+		ns = backup.Namespace
+	}
+
+	if err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "ResticBackup", backup.Namespace, "ResticRepository",
+		ns, backup.Spec.FallbackRepositoryRef.Name); err != nil {
+		log.Error(err, "Fallback repository not permitted")
+		return nil
+	}
+
+	fallback := &backupv1alpha1.ResticRepository{}
+	if err := r.Get(ctx, types.NamespacedName{Name: backup.Spec.FallbackRepositoryRef.Name, Namespace: ns}, fallback); err != nil {
+		log.Error(err, "Failed to get fallback repository")
+		return nil
+	}
+	if !conditions.IsConditionTrue(fallback.Status.Conditions, "Ready") {
+		log.Info("Fallback repository not ready")
+		return nil
+	}
+
+	return fallback
+}
+
+// setFallbackCondition records whether backups currently go to the fallback repository.
+func (r *ResticBackupReconciler) setFallbackCondition(backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository, usedFallback bool) {
+	if usedFallback {
+		if !conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionUsedFallback) {
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "UsedFallback",
+				fmt.Sprintf("Primary repository is down, backing up to fallback repository %s/%s", repository.Namespace, repository.Name))
+		}
+		conditions.SetCondition(&backup.Status.Conditions, metav1.Condition{
+			Type:    backupv1alpha1.ConditionUsedFallback,
+			Status:  metav1.ConditionTrue,
+			Reason:  "PrimaryRepositoryNotReady",
+			Message: fmt.Sprintf("Backups are written to fallback repository %s/%s", repository.Namespace, repository.Name),
+		})
+		return
+	}
+
+	if backup.Spec.FallbackRepositoryRef == nil {
+		conditions.RemoveCondition(&backup.Status.Conditions, backupv1alpha1.ConditionUsedFallback)
+		return
+	}
+	if conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionUsedFallback) {
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "PrimaryRepositoryRestored", "Primary repository is ready again, backing up to primary repository")
+	}
+	conditions.SetCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:    backupv1alpha1.ConditionUsedFallback,
+		Status:  metav1.ConditionFalse,
+		Reason:  "PrimaryRepositoryReady",
+		Message: "Backups are written to the primary repository",
+	})
+}
+
+// isFallbackRepository reports whether repository is the backup's fallback repository.
+func isFallbackRepository(backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) bool {
+	ref := backup.Spec.FallbackRepositoryRef
+	if ref == nil {
+		return false
+	}
+	ns := ref.Namespace
+	if ns == "" {
+		ns = backup.Namespace
+	}
+	return repository.Name == ref.Name && repository.Namespace == ns
+}
+
 func (r *ResticBackupReconciler) reconcileCronJob(ctx context.Context, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) error {
 	log := log.FromContext(ctx)
 
-	cronJob := r.buildCronJob(backup, repository)
+	cronJob, err := r.buildCronJob(backup, repository)
+	if err != nil {
+		return err
+	}
 
 	// Set owner reference
 	if err := controllerutil.SetControllerReference(backup, cronJob, r.Scheme); err != nil {
@@ -197,18 +522,19 @@ func (r *ResticBackupReconciler) reconcileCronJob(ctx context.Context, backup *b
 
 	// Check if CronJob exists
 	existingCronJob := &batchv1.CronJob{}
-	err := r.Get(ctx, types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, existingCronJob)
+	err = r.Get(ctx, types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, existingCronJob)
 
 	if apierrors.IsNotFound(err) {
 		log.Info("Creating CronJob", "name", cronJob.Name)
-		if err := r.Create(ctx, cronJob); err != nil {
+		created, err := createOrAdopt(ctx, r.Client, backup, cronJob, existingCronJob)
+		if err != nil {
 			return fmt.Errorf("failed to create CronJob: %w", err)
 		}
-		r.Recorder.Event(backup, corev1.EventTypeNormal, "CronJobCreated", fmt.Sprintf("Created CronJob %s", cronJob.Name))
-		return nil
-	}
-
-	if err != nil {
+		if created {
+			r.Recorder.Event(backup, corev1.EventTypeNormal, "CronJobCreated", fmt.Sprintf("Created CronJob %s", cronJob.Name))
+			return nil
+		}
+	} else if err != nil {
 		return fmt.Errorf("failed to get CronJob: %w", err)
 	}
 
@@ -227,37 +553,126 @@ func (r *ResticBackupReconciler) reconcileCronJob(ctx context.Context, backup *b
 	return nil
 }
 
-func (r *ResticBackupReconciler) buildCronJob(backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) *batchv1.CronJob {
+// usesDedicatedServiceAccount reports whether the backup pods run with a generated ServiceAccount.
+func usesDedicatedServiceAccount(backup *backupv1alpha1.ResticBackup) bool {
+	return backup.Spec.JobConfig != nil &&
+		backup.Spec.JobConfig.CreateServiceAccount &&
+		backup.Spec.JobConfig.ServiceAccountName == ""
+}
+
+func serviceAccountName(backup *backupv1alpha1.ResticBackup) string {
+	return fmt.Sprintf("resticbackup-%s", backup.Name)
+}
+
+// reconcileServiceAccount ensures the dedicated ServiceAccount exists if requested,
+// and the ServiceAccount of a resources source. The ServiceAccounts have no RBAC
+// bindings, so a compromised restic image gains no API access; the resources
+// ServiceAccount only gets the permissions bound to it by the user. They are garbage
+// collected with the backup via their owner reference.
+func (r *ResticBackupReconciler) reconcileServiceAccount(ctx context.Context, backup *backupv1alpha1.ResticBackup) error {
+	if usesDedicatedServiceAccount(backup) {
+		if err := r.ensureServiceAccount(ctx, backup, serviceAccountName(backup)); err != nil {
+			return err
+		}
+	}
+	if backup.Spec.Source.Resources != nil {
+		return r.ensureServiceAccount(ctx, backup, resourcesServiceAccountName(backup))
+	}
+	return nil
+}
+
+// ensureServiceAccount creates or updates a ServiceAccount owned by the backup.
+func (r *ResticBackupReconciler) ensureServiceAccount(ctx context.Context, backup *backupv1alpha1.ResticBackup, name string) error {
+	log := log.FromContext(ctx)
+
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: backup.Namespace,
+		},
+	}
+
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, serviceAccount, func() error {
+		serviceAccount.Labels = map[string]string{
+			"app.kubernetes.io/name":       "restic-backup-operator",
+			"app.kubernetes.io/component":  "backup",
+			"app.kubernetes.io/managed-by": "restic-backup-operator",
+			resticBackupLabel:              backup.Name,
+		}
+		serviceAccount.AutomountServiceAccountToken = boolPtr(false)
+		return controllerutil.SetControllerReference(backup, serviceAccount, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reconcile ServiceAccount: %w", err)
+	}
+
+	if result == controllerutil.OperationResultCreated {
+		log.Info("Created ServiceAccount", "name", serviceAccount.Name)
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "ServiceAccountCreated", fmt.Sprintf("Created ServiceAccount %s", serviceAccount.Name))
+	}
+
+	return nil
+}
+
+func (r *ResticBackupReconciler) buildCronJob(backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) (*batchv1.CronJob, error) {
 	cronJobName := fmt.Sprintf("resticbackup-%s", backup.Name)
 
 	// Build restic image
-	resticImage := "ghcr.io/restic/restic:0.18.0"
-	if backup.Spec.Restic != nil && backup.Spec.Restic.Image != "" {
-		resticImage = backup.Spec.Restic.Image
+	var image string
+	if backup.Spec.Restic != nil {
+		image = backup.Spec.Restic.Image
 	}
+	resticImage := r.Images.Resolve(image, backup.Spec.JobConfig)
 
 	// Build hostname
-	hostname := backup.Name
-	if backup.Spec.Restic != nil && backup.Spec.Restic.Hostname != "" {
-		hostname = backup.Spec.Restic.Hostname
+	hostname, err := renderHostname(backup)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build tags
-	var tags []string
+	// Build tags, including the backup and the versions for forensics on later restores
+	tags := []string{backupTag(backup)}
 	if backup.Spec.Restic != nil {
-		tags = backup.Spec.Restic.Tags
+		tags = append(tags, backup.Spec.Restic.Tags...)
 	}
+	tags = append(tags, versionTags(resticImage)...)
+	tags = append(tags, sourcePVCTags(backup.Status.SourcePVC)...)
+	if isFallbackRepository(backup, repository) {
+		tags = append(tags, "fallback")
+	}
+	tags = normalizeTags(backup, tags)
 
 	// Build backup command
-	backupCmd := r.buildBackupCommand(backup, hostname, tags)
+	options := repositoryOptions(repository)
+	script := backupScript{
+		required: requiredSourcePaths(backup),
+		backup:   slices.Insert(r.buildBackupCommand(backup, hostname, tags), 2, options...),
+	}
+
+	// Never back up into another repository than the one used before
+	script.identityCheck = buildIdentityCheckCommands(repository)
+
+	// Remove locks left by interrupted runs before the backup
+	if backup.Spec.Restic != nil && backup.Spec.Restic.UnlockStaleLocks {
+		script.unlock = append([]string{"restic", "unlock"}, options...)
+	}
+
+	// Fail fast if the repository backend is running out of space
+	script.spaceCheck = buildSpaceCheckCommands(repository)
+
+	// Build forget command applying the effective retention after the backup
+	if forgetCmd := buildForgetCommand(effectiveRetention(backup, repository), hostname); forgetCmd != nil {
+		script.forget = slices.Insert(forgetCmd, 2, options...)
+		script.count = slices.Insert(buildSnapshotCountCommand(hostname), 2, options...)
+	}
 
 	// Build pod template
-	podSpec := r.buildPodSpec(backup, repository, resticImage, backupCmd)
+	podSpec := r.buildPodSpec(backup, repository, resticImage, script)
 
 	// Job configuration
 	var successLimit, failLimit int32 = 3, 3
-	var backoffLimit int32 = 0
-	var activeDeadline int64 = 3600
+	backoffLimit := r.JobDefaults.BackoffLimit(JobOperationBackup, backup.Spec.JobConfig)
+	activeDeadline := r.JobDefaults.ActiveDeadlineSeconds(JobOperationBackup, backup.Spec.JobConfig)
 
 	if backup.Spec.JobConfig != nil {
 		if backup.Spec.JobConfig.SuccessfulJobsHistoryLimit != nil {
@@ -266,12 +681,6 @@ func (r *ResticBackupReconciler) buildCronJob(backup *backupv1alpha1.ResticBacku
 		if backup.Spec.JobConfig.FailedJobsHistoryLimit != nil {
 			failLimit = *backup.Spec.JobConfig.FailedJobsHistoryLimit
 		}
-		if backup.Spec.JobConfig.BackoffLimit != nil {
-			backoffLimit = *backup.Spec.JobConfig.BackoffLimit
-		}
-		if backup.Spec.JobConfig.ActiveDeadlineSeconds != nil {
-			activeDeadline = *backup.Spec.JobConfig.ActiveDeadlineSeconds
-		}
 	}
 
 	// Concurrency policy
@@ -285,29 +694,35 @@ func (r *ResticBackupReconciler) buildCronJob(backup *backupv1alpha1.ResticBacku
 		}
 	}
 
+	// Suspend the CronJob outside of the backup window and during blackout periods
+	suspend := backup.Spec.Suspend
+	if window := evaluateBackupWindow(backup, time.Now()); window != nil && window.suspended {
+		suspend = true
+	}
+
 	cronJob := &batchv1.CronJob{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cronJobName,
 			Namespace: backup.Namespace,
 			Labels: map[string]string{
-				"app.kubernetes.io/name":        "restic-backup-operator",
-				"app.kubernetes.io/component":   "backup",
-				"app.kubernetes.io/managed-by":  "restic-backup-operator",
-				"backup.resticbackup.io/backup": backup.Name,
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "backup",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				resticBackupLabel:              backup.Name,
 			},
 		},
 		Spec: batchv1.CronJobSpec{
 			Schedule:                   backup.Spec.Schedule,
-			Suspend:                    &backup.Spec.Suspend,
+			Suspend:                    &suspend,
 			ConcurrencyPolicy:          concurrencyPolicy,
 			SuccessfulJobsHistoryLimit: &successLimit,
 			FailedJobsHistoryLimit:     &failLimit,
 			JobTemplate: batchv1.JobTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: map[string]string{
-						"app.kubernetes.io/name":        "restic-backup-operator",
-						"app.kubernetes.io/component":   "backup",
-						"backup.resticbackup.io/backup": backup.Name,
+						"app.kubernetes.io/name":      "restic-backup-operator",
+						"app.kubernetes.io/component": "backup",
+						resticBackupLabel:             backup.Name,
 					},
 				},
 				Spec: batchv1.JobSpec{
@@ -319,17 +734,81 @@ func (r *ResticBackupReconciler) buildCronJob(backup *backupv1alpha1.ResticBacku
 		},
 	}
 
+	// Jobs wait for the operator to scale down the workload, to run the pre-backup hook,
+	// for the repository, for a free backup slot or for an intermittent backend to be reachable
+	if usesScaleDown(backup) || usesPreBackupHook(backup) || repository.Spec.CoordinateJobs || r.limitsConcurrentBackups(repository) ||
+		expectsIntermittentBackend(repository) {
+		cronJob.Spec.JobTemplate.Spec.Suspend = boolPtr(true)
+	}
+
 	// Add timezone if specified
 	if backup.Spec.Timezone != "" && backup.Spec.Timezone != "UTC" {
 		cronJob.Spec.TimeZone = &backup.Spec.Timezone
 	}
 
-	return cronJob
+	return cronJob, nil
+}
+
+// versionTags returns the snapshot tags recording the operator and restic versions.
+// The restic version is taken from the image tag and omitted if the image has none.
+func versionTags(image string) []string {
+	tags := []string{fmt.Sprintf("operator-version=%s", version.Version)}
+
+	// Strip the digest and registry port before looking for the tag
+	ref, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		tags = append(tags, fmt.Sprintf("restic-version=%s", ref[i+1:]))
+	}
+
+	return tags
+}
+
+// hostnameTemplateData holds the variables available in ResticConfig.Hostname templates.
+type hostnameTemplateData struct {
+	Namespace string
+	Name      string
+	PVC       string
+}
+
+// renderHostname returns the snapshot hostname for a backup. The configured
+// hostname is rendered as a Go template, so "{{ .Namespace }}-{{ .Name }}"
+// yields a unique hostname per CR. Defaults to the CR name.
+func renderHostname(backup *backupv1alpha1.ResticBackup) (string, error) {
+	if backup.Spec.Restic == nil || backup.Spec.Restic.Hostname == "" {
+		return normalizeHostname(backup, backup.Name), nil
+	}
+
+	tmpl, err := template.New("hostname").Option("missingkey=error").Parse(backup.Spec.Restic.Hostname)
+	if err != nil {
+		return "", fmt.Errorf("invalid hostname template: %w", err)
+	}
+
+	data := hostnameTemplateData{
+		Namespace: backup.Namespace,
+		Name:      backup.Name,
+	}
+	claimNames := make([]string, 0, len(pvcSources(backup)))
+	for _, source := range pvcSources(backup) {
+		claimNames = append(claimNames, source.ClaimName)
+	}
+	data.PVC = strings.Join(claimNames, "-")
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render hostname template: %w", err)
+	}
+
+	hostname := strings.TrimSpace(sb.String())
+	if hostname == "" {
+		return "", fmt.Errorf("hostname template %q rendered an empty hostname", backup.Spec.Restic.Hostname)
+	}
+
+	return normalizeHostname(backup, hostname), nil
 }
 
 func (r *ResticBackupReconciler) buildBackupCommand(backup *backupv1alpha1.ResticBackup, hostname string, tags []string) []string {
 	cmd := []string{
-		"restic", "backup",
+		"restic", "backup", "--json",
 		"--host", hostname,
 	}
 
@@ -338,10 +817,8 @@ func (r *ResticBackupReconciler) buildBackupCommand(backup *backupv1alpha1.Resti
 	}
 
 	// Add excludes
-	if backup.Spec.Source.PVC != nil {
-		for _, exclude := range backup.Spec.Source.PVC.Excludes {
-			cmd = append(cmd, "--exclude", exclude)
-		}
+	for _, exclude := range sourceExcludes(backup) {
+		cmd = append(cmd, "--exclude", exclude)
 	}
 
 	// Add extra args
@@ -350,88 +827,20 @@ func (r *ResticBackupReconciler) buildBackupCommand(backup *backupv1alpha1.Resti
 	}
 
 	// Add source paths
-	if backup.Spec.Source.PVC != nil {
-		if len(backup.Spec.Source.PVC.Paths) > 0 {
-			for _, path := range backup.Spec.Source.PVC.Paths {
-				cmd = append(cmd, "/backup"+path)
-			}
-		} else {
-			cmd = append(cmd, "/backup")
-		}
+	cmd = append(cmd, backupSourcePaths(backup)...)
+	if backup.Spec.Source.Database != nil || backup.Spec.Source.Resources != nil {
+		cmd = append(cmd, dumpMountPath)
 	}
 
 	return cmd
 }
 
-func (r *ResticBackupReconciler) buildPodSpec(backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository, image string, command []string) corev1.PodTemplateSpec {
+func (r *ResticBackupReconciler) buildPodSpec(backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository, image string, script backupScript) corev1.PodTemplateSpec {
 	// Build environment variables
-	envVars := []corev1.EnvVar{
-		{
-			Name:  "RESTIC_REPOSITORY",
-			Value: repository.Spec.RepositoryURL,
-		},
-		{
-			Name: "RESTIC_PASSWORD",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: repository.Spec.CredentialsSecretRef.Name,
-					},
-					Key: "RESTIC_PASSWORD",
-				},
-			},
-		},
-	}
+	envVars := repositoryEnvVars(repository)
 
-	// Add AWS credentials if using S3
-	envVars = append(envVars,
-		corev1.EnvVar{
-			Name: "AWS_ACCESS_KEY_ID",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: repository.Spec.CredentialsSecretRef.Name,
-					},
-					Key:      "AWS_ACCESS_KEY_ID",
-					Optional: boolPtr(true),
-				},
-			},
-		},
-		corev1.EnvVar{
-			Name: "AWS_SECRET_ACCESS_KEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: repository.Spec.CredentialsSecretRef.Name,
-					},
-					Key:      "AWS_SECRET_ACCESS_KEY",
-					Optional: boolPtr(true),
-				},
-			},
-		},
-	)
-
-	// Build volumes
-	volumes := []corev1.Volume{}
-	volumeMounts := []corev1.VolumeMount{}
-
-	// Add PVC volume if source is PVC
-	if backup.Spec.Source.PVC != nil {
-		volumes = append(volumes, corev1.Volume{
-			Name: "backup-source",
-			VolumeSource: corev1.VolumeSource{
-				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-					ClaimName: backup.Spec.Source.PVC.ClaimName,
-					ReadOnly:  true,
-				},
-			},
-		})
-		volumeMounts = append(volumeMounts, corev1.VolumeMount{
-			Name:      "backup-source",
-			MountPath: "/backup",
-			ReadOnly:  true,
-		})
-	}
+	// Build volumes, starting with the source PVCs
+	volumes, volumeMounts := sourceVolumes(backup)
 
 	// Build security context
 	securityContext := &corev1.PodSecurityContext{
@@ -457,17 +866,15 @@ func (r *ResticBackupReconciler) buildPodSpec(backup *backupv1alpha1.ResticBacku
 		},
 	}
 
-	// Build resources
-	resources := corev1.ResourceRequirements{}
-	if backup.Spec.JobConfig != nil && backup.Spec.JobConfig.Resources != nil {
-		resources = *backup.Spec.JobConfig.Resources
-	}
+	// Build resources, with the memory limit raised after OOM kills
+	resources := backupResources(backup)
 
 	container := corev1.Container{
 		Name:            "restic",
 		Image:           image,
 		ImagePullPolicy: corev1.PullIfNotPresent,
-		Command:         command,
+		Command:         []string{"/bin/sh", "-c"},
+		Args:            []string{script.build()},
 		Env:             envVars,
 		VolumeMounts:    volumeMounts,
 		SecurityContext: containerSecurityContext,
@@ -477,37 +884,59 @@ func (r *ResticBackupReconciler) buildPodSpec(backup *backupv1alpha1.ResticBacku
 	podSpec := corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
 			Labels: map[string]string{
-				"app.kubernetes.io/name":        "restic-backup-operator",
-				"app.kubernetes.io/component":   "backup",
-				"backup.resticbackup.io/backup": backup.Name,
+				"app.kubernetes.io/name":      "restic-backup-operator",
+				"app.kubernetes.io/component": "backup",
+				resticBackupLabel:             backup.Name,
 			},
 		},
 		Spec: corev1.PodSpec{
-			RestartPolicy:   corev1.RestartPolicyNever,
-			SecurityContext: securityContext,
-			Containers:      []corev1.Container{container},
-			Volumes:         volumes,
+			RestartPolicy:                 corev1.RestartPolicyNever,
+			SecurityContext:               securityContext,
+			TerminationGracePeriodSeconds: int64Ptr(backupTerminationGracePeriodSeconds),
+			Containers:                    []corev1.Container{container},
+			Volumes:                       volumes,
 		},
 	}
 
-	// Add node selector
-	if backup.Spec.JobConfig != nil && backup.Spec.JobConfig.NodeSelector != nil {
-		podSpec.Spec.NodeSelector = backup.Spec.JobConfig.NodeSelector
+	// Dump the database and the Kubernetes objects to a shared volume before restic
+	// backs it up
+	if backup.Spec.Source.Database != nil || backup.Spec.Source.Resources != nil {
+		podSpec.Spec.Volumes = append(podSpec.Spec.Volumes, corev1.Volume{
+			Name:         dumpVolumeName,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		podSpec.Spec.Containers[0].VolumeMounts = append(podSpec.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      dumpVolumeName,
+			MountPath: dumpMountPath,
+			ReadOnly:  true,
+		})
 	}
-
-	// Add tolerations
-	if backup.Spec.JobConfig != nil && backup.Spec.JobConfig.Tolerations != nil {
-		podSpec.Spec.Tolerations = backup.Spec.JobConfig.Tolerations
+	if database := backup.Spec.Source.Database; database != nil {
+		podSpec.Spec.InitContainers = append(podSpec.Spec.InitContainers,
+			buildDumpContainer(database, containerSecurityContext, resources))
+	}
+	if backup.Spec.Source.Resources != nil {
+		podSpec.Spec.Volumes = append(podSpec.Spec.Volumes, resourcesTokenVolume())
+		podSpec.Spec.InitContainers = append(podSpec.Spec.InitContainers,
+			buildResourcesContainer(backup, containerSecurityContext, resources))
 	}
 
-	// Add affinity
-	if backup.Spec.JobConfig != nil && backup.Spec.JobConfig.Affinity != nil {
-		podSpec.Spec.Affinity = backup.Spec.JobConfig.Affinity
+	// Apply scheduling and networking settings
+	applyRepositoryCache(&podSpec.Spec, repository, backup.Namespace)
+	applyRepositoryCredentials(&podSpec.Spec, repository)
+	applyJobConfiguration(&podSpec.Spec, backup.Spec.JobConfig)
+	applyPodMetadata(&podSpec.ObjectMeta, backup.Spec.JobConfig)
+
+	// Run with the dedicated ServiceAccount without an API token
+	if usesDedicatedServiceAccount(backup) {
+		podSpec.Spec.ServiceAccountName = serviceAccountName(backup)
+		podSpec.Spec.AutomountServiceAccountToken = boolPtr(false)
 	}
 
-	// Add service account
-	if backup.Spec.JobConfig != nil && backup.Spec.JobConfig.ServiceAccountName != "" {
-		podSpec.Spec.ServiceAccountName = backup.Spec.JobConfig.ServiceAccountName
+	// Only the resources container gets the token of the resources ServiceAccount
+	if backup.Spec.Source.Resources != nil {
+		podSpec.Spec.ServiceAccountName = resourcesServiceAccountName(backup)
+		podSpec.Spec.AutomountServiceAccountToken = boolPtr(false)
 	}
 
 	return podSpec
@@ -520,8 +949,12 @@ func (r *ResticBackupReconciler) calculateNextBackup(backup *backupv1alpha1.Rest
 		return nil
 	}
 
-	next := schedule.Next(time.Now())
-	return &metav1.Time{Time: next}
+	// A run missed outside of the backup window starts once the window opens
+	next := nextAllowedTime(backup, schedule.Next(time.Now()))
+	if next == nil {
+		return nil
+	}
+	return &metav1.Time{Time: *next}
 }
 
 func (r *ResticBackupReconciler) setCondition(backup *backupv1alpha1.ResticBackup, condition metav1.Condition) {
@@ -530,9 +963,23 @@ func (r *ResticBackupReconciler) setCondition(backup *backupv1alpha1.ResticBacku
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ResticBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Index the PVs and backups the overlapping backup check looks up
+	indexer := mgr.GetFieldIndexer()
+	if err := indexer.IndexField(context.Background(), &corev1.PersistentVolume{}, volumeLocationField, indexVolumeLocation); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(context.Background(), &backupv1alpha1.ResticBackup{}, sourceClaimsField, indexSourceClaims); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&backupv1alpha1.ResticBackup{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Owns(&batchv1.CronJob{}).
+		Owns(&corev1.ServiceAccount{}).
+		Watches(&batchv1.Job{}, handler.EnqueueRequestsFromMapFunc(backupForJob)).
+		Watches(&backupv1alpha1.ResticBackup{}, handler.EnqueueRequestsFromMapFunc(backupForDiscoveredBackup)).
+		Watches(&corev1.PersistentVolumeClaim{}, handler.EnqueueRequestsFromMapFunc(r.backupsForPVCSelector)).
 		Complete(r)
 }
 
@@ -543,3 +990,7 @@ func boolPtr(b bool) *bool {
 func int64Ptr(i int64) *int64 {
 	return &i
 }
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -25,9 +26,12 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/version"
 )
 
 var _ = Describe("ResticBackup Controller", func() {
@@ -36,7 +40,7 @@ var _ = Describe("ResticBackup Controller", func() {
 		interval = time.Millisecond * 250
 	)
 
-	Context("When creating a ResticBackup", func() {
+	Context("When creating a ResticBackup", Label(envtestLabel), func() {
 		var (
 			testNamespace string
 			backupKey     types.NamespacedName
@@ -547,6 +551,201 @@ var _ = Describe("ResticBackup Controller", func() {
 		})
 	})
 
+	Context("buildPodSpec service account", func() {
+		var (
+			reconciler *ResticBackupReconciler
+			repository *backupv1alpha1.ResticRepository
+		)
+
+		BeforeEach(func() {
+			reconciler = &ResticBackupReconciler{}
+			repository = &backupv1alpha1.ResticRepository{
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					RepositoryURL:        "s3:https://s3.example.com/bucket",
+					CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "restic-credentials"},
+				},
+			}
+		})
+
+		It("should use the dedicated service account without token", func() {
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					JobConfig: &backupv1alpha1.JobConfiguration{CreateServiceAccount: true},
+				},
+			}
+
+			podSpec := reconciler.buildPodSpec(backup, repository, "restic", backupScript{})
+			Expect(podSpec.Spec.ServiceAccountName).To(Equal("resticbackup-app"))
+			Expect(podSpec.Spec.AutomountServiceAccountToken).To(HaveValue(BeFalse()))
+		})
+
+		It("should prefer an explicit service account name", func() {
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					JobConfig: &backupv1alpha1.JobConfiguration{
+						CreateServiceAccount: true,
+						ServiceAccountName:   "custom",
+					},
+				},
+			}
+
+			podSpec := reconciler.buildPodSpec(backup, repository, "restic", backupScript{})
+			Expect(podSpec.Spec.ServiceAccountName).To(Equal("custom"))
+			Expect(podSpec.Spec.AutomountServiceAccountToken).To(BeNil())
+		})
+
+		It("should give restic time to release its lock on termination", func() {
+			backup := &backupv1alpha1.ResticBackup{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"}}
+			podSpec := reconciler.buildPodSpec(backup, repository, "restic", backupScript{})
+			Expect(podSpec.Spec.TerminationGracePeriodSeconds).To(HaveValue(Equal(int64(120))))
+
+			backup.Spec.JobConfig = &backupv1alpha1.JobConfiguration{TerminationGracePeriodSeconds: int64Ptr(600)}
+			podSpec = reconciler.buildPodSpec(backup, repository, "restic", backupScript{})
+			Expect(podSpec.Spec.TerminationGracePeriodSeconds).To(HaveValue(Equal(int64(600))))
+		})
+	})
+
+	Context("fallback repository", func() {
+		var (
+			reconciler *ResticBackupReconciler
+			backup     *backupv1alpha1.ResticBackup
+		)
+
+		BeforeEach(func() {
+			reconciler = &ResticBackupReconciler{Recorder: record.NewFakeRecorder(10)}
+			backup = &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					RepositoryRef:         backupv1alpha1.CrossNamespaceObjectReference{Name: "primary"},
+					FallbackRepositoryRef: &backupv1alpha1.CrossNamespaceObjectReference{Name: "secondary"},
+					FallbackAfter:         &metav1.Duration{Duration: time.Hour},
+				},
+			}
+		})
+
+		It("should not fall back before the threshold has passed", func() {
+			primary := &backupv1alpha1.ResticRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: "primary", Namespace: "media"},
+				Status: backupv1alpha1.ResticRepositoryStatus{
+					Conditions: []metav1.Condition{{
+						Type:               "Ready",
+						Status:             metav1.ConditionFalse,
+						LastTransitionTime: metav1.NewTime(time.Now().Add(-10 * time.Minute)),
+					}},
+				},
+			}
+
+			Expect(reconciler.getFallbackRepository(context.Background(), backup, primary)).To(BeNil())
+		})
+
+		It("should identify the fallback repository", func() {
+			Expect(isFallbackRepository(backup, &backupv1alpha1.ResticRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: "secondary", Namespace: "media"},
+			})).To(BeTrue())
+			Expect(isFallbackRepository(backup, &backupv1alpha1.ResticRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: "primary", Namespace: "media"},
+			})).To(BeFalse())
+		})
+
+		It("should set the UsedFallback condition", func() {
+			fallback := &backupv1alpha1.ResticRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: "secondary", Namespace: "media"},
+			}
+
+			reconciler.setFallbackCondition(backup, fallback, true)
+			Expect(conditions.IsConditionTrue(backup.Status.Conditions, backupv1alpha1.ConditionUsedFallback)).To(BeTrue())
+
+			reconciler.setFallbackCondition(backup, fallback, false)
+			Expect(conditions.IsConditionFalse(backup.Status.Conditions, backupv1alpha1.ConditionUsedFallback)).To(BeTrue())
+		})
+	})
+
+	Context("versionTags helper function", func() {
+		It("should record the operator and restic versions", func() {
+			Expect(versionTags("ghcr.io/restic/restic:0.18.1")).To(Equal([]string{
+				"operator-version=" + version.Version,
+				"restic-version=0.18.1",
+			}))
+		})
+
+		It("should ignore registry ports and digests", func() {
+			Expect(versionTags("registry.local:5000/restic/restic:0.17.3@sha256:abcdef")).To(ContainElement("restic-version=0.17.3"))
+		})
+
+		It("should omit the restic version for untagged images", func() {
+			Expect(versionTags("registry.local:5000/restic/restic")).To(Equal([]string{
+				"operator-version=" + version.Version,
+			}))
+		})
+	})
+
+	Context("renderHostname helper function", func() {
+		It("should default to the CR name", func() {
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+			}
+
+			hostname, err := renderHostname(backup)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hostname).To(Equal("app"))
+		})
+
+		It("should keep plain hostnames unchanged", func() {
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					Restic: &backupv1alpha1.ResticConfig{Hostname: "emby"},
+				},
+			}
+
+			hostname, err := renderHostname(backup)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hostname).To(Equal("emby"))
+		})
+
+		It("should render template variables", func() {
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					Source: backupv1alpha1.BackupSource{
+						PVC: &backupv1alpha1.PVCSource{ClaimName: "app-data"},
+					},
+					Restic: &backupv1alpha1.ResticConfig{Hostname: "{{ .Namespace }}-{{ .Name }}-{{ .PVC }}"},
+				},
+			}
+
+			hostname, err := renderHostname(backup)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hostname).To(Equal("media-app-app-data"))
+		})
+
+		It("should reject invalid templates", func() {
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					Restic: &backupv1alpha1.ResticConfig{Hostname: "{{ .Unknown }}"},
+				},
+			}
+
+			_, err := renderHostname(backup)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject templates rendering an empty hostname", func() {
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					Restic: &backupv1alpha1.ResticConfig{Hostname: "{{ .PVC }}"},
+				},
+			}
+
+			_, err := renderHostname(backup)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Context("buildBackupCommand helper function", func() {
 		var reconciler *ResticBackupReconciler
 
@@ -0,0 +1,527 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	resticCheckFinalizer = "backup.resticbackup.io/resticcheck-finalizer"
+	// resticCheckLabel links check CronJobs and their Jobs to the ResticCheck
+	resticCheckLabel = "backup.resticbackup.io/check"
+)
+
+// checkSummaryPattern selects the error lines of the restic check output that are written
+// to the termination message of the check container.
+const checkSummaryPattern = `^Fatal:|error`
+
+// checkCorruptionMessage is printed by restic check if the repository data is damaged.
+const checkCorruptionMessage = "repository contains errors"
+
+// ResticCheckReconciler reconciles a ResticCheck object
+type ResticCheckReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader reads check pods directly from the API server to avoid caching all pods.
+	// Falls back to Client if not set.
+	APIReader client.Reader
+	// StartupAudit, if set, delays reconciles until existing child objects are corrected.
+	StartupAudit *StartupAudit
+	// MaxConcurrentReconciles is the number of ResticChecks reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+	// Images resolves the default restic image, e.g. to a private mirror.
+	Images *ImageConfig
+	// JobDefaults sets the default active deadline and backoff limit of the jobs.
+	JobDefaults *JobDefaults
+	// FeatureGates enables optional capabilities. If nil, the feature defaults apply.
+	FeatureGates *features.Gate
+}
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticchecks,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticchecks/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticchecks/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop.
+func (r *ResticCheckReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciling ResticCheck")
+
+	// Wait for the startup audit to correct existing child objects
+	if err := r.StartupAudit.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Fetch the ResticCheck instance
+	check := &backupv1alpha1.ResticCheck{}
+	if err := r.Get(ctx, req.NamespacedName, check); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("ResticCheck resource not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get ResticCheck")
+		return ctrl.Result{}, err
+	}
+
+	// Handle deletion
+	if !check.DeletionTimestamp.IsZero() {
+		return r.handleDeletion(ctx, check)
+	}
+
+	// Add finalizer if missing
+	if !controllerutil.ContainsFinalizer(check, resticCheckFinalizer) {
+		controllerutil.AddFinalizer(check, resticCheckFinalizer)
+		if err := r.Update(ctx, check); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Reject sidecars the job pods can't be created with
+	if err := validateSidecars(check.Spec.JobConfig); err != nil {
+		r.setCondition(check, conditions.NotReadyCondition("InvalidSidecars", err.Error()))
+		r.Recorder.Event(check, corev1.EventTypeWarning, "InvalidSidecars", err.Error())
+		if updateErr := r.Status().Update(ctx, check); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Get the repository
+	repository, err := r.getRepository(ctx, check)
+	setReferenceDenied(&check.Status.Conditions, err)
+	if err != nil {
+		log.Error(err, "Failed to get repository")
+		reason := referenceErrorReason(err, "RepositoryNotFound")
+		r.setCondition(check, conditions.NotReadyCondition(reason, err.Error()))
+		r.Recorder.Event(check, corev1.EventTypeWarning, reason, err.Error())
+		if updateErr := r.Status().Update(ctx, check); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+	}
+
+	// Check repository is ready
+	if !conditions.IsConditionTrue(repository.Status.Conditions, "Ready") {
+		log.Info("Repository not ready, requeuing")
+		r.setCondition(check, conditions.NotReadyCondition("RepositoryNotReady", "Referenced repository is not ready"))
+		if err := r.Status().Update(ctx, check); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	// Reconcile CronJob
+	if err := r.reconcileCronJob(ctx, check, repository); err != nil {
+		log.Error(err, "Failed to reconcile CronJob")
+		r.setCondition(check, conditions.NotReadyCondition("CronJobFailed", err.Error()))
+		r.Recorder.Event(check, corev1.EventTypeWarning, "CronJobFailed", err.Error())
+		if updateErr := r.Status().Update(ctx, check); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+	}
+
+	// Report the result of the latest finished check job
+	if err := r.updateLastCheck(ctx, check); err != nil {
+		log.Error(err, "Failed to evaluate check jobs")
+	}
+
+	// Calculate next check time
+	nextCheck := r.calculateNextCheck(check)
+	if nextCheck != nil {
+		check.Status.NextCheck = nextCheck
+	}
+
+	// Set Ready condition
+	r.setCondition(check, conditions.ReadyCondition("CheckConfigured", "Check CronJob is configured"))
+	check.Status.ObservedGeneration = check.Generation
+
+	if err := r.Status().Update(ctx, check); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+func (r *ResticCheckReconciler) handleDeletion(ctx context.Context, check *backupv1alpha1.ResticCheck) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if controllerutil.ContainsFinalizer(check, resticCheckFinalizer) {
+		log.Info("Performing finalizer cleanup for ResticCheck")
+
+		// CronJob will be garbage collected due to owner reference
+
+		controllerutil.RemoveFinalizer(check, resticCheckFinalizer)
+		if err := r.Update(ctx, check); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *ResticCheckReconciler) getRepository(ctx context.Context, check *backupv1alpha1.ResticCheck) (*backupv1alpha1.ResticRepository, error) {
+	repository := &backupv1alpha1.ResticRepository{}
+	ns := check.Spec.RepositoryRef.Namespace
+	if ns == "" {
+		ns = check.Namespace
+	}
+
+	name := types.NamespacedName{
+		Name:      check.Spec.RepositoryRef.Name,
+		Namespace: ns,
+	}
+
+	if err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "ResticCheck", check.Namespace, "ResticRepository", ns, name.Name); err != nil {
+		return nil, err
+	}
+	if err := r.Get(ctx, name, repository); err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	return repository, nil
+}
+
+func (r *ResticCheckReconciler) reconcileCronJob(ctx context.Context, check *backupv1alpha1.ResticCheck, repository *backupv1alpha1.ResticRepository) error {
+	log := log.FromContext(ctx)
+
+	cronJob := r.buildCronJob(check, repository)
+
+	// Set owner reference
+	if err := controllerutil.SetControllerReference(check, cronJob, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	// Update status with CronJob reference
+	check.Status.CronJobRef = &backupv1alpha1.ObjectReference{
+		Name:      cronJob.Name,
+		Namespace: cronJob.Namespace,
+	}
+
+	// Check if CronJob exists
+	existingCronJob := &batchv1.CronJob{}
+	err := r.Get(ctx, types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, existingCronJob)
+
+	if apierrors.IsNotFound(err) {
+		log.Info("Creating CronJob", "name", cronJob.Name)
+		created, err := createOrAdopt(ctx, r.Client, check, cronJob, existingCronJob)
+		if err != nil {
+			return fmt.Errorf("failed to create CronJob: %w", err)
+		}
+		if created {
+			r.Recorder.Event(check, corev1.EventTypeNormal, "CronJobCreated", fmt.Sprintf("Created CronJob %s", cronJob.Name))
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get CronJob: %w", err)
+	}
+
+	// Update existing CronJob
+	existingCronJob.Spec = cronJob.Spec
+	if err := r.Update(ctx, existingCronJob); err != nil {
+		return fmt.Errorf("failed to update CronJob: %w", err)
+	}
+
+	return nil
+}
+
+// updateLastCheck evaluates the most recently finished check job. Each job is reported
+// only once, so events are emitted once per check run.
+func (r *ResticCheckReconciler) updateLastCheck(ctx context.Context, check *backupv1alpha1.ResticCheck) error {
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs,
+		client.InNamespace(check.Namespace),
+		client.MatchingLabels{resticCheckLabel: check.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list check jobs: %w", err)
+	}
+
+	latest, latestSucceeded, latestFinishedAt := latestFinishedJob(jobs.Items)
+	if latest == nil || latest.Name == check.Status.LastCheckJob {
+		return nil
+	}
+
+	finishedAt := metav1.NewTime(latestFinishedAt)
+	check.Status.LastCheck = &finishedAt
+	check.Status.LastCheckJob = latest.Name
+
+	if latestSucceeded {
+		check.Status.LastCheckResult = "Passed"
+		check.Status.LastSuccessfulCheck = &finishedAt
+		conditions.SetCondition(&check.Status.Conditions, metav1.Condition{
+			Type:    backupv1alpha1.ConditionIntegrityCheckSucceeded,
+			Status:  metav1.ConditionTrue,
+			Reason:  "CheckPassed",
+			Message: "Repository integrity check passed",
+		})
+		r.Recorder.Event(check, corev1.EventTypeNormal, "CheckPassed", "Repository integrity check passed")
+		return nil
+	}
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	message, err := jobTerminationMessage(ctx, reader, latest)
+	if err != nil {
+		// The pod may already be gone, report the failure without details
+		log.FromContext(ctx).Error(err, "Failed to read check output")
+	}
+
+	check.Status.LastCheckResult = "Failed"
+	reason, summary := checkFailure(latest.Name, message)
+	conditions.SetCondition(&check.Status.Conditions, metav1.Condition{
+		Type:    backupv1alpha1.ConditionIntegrityCheckSucceeded,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: summary,
+	})
+	r.Recorder.Event(check, corev1.EventTypeWarning, reason, summary)
+
+	return nil
+}
+
+// latestFinishedJob returns the most recently finished Job, whether it succeeded and
+// when it finished. It returns nil if no Job has finished yet.
+func latestFinishedJob(jobs []batchv1.Job) (*batchv1.Job, bool, time.Time) {
+	var latest *batchv1.Job
+	var latestSucceeded bool
+	var latestFinishedAt time.Time
+	for i := range jobs {
+		finished, succeeded, finishedAt := jobFinished(&jobs[i])
+		if finished && finishedAt.After(latestFinishedAt) {
+			latest = &jobs[i]
+			latestSucceeded = succeeded
+			latestFinishedAt = finishedAt
+		}
+	}
+	return latest, latestSucceeded, latestFinishedAt
+}
+
+// checkFailure returns the reason and message for a failed check job. Damaged data is
+// reported as CorruptionDetected, any other failure as CheckFailed.
+func checkFailure(jobName, output string) (string, string) {
+	reason := "CheckFailed"
+	summary := fmt.Sprintf("Repository integrity check failed, see job %s", jobName)
+	if strings.Contains(output, checkCorruptionMessage) {
+		reason = "CorruptionDetected"
+		summary = fmt.Sprintf("Repository integrity check found damaged data, see job %s", jobName)
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		summary = fmt.Sprintf("%s: %s", summary, output)
+	}
+	return reason, summary
+}
+
+// buildCheckScript builds the shell script run by the check container. Errors reported
+// by restic are written to the termination message so the operator can tell corrupted
+// data apart from other failures. Options are the extended options of the repository
+// backend, the heartbeat commands run after a successful check.
+func buildCheckScript(check *backupv1alpha1.ResticCheck, options, heartbeat []string) string {
+	cmd := restic.NewCommand("check").WithArgs(options)
+	if check.Spec.ReadDataSubset != "" {
+		cmd.WithReadDataSubset(check.Spec.ReadDataSubset)
+	}
+
+	commands := []string{
+		"set -o pipefail",
+		fmt.Sprintf("restic %s 2>&1 | tee /tmp/check.log", shellQuoteArgs(cmd.Build())),
+		"rc=$?",
+		fmt.Sprintf("grep -E '%s' /tmp/check.log | tail -n 20 > /dev/termination-log || true", checkSummaryPattern),
+	}
+	if len(heartbeat) > 0 {
+		commands = append(commands, "if [ $rc -eq 0 ]; then")
+		commands = append(commands, heartbeat...)
+		commands = append(commands, "fi")
+	}
+	commands = append(commands, "exit $rc")
+
+	return strings.Join(commands, "\n")
+}
+
+func (r *ResticCheckReconciler) buildCronJob(check *backupv1alpha1.ResticCheck, repository *backupv1alpha1.ResticRepository) *batchv1.CronJob {
+	cronJobName := fmt.Sprintf("resticcheck-%s", check.Name)
+
+	// Build restic image
+	resticImage := r.Images.Resolve(check.Spec.Image, check.Spec.JobConfig)
+
+	// Build environment variables
+	envVars := repositoryEnvVars(repository)
+
+	// Build the check script
+	options := repositoryOptions(repository)
+	heartbeat := buildHeartbeatCommands(repository, "check", check.Namespace+"/"+check.Name, options)
+	script := buildCheckScript(check, options, heartbeat)
+
+	var successLimit, failLimit int32 = 3, 3
+	backoffLimit := r.JobDefaults.BackoffLimit(JobOperationCheck, check.Spec.JobConfig)
+	activeDeadline := r.JobDefaults.ActiveDeadlineSeconds(JobOperationCheck, check.Spec.JobConfig)
+
+	if check.Spec.JobConfig != nil {
+		if check.Spec.JobConfig.SuccessfulJobsHistoryLimit != nil {
+			successLimit = *check.Spec.JobConfig.SuccessfulJobsHistoryLimit
+		}
+		if check.Spec.JobConfig.FailedJobsHistoryLimit != nil {
+			failLimit = *check.Spec.JobConfig.FailedJobsHistoryLimit
+		}
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "restic-backup-operator",
+		"app.kubernetes.io/component": "check",
+		resticCheckLabel:              check.Name,
+	}
+
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cronJobName,
+			Namespace: check.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "check",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				resticCheckLabel:               check.Name,
+			},
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   check.Spec.Schedule,
+			Suspend:                    &check.Spec.Suspend,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &successLimit,
+			FailedJobsHistoryLimit:     &failLimit,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit:          &backoffLimit,
+					ActiveDeadlineSeconds: &activeDeadline,
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: labels,
+						},
+						Spec: corev1.PodSpec{
+							RestartPolicy: corev1.RestartPolicyNever,
+							SecurityContext: &corev1.PodSecurityContext{
+								RunAsNonRoot: boolPtr(true),
+								RunAsUser:    int64Ptr(65532),
+								FSGroup:      int64Ptr(65532),
+								SeccompProfile: &corev1.SeccompProfile{
+									Type: corev1.SeccompProfileTypeRuntimeDefault,
+								},
+							},
+							Containers: []corev1.Container{
+								{
+									Name:            "restic",
+									Image:           resticImage,
+									ImagePullPolicy: corev1.PullIfNotPresent,
+									Command:         []string{"/bin/sh", "-c"},
+									Args:            []string{script},
+									Env:             envVars,
+									SecurityContext: &corev1.SecurityContext{
+										AllowPrivilegeEscalation: boolPtr(false),
+										ReadOnlyRootFilesystem:   boolPtr(false),
+										RunAsNonRoot:             boolPtr(true),
+										Capabilities: &corev1.Capabilities{
+											Drop: []corev1.Capability{"ALL"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// Add timezone if specified
+	if check.Spec.Timezone != "" && check.Spec.Timezone != "UTC" {
+		cronJob.Spec.TimeZone = &check.Spec.Timezone
+	}
+
+	// Apply scheduling and networking settings
+	applyRepositoryCache(&cronJob.Spec.JobTemplate.Spec.Template.Spec, repository, check.Namespace)
+	applyRepositoryCredentials(&cronJob.Spec.JobTemplate.Spec.Template.Spec, repository)
+	applyJobConfiguration(&cronJob.Spec.JobTemplate.Spec.Template.Spec, check.Spec.JobConfig)
+	applyPodMetadata(&cronJob.Spec.JobTemplate.Spec.Template.ObjectMeta, check.Spec.JobConfig)
+
+	return cronJob
+}
+
+func (r *ResticCheckReconciler) calculateNextCheck(check *backupv1alpha1.ResticCheck) *metav1.Time {
+	schedule, err := scheduleParser.Parse(check.Spec.Schedule)
+	if err != nil {
+		return nil
+	}
+
+	next := schedule.Next(time.Now())
+	return &metav1.Time{Time: next}
+}
+
+func (r *ResticCheckReconciler) setCondition(check *backupv1alpha1.ResticCheck, condition metav1.Condition) {
+	conditions.SetCondition(&check.Status.Conditions, condition)
+}
+
+// checkForJob maps a check Job to its ResticCheck. The Jobs are owned by the
+// CronJob, so they are matched by label instead of owner reference.
+func checkForJob(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[resticCheckLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ResticCheckReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&backupv1alpha1.ResticCheck{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Owns(&batchv1.CronJob{}).
+		Watches(&batchv1.Job{}, handler.EnqueueRequestsFromMapFunc(checkForJob)).
+		Complete(r)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// finishedJob builds a Job with a Complete or Failed condition at the given time.
+func finishedJob(name string, succeeded bool, at time.Time) batchv1.Job {
+	conditionType := batchv1.JobFailed
+	if succeeded {
+		conditionType = batchv1.JobComplete
+	}
+	return batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{
+				{Type: conditionType, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(at)},
+			},
+		},
+	}
+}
+
+var _ = Describe("ResticCheck Controller", func() {
+	const (
+		timeout  = time.Second * 10
+		interval = time.Millisecond * 250
+	)
+
+	Context("When creating a ResticCheck", Label(envtestLabel), func() {
+		var (
+			testNamespace string
+			checkKey      types.NamespacedName
+		)
+
+		BeforeEach(func() {
+			testNamespace = "test-check-" + randString(5)
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: testNamespace},
+			}
+			Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+
+			checkKey = types.NamespacedName{
+				Name:      "test-check",
+				Namespace: testNamespace,
+			}
+		})
+
+		AfterEach(func() {
+			check := &backupv1alpha1.ResticCheck{}
+			if err := k8sClient.Get(ctx, checkKey, check); err == nil {
+				controllerutil.RemoveFinalizer(check, resticCheckFinalizer)
+				_ = k8sClient.Update(ctx, check)
+				_ = k8sClient.Delete(ctx, check)
+			}
+
+			ns := &corev1.Namespace{}
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: testNamespace}, ns); err == nil {
+				_ = k8sClient.Delete(ctx, ns)
+			}
+		})
+
+		It("should set NotReady condition when repository does not exist", func() {
+			check := &backupv1alpha1.ResticCheck{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      checkKey.Name,
+					Namespace: checkKey.Namespace,
+				},
+				Spec: backupv1alpha1.ResticCheckSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{
+						Name: "nonexistent-repository",
+					},
+					Schedule: "0 4 * * 0",
+				},
+			}
+			Expect(k8sClient.Create(ctx, check)).To(Succeed())
+
+			Eventually(func() string {
+				c := &backupv1alpha1.ResticCheck{}
+				if err := k8sClient.Get(ctx, checkKey, c); err != nil {
+					return ""
+				}
+				for _, cond := range c.Status.Conditions {
+					if cond.Type == "Ready" && cond.Status == metav1.ConditionFalse {
+						return cond.Reason
+					}
+				}
+				return ""
+			}, timeout, interval).Should(Equal("RepositoryNotFound"))
+		})
+
+		It("should default the data subset", func() {
+			check := &backupv1alpha1.ResticCheck{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      checkKey.Name,
+					Namespace: checkKey.Namespace,
+				},
+				Spec: backupv1alpha1.ResticCheckSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{
+						Name: "nonexistent-repository",
+					},
+					Schedule: "0 4 * * 0",
+				},
+			}
+			Expect(k8sClient.Create(ctx, check)).To(Succeed())
+			Expect(check.Spec.ReadDataSubset).To(Equal("10%"))
+		})
+	})
+
+	Context("buildCronJob helper function", func() {
+		var (
+			reconciler *ResticCheckReconciler
+			repository *backupv1alpha1.ResticRepository
+		)
+
+		BeforeEach(func() {
+			reconciler = &ResticCheckReconciler{}
+			repository = &backupv1alpha1.ResticRepository{
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					RepositoryURL: "local:/tmp/test-repo",
+					CredentialsSecretRef: backupv1alpha1.SecretKeySelector{
+						Name: "test-credentials",
+					},
+				},
+			}
+		})
+
+		It("should build a check CronJob reading the data subset", func() {
+			check := &backupv1alpha1.ResticCheck{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "weekly",
+					Namespace: "default",
+				},
+				Spec: backupv1alpha1.ResticCheckSpec{
+					Schedule:       "0 4 * * 0",
+					Timezone:       "Europe/Berlin",
+					ReadDataSubset: "25%",
+				},
+			}
+
+			cronJob := reconciler.buildCronJob(check, repository)
+			Expect(cronJob.Name).To(Equal("resticcheck-weekly"))
+			Expect(cronJob.Spec.Schedule).To(Equal("0 4 * * 0"))
+			Expect(*cronJob.Spec.TimeZone).To(Equal("Europe/Berlin"))
+			Expect(cronJob.Spec.JobTemplate.Labels).To(HaveKeyWithValue(resticCheckLabel, "weekly"))
+
+			container := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("ghcr.io/restic/restic:0.18.0"))
+			Expect(container.Args[0]).To(ContainSubstring("restic 'check' '--read-data-subset' '25%'"))
+			Expect(container.Args[0]).To(ContainSubstring("/dev/termination-log"))
+			Expect(container.Args[0]).To(HaveSuffix("exit $rc"))
+		})
+	})
+
+	Context("check result helper functions", func() {
+		It("should pick the most recently finished job", func() {
+			now := time.Now()
+			jobs := []batchv1.Job{
+				finishedJob("old", false, now.Add(-2*time.Hour)),
+				finishedJob("new", true, now.Add(-time.Hour)),
+				{ObjectMeta: metav1.ObjectMeta{Name: "running"}},
+			}
+
+			latest, succeeded, _ := latestFinishedJob(jobs)
+			Expect(latest.Name).To(Equal("new"))
+			Expect(succeeded).To(BeTrue())
+		})
+
+		It("should return nil without finished jobs", func() {
+			latest, _, _ := latestFinishedJob([]batchv1.Job{{ObjectMeta: metav1.ObjectMeta{Name: "running"}}})
+			Expect(latest).To(BeNil())
+		})
+
+		It("should detect corrupted data", func() {
+			reason, message := checkFailure("resticcheck-weekly-1", "error: pack 1234: ciphertext verification failed\nFatal: repository contains errors\n")
+			Expect(reason).To(Equal("CorruptionDetected"))
+			Expect(message).To(ContainSubstring("resticcheck-weekly-1"))
+			Expect(message).To(ContainSubstring("ciphertext verification failed"))
+		})
+
+		It("should report other failures as CheckFailed", func() {
+			reason, _ := checkFailure("resticcheck-weekly-1", "Fatal: unable to open repository")
+			Expect(reason).To(Equal("CheckFailed"))
+
+			reason, message := checkFailure("resticcheck-weekly-1", "")
+			Expect(reason).To(Equal("CheckFailed"))
+			Expect(message).To(Equal("Repository integrity check failed, see job resticcheck-weekly-1"))
+		})
+
+		It("should compute the next check of schedule descriptors", func() {
+			check := &backupv1alpha1.ResticCheck{Spec: backupv1alpha1.ResticCheckSpec{Schedule: "@weekly"}}
+			next := (&ResticCheckReconciler{}).calculateNextCheck(check)
+			Expect(next).NotTo(BeNil())
+			Expect(next.Time).To(BeTemporally("~", time.Now(), 7*24*time.Hour))
+		})
+
+		It("should map check Jobs to their ResticCheck", func() {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "resticcheck-weekly-1",
+					Namespace: "default",
+					Labels:    map[string]string{resticCheckLabel: "weekly"},
+				},
+			}
+			Expect(checkForJob(context.Background(), job)).To(ConsistOf(HaveField("NamespacedName", types.NamespacedName{Name: "weekly", Namespace: "default"})))
+			Expect(checkForJob(context.Background(), &batchv1.Job{})).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,512 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	resticPruneFinalizer = "backup.resticbackup.io/resticprune-finalizer"
+)
+
+// pruneSummaryPattern selects the lines of the restic prune output that are written to the
+// termination message of the prune container. The termination message is limited to 4 KiB,
+// so only the lines needed to compute the prune statistics are kept.
+const pruneSummaryPattern = `^total prune:|files deleted$|^removing [0-9]+ old packs$`
+
+// ResticPruneReconciler reconciles a ResticPrune object
+type ResticPruneReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// APIReader reads prune pods directly from the API server to avoid caching all pods.
+	// Falls back to Client if not set.
+	APIReader client.Reader
+	// MaxConcurrentReconciles is the number of ResticPrunes reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+	// Images resolves the default restic image, e.g. to a private mirror.
+	Images *ImageConfig
+	// JobDefaults sets the default active deadline and backoff limit of the jobs.
+	JobDefaults *JobDefaults
+	// FeatureGates enables optional capabilities. If nil, the feature defaults apply.
+	FeatureGates *features.Gate
+	// SnapshotCache, if set, is invalidated after each prune.
+	SnapshotCache *SnapshotCache
+}
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticprunes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticprunes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticprunes/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop.
+func (r *ResticPruneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciling ResticPrune")
+
+	// Fetch the ResticPrune instance
+	prune := &backupv1alpha1.ResticPrune{}
+	if err := r.Get(ctx, req.NamespacedName, prune); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("ResticPrune resource not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get ResticPrune")
+		return ctrl.Result{}, err
+	}
+
+	// Handle deletion
+	if !prune.DeletionTimestamp.IsZero() {
+		return r.handleDeletion(ctx, prune)
+	}
+
+	// Add finalizer if missing
+	if !controllerutil.ContainsFinalizer(prune, resticPruneFinalizer) {
+		controllerutil.AddFinalizer(prune, resticPruneFinalizer)
+		if err := r.Update(ctx, prune); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Initialize phase if not set
+	if prune.Status.Phase == "" {
+		prune.Status.Phase = backupv1alpha1.PrunePhasePending
+		if err := r.Status().Update(ctx, prune); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Handle prune based on phase
+	switch prune.Status.Phase {
+	case backupv1alpha1.PrunePhasePending:
+		return r.handlePending(ctx, prune)
+	case backupv1alpha1.PrunePhaseInProgress:
+		return r.handleInProgress(ctx, prune)
+	case backupv1alpha1.PrunePhaseCompleted, backupv1alpha1.PrunePhaseFailed:
+		// Nothing to do for completed/failed prunes
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *ResticPruneReconciler) handleDeletion(ctx context.Context, prune *backupv1alpha1.ResticPrune) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if controllerutil.ContainsFinalizer(prune, resticPruneFinalizer) {
+		log.Info("Performing finalizer cleanup for ResticPrune")
+
+		if err := r.releaseRepository(ctx, prune); err != nil {
+			log.Error(err, "Failed to release repository lease")
+		}
+
+		controllerutil.RemoveFinalizer(prune, resticPruneFinalizer)
+		if err := r.Update(ctx, prune); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *ResticPruneReconciler) handlePending(ctx context.Context, prune *backupv1alpha1.ResticPrune) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	// Reject sidecars the job pod can't be created with
+	if err := validateSidecars(prune.Spec.JobConfig); err != nil {
+		r.setCondition(prune, conditions.NotReadyCondition("InvalidSidecars", err.Error()))
+		r.Recorder.Event(prune, corev1.EventTypeWarning, "InvalidSidecars", err.Error())
+		prune.Status.Phase = backupv1alpha1.PrunePhaseFailed
+		if updateErr := r.Status().Update(ctx, prune); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Get the repository
+	repository, err := r.getRepository(ctx, prune)
+	setReferenceDenied(&prune.Status.Conditions, err)
+	if err != nil {
+		log.Error(err, "Failed to get repository")
+		reason := referenceErrorReason(err, "RepositoryNotFound")
+		r.setCondition(prune, conditions.NotReadyCondition(reason, err.Error()))
+		r.Recorder.Event(prune, corev1.EventTypeWarning, reason, err.Error())
+		prune.Status.Phase = backupv1alpha1.PrunePhaseFailed
+		if updateErr := r.Status().Update(ctx, prune); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Wait until no other job uses the repository
+	if repository.Spec.CoordinateJobs {
+		duration := time.Duration(r.JobDefaults.ActiveDeadlineSeconds(JobOperationPrune, prune.Spec.JobConfig)) * time.Second
+		message, err := acquireRepositoryForExclusiveJob(ctx, r.Client, r.apiReader(), r.Scheme, repository, r.leaseHolder(prune), duration)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		setWaitingForRepository(&prune.Status.Conditions, message)
+		if message != "" {
+			log.Info("Waiting for repository", "reason", message)
+			if err := r.Status().Update(ctx, prune); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: waitingForRepositoryInterval}, nil
+		}
+	}
+
+	// Create prune job
+	job := r.buildPruneJob(prune, repository)
+
+	// Set owner reference
+	if err := controllerutil.SetControllerReference(prune, job, r.Scheme); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	// Record the expected job before creating it, so a reconcile retried after a status
+	// conflict adopts the job
+	if prune.Status.JobRef == nil {
+		prune.Status.JobRef = &backupv1alpha1.ObjectReference{
+			Name:      job.Name,
+			Namespace: job.Namespace,
+		}
+		if err := r.Status().Update(ctx, prune); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Create the job
+	created, err := createOrAdopt(ctx, r.Client, prune, job, &batchv1.Job{})
+	if err != nil {
+		log.Error(err, "Failed to create prune job")
+		r.setCondition(prune, conditions.NotReadyCondition("JobCreationFailed", err.Error()))
+		prune.Status.Phase = backupv1alpha1.PrunePhaseFailed
+		if updateErr := r.Status().Update(ctx, prune); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+	if created {
+		r.Recorder.Event(prune, corev1.EventTypeNormal, "PruneStarted", fmt.Sprintf("Prune job %s created", job.Name))
+	}
+
+	// Update status
+	now := metav1.NewTime(time.Now())
+	prune.Status.Phase = backupv1alpha1.PrunePhaseInProgress
+	prune.Status.StartTime = &now
+	prune.Status.ObservedGeneration = prune.Generation
+	r.setCondition(prune, conditions.NewCondition("Ready", metav1.ConditionUnknown, "PruneInProgress", "Prune job is running"))
+
+	if err := r.Status().Update(ctx, prune); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+}
+
+func (r *ResticPruneReconciler) handleInProgress(ctx context.Context, prune *backupv1alpha1.ResticPrune) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if prune.Status.JobRef == nil {
+		prune.Status.Phase = backupv1alpha1.PrunePhaseFailed
+		r.setCondition(prune, conditions.NotReadyCondition("JobNotFound", "No job reference in status"))
+		if err := r.Status().Update(ctx, prune); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Get the job
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      prune.Status.JobRef.Name,
+		Namespace: prune.Status.JobRef.Namespace,
+	}, job); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("Prune job not found, marking as failed")
+			if err := r.releaseRepository(ctx, prune); err != nil {
+				return ctrl.Result{}, err
+			}
+			prune.Status.Phase = backupv1alpha1.PrunePhaseFailed
+			r.setCondition(prune, conditions.NotReadyCondition("JobNotFound", "Prune job was not found"))
+			if updateErr := r.Status().Update(ctx, prune); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Failed pods are retried up to the backoff limit, the job runs until it finished
+	finished, succeeded, _ := jobFinished(job)
+	if !finished {
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+
+	// Release the repository once the job finished
+	if err := r.releaseRepository(ctx, prune); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.SnapshotCache.Invalidate(r.repositoryKey(prune))
+
+	// Check job status
+	if succeeded {
+		result, err := r.getPruneResult(ctx, job)
+		if err != nil {
+			// Statistics are informational, don't fail the prune because of them
+			log.Error(err, "Failed to read prune statistics")
+		}
+
+		now := metav1.NewTime(time.Now())
+		prune.Status.Phase = backupv1alpha1.PrunePhaseCompleted
+		prune.Status.CompletionTime = &now
+		if result != nil {
+			prune.Status.PacksDeleted = int64(result.PacksDeleted)
+			prune.Status.BytesFreed = int64(result.BytesFreed)
+			prune.Status.SpaceFreed = formatBytes(result.BytesFreed)
+		}
+		message := fmt.Sprintf("Prune completed successfully, deleted %d packs and freed %s",
+			prune.Status.PacksDeleted, formatBytes(uint64(prune.Status.BytesFreed)))
+		r.setCondition(prune, conditions.ReadyCondition("PruneCompleted", message))
+		r.Recorder.Event(prune, corev1.EventTypeNormal, "PruneCompleted", message)
+		if err := r.Status().Update(ctx, prune); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	now := metav1.NewTime(time.Now())
+	prune.Status.Phase = backupv1alpha1.PrunePhaseFailed
+	prune.Status.CompletionTime = &now
+	r.setCondition(prune, conditions.NotReadyCondition("PruneFailed", "Prune job failed"))
+	r.Recorder.Event(prune, corev1.EventTypeWarning, "PruneFailed", "Prune job failed")
+	if err := r.Status().Update(ctx, prune); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// releaseRepository releases the repository Lease held by the prune, if any.
+func (r *ResticPruneReconciler) releaseRepository(ctx context.Context, prune *backupv1alpha1.ResticPrune) error {
+	repository, err := r.getRepository(ctx, prune)
+	if err != nil {
+		// The Lease of a deleted repository is garbage collected with it, the Lease of
+		// a repository no longer granted expires
+		var denied *referenceDeniedError
+		if apierrors.IsNotFound(err) || errors.As(err, &denied) {
+			return nil
+		}
+		return err
+	}
+	if !repository.Spec.CoordinateJobs {
+		return nil
+	}
+	return releaseRepositoryLease(ctx, r.Client, r.apiReader(), repository, r.leaseHolder(prune))
+}
+
+// leaseHolder returns the identity of the prune in the repository Lease.
+func (r *ResticPruneReconciler) leaseHolder(prune *backupv1alpha1.ResticPrune) string {
+	return "ResticPrune/" + prune.Namespace + "/" + prune.Name
+}
+
+func (r *ResticPruneReconciler) apiReader() client.Reader {
+	if r.APIReader == nil {
+		return r.Client
+	}
+	return r.APIReader
+}
+
+// getPruneResult reads the prune statistics from the termination message of the
+// prune pod.
+func (r *ResticPruneReconciler) getPruneResult(ctx context.Context, job *batchv1.Job) (*restic.PruneResult, error) {
+	message, err := jobTerminationMessage(ctx, r.apiReader(), job)
+	if err != nil {
+		return nil, err
+	}
+	return restic.ParsePruneOutput(message), nil
+}
+
+// repositoryKey returns the key of the repository referenced by the prune.
+func (r *ResticPruneReconciler) repositoryKey(prune *backupv1alpha1.ResticPrune) types.NamespacedName {
+	ns := prune.Spec.RepositoryRef.Namespace
+	if ns == "" {
+		ns = prune.Namespace
+	}
+	return types.NamespacedName{Name: prune.Spec.RepositoryRef.Name, Namespace: ns}
+}
+
+func (r *ResticPruneReconciler) getRepository(ctx context.Context, prune *backupv1alpha1.ResticPrune) (*backupv1alpha1.ResticRepository, error) {
+	repository := &backupv1alpha1.ResticRepository{}
+	name := r.repositoryKey(prune)
+	if err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "ResticPrune", prune.Namespace, "ResticRepository", name.Namespace, name.Name); err != nil {
+		return nil, err
+	}
+	if err := r.Get(ctx, name, repository); err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	return repository, nil
+}
+
+// buildPruneScript builds the shell script run by the prune container. The prune output
+// is written to the container log and its summary to the termination message.
+// Options are the extended options of the repository backend.
+func buildPruneScript(prune *backupv1alpha1.ResticPrune, options []string) string {
+	cmd := restic.NewCommand("prune").WithArgs(options)
+	if opts := prune.Spec.Options; opts != nil {
+		if opts.MaxUnused != "" {
+			cmd.WithArgs([]string{"--max-unused", opts.MaxUnused})
+		}
+		if opts.MaxRepackSize != "" {
+			cmd.WithArgs([]string{"--max-repack-size", opts.MaxRepackSize})
+		}
+		if opts.RepackCacheableOnly {
+			cmd.WithArg("--repack-cacheable-only")
+		}
+	}
+
+	commands := []string{
+		"set -eo pipefail",
+		fmt.Sprintf("restic %s 2>&1 | tee /tmp/prune.log", shellQuoteArgs(cmd.Build())),
+		fmt.Sprintf("grep -E '%s' /tmp/prune.log > /dev/termination-log || true", pruneSummaryPattern),
+	}
+
+	return strings.Join(commands, "\n")
+}
+
+// shellQuoteArgs quotes each argument for use in a POSIX shell.
+func shellQuoteArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
+
+func (r *ResticPruneReconciler) buildPruneJob(prune *backupv1alpha1.ResticPrune, repository *backupv1alpha1.ResticRepository) *batchv1.Job {
+	jobName := fmt.Sprintf("resticprune-%s", prune.Name)
+
+	// Build restic image
+	resticImage := r.Images.Resolve(prune.Spec.Image, prune.Spec.JobConfig)
+
+	// Build environment variables
+	envVars := repositoryEnvVars(repository)
+
+	backoffLimit := r.JobDefaults.BackoffLimit(JobOperationPrune, prune.Spec.JobConfig)
+	activeDeadline := r.JobDefaults.ActiveDeadlineSeconds(JobOperationPrune, prune.Spec.JobConfig)
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: prune.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "prune",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				"backup.resticbackup.io/prune": prune.Name,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &activeDeadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app.kubernetes.io/name":       "restic-backup-operator",
+						"app.kubernetes.io/component":  "prune",
+						"backup.resticbackup.io/prune": prune.Name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot: boolPtr(true),
+						RunAsUser:    int64Ptr(65532),
+						FSGroup:      int64Ptr(65532),
+						SeccompProfile: &corev1.SeccompProfile{
+							Type: corev1.SeccompProfileTypeRuntimeDefault,
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:            "restic",
+							Image:           resticImage,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Command:         []string{"/bin/sh", "-c"},
+							Args:            []string{buildPruneScript(prune, repositoryOptions(repository))},
+							Env:             envVars,
+							SecurityContext: &corev1.SecurityContext{
+								AllowPrivilegeEscalation: boolPtr(false),
+								ReadOnlyRootFilesystem:   boolPtr(false),
+								RunAsNonRoot:             boolPtr(true),
+								Capabilities: &corev1.Capabilities{
+									Drop: []corev1.Capability{"ALL"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// Apply scheduling and networking settings
+	applyRepositoryCache(&job.Spec.Template.Spec, repository, prune.Namespace)
+	applyRepositoryCredentials(&job.Spec.Template.Spec, repository)
+	applyJobConfiguration(&job.Spec.Template.Spec, prune.Spec.JobConfig)
+	applyPodMetadata(&job.Spec.Template.ObjectMeta, prune.Spec.JobConfig)
+
+	return job
+}
+
+func (r *ResticPruneReconciler) setCondition(prune *backupv1alpha1.ResticPrune, condition metav1.Condition) {
+	conditions.SetCondition(&prune.Status.Conditions, condition)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ResticPruneReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&backupv1alpha1.ResticPrune{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
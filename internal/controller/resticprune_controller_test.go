@@ -0,0 +1,276 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("ResticPrune Controller", func() {
+	const (
+		timeout  = time.Second * 10
+		interval = time.Millisecond * 250
+	)
+
+	Context("When creating a ResticPrune", Label(envtestLabel), func() {
+		var (
+			testNamespace string
+			pruneKey      types.NamespacedName
+			repositoryKey types.NamespacedName
+		)
+
+		BeforeEach(func() {
+			testNamespace = "test-prune-" + randString(5)
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: testNamespace},
+			}
+			Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+
+			pruneKey = types.NamespacedName{
+				Name:      "test-prune",
+				Namespace: testNamespace,
+			}
+			repositoryKey = types.NamespacedName{
+				Name:      "test-repository",
+				Namespace: testNamespace,
+			}
+		})
+
+		AfterEach(func() {
+			prune := &backupv1alpha1.ResticPrune{}
+			if err := k8sClient.Get(ctx, pruneKey, prune); err == nil {
+				controllerutil.RemoveFinalizer(prune, resticPruneFinalizer)
+				_ = k8sClient.Update(ctx, prune)
+				_ = k8sClient.Delete(ctx, prune)
+			}
+
+			job := &batchv1.Job{}
+			jobKey := types.NamespacedName{
+				Name:      "resticprune-" + pruneKey.Name,
+				Namespace: testNamespace,
+			}
+			if err := k8sClient.Get(ctx, jobKey, job); err == nil {
+				_ = k8sClient.Delete(ctx, job)
+			}
+
+			repository := &backupv1alpha1.ResticRepository{}
+			if err := k8sClient.Get(ctx, repositoryKey, repository); err == nil {
+				_ = k8sClient.Delete(ctx, repository)
+			}
+
+			ns := &corev1.Namespace{}
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: testNamespace}, ns); err == nil {
+				_ = k8sClient.Delete(ctx, ns)
+			}
+		})
+
+		It("should transition from Pending to Failed when repository does not exist", func() {
+			prune := &backupv1alpha1.ResticPrune{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pruneKey.Name,
+					Namespace: pruneKey.Namespace,
+				},
+				Spec: backupv1alpha1.ResticPruneSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{
+						Name: repositoryKey.Name,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, prune)).To(Succeed())
+
+			Eventually(func() backupv1alpha1.PrunePhase {
+				p := &backupv1alpha1.ResticPrune{}
+				if err := k8sClient.Get(ctx, pruneKey, p); err != nil {
+					return ""
+				}
+				return p.Status.Phase
+			}, timeout, interval).Should(Equal(backupv1alpha1.PrunePhaseFailed))
+		})
+
+		It("should create prune job when the repository exists", func() {
+			repository := &backupv1alpha1.ResticRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      repositoryKey.Name,
+					Namespace: repositoryKey.Namespace,
+				},
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					RepositoryURL: "local:/tmp/test-repo",
+					CredentialsSecretRef: backupv1alpha1.SecretKeySelector{
+						Name: "test-credentials",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, repository)).To(Succeed())
+
+			prune := &backupv1alpha1.ResticPrune{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pruneKey.Name,
+					Namespace: pruneKey.Namespace,
+				},
+				Spec: backupv1alpha1.ResticPruneSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{
+						Name: repositoryKey.Name,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, prune)).To(Succeed())
+
+			jobKey := types.NamespacedName{
+				Name:      "resticprune-" + pruneKey.Name,
+				Namespace: testNamespace,
+			}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, jobKey, &batchv1.Job{})
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() backupv1alpha1.PrunePhase {
+				p := &backupv1alpha1.ResticPrune{}
+				if err := k8sClient.Get(ctx, pruneKey, p); err != nil {
+					return ""
+				}
+				return p.Status.Phase
+			}, timeout, interval).Should(Equal(backupv1alpha1.PrunePhaseInProgress))
+		})
+
+		It("should read prune statistics from the pod termination message", func() {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "resticprune-" + pruneKey.Name,
+					Namespace: testNamespace,
+				},
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      job.Name + "-abcde",
+					Namespace: testNamespace,
+					Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{Name: "restic", Image: "ghcr.io/restic/restic:0.18.0"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+
+			pod.Status.Phase = corev1.PodSucceeded
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{
+					Name: "restic",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							Message: "total prune:          22 blobs / 2.000 MiB\n[0:00] 100.00%  3 / 3 files deleted\n",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			reconciler := &ResticPruneReconciler{Client: k8sClient}
+			result, err := reconciler.getPruneResult(ctx, job)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.PacksDeleted).To(Equal(3))
+			Expect(result.BytesFreed).To(Equal(uint64(2 * 1024 * 1024)))
+		})
+	})
+
+	Context("buildPruneJob helper function", func() {
+		var (
+			reconciler *ResticPruneReconciler
+			repository *backupv1alpha1.ResticRepository
+		)
+
+		BeforeEach(func() {
+			reconciler = &ResticPruneReconciler{}
+			repository = &backupv1alpha1.ResticRepository{
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					RepositoryURL: "local:/tmp/test-repo",
+					CredentialsSecretRef: backupv1alpha1.SecretKeySelector{
+						Name: "test-credentials",
+					},
+				},
+			}
+		})
+
+		It("should build basic prune job", func() {
+			prune := &backupv1alpha1.ResticPrune{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-prune",
+					Namespace: "default",
+				},
+			}
+
+			job := reconciler.buildPruneJob(prune, repository)
+			Expect(job.Name).To(Equal("resticprune-test-prune"))
+			Expect(job.Namespace).To(Equal("default"))
+			Expect(job.Labels).To(HaveKeyWithValue("app.kubernetes.io/component", "prune"))
+
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("ghcr.io/restic/restic:0.18.0"))
+			Expect(container.Command).To(Equal([]string{"/bin/sh", "-c"}))
+			Expect(container.Args[0]).To(ContainSubstring("restic 'prune' 2>&1"))
+			Expect(container.Args[0]).To(ContainSubstring("/dev/termination-log"))
+		})
+
+		It("should pass prune options to restic", func() {
+			prune := &backupv1alpha1.ResticPrune{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-prune",
+					Namespace: "default",
+				},
+				Spec: backupv1alpha1.ResticPruneSpec{
+					Image: "ghcr.io/restic/restic:0.18.1",
+					Options: &backupv1alpha1.PruneOptions{
+						MaxUnused:           "5%",
+						MaxRepackSize:       "10G",
+						RepackCacheableOnly: true,
+					},
+				},
+			}
+
+			job := reconciler.buildPruneJob(prune, repository)
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("ghcr.io/restic/restic:0.18.1"))
+			Expect(container.Args[0]).To(ContainSubstring("'--max-unused' '5%'"))
+			Expect(container.Args[0]).To(ContainSubstring("'--max-repack-size' '10G'"))
+			Expect(container.Args[0]).To(ContainSubstring("'--repack-cacheable-only'"))
+		})
+
+		It("should quote shell metacharacters in options", func() {
+			prune := &backupv1alpha1.ResticPrune{
+				Spec: backupv1alpha1.ResticPruneSpec{
+					Options: &backupv1alpha1.PruneOptions{
+						MaxUnused: "5%'; rm -rf /; echo '",
+					},
+				},
+			}
+
+			Expect(buildPruneScript(prune, nil)).To(ContainSubstring(`'5%'\''; rm -rf /; echo '\'''`))
+		})
+	})
+})
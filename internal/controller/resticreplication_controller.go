@@ -0,0 +1,591 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	resticReplicationFinalizer = "backup.resticbackup.io/resticreplication-finalizer"
+	// resticReplicationLabel links replication CronJobs and their Jobs to the ResticReplication
+	resticReplicationLabel = "backup.resticbackup.io/replication"
+)
+
+// ResticReplicationReconciler reconciles a ResticReplication object
+type ResticReplicationReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Executor is optional - if nil, a default executor will be created
+	Executor restic.Executor
+	// StartupAudit, if set, delays reconciles until existing child objects are corrected.
+	StartupAudit *StartupAudit
+	// MaxConcurrentReconciles is the number of ResticReplications reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+	// Images resolves the default restic image, e.g. to a private mirror.
+	Images *ImageConfig
+	// JobDefaults sets the default active deadline and backoff limit of the jobs.
+	JobDefaults *JobDefaults
+	// FeatureGates enables optional capabilities. If nil, the feature defaults apply.
+	FeatureGates *features.Gate
+}
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticreplications,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticreplications/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticreplications/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop.
+func (r *ResticReplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciling ResticReplication")
+
+	// Wait for the startup audit to correct existing child objects
+	if err := r.StartupAudit.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	replication := &backupv1alpha1.ResticReplication{}
+	if err := r.Get(ctx, req.NamespacedName, replication); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("ResticReplication resource not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get ResticReplication")
+		return ctrl.Result{}, err
+	}
+
+	// Handle deletion
+	if !replication.DeletionTimestamp.IsZero() {
+		return r.handleDeletion(ctx, replication)
+	}
+
+	// Add finalizer if missing
+	if !controllerutil.ContainsFinalizer(replication, resticReplicationFinalizer) {
+		controllerutil.AddFinalizer(replication, resticReplicationFinalizer)
+		if err := r.Update(ctx, replication); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Reject sidecars the job pods can't be created with
+	if err := validateSidecars(replication.Spec.JobConfig); err != nil {
+		r.setCondition(replication, conditions.NotReadyCondition("InvalidSidecars", err.Error()))
+		r.Recorder.Event(replication, corev1.EventTypeWarning, "InvalidSidecars", err.Error())
+		if updateErr := r.Status().Update(ctx, replication); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Get both repositories
+	source, err := r.getRepository(ctx, replication, replication.Spec.SourceRepositoryRef)
+	if err == nil {
+		var destination *backupv1alpha1.ResticRepository
+		destination, err = r.getRepository(ctx, replication, replication.Spec.DestinationRepositoryRef)
+		if err == nil {
+			return r.reconcileReplication(ctx, replication, source, destination)
+		}
+	}
+	setReferenceDenied(&replication.Status.Conditions, err)
+	log.Error(err, "Failed to get repository")
+	reason := referenceErrorReason(err, "RepositoryNotFound")
+	r.setCondition(replication, conditions.NotReadyCondition(reason, err.Error()))
+	r.Recorder.Event(replication, corev1.EventTypeWarning, reason, err.Error())
+	if updateErr := r.Status().Update(ctx, replication); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+}
+
+// reconcileReplication reconciles the CronJob copying the snapshots and reports the
+// replicated snapshots.
+func (r *ResticReplicationReconciler) reconcileReplication(ctx context.Context, replication *backupv1alpha1.ResticReplication,
+	source, destination *backupv1alpha1.ResticRepository) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	setReferenceDenied(&replication.Status.Conditions, nil)
+
+	if client.ObjectKeyFromObject(source) == client.ObjectKeyFromObject(destination) {
+		msg := "source and destination reference the same repository"
+		r.setCondition(replication, conditions.NotReadyCondition("InvalidReplication", msg))
+		if err := r.Status().Update(ctx, replication); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Check both repositories are ready
+	for _, repository := range []*backupv1alpha1.ResticRepository{source, destination} {
+		if !conditions.IsConditionTrue(repository.Status.Conditions, "Ready") {
+			log.Info("Repository not ready, requeuing", "repository", repository.Name)
+			r.setCondition(replication, conditions.NotReadyCondition("RepositoryNotReady",
+				fmt.Sprintf("Referenced repository %s is not ready", repository.Name)))
+			if err := r.Status().Update(ctx, replication); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+	}
+
+	// Reconcile CronJob
+	if err := r.reconcileCronJob(ctx, replication, source, destination); err != nil {
+		log.Error(err, "Failed to reconcile CronJob")
+		r.setCondition(replication, conditions.NotReadyCondition("CronJobFailed", err.Error()))
+		r.Recorder.Event(replication, corev1.EventTypeWarning, "CronJobFailed", err.Error())
+		if updateErr := r.Status().Update(ctx, replication); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+	}
+
+	// Report the result of the latest finished replication job, and list the replicated
+	// snapshots after each run or when the filters changed
+	finished, err := r.updateLastReplication(ctx, replication)
+	if err != nil {
+		log.Error(err, "Failed to evaluate replication jobs")
+	}
+	if finished || replication.Status.ObservedGeneration != replication.Generation {
+		if err := r.updateReplicatedSnapshots(ctx, replication, source, destination); err != nil {
+			log.Error(err, "Failed to list replicated snapshots")
+		}
+	}
+
+	if next := r.calculateNextReplication(replication); next != nil {
+		replication.Status.NextReplication = next
+	}
+
+	r.setCondition(replication, conditions.ReadyCondition("ReplicationConfigured", "Replication CronJob is configured"))
+	replication.Status.ObservedGeneration = replication.Generation
+
+	if err := r.Status().Update(ctx, replication); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+func (r *ResticReplicationReconciler) handleDeletion(ctx context.Context, replication *backupv1alpha1.ResticReplication) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if controllerutil.ContainsFinalizer(replication, resticReplicationFinalizer) {
+		log.Info("Performing finalizer cleanup for ResticReplication")
+
+		// CronJob will be garbage collected due to owner reference
+
+		controllerutil.RemoveFinalizer(replication, resticReplicationFinalizer)
+		if err := r.Update(ctx, replication); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *ResticReplicationReconciler) getRepository(ctx context.Context, replication *backupv1alpha1.ResticReplication,
+	ref backupv1alpha1.CrossNamespaceObjectReference) (*backupv1alpha1.ResticRepository, error) {
+	repository := &backupv1alpha1.ResticRepository{}
+	ns := ref.Namespace
+	if ns == "" {
+		ns = replication.Namespace
+	}
+
+	if err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "ResticReplication", replication.Namespace, "ResticRepository", ns, ref.Name); err != nil {
+		return nil, err
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ns}, repository); err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	return repository, nil
+}
+
+func (r *ResticReplicationReconciler) reconcileCronJob(ctx context.Context, replication *backupv1alpha1.ResticReplication,
+	source, destination *backupv1alpha1.ResticRepository) error {
+	log := log.FromContext(ctx)
+
+	cronJob := r.buildCronJob(replication, source, destination)
+
+	// Set owner reference
+	if err := controllerutil.SetControllerReference(replication, cronJob, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	// Update status with CronJob reference
+	replication.Status.CronJobRef = &backupv1alpha1.ObjectReference{
+		Name:      cronJob.Name,
+		Namespace: cronJob.Namespace,
+	}
+
+	existingCronJob := &batchv1.CronJob{}
+	err := r.Get(ctx, types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, existingCronJob)
+
+	if apierrors.IsNotFound(err) {
+		log.Info("Creating CronJob", "name", cronJob.Name)
+		created, err := createOrAdopt(ctx, r.Client, replication, cronJob, existingCronJob)
+		if err != nil {
+			return fmt.Errorf("failed to create CronJob: %w", err)
+		}
+		if created {
+			r.Recorder.Event(replication, corev1.EventTypeNormal, "CronJobCreated", fmt.Sprintf("Created CronJob %s", cronJob.Name))
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get CronJob: %w", err)
+	}
+
+	// Update existing CronJob
+	existingCronJob.Spec = cronJob.Spec
+	if err := r.Update(ctx, existingCronJob); err != nil {
+		return fmt.Errorf("failed to update CronJob: %w", err)
+	}
+
+	return nil
+}
+
+// updateLastReplication evaluates the most recently finished replication job and
+// reports whether a job finished since the last evaluation. Each job is reported only
+// once, so events are emitted once per replication run.
+func (r *ResticReplicationReconciler) updateLastReplication(ctx context.Context, replication *backupv1alpha1.ResticReplication) (bool, error) {
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs,
+		client.InNamespace(replication.Namespace),
+		client.MatchingLabels{resticReplicationLabel: replication.Name},
+	); err != nil {
+		return false, fmt.Errorf("failed to list replication jobs: %w", err)
+	}
+
+	latest, latestSucceeded, latestFinishedAt := latestFinishedJob(jobs.Items)
+	if latest == nil || latest.Name == replication.Status.LastReplicationJob {
+		return false, nil
+	}
+
+	finishedAt := metav1.NewTime(latestFinishedAt)
+	replication.Status.LastReplication = &finishedAt
+	replication.Status.LastReplicationJob = latest.Name
+
+	if latestSucceeded {
+		replication.Status.LastReplicationResult = "Succeeded"
+		replication.Status.LastSuccessfulReplication = &finishedAt
+		r.Recorder.Event(replication, corev1.EventTypeNormal, "ReplicationSucceeded", "Snapshots copied to the destination repository")
+		return true, nil
+	}
+
+	msg := fmt.Sprintf("Replication failed, see job %s", latest.Name)
+	replication.Status.LastReplicationResult = "Failed"
+	conditions.SetCondition(&replication.Status.Conditions, metav1.Condition{
+		Type:    backupv1alpha1.ConditionReplicated,
+		Status:  metav1.ConditionFalse,
+		Reason:  "ReplicationFailed",
+		Message: msg,
+	})
+	r.Recorder.Event(replication, corev1.EventTypeWarning, "ReplicationFailed", msg)
+	return true, nil
+}
+
+// updateReplicatedSnapshots lists the snapshots of both repositories and reports the
+// source snapshots found in the destination.
+func (r *ResticReplicationReconciler) updateReplicatedSnapshots(ctx context.Context, replication *backupv1alpha1.ResticReplication,
+	source, destination *backupv1alpha1.ResticRepository) error {
+	executor := r.Executor
+	if executor == nil {
+		executor = restic.NewExecutor(log.FromContext(ctx))
+	}
+
+	var listed [2][]restic.Snapshot
+	for i, repository := range []*backupv1alpha1.ResticRepository{source, destination} {
+		creds, err := repositoryCredentials(ctx, r.Client, repository)
+		if err != nil {
+			return err
+		}
+		if listed[i], err = executor.Snapshots(ctx, creds); err != nil {
+			return fmt.Errorf("failed to list snapshots of %s: %w", repository.Name, err)
+		}
+	}
+
+	replicated, pending := replicatedSnapshots(replication, listed[0], listed[1])
+	replication.Status.ReplicatedSnapshotCount = int32(len(replicated))
+	replication.Status.PendingSnapshotCount = int32(pending)
+	if len(replicated) > defaultMaxListedSnapshots {
+		replicated = replicated[:defaultMaxListedSnapshots]
+	}
+	replication.Status.ReplicatedSnapshots = replicated
+
+	status, reason, msg := metav1.ConditionTrue, "SnapshotsReplicated", "All source snapshots are in the destination repository"
+	if pending > 0 {
+		status, reason, msg = metav1.ConditionFalse, "SnapshotsPending", fmt.Sprintf("%d source snapshots are not in the destination repository", pending)
+	}
+	if replication.Status.LastReplicationResult != "Failed" || pending == 0 {
+		conditions.SetCondition(&replication.Status.Conditions, metav1.Condition{
+			Type:    backupv1alpha1.ConditionReplicated,
+			Status:  status,
+			Reason:  reason,
+			Message: msg,
+		})
+	}
+	return nil
+}
+
+// replicatedSnapshots returns the short IDs of the source snapshots matching the filters
+// of the replication that are in the destination, newest first, and the number of
+// matching source snapshots missing from it. restic copy records the ID of the copied
+// snapshot as original, and keeps the original of copies.
+func replicatedSnapshots(replication *backupv1alpha1.ResticReplication, source, destination []restic.Snapshot) ([]string, int) {
+	originalID := func(snapshot restic.Snapshot) string {
+		if snapshot.Original != "" {
+			return snapshot.Original
+		}
+		return snapshot.ID
+	}
+	copied := make(map[string]bool, len(destination))
+	for _, snapshot := range destination {
+		copied[originalID(snapshot)] = true
+	}
+
+	sorted := slices.Clone(source)
+	slices.SortStableFunc(sorted, func(a, b restic.Snapshot) int {
+		return b.Time.Compare(a.Time)
+	})
+
+	replicated := []string{}
+	pending := 0
+	for _, snapshot := range sorted {
+		if !replicationMatches(replication, snapshot) {
+			continue
+		}
+		if !copied[originalID(snapshot)] {
+			pending++
+			continue
+		}
+		id := snapshot.ShortID
+		if id == "" {
+			id = snapshot.ID[:min(8, len(snapshot.ID))]
+		}
+		replicated = append(replicated, id)
+	}
+	return replicated, pending
+}
+
+// replicationMatches reports whether restic copy selects the snapshot with the host and
+// tag filters of the replication. Several filters of a kind match any of them.
+func replicationMatches(replication *backupv1alpha1.ResticReplication, snapshot restic.Snapshot) bool {
+	if len(replication.Spec.Hosts) > 0 && !slices.Contains(replication.Spec.Hosts, snapshot.Hostname) {
+		return false
+	}
+	if len(replication.Spec.Tags) == 0 {
+		return true
+	}
+	for _, tag := range replication.Spec.Tags {
+		if slices.Contains(snapshot.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildReplicationScript builds the shell script copying the snapshots. Options are the
+// extended options of the destination repository backend.
+func buildReplicationScript(replication *backupv1alpha1.ResticReplication, options []string) string {
+	cmd := restic.NewCommand("copy").WithArgs(options).WithTags(replication.Spec.Tags)
+	for _, host := range replication.Spec.Hosts {
+		cmd.WithHost(host)
+	}
+	return fmt.Sprintf("restic %s", shellQuoteArgs(cmd.Build()))
+}
+
+// replicationEnvVars returns the environment of the replication container: the
+// credentials of the destination repository and the repository and password of the
+// source. restic copy shares the backend credentials between both repositories.
+func replicationEnvVars(source, destination *backupv1alpha1.ResticRepository) []corev1.EnvVar {
+	envVars := repositoryEnvVars(destination)
+	return append(envVars,
+		corev1.EnvVar{
+			Name:  "RESTIC_FROM_REPOSITORY",
+			Value: source.Spec.RepositoryURL,
+		},
+		corev1.EnvVar{
+			Name: "RESTIC_FROM_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: source.Spec.CredentialsSecretRef.Name},
+				Key:                  repositoryCredentialKeys(source).Password,
+			}},
+		},
+	)
+}
+
+func (r *ResticReplicationReconciler) buildCronJob(replication *backupv1alpha1.ResticReplication,
+	source, destination *backupv1alpha1.ResticRepository) *batchv1.CronJob {
+	cronJobName := fmt.Sprintf("resticreplication-%s", replication.Name)
+
+	resticImage := r.Images.Resolve(replication.Spec.Image, replication.Spec.JobConfig)
+	script := buildReplicationScript(replication, repositoryOptions(destination))
+
+	var successLimit, failLimit int32 = 3, 3
+	backoffLimit := r.JobDefaults.BackoffLimit(JobOperationReplication, replication.Spec.JobConfig)
+	activeDeadline := r.JobDefaults.ActiveDeadlineSeconds(JobOperationReplication, replication.Spec.JobConfig)
+
+	if replication.Spec.JobConfig != nil {
+		if replication.Spec.JobConfig.SuccessfulJobsHistoryLimit != nil {
+			successLimit = *replication.Spec.JobConfig.SuccessfulJobsHistoryLimit
+		}
+		if replication.Spec.JobConfig.FailedJobsHistoryLimit != nil {
+			failLimit = *replication.Spec.JobConfig.FailedJobsHistoryLimit
+		}
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "restic-backup-operator",
+		"app.kubernetes.io/component": "replication",
+		resticReplicationLabel:        replication.Name,
+	}
+
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cronJobName,
+			Namespace: replication.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "replication",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				resticReplicationLabel:         replication.Name,
+			},
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   replication.Spec.Schedule,
+			Suspend:                    &replication.Spec.Suspend,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &successLimit,
+			FailedJobsHistoryLimit:     &failLimit,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit:          &backoffLimit,
+					ActiveDeadlineSeconds: &activeDeadline,
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: labels,
+						},
+						Spec: corev1.PodSpec{
+							RestartPolicy: corev1.RestartPolicyNever,
+							SecurityContext: &corev1.PodSecurityContext{
+								RunAsNonRoot: boolPtr(true),
+								RunAsUser:    int64Ptr(65532),
+								FSGroup:      int64Ptr(65532),
+								SeccompProfile: &corev1.SeccompProfile{
+									Type: corev1.SeccompProfileTypeRuntimeDefault,
+								},
+							},
+							Containers: []corev1.Container{
+								{
+									Name:            "restic",
+									Image:           resticImage,
+									ImagePullPolicy: corev1.PullIfNotPresent,
+									Command:         []string{"/bin/sh", "-c"},
+									Args:            []string{script},
+									Env:             replicationEnvVars(source, destination),
+									SecurityContext: &corev1.SecurityContext{
+										AllowPrivilegeEscalation: boolPtr(false),
+										ReadOnlyRootFilesystem:   boolPtr(false),
+										RunAsNonRoot:             boolPtr(true),
+										Capabilities: &corev1.Capabilities{
+											Drop: []corev1.Capability{"ALL"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// Add timezone if specified
+	if replication.Spec.Timezone != "" && replication.Spec.Timezone != "UTC" {
+		cronJob.Spec.TimeZone = &replication.Spec.Timezone
+	}
+
+	// Apply scheduling and networking settings
+	applyRepositoryCache(&cronJob.Spec.JobTemplate.Spec.Template.Spec, destination, replication.Namespace)
+	applyRepositoryCredentials(&cronJob.Spec.JobTemplate.Spec.Template.Spec, destination)
+	applyJobConfiguration(&cronJob.Spec.JobTemplate.Spec.Template.Spec, replication.Spec.JobConfig)
+	applyPodMetadata(&cronJob.Spec.JobTemplate.Spec.Template.ObjectMeta, replication.Spec.JobConfig)
+
+	return cronJob
+}
+
+func (r *ResticReplicationReconciler) calculateNextReplication(replication *backupv1alpha1.ResticReplication) *metav1.Time {
+	schedule, err := scheduleParser.Parse(replication.Spec.Schedule)
+	if err != nil {
+		return nil
+	}
+
+	next := schedule.Next(time.Now())
+	return &metav1.Time{Time: next}
+}
+
+func (r *ResticReplicationReconciler) setCondition(replication *backupv1alpha1.ResticReplication, condition metav1.Condition) {
+	conditions.SetCondition(&replication.Status.Conditions, condition)
+}
+
+// replicationForJob maps a replication Job to its ResticReplication. The Jobs are owned
+// by the CronJob, so they are matched by label instead of owner reference.
+func replicationForJob(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[resticReplicationLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ResticReplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&backupv1alpha1.ResticReplication{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Owns(&batchv1.CronJob{}).
+		Watches(&batchv1.Job{}, handler.EnqueueRequestsFromMapFunc(replicationForJob)).
+		Complete(r)
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// repositorySnapshotsExecutor lists fixed snapshots per repository URL.
+type repositorySnapshotsExecutor struct {
+	MockExecutor
+	snapshots map[string][]restic.Snapshot
+}
+
+func (e *repositorySnapshotsExecutor) Snapshots(_ context.Context, creds restic.Credentials) ([]restic.Snapshot, error) {
+	return e.snapshots[creds.Repository], nil
+}
+
+var _ = Describe("ResticReplication Controller", func() {
+	var source, destination *backupv1alpha1.ResticRepository
+
+	BeforeEach(func() {
+		source = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "primary", Namespace: "default"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/primary",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "primary-credentials"},
+			},
+		}
+		destination = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "offsite", Namespace: "default"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/offsite",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "offsite-credentials"},
+			},
+		}
+	})
+
+	Context("buildCronJob helper function", func() {
+		It("should copy the filtered snapshots from the source repository", func() {
+			replication := &backupv1alpha1.ResticReplication{
+				ObjectMeta: metav1.ObjectMeta{Name: "offsite", Namespace: "default"},
+				Spec: backupv1alpha1.ResticReplicationSpec{
+					Schedule: "0 5 * * *",
+					Hosts:    []string{"default-app"},
+					Tags:     []string{"daily"},
+				},
+			}
+
+			cronJob := (&ResticReplicationReconciler{}).buildCronJob(replication, source, destination)
+			Expect(cronJob.Name).To(Equal("resticreplication-offsite"))
+			Expect(cronJob.Spec.JobTemplate.Labels).To(HaveKeyWithValue(resticReplicationLabel, "offsite"))
+
+			container := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+			Expect(container.Args[0]).To(Equal("restic 'copy' '--tag' 'daily' '--host' 'default-app'"))
+
+			env := map[string]string{}
+			for _, e := range container.Env {
+				env[e.Name] = e.Value
+				if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil {
+					env[e.Name] = e.ValueFrom.SecretKeyRef.Name + "/" + e.ValueFrom.SecretKeyRef.Key
+				}
+			}
+			Expect(env).To(HaveKeyWithValue("RESTIC_REPOSITORY", "s3:s3.amazonaws.com/offsite"))
+			Expect(env).To(HaveKeyWithValue("RESTIC_PASSWORD", "offsite-credentials/RESTIC_PASSWORD"))
+			Expect(env).To(HaveKeyWithValue("RESTIC_FROM_REPOSITORY", "s3:s3.amazonaws.com/primary"))
+			Expect(env).To(HaveKeyWithValue("RESTIC_FROM_PASSWORD", "primary-credentials/RESTIC_PASSWORD"))
+		})
+	})
+
+	Context("replicated snapshot helper functions", func() {
+		now := time.Now()
+		sourceSnapshots := []restic.Snapshot{
+			{ID: "aaaaaaaaaaaa", ShortID: "aaaaaaaa", Time: now.Add(-2 * time.Hour), Hostname: "default-app", Tags: []string{"daily"}},
+			{ID: "bbbbbbbbbbbb", ShortID: "bbbbbbbb", Time: now.Add(-time.Hour), Hostname: "default-app", Tags: []string{"daily"}},
+			{ID: "cccccccccccc", ShortID: "cccccccc", Time: now, Hostname: "default-app", Tags: []string{"daily"}},
+			{ID: "dddddddddddd", ShortID: "dddddddd", Time: now, Hostname: "default-db", Tags: []string{"weekly"}},
+		}
+		destinationSnapshots := []restic.Snapshot{
+			{ID: "111111111111", Original: "aaaaaaaaaaaa"},
+			{ID: "222222222222", Original: "bbbbbbbbbbbb"},
+		}
+
+		It("should match copies by their original snapshot, newest first", func() {
+			replicated, pending := replicatedSnapshots(&backupv1alpha1.ResticReplication{}, sourceSnapshots, destinationSnapshots)
+			Expect(replicated).To(Equal([]string{"bbbbbbbb", "aaaaaaaa"}))
+			Expect(pending).To(Equal(2))
+		})
+
+		It("should only count the snapshots selected by the filters", func() {
+			replication := &backupv1alpha1.ResticReplication{
+				Spec: backupv1alpha1.ResticReplicationSpec{Hosts: []string{"default-app"}},
+			}
+			_, pending := replicatedSnapshots(replication, sourceSnapshots, destinationSnapshots)
+			Expect(pending).To(Equal(1))
+
+			replication.Spec = backupv1alpha1.ResticReplicationSpec{Tags: []string{"monthly", "weekly"}}
+			replicated, pending := replicatedSnapshots(replication, sourceSnapshots, destinationSnapshots)
+			Expect(replicated).To(BeEmpty())
+			Expect(pending).To(Equal(1))
+		})
+	})
+
+	Context("status helper functions", func() {
+		var (
+			reconciler  *ResticReplicationReconciler
+			replication *backupv1alpha1.ResticReplication
+		)
+
+		BeforeEach(func() {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			replication = &backupv1alpha1.ResticReplication{
+				ObjectMeta: metav1.ObjectMeta{Name: "offsite", Namespace: "default"},
+			}
+			job := finishedJob("resticreplication-offsite-1", false, time.Now())
+			job.Namespace = "default"
+			job.Labels = map[string]string{resticReplicationLabel: "offsite"}
+
+			var secrets []client.Object
+			for _, name := range []string{"primary-credentials", "offsite-credentials"} {
+				secrets = append(secrets, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+				})
+			}
+
+			reconciler = &ResticReplicationReconciler{
+				Client: fake.NewClientBuilder().WithScheme(testScheme).
+					WithObjects(replication, &job).
+					WithObjects(secrets...).
+					WithStatusSubresource(&backupv1alpha1.ResticReplication{}).
+					Build(),
+				Scheme:   testScheme,
+				Recorder: record.NewFakeRecorder(10),
+				Executor: &repositorySnapshotsExecutor{snapshots: map[string][]restic.Snapshot{
+					source.Spec.RepositoryURL: {{ID: "aaaaaaaaaaaa", ShortID: "aaaaaaaa"}},
+				}},
+			}
+		})
+
+		It("should report a failed replication job once", func() {
+			finished, err := reconciler.updateLastReplication(context.Background(), replication)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(finished).To(BeTrue())
+			Expect(replication.Status.LastReplicationResult).To(Equal("Failed"))
+			Expect(replication.Status.LastReplicationJob).To(Equal("resticreplication-offsite-1"))
+			Expect(conditions.IsConditionTrue(replication.Status.Conditions, backupv1alpha1.ConditionReplicated)).To(BeFalse())
+
+			finished, err = reconciler.updateLastReplication(context.Background(), replication)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(finished).To(BeFalse())
+		})
+
+		It("should report the snapshots missing from the destination", func() {
+			Expect(reconciler.updateReplicatedSnapshots(context.Background(), replication, source, destination)).To(Succeed())
+			Expect(replication.Status.ReplicatedSnapshots).To(BeEmpty())
+			Expect(replication.Status.PendingSnapshotCount).To(Equal(int32(1)))
+			condition := conditions.GetCondition(replication.Status.Conditions, backupv1alpha1.ConditionReplicated)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal("SnapshotsPending"))
+		})
+	})
+
+	It("should compute the next replication of schedule descriptors", func() {
+		replication := &backupv1alpha1.ResticReplication{Spec: backupv1alpha1.ResticReplicationSpec{Schedule: "@daily"}}
+		next := (&ResticReplicationReconciler{}).calculateNextReplication(replication)
+		Expect(next).NotTo(BeNil())
+		Expect(next.Time).To(BeTemporally("~", time.Now(), 24*time.Hour))
+	})
+
+	It("should map replication Jobs to their ResticReplication", func() {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "resticreplication-offsite-1",
+				Namespace: "default",
+				Labels:    map[string]string{resticReplicationLabel: "offsite"},
+			},
+		}
+		Expect(replicationForJob(context.Background(), job)).To(ConsistOf(HaveField("NamespacedName", types.NamespacedName{Name: "offsite", Namespace: "default"})))
+	})
+})
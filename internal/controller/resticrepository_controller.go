@@ -20,18 +20,24 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/robfig/cron/v3"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
 	"github.com/madic-creates/restic-backup-operator/internal/conditions"
@@ -41,10 +47,20 @@ import (
 const (
 	defaultRequeueInterval = 1 * time.Hour
 	errorRequeueInterval   = 30 * time.Second
+	// defaultCredentialsCheckInterval is the default interval of the repository credentials probe
+	defaultCredentialsCheckInterval = 5 * time.Minute
 	// DefaultStaleLockThreshold defines the default duration after which a lock is considered stale
 	DefaultStaleLockThreshold = 30 * time.Minute
 )
 
+// Repository operations recorded in status.lastFailure.
+const (
+	repositoryOperationInit   = "Init"
+	repositoryOperationUnlock = "Unlock"
+	repositoryOperationCheck  = "Check"
+	repositoryOperationProbe  = "Probe"
+)
+
 // lockAgeRegex matches the lock age in restic error messages like "(12h36m32.091009819s ago)"
 var lockAgeRegex = regexp.MustCompile(`\((\d+h)?(\d+m)?[\d.]+s ago\)`)
 
@@ -58,11 +74,31 @@ type ResticRepositoryReconciler struct {
 	// StaleLockThreshold defines how old a lock must be to be considered stale.
 	// If not set, DefaultStaleLockThreshold is used.
 	StaleLockThreshold time.Duration
+	// MaxConcurrentReconciles is the number of ResticRepositories reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+	// APIReader reads cache cleanup pods directly from the API server to avoid caching all pods.
+	// Falls back to Client if not set.
+	APIReader client.Reader
+	// Images resolves the default restic image, e.g. to a private mirror.
+	Images *ImageConfig
+	// StatsCollector gathers repository statistics in the background.
+	// If nil, statistics are gathered during the reconcile.
+	StatsCollector *StatsCollector
+	// JobDefaults sets the default active deadline and backoff limit of the jobs.
+	JobDefaults *JobDefaults
+	// AnnotatedPVCRepository is the repository of annotated PVCs without repository
+	// annotation. Annotated PVCs block the deletion of their repository if set, it is
+	// empty while the AnnotatedPVCBackups feature gate is disabled.
+	AnnotatedPVCRepository string
 }
 
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrepositories,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrepositories/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop.
@@ -75,17 +111,31 @@ func (r *ResticRepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	if err := r.Get(ctx, req.NamespacedName, repository); err != nil {
 		if apierrors.IsNotFound(err) {
 			log.Info("ResticRepository resource not found, ignoring")
+			deleteRepositoryMetrics(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		log.Error(err, "Failed to get ResticRepository")
 		return ctrl.Result{}, err
 	}
 
+	// Handle deletion
+	if !repository.DeletionTimestamp.IsZero() {
+		return r.handleDeletion(ctx, repository)
+	}
+
+	// Add finalizer if missing
+	if !controllerutil.ContainsFinalizer(repository, resticRepositoryFinalizer) {
+		controllerutil.AddFinalizer(repository, resticRepositoryFinalizer)
+		if err := r.Update(ctx, repository); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Get credentials from secret
-	creds, err := r.getCredentials(ctx, repository)
+	creds, err := repositoryCredentials(ctx, r.Client, repository)
 	if err != nil {
 		log.Error(err, "Failed to get credentials")
-		r.setCondition(repository, conditions.NotReadyCondition("CredentialsNotFound", err.Error()))
+		r.setCredentialsInvalid(repository, "CredentialsNotFound", err.Error())
 		r.Recorder.Event(repository, corev1.EventTypeWarning, "CredentialsNotFound", err.Error())
 		if err := r.Status().Update(ctx, repository); err != nil {
 			return ctrl.Result{}, err
@@ -99,70 +149,140 @@ func (r *ResticRepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		executor = restic.NewExecutor(log)
 	}
 
-	// Check if repository exists and is accessible
-	checkResult, err := executor.Check(ctx, creds)
-	if err != nil {
-		errStr := err.Error()
-
-		// Check if repository is locked
-		if strings.Contains(errStr, "repository is already locked") {
-			// Only remove locks that are stale (older than threshold)
-			lockAge := parseLockAge(errStr)
-			threshold := r.getStaleLockThreshold()
-			if lockAge >= threshold {
-				log.Info("Repository has stale lock, attempting to remove", "lockAge", lockAge, "threshold", threshold)
-				if unlockErr := executor.Unlock(ctx, creds); unlockErr != nil {
-					log.Error(unlockErr, "Failed to unlock repository")
-					r.setCondition(repository, conditions.NotReadyCondition("UnlockFailed", unlockErr.Error()))
-					r.Recorder.Event(repository, corev1.EventTypeWarning, "UnlockFailed", unlockErr.Error())
+	// Probe the repository with its credentials once per credentials check interval.
+	// Reading the config is cheap, so bad credentials are detected quickly while the
+	// expensive integrity check runs on its own schedule. Repositories the operator
+	// can't access itself are probed by a Job.
+	var config *restic.RepositoryConfig
+	probed := true
+	if probedByJob(repository) {
+		var result *ctrl.Result
+		config, result, err = r.reconcileProbeJob(ctx, repository)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if result != nil {
+			return *result, nil
+		}
+		probed = config != nil
+	} else if !credentialsProbeDue(repository, time.Now()) {
+		probed = false
+	} else {
+		config, err = executor.CatConfig(ctx, creds)
+		if err != nil && expectsIntermittentBackend(repository) && backendUnreachable(err) {
+			return r.handleUnreachableBackend(ctx, repository, err)
+		}
+		if err != nil {
+			errStr := err.Error()
+
+			// Check if repository is locked
+			if strings.Contains(errStr, "repository is already locked") {
+				// Only remove locks that are stale (older than threshold)
+				lockAge := parseLockAge(errStr)
+				threshold := r.getStaleLockThreshold()
+				if lockAge >= threshold {
+					log.Info("Repository has stale lock, attempting to remove", "lockAge", lockAge, "threshold", threshold)
+					if unlockErr := executor.Unlock(ctx, creds); unlockErr != nil {
+						log.Error(unlockErr, "Failed to unlock repository")
+						r.setCondition(repository, conditions.NotReadyCondition("UnlockFailed", unlockErr.Error()))
+						recordRepositoryFailure(repository, repositoryOperationUnlock, unlockErr)
+						r.Recorder.Event(repository, corev1.EventTypeWarning, "UnlockFailed", unlockErr.Error())
+						if updateErr := r.Status().Update(ctx, repository); updateErr != nil {
+							return ctrl.Result{}, updateErr
+						}
+						return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+					}
+					r.Recorder.Event(repository, corev1.EventTypeNormal, "RepositoryUnlocked", fmt.Sprintf("Stale lock (age: %s) was removed from repository", lockAge))
+					log.Info("Repository unlocked successfully, retrying probe")
+
+					// Retry probe after unlock
+					config, err = executor.CatConfig(ctx, creds)
+				} else {
+					// Lock is fresh - another operation might be in progress
+					log.Info("Repository is locked by active operation, will retry later", "lockAge", lockAge, "threshold", threshold)
+					r.setCondition(repository, conditions.NotReadyCondition("RepositoryLocked", fmt.Sprintf("Repository is locked by another operation (lock age: %s, threshold: %s)", lockAge, threshold)))
+					r.Recorder.Event(repository, corev1.EventTypeWarning, "RepositoryLocked", fmt.Sprintf("Repository is locked by another operation, lock age: %s (threshold: %s)", lockAge, threshold))
 					if updateErr := r.Status().Update(ctx, repository); updateErr != nil {
 						return ctrl.Result{}, updateErr
 					}
 					return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
 				}
-				r.Recorder.Event(repository, corev1.EventTypeNormal, "RepositoryUnlocked", fmt.Sprintf("Stale lock (age: %s) was removed from repository", lockAge))
-				log.Info("Repository unlocked successfully, retrying check")
+			}
 
-				// Retry check after unlock
-				checkResult, err = executor.Check(ctx, creds)
-				if err == nil && checkResult != nil && checkResult.Success {
-					log.Info("Repository check passed after unlock")
-				}
-			} else {
-				// Lock is fresh - another operation might be in progress
-				log.Info("Repository is locked by active operation, will retry later", "lockAge", lockAge, "threshold", threshold)
-				r.setCondition(repository, conditions.NotReadyCondition("RepositoryLocked", fmt.Sprintf("Repository is locked by another operation (lock age: %s, threshold: %s)", lockAge, threshold)))
-				r.Recorder.Event(repository, corev1.EventTypeWarning, "RepositoryLocked", fmt.Sprintf("Repository is locked by another operation, lock age: %s (threshold: %s)", lockAge, threshold))
+			// A repository whose identity is known was initialized before. Initializing it
+			// again would create another repository, e.g. in a swapped bucket.
+			if err != nil && expectedRepositoryID(repository) != "" {
+				log.Error(err, "Failed to read repository config")
+				message := fmt.Sprintf("Failed to read the config of repository %s, not initializing a new repository: %v",
+					expectedRepositoryID(repository), err)
+				r.setCredentialsInvalid(repository, "ProbeFailed", message)
+				recordRepositoryFailure(repository, repositoryOperationProbe, err)
+				r.Recorder.Event(repository, corev1.EventTypeWarning, "ProbeFailed", message)
 				if updateErr := r.Status().Update(ctx, repository); updateErr != nil {
 					return ctrl.Result{}, updateErr
 				}
 				return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
 			}
-		}
 
-		// If still failing (not a lock issue, or lock removal didn't help), try to initialize
-		if err != nil {
-			log.Info("Repository check failed, attempting initialization", "error", err.Error())
-			if initErr := executor.Init(ctx, creds); initErr != nil {
-				log.Error(initErr, "Failed to initialize repository")
-				r.setCondition(repository, conditions.NotReadyCondition("InitializationFailed", initErr.Error()))
-				r.Recorder.Event(repository, corev1.EventTypeWarning, "InitializationFailed", initErr.Error())
-				if updateErr := r.Status().Update(ctx, repository); updateErr != nil {
-					return ctrl.Result{}, updateErr
+			// If still failing (not a lock issue, or lock removal didn't help), try to initialize
+			if err != nil {
+				log.Info("Repository probe failed, attempting initialization", "error", err.Error())
+				if initErr := executor.Init(ctx, creds); initErr != nil {
+					log.Error(initErr, "Failed to initialize repository")
+					r.setCredentialsInvalid(repository, "InitializationFailed", initErr.Error())
+					recordRepositoryFailure(repository, repositoryOperationInit, initErr, err)
+					r.Recorder.Event(repository, corev1.EventTypeWarning, "InitializationFailed", initErr.Error())
+					if updateErr := r.Status().Update(ctx, repository); updateErr != nil {
+						return ctrl.Result{}, updateErr
+					}
+					return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+				}
+				r.Recorder.Event(repository, corev1.EventTypeNormal, "RepositoryInitialized", "Repository was successfully initialized")
+				log.Info("Repository initialized successfully")
+
+				// Record the identity of the new repository
+				if config, err = executor.CatConfig(ctx, creds); err != nil {
+					log.Error(err, "Failed to read the config of the initialized repository")
 				}
-				return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
 			}
-			r.Recorder.Event(repository, corev1.EventTypeNormal, "RepositoryInitialized", "Repository was successfully initialized")
-			log.Info("Repository initialized successfully")
+		} else {
+			log.Info("Repository probe passed")
 		}
-	} else if checkResult != nil && checkResult.Success {
-		log.Info("Repository check passed")
+	}
+
+	// Refuse a repository with another ID than the one used before
+	if config != nil && r.confirmRepositoryIdentity(repository, config.ID) {
+		log.Info("Backend serves another repository", "repositoryID", config.ID, "expected", expectedRepositoryID(repository))
+		if err := r.Status().Update(ctx, repository); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+	}
+
+	// Provision the cache PVC and clean up stale cache data
+	if err := r.reconcileCache(ctx, repository); err != nil {
+		log.Error(err, "Failed to reconcile cache")
+		r.Recorder.Event(repository, corev1.EventTypeWarning, "CacheFailed", err.Error())
 	}
 
 	// Repository is accessible - set Ready condition immediately
 	// This ensures the repository is marked as ready even if stats retrieval is slow
 	r.setCondition(repository, conditions.ReadyCondition("RepositoryAccessible", "Repository is initialized and accessible"))
+	r.setCondition(repository, metav1.Condition{
+		Type:    backupv1alpha1.ConditionCredentialsValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  "RepositoryAccessible",
+		Message: "Repository config was read with the credentials",
+	})
+	if probed {
+		repository.Status.LastCredentialsCheck = &metav1.Time{Time: time.Now()}
+	}
 	repository.Status.ObservedGeneration = repository.Generation
+	if repository.Spec.SnapshotListing == nil {
+		repository.Status.Snapshots = nil
+	}
+	r.setReachable(repository)
+	clearRepositoryFailure(repository, repositoryOperationInit, repositoryOperationUnlock, repositoryOperationProbe)
 
 	if err := r.Status().Update(ctx, repository); err != nil {
 		log.Error(err, "Failed to update status")
@@ -171,15 +291,28 @@ func (r *ResticRepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Req
 
 	// Get repository statistics (non-blocking for Ready status)
 	// Stats can be slow for large repositories, so we run it after marking Ready
-	stats, err := executor.Stats(ctx, creds, restic.StatsOptions{Mode: "restore-size"})
-	if err != nil {
-		log.Error(err, "Failed to get repository stats")
-		// Don't fail the reconciliation just because stats failed
-	} else {
-		repository.Status.Statistics = &backupv1alpha1.RepositoryStatistics{
-			TotalSize:      formatBytes(stats.TotalSize),
-			TotalFileCount: int64(stats.TotalFileCount),
-			SnapshotCount:  int32(stats.SnapshotCount),
+	// and refresh them less often than the credentials are probed
+	// Gathered by the stats collector if configured, so slow stats don't block other repositories
+	// With the Job check strategy, they are gathered by a stats Job instead
+	switch {
+	case repository.Spec.CheckStrategy == backupv1alpha1.CheckStrategyJob:
+	case !statisticsDue(repository):
+	case r.StatsCollector != nil:
+		r.StatsCollector.Enqueue(req.NamespacedName)
+	default:
+		stats, err := executor.Stats(ctx, creds, restic.StatsOptions{Mode: "restore-size"})
+		if err != nil {
+			log.Error(err, "Failed to get repository stats")
+			// Don't fail the reconciliation just because stats failed
+			break
+		}
+		setRepositoryStatistics(repository, stats, time.Now())
+		if snapshots, report, err := snapshotStatus(ctx, r.Client, executor, creds, repository); err != nil {
+			log.Error(err, "Failed to list repository snapshots")
+		} else {
+			recordRetentionReportMetrics(req.NamespacedName, report)
+			repository.Status.Snapshots = snapshots
+			repository.Status.RetentionReport = report
 		}
 		// Update status with statistics
 		if err := r.Status().Update(ctx, repository); err != nil {
@@ -188,47 +321,192 @@ func (r *ResticRepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		}
 	}
 
+	// Run the scheduled integrity check if it is due
+	requeueAfter := credentialsCheckInterval(repository)
+	if last := repository.Status.LastCredentialsCheck; !probed && last != nil {
+		requeueAfter = max(requeueAfter-time.Since(last.Time), time.Second)
+	}
+	if next := nextStatistics(repository); next != nil && time.Until(*next) < requeueAfter {
+		requeueAfter = max(time.Until(*next), errorRequeueInterval)
+	}
+	if repository.Spec.CheckStrategy == backupv1alpha1.CheckStrategyJob {
+		if err := r.reconcileStatsJob(ctx, repository); err != nil {
+			log.Error(err, "Failed to gather repository statistics")
+		}
+	}
+	// restic check reads the whole repository, it always runs in a Job
+	next, err := r.reconcileCheckJob(ctx, repository)
+	if err != nil {
+		log.Error(err, "Failed to run integrity check")
+	} else if next != nil && time.Until(*next) < requeueAfter {
+		requeueAfter = max(time.Until(*next), errorRequeueInterval)
+	}
+
 	r.Recorder.Event(repository, corev1.EventTypeNormal, "ReconcileSuccess", "Repository reconciled successfully")
 
-	return ctrl.Result{RequeueAfter: defaultRequeueInterval}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
-func (r *ResticRepositoryReconciler) getCredentials(ctx context.Context, repository *backupv1alpha1.ResticRepository) (restic.Credentials, error) {
-	secret := &corev1.Secret{}
-	secretName := types.NamespacedName{
-		Name:      repository.Spec.CredentialsSecretRef.Name,
-		Namespace: repository.Namespace,
+// credentialsProbeDue reports whether the credentials of a repository must be probed:
+// once per credentials check interval, after a spec change and while it isn't Ready.
+func credentialsProbeDue(repository *backupv1alpha1.ResticRepository, now time.Time) bool {
+	last := repository.Status.LastCredentialsCheck
+	return last == nil || now.Sub(last.Time) >= credentialsCheckInterval(repository) ||
+		repository.Status.ObservedGeneration != repository.Generation ||
+		!conditions.IsConditionTrue(repository.Status.Conditions, backupv1alpha1.ConditionReady)
+}
+
+// credentialsCheckInterval returns the interval of the credentials probe.
+func credentialsCheckInterval(repository *backupv1alpha1.ResticRepository) time.Duration {
+	if expectsIntermittentBackend(repository) {
+		return connectivityProbeInterval(repository)
+	}
+	if interval := repository.Spec.CredentialsCheckInterval; interval != nil && interval.Duration > 0 {
+		return interval.Duration
 	}
+	return defaultCredentialsCheckInterval
+}
 
-	if err := r.Get(ctx, secretName, secret); err != nil {
-		return restic.Credentials{}, fmt.Errorf("failed to get credentials secret: %w", err)
+// statisticsInterval returns the interval of the repository statistics, zero if they
+// are disabled.
+func statisticsInterval(repository *backupv1alpha1.ResticRepository) time.Duration {
+	if interval := repository.Spec.StatsInterval; interval != nil {
+		return max(interval.Duration, 0)
 	}
+	return defaultRequeueInterval
+}
 
-	password, ok := secret.Data["RESTIC_PASSWORD"]
-	if !ok {
-		return restic.Credentials{}, fmt.Errorf("RESTIC_PASSWORD not found in secret")
+// statisticsUpdatedAt returns the time the repository statistics were last gathered.
+func statisticsUpdatedAt(repository *backupv1alpha1.ResticRepository) *time.Time {
+	if updatedAt := repository.Status.StatisticsUpdatedAt; updatedAt != nil {
+		return &updatedAt.Time
 	}
+	// Statistics recorded before status.statisticsUpdatedAt was introduced
+	if stats := repository.Status.Statistics; stats != nil && stats.LastUpdated != nil {
+		return &stats.LastUpdated.Time
+	}
+	return nil
+}
 
-	creds := restic.Credentials{
-		Repository: repository.Spec.RepositoryURL,
-		Password:   string(password),
+// nextStatistics returns the time the repository statistics are due, nil if they are disabled.
+func nextStatistics(repository *backupv1alpha1.ResticRepository) *time.Time {
+	interval := statisticsInterval(repository)
+	if interval == 0 {
+		return nil
+	}
+	next := time.Now()
+	if updatedAt := statisticsUpdatedAt(repository); updatedAt != nil {
+		next = updatedAt.Add(interval)
 	}
+	return &next
+}
 
-	// Optional AWS credentials
-	if awsKeyID, ok := secret.Data["AWS_ACCESS_KEY_ID"]; ok {
-		creds.AWSAccessKeyID = string(awsKeyID)
+// statisticsDue reports whether the repository statistics should be refreshed.
+func statisticsDue(repository *backupv1alpha1.ResticRepository) bool {
+	next := nextStatistics(repository)
+	return next != nil && !time.Now().Before(*next)
+}
+
+// integrityCheckSchedule parses the schedule of the integrity check of the repository.
+// It returns nil if scheduled integrity checks are disabled.
+func integrityCheckSchedule(repository *backupv1alpha1.ResticRepository) (cron.Schedule, error) {
+	check := repository.Spec.IntegrityCheck
+	if check == nil || !check.Enabled || check.Schedule == "" {
+		return nil, nil
+	}
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	schedule, err := parser.Parse(check.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid integrity check schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// setIntegrityCheckResult records the result of an integrity check started at the given
+// time, which read the data subset. A nil checkErr means the check passed; the reason of
+// failed checks tells damaged data apart from other failures.
+func (r *ResticRepositoryReconciler) setIntegrityCheckResult(repository *backupv1alpha1.ResticRepository, at time.Time, subset int32, reason string, checkErr error) {
+	repository.Status.LastIntegrityCheck = &metav1.Time{Time: at}
+	repository.Status.LastIntegrityCheckSubset = subset
+	if checkErr != nil {
+		repository.Status.LastIntegrityCheckResult = "Failed"
+		conditions.SetCondition(&repository.Status.Conditions, metav1.Condition{
+			Type:    backupv1alpha1.ConditionIntegrityCheckSucceeded,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: checkErr.Error(),
+		})
+		recordRepositoryFailure(repository, repositoryOperationCheck, checkErr)
+		r.Recorder.Event(repository, corev1.EventTypeWarning, "RepositoryUnhealthy", fmt.Sprintf("Repository integrity check failed: %s", checkErr))
+		return
+	}
+	repository.Status.LastIntegrityCheckResult = "Passed"
+	conditions.SetCondition(&repository.Status.Conditions, metav1.Condition{
+		Type:    backupv1alpha1.ConditionIntegrityCheckSucceeded,
+		Status:  metav1.ConditionTrue,
+		Reason:  "CheckPassed",
+		Message: "Repository integrity check passed",
+	})
+	clearRepositoryFailure(repository, repositoryOperationCheck)
+	r.Recorder.Event(repository, corev1.EventTypeNormal, "IntegrityCheckPassed", "Repository integrity check passed")
+}
+
+// setRepositoryStatistics records the statistics of the repository gathered at the given time.
+func setRepositoryStatistics(repository *backupv1alpha1.ResticRepository, stats *restic.RepoStats, at time.Time) {
+	recordRepositoryMetrics(client.ObjectKeyFromObject(repository), stats, at)
+	repository.Status.StatisticsUpdatedAt = &metav1.Time{Time: at}
+	repository.Status.Statistics = &backupv1alpha1.RepositoryStatistics{
+		TotalSize:      formatBytes(stats.TotalSize),
+		TotalFileCount: int64(stats.TotalFileCount),
+		SnapshotCount:  int32(stats.SnapshotCount),
+		LastUpdated:    &metav1.Time{Time: at},
+	}
+}
+
+// nextDataSubset returns the data subset (1..N) to read in the next integrity check,
+// or 0 if data verification is disabled.
+func nextDataSubset(check *backupv1alpha1.IntegrityCheckConfig, lastSubset int32) int32 {
+	if check.ReadDataSubsets == nil || *check.ReadDataSubsets < 1 {
+		return 0
 	}
-	if awsSecret, ok := secret.Data["AWS_SECRET_ACCESS_KEY"]; ok {
-		creds.AWSSecretAccessKey = string(awsSecret)
+	return lastSubset%*check.ReadDataSubsets + 1
+}
+
+// recordRepositoryFailure stores the restic error output of a failed operation in the
+// status. The output of the first error carrying any is used.
+func recordRepositoryFailure(repository *backupv1alpha1.ResticRepository, operation string, errs ...error) {
+	failure := &backupv1alpha1.RepositoryFailure{Operation: operation, Time: metav1.Now()}
+	for _, err := range errs {
+		if failure.Stderr = restic.StderrExcerpt(err, restic.DefaultStderrLines); failure.Stderr != "" {
+			break
+		}
 	}
+	repository.Status.LastFailure = failure
+}
 
-	return creds, nil
+// clearRepositoryFailure removes the last failure if one of the operations failed.
+func clearRepositoryFailure(repository *backupv1alpha1.ResticRepository, operations ...string) {
+	if failure := repository.Status.LastFailure; failure != nil && slices.Contains(operations, failure.Operation) {
+		repository.Status.LastFailure = nil
+	}
 }
 
 func (r *ResticRepositoryReconciler) setCondition(repository *backupv1alpha1.ResticRepository, condition metav1.Condition) {
 	conditions.SetCondition(&repository.Status.Conditions, condition)
 }
 
+// setCredentialsInvalid marks the repository as not ready because it cannot be
+// accessed with its credentials.
+func (r *ResticRepositoryReconciler) setCredentialsInvalid(repository *backupv1alpha1.ResticRepository, reason, message string) {
+	r.setCondition(repository, conditions.NotReadyCondition(reason, message))
+	r.setCondition(repository, metav1.Condition{
+		Type:    backupv1alpha1.ConditionCredentialsValid,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
 // getStaleLockThreshold returns the configured stale lock threshold or the default.
 func (r *ResticRepositoryReconciler) getStaleLockThreshold() time.Duration {
 	if r.StaleLockThreshold > 0 {
@@ -240,7 +518,11 @@ func (r *ResticRepositoryReconciler) getStaleLockThreshold() time.Duration {
 // SetupWithManager sets up the controller with the Manager.
 func (r *ResticRepositoryReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&backupv1alpha1.ResticRepository{}).
+		// Status updates, e.g. of the credentials probe, must not trigger another reconcile
+		For(&backupv1alpha1.ResticRepository{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Owns(&batchv1.Job{}).
 		Complete(r)
 }
 
@@ -17,15 +17,26 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
 )
 
 var _ = Describe("ResticRepository Controller", func() {
@@ -34,7 +45,7 @@ var _ = Describe("ResticRepository Controller", func() {
 		interval = time.Millisecond * 250
 	)
 
-	Context("When creating a ResticRepository", func() {
+	Context("When creating a ResticRepository", Label(envtestLabel), func() {
 		var (
 			testNamespace string
 			repositoryKey types.NamespacedName
@@ -229,6 +240,283 @@ lock was created at 2025-12-26 21:32:34 (12h36m32.091009819s ago)`
 			Expect(parseLockAge("")).To(Equal(time.Duration(0)))
 		})
 	})
+
+	Context("nextDataSubset helper function", func() {
+		It("should disable data verification without subsets", func() {
+			check := &backupv1alpha1.IntegrityCheckConfig{Enabled: true}
+			Expect(nextDataSubset(check, 0)).To(Equal(int32(0)))
+		})
+
+		It("should rotate through all subsets", func() {
+			subsets := int32(3)
+			check := &backupv1alpha1.IntegrityCheckConfig{Enabled: true, ReadDataSubsets: &subsets}
+			Expect(nextDataSubset(check, 0)).To(Equal(int32(1)))
+			Expect(nextDataSubset(check, 1)).To(Equal(int32(2)))
+			Expect(nextDataSubset(check, 2)).To(Equal(int32(3)))
+			Expect(nextDataSubset(check, 3)).To(Equal(int32(1)))
+		})
+
+		It("should stay in range after the number of subsets was reduced", func() {
+			subsets := int32(2)
+			check := &backupv1alpha1.IntegrityCheckConfig{Enabled: true, ReadDataSubsets: &subsets}
+			Expect(nextDataSubset(check, 7)).To(BeNumerically("<=", 2))
+		})
+	})
+
+	Context("reconcileCheckJob", func() {
+		It("should skip the check until the schedule is due", func() {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			reconciler := &ResticRepositoryReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme).Build()}
+			repository := &backupv1alpha1.ResticRepository{
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					IntegrityCheck: &backupv1alpha1.IntegrityCheckConfig{
+						Enabled:  true,
+						Schedule: "@weekly",
+					},
+				},
+				Status: backupv1alpha1.ResticRepositoryStatus{
+					LastIntegrityCheck: &metav1.Time{Time: time.Now()},
+				},
+			}
+
+			next, err := reconciler.reconcileCheckJob(context.Background(), repository)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(next).NotTo(BeNil())
+			Expect(*next).To(BeTemporally(">", time.Now()))
+			jobs := &batchv1.JobList{}
+			Expect(reconciler.List(context.Background(), jobs)).To(Succeed())
+			Expect(jobs.Items).To(BeEmpty())
+		})
+	})
+})
+
+// probeExecutor is a MockExecutor with a failing credentials probe that counts probes,
+// integrity checks and statistics runs.
+type probeExecutor struct {
+	MockExecutor
+	probeErr     error
+	initErr      error
+	repositoryID string
+	probes       int
+	checks       int
+	stats        int
+}
+
+func (e *probeExecutor) CatConfig(_ context.Context, _ restic.Credentials) (*restic.RepositoryConfig, error) {
+	e.probes++
+	if e.probeErr != nil {
+		return nil, e.probeErr
+	}
+	return &restic.RepositoryConfig{Version: 2, ID: e.repositoryID}, nil
+}
+
+func (e *probeExecutor) Init(_ context.Context, _ restic.Credentials) error {
+	return e.initErr
+}
+
+func (e *probeExecutor) Check(_ context.Context, _ restic.Credentials, _ restic.CheckOptions) (*restic.CheckResult, error) {
+	e.checks++
+	return &restic.CheckResult{Success: true}, nil
+}
+
+func (e *probeExecutor) Stats(ctx context.Context, creds restic.Credentials, opts restic.StatsOptions) (*restic.RepoStats, error) {
+	e.stats++
+	return e.MockExecutor.Stats(ctx, creds, opts)
+}
+
+var _ = Describe("ResticRepository credentials probe", func() {
+	var repository *backupv1alpha1.ResticRepository
+
+	BeforeEach(func() {
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "backup-system"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+	})
+
+	reconcileWith := func(executor restic.Executor) (ctrl.Result, *backupv1alpha1.ResticRepository) {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-credentials", Namespace: "backup-system"},
+			Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+		}
+		c := fake.NewClientBuilder().WithScheme(testScheme).
+			WithObjects(repository, secret).
+			WithStatusSubresource(&backupv1alpha1.ResticRepository{}).
+			Build()
+		reconciler := &ResticRepositoryReconciler{
+			Client:   c,
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+			Executor: executor,
+		}
+
+		result, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "repo", Namespace: "backup-system"}})
+		Expect(err).NotTo(HaveOccurred())
+		updated := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(context.Background(), types.NamespacedName{Name: "repo", Namespace: "backup-system"}, updated)).To(Succeed())
+		return result, updated
+	}
+
+	It("should mark the credentials valid without running an integrity check", func() {
+		executor := &probeExecutor{}
+		result, updated := reconcileWith(executor)
+
+		Expect(executor.checks).To(BeZero())
+		Expect(apimeta.IsStatusConditionTrue(updated.Status.Conditions, backupv1alpha1.ConditionCredentialsValid)).To(BeTrue())
+		Expect(apimeta.FindStatusCondition(updated.Status.Conditions, backupv1alpha1.ConditionIntegrityCheckSucceeded)).To(BeNil())
+		Expect(updated.Status.LastCredentialsCheck).NotTo(BeNil())
+		Expect(updated.Status.Statistics.LastUpdated).NotTo(BeNil())
+		Expect(updated.Status.StatisticsUpdatedAt).NotTo(BeNil())
+		Expect(result.RequeueAfter).To(Equal(defaultCredentialsCheckInterval))
+	})
+
+	It("should not probe the credentials again before the interval passed", func() {
+		repository.Generation = 1
+		repository.Status.ObservedGeneration = 1
+		repository.Status.LastCredentialsCheck = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+		repository.Status.Conditions = []metav1.Condition{{
+			Type: backupv1alpha1.ConditionReady, Status: metav1.ConditionTrue, Reason: "RepositoryAccessible",
+		}}
+		executor := &probeExecutor{}
+		result, updated := reconcileWith(executor)
+
+		Expect(executor.probes).To(BeZero())
+		Expect(updated.Status.LastCredentialsCheck.Time).To(BeTemporally("~", time.Now().Add(-time.Minute), time.Second))
+		Expect(result.RequeueAfter).To(BeNumerically("~", defaultCredentialsCheckInterval-time.Minute, time.Second))
+
+		repository.Status.LastCredentialsCheck = &metav1.Time{Time: time.Now().Add(-defaultCredentialsCheckInterval)}
+		_, updated = reconcileWith(executor)
+		Expect(executor.probes).To(Equal(1))
+		Expect(updated.Status.LastCredentialsCheck.Time).To(BeTemporally("~", time.Now(), time.Second))
+	})
+
+	It("should requeue after the configured credentials check interval", func() {
+		repository.Spec.CredentialsCheckInterval = &metav1.Duration{Duration: time.Minute}
+		result, _ := reconcileWith(&probeExecutor{})
+		Expect(result.RequeueAfter).To(Equal(time.Minute))
+	})
+
+	It("should mark the credentials invalid when the repository cannot be accessed", func() {
+		executor := &probeExecutor{
+			probeErr: errors.New("wrong password or no key found"),
+			initErr:  errors.New("failed to initialize repository: access denied"),
+		}
+		result, updated := reconcileWith(executor)
+
+		Expect(result.RequeueAfter).To(Equal(errorRequeueInterval))
+		condition := apimeta.FindStatusCondition(updated.Status.Conditions, backupv1alpha1.ConditionCredentialsValid)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("InitializationFailed"))
+		Expect(apimeta.IsStatusConditionFalse(updated.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+	})
+
+	It("should record the restic error output of a failed initialization", func() {
+		executor := &probeExecutor{
+			probeErr: &restic.CommandError{Err: errors.New("exit status 1"), Stderr: "Fatal: unable to open config file: Stat: Access Denied."},
+			initErr:  fmt.Errorf("failed to initialize repository: %w", &restic.CommandError{Err: errors.New("exit status 1")}),
+		}
+		_, updated := reconcileWith(executor)
+
+		Expect(updated.Status.LastFailure).NotTo(BeNil())
+		Expect(updated.Status.LastFailure.Operation).To(Equal("Init"))
+		Expect(updated.Status.LastFailure.Stderr).To(Equal("Fatal: unable to open config file: Stat: Access Denied."))
+	})
+
+	It("should clear the last failure once the repository is accessible", func() {
+		repository.Status.LastFailure = &backupv1alpha1.RepositoryFailure{Operation: "Init", Time: metav1.Now()}
+		_, updated := reconcileWith(&probeExecutor{})
+		Expect(updated.Status.LastFailure).To(BeNil())
+	})
+
+	It("should not refresh recent statistics", func() {
+		Expect(statisticsDue(repository)).To(BeTrue())
+		repository.Status.Statistics = &backupv1alpha1.RepositoryStatistics{LastUpdated: &metav1.Time{Time: time.Now()}}
+		Expect(statisticsDue(repository)).To(BeFalse())
+		repository.Status.Statistics.LastUpdated = &metav1.Time{Time: time.Now().Add(-2 * defaultRequeueInterval)}
+		Expect(statisticsDue(repository)).To(BeTrue())
+	})
+
+	It("should refresh the statistics at the configured interval", func() {
+		repository.Spec.StatsInterval = &metav1.Duration{Duration: 24 * time.Hour}
+		repository.Status.StatisticsUpdatedAt = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+		Expect(statisticsDue(repository)).To(BeFalse())
+		repository.Status.StatisticsUpdatedAt = &metav1.Time{Time: time.Now().Add(-25 * time.Hour)}
+		Expect(statisticsDue(repository)).To(BeTrue())
+	})
+
+	It("should requeue when the statistics are due before the next credentials probe", func() {
+		repository.Spec.CredentialsCheckInterval = &metav1.Duration{Duration: time.Hour}
+		repository.Spec.StatsInterval = &metav1.Duration{Duration: 10 * time.Minute}
+		result, _ := reconcileWith(&probeExecutor{})
+		Expect(result.RequeueAfter).To(BeNumerically("~", 10*time.Minute, time.Second))
+	})
+
+	It("should not gather statistics when they are disabled", func() {
+		repository.Spec.StatsInterval = &metav1.Duration{}
+		executor := &probeExecutor{}
+		result, updated := reconcileWith(executor)
+
+		Expect(executor.stats).To(BeZero())
+		Expect(updated.Status.Statistics).To(BeNil())
+		Expect(updated.Status.StatisticsUpdatedAt).To(BeNil())
+		Expect(result.RequeueAfter).To(Equal(defaultCredentialsCheckInterval))
+	})
+
+	It("should record the repository ID at the first probe", func() {
+		_, updated := reconcileWith(&probeExecutor{repositoryID: testRepositoryID})
+
+		Expect(updated.Status.RepositoryID).To(Equal(testRepositoryID))
+		Expect(apimeta.IsStatusConditionFalse(updated.Status.Conditions, backupv1alpha1.ConditionRepositoryIdentityChanged)).To(BeTrue())
+		Expect(apimeta.IsStatusConditionTrue(updated.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+	})
+
+	It("should refuse a backend serving another repository", func() {
+		repository.Status.RepositoryID = testRepositoryID
+		result, updated := reconcileWith(&probeExecutor{repositoryID: otherRepositoryID})
+
+		Expect(result.RequeueAfter).To(Equal(errorRequeueInterval))
+		Expect(updated.Status.RepositoryID).To(Equal(testRepositoryID))
+		condition := apimeta.FindStatusCondition(updated.Status.Conditions, backupv1alpha1.ConditionRepositoryIdentityChanged)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(ContainSubstring(otherRepositoryID))
+		Expect(apimeta.IsStatusConditionFalse(updated.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+	})
+
+	It("should accept a replaced repository pinned in the spec", func() {
+		repository.Status.RepositoryID = testRepositoryID
+		repository.Spec.RepositoryID = otherRepositoryID
+		_, updated := reconcileWith(&probeExecutor{repositoryID: otherRepositoryID})
+
+		Expect(updated.Status.RepositoryID).To(Equal(otherRepositoryID))
+		Expect(apimeta.IsStatusConditionFalse(updated.Status.Conditions, backupv1alpha1.ConditionRepositoryIdentityChanged)).To(BeTrue())
+		Expect(apimeta.IsStatusConditionTrue(updated.Status.Conditions, backupv1alpha1.ConditionReady)).To(BeTrue())
+	})
+
+	It("should not initialize a repository whose identity is known", func() {
+		repository.Status.RepositoryID = testRepositoryID
+		executor := &probeExecutor{
+			probeErr: errors.New("unable to open config file: Stat: The specified key does not exist."),
+			initErr:  errors.New("initialization must not be attempted"),
+		}
+		result, updated := reconcileWith(executor)
+
+		Expect(result.RequeueAfter).To(Equal(errorRequeueInterval))
+		condition := apimeta.FindStatusCondition(updated.Status.Conditions, backupv1alpha1.ConditionCredentialsValid)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal("ProbeFailed"))
+		Expect(updated.Status.LastFailure).NotTo(BeNil())
+		Expect(updated.Status.LastFailure.Operation).To(Equal(repositoryOperationProbe))
+	})
 })
 
 // randString generates a random string of lowercase letters
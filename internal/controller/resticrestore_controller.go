@@ -19,26 +19,39 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
 	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+	"github.com/madic-creates/restic-backup-operator/internal/notifications"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
 )
 
 const (
 	resticRestoreFinalizer = "backup.resticbackup.io/resticrestore-finalizer"
+	// resticRestoreLabel links restore Jobs and created PVCs to the ResticRestore
+	resticRestoreLabel = "backup.resticbackup.io/restore"
+	// restoreQueueRequeueInterval defines how often queued restores re-check for a free slot
+	restoreQueueRequeueInterval = 30 * time.Second
 )
 
 // ResticRestoreReconciler reconciles a ResticRestore object
@@ -46,13 +59,55 @@ type ResticRestoreReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	// MaxConcurrentRestores limits the number of restores running cluster-wide.
+	// Additional restores are queued. Zero means unlimited.
+	MaxConcurrentRestores int
+	// MaxConcurrentRestoresPerNamespace limits the number of restores running
+	// in a single namespace. Additional restores are queued. Zero means unlimited.
+	MaxConcurrentRestoresPerNamespace int
+	// StartupAudit, if set, delays reconciles until existing child objects are corrected.
+	StartupAudit *StartupAudit
+	// MaxConcurrentReconciles is the number of ResticRestores reconciled in parallel. Defaults to 1.
+	MaxConcurrentReconciles int
+	// Images resolves the default restic image, e.g. to a private mirror.
+	Images *ImageConfig
+	// JobDefaults sets the default active deadline and backoff limit of the jobs.
+	JobDefaults *JobDefaults
+	// Executor lists the repository snapshots to resolve snapshot selectors.
+	// If nil, a default executor will be created.
+	Executor restic.Executor
+	// SnapshotCache, if set, serves the snapshots to resolve snapshot selectors.
+	SnapshotCache *SnapshotCache
+	// APIReader reads the pods of restore jobs, which are not cached, and counts the
+	// active restores. Defaults to Client.
+	APIReader client.Reader
+	// PodLogs reads the progress of running restore jobs for the target PVC annotation
+	// and the end of the logs of failed restore jobs. If nil, the target PVC is annotated
+	// without progress and failures are reported without logs.
+	PodLogs PodLogReader
+	// FeatureGates enables optional capabilities. If nil, the feature defaults apply.
+	FeatureGates *features.Gate
+	// Notifications reports finished restores and failed restore drills. If nil, no
+	// notifications are sent.
+	Notifications *notifications.Manager
+
+	// restoreSlots serializes admitting restores while a concurrency limit applies.
+	restoreSlots sync.Mutex
+	// admitted holds the restores admitted by running reconciles that haven't recorded
+	// their job yet.
+	admitted map[types.UID]bool
 }
 
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrestores,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrestores/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrestores/finalizers,verbs=update
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop.
@@ -60,11 +115,17 @@ func (r *ResticRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	log := log.FromContext(ctx)
 	log.Info("Reconciling ResticRestore")
 
+	// Wait for the startup audit to correct existing child objects
+	if err := r.StartupAudit.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Fetch the ResticRestore instance
 	restore := &backupv1alpha1.ResticRestore{}
 	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
 		if apierrors.IsNotFound(err) {
 			log.Info("ResticRestore resource not found, ignoring")
+			deleteRestoreMetrics(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		log.Error(err, "Failed to get ResticRestore")
@@ -76,6 +137,9 @@ func (r *ResticRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return r.handleDeletion(ctx, restore)
 	}
 
+	// Export the phase the restore ends up in after this reconciliation
+	defer recordRestorePhase(restore)
+
 	// Add finalizer if missing
 	if !controllerutil.ContainsFinalizer(restore, resticRestoreFinalizer) {
 		controllerutil.AddFinalizer(restore, resticRestoreFinalizer)
@@ -84,6 +148,11 @@ func (r *ResticRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		}
 	}
 
+	// Scheduled restores run as restore drills
+	if isRestoreDrill(restore) {
+		return r.reconcileDrill(ctx, restore)
+	}
+
 	// Initialize phase if not set
 	if restore.Status.Phase == "" {
 		restore.Status.Phase = backupv1alpha1.RestorePhasePending
@@ -94,12 +163,21 @@ func (r *ResticRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	// Handle restore based on phase
 	switch restore.Status.Phase {
-	case backupv1alpha1.RestorePhasePending:
+	case backupv1alpha1.RestorePhasePending, backupv1alpha1.RestorePhaseQueued:
 		return r.handlePending(ctx, restore)
 	case backupv1alpha1.RestorePhaseInProgress:
 		return r.handleInProgress(ctx, restore)
 	case backupv1alpha1.RestorePhaseCompleted, backupv1alpha1.RestorePhaseFailed:
-		// Nothing to do for completed/failed restores
+		// Scale the workload stopped for the restore up again, also after failures
+		stopped := restore.Status.StoppedWorkload != nil
+		if err := r.startRestoreWorkload(ctx, restore); err != nil {
+			return ctrl.Result{}, err
+		}
+		if stopped {
+			if err := r.Status().Update(ctx, restore); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 		return ctrl.Result{}, nil
 	}
 
@@ -111,6 +189,13 @@ func (r *ResticRestoreReconciler) handleDeletion(ctx context.Context, restore *b
 
 	if controllerutil.ContainsFinalizer(restore, resticRestoreFinalizer) {
 		log.Info("Performing finalizer cleanup for ResticRestore")
+		deleteRestoreMetrics(client.ObjectKeyFromObject(restore))
+		if err := r.clearDeletedRestoreTarget(ctx, restore); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.startRestoreWorkload(ctx, restore); err != nil {
+			return ctrl.Result{}, err
+		}
 
 		controllerutil.RemoveFinalizer(restore, resticRestoreFinalizer)
 		if err := r.Update(ctx, restore); err != nil {
@@ -124,12 +209,34 @@ func (r *ResticRestoreReconciler) handleDeletion(ctx context.Context, restore *b
 func (r *ResticRestoreReconciler) handlePending(ctx context.Context, restore *backupv1alpha1.ResticRestore) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
+	// Snapshot IDs are passed to restic, also by the operator itself for dump targets
+	if err := validateSnapshotIDs(&restore.Spec); err != nil {
+		r.setCondition(restore, conditions.NotReadyCondition("InvalidSnapshotID", err.Error()))
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "InvalidSnapshotID", err.Error())
+		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+		if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := validateSidecars(restore.Spec.JobConfig); err != nil {
+		r.setCondition(restore, conditions.NotReadyCondition("InvalidSidecars", err.Error()))
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "InvalidSidecars", err.Error())
+		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+		if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// Get the backup reference to find repository
 	backup, err := r.getBackup(ctx, restore)
+	setReferenceDenied(&restore.Status.Conditions, err)
 	if err != nil {
 		log.Error(err, "Failed to get backup")
-		r.setCondition(restore, conditions.NotReadyCondition("BackupNotFound", err.Error()))
-		r.Recorder.Event(restore, corev1.EventTypeWarning, "BackupNotFound", err.Error())
+		reason := referenceErrorReason(err, "BackupNotFound")
+		r.setCondition(restore, conditions.NotReadyCondition(reason, err.Error()))
+		r.Recorder.Event(restore, corev1.EventTypeWarning, reason, err.Error())
 		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
 		if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
 			return ctrl.Result{}, updateErr
@@ -139,9 +246,10 @@ func (r *ResticRestoreReconciler) handlePending(ctx context.Context, restore *ba
 
 	// Get the repository
 	repository, err := r.getRepository(ctx, backup)
+	setReferenceDenied(&restore.Status.Conditions, err)
 	if err != nil {
 		log.Error(err, "Failed to get repository")
-		r.setCondition(restore, conditions.NotReadyCondition("RepositoryNotFound", err.Error()))
+		r.setCondition(restore, conditions.NotReadyCondition(referenceErrorReason(err, "RepositoryNotFound"), err.Error()))
 		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
 		if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
 			return ctrl.Result{}, updateErr
@@ -149,54 +257,219 @@ func (r *ResticRestoreReconciler) handlePending(ctx context.Context, restore *ba
 		return ctrl.Result{}, nil
 	}
 
-	// Determine snapshot ID
+	// Never restore from another repository than the one backed up into
+	if repositoryIdentityChanged(repository) {
+		msg := fmt.Sprintf("Repository %s serves another restic repository than the one used before", repository.Name)
+		r.setCondition(restore, conditions.NotReadyCondition("RepositoryIdentityChanged", msg))
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RepositoryIdentityChanged", msg)
+		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+		if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// A job name recorded by an earlier reconcile means the restore already left the
+	// queue and resolved its snapshot, and its job may exist
+	recorded := restore.Status.JobRef != nil
+
+	// Queue the restore if the concurrency limits are reached
+	throttleMsg := ""
+	if !recorded {
+		var release func()
+		throttleMsg, release, err = r.admitRestore(ctx, restore)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		defer release()
+	}
+	if throttleMsg != "" {
+		if restore.Status.Phase != backupv1alpha1.RestorePhaseQueued {
+			log.Info("Restore queued", "reason", throttleMsg)
+			r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreQueued", throttleMsg)
+		}
+		restore.Status.Phase = backupv1alpha1.RestorePhaseQueued
+		r.setCondition(restore, conditions.UnknownCondition("RestoreQueued", throttleMsg))
+		if err := r.Status().Update(ctx, restore); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: restoreQueueRequeueInterval}, nil
+	}
+
+	// Determine snapshot ID, or the snapshots of a restore chain. This runs before the
+	// target PVC is created and the workload is stopped, so a restore failing to resolve
+	// its snapshot leaves neither an orphaned PVC nor a stopped workload behind.
 	snapshotID := restore.Spec.SnapshotID
-	if snapshotID == "" && restore.Spec.SnapshotSelector != nil {
-		// For now, just use "latest" - full implementation would query restic
-		snapshotID = "latest"
+	var chain []string
+	if recorded {
+		snapshotID = restore.Status.RestoredSnapshot
+		chain = restore.Status.RestoredSnapshots
+	} else if restore.Spec.Chain != nil {
+		snapshots, err := r.resolveRestoreChain(ctx, repository, restore.Spec.Chain)
+		if err != nil {
+			log.Error(err, "Failed to resolve restore chain")
+			r.setCondition(restore, conditions.NotReadyCondition("SnapshotSelectionFailed", err.Error()))
+			if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+		}
+		if len(snapshots) == 0 {
+			msg := "no snapshot matches the chain selector"
+			r.setCondition(restore, conditions.NotReadyCondition("NoMatchingSnapshot", msg))
+			r.Recorder.Event(restore, corev1.EventTypeWarning, "NoMatchingSnapshot", msg)
+			restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+			if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{}, nil
+		}
+		log.Info("Resolved restore chain", "snapshots", len(snapshots))
+		last := snapshots[len(snapshots)-1]
+		chain = snapshotIDs(snapshots)
+		snapshotID = last.ID
+		if !last.Time.IsZero() {
+			restore.Status.RestoredSnapshotTime = &metav1.Time{Time: last.Time}
+		}
+	} else if snapshotID == "" && restore.Spec.SnapshotSelector != nil {
+		snapshot, err := r.resolveSnapshotSelector(ctx, repository, restore.Spec.SnapshotSelector)
+		if err != nil {
+			log.Error(err, "Failed to resolve snapshot selector")
+			r.setCondition(restore, conditions.NotReadyCondition("SnapshotSelectionFailed", err.Error()))
+			if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+		}
+		if snapshot == nil {
+			msg := "no snapshot matches the snapshot selector"
+			r.setCondition(restore, conditions.NotReadyCondition("NoMatchingSnapshot", msg))
+			r.Recorder.Event(restore, corev1.EventTypeWarning, "NoMatchingSnapshot", msg)
+			restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+			if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{}, nil
+		}
+		log.Info("Resolved snapshot selector", "snapshot", snapshot.ShortID, "time", snapshot.Time)
+		snapshotID = snapshot.ID
+		restore.Status.RestoredSnapshotTime = &metav1.Time{Time: snapshot.Time}
 	}
 	if snapshotID == "" {
 		snapshotID = "latest"
 	}
 
-	// Create restore job
-	job := r.buildRestoreJob(restore, backup, repository, snapshotID)
+	// Resolve the pod volume of a file restore
+	var targetPod *corev1.Pod
+	var targetClaim string
+	if restore.Spec.Target.Pod != nil {
+		targetPod, targetClaim, err = r.resolvePodTarget(ctx, restore)
+		if err != nil {
+			log.Error(err, "Failed to resolve target pod")
+			r.setCondition(restore, conditions.NotReadyCondition("TargetPodUnavailable", err.Error()))
+			r.Recorder.Event(restore, corev1.EventTypeWarning, "TargetPodUnavailable", err.Error())
+			restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+			if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{}, nil
+		}
+	}
 
-	// Set owner reference
-	if err := controllerutil.SetControllerReference(restore, job, r.Scheme); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to set owner reference: %w", err)
+	// Create the target PVC of a newPVC target
+	if err := r.ensureNewPVC(ctx, restore, backup); err != nil {
+		log.Error(err, "Failed to create target PVC")
+		r.setCondition(restore, conditions.NotReadyCondition("PVCCreationFailed", err.Error()))
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "PVCCreationFailed", err.Error())
+		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+		if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
 	}
 
-	// Create the job
-	if err := r.Create(ctx, job); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create restore job")
-			r.setCondition(restore, conditions.NotReadyCondition("JobCreationFailed", err.Error()))
+	// Stop the workload using the target PVC before the restore job mounts it
+	if restore.Spec.StopWorkload != nil {
+		message, err := r.stopRestoreWorkload(ctx, restore)
+		if err != nil {
+			log.Error(err, "Failed to stop workload")
+			r.setCondition(restore, conditions.NotReadyCondition("StopWorkloadFailed", err.Error()))
+			r.Recorder.Event(restore, corev1.EventTypeWarning, "StopWorkloadFailed", err.Error())
 			restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
 			if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
 				return ctrl.Result{}, updateErr
 			}
 			return ctrl.Result{}, nil
 		}
+		if message != "" {
+			r.setCondition(restore, conditions.UnknownCondition("StoppingWorkload", message))
+			if err := r.Status().Update(ctx, restore); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+	}
+
+	// Dump targets are written by the operator without a restore job
+	if restore.Spec.Target.Dump != nil {
+		return r.handleDump(ctx, restore, repository, snapshotID)
+	}
+
+	// Create restore job
+	if len(chain) == 0 {
+		chain = []string{snapshotID}
+	}
+	job := r.buildRestoreJob(restore, backup, repository, chain...)
+	if targetPod != nil {
+		applyPodTarget(job, targetPod, targetClaim, restore.Spec.Target.Pod.SubPath)
+	}
+
+	// Set owner reference
+	if err := controllerutil.SetControllerReference(restore, job, r.Scheme); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	// Record the expected job and snapshot before creating the job, so a reconcile
+	// retried after a status conflict adopts the job instead of resolving another snapshot
+	if !recorded {
+		restore.Status.RestoredSnapshot = snapshotID
+		if restore.Spec.Chain != nil {
+			restore.Status.RestoredSnapshots = chain
+		}
+		restore.Status.JobRef = &backupv1alpha1.ObjectReference{
+			Name:      job.Name,
+			Namespace: job.Namespace,
+		}
+		if err := r.Status().Update(ctx, restore); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Create the job
+	created, err := createOrAdopt(ctx, r.Client, restore, job, &batchv1.Job{})
+	if err != nil {
+		log.Error(err, "Failed to create restore job")
+		r.setCondition(restore, conditions.NotReadyCondition("JobCreationFailed", err.Error()))
+		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+		if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+	if created {
+		r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreStarted", fmt.Sprintf("Restore job %s created", job.Name))
 	}
 
 	// Update status
 	now := metav1.NewTime(time.Now())
 	restore.Status.Phase = backupv1alpha1.RestorePhaseInProgress
 	restore.Status.StartTime = &now
-	restore.Status.RestoredSnapshot = snapshotID
-	restore.Status.JobRef = &backupv1alpha1.ObjectReference{
-		Name:      job.Name,
-		Namespace: job.Namespace,
-	}
 	r.setCondition(restore, conditions.NewCondition("Ready", metav1.ConditionUnknown, "RestoreInProgress", "Restore job is running"))
 
 	if err := r.Status().Update(ctx, restore); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreStarted", fmt.Sprintf("Restore job %s created", job.Name))
-
 	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 }
 
@@ -230,13 +503,28 @@ func (r *ResticRestoreReconciler) handleInProgress(ctx context.Context, restore
 		return ctrl.Result{}, err
 	}
 
+	// Remove the progress from the target PVC once the job finished
+	if job.Status.Succeeded > 0 || job.Status.Failed > 0 {
+		if err := r.clearRestoreTarget(ctx, restore, job); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Check job status
 	if job.Status.Succeeded > 0 {
 		now := metav1.NewTime(time.Now())
 		restore.Status.Phase = backupv1alpha1.RestorePhaseCompleted
 		restore.Status.CompletionTime = &now
+		if restore.Spec.Assertions != nil {
+			if _, err := r.recordAssertionResults(ctx, restore, job); err != nil {
+				log.Error(err, "Failed to read restore assertion results")
+			}
+		}
 		r.setCondition(restore, conditions.ReadyCondition("RestoreCompleted", "Restore completed successfully"))
 		r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreCompleted", "Restore completed successfully")
+		if err := r.notifyRestoreRun(ctx, restore, job, true, ""); err != nil {
+			return ctrl.Result{}, err
+		}
 		if err := r.Status().Update(ctx, restore); err != nil {
 			return ctrl.Result{}, err
 		}
@@ -247,18 +535,314 @@ func (r *ResticRestoreReconciler) handleInProgress(ctx context.Context, restore
 		now := metav1.NewTime(time.Now())
 		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
 		restore.Status.CompletionTime = &now
-		r.setCondition(restore, conditions.NotReadyCondition("RestoreFailed", "Restore job failed"))
-		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", "Restore job failed")
+		reason, message := "RestoreFailed", "Restore job failed"
+		if restore.Spec.Assertions != nil {
+			failed, err := r.recordAssertionResults(ctx, restore, job)
+			if err != nil {
+				// The pod may already be gone, report the failure without details
+				log.Error(err, "Failed to read restore assertion results")
+			}
+			if len(failed) > 0 {
+				reason, message = "AssertionsFailed", fmt.Sprintf("Restore assertions failed: %s", strings.Join(failed, ", "))
+			}
+		}
+		reader := r.APIReader
+		if reader == nil {
+			reader = r.Client
+		}
+		logs, err := jobFailureLogs(ctx, reader, r.PodLogs, job)
+		if err != nil {
+			log.Error(err, "Failed to read restore job logs")
+		}
+		restore.Status.FailureMessage = logs
+		r.setCondition(restore, conditions.NotReadyCondition(reason, message))
+		r.Recorder.Event(restore, corev1.EventTypeWarning, reason, withJobLogs(message, logs))
+		if err := r.notifyRestoreRun(ctx, restore, job, false, withJobLogs(message, logs)); err != nil {
+			return ctrl.Result{}, err
+		}
 		if err := r.Status().Update(ctx, restore); err != nil {
 			return ctrl.Result{}, err
 		}
 		return ctrl.Result{}, nil
 	}
 
-	// Job still running
+	// Job still running, show its progress on the target PVC
+	if err := r.annotateRestoreTarget(ctx, restore, job); err != nil {
+		log.Error(err, "Failed to annotate target PVC with the restore progress")
+	}
 	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 }
 
+// ensureNewPVC creates the PVC of a newPVC target. The PVC is labeled with the restore,
+// so a PVC created by an earlier reconcile is reused, while an existing PVC of anyone
+// else is never restored into. With the Delete reclaim policy the PVC is owned by the
+// restore and garbage collected with it.
+func (r *ResticRestoreReconciler) ensureNewPVC(ctx context.Context, restore *backupv1alpha1.ResticRestore, backup *backupv1alpha1.ResticBackup) error {
+	target := restore.Spec.Target.NewPVC
+	if target == nil {
+		return nil
+	}
+
+	existing := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: restore.Namespace}, existing)
+	if err == nil {
+		if existing.Labels[resticRestoreLabel] != restore.Name {
+			return fmt.Errorf("PVC %s already exists and was not created by this restore, use a pvc target to restore into it", target.Name)
+		}
+		restore.Status.CreatedPVC = existing.Name
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get PVC %s: %w", target.Name, err)
+	}
+
+	var source *corev1.PersistentVolumeClaim
+	if target.InheritFromSource {
+		if source, err = r.getSourcePVC(ctx, backup); err != nil {
+			return err
+		}
+	}
+	var recorded *backupv1alpha1.SourcePVCStatus
+	if target.RestoreMetadata {
+		if recorded = backup.Status.SourcePVC; recorded == nil {
+			return fmt.Errorf("backup %s has not recorded a source PVC to restore the metadata of", backup.Name)
+		}
+	}
+	pvc, err := buildNewPVC(restore, recorded, source)
+	if err != nil {
+		return err
+	}
+	if target.ReclaimPolicy == backupv1alpha1.PVCReclaimDelete {
+		if err := controllerutil.SetControllerReference(restore, pvc, r.Scheme); err != nil {
+			return fmt.Errorf("failed to set owner reference: %w", err)
+		}
+	}
+	if err := r.Create(ctx, pvc); err != nil {
+		return fmt.Errorf("failed to create PVC %s: %w", pvc.Name, err)
+	}
+
+	restore.Status.CreatedPVC = pvc.Name
+	r.Recorder.Event(restore, corev1.EventTypeNormal, "PVCCreated", fmt.Sprintf("Created PVC %s", pvc.Name))
+	return nil
+}
+
+// getSourcePVC returns the source PVC of a backup.
+func (r *ResticRestoreReconciler) getSourcePVC(ctx context.Context, backup *backupv1alpha1.ResticBackup) (*corev1.PersistentVolumeClaim, error) {
+	if backup.Spec.Source.PVC == nil {
+		return nil, fmt.Errorf("backup %s has no source PVC to inherit from", backup.Name)
+	}
+	source := &corev1.PersistentVolumeClaim{}
+	key := types.NamespacedName{Name: backup.Spec.Source.PVC.ClaimName, Namespace: backup.Namespace}
+	if err := r.Get(ctx, key, source); err != nil {
+		return nil, fmt.Errorf("failed to get source PVC %s: %w", key.Name, err)
+	}
+	return source, nil
+}
+
+// buildNewPVC builds the PVC of a newPVC target. The settings of the source PVC, if
+// given, are the defaults of the target fields, taking precedence over the settings
+// recorded by the backup. The recorded labels and annotations are copied. Access modes
+// default to ReadWriteOnce. Block volumes are refused, restic restores files into a
+// filesystem.
+func buildNewPVC(restore *backupv1alpha1.ResticRestore, recorded *backupv1alpha1.SourcePVCStatus, source *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
+	target := restore.Spec.Target.NewPVC
+	spec := corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+	}
+	labels := map[string]string{}
+	var annotations map[string]string
+	if recorded != nil {
+		if recorded.StorageClassName != "" {
+			spec.StorageClassName = &recorded.StorageClassName
+		}
+		if len(recorded.AccessModes) > 0 {
+			spec.AccessModes = make([]corev1.PersistentVolumeAccessMode, 0, len(recorded.AccessModes))
+			for _, mode := range recorded.AccessModes {
+				spec.AccessModes = append(spec.AccessModes, corev1.PersistentVolumeAccessMode(mode))
+			}
+		}
+		if recorded.Capacity != "" {
+			quantity, err := resource.ParseQuantity(recorded.Capacity)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recorded capacity %q of PVC %s: %w", recorded.Capacity, recorded.ClaimName, err)
+			}
+			spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: quantity}
+		}
+		for key, value := range recorded.Labels {
+			labels[key] = value
+		}
+		if len(recorded.Annotations) > 0 {
+			annotations = make(map[string]string, len(recorded.Annotations))
+			for key, value := range recorded.Annotations {
+				annotations[key] = value
+			}
+		}
+	}
+	if source != nil {
+		if source.Spec.VolumeMode != nil && *source.Spec.VolumeMode == corev1.PersistentVolumeBlock && target.VolumeMode == "" {
+			return nil, fmt.Errorf("source PVC %s is a Block volume, set volumeMode Filesystem to restore its files", source.Name)
+		}
+		spec.StorageClassName = source.Spec.StorageClassName
+		if len(source.Spec.AccessModes) > 0 {
+			spec.AccessModes = source.Spec.AccessModes
+		}
+		spec.VolumeMode = source.Spec.VolumeMode
+		if size, ok := source.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: size}
+		}
+	}
+
+	if target.Size != "" {
+		quantity, err := resource.ParseQuantity(target.Size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PVC size %q: %w", target.Size, err)
+		}
+		spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: quantity}
+	}
+	if spec.Resources.Requests == nil {
+		return nil, fmt.Errorf("the size of PVC %s is required", target.Name)
+	}
+
+	if len(target.AccessModes) > 0 {
+		spec.AccessModes = make([]corev1.PersistentVolumeAccessMode, 0, len(target.AccessModes))
+		for _, mode := range target.AccessModes {
+			spec.AccessModes = append(spec.AccessModes, corev1.PersistentVolumeAccessMode(mode))
+		}
+	}
+	if target.StorageClassName != "" {
+		spec.StorageClassName = &target.StorageClassName
+	}
+	if target.VolumeMode != "" {
+		volumeMode := corev1.PersistentVolumeMode(target.VolumeMode)
+		spec.VolumeMode = &volumeMode
+	}
+	if spec.VolumeMode != nil && *spec.VolumeMode == corev1.PersistentVolumeBlock {
+		return nil, fmt.Errorf("PVC %s can't be a Block volume, restic restores files into a filesystem", target.Name)
+	}
+
+	// The labels of the operator override recorded ones, the restore finds its PVC by them
+	labels["app.kubernetes.io/managed-by"] = "restic-backup-operator"
+	labels[resticRestoreLabel] = restore.Name
+
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        target.Name,
+			Namespace:   restore.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: spec,
+	}, nil
+}
+
+// admitRestore returns a non-empty message if the restore must wait for a free restore
+// slot. Otherwise the restore holds its slot until the returned release function is
+// called, by then its job is recorded in the status and counted.
+func (r *ResticRestoreReconciler) admitRestore(ctx context.Context, restore *backupv1alpha1.ResticRestore) (string, func(), error) {
+	release := func() {}
+	if r.MaxConcurrentRestores <= 0 && r.MaxConcurrentRestoresPerNamespace <= 0 {
+		return "", release, nil
+	}
+
+	// Parallel reconciles must not admit restores for the same free slot
+	r.restoreSlots.Lock()
+	defer r.restoreSlots.Unlock()
+	msg, err := r.getThrottleMessage(ctx, restore)
+	if err != nil || msg != "" {
+		return msg, release, err
+	}
+	if r.admitted == nil {
+		r.admitted = map[types.UID]bool{}
+	}
+	r.admitted[restore.UID] = true
+	return "", func() {
+		r.restoreSlots.Lock()
+		defer r.restoreSlots.Unlock()
+		delete(r.admitted, restore.UID)
+	}, nil
+}
+
+// getThrottleMessage returns a non-empty message if the restore must wait because the
+// cluster-wide or per-namespace concurrency limit is reached. Restores are read with the
+// API reader, so restores admitted by a previous reconcile are counted. Queued restores
+// are started in creation order: restores queued earlier take a free slot first, unless
+// they wait for the limit of their namespace.
+func (r *ResticRestoreReconciler) getThrottleMessage(ctx context.Context, restore *backupv1alpha1.ResticRestore) (string, error) {
+	if r.MaxConcurrentRestores <= 0 && r.MaxConcurrentRestoresPerNamespace <= 0 {
+		return "", nil
+	}
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	restores := &backupv1alpha1.ResticRestoreList{}
+	if err := reader.List(ctx, restores); err != nil {
+		return "", fmt.Errorf("failed to list restores: %w", err)
+	}
+
+	clusterCount := 0
+	namespaceCounts := map[string]int{}
+	fits := func(namespace string) bool {
+		return (r.MaxConcurrentRestores <= 0 || clusterCount < r.MaxConcurrentRestores) &&
+			(r.MaxConcurrentRestoresPerNamespace <= 0 || namespaceCounts[namespace] < r.MaxConcurrentRestoresPerNamespace)
+	}
+
+	var queued []*backupv1alpha1.ResticRestore
+	for i := range restores.Items {
+		other := &restores.Items[i]
+		if other.UID == restore.UID {
+			continue
+		}
+		switch {
+		case restoreActive(other) || r.admitted[other.UID]:
+			clusterCount++
+			namespaceCounts[other.Namespace]++
+		case other.Status.Phase == backupv1alpha1.RestorePhaseQueued && queuedBefore(other, restore):
+			queued = append(queued, other)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool { return queuedBefore(queued[i], queued[j]) })
+	for _, other := range queued {
+		if fits(other.Namespace) {
+			clusterCount++
+			namespaceCounts[other.Namespace]++
+		}
+	}
+
+	if r.MaxConcurrentRestores > 0 && clusterCount >= r.MaxConcurrentRestores {
+		return fmt.Sprintf("Waiting for a free restore slot (%d/%d restores active or queued cluster-wide)", clusterCount, r.MaxConcurrentRestores), nil
+	}
+	if namespaceCount := namespaceCounts[restore.Namespace]; r.MaxConcurrentRestoresPerNamespace > 0 && namespaceCount >= r.MaxConcurrentRestoresPerNamespace {
+		return fmt.Sprintf("Waiting for a free restore slot (%d/%d restores active or queued in namespace)", namespaceCount, r.MaxConcurrentRestoresPerNamespace), nil
+	}
+
+	return "", nil
+}
+
+// restoreActive reports whether a restore holds a restore slot: it runs or has recorded
+// the job it is about to create.
+func restoreActive(restore *backupv1alpha1.ResticRestore) bool {
+	switch restore.Status.Phase {
+	case backupv1alpha1.RestorePhaseInProgress:
+		return true
+	case backupv1alpha1.RestorePhasePending, backupv1alpha1.RestorePhaseQueued:
+		return restore.Status.JobRef != nil
+	}
+	return false
+}
+
+// queuedBefore reports whether restore a was created before restore b.
+func queuedBefore(a, b *backupv1alpha1.ResticRestore) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
 func (r *ResticRestoreReconciler) getBackup(ctx context.Context, restore *backupv1alpha1.ResticRestore) (*backupv1alpha1.ResticBackup, error) {
 	backup := &backupv1alpha1.ResticBackup{}
 	ns := restore.Spec.BackupRef.Namespace
@@ -271,6 +855,9 @@ func (r *ResticRestoreReconciler) getBackup(ctx context.Context, restore *backup
 		Namespace: ns,
 	}
 
+	if err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "ResticRestore", restore.Namespace, "ResticBackup", ns, name.Name); err != nil {
+		return nil, err
+	}
 	if err := r.Get(ctx, name, backup); err != nil {
 		return nil, fmt.Errorf("failed to get backup: %w", err)
 	}
@@ -290,6 +877,10 @@ func (r *ResticRestoreReconciler) getRepository(ctx context.Context, backup *bac
 		Namespace: ns,
 	}
 
+	// The repository is accessed on behalf of the backup
+	if err := checkReferenceGrant(ctx, r.Client, r.FeatureGates, "ResticBackup", backup.Namespace, "ResticRepository", ns, name.Name); err != nil {
+		return nil, err
+	}
 	if err := r.Get(ctx, name, repository); err != nil {
 		return nil, fmt.Errorf("failed to get repository: %w", err)
 	}
@@ -297,21 +888,38 @@ func (r *ResticRestoreReconciler) getRepository(ctx context.Context, backup *bac
 	return repository, nil
 }
 
-func (r *ResticRestoreReconciler) buildRestoreJob(restore *backupv1alpha1.ResticRestore, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository, snapshotID string) *batchv1.Job {
-	jobName := fmt.Sprintf("resticrestore-%s", restore.Name)
+// restoreOptionArgs returns the restic restore flags of the restore options.
+func restoreOptionArgs(options *backupv1alpha1.RestoreOptions) []string {
+	if options == nil {
+		return nil
+	}
 
-	// Build restic image
-	resticImage := "ghcr.io/restic/restic:0.18.0"
-	if backup.Spec.Restic != nil && backup.Spec.Restic.Image != "" {
-		resticImage = backup.Spec.Restic.Image
+	var args []string
+	if options.Verify {
+		args = append(args, "--verify")
 	}
+	if options.Sparse {
+		args = append(args, "--sparse")
+	}
+	if mode := options.OverwriteMode; mode != "" {
+		args = append(args, "--overwrite", mode)
+	} else if !options.Overwrite {
+		args = append(args, "--overwrite", "never")
+	}
+	return args
+}
 
-	// Build restore command
+// buildRestoreCommand builds the restic command restoring a snapshot into the target.
+func buildRestoreCommand(restore *backupv1alpha1.ResticRestore, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository, snapshotID string) []string {
 	restoreCmd := []string{
 		"restic", "restore",
 		snapshotID,
-		"--target", "/restore",
+		"--target", restoreTargetPath,
 	}
+	restoreCmd = append(restoreCmd, repositoryOptions(repository)...)
+
+	// Print JSON status lines, read for the progress annotation of the target PVC
+	restoreCmd = append(restoreCmd, "--json")
 
 	// Add include paths
 	for _, path := range restore.Spec.IncludePaths {
@@ -323,54 +931,36 @@ func (r *ResticRestoreReconciler) buildRestoreJob(restore *backupv1alpha1.Restic
 		restoreCmd = append(restoreCmd, "--exclude", path)
 	}
 
-	// Add verify flag
-	if restore.Spec.Options != nil && restore.Spec.Options.Verify {
-		restoreCmd = append(restoreCmd, "--verify")
+	restoreCmd = append(restoreCmd, restoreOptionArgs(restore.Spec.Options)...)
+	if isRestoreDrill(restore) {
+		restoreCmd = append(restoreCmd, drillRestoreArgs(restore, backup)...)
 	}
 
-	// Build environment variables
-	envVars := []corev1.EnvVar{
-		{
-			Name:  "RESTIC_REPOSITORY",
-			Value: repository.Spec.RepositoryURL,
-		},
-		{
-			Name: "RESTIC_PASSWORD",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: repository.Spec.CredentialsSecretRef.Name,
-					},
-					Key: "RESTIC_PASSWORD",
-				},
-			},
-		},
-		{
-			Name: "AWS_ACCESS_KEY_ID",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: repository.Spec.CredentialsSecretRef.Name,
-					},
-					Key:      "AWS_ACCESS_KEY_ID",
-					Optional: boolPtr(true),
-				},
-			},
-		},
-		{
-			Name: "AWS_SECRET_ACCESS_KEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: repository.Spec.CredentialsSecretRef.Name,
-					},
-					Key:      "AWS_SECRET_ACCESS_KEY",
-					Optional: boolPtr(true),
-				},
-			},
-		},
+	return restoreCmd
+}
+
+// buildRestoreJob builds the job restoring the snapshots into the target. The
+// snapshots of a restore chain are restored one after another, in the given order.
+func (r *ResticRestoreReconciler) buildRestoreJob(restore *backupv1alpha1.ResticRestore, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository, snapshotIDs ...string) *batchv1.Job {
+	jobName := fmt.Sprintf("resticrestore-%s", restore.Name)
+
+	// Build restic image
+	var image string
+	if backup.Spec.Restic != nil {
+		image = backup.Spec.Restic.Image
+	}
+	resticImage := r.Images.Resolve(image, restore.Spec.JobConfig)
+
+	// Build restore commands
+	restoreCmds := make([][]string, 0, len(snapshotIDs))
+	for _, snapshotID := range snapshotIDs {
+		restoreCmds = append(restoreCmds, buildRestoreCommand(restore, backup, repository, snapshotID))
 	}
 
+	// Build environment variables
+	envVars := repositoryEnvVars(repository)
+	envVars = append(envVars, corev1.EnvVar{Name: "RESTIC_PROGRESS_FPS", Value: restoreProgressFPS})
+
 	// Determine target PVC
 	var targetPVC string
 	if restore.Spec.Target.PVC != nil {
@@ -379,27 +969,18 @@ func (r *ResticRestoreReconciler) buildRestoreJob(restore *backupv1alpha1.Restic
 		targetPVC = restore.Spec.Target.NewPVC.Name
 	}
 
-	var backoffLimit int32 = 0
-	var activeDeadline int64 = 3600
-
-	if restore.Spec.JobConfig != nil {
-		if restore.Spec.JobConfig.BackoffLimit != nil {
-			backoffLimit = *restore.Spec.JobConfig.BackoffLimit
-		}
-		if restore.Spec.JobConfig.ActiveDeadlineSeconds != nil {
-			activeDeadline = *restore.Spec.JobConfig.ActiveDeadlineSeconds
-		}
-	}
+	backoffLimit := r.JobDefaults.BackoffLimit(JobOperationRestore, restore.Spec.JobConfig)
+	activeDeadline := r.JobDefaults.ActiveDeadlineSeconds(JobOperationRestore, restore.Spec.JobConfig)
 
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      jobName,
 			Namespace: restore.Namespace,
 			Labels: map[string]string{
-				"app.kubernetes.io/name":         "restic-backup-operator",
-				"app.kubernetes.io/component":    "restore",
-				"app.kubernetes.io/managed-by":   "restic-backup-operator",
-				"backup.resticbackup.io/restore": restore.Name,
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  "restore",
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				resticRestoreLabel:             restore.Name,
 			},
 		},
 		Spec: batchv1.JobSpec{
@@ -408,9 +989,9 @@ func (r *ResticRestoreReconciler) buildRestoreJob(restore *backupv1alpha1.Restic
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: map[string]string{
-						"app.kubernetes.io/name":         "restic-backup-operator",
-						"app.kubernetes.io/component":    "restore",
-						"backup.resticbackup.io/restore": restore.Name,
+						"app.kubernetes.io/name":      "restic-backup-operator",
+						"app.kubernetes.io/component": "restore",
+						resticRestoreLabel:            restore.Name,
 					},
 				},
 				Spec: corev1.PodSpec{
@@ -428,7 +1009,7 @@ func (r *ResticRestoreReconciler) buildRestoreJob(restore *backupv1alpha1.Restic
 							Name:            "restic",
 							Image:           resticImage,
 							ImagePullPolicy: corev1.PullIfNotPresent,
-							Command:         restoreCmd,
+							Command:         restoreCmds[0],
 							Env:             envVars,
 							SecurityContext: &corev1.SecurityContext{
 								AllowPrivilegeEscalation: boolPtr(false),
@@ -441,7 +1022,7 @@ func (r *ResticRestoreReconciler) buildRestoreJob(restore *backupv1alpha1.Restic
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "restore-target",
-									MountPath: "/restore",
+									MountPath: restoreTargetPath,
 								},
 							},
 						},
@@ -461,6 +1042,27 @@ func (r *ResticRestoreReconciler) buildRestoreJob(restore *backupv1alpha1.Restic
 		},
 	}
 
+	// Check the identity of the repository, restore the snapshots of a chain in order,
+	// then fix the ownership of the restored files and run the assertions
+	identityCheck := buildIdentityCheckCommands(repository)
+	fix, assertions := restoreFixOwnership(restore), restore.Spec.Assertions
+	if len(identityCheck) > 0 || len(restoreCmds) > 1 || fix != nil || assertions != nil {
+		container := &job.Spec.Template.Spec.Containers[0]
+		container.Command = []string{"/bin/sh", "-c"}
+		container.Args = []string{strings.Join(append(identityCheck, buildRestoreScript(restoreCmds, fix, assertions)), "\n")}
+	}
+	if fix != nil {
+		applyFixOwnershipSecurityContext(&job.Spec.Template.Spec.Containers[0])
+	}
+	if assertions != nil {
+		applyChecksumManifest(&job.Spec.Template.Spec, assertions)
+	}
+
+	// Apply scheduling and networking settings
+	applyRepositoryCredentials(&job.Spec.Template.Spec, repository)
+	applyJobConfiguration(&job.Spec.Template.Spec, restore.Spec.JobConfig)
+	applyPodMetadata(&job.Spec.Template.ObjectMeta, restore.Spec.JobConfig)
+
 	return job
 }
 
@@ -472,6 +1074,8 @@ func (r *ResticRestoreReconciler) setCondition(restore *backupv1alpha1.ResticRes
 func (r *ResticRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&backupv1alpha1.ResticRestore{}).
-		Owns(&batchv1.Job{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Owns(&batchv1.CronJob{}).
+		Watches(&batchv1.Job{}, handler.EnqueueRequestsFromMapFunc(restoreForJob)).
 		Complete(r)
 }
@@ -17,17 +17,25 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
 )
 
 var _ = Describe("ResticRestore Controller", func() {
@@ -36,7 +44,7 @@ var _ = Describe("ResticRestore Controller", func() {
 		interval = time.Millisecond * 250
 	)
 
-	Context("When creating a ResticRestore", func() {
+	Context("When creating a ResticRestore", Label(envtestLabel), func() {
 		var (
 			testNamespace string
 			restoreKey    types.NamespacedName
@@ -574,7 +582,8 @@ var _ = Describe("ResticRestore Controller", func() {
 			job := reconciler.buildRestoreJob(restore, backup, repository, "latest")
 			Expect(job.Name).To(Equal("resticrestore-test-restore"))
 			Expect(job.Namespace).To(Equal("default"))
-			Expect(job.Spec.Template.Spec.Containers[0].Command).To(ContainElements("restic", "restore", "latest", "--target", "/restore"))
+			Expect(job.Spec.Template.Spec.Containers[0].Command).To(ContainElements("restic", "restore", "latest", "--target", "/restore", "--json"))
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "RESTIC_PROGRESS_FPS", Value: restoreProgressFPS}))
 		})
 
 		It("should include include paths in restore command", func() {
@@ -694,5 +703,324 @@ var _ = Describe("ResticRestore Controller", func() {
 			// Check volume source uses new PVC name
 			Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("new-target-pvc"))
 		})
+
+		It("should pass sparse and overwrite options to restic restore", func() {
+			restore := &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-restore",
+					Namespace: "default",
+				},
+				Spec: backupv1alpha1.ResticRestoreSpec{
+					Target: backupv1alpha1.RestoreTarget{
+						PVC: &backupv1alpha1.PVCTarget{
+							ClaimName: "target-pvc",
+						},
+					},
+					Options: &backupv1alpha1.RestoreOptions{
+						Overwrite:     true,
+						OverwriteMode: "if-changed",
+						Sparse:        true,
+					},
+				},
+			}
+			backup := &backupv1alpha1.ResticBackup{}
+			repository := &backupv1alpha1.ResticRepository{
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					CredentialsSecretRef: backupv1alpha1.SecretKeySelector{
+						Name: "test-credentials",
+					},
+				},
+			}
+
+			job := reconciler.buildRestoreJob(restore, backup, repository, "latest")
+			Expect(job.Spec.Template.Spec.Containers[0].Command).To(ContainElements("--sparse", "--overwrite", "if-changed"))
+		})
+	})
+
+	Context("restoreOptionArgs helper function", func() {
+		It("should keep existing files without overwrite", func() {
+			Expect(restoreOptionArgs(&backupv1alpha1.RestoreOptions{})).To(Equal([]string{"--overwrite", "never"}))
+		})
+
+		It("should use the restic default with overwrite enabled", func() {
+			Expect(restoreOptionArgs(&backupv1alpha1.RestoreOptions{Overwrite: true, Verify: true})).To(Equal([]string{"--verify"}))
+			Expect(restoreOptionArgs(nil)).To(BeEmpty())
+		})
+	})
+
+	Context("ensureNewPVC", func() {
+		var (
+			reconciler *ResticRestoreReconciler
+			restore    *backupv1alpha1.ResticRestore
+			backup     *backupv1alpha1.ResticBackup
+		)
+
+		BeforeEach(func() {
+			backup = &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-backup", Namespace: "default"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					Source: backupv1alpha1.BackupSource{PVC: &backupv1alpha1.PVCSource{ClaimName: "data"}},
+				},
+			}
+			restore = &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-restore", Namespace: "default", UID: "restore-uid"},
+				Spec: backupv1alpha1.ResticRestoreSpec{
+					Target: backupv1alpha1.RestoreTarget{
+						NewPVC: &backupv1alpha1.NewPVCTarget{
+							Name:             "restored-data",
+							Size:             "10Gi",
+							StorageClassName: "longhorn",
+							AccessModes:      []string{"ReadWriteMany"},
+						},
+					},
+				},
+			}
+		})
+
+		newReconciler := func(objects ...client.Object) {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			reconciler = &ResticRestoreReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build(),
+				Scheme:   testScheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+		}
+
+		getPVC := func() *corev1.PersistentVolumeClaim {
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "restored-data", Namespace: "default"}, pvc)).To(Succeed())
+			return pvc
+		}
+
+		It("should create the PVC without owner reference by default", func() {
+			newReconciler()
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(Succeed())
+			Expect(restore.Status.CreatedPVC).To(Equal("restored-data"))
+
+			pvc := getPVC()
+			Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("10Gi"))
+			Expect(*pvc.Spec.StorageClassName).To(Equal("longhorn"))
+			Expect(pvc.Spec.AccessModes).To(ConsistOf(corev1.ReadWriteMany))
+			Expect(pvc.OwnerReferences).To(BeEmpty())
+
+			// A later reconcile reuses the PVC
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(Succeed())
+		})
+
+		It("should own the PVC with the Delete reclaim policy", func() {
+			restore.Spec.Target.NewPVC.ReclaimPolicy = backupv1alpha1.PVCReclaimDelete
+			newReconciler()
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(Succeed())
+			Expect(getPVC().OwnerReferences).To(ConsistOf(HaveField("Name", "test-restore")))
+		})
+
+		It("should refuse to restore into an existing PVC", func() {
+			newReconciler(&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "restored-data", Namespace: "default"},
+			})
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(MatchError(ContainSubstring("already exists")))
+		})
+
+		It("should inherit the settings of the source PVC", func() {
+			storageClass := "local-path"
+			filesystem := corev1.PersistentVolumeFilesystem
+			newReconciler(&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &storageClass,
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					VolumeMode:       &filesystem,
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")},
+					},
+				},
+			})
+			restore.Spec.Target.NewPVC.InheritFromSource = true
+			restore.Spec.Target.NewPVC.Size = ""
+			restore.Spec.Target.NewPVC.AccessModes = nil
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(Succeed())
+
+			pvc := getPVC()
+			Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("5Gi"))
+			Expect(*pvc.Spec.StorageClassName).To(Equal("longhorn"))
+			Expect(pvc.Spec.AccessModes).To(ConsistOf(corev1.ReadWriteOnce))
+			Expect(*pvc.Spec.VolumeMode).To(Equal(corev1.PersistentVolumeFilesystem))
+		})
+
+		It("should recreate the source PVC recorded by the backup", func() {
+			backup.Status.SourcePVC = &backupv1alpha1.SourcePVCStatus{
+				ClaimName:        "data",
+				StorageClassName: "local-path",
+				Capacity:         "5Gi",
+				AccessModes:      []string{"ReadWriteOnce"},
+				Labels:           map[string]string{"app": "postgres", resticRestoreLabel: "other"},
+				Annotations:      map[string]string{"example.com/owner": "team-a"},
+			}
+			newReconciler()
+			restore.Spec.Target.NewPVC.RestoreMetadata = true
+			restore.Spec.Target.NewPVC.Size = ""
+			restore.Spec.Target.NewPVC.AccessModes = nil
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(Succeed())
+
+			pvc := getPVC()
+			Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("5Gi"))
+			Expect(*pvc.Spec.StorageClassName).To(Equal("longhorn"))
+			Expect(pvc.Spec.AccessModes).To(ConsistOf(corev1.ReadWriteOnce))
+			Expect(pvc.Labels).To(HaveKeyWithValue("app", "postgres"))
+			Expect(pvc.Labels).To(HaveKeyWithValue(resticRestoreLabel, "test-restore"))
+			Expect(pvc.Annotations).To(HaveKeyWithValue("example.com/owner", "team-a"))
+		})
+
+		It("should refuse to restore metadata the backup has not recorded", func() {
+			newReconciler()
+			restore.Spec.Target.NewPVC.RestoreMetadata = true
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(MatchError(ContainSubstring("has not recorded a source PVC")))
+		})
+
+		It("should refuse Block volumes", func() {
+			block := corev1.PersistentVolumeBlock
+			newReconciler(&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"},
+				Spec:       corev1.PersistentVolumeClaimSpec{VolumeMode: &block},
+			})
+			restore.Spec.Target.NewPVC.InheritFromSource = true
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(MatchError(ContainSubstring("is a Block volume")))
+
+			restore.Spec.Target.NewPVC.VolumeMode = "Filesystem"
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(Succeed())
+			Expect(*getPVC().Spec.VolumeMode).To(Equal(corev1.PersistentVolumeFilesystem))
+
+			restore.Spec.Target.NewPVC.Name = "restored-block"
+			restore.Spec.Target.NewPVC.VolumeMode = "Block"
+			Expect(reconciler.ensureNewPVC(context.Background(), restore, backup)).To(MatchError(ContainSubstring("can't be a Block volume")))
+		})
+	})
+
+	Context("selectSnapshot", func() {
+		now := time.Now()
+		snapshots := []restic.Snapshot{
+			{ID: "old", Time: now.Add(-48 * time.Hour), Hostname: "app-data", Paths: []string{"/backup"}, Tags: []string{"daily"}},
+			{ID: "new", Time: now.Add(-1 * time.Hour), Hostname: "app-data", Paths: []string{"/backup"}, Tags: []string{"hourly"}},
+			{ID: "other", Time: now, Hostname: "other-data", Paths: []string{"/data"}, Tags: []string{"daily"}},
+		}
+
+		It("should select the newest snapshot matching hostname and tags", func() {
+			selector := &backupv1alpha1.SnapshotSelector{Hostname: "app-data", Tags: []string{"daily"}}
+			Expect(selectSnapshot(snapshots, selector).ID).To(Equal("old"))
+		})
+
+		It("should filter by paths", func() {
+			selector := &backupv1alpha1.SnapshotSelector{Paths: []string{"/backup"}}
+			Expect(selectSnapshot(snapshots, selector).ID).To(Equal("new"))
+		})
+
+		It("should select the newest snapshot before the timestamp", func() {
+			selector := &backupv1alpha1.SnapshotSelector{Before: &metav1.Time{Time: now.Add(-2 * time.Hour)}}
+			Expect(selectSnapshot(snapshots, selector).ID).To(Equal("old"))
+		})
+
+		It("should return nil if no snapshot matches", func() {
+			selector := &backupv1alpha1.SnapshotSelector{Hostname: "app-data", Tags: []string{"weekly"}}
+			Expect(selectSnapshot(snapshots, selector)).To(BeNil())
+		})
+	})
+
+	Context("restore throttling helper functions", func() {
+		It("should not throttle when no limits are configured", func() {
+			reconciler := &ResticRestoreReconciler{}
+			restore := &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-restore", Namespace: "default"},
+			}
+
+			msg, err := reconciler.getThrottleMessage(ctx, restore)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(msg).To(BeEmpty())
+		})
+
+		It("should not admit two restores for the same free slot", func() {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			first := &backupv1alpha1.ResticRestore{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "default", UID: "first-uid"}}
+			second := &backupv1alpha1.ResticRestore{ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "default", UID: "second-uid"}}
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(first, second).Build()
+			reconciler := &ResticRestoreReconciler{Client: c, APIReader: c, MaxConcurrentRestores: 1}
+
+			msg, release, err := reconciler.admitRestore(ctx, first)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(msg).To(BeEmpty())
+			msg, _, err = reconciler.admitRestore(ctx, second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(msg).To(ContainSubstring("1/1 restores active or queued cluster-wide"))
+
+			release()
+			msg, _, err = reconciler.admitRestore(ctx, second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(msg).To(BeEmpty())
+		})
+
+		It("should not let a restore waiting for its namespace block other namespaces", func() {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			created := metav1.NewTime(time.Now().Add(-time.Hour))
+			running := &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "shop", UID: "running-uid", CreationTimestamp: created},
+				Status:     backupv1alpha1.ResticRestoreStatus{Phase: backupv1alpha1.RestorePhaseInProgress},
+			}
+			waiting := &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "waiting", Namespace: "shop", UID: "waiting-uid", CreationTimestamp: created},
+				Status:     backupv1alpha1.ResticRestoreStatus{Phase: backupv1alpha1.RestorePhaseQueued},
+			}
+			restore := &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "blog", Namespace: "blog", UID: "blog-uid", CreationTimestamp: metav1.Now()},
+			}
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(running, waiting, restore).Build()
+			reconciler := &ResticRestoreReconciler{Client: c, MaxConcurrentRestores: 2, MaxConcurrentRestoresPerNamespace: 1}
+
+			msg, err := reconciler.getThrottleMessage(ctx, restore)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(msg).To(BeEmpty())
+
+			reconciler.MaxConcurrentRestoresPerNamespace = 2
+			msg, err = reconciler.getThrottleMessage(ctx, restore)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(msg).To(ContainSubstring("2/2 restores active or queued cluster-wide"))
+		})
+
+		It("should order queued restores by creation time", func() {
+			older := &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "b-restore",
+					Namespace:         "default",
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Minute)),
+				},
+			}
+			newer := &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "a-restore",
+					Namespace:         "default",
+					CreationTimestamp: metav1.NewTime(time.Now()),
+				},
+			}
+
+			Expect(queuedBefore(older, newer)).To(BeTrue())
+			Expect(queuedBefore(newer, older)).To(BeFalse())
+		})
+
+		It("should order restores with equal creation time by name", func() {
+			now := metav1.NewTime(time.Now())
+			a := &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "a-restore", Namespace: "default", CreationTimestamp: now},
+			}
+			b := &backupv1alpha1.ResticRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "b-restore", Namespace: "default", CreationTimestamp: now},
+			}
+
+			Expect(queuedBefore(a, b)).To(BeTrue())
+			Expect(queuedBefore(b, a)).To(BeFalse())
+		})
 	})
 })
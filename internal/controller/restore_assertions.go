@@ -0,0 +1,199 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// restoreTargetPath is where the restore target is mounted in the restore container.
+	restoreTargetPath = "/restore"
+	// checksumManifestVolume holds the checksum manifest of a ConfigMap.
+	checksumManifestVolume = "checksum-manifest"
+	// checksumManifestPath is where the checksum manifest of a ConfigMap is mounted.
+	checksumManifestPath = "/etc/restic/checksums"
+	// checksumManifestFile is the file name of the mounted checksum manifest.
+	checksumManifestFile = "SHA256SUMS"
+)
+
+// Assertion results are written to the termination message as tab-separated lines.
+const (
+	assertionPassed = "PASS"
+	assertionFailed = "FAIL"
+)
+
+// buildRestoreScript builds the shell script run by the restore container if the
+// restore restores a chain of snapshots, fixes the ownership of the restored files or
+// has assertions. The snapshots are restored in order, and each restore command must
+// succeed before the next one runs. Both the ownership fix and the assertions run only
+// after a successful restore, the ownership first so the assertions check the files
+// as the application sees them. A failed assertion fails the job. The assertion results
+// are written to the termination message, failed assertions first, so they survive
+// truncation of long messages.
+func buildRestoreScript(restoreCmds [][]string, fix *backupv1alpha1.FixOwnership, assertions *backupv1alpha1.RestoreAssertions) string {
+	commands := make([]string, 0, len(restoreCmds))
+	for _, command := range restoreCmds {
+		commands = append(commands, shellQuoteArgs(command)+" || exit $?")
+	}
+	if fix != nil {
+		commands = append(commands, buildFixOwnershipCommands(fix)...)
+	}
+	if assertions == nil {
+		return strings.Join(commands, "\n")
+	}
+
+	commands = append(commands,
+		"failed=0",
+		"pass() { printf '"+assertionPassed+"\\t%s\\t%s\\n' \"$1\" \"$2\" >> /tmp/assertions.log; }",
+		"fail() { printf '"+assertionFailed+"\\t%s\\t%s\\n' \"$1\" \"$2\" >> /tmp/assertions.log; failed=1; }",
+	)
+
+	for _, p := range assertions.PathsExist {
+		name := shellQuoteArgs([]string{"pathExists:" + p})
+		target := shellQuoteArgs([]string{path.Join(restoreTargetPath, p)})
+		commands = append(commands, fmt.Sprintf("if [ -e %s ]; then pass %s 'exists'; else fail %s 'does not exist'; fi", target, name, name))
+	}
+
+	if assertions.MinFileCount != nil {
+		commands = append(commands,
+			fmt.Sprintf("files=$(find %s -type f | wc -l)", restoreTargetPath),
+			fmt.Sprintf("if [ \"$files\" -ge %d ]; then pass minFileCount \"$files files\"; else fail minFileCount \"$files files, expected at least %d\"; fi",
+				*assertions.MinFileCount, *assertions.MinFileCount),
+		)
+	}
+
+	if manifest := assertions.ChecksumManifest; manifest != nil {
+		dir, file := restoreTargetPath, path.Join(checksumManifestPath, checksumManifestFile)
+		if manifest.Path != "" {
+			dir, file = path.Split(path.Join(restoreTargetPath, manifest.Path))
+		}
+		commands = append(commands, fmt.Sprintf("if (cd %s && sha256sum -c %s) > /tmp/checksums.log 2>&1; "+
+			"then pass checksumManifest \"$(grep -c ': OK$' /tmp/checksums.log) files match\"; "+
+			"else fail checksumManifest \"$(grep -v ': OK$' /tmp/checksums.log | head -n 1)\"; fi",
+			shellQuoteArgs([]string{dir}), shellQuoteArgs([]string{file})))
+	}
+
+	if assertions.Command != "" {
+		commands = append(commands, fmt.Sprintf("if (cd %s && /bin/sh -c %s) > /tmp/command.log 2>&1; "+
+			"then pass command 'exited with 0'; "+
+			"else fail command \"exit code $?: $(tail -n 1 /tmp/command.log)\"; fi",
+			restoreTargetPath, shellQuoteArgs([]string{assertions.Command})))
+	}
+
+	commands = append(commands,
+		fmt.Sprintf("{ grep '^%s' /tmp/assertions.log; grep '^%s' /tmp/assertions.log; } > /dev/termination-log", assertionFailed, assertionPassed),
+		"exit $failed",
+	)
+	return strings.Join(commands, "\n")
+}
+
+// applyChecksumManifest mounts the checksum manifest of a ConfigMap into the restore container.
+func applyChecksumManifest(podSpec *corev1.PodSpec, assertions *backupv1alpha1.RestoreAssertions) {
+	if assertions == nil || assertions.ChecksumManifest == nil || assertions.ChecksumManifest.ConfigMapRef == nil {
+		return
+	}
+
+	ref := assertions.ChecksumManifest.ConfigMapRef
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: checksumManifestVolume,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+				Items:                []corev1.KeyToPath{{Key: ref.Key, Path: checksumManifestFile}},
+			},
+		},
+	})
+	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      checksumManifestVolume,
+		MountPath: checksumManifestPath,
+		ReadOnly:  true,
+	})
+}
+
+// parseAssertionResults parses the assertion results of a restore termination message.
+// Lines that aren't assertion results, e.g. a truncated last line, are skipped.
+func parseAssertionResults(message string) []backupv1alpha1.RestoreAssertionResult {
+	var results []backupv1alpha1.RestoreAssertionResult
+	for _, line := range strings.Split(message, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 || (fields[0] != assertionPassed && fields[0] != assertionFailed) {
+			continue
+		}
+		results = append(results, backupv1alpha1.RestoreAssertionResult{
+			Name:    fields[1],
+			Passed:  fields[0] == assertionPassed,
+			Message: fields[2],
+		})
+	}
+	return results
+}
+
+// recordAssertionResults reads the assertion results of a finished restore job into
+// the status and sets the AssertionsPassed condition. It returns the names of the
+// failed assertions.
+func (r *ResticRestoreReconciler) recordAssertionResults(ctx context.Context, restore *backupv1alpha1.ResticRestore, job *batchv1.Job) ([]string, error) {
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	message, err := jobTerminationMessage(ctx, reader, job)
+	if err != nil {
+		return nil, err
+	}
+
+	results := parseAssertionResults(message)
+	restore.Status.Assertions = results
+	if len(results) == 0 {
+		// The restore itself failed, the assertions didn't run
+		return nil, nil
+	}
+
+	var failed []string
+	for _, result := range results {
+		if !result.Passed {
+			failed = append(failed, result.Name)
+		}
+	}
+
+	if len(failed) == 0 {
+		r.setCondition(restore, metav1.Condition{
+			Type:    backupv1alpha1.ConditionAssertionsPassed,
+			Status:  metav1.ConditionTrue,
+			Reason:  "AssertionsPassed",
+			Message: fmt.Sprintf("All %d restore assertions passed", len(results)),
+		})
+		return nil, nil
+	}
+
+	r.setCondition(restore, metav1.Condition{
+		Type:    backupv1alpha1.ConditionAssertionsPassed,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AssertionsFailed",
+		Message: fmt.Sprintf("Restore assertions failed: %s", strings.Join(failed, ", ")),
+	})
+	return failed, nil
+}
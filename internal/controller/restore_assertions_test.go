@@ -0,0 +1,150 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("Restore assertions", func() {
+	var restore *backupv1alpha1.ResticRestore
+
+	BeforeEach(func() {
+		minFiles := int64(100)
+		restore = &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "verify", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				Target: backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "scratch"}},
+				Assertions: &backupv1alpha1.RestoreAssertions{
+					PathsExist:   []string{"/backup/data/db.sqlite"},
+					MinFileCount: &minFiles,
+					ChecksumManifest: &backupv1alpha1.ChecksumManifest{
+						ConfigMapRef: &backupv1alpha1.ConfigMapKeySelector{Name: "checksums", Key: "db"},
+					},
+				},
+			},
+		}
+	})
+
+	It("should run the assertions after the restore", func() {
+		repository := &backupv1alpha1.ResticRepository{
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		job := (&ResticRestoreReconciler{}).buildRestoreJob(restore, &backupv1alpha1.ResticBackup{}, repository, "abc123")
+
+		container := job.Spec.Template.Spec.Containers[0]
+		Expect(container.Command).To(Equal([]string{"/bin/sh", "-c"}))
+		Expect(container.Args).To(HaveLen(1))
+		script := container.Args[0]
+		Expect(script).To(HavePrefix("'restic' 'restore' 'abc123' '--target' '/restore'"))
+		Expect(script).To(ContainSubstring("if [ -e '/restore/backup/data/db.sqlite' ]; then pass 'pathExists:/backup/data/db.sqlite'"))
+		Expect(script).To(ContainSubstring(`if [ "$files" -ge 100 ]`))
+		Expect(script).To(ContainSubstring("(cd '/restore' && sha256sum -c '/etc/restic/checksums/SHA256SUMS')"))
+		Expect(script).To(HaveSuffix("exit $failed"))
+
+		Expect(job.Spec.Template.Spec.Volumes).To(ContainElement(And(
+			HaveField("Name", checksumManifestVolume),
+			HaveField("ConfigMap.Items", ConsistOf(corev1.KeyToPath{Key: "db", Path: checksumManifestFile})),
+		)))
+		Expect(container.VolumeMounts).To(ContainElement(HaveField("MountPath", checksumManifestPath)))
+	})
+
+	It("should check a manifest within the restored data relative to its directory", func() {
+		restore.Spec.Assertions = &backupv1alpha1.RestoreAssertions{
+			ChecksumManifest: &backupv1alpha1.ChecksumManifest{Path: "/backup/data/SHA256SUMS"},
+		}
+		script := buildRestoreScript([][]string{{"restic", "restore"}}, nil, restore.Spec.Assertions)
+		Expect(script).To(ContainSubstring("(cd '/restore/backup/data/' && sha256sum -c 'SHA256SUMS')"))
+		Expect(script).NotTo(ContainSubstring("minFileCount"))
+	})
+
+	It("should parse the assertion results", func() {
+		results := parseAssertionResults("FAIL\tminFileCount\t12 files, expected at least 100\nPASS\tpathExists:/backup/data\texists\nPASS\tchecks")
+		Expect(results).To(Equal([]backupv1alpha1.RestoreAssertionResult{
+			{Name: "minFileCount", Passed: false, Message: "12 files, expected at least 100"},
+			{Name: "pathExists:/backup/data", Passed: true, Message: "exists"},
+		}))
+	})
+
+	It("should fail the restore with the failed assertions", func() {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		restore.Status = backupv1alpha1.ResticRestoreStatus{
+			Phase:  backupv1alpha1.RestorePhaseInProgress,
+			JobRef: &backupv1alpha1.ObjectReference{Name: "resticrestore-verify", Namespace: "media"},
+		}
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "resticrestore-verify", Namespace: "media"},
+			Status:     batchv1.JobStatus{Failed: 1},
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "resticrestore-verify-abcde",
+				Namespace: "media",
+				Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+			},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "restic",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: 1,
+					Message:  "FAIL\tpathExists:/backup/data/db.sqlite\tdoes not exist\nPASS\tminFileCount\t120 files\n",
+				}},
+			}}},
+		}
+		recorder := record.NewFakeRecorder(10)
+		reconciler := &ResticRestoreReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(restore, job, pod).
+				WithStatusSubresource(&backupv1alpha1.ResticRestore{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: recorder,
+		}
+
+		_, err := reconciler.handleInProgress(context.Background(), restore)
+		Expect(err).NotTo(HaveOccurred())
+
+		updated := &backupv1alpha1.ResticRestore{}
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "verify", Namespace: "media"}, updated)).To(Succeed())
+		Expect(updated.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseFailed))
+		Expect(updated.Status.Assertions).To(HaveLen(2))
+		Expect(conditions.IsConditionFalse(updated.Status.Conditions, backupv1alpha1.ConditionAssertionsPassed)).To(BeTrue())
+		ready := conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionReady)
+		Expect(ready.Reason).To(Equal("AssertionsFailed"))
+		Expect(ready.Message).To(ContainSubstring("pathExists:/backup/data/db.sqlite"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("AssertionsFailed")))
+	})
+})
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// maxRestoreChainLength limits the snapshots a chain selector may select, so a too
+// broad selector fails instead of creating a job restoring the whole repository.
+const maxRestoreChainLength = 100
+
+// resolveRestoreChain returns the snapshots of a restore chain in the order they are
+// restored. Listed snapshot IDs are returned as given, without their time. The
+// snapshots of a selector are listed from the repository, bypassing the snapshot cache,
+// so log backups taken since its last refresh are part of the chain.
+func (r *ResticRestoreReconciler) resolveRestoreChain(ctx context.Context, repository *backupv1alpha1.ResticRepository, chain *backupv1alpha1.RestoreChain) ([]restic.Snapshot, error) {
+	if len(chain.SnapshotIDs) > 0 {
+		snapshots := make([]restic.Snapshot, 0, len(chain.SnapshotIDs))
+		for _, id := range chain.SnapshotIDs {
+			snapshots = append(snapshots, restic.Snapshot{ID: id})
+		}
+		return snapshots, nil
+	}
+
+	snapshots, err := r.listSnapshots(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	return selectRestoreChain(snapshots, chain)
+}
+
+// selectRestoreChain returns the snapshots matching the selector of a chain and taken
+// in its time range, oldest first.
+func selectRestoreChain(snapshots []restic.Snapshot, chain *backupv1alpha1.RestoreChain) ([]restic.Snapshot, error) {
+	var selected []restic.Snapshot
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		if !snapshotMatches(snapshot, chain.Selector) {
+			continue
+		}
+		if chain.After != nil && snapshot.Time.Before(chain.After.Time) {
+			continue
+		}
+		selected = append(selected, *snapshot)
+	}
+	if len(selected) > maxRestoreChainLength {
+		return nil, fmt.Errorf("the chain selector matches %d snapshots, at most %d can be restored in a chain",
+			len(selected), maxRestoreChainLength)
+	}
+
+	slices.SortStableFunc(selected, func(a, b restic.Snapshot) int {
+		return a.Time.Compare(b.Time)
+	})
+	return selected, nil
+}
+
+// snapshotIDs returns the IDs of the snapshots.
+func snapshotIDs(snapshots []restic.Snapshot) []string {
+	ids := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		ids = append(ids, snapshot.ID)
+	}
+	return ids
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+	"github.com/madic-creates/restic-backup-operator/internal/restic/fakerestic"
+)
+
+var _ = Describe("Restore chain", func() {
+	var (
+		ctx        context.Context
+		base       time.Time
+		restore    *backupv1alpha1.ResticRestore
+		backup     *backupv1alpha1.ResticBackup
+		repository *backupv1alpha1.ResticRepository
+		executor   *fakerestic.Executor
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		base = time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+		restore = &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "postgres-pitr", Namespace: "db"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "postgres"},
+				Chain: &backupv1alpha1.RestoreChain{
+					Selector: &backupv1alpha1.SnapshotSelector{
+						Tags:   []string{"postgres"},
+						Before: &metav1.Time{Time: base.Add(3 * time.Hour)},
+					},
+					After: &metav1.Time{Time: base},
+				},
+				Target: backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "postgres-data"}},
+			},
+		}
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "postgres", Namespace: "db"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"},
+			},
+		}
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "db"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "local:/tmp/test-repo",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		executor = fakerestic.New().WithSnapshots(
+			restic.Snapshot{ID: "wal2", Time: base.Add(2 * time.Hour), Tags: []string{"postgres"}},
+			restic.Snapshot{ID: "old", Time: base.Add(-time.Hour), Tags: []string{"postgres"}},
+			restic.Snapshot{ID: "base", Time: base, Tags: []string{"postgres"}},
+			restic.Snapshot{ID: "media", Time: base.Add(time.Hour), Tags: []string{"media"}},
+			restic.Snapshot{ID: "wal1", Time: base.Add(time.Hour), Tags: []string{"postgres"}},
+			restic.Snapshot{ID: "later", Time: base.Add(4 * time.Hour), Tags: []string{"postgres"}},
+		)
+	})
+
+	newReconciler := func() *ResticRestoreReconciler {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-credentials", Namespace: "db"},
+			Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+		}
+		return &ResticRestoreReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(restore, backup, repository, secret).
+				WithStatusSubresource(&backupv1alpha1.ResticRestore{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+			Executor: executor,
+		}
+	}
+
+	It("should select the snapshots of the time range, oldest first", func() {
+		snapshots, err := newReconciler().resolveRestoreChain(ctx, repository, restore.Spec.Chain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshotIDs(snapshots)).To(Equal([]string{"base", "wal1", "wal2"}))
+	})
+
+	It("should restore listed snapshot IDs in the given order", func() {
+		restore.Spec.Chain = &backupv1alpha1.RestoreChain{SnapshotIDs: []string{"wal1", "base"}}
+		snapshots, err := newReconciler().resolveRestoreChain(ctx, repository, restore.Spec.Chain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshotIDs(snapshots)).To(Equal([]string{"wal1", "base"}))
+		Expect(executor.Calls("Snapshots")).To(BeEmpty())
+	})
+
+	It("should reject selectors matching too many snapshots", func() {
+		snapshots := make([]restic.Snapshot, maxRestoreChainLength+1)
+		_, err := selectRestoreChain(snapshots, &backupv1alpha1.RestoreChain{})
+		Expect(err).To(MatchError(ContainSubstring("at most 100")))
+	})
+
+	It("should restore the chain in order with one job", func() {
+		r := newReconciler()
+		_, err := r.handlePending(ctx, restore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restore.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseInProgress))
+		Expect(restore.Status.RestoredSnapshots).To(Equal([]string{"base", "wal1", "wal2"}))
+		Expect(restore.Status.RestoredSnapshot).To(Equal("wal2"))
+		Expect(restore.Status.RestoredSnapshotTime.Time).To(BeTemporally("==", base.Add(2*time.Hour)))
+
+		job := &batchv1.Job{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: "resticrestore-postgres-pitr", Namespace: "db"}, job)).To(Succeed())
+		container := job.Spec.Template.Spec.Containers[0]
+		Expect(container.Command).To(Equal([]string{"/bin/sh", "-c"}))
+		Expect(container.Args).To(Equal([]string{
+			"'restic' 'restore' 'base' '--target' '/restore' '--json' || exit $?\n" +
+				"'restic' 'restore' 'wal1' '--target' '/restore' '--json' || exit $?\n" +
+				"'restic' 'restore' 'wal2' '--target' '/restore' '--json' || exit $?",
+		}))
+	})
+
+	It("should fail if no snapshot is in the time range", func() {
+		restore.Spec.Chain.After = &metav1.Time{Time: base.Add(5 * time.Hour)}
+		restore.Spec.Chain.Selector.Before = nil
+		_, err := newReconciler().handlePending(ctx, restore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restore.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseFailed))
+		Expect(restore.Status.JobRef).To(BeNil())
+	})
+
+	It("should not create the newPVC target if no snapshot is in the time range", func() {
+		restore.Spec.Chain.After = &metav1.Time{Time: base.Add(5 * time.Hour)}
+		restore.Spec.Chain.Selector.Before = nil
+		restore.Spec.Target = backupv1alpha1.RestoreTarget{
+			NewPVC: &backupv1alpha1.NewPVCTarget{Name: "postgres-restored", Size: "10Gi"},
+		}
+		r := newReconciler()
+		_, err := r.handlePending(ctx, restore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restore.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseFailed))
+		Expect(restore.Status.CreatedPVC).To(BeEmpty())
+
+		pvcs := &corev1.PersistentVolumeClaimList{}
+		Expect(r.List(ctx, pvcs, client.InNamespace("db"))).To(Succeed())
+		Expect(pvcs.Items).To(BeEmpty())
+	})
+})
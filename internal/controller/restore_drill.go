@@ -0,0 +1,342 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+// drillRequeueInterval defines how often restore drills re-check their jobs in case a
+// job event was missed.
+const drillRequeueInterval = 5 * time.Minute
+
+// isRestoreDrill returns true if the restore runs on a schedule.
+func isRestoreDrill(restore *backupv1alpha1.ResticRestore) bool {
+	return restore.Spec.Schedule != ""
+}
+
+// reconcileDrill reconciles a scheduled restore. Instead of a single restore job, a
+// CronJob restores the latest snapshot into the newPVC target on every run, and the
+// result of each finished run is recorded in the drill status.
+func (r *ResticRestoreReconciler) reconcileDrill(ctx context.Context, restore *backupv1alpha1.ResticRestore) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	// The webhook rejects these drills too, but it may be disabled
+	if err := validateDrill(&restore.Spec); err != nil {
+		restore.Status.Phase = backupv1alpha1.RestorePhaseScheduled
+		r.setCondition(restore, conditions.NotReadyCondition("InvalidDrill", err.Error()))
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "InvalidDrill", err.Error())
+		return ctrl.Result{}, r.Status().Update(ctx, restore)
+	}
+
+	backup, err := r.getBackup(ctx, restore)
+	setReferenceDenied(&restore.Status.Conditions, err)
+	if err != nil {
+		log.Error(err, "Failed to get backup")
+		return r.drillNotReady(ctx, restore, referenceErrorReason(err, "BackupNotFound"), err)
+	}
+
+	repository, err := r.getRepository(ctx, backup)
+	setReferenceDenied(&restore.Status.Conditions, err)
+	if err != nil {
+		log.Error(err, "Failed to get repository")
+		return r.drillNotReady(ctx, restore, referenceErrorReason(err, "RepositoryNotFound"), err)
+	}
+
+	if err := r.ensureNewPVC(ctx, restore, backup); err != nil {
+		log.Error(err, "Failed to create target PVC")
+		return r.drillNotReady(ctx, restore, "PVCCreationFailed", err)
+	}
+
+	if err := r.reconcileDrillCronJob(ctx, restore, backup, repository); err != nil {
+		log.Error(err, "Failed to reconcile CronJob")
+		return r.drillNotReady(ctx, restore, "CronJobFailed", err)
+	}
+
+	// Report the result of the latest finished drill job
+	if err := r.updateLastDrill(ctx, restore, backup); err != nil {
+		log.Error(err, "Failed to evaluate restore drill jobs")
+	}
+
+	restore.Status.Phase = backupv1alpha1.RestorePhaseScheduled
+	restore.Status.Drill.NextRunTime = nextDrillRun(restore, time.Now())
+	restore.Status.ObservedGeneration = restore.Generation
+	if restore.Status.Drill.LastResult == backupv1alpha1.RestoreDrillFailed {
+		r.setCondition(restore, conditions.NotReadyCondition("DrillFailed",
+			fmt.Sprintf("Restore drill failed, see job %s", restore.Status.Drill.LastJobName)))
+	} else {
+		r.setCondition(restore, conditions.ReadyCondition("DrillScheduled", "Restore drill CronJob is configured"))
+	}
+
+	if err := r.Status().Update(ctx, restore); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: drillRequeueInterval}, nil
+}
+
+// validateDrill checks that a restore drill restores the latest snapshot into a PVC
+// created for it. Restoring with --delete into an existing PVC on every run would
+// overwrite live data.
+func validateDrill(spec *backupv1alpha1.ResticRestoreSpec) error {
+	switch {
+	case spec.Target.NewPVC == nil:
+		return fmt.Errorf("a restore drill restores into a newPVC target")
+	case spec.SnapshotID != "", spec.Chain != nil,
+		spec.SnapshotSelector != nil && spec.SnapshotSelector.Before != nil:
+		return fmt.Errorf("a restore drill restores the latest snapshot, snapshotID, chain and snapshotSelector.before must be empty")
+	}
+	return nil
+}
+
+// drillNotReady reports an error preventing the drill from being scheduled. The drill
+// is retried, e.g. until a missing backup is created.
+func (r *ResticRestoreReconciler) drillNotReady(ctx context.Context, restore *backupv1alpha1.ResticRestore, reason string, err error) (ctrl.Result, error) {
+	restore.Status.Phase = backupv1alpha1.RestorePhaseScheduled
+	r.setCondition(restore, conditions.NotReadyCondition(reason, err.Error()))
+	r.Recorder.Event(restore, corev1.EventTypeWarning, reason, err.Error())
+	if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+}
+
+func (r *ResticRestoreReconciler) reconcileDrillCronJob(ctx context.Context, restore *backupv1alpha1.ResticRestore, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) error {
+	log := log.FromContext(ctx)
+
+	cronJob := r.buildDrillCronJob(restore, backup, repository)
+
+	// Set owner reference
+	if err := controllerutil.SetControllerReference(restore, cronJob, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	restore.Status.CronJobRef = &backupv1alpha1.ObjectReference{
+		Name:      cronJob.Name,
+		Namespace: cronJob.Namespace,
+	}
+	if restore.Status.Drill == nil {
+		restore.Status.Drill = &backupv1alpha1.RestoreDrillStatus{}
+	}
+
+	existingCronJob := &batchv1.CronJob{}
+	err := r.Get(ctx, types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, existingCronJob)
+	if apierrors.IsNotFound(err) {
+		log.Info("Creating CronJob", "name", cronJob.Name)
+		created, err := createOrAdopt(ctx, r.Client, restore, cronJob, existingCronJob)
+		if err != nil {
+			return fmt.Errorf("failed to create CronJob: %w", err)
+		}
+		if created {
+			r.Recorder.Event(restore, corev1.EventTypeNormal, "CronJobCreated", fmt.Sprintf("Created CronJob %s", cronJob.Name))
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get CronJob: %w", err)
+	}
+
+	existingCronJob.Spec = cronJob.Spec
+	if err := r.Update(ctx, existingCronJob); err != nil {
+		return fmt.Errorf("failed to update CronJob: %w", err)
+	}
+	return nil
+}
+
+// buildDrillCronJob builds the CronJob of a restore drill from the restore job. Runs
+// never overlap, as they share the target PVC.
+func (r *ResticRestoreReconciler) buildDrillCronJob(restore *backupv1alpha1.ResticRestore, backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository) *batchv1.CronJob {
+	job := r.buildRestoreJob(restore, backup, repository, "latest")
+
+	var successLimit, failLimit int32 = 3, 3
+	if restore.Spec.JobConfig != nil {
+		if restore.Spec.JobConfig.SuccessfulJobsHistoryLimit != nil {
+			successLimit = *restore.Spec.JobConfig.SuccessfulJobsHistoryLimit
+		}
+		if restore.Spec.JobConfig.FailedJobsHistoryLimit != nil {
+			failLimit = *restore.Spec.JobConfig.FailedJobsHistoryLimit
+		}
+	}
+
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: job.ObjectMeta,
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   restore.Spec.Schedule,
+			Suspend:                    &restore.Spec.Suspend,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &successLimit,
+			FailedJobsHistoryLimit:     &failLimit,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: job.Labels},
+				Spec:       job.Spec,
+			},
+		},
+	}
+
+	if restore.Spec.Timezone != "" && restore.Spec.Timezone != "UTC" {
+		cronJob.Spec.TimeZone = &restore.Spec.Timezone
+	}
+
+	return cronJob
+}
+
+// drillRestoreArgs returns the restic restore flags of a restore drill. The snapshot
+// selector filters the "latest" snapshot, defaulting to the snapshots of the referenced
+// backup. Files not in the snapshot are deleted, so the target PVC holds exactly the
+// snapshot on every run.
+func drillRestoreArgs(restore *backupv1alpha1.ResticRestore, backup *backupv1alpha1.ResticBackup) []string {
+	args := []string{"--delete"}
+
+	selector := restore.Spec.SnapshotSelector
+	if selector == nil || (selector.Hostname == "" && len(selector.Tags) == 0 && len(selector.Paths) == 0) {
+		return append(args, "--tag", normalizeTags(backup, []string{backupTag(backup)})[0])
+	}
+
+	if selector.Hostname != "" {
+		args = append(args, "--host", selector.Hostname)
+	}
+	if len(selector.Tags) > 0 {
+		args = append(args, "--tag", strings.Join(selector.Tags, ","))
+	}
+	for _, path := range selector.Paths {
+		args = append(args, "--path", path)
+	}
+	return args
+}
+
+// updateLastDrill evaluates the most recently finished drill job. Each job is reported
+// only once, so events and notifications are sent once per drill run.
+func (r *ResticRestoreReconciler) updateLastDrill(ctx context.Context, restore *backupv1alpha1.ResticRestore, backup *backupv1alpha1.ResticBackup) error {
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs,
+		client.InNamespace(restore.Namespace),
+		client.MatchingLabels{resticRestoreLabel: restore.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list restore drill jobs: %w", err)
+	}
+
+	drill := restore.Status.Drill
+	latest, latestSucceeded, latestFinishedAt := latestFinishedJob(jobs.Items)
+	if latest == nil || latest.Name == drill.LastJobName {
+		return nil
+	}
+
+	finishedAt := metav1.NewTime(latestFinishedAt)
+	drill.LastRunTime = &finishedAt
+	drill.LastJobName = latest.Name
+
+	var failed []string
+	if restore.Spec.Assertions != nil {
+		var err error
+		if failed, err = r.recordAssertionResults(ctx, restore, latest); err != nil {
+			// The pod may already be gone, report the result without details
+			log.FromContext(ctx).Error(err, "Failed to read restore assertion results")
+		}
+	}
+
+	if latestSucceeded {
+		drill.LastResult = backupv1alpha1.RestoreDrillSucceeded
+		drill.LastSuccessTime = &finishedAt
+		drill.ConsecutiveFailures = 0
+		r.Recorder.Event(restore, corev1.EventTypeNormal, "DrillSucceeded", fmt.Sprintf("Restore drill job %s succeeded", latest.Name))
+		return nil
+	}
+
+	drill.LastResult = backupv1alpha1.RestoreDrillFailed
+	drill.ConsecutiveFailures++
+	message := fmt.Sprintf("Restore drill job %s failed", latest.Name)
+	if len(failed) > 0 {
+		message = fmt.Sprintf("%s, assertions failed: %s", message, strings.Join(failed, ", "))
+	}
+	r.Recorder.Event(restore, corev1.EventTypeWarning, "DrillFailed", message)
+	r.notifyDrillFailure(ctx, restore, backup, latest, latestFinishedAt, message)
+
+	return nil
+}
+
+// notifyDrillFailure sends a failed drill run to the notification backends of the
+// referenced backup. Errors are reported as events, they do not affect the drill.
+func (r *ResticRestoreReconciler) notifyDrillFailure(ctx context.Context, restore *backupv1alpha1.ResticRestore, backup *backupv1alpha1.ResticBackup, job *batchv1.Job, finishedAt time.Time, message string) {
+	if r.Notifications == nil || backup.Spec.Notifications == nil {
+		return
+	}
+	log := log.FromContext(ctx)
+
+	config, err := backupNotificationConfig(ctx, r.Client, backup)
+	if err != nil {
+		log.Error(err, "Failed to resolve notification config")
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+	}
+	// The Pushgateway receives backup metrics only
+	config.Pushgateway = nil
+
+	var duration time.Duration
+	if job.Status.StartTime != nil {
+		duration = finishedAt.Sub(job.Status.StartTime.Time).Round(time.Second)
+	}
+
+	if err := r.Notifications.NotifyRestoreFailure(ctx, config, restore.Name, restore.Namespace, message, duration); err != nil {
+		log.Error(err, "Failed to send restore drill notification")
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+	}
+}
+
+// nextDrillRun returns the next scheduled drill run after now, or nil if the drill is
+// suspended or its schedule is invalid.
+func nextDrillRun(restore *backupv1alpha1.ResticRestore, now time.Time) *metav1.Time {
+	if restore.Spec.Suspend {
+		return nil
+	}
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	schedule, err := parser.Parse(restore.Spec.Schedule)
+	if err != nil {
+		return nil
+	}
+	if restore.Spec.Timezone != "" {
+		if location, err := time.LoadLocation(restore.Spec.Timezone); err == nil {
+			now = now.In(location)
+		}
+	}
+	return &metav1.Time{Time: schedule.Next(now)}
+}
+
+// restoreForJob maps a restore Job to its ResticRestore. The Jobs of restore drills are
+// owned by the CronJob, so they are matched by label instead of owner reference.
+func restoreForJob(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[resticRestoreLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}}}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("Restore drills", func() {
+	var (
+		restore    *backupv1alpha1.ResticRestore
+		backup     *backupv1alpha1.ResticBackup
+		repository *backupv1alpha1.ResticRepository
+	)
+
+	BeforeEach(func() {
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "emby", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo"},
+				Schedule:      "0 2 * * *",
+			},
+		}
+		restore = &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "drill", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "emby"},
+				Schedule:  "0 4 * * 0",
+				Timezone:  "Europe/Berlin",
+				Target: backupv1alpha1.RestoreTarget{NewPVC: &backupv1alpha1.NewPVCTarget{
+					Name: "drill-scratch",
+					Size: "1Gi",
+				}},
+				Assertions: &backupv1alpha1.RestoreAssertions{Command: "test -s backup/data/library.db"},
+			},
+		}
+	})
+
+	It("should restore the latest snapshot of the backup on the schedule", func() {
+		cronJob := (&ResticRestoreReconciler{}).buildDrillCronJob(restore, backup, repository)
+
+		Expect(cronJob.Name).To(Equal("resticrestore-drill"))
+		Expect(cronJob.Spec.Schedule).To(Equal("0 4 * * 0"))
+		Expect(cronJob.Spec.TimeZone).To(HaveValue(Equal("Europe/Berlin")))
+		Expect(cronJob.Spec.ConcurrencyPolicy).To(Equal(batchv1.ForbidConcurrent))
+		Expect(cronJob.Spec.JobTemplate.Labels).To(HaveKeyWithValue(resticRestoreLabel, "drill"))
+
+		script := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Args[0]
+		Expect(script).To(HavePrefix("'restic' 'restore' 'latest' '--target' '/restore'"))
+		Expect(script).To(ContainSubstring("'--delete' '--tag' 'backup=media/emby'"))
+		Expect(script).To(ContainSubstring("(cd /restore && /bin/sh -c 'test -s backup/data/library.db')"))
+	})
+
+	It("should filter the latest snapshot by the snapshot selector", func() {
+		restore.Spec.SnapshotSelector = &backupv1alpha1.SnapshotSelector{
+			Hostname: "emby",
+			Tags:     []string{"daily", "media"},
+			Paths:    []string{"/backup"},
+		}
+		Expect(drillRestoreArgs(restore, backup)).To(Equal([]string{
+			"--delete", "--host", "emby", "--tag", "daily,media", "--path", "/backup",
+		}))
+	})
+
+	It("should compute the next run in the time zone of the schedule", func() {
+		now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+		next := nextDrillRun(restore, now)
+		Expect(next).NotTo(BeNil())
+		Expect(next.UTC()).To(Equal(time.Date(2025, 3, 2, 3, 0, 0, 0, time.UTC)))
+
+		restore.Spec.Suspend = true
+		Expect(nextDrillRun(restore, now)).To(BeNil())
+	})
+
+	It("should not schedule a drill into an existing PVC", func() {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		restore.Spec.Target = backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "emby-data"}}
+		reconciler := &ResticRestoreReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(restore, backup, repository).
+				WithStatusSubresource(&backupv1alpha1.ResticRestore{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+		}
+		key := types.NamespacedName{Name: "drill", Namespace: "media"}
+		DeferCleanup(deleteRestoreMetrics, key)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		updated := &backupv1alpha1.ResticRestore{}
+		Expect(reconciler.Get(ctx, key, updated)).To(Succeed())
+		Expect(updated.Status.CronJobRef).To(BeNil())
+		Expect(conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionReady).Reason).To(Equal("InvalidDrill"))
+		Expect(reconciler.Get(ctx, types.NamespacedName{Name: "resticrestore-drill", Namespace: "media"}, &batchv1.CronJob{})).NotTo(Succeed())
+	})
+
+	It("should record a failed drill run once", func() {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		finishedAt := metav1.NewTime(time.Now().Add(-time.Minute))
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "resticrestore-drill-29001",
+				Namespace: "media",
+				Labels:    map[string]string{resticRestoreLabel: "drill"},
+			},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type:               batchv1.JobFailed,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: finishedAt,
+			}}},
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "resticrestore-drill-29001-abcde",
+				Namespace: "media",
+				Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+			},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "restic",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: 1,
+					Message:  "FAIL\tcommand\texit code 1: \n",
+				}},
+			}}},
+		}
+		recorder := record.NewFakeRecorder(10)
+		reconciler := &ResticRestoreReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(restore, backup, repository, job, pod).
+				WithStatusSubresource(&backupv1alpha1.ResticRestore{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: recorder,
+		}
+		key := types.NamespacedName{Name: "drill", Namespace: "media"}
+		DeferCleanup(deleteRestoreMetrics, key)
+
+		for range 2 {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		updated := &backupv1alpha1.ResticRestore{}
+		Expect(reconciler.Get(ctx, key, updated)).To(Succeed())
+		Expect(updated.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseScheduled))
+		Expect(updated.Status.CronJobRef).NotTo(BeNil())
+		Expect(updated.Status.Drill.LastResult).To(Equal(backupv1alpha1.RestoreDrillFailed))
+		Expect(updated.Status.Drill.LastJobName).To(Equal(job.Name))
+		Expect(updated.Status.Drill.ConsecutiveFailures).To(Equal(int32(1)))
+		Expect(updated.Status.Drill.NextRunTime).NotTo(BeNil())
+		Expect(updated.Status.Assertions).To(ConsistOf(HaveField("Name", "command")))
+		Expect(conditions.GetCondition(updated.Status.Conditions, backupv1alpha1.ConditionReady).Reason).To(Equal("DrillFailed"))
+
+		cronJob := &batchv1.CronJob{}
+		Expect(reconciler.Get(ctx, types.NamespacedName{Name: "resticrestore-drill", Namespace: "media"}, cronJob)).To(Succeed())
+		Expect(reconciler.Get(ctx, types.NamespacedName{Name: "drill-scratch", Namespace: "media"}, &corev1.PersistentVolumeClaim{})).To(Succeed())
+
+		var drillEvents []string
+		for len(recorder.Events) > 0 {
+			if event := <-recorder.Events; strings.Contains(event, "DrillFailed") {
+				drillEvents = append(drillEvents, event)
+			}
+		}
+		Expect(drillEvents).To(ConsistOf(ContainSubstring("assertions failed: command")))
+	})
+})
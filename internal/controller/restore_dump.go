@@ -0,0 +1,188 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// maxDumpSize limits the data of a dump target. Secrets and ConfigMaps are limited
+	// to 1 MiB including their metadata.
+	maxDumpSize = 1000 * 1024
+	// dumpTimeout bounds the restic dump run by the operator.
+	dumpTimeout = 10 * time.Minute
+)
+
+// invalidDumpKeyChars matches the characters not allowed in Secret and ConfigMap keys.
+var invalidDumpKeyChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// runDump dumps the path of the dump target from the snapshot into a new Secret or
+// ConfigMap and returns the size of the dumped data. restic dump runs in the operator,
+// no job or PVC is needed.
+func (r *ResticRestoreReconciler) runDump(ctx context.Context, restore *backupv1alpha1.ResticRestore,
+	repository *backupv1alpha1.ResticRepository, snapshotID string) (int, error) {
+	target := restore.Spec.Target.Dump
+	if !path.IsAbs(target.Path) {
+		return 0, fmt.Errorf("dump path %q must be absolute", target.Path)
+	}
+	creds, err := repositoryCredentials(ctx, r.Client, repository)
+	if err != nil {
+		return 0, err
+	}
+
+	executor := r.Executor
+	if executor == nil {
+		executor = restic.NewExecutor(log.FromContext(ctx))
+	}
+
+	dumpCtx, cancel := context.WithTimeout(ctx, dumpTimeout)
+	defer cancel()
+	data, err := executor.Dump(dumpCtx, creds, restic.DumpOptions{
+		SnapshotID: snapshotID,
+		Path:       target.Path,
+		MaxSize:    maxDumpSize,
+		ExtraArgs:  repositoryOptions(repository),
+	})
+	if errors.Is(err, restic.ErrDumpTooLarge) {
+		return 0, fmt.Errorf("%s is larger than %s, restore it into a PVC instead", target.Path, formatBytes(maxDumpSize))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	obj := buildDumpObject(restore, data)
+	if err := r.Create(ctx, obj); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return 0, fmt.Errorf("failed to create %s %s: %w", dumpKind(target), target.Name, err)
+		}
+		// A previous reconcile may have created it before the status update failed
+		existing := obj.DeepCopyObject().(client.Object)
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+			return 0, err
+		}
+		if existing.GetLabels()[resticRestoreLabel] != restore.Name {
+			return 0, fmt.Errorf("%s %s already exists", dumpKind(target), target.Name)
+		}
+	}
+	return len(data), nil
+}
+
+// dumpKind returns the kind of object created by a dump target.
+func dumpKind(target *backupv1alpha1.DumpTarget) backupv1alpha1.DumpTargetKind {
+	if target.Kind == "" {
+		return backupv1alpha1.DumpTargetSecret
+	}
+	return target.Kind
+}
+
+// dumpKey returns the data key of a dump target, by default the base name of its path
+// with the characters not allowed in keys replaced.
+func dumpKey(target *backupv1alpha1.DumpTarget) string {
+	if target.Key != "" {
+		return target.Key
+	}
+	key := invalidDumpKeyChars.ReplaceAllString(path.Base(path.Clean("/"+target.Path)), "_")
+	if key == "_" || key == "." || key == ".." {
+		return "dump.tar"
+	}
+	return key
+}
+
+// buildDumpObject builds the Secret or ConfigMap of a dump target. ConfigMaps keep data
+// that isn't valid UTF-8, like tar archives, in binaryData.
+func buildDumpObject(restore *backupv1alpha1.ResticRestore, data []byte) client.Object {
+	target := restore.Spec.Target.Dump
+	meta := metav1.ObjectMeta{
+		Name:      target.Name,
+		Namespace: restore.Namespace,
+		Labels: map[string]string{
+			"app.kubernetes.io/name":       "restic-backup-operator",
+			"app.kubernetes.io/component":  "restore",
+			"app.kubernetes.io/managed-by": "restic-backup-operator",
+			resticRestoreLabel:             restore.Name,
+		},
+	}
+	key := dumpKey(target)
+
+	if dumpKind(target) == backupv1alpha1.DumpTargetConfigMap {
+		configMap := &corev1.ConfigMap{ObjectMeta: meta}
+		if utf8.Valid(data) {
+			configMap.Data = map[string]string{key: string(data)}
+		} else {
+			configMap.BinaryData = map[string][]byte{key: data}
+		}
+		return configMap
+	}
+	return &corev1.Secret{
+		ObjectMeta: meta,
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{key: data},
+	}
+}
+
+// handleDump runs the dump of a dump target and completes or fails the restore.
+func (r *ResticRestoreReconciler) handleDump(ctx context.Context, restore *backupv1alpha1.ResticRestore,
+	repository *backupv1alpha1.ResticRepository, snapshotID string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	target := restore.Spec.Target.Dump
+
+	startTime := metav1.NewTime(time.Now())
+	size, err := r.runDump(ctx, restore, repository, snapshotID)
+	now := metav1.NewTime(time.Now())
+	restore.Status.StartTime = &startTime
+	restore.Status.CompletionTime = &now
+	restore.Status.RestoredSnapshot = snapshotID
+	if err != nil {
+		log.Error(err, "Failed to dump snapshot")
+		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+		r.setCondition(restore, conditions.NotReadyCondition("DumpFailed", err.Error()))
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "DumpFailed", err.Error())
+		if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
+	msg := fmt.Sprintf("Dumped %s into %s %s", target.Path, dumpKind(target), target.Name)
+	restore.Status.Phase = backupv1alpha1.RestorePhaseCompleted
+	restore.Status.RestoredSize = formatBytes(uint64(size))
+	restore.Status.CreatedDump = target.Name
+	r.setCondition(restore, conditions.ReadyCondition("RestoreCompleted", msg))
+	r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreCompleted", msg)
+	if err := r.Status().Update(ctx, restore); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
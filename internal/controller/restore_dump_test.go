@@ -0,0 +1,167 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// dumpExecutor returns fixed data for restic dump.
+type dumpExecutor struct {
+	MockExecutor
+	data []byte
+	opts restic.DumpOptions
+}
+
+func (e *dumpExecutor) Dump(_ context.Context, _ restic.Credentials, opts restic.DumpOptions) ([]byte, error) {
+	e.opts = opts
+	if len(e.data) > opts.MaxSize {
+		return nil, restic.ErrDumpTooLarge
+	}
+	return e.data, nil
+}
+
+var _ = Describe("Dump target", func() {
+	var (
+		restore    *backupv1alpha1.ResticRestore
+		repository *backupv1alpha1.ResticRepository
+		executor   *dumpExecutor
+	)
+
+	BeforeEach(func() {
+		restore = &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				Target: backupv1alpha1.RestoreTarget{
+					Dump: &backupv1alpha1.DumpTarget{Path: "/data/config.yaml", Name: "app-config"},
+				},
+			},
+		}
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "default"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "local:/tmp/test-repo",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		executor = &dumpExecutor{data: []byte("replicas: 3\n")}
+	})
+
+	newReconciler := func() *ResticRestoreReconciler {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-credentials", Namespace: "default"},
+			Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+		}
+		return &ResticRestoreReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).
+				WithObjects(restore, secret).
+				WithStatusSubresource(&backupv1alpha1.ResticRestore{}).
+				Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+			Executor: executor,
+		}
+	}
+
+	It("should dump the path into a Secret and complete the restore", func() {
+		r := newReconciler()
+		_, err := r.handleDump(context.Background(), restore, repository, "abc123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(executor.opts.SnapshotID).To(Equal("abc123"))
+		Expect(executor.opts.Path).To(Equal("/data/config.yaml"))
+
+		secret := &corev1.Secret{}
+		Expect(r.Get(context.Background(), types.NamespacedName{Name: "app-config", Namespace: "default"}, secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("config.yaml", []byte("replicas: 3\n")))
+		Expect(secret.Labels).To(HaveKeyWithValue(resticRestoreLabel, "app-config"))
+
+		Expect(restore.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseCompleted))
+		Expect(restore.Status.CreatedDump).To(Equal("app-config"))
+		Expect(restore.Status.RestoredSnapshot).To(Equal("abc123"))
+
+		// A repeated dump finds its own Secret
+		_, err = r.runDump(context.Background(), restore, repository, "abc123")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should keep binary data of a ConfigMap in binaryData", func() {
+		restore.Spec.Target.Dump = &backupv1alpha1.DumpTarget{
+			Path: "/data/", Kind: backupv1alpha1.DumpTargetConfigMap, Name: "data-archive",
+		}
+		executor.data = []byte{0x1f, 0x8b, 0xff, 0x00}
+		r := newReconciler()
+		_, err := r.runDump(context.Background(), restore, repository, "latest")
+		Expect(err).NotTo(HaveOccurred())
+
+		configMap := &corev1.ConfigMap{}
+		Expect(r.Get(context.Background(), types.NamespacedName{Name: "data-archive", Namespace: "default"}, configMap)).To(Succeed())
+		Expect(configMap.Data).To(BeEmpty())
+		Expect(configMap.BinaryData).To(HaveKeyWithValue("data", executor.data))
+	})
+
+	It("should fail restores larger than the dump limit", func() {
+		executor.data = []byte(strings.Repeat("x", maxDumpSize+1))
+		r := newReconciler()
+		_, err := r.handleDump(context.Background(), restore, repository, "latest")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restore.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseFailed))
+		Expect(restore.Status.Conditions).To(ContainElement(And(
+			HaveField("Reason", "DumpFailed"),
+			HaveField("Message", ContainSubstring("restore it into a PVC instead")),
+		)))
+	})
+
+	It("should reject snapshot IDs and paths restic could parse as flags", func() {
+		restore.Spec.SnapshotID = "--password-command=sh"
+		r := newReconciler()
+		_, err := r.handlePending(context.Background(), restore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restore.Status.Phase).To(Equal(backupv1alpha1.RestorePhaseFailed))
+		Expect(restore.Status.Conditions).To(ContainElement(HaveField("Reason", "InvalidSnapshotID")))
+
+		restore.Spec.Target.Dump.Path = "--password-command=sh"
+		_, err = r.runDump(context.Background(), restore, repository, "latest")
+		Expect(err).To(MatchError(ContainSubstring("must be absolute")))
+		Expect(executor.opts.SnapshotID).To(BeEmpty())
+	})
+
+	It("should not overwrite objects of other restores", func() {
+		r := newReconciler()
+		Expect(r.Create(context.Background(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"},
+		})).To(Succeed())
+		_, err := r.runDump(context.Background(), restore, repository, "latest")
+		Expect(err).To(MatchError(ContainSubstring("already exists")))
+	})
+})
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// resolvePodTarget returns the running pod of the pod target of a file restore and the
+// PVC backing the target volume. The pod is read with the API reader, so the operator
+// doesn't cache all Pods of the cluster.
+func (r *ResticRestoreReconciler) resolvePodTarget(ctx context.Context, restore *backupv1alpha1.ResticRestore) (*corev1.Pod, string, error) {
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	target := restore.Spec.Target.Pod
+	pod := &corev1.Pod{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: restore.Namespace}, pod); err != nil {
+		return nil, "", fmt.Errorf("failed to get target pod %s: %w", target.Name, err)
+	}
+	if pod.Status.Phase != corev1.PodRunning || pod.Spec.NodeName == "" {
+		return nil, "", fmt.Errorf("target pod %s is not running", target.Name)
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name != target.Volume {
+			continue
+		}
+		if volume.PersistentVolumeClaim == nil {
+			return nil, "", fmt.Errorf("volume %s of pod %s is not backed by a PVC", target.Volume, target.Name)
+		}
+		return pod, volume.PersistentVolumeClaim.ClaimName, nil
+	}
+	return nil, "", fmt.Errorf("pod %s has no volume %s", target.Name, target.Volume)
+}
+
+// applyPodTarget lets the restore job restore into the PVC of the target pod volume. The
+// job is scheduled to the node of the pod with a required node affinity, so that a
+// ReadWriteOnce volume can be mounted a second time while the scheduler still checks
+// taints and resources, and runs with the user and group of the pod, so that the restored files get the owner
+// the application expects. Without an fsGroup of the pod the volume isn't changed to the
+// fsGroup of the job, which would touch every file the application uses.
+func applyPodTarget(job *batchv1.Job, pod *corev1.Pod, claimName, subPath string) {
+	spec := &job.Spec.Template.Spec
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	}
+	spec.Affinity.NodeAffinity = &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchFields: []corev1.NodeSelectorRequirement{{
+					Key:      "metadata.name",
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{pod.Spec.NodeName},
+				}},
+			}},
+		},
+	}
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == "restore-target" {
+			spec.Volumes[i].PersistentVolumeClaim.ClaimName = claimName
+		}
+	}
+	for i := range spec.Containers[0].VolumeMounts {
+		mount := &spec.Containers[0].VolumeMounts[i]
+		if mount.Name == "restore-target" {
+			mount.SubPath = strings.Trim(subPath, "/")
+		}
+	}
+
+	securityContext := spec.SecurityContext
+	securityContext.FSGroup = nil
+	podSecurityContext := pod.Spec.SecurityContext
+	if podSecurityContext == nil {
+		return
+	}
+	if podSecurityContext.RunAsUser != nil && *podSecurityContext.RunAsUser != 0 {
+		securityContext.RunAsUser = podSecurityContext.RunAsUser
+	}
+	securityContext.RunAsGroup = podSecurityContext.RunAsGroup
+	if podSecurityContext.FSGroup != nil {
+		policy := corev1.FSGroupChangeOnRootMismatch
+		securityContext.FSGroup = podSecurityContext.FSGroup
+		securityContext.FSGroupChangePolicy = &policy
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("File restore", func() {
+	var (
+		restore *backupv1alpha1.ResticRestore
+		pod     *corev1.Pod
+	)
+
+	BeforeEach(func() {
+		restore = &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "config-php", Namespace: "nextcloud"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				Mode: backupv1alpha1.RestoreModeFileRestore,
+				Target: backupv1alpha1.RestoreTarget{
+					Pod: &backupv1alpha1.PodTarget{Name: "nextcloud-0", Volume: "data", SubPath: "/restored/"},
+				},
+				IncludePaths: []string{"/data/config/config.php"},
+			},
+		}
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "nextcloud-0", Namespace: "nextcloud"},
+			Spec: corev1.PodSpec{
+				NodeName: "node-2",
+				SecurityContext: &corev1.PodSecurityContext{
+					RunAsUser:  int64Ptr(33),
+					RunAsGroup: int64Ptr(33),
+					FSGroup:    int64Ptr(33),
+				},
+				Volumes: []corev1.Volume{
+					{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+					{Name: "data", VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "nextcloud-data"},
+					}},
+				},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	})
+
+	newReconciler := func() *ResticRestoreReconciler {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		// Pods are only read with the API reader
+		return &ResticRestoreReconciler{
+			Client:    fake.NewClientBuilder().WithScheme(testScheme).Build(),
+			APIReader: fake.NewClientBuilder().WithScheme(testScheme).WithObjects(pod).Build(),
+			Scheme:    testScheme,
+		}
+	}
+
+	It("should resolve the PVC of the pod volume", func() {
+		resolved, claim, err := newReconciler().resolvePodTarget(context.Background(), restore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved.Spec.NodeName).To(Equal("node-2"))
+		Expect(claim).To(Equal("nextcloud-data"))
+	})
+
+	It("should reject pods that aren't running or volumes without PVC", func() {
+		pod.Status.Phase = corev1.PodPending
+		_, _, err := newReconciler().resolvePodTarget(context.Background(), restore)
+		Expect(err).To(MatchError(ContainSubstring("is not running")))
+
+		pod.Status.Phase = corev1.PodRunning
+		restore.Spec.Target.Pod.Volume = "tmp"
+		_, _, err = newReconciler().resolvePodTarget(context.Background(), restore)
+		Expect(err).To(MatchError(ContainSubstring("not backed by a PVC")))
+
+		restore.Spec.Target.Pod.Volume = "cache"
+		_, _, err = newReconciler().resolvePodTarget(context.Background(), restore)
+		Expect(err).To(MatchError(ContainSubstring("has no volume cache")))
+	})
+
+	It("should restore on the node of the pod with its user", func() {
+		backup := &backupv1alpha1.ResticBackup{}
+		repository := &backupv1alpha1.ResticRepository{
+			Spec: backupv1alpha1.ResticRepositorySpec{RepositoryURL: "local:/tmp/test-repo"},
+		}
+		job := (&ResticRestoreReconciler{}).buildRestoreJob(restore, backup, repository, "latest")
+		applyPodTarget(job, pod, "nextcloud-data", restore.Spec.Target.Pod.SubPath)
+
+		spec := job.Spec.Template.Spec
+		Expect(spec.NodeName).To(BeEmpty())
+		Expect(spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).To(ConsistOf(
+			HaveField("MatchFields", ConsistOf(corev1.NodeSelectorRequirement{
+				Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"node-2"},
+			})),
+		))
+		Expect(spec.Volumes).To(ContainElement(HaveField("PersistentVolumeClaim.ClaimName", "nextcloud-data")))
+		Expect(spec.Containers[0].VolumeMounts).To(ContainElement(And(
+			HaveField("Name", "restore-target"),
+			HaveField("SubPath", "restored"),
+		)))
+		Expect(spec.Containers[0].Command).To(ContainElements("--include", "/data/config/config.php"))
+		Expect(*spec.SecurityContext.RunAsUser).To(BeEquivalentTo(33))
+		Expect(*spec.SecurityContext.FSGroup).To(BeEquivalentTo(33))
+		Expect(*spec.SecurityContext.FSGroupChangePolicy).To(Equal(corev1.FSGroupChangeOnRootMismatch))
+
+		// Without fsGroup of the pod, the volume isn't changed to the fsGroup of the job
+		pod.Spec.SecurityContext = nil
+		job = (&ResticRestoreReconciler{}).buildRestoreJob(restore, backup, repository, "latest")
+		applyPodTarget(job, pod, "nextcloud-data", "")
+		Expect(job.Spec.Template.Spec.SecurityContext.FSGroup).To(BeNil())
+		Expect(*job.Spec.Template.Spec.SecurityContext.RunAsUser).To(BeEquivalentTo(65532))
+	})
+})
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// notifyRestoreRun sends the result of a finished restore job to the notification
+// backends of the referenced backup. The job is annotated before sending, so the result
+// is sent once even if the status update fails afterwards. Errors sending it are
+// reported as events, they do not affect the restore.
+func (r *ResticRestoreReconciler) notifyRestoreRun(ctx context.Context, restore *backupv1alpha1.ResticRestore, job *batchv1.Job, succeeded bool, message string) error {
+	if r.Notifications == nil || job.Annotations[notifiedAnnotation] != "" {
+		return nil
+	}
+	log := log.FromContext(ctx)
+
+	backup, err := r.getBackup(ctx, restore)
+	if err != nil {
+		// Without the backup there is nothing configured to notify
+		log.Error(err, "Failed to get backup for restore notification")
+		return nil
+	}
+	if backup.Spec.Notifications == nil {
+		return nil
+	}
+
+	result := backupResultSucceeded
+	if !succeeded {
+		result = backupResultFailed
+	}
+	if err := annotateJob(ctx, r.Client, job, notifiedAnnotation, result); err != nil {
+		return err
+	}
+
+	config, err := backupNotificationConfig(ctx, r.Client, backup)
+	if err != nil {
+		log.Error(err, "Failed to resolve notification config")
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+	}
+	// The Pushgateway receives backup metrics only
+	config.Pushgateway = nil
+
+	var duration time.Duration
+	if job.Status.StartTime != nil {
+		duration = time.Since(job.Status.StartTime.Time).Round(time.Second)
+		if job.Status.CompletionTime != nil {
+			duration = job.Status.CompletionTime.Sub(job.Status.StartTime.Time).Round(time.Second)
+		}
+	}
+
+	if succeeded {
+		err = r.Notifications.NotifyRestoreSuccess(ctx, config, restore.Name, restore.Namespace, restore.Status.RestoredSnapshot, duration)
+	} else {
+		err = r.Notifications.NotifyRestoreFailure(ctx, config, restore.Name, restore.Namespace, message, duration)
+	}
+	if err != nil {
+		log.Error(err, "Failed to send restore notification", "job", job.Name)
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/notifications"
+)
+
+var _ = Describe("Restore notifications", func() {
+	var (
+		received []map[string]any
+		server   *httptest.Server
+		backup   *backupv1alpha1.ResticBackup
+		restore  *backupv1alpha1.ResticRestore
+		job      *batchv1.Job
+	)
+
+	BeforeEach(func() {
+		received = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var message map[string]any
+			_ = json.NewDecoder(req.Body).Decode(&message)
+			received = append(received, message)
+			w.WriteHeader(http.StatusOK)
+		}))
+		DeferCleanup(server.Close)
+
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Notifications: &backupv1alpha1.NotificationConfig{
+					Ntfy: &backupv1alpha1.NtfyConfig{Enabled: true, ServerURL: server.URL, Topic: "backups"},
+				},
+			},
+		}
+		restore = &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-restore", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "app"},
+			},
+			Status: backupv1alpha1.ResticRestoreStatus{RestoredSnapshot: "abc123"},
+		}
+		start := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+		job = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "resticrestore-app-restore", Namespace: "media"},
+			Status:     batchv1.JobStatus{StartTime: &start},
+		}
+	})
+
+	newReconciler := func() *ResticRestoreReconciler {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		return &ResticRestoreReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(testScheme).WithObjects(backup, restore, job).Build(),
+			Recorder:      record.NewFakeRecorder(10),
+			Notifications: notifications.NewManager(logr.Discard()),
+		}
+	}
+
+	It("should send a completed restore once", func() {
+		reconciler := newReconciler()
+		Expect(reconciler.notifyRestoreRun(context.Background(), restore, job, true, "")).To(Succeed())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0]["title"]).To(HavePrefix("media/app-restore"))
+		Expect(received[0]["message"]).To(ContainSubstring("Restore completed successfully from snapshot: abc123"))
+
+		// A failed status update processes the job again
+		recorded := &batchv1.Job{}
+		Expect(reconciler.Get(context.Background(), client.ObjectKeyFromObject(job), recorded)).To(Succeed())
+		Expect(recorded.Annotations).To(HaveKeyWithValue(notifiedAnnotation, backupResultSucceeded))
+		Expect(reconciler.notifyRestoreRun(context.Background(), restore, recorded, true, "")).To(Succeed())
+		Expect(received).To(HaveLen(1))
+	})
+
+	It("should send a failed restore", func() {
+		reconciler := newReconciler()
+		Expect(reconciler.notifyRestoreRun(context.Background(), restore, job, false, "Restore job failed")).To(Succeed())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0]["title"]).To(HaveSuffix("Failed"))
+		Expect(received[0]["message"]).To(ContainSubstring("Restore failed: Restore job failed"))
+	})
+
+	It("should not notify if the backup has no notifications", func() {
+		backup.Spec.Notifications = nil
+		reconciler := newReconciler()
+		Expect(reconciler.notifyRestoreRun(context.Background(), restore, job, true, "")).To(Succeed())
+		Expect(received).To(BeEmpty())
+
+		recorded := &batchv1.Job{}
+		Expect(reconciler.Get(context.Background(), client.ObjectKeyFromObject(job), recorded)).To(Succeed())
+		Expect(recorded.Annotations).NotTo(HaveKey(notifiedAnnotation))
+	})
+})
@@ -0,0 +1,63 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// restoreFixOwnership returns the ownership fix of a restore, or nil if it has none.
+func restoreFixOwnership(restore *backupv1alpha1.ResticRestore) *backupv1alpha1.FixOwnership {
+	if restore.Spec.Options == nil {
+		return nil
+	}
+	return restore.Spec.Options.FixOwnership
+}
+
+// buildFixOwnershipCommands returns the shell commands changing the owner and
+// permissions of the restored files. Symbolic links are changed themselves, their
+// targets may be outside the restore target.
+func buildFixOwnershipCommands(fix *backupv1alpha1.FixOwnership) []string {
+	gid := fix.UID
+	if fix.GID != nil {
+		gid = *fix.GID
+	}
+
+	flags := ""
+	if fix.Recursive == nil || *fix.Recursive {
+		flags = "-R "
+	}
+
+	commands := []string{fmt.Sprintf("chown %s-h %d:%d %s || exit $?", flags, fix.UID, gid, restoreTargetPath)}
+	if fix.Mode != "" {
+		commands = append(commands, fmt.Sprintf("chmod %s%s %s || exit $?", flags, shellQuoteArgs([]string{fix.Mode}), restoreTargetPath))
+	}
+	return commands
+}
+
+// applyFixOwnershipSecurityContext lets the restore container run as root with the
+// capabilities to change the owner and permissions of files of other users.
+func applyFixOwnershipSecurityContext(container *corev1.Container) {
+	securityContext := container.SecurityContext
+	securityContext.RunAsUser = int64Ptr(0)
+	securityContext.RunAsNonRoot = boolPtr(false)
+	securityContext.Capabilities.Add = append(securityContext.Capabilities.Add, "CHOWN", "FOWNER", "DAC_OVERRIDE")
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Restore ownership", func() {
+	var (
+		restore    *backupv1alpha1.ResticRestore
+		repository *backupv1alpha1.ResticRepository
+	)
+
+	BeforeEach(func() {
+		restore = &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				Target: backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "data"}},
+				Options: &backupv1alpha1.RestoreOptions{
+					Overwrite:    true,
+					FixOwnership: &backupv1alpha1.FixOwnership{UID: 1000},
+				},
+			},
+		}
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "media"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+	})
+
+	It("should change the owner of the restored files after the restore", func() {
+		job := (&ResticRestoreReconciler{}).buildRestoreJob(restore, &backupv1alpha1.ResticBackup{}, repository, "abc123")
+
+		container := job.Spec.Template.Spec.Containers[0]
+		Expect(container.Command).To(Equal([]string{"/bin/sh", "-c"}))
+		Expect(container.Args[0]).To(HavePrefix("'restic' 'restore' 'abc123'"))
+		Expect(container.Args[0]).To(HaveSuffix("\nchown -R -h 1000:1000 /restore || exit $?"))
+
+		Expect(*container.SecurityContext.RunAsUser).To(BeZero())
+		Expect(*container.SecurityContext.RunAsNonRoot).To(BeFalse())
+		Expect(container.SecurityContext.Capabilities.Add).To(ConsistOf(
+			corev1.Capability("CHOWN"), corev1.Capability("FOWNER"), corev1.Capability("DAC_OVERRIDE")))
+		Expect(container.SecurityContext.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+	})
+
+	It("should change the group and permissions of the target only if not recursive", func() {
+		gid, recursive := int64(2000), false
+		restore.Spec.Options.FixOwnership.GID = &gid
+		restore.Spec.Options.FixOwnership.Mode = "u+rwX,g+rX"
+		restore.Spec.Options.FixOwnership.Recursive = &recursive
+
+		Expect(buildFixOwnershipCommands(restore.Spec.Options.FixOwnership)).To(Equal([]string{
+			"chown -h 1000:2000 /restore || exit $?",
+			"chmod 'u+rwX,g+rX' /restore || exit $?",
+		}))
+	})
+
+	It("should fix the ownership before the assertions run", func() {
+		restore.Spec.Assertions = &backupv1alpha1.RestoreAssertions{PathsExist: []string{"/data"}}
+
+		script := buildRestoreScript([][]string{{"restic", "restore"}}, restoreFixOwnership(restore), restore.Spec.Assertions)
+		Expect(script).To(MatchRegexp(`(?s)chown .*pathExists`))
+		Expect(script).To(HaveSuffix("exit $failed"))
+	})
+
+	It("should run the restore as non-root without an ownership fix", func() {
+		restore.Spec.Options.FixOwnership = nil
+		job := (&ResticRestoreReconciler{}).buildRestoreJob(restore, &backupv1alpha1.ResticBackup{}, repository, "abc123")
+
+		container := job.Spec.Template.Spec.Containers[0]
+		Expect(container.Command[:2]).To(Equal([]string{"restic", "restore"}))
+		Expect(container.SecurityContext.RunAsUser).To(BeNil())
+		Expect(container.SecurityContext.Capabilities.Add).To(BeEmpty())
+	})
+})
@@ -0,0 +1,186 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// restoringAnnotation names the ResticRestore writing into the annotated PVC.
+	restoringAnnotation = "backup.resticbackup.io/restoring"
+	// restoreProgressAnnotation is the progress of the restore writing into the PVC.
+	restoreProgressAnnotation = "backup.resticbackup.io/restore-progress"
+	// restoreProgressUpdatedAnnotation is the time the progress was read.
+	restoreProgressUpdatedAnnotation = "backup.resticbackup.io/restore-progress-updated"
+
+	// restoreProgressFPS makes restic print a JSON status line every 10 seconds. Without
+	// it, restic restore --json prints 60 status lines per second.
+	restoreProgressFPS = "0.1"
+	// restoreProgressLogLines is the number of log lines searched for the latest status line.
+	restoreProgressLogLines = 20
+)
+
+// restoreTargetClaim returns the PVC a restore job writes into, empty if it has none.
+func restoreTargetClaim(job *batchv1.Job) string {
+	for _, volume := range job.Spec.Template.Spec.Volumes {
+		if volume.Name == "restore-target" && volume.PersistentVolumeClaim != nil {
+			return volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	return ""
+}
+
+// formatRestoreProgress formats the progress of a restore for the PVC annotation.
+func formatRestoreProgress(progress *restic.Progress) string {
+	return fmt.Sprintf("%.0f%% (%s of %s)", progress.PercentDone*100,
+		formatBytes(progress.BytesDone), formatBytes(progress.TotalBytes))
+}
+
+// restoreJobProgress returns the latest progress printed by the running pod of the
+// restore job, nil if there is none yet.
+func (r *ResticRestoreReconciler) restoreJobProgress(ctx context.Context, job *batchv1.Job) (*restic.Progress, error) {
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.JobNameLabel: job.Name},
+	); err != nil {
+		return nil, fmt.Errorf("failed to list pods of job %s: %w", job.Name, err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		logs, err := r.PodLogs.TailLogs(ctx, pod.Namespace, pod.Name, "restic", restoreProgressLogLines)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(logs, "\n")
+		for i := len(lines) - 1; i >= 0; i-- {
+			if progress, ok := restic.ParseProgress([]byte(lines[i])); ok {
+				return &progress, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// annotateRestoreTarget records the running restore and its progress on the target PVC,
+// so kubectl describe pvc shows why the volume is being written.
+func (r *ResticRestoreReconciler) annotateRestoreTarget(ctx context.Context, restore *backupv1alpha1.ResticRestore, job *batchv1.Job) error {
+	claim := restoreTargetClaim(job)
+	if claim == "" {
+		return nil
+	}
+
+	annotations := map[string]string{restoringAnnotation: restore.Name}
+	if r.PodLogs != nil {
+		progress, err := r.restoreJobProgress(ctx, job)
+		if err != nil {
+			return err
+		}
+		if progress != nil {
+			annotations[restoreProgressAnnotation] = formatRestoreProgress(progress)
+			annotations[restoreProgressUpdatedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		}
+	}
+
+	return r.updateTargetAnnotations(ctx, types.NamespacedName{Name: claim, Namespace: job.Namespace}, func(pvc *corev1.PersistentVolumeClaim) bool {
+		if owner := pvc.Annotations[restoringAnnotation]; owner != "" && owner != restore.Name {
+			// Another restore writes into the PVC
+			return false
+		}
+		if pvc.Annotations[restoringAnnotation] == restore.Name && annotations[restoreProgressAnnotation] == "" {
+			// Keep the last progress while no new one was printed
+			return false
+		}
+		if pvc.Annotations == nil {
+			pvc.Annotations = map[string]string{}
+		}
+		maps.Copy(pvc.Annotations, annotations)
+		return true
+	})
+}
+
+// clearRestoreTarget removes the restore annotations from the target PVC once the
+// restore finished.
+func (r *ResticRestoreReconciler) clearRestoreTarget(ctx context.Context, restore *backupv1alpha1.ResticRestore, job *batchv1.Job) error {
+	claim := restoreTargetClaim(job)
+	if claim == "" {
+		return nil
+	}
+	return r.updateTargetAnnotations(ctx, types.NamespacedName{Name: claim, Namespace: job.Namespace}, func(pvc *corev1.PersistentVolumeClaim) bool {
+		if pvc.Annotations[restoringAnnotation] != restore.Name {
+			return false
+		}
+		delete(pvc.Annotations, restoringAnnotation)
+		delete(pvc.Annotations, restoreProgressAnnotation)
+		delete(pvc.Annotations, restoreProgressUpdatedAnnotation)
+		return true
+	})
+}
+
+// updateTargetAnnotations patches the annotations of the PVC if mutate changed them.
+func (r *ResticRestoreReconciler) updateTargetAnnotations(ctx context.Context, key types.NamespacedName, mutate func(*corev1.PersistentVolumeClaim) bool) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, key, pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get target PVC %s: %w", key.Name, err)
+	}
+
+	patch := client.MergeFrom(pvc.DeepCopy())
+	if !mutate(pvc) {
+		return nil
+	}
+	if err := r.Patch(ctx, pvc, patch); err != nil {
+		return fmt.Errorf("failed to annotate target PVC %s: %w", key.Name, err)
+	}
+	return nil
+}
+
+// clearDeletedRestoreTarget removes the restore annotations from the target PVC of a
+// restore deleted while its job was running.
+func (r *ResticRestoreReconciler) clearDeletedRestoreTarget(ctx context.Context, restore *backupv1alpha1.ResticRestore) error {
+	if restore.Status.Phase != backupv1alpha1.RestorePhaseInProgress || restore.Status.JobRef == nil {
+		return nil
+	}
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, types.NamespacedName{Name: restore.Status.JobRef.Name, Namespace: restore.Status.JobRef.Namespace}, job); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	return r.clearRestoreTarget(ctx, restore, job)
+}
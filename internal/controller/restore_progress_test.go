@@ -0,0 +1,150 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// fakePodLogReader returns the same log for every pod.
+type fakePodLogReader struct {
+	logs string
+}
+
+func (r *fakePodLogReader) TailLogs(_ context.Context, _, _, _ string, _ int64) (string, error) {
+	return r.logs, nil
+}
+
+var _ = Describe("Restore progress", func() {
+	var (
+		ctx        context.Context
+		testScheme *runtime.Scheme
+		restore    *backupv1alpha1.ResticRestore
+		job        *batchv1.Job
+		pvc        *corev1.PersistentVolumeClaim
+		logs       *fakePodLogReader
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		restore = &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "emby-restore", Namespace: "media"},
+			Status: backupv1alpha1.ResticRestoreStatus{
+				Phase:  backupv1alpha1.RestorePhaseInProgress,
+				JobRef: &backupv1alpha1.ObjectReference{Name: "resticrestore-emby-restore", Namespace: "media"},
+			},
+		}
+		job = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "resticrestore-emby-restore", Namespace: "media"},
+			Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{{
+					Name:         "restore-target",
+					VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "emby-config"}},
+				}},
+			}}},
+		}
+		pvc = &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "emby-config", Namespace: "media"}}
+		logs = &fakePodLogReader{logs: `{"message_type":"status","seconds_elapsed":10,"percent_done":0.1,"total_bytes":1073741824,"bytes_restored":107374182}
+{"message_type":"status","seconds_elapsed":20,"percent_done":0.5,"total_bytes":1073741824,"bytes_restored":536870912}
+`}
+	})
+
+	newReconciler := func(objects ...client.Object) *ResticRestoreReconciler {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: job.Name + "-x7k2p", Namespace: job.Namespace, Labels: map[string]string{batchv1.JobNameLabel: job.Name}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		c := fake.NewClientBuilder().WithScheme(testScheme).
+			WithObjects(append(objects, job, pvc, pod)...).
+			Build()
+		return &ResticRestoreReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(10), PodLogs: logs}
+	}
+
+	getPVC := func(r *ResticRestoreReconciler) *corev1.PersistentVolumeClaim {
+		updated := &corev1.PersistentVolumeClaim{}
+		Expect(r.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, updated)).To(Succeed())
+		return updated
+	}
+
+	It("should annotate the target PVC with the latest progress", func() {
+		r := newReconciler()
+		Expect(r.annotateRestoreTarget(ctx, restore, job)).To(Succeed())
+
+		annotations := getPVC(r).Annotations
+		Expect(annotations).To(HaveKeyWithValue(restoringAnnotation, "emby-restore"))
+		Expect(annotations).To(HaveKeyWithValue(restoreProgressAnnotation, "50% (512.0 MiB of 1.0 GiB)"))
+		Expect(annotations).To(HaveKey(restoreProgressUpdatedAnnotation))
+	})
+
+	It("should keep the last progress while restic printed no new status", func() {
+		r := newReconciler()
+		Expect(r.annotateRestoreTarget(ctx, restore, job)).To(Succeed())
+
+		logs.logs = "restoring files\n"
+		Expect(r.annotateRestoreTarget(ctx, restore, job)).To(Succeed())
+		Expect(getPVC(r).Annotations).To(HaveKeyWithValue(restoreProgressAnnotation, "50% (512.0 MiB of 1.0 GiB)"))
+	})
+
+	It("should annotate the restore without progress reader", func() {
+		r := newReconciler()
+		r.PodLogs = nil
+		Expect(r.annotateRestoreTarget(ctx, restore, job)).To(Succeed())
+
+		annotations := getPVC(r).Annotations
+		Expect(annotations).To(HaveKeyWithValue(restoringAnnotation, "emby-restore"))
+		Expect(annotations).NotTo(HaveKey(restoreProgressAnnotation))
+	})
+
+	It("should not annotate a PVC written by another restore", func() {
+		pvc.Annotations = map[string]string{restoringAnnotation: "other-restore"}
+		r := newReconciler()
+		Expect(r.annotateRestoreTarget(ctx, restore, job)).To(Succeed())
+		Expect(getPVC(r).Annotations).To(Equal(map[string]string{restoringAnnotation: "other-restore"}))
+
+		Expect(r.clearRestoreTarget(ctx, restore, job)).To(Succeed())
+		Expect(getPVC(r).Annotations).To(HaveKeyWithValue(restoringAnnotation, "other-restore"))
+	})
+
+	It("should remove the annotations when the restore is deleted", func() {
+		pvc.Annotations = map[string]string{
+			restoringAnnotation:       "emby-restore",
+			restoreProgressAnnotation: "50% (512.0 MiB of 1.0 GiB)",
+			"team":                    "media",
+		}
+		r := newReconciler()
+		Expect(r.clearDeletedRestoreTarget(ctx, restore)).To(Succeed())
+		Expect(getPVC(r).Annotations).To(Equal(map[string]string{"team": "media"}))
+	})
+})
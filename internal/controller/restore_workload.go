@@ -0,0 +1,116 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// restoreWorkloadOwner returns what a workload stopped for the restore is recorded to
+// be scaled down for. Kinds start upper case, so it never matches a backup name.
+func restoreWorkloadOwner(restore *backupv1alpha1.ResticRestore) string {
+	return "ResticRestore/" + restore.Name
+}
+
+// stopRestoreWorkload scales the workload of stopWorkload to zero replicas before the
+// restore job is created. A message is returned while pods of the workload are still
+// running or the workload is scaled down for something else, and an error if the pods
+// did not terminate within the timeout.
+func (r *ResticRestoreReconciler) stopRestoreWorkload(ctx context.Context, restore *backupv1alpha1.ResticRestore) (string, error) {
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	stop := restore.Spec.StopWorkload
+	ref := stop.WorkloadRef
+	workload, err := getWorkload(ctx, reader, restore.Namespace, ref)
+	if err != nil {
+		return "", err
+	}
+
+	switch scaledDownFor := workloadScaledDownFor(workload); scaledDownFor {
+	case restoreWorkloadOwner(restore):
+	case "":
+		replicas, err := scaleDownWorkloadFor(ctx, r.Client, workload, restoreWorkloadOwner(restore))
+		if err != nil {
+			return "", err
+		}
+		restore.Status.StoppedWorkload = ref.DeepCopy()
+		r.Recorder.Event(restore, corev1.EventTypeNormal, "WorkloadScaledDown",
+			fmt.Sprintf("Scaled %s %s down from %d replicas for the restore", ref.Kind, ref.Name, replicas))
+	default:
+		return fmt.Sprintf("Waiting for %s %s, which is scaled down for %s", ref.Kind, ref.Name, scaledDownFor), nil
+	}
+
+	running, err := countWorkloadPods(ctx, reader, workload)
+	if err != nil {
+		return "", err
+	}
+	if running == 0 {
+		return "", nil
+	}
+	timeout := scaleDownTimeout(stop)
+	if since := workloadScaledDownSince(workload); !since.IsZero() && time.Since(since) > timeout {
+		return "", fmt.Errorf("%d pods of %s %s still running after %s", running, ref.Kind, ref.Name, timeout)
+	}
+	return fmt.Sprintf("Waiting for %d pods of %s %s to terminate", running, ref.Kind, ref.Name), nil
+}
+
+// startRestoreWorkload scales the workload stopped for the restore up to its previous
+// replicas. Workloads scaled down for anything else are left alone.
+func (r *ResticRestoreReconciler) startRestoreWorkload(ctx context.Context, restore *backupv1alpha1.ResticRestore) error {
+	ref := restore.Status.StoppedWorkload
+	if ref == nil && restore.Spec.StopWorkload != nil {
+		ref = &restore.Spec.StopWorkload.WorkloadRef
+	}
+	if ref == nil {
+		return nil
+	}
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	workload, err := getWorkload(ctx, reader, restore.Namespace, *ref)
+	if apierrors.IsNotFound(err) {
+		restore.Status.StoppedWorkload = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if workloadScaledDownFor(workload) != restoreWorkloadOwner(restore) {
+		restore.Status.StoppedWorkload = nil
+		return nil
+	}
+
+	replicas, err := scaleUpWorkload(ctx, r.Client, workload)
+	if err != nil {
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "ScaleUpFailed", err.Error())
+		return err
+	}
+	r.Recorder.Event(restore, corev1.EventTypeNormal, "WorkloadScaledUp",
+		fmt.Sprintf("Scaled %s %s up to %d replicas after the restore", ref.Kind, ref.Name, replicas))
+	restore.Status.StoppedWorkload = nil
+	return nil
+}
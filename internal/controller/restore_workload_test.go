@@ -0,0 +1,158 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Restore workload", func() {
+	var (
+		ctx        context.Context
+		testScheme *runtime.Scheme
+		reconciler *ResticRestoreReconciler
+		restore    *backupv1alpha1.ResticRestore
+		nextcloud  *appsv1.Deployment
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		restore = &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "nextcloud-restore", Namespace: "cloud", Finalizers: []string{resticRestoreFinalizer}},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				Target: backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "nextcloud-data"}},
+				StopWorkload: &backupv1alpha1.ScaleDownHook{
+					WorkloadRef: backupv1alpha1.WorkloadReference{Kind: "Deployment", Name: "nextcloud"},
+					Timeout:     &metav1.Duration{Duration: time.Minute},
+				},
+			},
+		}
+		nextcloud = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "nextcloud", Namespace: "cloud"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: int32Ptr(3),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nextcloud"}},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) {
+		reconciler = &ResticRestoreReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).
+				WithStatusSubresource(&backupv1alpha1.ResticRestore{}).Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+		}
+	}
+
+	getDeployment := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(nextcloud), deployment)).To(Succeed())
+		return deployment
+	}
+
+	nextcloudPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "nextcloud-7d9f", Namespace: "cloud", Labels: map[string]string{"app": "nextcloud"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+
+	It("should scale the workload down and wait for its pods to terminate", func() {
+		pod := nextcloudPod()
+		newReconciler(restore, nextcloud, pod)
+
+		message, err := reconciler.stopRestoreWorkload(ctx, restore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(Equal("Waiting for 1 pods of Deployment nextcloud to terminate"))
+		Expect(getDeployment().Spec.Replicas).To(HaveValue(BeEquivalentTo(0)))
+		Expect(getDeployment().Annotations).To(HaveKeyWithValue(scaledDownByAnnotation, "ResticRestore/nextcloud-restore"))
+		Expect(restore.Status.StoppedWorkload).To(HaveValue(HaveField("Name", "nextcloud")))
+
+		Expect(reconciler.Delete(ctx, pod)).To(Succeed())
+		Expect(reconciler.stopRestoreWorkload(ctx, restore)).To(BeEmpty())
+	})
+
+	It("should fail if the pods do not terminate within the timeout", func() {
+		nextcloud.Annotations = map[string]string{
+			scaledDownByAnnotation:       "ResticRestore/nextcloud-restore",
+			scaledDownReplicasAnnotation: "3",
+			scaledDownAtAnnotation:       time.Now().Add(-2 * time.Minute).UTC().Format(time.RFC3339),
+		}
+		newReconciler(restore, nextcloud, nextcloudPod())
+
+		_, err := reconciler.stopRestoreWorkload(ctx, restore)
+		Expect(err).To(MatchError(ContainSubstring("still running after 1m0s")))
+	})
+
+	It("should wait while a backup scaled the workload down", func() {
+		nextcloud.Annotations = map[string]string{scaledDownByAnnotation: "nextcloud/resticbackup-nextcloud-1"}
+		newReconciler(restore, nextcloud)
+
+		Expect(reconciler.stopRestoreWorkload(ctx, restore)).To(ContainSubstring("scaled down for nextcloud/resticbackup-nextcloud-1"))
+		Expect(getDeployment().Spec.Replicas).To(HaveValue(BeEquivalentTo(3)))
+	})
+
+	It("should scale the workload up after the restore failed", func() {
+		nextcloud.Annotations = map[string]string{
+			scaledDownByAnnotation:       "ResticRestore/nextcloud-restore",
+			scaledDownReplicasAnnotation: "3",
+		}
+		nextcloud.Spec.Replicas = int32Ptr(0)
+		restore.Status.Phase = backupv1alpha1.RestorePhaseFailed
+		restore.Status.StoppedWorkload = &backupv1alpha1.WorkloadReference{Kind: "Deployment", Name: "nextcloud"}
+		newReconciler(restore, nextcloud)
+		DeferCleanup(deleteRestoreMetrics, client.ObjectKeyFromObject(restore))
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(restore)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getDeployment().Spec.Replicas).To(HaveValue(BeEquivalentTo(3)))
+		Expect(getDeployment().Annotations).To(BeEmpty())
+
+		updated := &backupv1alpha1.ResticRestore{}
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(restore), updated)).To(Succeed())
+		Expect(updated.Status.StoppedWorkload).To(BeNil())
+	})
+
+	It("should leave workloads scaled down by backups alone", func() {
+		nextcloud.Annotations = map[string]string{scaledDownByAnnotation: "nextcloud/resticbackup-nextcloud-1"}
+		nextcloud.Spec.Replicas = int32Ptr(0)
+		newReconciler(restore, nextcloud)
+
+		Expect(reconciler.startRestoreWorkload(ctx, restore)).To(Succeed())
+		Expect(getDeployment().Spec.Replicas).To(HaveValue(BeEquivalentTo(0)))
+	})
+})
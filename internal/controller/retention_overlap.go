@@ -0,0 +1,229 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+const (
+	// defaultJobDurationEstimate is assumed for jobs without a recorded duration.
+	defaultJobDurationEstimate = 30 * time.Minute
+	// overlapCheckPeriod is the period of upcoming retention runs checked for overlaps,
+	// covering weekly schedules.
+	overlapCheckPeriod = 8 * 24 * time.Hour
+	// minOverlapCheckRuns is the number of upcoming retention runs checked at least,
+	// covering monthly schedules.
+	minOverlapCheckRuns = 3
+	// maxOverlapCheckRuns limits the runs checked of frequent schedules.
+	maxOverlapCheckRuns = 1000
+	// scheduleOffsetStep is the step in which retention schedules are moved.
+	scheduleOffsetStep = 15
+	// retentionLockWait is how long retention waits for backups to release the
+	// repository with the Wait overlap policy.
+	retentionLockWait = "1h"
+	// retentionScheduleLockWait is how long retention otherwise waits for the repository,
+	// e.g. while another schedule of the policy runs forget or prune.
+	retentionScheduleLockWait = "30m"
+)
+
+// backupWindow is the schedule and estimated duration of a backup.
+type backupWindow struct {
+	name     string
+	schedule cron.Schedule
+	location *time.Location
+	duration time.Duration
+}
+
+// overlaps reports whether the backup runs during one of the next retention runs of
+// schedule, each taking duration.
+func (w backupWindow) overlaps(schedule cron.Schedule, duration time.Duration, now time.Time) bool {
+	run := now
+	for i := 0; i < maxOverlapCheckRuns; i++ {
+		run = schedule.Next(run)
+		if run.IsZero() || (i >= minOverlapCheckRuns && run.After(now.Add(overlapCheckPeriod))) {
+			return false
+		}
+		// The first backup starting after run-w.duration is still running at run
+		start := w.schedule.Next(run.Add(-w.duration).In(w.location))
+		if !start.IsZero() && start.Before(run.Add(duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// repositoryBackupWindows returns the backup windows of the scheduled backups of a repository.
+func (r *GlobalRetentionPolicyReconciler) repositoryBackupWindows(ctx context.Context, repository *backupv1alpha1.ResticRepository) ([]backupWindow, error) {
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := r.List(ctx, backups); err != nil {
+		return nil, fmt.Errorf("failed to list ResticBackups: %w", err)
+	}
+
+	var windows []backupWindow
+	for i := range backups.Items {
+		backup := &backups.Items[i]
+		if backup.Spec.Suspend || !referencesRepository(backup.Spec.RepositoryRef, backup.Namespace, repository) {
+			continue
+		}
+		schedule, err := scheduleParser.Parse(backup.Spec.Schedule)
+		if err != nil {
+			continue
+		}
+
+		window := backupWindow{
+			name:     backup.Namespace + "/" + backup.Name,
+			schedule: schedule,
+			location: time.UTC,
+			duration: defaultJobDurationEstimate,
+		}
+		if backup.Spec.Timezone != "" {
+			if location, err := time.LoadLocation(backup.Spec.Timezone); err == nil {
+				window.location = location
+			}
+		}
+		if backup.Status.LastBackup != nil {
+			if duration, err := time.ParseDuration(backup.Status.LastBackup.Duration); err == nil && duration > 0 {
+				window.duration = duration
+			}
+		}
+		windows = append(windows, window)
+	}
+
+	slices.SortFunc(windows, func(a, b backupWindow) int { return strings.Compare(a.name, b.name) })
+	return windows, nil
+}
+
+// updateScheduleOverlaps records the retention schedules of the policy running at the same
+// time as backups of the repository. With the Offset policy, an overlapping schedule is moved
+// to a later time of the same day without backups.
+func (r *GlobalRetentionPolicyReconciler) updateScheduleOverlaps(ctx context.Context, policy *backupv1alpha1.GlobalRetentionPolicy,
+	repository *backupv1alpha1.ResticRepository) error {
+	windows, err := r.repositoryBackupWindows(ctx, repository)
+	if err != nil {
+		return err
+	}
+
+	duration := defaultJobDurationEstimate
+	if d, err := time.ParseDuration(policy.Status.LastRunDuration); err == nil && d > 0 {
+		duration = d
+	}
+
+	now := time.Now()
+	var overlaps []backupv1alpha1.RetentionScheduleOverlap
+	for _, s := range retentionSchedules(policy) {
+		schedule, err := scheduleParser.Parse(s.configured)
+		if err != nil {
+			continue
+		}
+
+		var backups []string
+		for _, window := range windows {
+			if window.overlaps(schedule, duration, now) {
+				backups = append(backups, window.name)
+			}
+		}
+		if len(backups) == 0 {
+			continue
+		}
+
+		overlap := backupv1alpha1.RetentionScheduleOverlap{Schedule: s.configured, OverlappingBackups: backups}
+		if policy.Spec.BackupOverlapPolicy == backupv1alpha1.BackupOverlapOffset {
+			overlap.AdjustedSchedule = offsetSchedule(s.configured, duration, windows, now)
+		}
+		overlaps = append(overlaps, overlap)
+	}
+
+	policy.Status.ScheduleOverlaps = overlaps
+	setScheduleOverlapCondition(policy)
+	return nil
+}
+
+// offsetSchedule moves a schedule with a fixed minute and hour in steps of
+// scheduleOffsetStep minutes until no backup overlaps. It returns an empty string if the
+// schedule cannot be moved within the same day.
+func offsetSchedule(spec string, duration time.Duration, windows []backupWindow, now time.Time) string {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return ""
+	}
+	minute, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return ""
+	}
+	hour, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return ""
+	}
+
+	for start := hour*60 + minute + scheduleOffsetStep; start < 24*60; start += scheduleOffsetStep {
+		candidate := fmt.Sprintf("%d %d %s", start%60, start/60, strings.Join(fields[2:], " "))
+		schedule, err := scheduleParser.Parse(candidate)
+		if err != nil {
+			return ""
+		}
+		if !slices.ContainsFunc(windows, func(w backupWindow) bool { return w.overlaps(schedule, duration, now) }) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// setScheduleOverlapCondition sets the ScheduleOverlap condition from the recorded overlaps.
+func setScheduleOverlapCondition(policy *backupv1alpha1.GlobalRetentionPolicy) {
+	if len(policy.Status.ScheduleOverlaps) == 0 {
+		conditions.SetCondition(&policy.Status.Conditions, conditions.NewCondition(backupv1alpha1.ConditionScheduleOverlap,
+			metav1.ConditionFalse, "NoOverlap", "No backups of the repository run at the same time"))
+		return
+	}
+
+	var backups []string
+	for _, overlap := range policy.Status.ScheduleOverlaps {
+		for _, backup := range overlap.OverlappingBackups {
+			if !slices.Contains(backups, backup) {
+				backups = append(backups, backup)
+			}
+		}
+	}
+
+	reason, action := "OverlapIgnored", "retention may fail on locked repository"
+	switch policy.Spec.BackupOverlapPolicy {
+	case backupv1alpha1.BackupOverlapOffset:
+		reason, action = "ScheduleOffset", "retention schedule moved"
+		for _, overlap := range policy.Status.ScheduleOverlaps {
+			if overlap.AdjustedSchedule == "" {
+				reason, action = "NoOffsetFound", fmt.Sprintf("no time without backups found for schedule %q", overlap.Schedule)
+				break
+			}
+		}
+	case backupv1alpha1.BackupOverlapWait:
+		reason, action = "WaitingForBackups", fmt.Sprintf("retention waits up to %s for backups", retentionLockWait)
+	}
+	conditions.SetCondition(&policy.Status.Conditions, conditions.NewCondition(backupv1alpha1.ConditionScheduleOverlap,
+		metav1.ConditionTrue, reason, fmt.Sprintf("Retention overlaps with backups %s: %s", strings.Join(backups, ", "), action)))
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+)
+
+var _ = Describe("Retention overlap detection", func() {
+	var now time.Time
+
+	parse := func(spec string) cron.Schedule {
+		schedule, err := cron.ParseStandard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		return schedule
+	}
+
+	nightlyBackup := func() backupWindow {
+		return backupWindow{name: "media/db", schedule: parse("0 2 * * *"), location: time.UTC, duration: time.Hour}
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	})
+
+	Context("backupWindow.overlaps", func() {
+		It("should detect retention runs during a backup", func() {
+			Expect(nightlyBackup().overlaps(parse("30 2 * * *"), 30*time.Minute, now)).To(BeTrue())
+			Expect(nightlyBackup().overlaps(parse("45 1 * * *"), 30*time.Minute, now)).To(BeTrue())
+			Expect(nightlyBackup().overlaps(parse("0 3 * * *"), 30*time.Minute, now)).To(BeFalse())
+		})
+
+		It("should check weekly retention runs", func() {
+			weekly := backupWindow{name: "media/db", schedule: parse("0 2 * * 0"), location: time.UTC, duration: time.Hour}
+			Expect(weekly.overlaps(parse("30 2 * * *"), 30*time.Minute, now)).To(BeTrue())
+		})
+
+		It("should interpret backup schedules in their timezone", func() {
+			berlin, err := time.LoadLocation("Europe/Berlin")
+			Expect(err).NotTo(HaveOccurred())
+			window := nightlyBackup()
+			window.location = berlin
+
+			// 02:00 in Berlin is 01:00 UTC in winter
+			Expect(window.overlaps(parse("30 1 * * *"), 30*time.Minute, now)).To(BeTrue())
+			Expect(window.overlaps(parse("30 2 * * *"), 30*time.Minute, now)).To(BeFalse())
+		})
+	})
+
+	Context("offsetSchedule", func() {
+		It("should move the schedule after the backup", func() {
+			Expect(offsetSchedule("30 2 * * *", 30*time.Minute, []backupWindow{nightlyBackup()}, now)).To(Equal("0 3 * * *"))
+		})
+
+		It("should keep the other schedule fields", func() {
+			Expect(offsetSchedule("30 2 * * 0", 30*time.Minute, []backupWindow{nightlyBackup()}, now)).To(Equal("0 3 * * 0"))
+		})
+
+		It("should not move schedules without a fixed time", func() {
+			Expect(offsetSchedule("*/15 * * * *", 30*time.Minute, []backupWindow{nightlyBackup()}, now)).To(BeEmpty())
+			Expect(offsetSchedule("@daily", 30*time.Minute, []backupWindow{nightlyBackup()}, now)).To(BeEmpty())
+		})
+
+		It("should not move schedules past midnight", func() {
+			late := backupWindow{name: "media/db", schedule: parse("0 23 * * *"), location: time.UTC, duration: 2 * time.Hour}
+			Expect(offsetSchedule("30 23 * * *", 30*time.Minute, []backupWindow{late}, now)).To(BeEmpty())
+		})
+	})
+
+	Context("updateScheduleOverlaps", func() {
+		var (
+			policy     *backupv1alpha1.GlobalRetentionPolicy
+			repository *backupv1alpha1.ResticRepository
+			reconciler *GlobalRetentionPolicyReconciler
+		)
+
+		BeforeEach(func() {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			keepLast := int32(7)
+			repository = &backupv1alpha1.ResticRepository{ObjectMeta: metav1.ObjectMeta{Name: "nas", Namespace: "backup"}}
+			policy = &backupv1alpha1.GlobalRetentionPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "daily", Namespace: "backup"},
+				Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas"},
+					Schedule:      "30 2 * * *",
+					Policies: []backupv1alpha1.RetentionPolicyEntry{
+						{Retention: backupv1alpha1.RetentionPolicy{KeepLast: &keepLast}},
+					},
+				},
+			}
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas", Namespace: "backup"},
+					Schedule:      "0 2 * * *",
+				},
+				Status: backupv1alpha1.ResticBackupStatus{
+					LastBackup: &backupv1alpha1.BackupRunStatus{Duration: "45m"},
+				},
+			}
+			other := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "media"},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "s3", Namespace: "backup"},
+					Schedule:      "30 2 * * *",
+				},
+			}
+
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(repository, backup, other).Build()
+			reconciler = &GlobalRetentionPolicyReconciler{Client: c}
+		})
+
+		It("should report overlapping backups of the repository", func() {
+			Expect(reconciler.updateScheduleOverlaps(context.Background(), policy, repository)).To(Succeed())
+
+			Expect(policy.Status.ScheduleOverlaps).To(ConsistOf(backupv1alpha1.RetentionScheduleOverlap{
+				Schedule:           "30 2 * * *",
+				OverlappingBackups: []string{"media/db"},
+			}))
+			condition := conditions.GetCondition(policy.Status.Conditions, backupv1alpha1.ConditionScheduleOverlap)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal("OverlapIgnored"))
+			Expect(retentionSchedules(policy)[0].schedule).To(Equal("30 2 * * *"))
+		})
+
+		It("should move the schedule with the Offset policy", func() {
+			policy.Spec.BackupOverlapPolicy = backupv1alpha1.BackupOverlapOffset
+
+			Expect(reconciler.updateScheduleOverlaps(context.Background(), policy, repository)).To(Succeed())
+			Expect(policy.Status.ScheduleOverlaps[0].AdjustedSchedule).To(Equal("45 2 * * *"))
+			Expect(conditions.GetCondition(policy.Status.Conditions, backupv1alpha1.ConditionScheduleOverlap).Reason).To(Equal("ScheduleOffset"))
+
+			schedules := retentionSchedules(policy)
+			Expect(schedules[0].schedule).To(Equal("45 2 * * *"))
+			Expect(schedules[0].name).To(Equal("globalretention-daily"))
+
+			// The moved schedule is not reported as overlapping again
+			Expect(reconciler.updateScheduleOverlaps(context.Background(), policy, repository)).To(Succeed())
+			Expect(retentionSchedules(policy)[0].schedule).To(Equal("45 2 * * *"))
+		})
+
+		It("should wait for backups with the Wait policy", func() {
+			policy.Spec.BackupOverlapPolicy = backupv1alpha1.BackupOverlapWait
+
+			Expect(reconciler.updateScheduleOverlaps(context.Background(), policy, repository)).To(Succeed())
+			Expect(conditions.GetCondition(policy.Status.Conditions, backupv1alpha1.ConditionScheduleOverlap).Reason).To(Equal("WaitingForBackups"))
+
+			script := reconciler.buildRetentionScript(policy, nil)
+			Expect(script).To(ContainSubstring("restic forget '--retry-lock' '1h'"))
+		})
+
+		It("should check schedule descriptors", func() {
+			policy.Spec.Schedule = "@hourly"
+
+			Expect(reconciler.updateScheduleOverlaps(context.Background(), policy, repository)).To(Succeed())
+			Expect(policy.Status.ScheduleOverlaps).To(ConsistOf(HaveField("Schedule", "@hourly")))
+		})
+
+		It("should clear the condition without overlaps", func() {
+			policy.Spec.Schedule = "0 5 * * *"
+
+			Expect(reconciler.updateScheduleOverlaps(context.Background(), policy, repository)).To(Succeed())
+			Expect(policy.Status.ScheduleOverlaps).To(BeEmpty())
+			Expect(conditions.IsConditionFalse(policy.Status.Conditions, backupv1alpha1.ConditionScheduleOverlap)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,289 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// maxSimulatedRuns bounds the number of scheduled runs simulated per backup.
+const maxSimulatedRuns = 100000
+
+// withinPattern matches the components of a keepWithin duration like "1y6m".
+var withinPattern = regexp.MustCompile(`([0-9]+)([ymdh])`)
+
+// retentionTolerance returns the difference between the expected and the actual snapshot
+// count that is still reported as OK, to allow for running and retried backups.
+func retentionTolerance(expected int) int {
+	return max(2, expected/5)
+}
+
+// buildRetentionReport compares the snapshots of each backup of the repository with the
+// number of snapshots its schedule and retention policy should keep.
+func buildRetentionReport(ctx context.Context, c client.Reader, repository *backupv1alpha1.ResticRepository,
+	snapshots []restic.Snapshot, now time.Time) (*backupv1alpha1.RetentionReport, error) {
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := c.List(ctx, backups); err != nil {
+		return nil, fmt.Errorf("failed to list ResticBackups: %w", err)
+	}
+
+	report := &backupv1alpha1.RetentionReport{LastUpdated: &metav1.Time{Time: now}}
+	for i := range backups.Items {
+		backup := &backups.Items[i]
+		if !referencesRepository(backup.Spec.RepositoryRef, backup.Namespace, repository) {
+			continue
+		}
+		report.Backups = append(report.Backups, backupRetentionReport(backup, repository, snapshots, now))
+	}
+	slices.SortFunc(report.Backups, func(a, b backupv1alpha1.BackupRetentionReport) int {
+		return strings.Compare(a.Backup, b.Backup)
+	})
+	return report, nil
+}
+
+// backupRetentionReport classifies the snapshot count of a backup.
+func backupRetentionReport(backup *backupv1alpha1.ResticBackup, repository *backupv1alpha1.ResticRepository,
+	snapshots []restic.Snapshot, now time.Time) backupv1alpha1.BackupRetentionReport {
+	entry := backupv1alpha1.BackupRetentionReport{
+		Backup: backup.Namespace + "/" + backup.Name,
+		Status: backupv1alpha1.RetentionReportUnknown,
+	}
+
+	tag := normalizeTags(backup, []string{backupTag(backup)})[0]
+	var newest time.Time
+	for _, snapshot := range snapshots {
+		if !slices.Contains(snapshot.Tags, tag) {
+			continue
+		}
+		entry.ActualSnapshots++
+		if snapshot.Time.After(newest) {
+			newest = snapshot.Time
+		}
+	}
+	if !newest.IsZero() {
+		entry.NewestSnapshot = &metav1.Time{Time: newest}
+	}
+
+	retention := effectiveRetention(backup, repository)
+	switch {
+	case backup.Spec.Suspend:
+		entry.Message = "Backup is suspended"
+		return entry
+	case retention == nil:
+		entry.Message = "Backup has no retention policy"
+		return entry
+	}
+
+	expected, err := expectedSnapshots(backup, retention.Policy, now)
+	if err != nil {
+		entry.Message = err.Error()
+		return entry
+	}
+	entry.ExpectedSnapshots = int32Ptr(int32(expected))
+
+	actual := int(entry.ActualSnapshots)
+	tolerance := retentionTolerance(expected)
+	switch {
+	case actual > expected+tolerance:
+		entry.Status = backupv1alpha1.RetentionReportOverRetention
+		entry.Message = fmt.Sprintf("%d snapshots, the schedule and retention policy keep %d: forget may not be running", actual, expected)
+	case actual < expected-tolerance:
+		entry.Status = backupv1alpha1.RetentionReportUnderRetention
+		entry.Message = fmt.Sprintf("%d snapshots, the schedule and retention policy keep %d: backups may be missing", actual, expected)
+	default:
+		entry.Status = backupv1alpha1.RetentionReportOK
+	}
+	return entry
+}
+
+// expectedSnapshots simulates the scheduled runs of a backup since its creation and
+// returns the number of snapshots the retention policy keeps of them. Only the runs
+// within the retention horizon are simulated.
+func expectedSnapshots(backup *backupv1alpha1.ResticBackup, policy *backupv1alpha1.RetentionPolicy, now time.Time) (int, error) {
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	schedule, err := parser.Parse(backup.Spec.Schedule)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schedule: %w", err)
+	}
+	location := time.UTC
+	if backup.Spec.Timezone != "" {
+		if location, err = time.LoadLocation(backup.Spec.Timezone); err != nil {
+			return 0, fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+
+	start := backup.CreationTimestamp.Time
+	if interval, ok := scheduleInterval(backup.Spec.Schedule, now); ok {
+		if horizon := retentionHorizon(policy, interval, now); horizon > 0 && now.Add(-horizon).After(start) {
+			start = now.Add(-horizon)
+		}
+	}
+	if start.IsZero() {
+		return 0, fmt.Errorf("backup has no creation time")
+	}
+
+	var runs []time.Time
+	for next := schedule.Next(start.In(location)); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		if len(runs) == maxSimulatedRuns {
+			return 0, fmt.Errorf("schedule runs too often to simulate its retention")
+		}
+		runs = append(runs, next)
+	}
+	slices.Reverse(runs)
+	return keptSnapshots(runs, policy), nil
+}
+
+// retentionHorizon returns how far back snapshots can be kept by the retention policy for
+// a schedule with the given interval, or 0 if the policy keeps all snapshots. A bucket
+// rule like keepDaily keeps snapshots of distinct days, so a schedule running less often
+// than the bucket stretches the horizon.
+func retentionHorizon(policy *backupv1alpha1.RetentionPolicy, interval time.Duration, now time.Time) time.Duration {
+	day := 24 * time.Hour
+	var horizon time.Duration
+	for _, rule := range []struct {
+		count  *int32
+		bucket time.Duration
+	}{
+		{policy.KeepLast, interval},
+		{policy.KeepHourly, time.Hour},
+		{policy.KeepDaily, day},
+		{policy.KeepWeekly, 7 * day},
+		{policy.KeepMonthly, 31 * day},
+		{policy.KeepYearly, 366 * day},
+	} {
+		if count := int32Value(rule.count); count > 0 {
+			horizon = max(horizon, time.Duration(count+2)*max(rule.bucket, interval))
+		}
+	}
+	for _, within := range []string{
+		policy.KeepWithin, policy.KeepWithinHourly, policy.KeepWithinDaily,
+		policy.KeepWithinWeekly, policy.KeepWithinMonthly, policy.KeepWithinYearly,
+	} {
+		if within != "" {
+			horizon = max(horizon, now.Sub(withinStart(now, within))+2*interval)
+		}
+	}
+	return horizon
+}
+
+// retentionBucket tracks a keep rule while applying a retention policy.
+type retentionBucket struct {
+	count  int
+	within string
+	key    func(t time.Time, nr int) int
+	last   int
+}
+
+// keptSnapshots applies the retention policy to snapshot times sorted newest first the way
+// restic forget does and returns the number of kept snapshots.
+func keptSnapshots(times []time.Time, policy *backupv1alpha1.RetentionPolicy) int {
+	hourly := func(t time.Time, _ int) int { return t.Year()*1000000 + int(t.Month())*10000 + t.Day()*100 + t.Hour() }
+	daily := func(t time.Time, _ int) int { return t.Year()*10000 + int(t.Month())*100 + t.Day() }
+	weekly := func(t time.Time, _ int) int {
+		year, week := t.ISOWeek()
+		return year*100 + week
+	}
+	monthly := func(t time.Time, _ int) int { return t.Year()*100 + int(t.Month()) }
+	yearly := func(t time.Time, _ int) int { return t.Year() }
+
+	buckets := []*retentionBucket{
+		{count: int32Value(policy.KeepLast), key: func(_ time.Time, nr int) int { return nr }},
+		{count: int32Value(policy.KeepHourly), key: hourly},
+		{count: int32Value(policy.KeepDaily), key: daily},
+		{count: int32Value(policy.KeepWeekly), key: weekly},
+		{count: int32Value(policy.KeepMonthly), key: monthly},
+		{count: int32Value(policy.KeepYearly), key: yearly},
+		{count: -1, within: policy.KeepWithinHourly, key: hourly},
+		{count: -1, within: policy.KeepWithinDaily, key: daily},
+		{count: -1, within: policy.KeepWithinWeekly, key: weekly},
+		{count: -1, within: policy.KeepWithinMonthly, key: monthly},
+		{count: -1, within: policy.KeepWithinYearly, key: yearly},
+	}
+	active := policy.KeepWithin != ""
+	for _, bucket := range buckets {
+		bucket.last = -1
+		if bucket.count > 0 || bucket.within != "" {
+			active = true
+		}
+	}
+	// restic forget doesn't remove snapshots without a policy
+	if !active || len(times) == 0 {
+		return len(times)
+	}
+
+	latest := times[0]
+	kept := 0
+	for nr, t := range times {
+		t = t.UTC()
+		keep := policy.KeepWithin != "" && t.After(withinStart(latest, policy.KeepWithin))
+		for _, bucket := range buckets {
+			if bucket.within != "" {
+				if !t.After(withinStart(latest, bucket.within)) {
+					continue
+				}
+			} else if bucket.count <= 0 {
+				continue
+			}
+			if key := bucket.key(t, nr); key != bucket.last || nr == len(times)-1 {
+				keep = true
+				bucket.last = key
+				if bucket.count > 0 {
+					bucket.count--
+				}
+			}
+		}
+		if keep {
+			kept++
+		}
+	}
+	return kept
+}
+
+// withinStart returns the start of a keepWithin duration like "1y6m" ending at latest.
+func withinStart(latest time.Time, within string) time.Time {
+	var years, months, days, hours int
+	for _, match := range withinPattern.FindAllStringSubmatch(within, -1) {
+		value, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		switch match[2] {
+		case "y":
+			years += value
+		case "m":
+			months += value
+		case "d":
+			days += value
+		case "h":
+			hours += value
+		}
+	}
+	return latest.AddDate(-years, -months, -days).Add(-time.Duration(hours) * time.Hour)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+var _ = Describe("Retention report", func() {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	hourlyRuns := func(count int) []time.Time {
+		runs := make([]time.Time, 0, count)
+		for i := range count {
+			runs = append(runs, now.Add(-time.Duration(i)*time.Hour))
+		}
+		return runs
+	}
+
+	It("should apply the retention policy like restic forget", func() {
+		runs := hourlyRuns(240)
+		Expect(keptSnapshots(runs, &backupv1alpha1.RetentionPolicy{})).To(Equal(240))
+		Expect(keptSnapshots(runs, &backupv1alpha1.RetentionPolicy{KeepLast: int32Ptr(3), KeepDaily: int32Ptr(7)})).To(Equal(9))
+		Expect(keptSnapshots(runs, &backupv1alpha1.RetentionPolicy{KeepWithin: "2d"})).To(Equal(48))
+		Expect(keptSnapshots(runs, &backupv1alpha1.RetentionPolicy{KeepWithinDaily: "3d"})).To(Equal(4))
+	})
+
+	It("should simulate the scheduled runs within the retention horizon", func() {
+		backup := &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.AddDate(-1, 0, 0))},
+			Spec:       backupv1alpha1.ResticBackupSpec{Schedule: "0 2 * * 0"},
+		}
+		expected, err := expectedSnapshots(backup, &backupv1alpha1.RetentionPolicy{KeepDaily: int32Ptr(7)}, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expected).To(Equal(7))
+
+		backup.CreationTimestamp = metav1.NewTime(now.AddDate(0, 0, -2))
+		backup.Spec.Schedule = "0 2 * * *"
+		expected, err = expectedSnapshots(backup, &backupv1alpha1.RetentionPolicy{KeepDaily: int32Ptr(7)}, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expected).To(Equal(2))
+
+		backup.Spec.Timezone = "Mars/Olympus"
+		_, err = expectedSnapshots(backup, &backupv1alpha1.RetentionPolicy{KeepDaily: int32Ptr(7)}, now)
+		Expect(err).To(MatchError(ContainSubstring("invalid timezone")))
+	})
+
+	It("should flag over- and under-retention per backup", func() {
+		repository := &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "nas", Namespace: "backup"},
+		}
+		newBackup := func(name, repository string, retention *backupv1alpha1.RetentionConfig) *backupv1alpha1.ResticBackup {
+			return &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              name,
+					Namespace:         "media",
+					CreationTimestamp: metav1.NewTime(now.AddDate(0, 0, -30)),
+				},
+				Spec: backupv1alpha1.ResticBackupSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: repository, Namespace: "backup"},
+					Schedule:      "0 2 * * *",
+					Retention:     retention,
+				},
+			}
+		}
+		retention := &backupv1alpha1.RetentionConfig{
+			Enabled: true,
+			Policy:  &backupv1alpha1.RetentionPolicy{KeepDaily: int32Ptr(7)},
+		}
+
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(
+			newBackup("ok", "nas", retention),
+			newBackup("stale", "nas", retention),
+			newBackup("failing", "nas", retention),
+			newBackup("manual", "nas", nil),
+			newBackup("other", "s3", retention),
+		).Build()
+
+		var snapshots []restic.Snapshot
+		addSnapshots := func(backup string, days int) {
+			for i := range days {
+				snapshots = append(snapshots, restic.Snapshot{
+					Time: time.Date(2026, 3, 10-i, 2, 1, 0, 0, time.UTC),
+					Tags: []string{"backup=media/" + backup},
+				})
+			}
+		}
+		addSnapshots("ok", 7)
+		addSnapshots("stale", 30)
+		addSnapshots("failing", 1)
+		addSnapshots("manual", 30)
+
+		report, err := buildRetentionReport(context.Background(), c, repository, snapshots, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.LastUpdated.Time).To(Equal(now))
+		Expect(report.Backups).To(HaveLen(4))
+
+		byName := map[string]backupv1alpha1.BackupRetentionReport{}
+		for _, entry := range report.Backups {
+			byName[entry.Backup] = entry
+		}
+		Expect(byName["media/ok"]).To(And(
+			HaveField("Status", backupv1alpha1.RetentionReportOK),
+			HaveField("ExpectedSnapshots", HaveValue(BeEquivalentTo(7))),
+			HaveField("ActualSnapshots", BeEquivalentTo(7)),
+			HaveField("NewestSnapshot.Time", Equal(time.Date(2026, 3, 10, 2, 1, 0, 0, time.UTC))),
+		))
+		Expect(byName["media/stale"].Status).To(Equal(backupv1alpha1.RetentionReportOverRetention))
+		Expect(byName["media/stale"].Message).To(ContainSubstring("forget may not be running"))
+		Expect(byName["media/failing"].Status).To(Equal(backupv1alpha1.RetentionReportUnderRetention))
+		Expect(byName["media/failing"].Message).To(ContainSubstring("backups may be missing"))
+		Expect(byName["media/manual"]).To(And(
+			HaveField("Status", backupv1alpha1.RetentionReportUnknown),
+			HaveField("ExpectedSnapshots", BeNil()),
+			HaveField("ActualSnapshots", BeEquivalentTo(30)),
+		))
+	})
+})
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// recordRetentionRuns records the retention jobs that finished after the last recorded
+// run in LastRun, LastRunResult and LastRunDuration, in the order they finished. The end
+// of the log of a failed job is recorded in LastRunFailureMessage and attached to the
+// RetentionFailed event. After a finished job, the cached snapshots of the repository
+// are dropped, as they may list forgotten snapshots.
+func (r *GlobalRetentionPolicyReconciler) recordRetentionRuns(ctx context.Context, policy *backupv1alpha1.GlobalRetentionPolicy, repository *backupv1alpha1.ResticRepository) error {
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs, client.InNamespace(policy.Namespace), client.MatchingLabels{retentionPolicyLabel: policy.Name}); err != nil {
+		return fmt.Errorf("failed to list retention jobs: %w", err)
+	}
+
+	var since time.Time
+	if policy.Status.LastRun != nil {
+		since = policy.Status.LastRun.Time
+	}
+	finished := finishedJobsSince(jobs.Items, since)
+	if len(finished) > 0 {
+		r.SnapshotCache.Invalidate(client.ObjectKeyFromObject(repository))
+	}
+	for _, job := range finished {
+		_, succeeded, finishedAt := jobFinished(&job)
+		lastRun := metav1.NewTime(finishedAt)
+		policy.Status.LastRun = &lastRun
+		policy.Status.LastRunDuration = ""
+		if job.Status.StartTime != nil {
+			policy.Status.LastRunDuration = finishedAt.Sub(job.Status.StartTime.Time).Round(time.Second).String()
+		}
+
+		if succeeded {
+			policy.Status.LastRunResult = backupResultSucceeded
+			policy.Status.LastRunFailureMessage = ""
+			continue
+		}
+
+		logs, err := jobFailureLogs(ctx, r.apiReader(), r.PodLogs, &job)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to read retention job logs", "job", job.Name)
+		}
+		policy.Status.LastRunResult = backupResultFailed
+		policy.Status.LastRunFailureMessage = logs
+		r.Recorder.Event(policy, corev1.EventTypeWarning, "RetentionFailed", withJobLogs(fmt.Sprintf("Retention job %s failed", job.Name), logs))
+	}
+	return nil
+}
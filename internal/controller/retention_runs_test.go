@@ -0,0 +1,126 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Retention runs", func() {
+	var (
+		policy     *backupv1alpha1.GlobalRetentionPolicy
+		repository *backupv1alpha1.ResticRepository
+		recorder   *record.FakeRecorder
+	)
+
+	now := time.Date(2024, 1, 15, 12, 30, 0, 0, time.UTC)
+
+	retentionJob := func(name string, succeeded bool, at time.Time) *batchv1.Job {
+		job := finishedJob(name, succeeded, at)
+		job.Namespace = "backup-system"
+		job.Labels = map[string]string{retentionPolicyLabel: policy.Name}
+		startTime := metav1.NewTime(at.Add(-2 * time.Minute))
+		job.Status.StartTime = &startTime
+		return &job
+	}
+
+	newReconciler := func(logs string, objects ...client.Object) *GlobalRetentionPolicyReconciler {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		recorder = record.NewFakeRecorder(10)
+		return &GlobalRetentionPolicyReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build(),
+			Recorder: recorder,
+			PodLogs:  &fakePodLogReader{logs: logs},
+		}
+	}
+
+	BeforeEach(func() {
+		policy = &backupv1alpha1.GlobalRetentionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "monthly", Namespace: "backup-system"},
+		}
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "backup-system"},
+		}
+	})
+
+	It("should record the last finished retention job", func() {
+		r := newReconciler("", retentionJob("monthly-1", false, now.Add(-time.Hour)), retentionJob("monthly-2", true, now))
+		policy.Status.LastRunFailureMessage = "Fatal: repository is already locked"
+		Expect(r.recordRetentionRuns(context.Background(), policy, repository)).To(Succeed())
+
+		Expect(policy.Status.LastRun.Time).To(BeTemporally("==", now))
+		Expect(policy.Status.LastRunResult).To(Equal(backupResultSucceeded))
+		Expect(policy.Status.LastRunDuration).To(Equal("2m0s"))
+		Expect(policy.Status.LastRunFailureMessage).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(ContainSubstring("Retention job monthly-1 failed")))
+	})
+
+	It("should record the log of a failed retention job", func() {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "monthly-2-abcde",
+				Namespace: "backup-system",
+				Labels:    map[string]string{batchv1.JobNameLabel: "monthly-2"},
+			},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "restic",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}},
+			}}},
+		}
+		lastRun := metav1.NewTime(now.Add(-time.Hour))
+		policy.Status.LastRun = &lastRun
+		r := newReconciler("Fatal: repository is already locked\n", retentionJob("monthly-1", true, lastRun.Time), retentionJob("monthly-2", false, now), pod)
+		Expect(r.recordRetentionRuns(context.Background(), policy, repository)).To(Succeed())
+
+		Expect(policy.Status.LastRun.Time).To(BeTemporally("==", now))
+		Expect(policy.Status.LastRunResult).To(Equal(backupResultFailed))
+		Expect(policy.Status.LastRunFailureMessage).To(Equal("Fatal: repository is already locked"))
+		Expect(recorder.Events).To(Receive(Equal(
+			"Warning RetentionFailed Retention job monthly-2 failed:\nFatal: repository is already locked")))
+		Expect(recorder.Events).NotTo(Receive())
+	})
+
+	It("should drop the cached snapshots once a retention job finished", func() {
+		r := newReconciler("", retentionJob("monthly-1", true, now))
+		r.SnapshotCache = NewSnapshotCache(r.Client)
+		key := client.ObjectKeyFromObject(repository)
+		r.SnapshotCache.store(key, nil, now)
+
+		Expect(r.recordRetentionRuns(context.Background(), policy, repository)).To(Succeed())
+		Expect(r.SnapshotCache.entries).NotTo(HaveKey(key))
+
+		// Runs recorded before don't drop the cache again
+		r.SnapshotCache.store(key, nil, now)
+		Expect(r.recordRetentionRuns(context.Background(), policy, repository)).To(Succeed())
+		Expect(r.SnapshotCache.entries).To(HaveKey(key))
+	})
+})
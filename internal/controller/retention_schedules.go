@@ -0,0 +1,87 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// retentionSchedule is a group of retention policy entries run by one CronJob.
+type retentionSchedule struct {
+	// name is the name of the CronJob.
+	name string
+	// schedule is the cron schedule of the CronJob.
+	schedule string
+	// configured is the schedule as configured, before an offset to avoid backups.
+	configured string
+	// entries are the indexes of the entries in the policy.
+	entries []int
+	// prune runs prune after the entries.
+	prune bool
+	// waitForBackups waits for backups to release the repository.
+	waitForBackups bool
+}
+
+// retentionSchedules groups the entries of a GlobalRetentionPolicy by schedule. The first
+// group runs on the schedule of the policy, including prune, and always exists. Each other
+// schedule gets its own group, named after a hash of the schedule so that the CronJob
+// keeps its name when entries are reordered. Schedules moved to avoid backups, as recorded
+// in the status, are applied.
+func retentionSchedules(policy *backupv1alpha1.GlobalRetentionPolicy) []retentionSchedule {
+	schedules := []retentionSchedule{{
+		name:     fmt.Sprintf("globalretention-%s", policy.Name),
+		schedule: policy.Spec.Schedule,
+		prune:    policy.Spec.Prune,
+	}}
+	groups := map[string]int{policy.Spec.Schedule: 0}
+
+	for i, entry := range policy.Spec.Policies {
+		schedule := entry.Schedule
+		if schedule == "" {
+			schedule = policy.Spec.Schedule
+		}
+		group, ok := groups[schedule]
+		if !ok {
+			hash := fnv.New32a()
+			_, _ = hash.Write([]byte(schedule))
+			group = len(schedules)
+			groups[schedule] = group
+			schedules = append(schedules, retentionSchedule{
+				name:     fmt.Sprintf("globalretention-%s-%08x", policy.Name, hash.Sum32()),
+				schedule: schedule,
+			})
+		}
+		schedules[group].entries = append(schedules[group].entries, i)
+	}
+
+	for i := range schedules {
+		schedules[i].configured = schedules[i].schedule
+		schedules[i].waitForBackups = policy.Spec.BackupOverlapPolicy == backupv1alpha1.BackupOverlapWait
+		if policy.Spec.BackupOverlapPolicy != backupv1alpha1.BackupOverlapOffset {
+			continue
+		}
+		for _, overlap := range policy.Status.ScheduleOverlaps {
+			if overlap.Schedule == schedules[i].configured && overlap.AdjustedSchedule != "" {
+				schedules[i].schedule = overlap.AdjustedSchedule
+			}
+		}
+	}
+	return schedules
+}
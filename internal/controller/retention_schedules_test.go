@@ -0,0 +1,204 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Retention schedules", func() {
+	var policy *backupv1alpha1.GlobalRetentionPolicy
+
+	BeforeEach(func() {
+		keepLast := int32(24)
+		keepMonthly := int32(12)
+		policy = &backupv1alpha1.GlobalRetentionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "tiers", Namespace: "backup", UID: "tiers-uid"},
+			Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "nas"},
+				Schedule:      "0 3 * * *",
+				Prune:         true,
+				Policies: []backupv1alpha1.RetentionPolicyEntry{
+					{
+						Selector:  backupv1alpha1.RetentionSelector{Tags: []string{"hourly"}},
+						Retention: backupv1alpha1.RetentionPolicy{KeepLast: &keepLast},
+						Schedule:  "0 * * * *",
+					},
+					{
+						Selector:  backupv1alpha1.RetentionSelector{Tags: []string{"daily"}},
+						Retention: backupv1alpha1.RetentionPolicy{KeepLast: &keepLast},
+					},
+					{
+						Selector:  backupv1alpha1.RetentionSelector{Tags: []string{"archive"}},
+						Retention: backupv1alpha1.RetentionPolicy{KeepMonthly: &keepMonthly},
+						Schedule:  "0 4 1 * *",
+					},
+				},
+			},
+		}
+	})
+
+	Context("retentionSchedules", func() {
+		It("should group the entries by schedule", func() {
+			schedules := retentionSchedules(policy)
+			Expect(schedules).To(HaveLen(3))
+
+			Expect(schedules[0].name).To(Equal("globalretention-tiers"))
+			Expect(schedules[0].schedule).To(Equal("0 3 * * *"))
+			Expect(schedules[0].entries).To(Equal([]int{1}))
+			Expect(schedules[0].prune).To(BeTrue())
+
+			Expect(schedules[1].name).To(HavePrefix("globalretention-tiers-"))
+			Expect(schedules[1].schedule).To(Equal("0 * * * *"))
+			Expect(schedules[1].entries).To(Equal([]int{0}))
+			Expect(schedules[1].prune).To(BeFalse())
+
+			Expect(schedules[2].schedule).To(Equal("0 4 1 * *"))
+			Expect(schedules[2].entries).To(Equal([]int{2}))
+		})
+
+		It("should keep CronJob names when entries are reordered", func() {
+			names := map[string]string{}
+			for _, s := range retentionSchedules(policy) {
+				names[s.schedule] = s.name
+			}
+
+			entries := policy.Spec.Policies
+			entries[0], entries[2] = entries[2], entries[0]
+			for _, s := range retentionSchedules(policy) {
+				Expect(s.name).To(Equal(names[s.schedule]))
+			}
+		})
+
+		It("should run entries with the schedule of the policy in the policy CronJob", func() {
+			policy.Spec.Policies[0].Schedule = policy.Spec.Schedule
+			policy.Spec.Policies[2].Schedule = ""
+
+			schedules := retentionSchedules(policy)
+			Expect(schedules).To(HaveLen(1))
+			Expect(schedules[0].entries).To(Equal([]int{0, 1, 2}))
+		})
+	})
+
+	Context("buildScheduleScript", func() {
+		It("should only forget the entries of the schedule", func() {
+			reconciler := &GlobalRetentionPolicyReconciler{}
+			schedules := retentionSchedules(policy)
+
+			script := reconciler.buildScheduleScript(policy, schedules[1], nil)
+			Expect(script).To(ContainSubstring("echo 'Executing policy 1'"))
+			Expect(script).To(ContainSubstring("--tag hourly"))
+			Expect(script).NotTo(ContainSubstring("--tag daily"))
+			Expect(script).NotTo(ContainSubstring("restic prune"))
+
+			script = reconciler.buildRetentionScript(policy, nil)
+			Expect(script).To(ContainSubstring("echo 'Executing policy 2'"))
+			Expect(script).NotTo(ContainSubstring("--tag hourly"))
+			Expect(script).To(ContainSubstring("restic prune"))
+		})
+
+		It("should wait for the other schedules to release the repository", func() {
+			reconciler := &GlobalRetentionPolicyReconciler{}
+			for _, schedule := range retentionSchedules(policy) {
+				script := reconciler.buildScheduleScript(policy, schedule, nil)
+				Expect(script).To(ContainSubstring("restic forget '--retry-lock' '30m'"))
+			}
+			Expect(reconciler.buildRetentionScript(policy, nil)).To(ContainSubstring("restic prune '--retry-lock' '30m'"))
+		})
+	})
+
+	Context("reconcileCronJob", func() {
+		var (
+			ctx        context.Context
+			c          client.Client
+			reconciler *GlobalRetentionPolicyReconciler
+			repository *backupv1alpha1.ResticRepository
+		)
+
+		BeforeEach(func() {
+			ctx = context.Background()
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			repository = &backupv1alpha1.ResticRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: "nas", Namespace: "backup"},
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					RepositoryURL: "rest:http://nas:8000/",
+				},
+			}
+			c = fake.NewClientBuilder().WithScheme(testScheme).WithObjects(policy, repository).Build()
+			reconciler = &GlobalRetentionPolicyReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(10)}
+		})
+
+		listCronJobs := func() map[string]string {
+			cronJobs := &batchv1.CronJobList{}
+			Expect(c.List(ctx, cronJobs, client.InNamespace("backup"))).To(Succeed())
+			schedules := map[string]string{}
+			for _, cronJob := range cronJobs.Items {
+				schedules[cronJob.Name] = cronJob.Spec.Schedule
+			}
+			return schedules
+		}
+
+		It("should create one CronJob per schedule", func() {
+			Expect(reconciler.reconcileCronJob(ctx, policy, repository)).To(Succeed())
+
+			cronJobs := listCronJobs()
+			Expect(cronJobs).To(HaveLen(3))
+			Expect(cronJobs).To(HaveKeyWithValue("globalretention-tiers", "0 3 * * *"))
+			Expect(policy.Status.CronJobRef.Name).To(Equal("globalretention-tiers"))
+			Expect(policy.Status.EntryCronJobRefs).To(HaveLen(2))
+		})
+
+		It("should delete the CronJobs of removed schedules", func() {
+			Expect(reconciler.reconcileCronJob(ctx, policy, repository)).To(Succeed())
+
+			policy.Spec.Policies[0].Schedule = ""
+			policy.Spec.Policies[2].Schedule = ""
+			Expect(reconciler.reconcileCronJob(ctx, policy, repository)).To(Succeed())
+
+			Expect(listCronJobs()).To(Equal(map[string]string{"globalretention-tiers": "0 3 * * *"}))
+			Expect(policy.Status.EntryCronJobRefs).To(BeEmpty())
+		})
+	})
+
+	Context("calculateNextRun", func() {
+		It("should return the earliest run of all schedules", func() {
+			reconciler := &GlobalRetentionPolicyReconciler{}
+			policy.Spec.Schedule = "0 0 1 1 *"
+
+			nextRun := reconciler.calculateNextRun(policy)
+			Expect(nextRun).NotTo(BeNil())
+			Expect(nextRun.Minute()).To(Equal(0))
+			Expect(time.Until(nextRun.Time)).To(BeNumerically("<=", time.Hour))
+		})
+	})
+})
@@ -0,0 +1,128 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// updateSelectorCoverage sets the UnmatchedSelectors condition listing the policy
+// entries whose selector matches none of the snapshots of the repository, e.g. after
+// the tag of a backup was renamed and retention silently stopped applying. The
+// snapshots are served by the snapshot cache or listed by the executor; without either
+// the check is skipped.
+func (r *GlobalRetentionPolicyReconciler) updateSelectorCoverage(ctx context.Context, policy *backupv1alpha1.GlobalRetentionPolicy,
+	repository *backupv1alpha1.ResticRepository) error {
+	var snapshots []restic.Snapshot
+	switch {
+	case r.SnapshotCache != nil:
+		var err error
+		if snapshots, err = r.SnapshotCache.Snapshots(ctx, repository); err != nil {
+			return err
+		}
+	case r.Executor != nil:
+		creds, err := repositoryCredentials(ctx, r.Client, repository)
+		if err != nil {
+			return err
+		}
+		if snapshots, err = r.Executor.Snapshots(ctx, creds); err != nil {
+			return fmt.Errorf("failed to list snapshots: %w", err)
+		}
+	default:
+		return nil
+	}
+
+	if len(snapshots) == 0 {
+		conditions.SetCondition(&policy.Status.Conditions, conditions.NewCondition(backupv1alpha1.ConditionUnmatchedSelectors,
+			metav1.ConditionFalse, "NoSnapshots", "The repository has no snapshots yet"))
+		return nil
+	}
+
+	var unmatched []string
+	for i, entry := range policy.Spec.Policies {
+		if !slices.ContainsFunc(snapshots, func(s restic.Snapshot) bool { return retentionSelectorMatches(&s, &entry.Selector) }) {
+			unmatched = append(unmatched, fmt.Sprintf("policy %d (%s)", i+1, describeRetentionSelector(&entry.Selector)))
+		}
+	}
+	if len(unmatched) == 0 {
+		conditions.SetCondition(&policy.Status.Conditions, conditions.NewCondition(backupv1alpha1.ConditionUnmatchedSelectors,
+			metav1.ConditionFalse, "AllSelectorsMatch", "Every selector matches snapshots of the repository"))
+		return nil
+	}
+
+	message := fmt.Sprintf("Selectors match no snapshots of the repository: %s", strings.Join(unmatched, ", "))
+	previous := conditions.GetCondition(policy.Status.Conditions, backupv1alpha1.ConditionUnmatchedSelectors)
+	if previous == nil || previous.Status != metav1.ConditionTrue || previous.Message != message {
+		r.Recorder.Event(policy, corev1.EventTypeWarning, "UnmatchedSelectors", message)
+	}
+	conditions.SetCondition(&policy.Status.Conditions, conditions.NewCondition(backupv1alpha1.ConditionUnmatchedSelectors,
+		metav1.ConditionTrue, "SelectorsMatchNoSnapshots", message))
+	return nil
+}
+
+// retentionSelectorMatches reports whether restic forget selects the snapshot with the
+// selector. Like the --tag flags of restic, a snapshot matches if it has all tags of any
+// of the comma-separated tag lists.
+func retentionSelectorMatches(snapshot *restic.Snapshot, selector *backupv1alpha1.RetentionSelector) bool {
+	if selector.Hostname != "" && snapshot.Hostname != selector.Hostname {
+		return false
+	}
+	for _, path := range selector.Paths {
+		if !slices.Contains(snapshot.Paths, path) {
+			return false
+		}
+	}
+	if len(selector.Tags) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(selector.Tags, func(list string) bool {
+		for _, tag := range strings.Split(list, ",") {
+			if !slices.Contains(snapshot.Tags, tag) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// describeRetentionSelector returns the filters of a selector for messages.
+func describeRetentionSelector(selector *backupv1alpha1.RetentionSelector) string {
+	var filters []string
+	if len(selector.Tags) > 0 {
+		filters = append(filters, "tags "+strings.Join(selector.Tags, " | "))
+	}
+	if selector.Hostname != "" {
+		filters = append(filters, "hostname "+selector.Hostname)
+	}
+	if len(selector.Paths) > 0 {
+		filters = append(filters, "paths "+strings.Join(selector.Paths, " "))
+	}
+	if len(filters) == 0 {
+		return "all snapshots"
+	}
+	return strings.Join(filters, ", ")
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+	"github.com/madic-creates/restic-backup-operator/internal/restic/fakerestic"
+)
+
+var _ = Describe("Retention selector coverage", func() {
+	snapshot := restic.Snapshot{ID: "a1", Hostname: "web", Tags: []string{"app", "daily"}, Paths: []string{"/data"}}
+
+	It("should match snapshots like restic forget", func() {
+		Expect(retentionSelectorMatches(&snapshot, &backupv1alpha1.RetentionSelector{})).To(BeTrue())
+		Expect(retentionSelectorMatches(&snapshot, &backupv1alpha1.RetentionSelector{Tags: []string{"app,daily"}})).To(BeTrue())
+		Expect(retentionSelectorMatches(&snapshot, &backupv1alpha1.RetentionSelector{Tags: []string{"app,weekly"}})).To(BeFalse())
+		Expect(retentionSelectorMatches(&snapshot, &backupv1alpha1.RetentionSelector{Tags: []string{"weekly", "daily"}})).To(BeTrue())
+		Expect(retentionSelectorMatches(&snapshot, &backupv1alpha1.RetentionSelector{Tags: []string{"app"}, Hostname: "db"})).To(BeFalse())
+		Expect(retentionSelectorMatches(&snapshot, &backupv1alpha1.RetentionSelector{Paths: []string{"/data", "/config"}})).To(BeFalse())
+	})
+
+	Context("updateSelectorCoverage", func() {
+		var (
+			repository *backupv1alpha1.ResticRepository
+			policy     *backupv1alpha1.GlobalRetentionPolicy
+			executor   *snapshotsExecutor
+			recorder   *record.FakeRecorder
+			reconciler *GlobalRetentionPolicyReconciler
+		)
+
+		BeforeEach(func() {
+			testScheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+			Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			repository = &backupv1alpha1.ResticRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "backup"},
+				Spec: backupv1alpha1.ResticRepositorySpec{
+					RepositoryURL:        "s3:s3.example.com/bucket",
+					CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "creds"},
+				},
+			}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "backup"},
+				Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+			}
+			policy = &backupv1alpha1.GlobalRetentionPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "retention", Namespace: "backup"},
+				Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+					Policies: []backupv1alpha1.RetentionPolicyEntry{
+						{Selector: backupv1alpha1.RetentionSelector{Tags: []string{"app"}}},
+						{Selector: backupv1alpha1.RetentionSelector{Tags: []string{"emby-config"}, Hostname: "web"}},
+					},
+				},
+			}
+
+			executor = &snapshotsExecutor{snapshots: []restic.Snapshot{snapshot}}
+			cache := NewSnapshotCache(fake.NewClientBuilder().WithScheme(testScheme).WithObjects(repository, secret).Build())
+			cache.Executor = executor
+			recorder = record.NewFakeRecorder(10)
+			reconciler = &GlobalRetentionPolicyReconciler{Client: cache.Client, Recorder: recorder, SnapshotCache: cache}
+		})
+
+		It("should report selectors matching no snapshots once", func() {
+			Expect(reconciler.updateSelectorCoverage(ctx, policy, repository)).To(Succeed())
+
+			condition := conditions.GetCondition(policy.Status.Conditions, backupv1alpha1.ConditionUnmatchedSelectors)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Message).To(ContainSubstring("policy 2 (tags emby-config, hostname web)"))
+			Expect(condition.Message).NotTo(ContainSubstring("policy 1"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("UnmatchedSelectors")))
+
+			Expect(reconciler.updateSelectorCoverage(ctx, policy, repository)).To(Succeed())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should clear the condition once all selectors match", func() {
+			policy.Spec.Policies = policy.Spec.Policies[:1]
+			Expect(reconciler.updateSelectorCoverage(ctx, policy, repository)).To(Succeed())
+			Expect(conditions.IsConditionFalse(policy.Status.Conditions, backupv1alpha1.ConditionUnmatchedSelectors)).To(BeTrue())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should not report selectors of an empty repository", func() {
+			executor.snapshots = nil
+			Expect(reconciler.updateSelectorCoverage(ctx, policy, repository)).To(Succeed())
+			condition := conditions.GetCondition(policy.Status.Conditions, backupv1alpha1.ConditionUnmatchedSelectors)
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("NoSnapshots"))
+		})
+
+		It("should list the snapshots with the executor without a snapshot cache", func() {
+			executor := fakerestic.New().WithSnapshots(snapshot)
+			reconciler.SnapshotCache = nil
+			reconciler.Executor = executor
+			Expect(reconciler.updateSelectorCoverage(ctx, policy, repository)).To(Succeed())
+			Expect(conditions.IsConditionTrue(policy.Status.Conditions, backupv1alpha1.ConditionUnmatchedSelectors)).To(BeTrue())
+			Expect(executor.Calls("Snapshots")).To(ConsistOf(HaveField("Repository", "s3:s3.example.com/bucket")))
+
+			executor.Fail("Snapshots", fakerestic.ErrWrongPassword)
+			Expect(reconciler.updateSelectorCoverage(ctx, policy, repository)).To(MatchError(ContainSubstring("failed to list snapshots")))
+		})
+	})
+})
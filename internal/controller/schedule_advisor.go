@@ -0,0 +1,142 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// minScheduleAdvisorSamples is the number of backup runs needed before a schedule is suggested
+	minScheduleAdvisorSamples = 5
+	// lowDataChangeRate is the rate in bytes/day below which sub-daily schedules are oversized
+	lowDataChangeRate = 1 << 20
+	// highDataChangeRate is the rate in bytes/day above which less than daily schedules are undersized
+	highDataChangeRate = 1 << 30
+)
+
+// dataChangeRate returns the average number of bytes added per day. The data added by
+// the first sample belongs to the interval before the history starts and is ignored.
+// Returns false if the history is too short for a meaningful rate.
+func dataChangeRate(history []backupv1alpha1.BackupDataSample) (float64, bool) {
+	if len(history) < minScheduleAdvisorSamples {
+		return 0, false
+	}
+
+	span := history[len(history)-1].Time.Sub(history[0].Time.Time)
+	if span <= 0 {
+		return 0, false
+	}
+
+	var total int64
+	for _, sample := range history[1:] {
+		total += sample.DataAdded
+	}
+
+	return float64(total) / span.Hours() * 24, true
+}
+
+// scheduleParser parses cron schedules as the CronJob controller and the webhook do,
+// including descriptors like @daily.
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// scheduleInterval returns the average interval between the upcoming runs of a cron
+// schedule, so that schedules like "0 2 * * 1-5" are handled as well.
+func scheduleInterval(schedule string, now time.Time) (time.Duration, bool) {
+	sched, err := scheduleParser.Parse(schedule)
+	if err != nil {
+		return 0, false
+	}
+
+	const runs = 8
+	first := sched.Next(now)
+	last := first
+	for range runs {
+		last = sched.Next(last)
+	}
+	if first.IsZero() || last.IsZero() {
+		return 0, false
+	}
+
+	return last.Sub(first) / runs, true
+}
+
+// recommendSchedule returns an advisory schedule suggestion for the given schedule
+// interval and data-change rate, or an empty string if the schedule fits.
+func recommendSchedule(interval time.Duration, rate float64) string {
+	day := 24 * time.Hour
+	switch {
+	case interval < day && rate < lowDataChangeRate:
+		return fmt.Sprintf("Data changes by %s/day on average; consider a daily schedule instead of running every %s",
+			formatBytes(uint64(rate)), formatInterval(interval))
+	case interval > day && rate > highDataChangeRate:
+		return fmt.Sprintf("Data changes by %s/day on average; consider a daily schedule instead of running every %s to limit the data at risk",
+			formatBytes(uint64(rate)), formatInterval(interval))
+	}
+	return ""
+}
+
+// formatInterval formats a schedule interval in the largest whole unit, e.g. "1h" or "7d".
+func formatInterval(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
+
+// updateScheduleRecommendation analyzes the data-added history of a backup and updates
+// the advisory schedule recommendation in its status and metrics. It never changes
+// the schedule itself.
+func (r *ResticBackupReconciler) updateScheduleRecommendation(backup *backupv1alpha1.ResticBackup) {
+	recommendation := ""
+	rate, hasRate := dataChangeRate(backup.Status.DataAddedHistory)
+	interval, hasInterval := scheduleInterval(backup.Spec.Schedule, time.Now())
+
+	if hasRate && hasInterval {
+		recommendation = recommendSchedule(interval, rate)
+		backupDataChangeRate.WithLabelValues(backup.Namespace, backup.Name).Set(rate)
+		if recommendation != "" {
+			backupScheduleRecommendation.WithLabelValues(backup.Namespace, backup.Name).Set(1)
+		} else {
+			backupScheduleRecommendation.WithLabelValues(backup.Namespace, backup.Name).Set(0)
+		}
+	} else {
+		deleteScheduleAdvisorMetrics(backup)
+	}
+
+	if recommendation != "" && recommendation != backup.Status.ScheduleRecommendation {
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "ScheduleRecommendation", recommendation)
+	}
+	backup.Status.ScheduleRecommendation = recommendation
+}
+
+// deleteScheduleAdvisorMetrics removes the schedule advisor series of a backup.
+func deleteScheduleAdvisorMetrics(backup *backupv1alpha1.ResticBackup) {
+	backupDataChangeRate.DeleteLabelValues(backup.Namespace, backup.Name)
+	backupScheduleRecommendation.DeleteLabelValues(backup.Namespace, backup.Name)
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// dataAddedHistory builds a history of evenly spaced backup runs adding the same amount of data.
+func dataAddedHistory(runs int, every time.Duration, dataAdded int64) []backupv1alpha1.BackupDataSample {
+	start := time.Now().Add(-time.Duration(runs) * every)
+	history := make([]backupv1alpha1.BackupDataSample, 0, runs)
+	for i := range runs {
+		history = append(history, backupv1alpha1.BackupDataSample{
+			Time:      metav1.NewTime(start.Add(time.Duration(i) * every)),
+			DataAdded: dataAdded,
+		})
+	}
+	return history
+}
+
+var _ = Describe("Schedule advisor", func() {
+	Context("dataChangeRate", func() {
+		It("should require a minimum number of samples", func() {
+			_, ok := dataChangeRate(dataAddedHistory(minScheduleAdvisorSamples-1, time.Hour, 1024))
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should return the average bytes per day", func() {
+			rate, ok := dataChangeRate(dataAddedHistory(25, time.Hour, 1024))
+			Expect(ok).To(BeTrue())
+			Expect(rate).To(BeNumerically("~", 24*1024, 1))
+		})
+	})
+
+	Context("scheduleInterval", func() {
+		It("should return the interval of hourly and daily schedules", func() {
+			interval, ok := scheduleInterval("0 * * * *", time.Now())
+			Expect(ok).To(BeTrue())
+			Expect(interval).To(Equal(time.Hour))
+
+			interval, ok = scheduleInterval("@daily", time.Now())
+			Expect(ok).To(BeTrue())
+			Expect(interval).To(Equal(24 * time.Hour))
+		})
+
+		It("should reject invalid schedules", func() {
+			_, ok := scheduleInterval("not a schedule", time.Now())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("recommendSchedule", func() {
+		It("should suggest a daily schedule for hourly backups of rarely changing data", func() {
+			Expect(recommendSchedule(time.Hour, 512*1024)).To(Equal(
+				"Data changes by 512.0 KiB/day on average; consider a daily schedule instead of running every 1h"))
+		})
+
+		It("should suggest a daily schedule for weekly backups of frequently changing data", func() {
+			Expect(recommendSchedule(7*24*time.Hour, 5<<30)).To(ContainSubstring("instead of running every 7d"))
+		})
+
+		It("should not suggest anything if the schedule fits", func() {
+			Expect(recommendSchedule(time.Hour, 100<<20)).To(BeEmpty())
+			Expect(recommendSchedule(24*time.Hour, 512*1024)).To(BeEmpty())
+		})
+	})
+
+	Context("updateScheduleRecommendation", func() {
+		var (
+			reconciler *ResticBackupReconciler
+			recorder   *record.FakeRecorder
+		)
+
+		BeforeEach(func() {
+			recorder = record.NewFakeRecorder(10)
+			reconciler = &ResticBackupReconciler{Recorder: recorder}
+		})
+
+		It("should set the recommendation and emit an event once", func() {
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "advisor", Namespace: "default"},
+				Spec:       backupv1alpha1.ResticBackupSpec{Schedule: "0 * * * *"},
+				Status: backupv1alpha1.ResticBackupStatus{
+					DataAddedHistory: dataAddedHistory(10, time.Hour, 1024),
+				},
+			}
+
+			reconciler.updateScheduleRecommendation(backup)
+			Expect(backup.Status.ScheduleRecommendation).To(ContainSubstring("consider a daily schedule"))
+			Expect(recorder.Events).To(HaveLen(1))
+
+			reconciler.updateScheduleRecommendation(backup)
+			Expect(recorder.Events).To(HaveLen(1))
+		})
+
+		It("should clear the recommendation without enough history", func() {
+			backup := &backupv1alpha1.ResticBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "advisor", Namespace: "default"},
+				Spec:       backupv1alpha1.ResticBackupSpec{Schedule: "0 * * * *"},
+				Status: backupv1alpha1.ResticBackupStatus{
+					ScheduleRecommendation: "outdated",
+				},
+			}
+
+			reconciler.updateScheduleRecommendation(backup)
+			Expect(backup.Status.ScheduleRecommendation).To(BeEmpty())
+			Expect(recorder.Events).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,216 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	defaultSnapshotCacheMaxAge          = 10 * time.Minute
+	defaultSnapshotCacheRefreshInterval = 5 * time.Minute
+	// snapshotCacheIdleTimeout is the time after which repositories whose snapshots
+	// were not requested are no longer refreshed.
+	snapshotCacheIdleTimeout = time.Hour
+)
+
+// SnapshotCache keeps the snapshot metadata of repositories in memory, so resolving a
+// snapshot selector does not list the snapshots of a remote repository on every
+// restore. Snapshots older than MaxAge are listed again on the next request, and the
+// snapshots of repositories in use are refreshed in the background.
+type SnapshotCache struct {
+	client.Client
+	// Executor is optional - if nil, a default executor will be created
+	Executor restic.Executor
+	// MaxAge is the maximum age of cached snapshots. Defaults to 10m.
+	MaxAge time.Duration
+	// RefreshInterval is the interval of the background refresh. Defaults to 5m.
+	RefreshInterval time.Duration
+
+	mu      sync.Mutex
+	entries map[types.NamespacedName]*snapshotCacheEntry
+}
+
+// snapshotCacheEntry holds the snapshots of a repository.
+type snapshotCacheEntry struct {
+	snapshots []restic.Snapshot
+	// fetched is when the snapshots were listed
+	fetched time.Time
+	// used is when the snapshots were last requested
+	used time.Time
+}
+
+// NewSnapshotCache creates an empty snapshot cache.
+func NewSnapshotCache(c client.Client) *SnapshotCache {
+	return &SnapshotCache{
+		Client:  c,
+		entries: map[types.NamespacedName]*snapshotCacheEntry{},
+	}
+}
+
+// Snapshots returns the snapshots of the repository, listing them if they are not
+// cached or older than MaxAge.
+func (c *SnapshotCache) Snapshots(ctx context.Context, repository *backupv1alpha1.ResticRepository) ([]restic.Snapshot, error) {
+	key := client.ObjectKeyFromObject(repository)
+
+	c.mu.Lock()
+	entry := c.entries[key]
+	if entry != nil && time.Since(entry.fetched) < c.maxAge() {
+		entry.used = time.Now()
+		snapshots := entry.snapshots
+		c.mu.Unlock()
+		snapshotCacheRequests.WithLabelValues("hit").Inc()
+		return snapshots, nil
+	}
+	c.mu.Unlock()
+
+	snapshotCacheRequests.WithLabelValues("miss").Inc()
+	return c.Refresh(ctx, repository)
+}
+
+// Refresh lists the snapshots of the repository and caches them.
+func (c *SnapshotCache) Refresh(ctx context.Context, repository *backupv1alpha1.ResticRepository) ([]restic.Snapshot, error) {
+	snapshots, err := c.list(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	c.store(client.ObjectKeyFromObject(repository), snapshots, time.Now())
+	return snapshots, nil
+}
+
+// list lists the snapshots of the repository.
+func (c *SnapshotCache) list(ctx context.Context, repository *backupv1alpha1.ResticRepository) ([]restic.Snapshot, error) {
+	creds, err := repositoryCredentials(ctx, c.Client, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	executor := c.Executor
+	if executor == nil {
+		executor = restic.NewExecutor(log.FromContext(ctx))
+	}
+
+	snapshots, err := executor.Snapshots(ctx, creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// store caches the snapshots of a repository that were last requested at used.
+func (c *SnapshotCache) store(key types.NamespacedName, snapshots []restic.Snapshot, used time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = &snapshotCacheEntry{snapshots: snapshots, fetched: time.Now(), used: used}
+	snapshotCacheEntries.Set(float64(len(c.entries)))
+}
+
+// Invalidate removes the cached snapshots of a repository, e.g. after a backup added a
+// snapshot. It is a no-op on a nil cache.
+func (c *SnapshotCache) Invalidate(key types.NamespacedName) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	snapshotCacheEntries.Set(float64(len(c.entries)))
+}
+
+// Start refreshes the cached snapshots until the context is cancelled. It implements
+// manager.Runnable.
+func (c *SnapshotCache) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.refreshInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.refreshAll(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection ensures that only the leader, which resolves the snapshots of
+// restores, lists snapshots in the background.
+func (c *SnapshotCache) NeedLeaderElection() bool {
+	return true
+}
+
+func (c *SnapshotCache) maxAge() time.Duration {
+	if c.MaxAge > 0 {
+		return c.MaxAge
+	}
+	return defaultSnapshotCacheMaxAge
+}
+
+func (c *SnapshotCache) refreshInterval() time.Duration {
+	if c.RefreshInterval > 0 {
+		return c.RefreshInterval
+	}
+	return defaultSnapshotCacheRefreshInterval
+}
+
+// refreshAll lists the snapshots of the cached repositories again. Repositories that
+// were deleted or not used within the idle timeout are dropped from the cache.
+func (c *SnapshotCache) refreshAll(ctx context.Context) {
+	log := log.FromContext(ctx).WithName("snapshot-cache")
+
+	used := map[types.NamespacedName]time.Time{}
+	c.mu.Lock()
+	for key, entry := range c.entries {
+		if time.Since(entry.used) > snapshotCacheIdleTimeout {
+			delete(c.entries, key)
+			continue
+		}
+		used[key] = entry.used
+	}
+	snapshotCacheEntries.Set(float64(len(c.entries)))
+	c.mu.Unlock()
+
+	for key, lastUsed := range used {
+		repository := &backupv1alpha1.ResticRepository{}
+		if err := c.Get(ctx, key, repository); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				c.Invalidate(key)
+				continue
+			}
+			log.Error(err, "Failed to get repository", "repository", key)
+			continue
+		}
+
+		snapshots, err := c.list(ctx, repository)
+		if err != nil {
+			log.Error(err, "Failed to refresh snapshots", "repository", key)
+			continue
+		}
+		// A background refresh does not count as use
+		c.store(key, snapshots, lastUsed)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// countingSnapshotsExecutor is a snapshotsExecutor counting the snapshot listings.
+type countingSnapshotsExecutor struct {
+	snapshotsExecutor
+	calls int
+}
+
+func (e *countingSnapshotsExecutor) Snapshots(ctx context.Context, creds restic.Credentials) ([]restic.Snapshot, error) {
+	e.calls++
+	return e.snapshotsExecutor.Snapshots(ctx, creds)
+}
+
+var _ = Describe("Snapshot cache", func() {
+	var (
+		repository *backupv1alpha1.ResticRepository
+		executor   *countingSnapshotsExecutor
+		cache      *SnapshotCache
+	)
+
+	BeforeEach(func() {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "backup"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.example.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "creds"},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "backup"},
+			Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+		}
+		executor = &countingSnapshotsExecutor{snapshotsExecutor: snapshotsExecutor{snapshots: []restic.Snapshot{
+			{ID: "old", Hostname: "app", Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}}}
+		cache = NewSnapshotCache(fake.NewClientBuilder().WithScheme(testScheme).WithObjects(repository, secret).Build())
+		cache.Executor = executor
+	})
+
+	It("should serve snapshots from the cache until they expire", func() {
+		_, err := cache.Snapshots(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		snapshots, err := cache.Snapshots(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).To(HaveLen(1))
+		Expect(executor.calls).To(Equal(1))
+
+		cache.MaxAge = time.Nanosecond
+		_, err = cache.Snapshots(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(executor.calls).To(Equal(2))
+	})
+
+	It("should list the snapshots again after invalidation", func() {
+		_, err := cache.Snapshots(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+
+		cache.Invalidate(client.ObjectKeyFromObject(repository))
+		_, err = cache.Snapshots(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(executor.calls).To(Equal(2))
+	})
+
+	It("should refresh used repositories and drop deleted ones", func() {
+		_, err := cache.Snapshots(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+
+		cache.refreshAll(ctx)
+		Expect(executor.calls).To(Equal(2))
+
+		Expect(cache.Delete(ctx, repository)).To(Succeed())
+		cache.refreshAll(ctx)
+		Expect(executor.calls).To(Equal(2))
+		Expect(cache.entries).To(BeEmpty())
+	})
+
+	It("should list the snapshots again if no cached snapshot matches the selector", func() {
+		reconciler := &ResticRestoreReconciler{Client: cache.Client, SnapshotCache: cache}
+		_, err := cache.Snapshots(ctx, repository)
+		Expect(err).NotTo(HaveOccurred())
+
+		executor.snapshots = append(executor.snapshots, restic.Snapshot{
+			ID: "new", Hostname: "other", Time: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		})
+		snapshot, err := reconciler.resolveSnapshotSelector(ctx, repository, &backupv1alpha1.SnapshotSelector{Hostname: "other"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshot.ID).To(Equal("new"))
+		Expect(executor.calls).To(Equal(2))
+	})
+
+	It("should be a no-op to invalidate a nil cache", func() {
+		var nilCache *SnapshotCache
+		Expect(func() { nilCache.Invalidate(client.ObjectKeyFromObject(repository)) }).NotTo(Panic())
+	})
+})
@@ -0,0 +1,90 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// defaultMaxListedSnapshots is the default number of snapshots listed in the repository status.
+const defaultMaxListedSnapshots = 100
+
+// snapshotStatus lists the snapshots of the repository and returns the snapshot list and
+// the retention report of its status. The snapshot list is nil if the snapshot listing
+// is disabled.
+func snapshotStatus(ctx context.Context, c client.Reader, executor restic.Executor, creds restic.Credentials,
+	repository *backupv1alpha1.ResticRepository) ([]backupv1alpha1.SnapshotInfo, *backupv1alpha1.RetentionReport, error) {
+	snapshots, err := executor.Snapshots(ctx, creds)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	report, err := buildRetentionReport(ctx, c, repository, snapshots, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listing := repository.Spec.SnapshotListing
+	if listing == nil {
+		return nil, report, nil
+	}
+	limit := defaultMaxListedSnapshots
+	if listing.MaxSnapshots != nil && *listing.MaxSnapshots > 0 {
+		limit = int(*listing.MaxSnapshots)
+	}
+	return snapshotInfos(snapshots, limit), report, nil
+}
+
+// snapshotInfos converts the newest snapshots, newest first, up to the limit.
+func snapshotInfos(snapshots []restic.Snapshot, limit int) []backupv1alpha1.SnapshotInfo {
+	sorted := slices.Clone(snapshots)
+	slices.SortStableFunc(sorted, func(a, b restic.Snapshot) int {
+		return b.Time.Compare(a.Time)
+	})
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+
+	infos := make([]backupv1alpha1.SnapshotInfo, 0, len(sorted))
+	for _, snapshot := range sorted {
+		info := backupv1alpha1.SnapshotInfo{
+			ID:       snapshot.ShortID,
+			Time:     metav1.NewTime(snapshot.Time),
+			Hostname: snapshot.Hostname,
+			Tags:     snapshot.Tags,
+			Paths:    snapshot.Paths,
+		}
+		if info.ID == "" {
+			info.ID = shortSnapshotID(snapshot.ID)
+		}
+		if summary := snapshot.Summary; summary != nil {
+			info.Size = formatBytes(summary.TotalBytesProcessed)
+			info.FileCount = summary.TotalFilesProcessed
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// snapshotIDPattern matches the snapshot IDs accepted in restores: "latest" or a full or
+// abbreviated snapshot ID. Anything else could be parsed as a flag by restic.
+var snapshotIDPattern = regexp.MustCompile(`^(latest|[0-9a-f]{1,64})$`)
+
+// validateSnapshotIDs checks the snapshot IDs given in the spec of a restore.
+func validateSnapshotIDs(spec *backupv1alpha1.ResticRestoreSpec) error {
+	ids := []string{}
+	if spec.SnapshotID != "" {
+		ids = append(ids, spec.SnapshotID)
+	}
+	if spec.Chain != nil {
+		ids = append(ids, spec.Chain.SnapshotIDs...)
+	}
+	for _, id := range ids {
+		if !snapshotIDPattern.MatchString(id) {
+			return fmt.Errorf("invalid snapshot ID %q, expected latest or a hexadecimal snapshot ID", id)
+		}
+	}
+	return nil
+}
+
+// resolveSnapshotSelector lists the snapshots of the repository and returns the
+// newest one matching the selector. It returns nil if no snapshot matches. With a
+// snapshot cache, the cached snapshots are used and listed again only if none of them
+// matches, so snapshots taken since the last refresh are found.
+func (r *ResticRestoreReconciler) resolveSnapshotSelector(ctx context.Context, repository *backupv1alpha1.ResticRepository, selector *backupv1alpha1.SnapshotSelector) (*restic.Snapshot, error) {
+	if r.SnapshotCache != nil {
+		snapshots, err := r.SnapshotCache.Snapshots(ctx, repository)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot := selectSnapshot(snapshots, selector); snapshot != nil {
+			return snapshot, nil
+		}
+	}
+
+	snapshots, err := r.listSnapshots(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	return selectSnapshot(snapshots, selector), nil
+}
+
+// listSnapshots lists the current snapshots of the repository. With a snapshot cache,
+// the cache is refreshed.
+func (r *ResticRestoreReconciler) listSnapshots(ctx context.Context, repository *backupv1alpha1.ResticRepository) ([]restic.Snapshot, error) {
+	if r.SnapshotCache != nil {
+		return r.SnapshotCache.Refresh(ctx, repository)
+	}
+
+	creds, err := repositoryCredentials(ctx, r.Client, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	executor := r.Executor
+	if executor == nil {
+		executor = restic.NewExecutor(log.FromContext(ctx))
+	}
+
+	snapshots, err := executor.Snapshots(ctx, creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// selectSnapshot returns the newest snapshot matching the hostname, all tags
+// and all paths of the selector and taken before its timestamp.
+func selectSnapshot(snapshots []restic.Snapshot, selector *backupv1alpha1.SnapshotSelector) *restic.Snapshot {
+	var selected *restic.Snapshot
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		if !snapshotMatches(snapshot, selector) {
+			continue
+		}
+		if selected == nil || snapshot.Time.After(selected.Time) {
+			selected = snapshot
+		}
+	}
+	return selected
+}
+
+func snapshotMatches(snapshot *restic.Snapshot, selector *backupv1alpha1.SnapshotSelector) bool {
+	if selector == nil {
+		return true
+	}
+	if selector.Hostname != "" && snapshot.Hostname != selector.Hostname {
+		return false
+	}
+	for _, tag := range selector.Tags {
+		if !slices.Contains(snapshot.Tags, tag) {
+			return false
+		}
+	}
+	for _, path := range selector.Paths {
+		if !slices.Contains(snapshot.Paths, path) {
+			return false
+		}
+	}
+	if selector.Before != nil && !snapshot.Time.Before(selector.Before.Time) {
+		return false
+	}
+	return true
+}
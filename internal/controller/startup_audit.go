@@ -0,0 +1,305 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/conditions"
+	"github.com/madic-creates/restic-backup-operator/internal/features"
+)
+
+// StartupAudit revalidates all CronJobs and Jobs owned by the operator once after
+// startup and fixes drift, e.g. after an upgrade changed the generated pod templates.
+// Reconcilers holding a reference wait for the audit to finish before doing any work.
+type StartupAudit struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Images resolves the default restic image like the reconcilers do.
+	Images *ImageConfig
+	// JobDefaults sets the job defaults like the reconcilers do.
+	JobDefaults *JobDefaults
+	// FeatureGates enables optional capabilities like for the reconcilers.
+	FeatureGates *features.Gate
+	// MaxConcurrentBackups is the cluster-wide backup limit like for the ResticBackup reconciler.
+	MaxConcurrentBackups int
+
+	done chan struct{}
+}
+
+// NewStartupAudit creates a new StartupAudit.
+func NewStartupAudit(c client.Client, scheme *runtime.Scheme) *StartupAudit {
+	return &StartupAudit{
+		Client: c,
+		Scheme: scheme,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start runs the audit. It implements manager.Runnable.
+func (a *StartupAudit) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("startup-audit")
+	defer close(a.done)
+
+	start := time.Now()
+	corrections, err := a.run(ctx)
+	if err != nil {
+		// A failed audit must not block the operator, the regular reconciles
+		// converge the remaining objects.
+		log.Error(err, "Startup audit failed")
+	}
+	log.Info("Startup audit finished", "corrections", corrections, "duration", time.Since(start))
+
+	return nil
+}
+
+// NeedLeaderElection ensures only the leader corrects child objects.
+func (a *StartupAudit) NeedLeaderElection() bool {
+	return true
+}
+
+// Wait blocks until the audit has finished or the context is cancelled.
+// It is a no-op on a nil StartupAudit.
+func (a *StartupAudit) Wait(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *StartupAudit) run(ctx context.Context) (int, error) {
+	corrections := 0
+
+	n, err := a.auditBackupCronJobs(ctx)
+	corrections += n
+	if err != nil {
+		return corrections, err
+	}
+
+	n, err = a.auditRetentionCronJobs(ctx)
+	corrections += n
+	if err != nil {
+		return corrections, err
+	}
+
+	n, err = a.auditCheckCronJobs(ctx)
+	corrections += n
+	if err != nil {
+		return corrections, err
+	}
+
+	n, err = a.auditRestoreJobs(ctx)
+	corrections += n
+	return corrections, err
+}
+
+func (a *StartupAudit) auditBackupCronJobs(ctx context.Context) (int, error) {
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := a.List(ctx, backups); err != nil {
+		return 0, fmt.Errorf("failed to list ResticBackups: %w", err)
+	}
+
+	builder := &ResticBackupReconciler{Client: a.Client, Scheme: a.Scheme, Images: a.Images, JobDefaults: a.JobDefaults,
+		FeatureGates: a.FeatureGates, MaxConcurrentBackups: a.MaxConcurrentBackups}
+	corrections := 0
+	for i := range backups.Items {
+		backup := &backups.Items[i]
+		if !backup.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		// Backups without a ready repository are handled by the regular reconcile
+		repository, err := builder.getRepository(ctx, backup)
+		if err != nil || !conditions.IsConditionTrue(repository.Status.Conditions, "Ready") {
+			continue
+		}
+
+		cronJob, err := builder.buildCronJob(backup, repository)
+		if err != nil {
+			continue
+		}
+		corrected, err := a.correctCronJob(ctx, backup, cronJob)
+		if err != nil {
+			return corrections, err
+		}
+		if corrected {
+			corrections++
+		}
+	}
+
+	return corrections, nil
+}
+
+func (a *StartupAudit) auditRetentionCronJobs(ctx context.Context) (int, error) {
+	policies := &backupv1alpha1.GlobalRetentionPolicyList{}
+	if err := a.List(ctx, policies); err != nil {
+		return 0, fmt.Errorf("failed to list GlobalRetentionPolicies: %w", err)
+	}
+
+	builder := &GlobalRetentionPolicyReconciler{Client: a.Client, Scheme: a.Scheme, Images: a.Images, JobDefaults: a.JobDefaults, FeatureGates: a.FeatureGates}
+	corrections := 0
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if !policy.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		repository, err := builder.getRepository(ctx, policy)
+		if err != nil || !conditions.IsConditionTrue(repository.Status.Conditions, "Ready") {
+			continue
+		}
+
+		for _, schedule := range retentionSchedules(policy) {
+			corrected, err := a.correctCronJob(ctx, policy, builder.buildCronJob(policy, repository, schedule))
+			if err != nil {
+				return corrections, err
+			}
+			if corrected {
+				corrections++
+			}
+		}
+	}
+
+	return corrections, nil
+}
+
+func (a *StartupAudit) auditCheckCronJobs(ctx context.Context) (int, error) {
+	checks := &backupv1alpha1.ResticCheckList{}
+	if err := a.List(ctx, checks); err != nil {
+		return 0, fmt.Errorf("failed to list ResticChecks: %w", err)
+	}
+
+	builder := &ResticCheckReconciler{Client: a.Client, Scheme: a.Scheme, Images: a.Images, JobDefaults: a.JobDefaults, FeatureGates: a.FeatureGates}
+	corrections := 0
+	for i := range checks.Items {
+		check := &checks.Items[i]
+		if !check.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		repository, err := builder.getRepository(ctx, check)
+		if err != nil || !conditions.IsConditionTrue(repository.Status.Conditions, "Ready") {
+			continue
+		}
+
+		corrected, err := a.correctCronJob(ctx, check, builder.buildCronJob(check, repository))
+		if err != nil {
+			return corrections, err
+		}
+		if corrected {
+			corrections++
+		}
+	}
+
+	return corrections, nil
+}
+
+// correctCronJob creates the desired CronJob if it is missing or updates the existing
+// one if its spec drifted. It reports whether a correction was made.
+func (a *StartupAudit) correctCronJob(ctx context.Context, owner client.Object, desired *batchv1.CronJob) (bool, error) {
+	log := log.FromContext(ctx).WithName("startup-audit")
+
+	if err := controllerutil.SetControllerReference(owner, desired, a.Scheme); err != nil {
+		return false, fmt.Errorf("failed to set owner reference: %w", err)
+	}
+
+	existing := &batchv1.CronJob{}
+	err := a.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, existing)
+	if apierrors.IsNotFound(err) {
+		log.Info("Creating missing CronJob", "namespace", desired.Namespace, "name", desired.Name)
+		if err := a.Create(ctx, desired); err != nil {
+			return false, fmt.Errorf("failed to create CronJob %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+		startupAuditCorrections.WithLabelValues("CronJob").Inc()
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get CronJob %s/%s: %w", desired.Namespace, desired.Name, err)
+	}
+
+	// The API server defaults many fields, so only compare what the operator sets
+	if equality.Semantic.DeepDerivative(desired.Spec, existing.Spec) {
+		return false, nil
+	}
+
+	log.Info("Correcting drifted CronJob", "namespace", existing.Namespace, "name", existing.Name)
+	existing.Spec = desired.Spec
+	if err := a.Update(ctx, existing); err != nil {
+		return false, fmt.Errorf("failed to update CronJob %s/%s: %w", existing.Namespace, existing.Name, err)
+	}
+	startupAuditCorrections.WithLabelValues("CronJob").Inc()
+	return true, nil
+}
+
+// auditRestoreJobs removes restore Jobs whose ResticRestore no longer exists. Job pod
+// templates are immutable, so drifted Jobs of existing restores are left to finish.
+func (a *StartupAudit) auditRestoreJobs(ctx context.Context) (int, error) {
+	log := log.FromContext(ctx).WithName("startup-audit")
+
+	jobs := &batchv1.JobList{}
+	if err := a.List(ctx, jobs, client.MatchingLabels{
+		"app.kubernetes.io/managed-by": "restic-backup-operator",
+		"app.kubernetes.io/component":  "restore",
+	}); err != nil {
+		return 0, fmt.Errorf("failed to list restore Jobs: %w", err)
+	}
+
+	corrections := 0
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		restoreName := job.Labels[resticRestoreLabel]
+		if restoreName == "" || !job.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		restore := &backupv1alpha1.ResticRestore{}
+		err := a.Get(ctx, types.NamespacedName{Name: restoreName, Namespace: job.Namespace}, restore)
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return corrections, fmt.Errorf("failed to get ResticRestore %s/%s: %w", job.Namespace, restoreName, err)
+		}
+
+		log.Info("Deleting orphaned restore Job", "namespace", job.Namespace, "name", job.Name)
+		if err := a.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return corrections, fmt.Errorf("failed to delete Job %s/%s: %w", job.Namespace, job.Name, err)
+		}
+		startupAuditCorrections.WithLabelValues("Job").Inc()
+		corrections++
+	}
+
+	return corrections, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("StartupAudit", func() {
+	const (
+		timeout  = time.Second * 10
+		interval = time.Millisecond * 250
+	)
+
+	Context("Wait", func() {
+		It("should not block without a startup audit", func() {
+			var audit *StartupAudit
+			Expect(audit.Wait(context.Background())).To(Succeed())
+		})
+
+		It("should return when the context is cancelled", func() {
+			audit := NewStartupAudit(nil, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Expect(audit.Wait(ctx)).To(MatchError(context.Canceled))
+		})
+	})
+
+	Context("When auditing restore Jobs", Label(envtestLabel), func() {
+		ctx := context.Background()
+
+		It("should delete Jobs whose ResticRestore no longer exists", func() {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "resticrestore-orphaned",
+					Namespace: "default",
+					Labels: map[string]string{
+						"app.kubernetes.io/managed-by":   "restic-backup-operator",
+						"app.kubernetes.io/component":    "restore",
+						"backup.resticbackup.io/restore": "orphaned",
+					},
+				},
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							RestartPolicy: corev1.RestartPolicyNever,
+							Containers:    []corev1.Container{{Name: "restic", Image: "restic"}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, job)).To(Succeed())
+
+			audit := NewStartupAudit(k8sClient, k8sClient.Scheme())
+			Expect(audit.Start(ctx)).To(Succeed())
+			Expect(audit.Wait(ctx)).To(Succeed())
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, &batchv1.Job{})
+				return apierrors.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,396 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	// stateExportTag tags the snapshots of the operator state, which are also taken with
+	// it as hostname.
+	stateExportTag = "operator-state"
+	// stateExportFilename is the file name of the archive in the snapshots.
+	stateExportFilename = "operator-state.yaml.gz"
+	// stateExportSecretName is the Secret holding the latest archive for the export Job.
+	stateExportSecretName = "restic-operator-state"
+	// stateExportedAtAnnotation records the time of the latest export on the Secret.
+	stateExportedAtAnnotation = "backup.resticbackup.io/exported-at"
+	// stateExportMaxSize keeps the archive below the size limit of Secrets.
+	stateExportMaxSize = 1000 * 1024
+
+	defaultStateExportInterval = 24 * time.Hour
+	defaultStateExportKeepLast = 30
+	// stateExportRetryInterval is the wait before retrying a failed export.
+	stateExportRetryInterval = 15 * time.Minute
+	// stateExportJobTTL is how long finished export Jobs are kept.
+	stateExportJobTTL = int32(24 * 60 * 60)
+)
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrepositories;repositorytemplates;resticbackups;clusterbackuppolicies;resticchecks;resticreplications;backupverifications;globalretentionpolicies;resticreferencegrants;resticsnapshotrefs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=secrets,resourceNames=restic-operator-state,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create
+
+// StateExport periodically writes the backup-related resources of the whole cluster
+// into a repository, so the configuration of the operator survives the loss of the
+// cluster. The resources are serialized without status into a gzipped multi-document
+// YAML archive, which a Job stores in the repository as a snapshot tagged
+// operator-state.
+type StateExport struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Repository is the ResticRepository the state is written to.
+	Repository types.NamespacedName
+	// Interval is the time between two exports. Defaults to 24h.
+	Interval time.Duration
+	// KeepLast is the number of exports kept in the repository. Defaults to 30.
+	KeepLast int
+	// Images resolves the default restic image, e.g. to a private mirror.
+	Images *ImageConfig
+	// JobDefaults sets the default active deadline and backoff limit of the export Jobs.
+	JobDefaults *JobDefaults
+}
+
+// NewStateExport creates a state export writing into the repository.
+func NewStateExport(c client.Client, scheme *runtime.Scheme, repository types.NamespacedName) *StateExport {
+	return &StateExport{Client: c, Scheme: scheme, Repository: repository}
+}
+
+// stateExportLists returns the lists of the exported resources. One-time operations
+// (ResticRestore, ResticPrune and NamespaceRestore) are left out, since applying them
+// in a new cluster would run them again.
+func stateExportLists() []client.ObjectList {
+	return []client.ObjectList{
+		&backupv1alpha1.ResticRepositoryList{},
+		&backupv1alpha1.RepositoryTemplateList{},
+		&backupv1alpha1.ResticReferenceGrantList{},
+		&backupv1alpha1.ResticBackupList{},
+		&backupv1alpha1.ClusterBackupPolicyList{},
+		&backupv1alpha1.ResticCheckList{},
+		&backupv1alpha1.ResticReplicationList{},
+		&backupv1alpha1.BackupVerificationList{},
+		&backupv1alpha1.GlobalRetentionPolicyList{},
+		&backupv1alpha1.ResticSnapshotRefList{},
+	}
+}
+
+// Start exports the state once per interval until the context is cancelled. The time
+// of the last export is read from the Secret, so restarts of the operator don't cause
+// additional exports. It implements manager.Runnable.
+func (e *StateExport) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("state-export")
+
+	timer := time.NewTimer(e.untilNextExport(ctx))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			if err := e.Export(ctx); err != nil {
+				log.Error(err, "Failed to export the operator state")
+				timer.Reset(stateExportRetryInterval)
+				continue
+			}
+			timer.Reset(e.interval())
+		}
+	}
+}
+
+// NeedLeaderElection ensures that only the leader exports the state.
+func (e *StateExport) NeedLeaderElection() bool {
+	return true
+}
+
+func (e *StateExport) interval() time.Duration {
+	if e.Interval > 0 {
+		return e.Interval
+	}
+	return defaultStateExportInterval
+}
+
+func (e *StateExport) keepLast() int {
+	if e.KeepLast > 0 {
+		return e.KeepLast
+	}
+	return defaultStateExportKeepLast
+}
+
+// untilNextExport returns the time until the next export is due.
+func (e *StateExport) untilNextExport(ctx context.Context) time.Duration {
+	secret := &corev1.Secret{}
+	if err := e.Get(ctx, types.NamespacedName{Name: stateExportSecretName, Namespace: e.Repository.Namespace}, secret); err != nil {
+		return 0
+	}
+	exportedAt, err := time.Parse(time.RFC3339, secret.Annotations[stateExportedAtAnnotation])
+	if err != nil {
+		return 0
+	}
+	return max(e.interval()-time.Since(exportedAt), 0)
+}
+
+// Export serializes the resources, stores the archive in the Secret and starts a Job
+// writing it into the repository.
+func (e *StateExport) Export(ctx context.Context) error {
+	repository := &backupv1alpha1.ResticRepository{}
+	if err := e.Get(ctx, e.Repository, repository); err != nil {
+		return fmt.Errorf("failed to get repository %s: %w", e.Repository, err)
+	}
+
+	now := time.Now()
+	archive, count, err := e.buildArchive(ctx, now)
+	if err != nil {
+		return err
+	}
+	if len(archive) > stateExportMaxSize {
+		return fmt.Errorf("archive of %d resources has %d bytes, more than the %d bytes a Secret can hold", count, len(archive), stateExportMaxSize)
+	}
+
+	if err := e.storeArchive(ctx, archive, now); err != nil {
+		return err
+	}
+
+	job := e.buildJob(repository, now)
+	if err := e.Create(ctx, job); err != nil {
+		return fmt.Errorf("failed to create state export job: %w", err)
+	}
+	log.FromContext(ctx).Info("Exported operator state", "resources", count, "bytes", len(archive), "job", job.Name)
+	return nil
+}
+
+// buildArchive returns the gzipped YAML documents of the exported resources and their number.
+func (e *StateExport) buildArchive(ctx context.Context, now time.Time) ([]byte, int, error) {
+	var documents [][]byte
+	for _, list := range stateExportLists() {
+		if err := e.List(ctx, list); err != nil {
+			return nil, 0, fmt.Errorf("failed to list %T: %w", list, err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var objects []*unstructured.Unstructured
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok || metav1.GetControllerOf(obj) != nil || !obj.GetDeletionTimestamp().IsZero() {
+				// Resources created by the operator are recreated from their owners
+				continue
+			}
+			exported, err := exportObject(obj, e.Scheme)
+			if err != nil {
+				return nil, 0, err
+			}
+			objects = append(objects, exported)
+		}
+		slices.SortFunc(objects, func(a, b *unstructured.Unstructured) int {
+			return cmp.Or(cmp.Compare(a.GetNamespace(), b.GetNamespace()), cmp.Compare(a.GetName(), b.GetName()))
+		})
+
+		for _, obj := range objects {
+			document, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to serialize %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+			}
+			documents = append(documents, document)
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	fmt.Fprintf(zw, "# Exported by restic-backup-operator at %s: %d resources\n", now.UTC().Format(time.RFC3339), len(documents))
+	for _, document := range documents {
+		fmt.Fprintf(zw, "---\n%s", document)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to compress the state: %w", err)
+	}
+	return buf.Bytes(), len(documents), nil
+}
+
+// exportObject returns the resource without status and the metadata assigned by the
+// cluster, ready to be applied to a new cluster.
+func exportObject(obj client.Object, scheme *runtime.Scheme) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	exported := &unstructured.Unstructured{Object: content}
+	exported.SetGroupVersionKind(gvk)
+	unstructured.RemoveNestedField(exported.Object, "status")
+	exported.SetUID("")
+	exported.SetResourceVersion("")
+	exported.SetGeneration(0)
+	exported.SetCreationTimestamp(metav1.Time{})
+	exported.SetManagedFields(nil)
+	exported.SetFinalizers(nil)
+	exported.SetOwnerReferences(nil)
+
+	annotations := exported.GetAnnotations()
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	exported.SetAnnotations(annotations)
+	return exported, nil
+}
+
+// storeArchive writes the archive into the Secret mounted by the export Job.
+func (e *StateExport) storeArchive(ctx context.Context, archive []byte, now time.Time) error {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: stateExportSecretName, Namespace: e.Repository.Namespace}
+	err := e.Get(ctx, key, secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get state export secret: %w", err)
+	}
+
+	secret.Name = key.Name
+	secret.Namespace = key.Namespace
+	secret.Labels = map[string]string{
+		"app.kubernetes.io/name":       "restic-backup-operator",
+		"app.kubernetes.io/component":  stateExportTag,
+		"app.kubernetes.io/managed-by": "restic-backup-operator",
+	}
+	secret.Annotations = map[string]string{stateExportedAtAnnotation: now.UTC().Format(time.RFC3339)}
+	secret.Data = map[string][]byte{stateExportFilename: archive}
+
+	if apierrors.IsNotFound(err) {
+		if err := e.Create(ctx, secret); err != nil {
+			return fmt.Errorf("failed to create state export secret: %w", err)
+		}
+		return nil
+	}
+	if err := e.Update(ctx, secret); err != nil {
+		return fmt.Errorf("failed to update state export secret: %w", err)
+	}
+	return nil
+}
+
+// buildStateExportScript builds the shell script of the export container: the archive
+// is backed up from stdin and only the newest keepLast exports are kept.
+func buildStateExportScript(options []string, keepLast int) string {
+	backup := restic.NewCommand("backup").WithArgs(options).WithHost(stateExportTag).WithTag(stateExportTag).
+		WithArgs([]string{"--stdin", "--stdin-filename", stateExportFilename})
+	forget := restic.NewCommand("forget").WithArgs(options).WithHost(stateExportTag).WithTag(stateExportTag).WithKeepTag(retentionProtectedTag).WithKeepLast(keepLast)
+	return fmt.Sprintf("set -e\nrestic %s < /state/%s\nrestic %s",
+		shellQuoteArgs(backup.Build()), stateExportFilename, shellQuoteArgs(forget.Build()))
+}
+
+// buildJob builds the Job writing the archive of the Secret into the repository.
+func (e *StateExport) buildJob(repository *backupv1alpha1.ResticRepository, now time.Time) *batchv1.Job {
+	backoffLimit := e.JobDefaults.BackoffLimit(JobOperationBackup, nil)
+	activeDeadline := e.JobDefaults.ActiveDeadlineSeconds(JobOperationBackup, nil)
+	ttl := stateExportJobTTL
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "restic-backup-operator",
+		"app.kubernetes.io/component": stateExportTag,
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", stateExportSecretName, now.Unix()),
+			Namespace: repository.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "restic-backup-operator",
+				"app.kubernetes.io/component":  stateExportTag,
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &activeDeadline,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot: boolPtr(true),
+						RunAsUser:    int64Ptr(65532),
+						FSGroup:      int64Ptr(65532),
+						SeccompProfile: &corev1.SeccompProfile{
+							Type: corev1.SeccompProfileTypeRuntimeDefault,
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "state",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: stateExportSecretName},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:            "restic",
+							Image:           e.Images.Resolve("", nil),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Command:         []string{"/bin/sh", "-c"},
+							Args:            []string{buildStateExportScript(repositoryOptions(repository), e.keepLast())},
+							Env:             repositoryEnvVars(repository),
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "state", MountPath: "/state", ReadOnly: true},
+							},
+							SecurityContext: &corev1.SecurityContext{
+								AllowPrivilegeEscalation: boolPtr(false),
+								ReadOnlyRootFilesystem:   boolPtr(false),
+								RunAsNonRoot:             boolPtr(true),
+								Capabilities: &corev1.Capabilities{
+									Drop: []corev1.Capability{"ALL"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	applyRepositoryCache(&job.Spec.Template.Spec, repository, repository.Namespace)
+	applyRepositoryCredentials(&job.Spec.Template.Spec, repository)
+
+	return job
+}
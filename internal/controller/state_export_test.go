@@ -0,0 +1,202 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("StateExport", func() {
+	var (
+		ctx        context.Context
+		testScheme *runtime.Scheme
+		repository *backupv1alpha1.ResticRepository
+		backup     *backupv1alpha1.ResticBackup
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		testScheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		repository = &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: "offsite", Namespace: "backup-system", UID: "repo-uid"},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.example.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "creds"},
+			},
+			Status: backupv1alpha1.ResticRepositoryStatus{LastIntegrityCheckResult: "passed"},
+		}
+		backup = &backupv1alpha1.ResticBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app-data",
+				Namespace:   "app",
+				Finalizers:  []string{"resticbackup-finalizer"},
+				Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: "{}", "team": "platform"},
+			},
+			Spec: backupv1alpha1.ResticBackupSpec{
+				Schedule:      "0 2 * * *",
+				RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "offsite", Namespace: "backup-system"},
+			},
+		}
+	})
+
+	newExport := func(objects ...client.Object) *StateExport {
+		c := fake.NewClientBuilder().WithScheme(testScheme).
+			WithObjects(append(objects, repository, backup)...).
+			Build()
+		export := NewStateExport(c, testScheme, types.NamespacedName{Namespace: repository.Namespace, Name: repository.Name})
+		export.KeepLast = 7
+		return export
+	}
+
+	readArchive := func(archive []byte) string {
+		zr, err := gzip.NewReader(bytes.NewReader(archive))
+		Expect(err).NotTo(HaveOccurred())
+		content, err := io.ReadAll(zr)
+		Expect(err).NotTo(HaveOccurred())
+		return string(content)
+	}
+
+	It("should export the backup resources without status and cluster metadata", func() {
+		restore := &backupv1alpha1.ResticRestore{ObjectMeta: metav1.ObjectMeta{Name: "one-time", Namespace: "app"}}
+		owned := &backupv1alpha1.ResticSnapshotRef{ObjectMeta: metav1.ObjectMeta{Name: "owned-ref", Namespace: "backup-system"}}
+		Expect(controllerutil.SetControllerReference(repository, owned, testScheme)).To(Succeed())
+		export := newExport(restore, owned)
+
+		archive, count, err := export.buildArchive(ctx, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(2))
+
+		content := readArchive(archive)
+		Expect(content).To(ContainSubstring("kind: ResticRepository"))
+		Expect(content).To(ContainSubstring("kind: ResticBackup"))
+		Expect(content).To(ContainSubstring("apiVersion: backup.resticbackup.io/v1alpha1"))
+		Expect(content).To(ContainSubstring("team: platform"))
+		Expect(content).NotTo(ContainSubstring("one-time"))
+		Expect(content).NotTo(ContainSubstring("owned-ref"))
+		Expect(content).NotTo(ContainSubstring("status:"))
+		Expect(content).NotTo(ContainSubstring("resourceVersion"))
+		Expect(content).NotTo(ContainSubstring("resticbackup-finalizer"))
+		Expect(content).NotTo(ContainSubstring(corev1.LastAppliedConfigAnnotation))
+		Expect(strings.Count(content, "---\n")).To(Equal(2))
+	})
+
+	It("should export repository templates but not the repositories they create", func() {
+		template := &backupv1alpha1.RepositoryTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "per-team", UID: "template-uid"},
+			Spec: backupv1alpha1.RepositoryTemplateSpec{
+				Template: backupv1alpha1.RepositoryTemplateResource{Spec: backupv1alpha1.ResticRepositorySpec{
+					RepositoryURL: "s3:s3.example.com/backups/{{namespace}}",
+				}},
+			},
+		}
+		created := &backupv1alpha1.ResticRepository{ObjectMeta: metav1.ObjectMeta{Name: "per-team-repository", Namespace: "team-a"}}
+		Expect(controllerutil.SetControllerReference(template, created, testScheme)).To(Succeed())
+		export := newExport(template, created)
+
+		archive, count, err := export.buildArchive(ctx, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(3))
+
+		content := readArchive(archive)
+		Expect(content).To(ContainSubstring("kind: RepositoryTemplate"))
+		Expect(content).To(ContainSubstring("s3:s3.example.com/backups/{{namespace}}"))
+		Expect(content).NotTo(ContainSubstring("per-team-repository"))
+	})
+
+	It("should export cluster backup policies but not the backups they create", func() {
+		policy := &backupv1alpha1.ClusterBackupPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "databases", UID: "policy-uid"},
+			Spec: backupv1alpha1.ClusterBackupPolicySpec{
+				PVCSelector: metav1.LabelSelector{MatchLabels: map[string]string{"backup": "daily"}},
+			},
+		}
+		created := &backupv1alpha1.ResticBackup{ObjectMeta: metav1.ObjectMeta{Name: "databases-postgres-data", Namespace: "db"}}
+		Expect(controllerutil.SetControllerReference(policy, created, testScheme)).To(Succeed())
+		export := newExport(policy, created)
+
+		archive, count, err := export.buildArchive(ctx, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(3))
+
+		content := readArchive(archive)
+		Expect(content).To(ContainSubstring("kind: ClusterBackupPolicy"))
+		Expect(content).To(ContainSubstring("backup: daily"))
+		Expect(content).NotTo(ContainSubstring("databases-postgres-data"))
+	})
+
+	It("should store the archive and create the export Job", func() {
+		export := newExport()
+		Expect(export.Export(ctx)).To(Succeed())
+
+		secret := &corev1.Secret{}
+		Expect(export.Get(ctx, types.NamespacedName{Name: stateExportSecretName, Namespace: repository.Namespace}, secret)).To(Succeed())
+		Expect(readArchive(secret.Data[stateExportFilename])).To(ContainSubstring("name: app-data"))
+		Expect(secret.Annotations).To(HaveKey(stateExportedAtAnnotation))
+		Expect(export.untilNextExport(ctx)).To(BeNumerically(">", 23*time.Hour))
+
+		jobs := &batchv1.JobList{}
+		Expect(export.List(ctx, jobs, client.InNamespace(repository.Namespace))).To(Succeed())
+		Expect(jobs.Items).To(HaveLen(1))
+		container := jobs.Items[0].Spec.Template.Spec.Containers[0]
+		Expect(container.Args[0]).To(ContainSubstring("restic 'backup'"))
+		Expect(container.Args[0]).To(ContainSubstring("'--stdin-filename' 'operator-state.yaml.gz'"))
+		Expect(container.Args[0]).To(ContainSubstring("< /state/operator-state.yaml.gz"))
+		Expect(container.Args[0]).To(ContainSubstring("'--keep-last' '7'"))
+		Expect(container.Env).To(ContainElement(HaveField("Name", "RESTIC_REPOSITORY")))
+	})
+
+	It("should replace the archive of the previous export", func() {
+		previous := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: stateExportSecretName, Namespace: repository.Namespace},
+			Data:       map[string][]byte{stateExportFilename: []byte("outdated")},
+		}
+		export := newExport(previous)
+		Expect(export.Export(ctx)).To(Succeed())
+
+		secret := &corev1.Secret{}
+		Expect(export.Get(ctx, client.ObjectKeyFromObject(previous), secret)).To(Succeed())
+		Expect(readArchive(secret.Data[stateExportFilename])).To(ContainSubstring("name: app-data"))
+	})
+
+	It("should fail without the repository", func() {
+		export := newExport()
+		export.Repository.Name = "missing"
+		Expect(export.Export(ctx)).NotTo(Succeed())
+	})
+})
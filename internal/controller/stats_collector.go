@@ -0,0 +1,196 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+const (
+	defaultStatsWorkers   = 2
+	defaultStatsQueueSize = 100
+	defaultStatsCooldown  = 15 * time.Minute
+)
+
+// StatsCollector gathers repository statistics in a pool of background workers and
+// writes them to the repository status, so a slow restic stats of a large repository
+// does not block the reconciles of other repositories.
+type StatsCollector struct {
+	client.Client
+	// Executor is optional - if nil, a default executor will be created
+	Executor restic.Executor
+	// Workers is the number of repositories whose statistics are gathered in parallel. Defaults to 2.
+	Workers int
+	// Cooldown is the minimum time between two collections of the same repository,
+	// also after a failed one. Defaults to 15m.
+	Cooldown time.Duration
+
+	queue chan types.NamespacedName
+	mu    sync.Mutex
+	// queued holds the repositories waiting for or being collected
+	queued map[types.NamespacedName]bool
+	// lastRun holds the start of the last collection per repository
+	lastRun map[types.NamespacedName]time.Time
+}
+
+// NewStatsCollector creates a stats collector with a queue of the given size. Repositories
+// enqueued while the queue is full are skipped until their next reconcile.
+func NewStatsCollector(c client.Client, queueSize int) *StatsCollector {
+	if queueSize <= 0 {
+		queueSize = defaultStatsQueueSize
+	}
+	return &StatsCollector{
+		Client:  c,
+		queue:   make(chan types.NamespacedName, queueSize),
+		queued:  map[types.NamespacedName]bool{},
+		lastRun: map[types.NamespacedName]time.Time{},
+	}
+}
+
+// Enqueue schedules the statistics collection of a repository. It returns false if the
+// repository is already queued, was collected within the cooldown or the queue is full.
+func (c *StatsCollector) Enqueue(key types.NamespacedName) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.queued[key] || time.Since(c.lastRun[key]) < c.cooldown() {
+		return false
+	}
+
+	select {
+	case c.queue <- key:
+		c.queued[key] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// Start runs the workers until the context is cancelled. It implements manager.Runnable.
+func (c *StatsCollector) Start(ctx context.Context) error {
+	workers := c.Workers
+	if workers <= 0 {
+		workers = defaultStatsWorkers
+	}
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case key := <-c.queue:
+					c.collect(ctx, key)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	return nil
+}
+
+// NeedLeaderElection ensures that only the leader updates the repository status.
+func (c *StatsCollector) NeedLeaderElection() bool {
+	return true
+}
+
+func (c *StatsCollector) cooldown() time.Duration {
+	if c.Cooldown > 0 {
+		return c.Cooldown
+	}
+	return defaultStatsCooldown
+}
+
+// collect gathers the statistics of a repository and records them in its status.
+func (c *StatsCollector) collect(ctx context.Context, key types.NamespacedName) {
+	log := log.FromContext(ctx).WithName("stats-collector").WithValues("repository", key)
+
+	c.mu.Lock()
+	c.lastRun[key] = time.Now()
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.queued, key)
+		c.mu.Unlock()
+	}()
+
+	if err := c.updateStatistics(ctx, key); err != nil {
+		log.Error(err, "Failed to collect repository stats")
+		return
+	}
+	log.V(1).Info("Collected repository stats")
+}
+
+func (c *StatsCollector) updateStatistics(ctx context.Context, key types.NamespacedName) error {
+	repository := &backupv1alpha1.ResticRepository{}
+	if err := c.Get(ctx, key, repository); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	creds, err := repositoryCredentials(ctx, c.Client, repository)
+	if err != nil {
+		return err
+	}
+
+	executor := c.Executor
+	if executor == nil {
+		executor = restic.NewExecutor(log.FromContext(ctx))
+	}
+
+	stats, err := executor.Stats(ctx, creds, restic.StatsOptions{Mode: "restore-size"})
+	if err != nil {
+		return fmt.Errorf("failed to get repository stats: %w", err)
+	}
+	updatedAt := time.Now()
+
+	// Keep the previous snapshot list and retention report if the snapshots can't be listed
+	snapshots, report, listErr := snapshotStatus(ctx, c.Client, executor, creds, repository)
+	if listErr != nil {
+		log.FromContext(ctx).Error(listErr, "Failed to list repository snapshots")
+	} else {
+		recordRetentionReportMetrics(key, report)
+	}
+
+	// The reconciler updates the status concurrently, retry on conflicts
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, key, repository); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		setRepositoryStatistics(repository, stats, updatedAt)
+		if listErr == nil {
+			repository.Status.Snapshots = snapshots
+			repository.Status.RetentionReport = report
+		}
+		return c.Status().Update(ctx, repository)
+	})
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// snapshotListingExecutor lists a fixed set of snapshots.
+type snapshotListingExecutor struct {
+	MockExecutor
+	snapshots []restic.Snapshot
+}
+
+func (e *snapshotListingExecutor) Snapshots(_ context.Context, _ restic.Credentials) ([]restic.Snapshot, error) {
+	return e.snapshots, nil
+}
+
+var _ = Describe("Stats collector", func() {
+	key := types.NamespacedName{Name: "repo", Namespace: "backup-system"}
+
+	newClient := func() client.Client {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		repository := &backupv1alpha1.ResticRepository{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: backupv1alpha1.ResticRepositorySpec{
+				RepositoryURL:        "s3:s3.amazonaws.com/bucket",
+				CredentialsSecretRef: backupv1alpha1.SecretKeySelector{Name: "repo-credentials"},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-credentials", Namespace: key.Namespace},
+			Data:       map[string][]byte{"RESTIC_PASSWORD": []byte("secret")},
+		}
+		return fake.NewClientBuilder().WithScheme(testScheme).
+			WithObjects(repository, secret).
+			WithStatusSubresource(&backupv1alpha1.ResticRepository{}).
+			Build()
+	}
+
+	It("should skip repositories that are queued or within the cooldown", func() {
+		collector := NewStatsCollector(newClient(), 1)
+		collector.Executor = &MockExecutor{}
+		Expect(collector.Enqueue(key)).To(BeTrue())
+		Expect(collector.Enqueue(key)).To(BeFalse())
+
+		// The queue is full
+		Expect(collector.Enqueue(types.NamespacedName{Name: "other", Namespace: key.Namespace})).To(BeFalse())
+
+		collector.collect(context.Background(), <-collector.queue)
+		Expect(collector.Enqueue(key)).To(BeFalse())
+
+		collector.lastRun[key] = time.Now().Add(-defaultStatsCooldown)
+		Expect(collector.Enqueue(key)).To(BeTrue())
+	})
+
+	It("should record the statistics in the repository status", func() {
+		c := newClient()
+		collector := NewStatsCollector(c, 10)
+		collector.Executor = &MockExecutor{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = collector.Start(ctx)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		Expect(collector.Enqueue(key)).To(BeTrue())
+		Eventually(func() *backupv1alpha1.RepositoryStatistics {
+			repository := &backupv1alpha1.ResticRepository{}
+			Expect(c.Get(context.Background(), key, repository)).To(Succeed())
+			return repository.Status.Statistics
+		}).ShouldNot(BeNil())
+
+		repository := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(context.Background(), key, repository)).To(Succeed())
+		Expect(repository.Status.Statistics.TotalSize).To(Equal("1.0 KiB"))
+		Expect(repository.Status.Statistics.SnapshotCount).To(Equal(int32(1)))
+	})
+
+	It("should list the newest snapshots in the repository status", func() {
+		c := newClient()
+		repository := &backupv1alpha1.ResticRepository{}
+		Expect(c.Get(context.Background(), key, repository)).To(Succeed())
+		repository.Spec.SnapshotListing = &backupv1alpha1.SnapshotListingConfig{MaxSnapshots: int32Ptr(2)}
+		Expect(c.Update(context.Background(), repository)).To(Succeed())
+
+		day := time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC)
+		collector := NewStatsCollector(c, 10)
+		collector.Executor = &snapshotListingExecutor{snapshots: []restic.Snapshot{
+			{ID: "1111111111", ShortID: "11111111", Time: day, Hostname: "emby"},
+			{ID: "3333333333", ShortID: "33333333", Time: day.Add(48 * time.Hour), Hostname: "emby",
+				Tags: []string{"backup=media/emby"}, Paths: []string{"/backup"},
+				Summary: &restic.SnapshotSummary{TotalFilesProcessed: 12, TotalBytesProcessed: 2048}},
+			{ID: "2222222222", ShortID: "22222222", Time: day.Add(24 * time.Hour), Hostname: "emby"},
+		}}
+		Expect(collector.updateStatistics(context.Background(), key)).To(Succeed())
+
+		Expect(c.Get(context.Background(), key, repository)).To(Succeed())
+		Expect(repository.Status.Snapshots).To(HaveLen(2))
+		Expect(repository.Status.Snapshots[0]).To(And(
+			HaveField("ID", "33333333"),
+			HaveField("Tags", []string{"backup=media/emby"}),
+			HaveField("Size", "2.0 KiB"),
+			HaveField("FileCount", int64(12)),
+		))
+		Expect(repository.Status.Snapshots[1].ID).To(Equal("22222222"))
+		Expect(repository.Status.Snapshots[1].Size).To(BeEmpty())
+		Expect(repository.Status.RetentionReport).NotTo(BeNil())
+		Expect(repository.Status.RetentionReport.LastUpdated).NotTo(BeNil())
+	})
+})
@@ -18,6 +18,7 @@ package controller
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -46,7 +47,11 @@ func (m *MockExecutor) Unlock(_ context.Context, _ restic.Credentials) error {
 	return nil
 }
 
-func (m *MockExecutor) Check(_ context.Context, _ restic.Credentials) (*restic.CheckResult, error) {
+func (m *MockExecutor) CatConfig(_ context.Context, _ restic.Credentials) (*restic.RepositoryConfig, error) {
+	return &restic.RepositoryConfig{Version: 2}, nil
+}
+
+func (m *MockExecutor) Check(_ context.Context, _ restic.Credentials, _ restic.CheckOptions) (*restic.CheckResult, error) {
 	return &restic.CheckResult{Success: true}, nil
 }
 
@@ -78,6 +83,30 @@ func (m *MockExecutor) Prune(_ context.Context, _ restic.Credentials) (*restic.P
 	return &restic.PruneResult{}, nil
 }
 
+func (m *MockExecutor) Dump(_ context.Context, _ restic.Credentials, _ restic.DumpOptions) ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *MockExecutor) Diff(_ context.Context, _ restic.Credentials, _, _ string) (*restic.DiffResult, error) {
+	return &restic.DiffResult{}, nil
+}
+
+func (m *MockExecutor) Ls(_ context.Context, _ restic.Credentials, _, _ string) ([]restic.Node, error) {
+	return []restic.Node{}, nil
+}
+
+func (m *MockExecutor) Find(_ context.Context, _ restic.Credentials, _ string) ([]restic.FindResult, error) {
+	return []restic.FindResult{}, nil
+}
+
+func (m *MockExecutor) Tag(_ context.Context, _ restic.Credentials, _ string, _ restic.TagOptions) error {
+	return nil
+}
+
+func (m *MockExecutor) Copy(_ context.Context, _ restic.Credentials, _ restic.CopyOptions) error {
+	return nil
+}
+
 var (
 	cfg       *rest.Config
 	k8sClient client.Client
@@ -86,16 +115,41 @@ var (
 	cancel    context.CancelFunc
 )
 
+// envtestLabel labels the specs that need the envtest API server.
+const envtestLabel = "envtest"
+
+// envtestAvailable reports whether the envtest binaries or an existing cluster are
+// configured. Without them, only the specs that don't need an API server run.
+func envtestAvailable() bool {
+	if os.Getenv("KUBEBUILDER_ASSETS") != "" || os.Getenv("USE_EXISTING_CLUSTER") == "true" {
+		return true
+	}
+	_, err := os.Stat("/usr/local/kubebuilder/bin")
+	return err == nil
+}
+
 func TestControllers(t *testing.T) {
 	RegisterFailHandler(Fail)
 
-	RunSpecs(t, "Controller Suite")
+	suiteConfig, reporterConfig := GinkgoConfiguration()
+	if !envtestAvailable() {
+		t.Log("envtest binaries not found, skipping the specs labeled " + envtestLabel)
+		if suiteConfig.LabelFilter != "" {
+			suiteConfig.LabelFilter = "(" + suiteConfig.LabelFilter + ") && "
+		}
+		suiteConfig.LabelFilter += "!" + envtestLabel
+	}
+
+	RunSpecs(t, "Controller Suite", suiteConfig, reporterConfig)
 }
 
 var _ = BeforeSuite(func() {
 	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
 	ctx, cancel = context.WithCancel(context.TODO())
+	if !envtestAvailable() {
+		return
+	}
 
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
@@ -140,6 +194,21 @@ var _ = BeforeSuite(func() {
 		Client:   k8sManager.GetClient(),
 		Scheme:   k8sManager.GetScheme(),
 		Recorder: k8sManager.GetEventRecorderFor("resticrestore-controller"),
+		Executor: &MockExecutor{},
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&ResticPruneReconciler{
+		Client:   k8sManager.GetClient(),
+		Scheme:   k8sManager.GetScheme(),
+		Recorder: k8sManager.GetEventRecorderFor("resticprune-controller"),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&ResticCheckReconciler{
+		Client:   k8sManager.GetClient(),
+		Scheme:   k8sManager.GetScheme(),
+		Recorder: k8sManager.GetEventRecorderFor("resticcheck-controller"),
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -150,6 +219,13 @@ var _ = BeforeSuite(func() {
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
+	err = (&NamespaceRestoreReconciler{
+		Client:   k8sManager.GetClient(),
+		Scheme:   k8sManager.GetScheme(),
+		Recorder: k8sManager.GetEventRecorderFor("namespacerestore-controller"),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
 	go func() {
 		defer GinkgoRecover()
 		err = k8sManager.Start(ctx)
@@ -159,6 +235,9 @@ var _ = BeforeSuite(func() {
 
 var _ = AfterSuite(func() {
 	cancel()
+	if testEnv == nil {
+		return
+	}
 	By("tearing down the test environment")
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
@@ -0,0 +1,357 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// populatorLabel links the prime PVC and ResticRestore populating a PVC to the PVC
+	populatorLabel = "backup.resticbackup.io/populated-claim"
+	// populatorPrefix prefixes the names of the prime PVC and ResticRestore of a PVC
+	populatorPrefix = "populate-"
+	// populatedFromAnnotation records the ResticSnapshotRef a PersistentVolume was
+	// populated from
+	populatedFromAnnotation = "backup.resticbackup.io/populated-from"
+	// selectedNodeAnnotation is set by the scheduler on PVCs of WaitForFirstConsumer
+	// storage classes
+	selectedNodeAnnotation = "volume.kubernetes.io/selected-node"
+)
+
+// VolumePopulatorReconciler populates PVCs with a dataSourceRef to a ResticSnapshotRef.
+// It follows the volume populator pattern: the snapshot is restored into a prime PVC with
+// the same spec, whose PersistentVolume is then rebound to the PVC.
+type VolumePopulatorReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticsnapshotrefs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=backup.resticbackup.io,resources=resticrestores,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile populates a PVC referencing a ResticSnapshotRef.
+func (r *VolumePopulatorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, req.NamespacedName, pvc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !isPopulatedClaim(pvc) || !pvc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	// The PVC is bound, remove the prime PVC and the restore
+	if pvc.Spec.VolumeName != "" {
+		return ctrl.Result{}, r.cleanup(ctx, pvc)
+	}
+
+	source := pvc.Spec.DataSourceRef
+	if source.Namespace != nil && *source.Namespace != pvc.Namespace {
+		r.Recorder.Event(pvc, corev1.EventTypeWarning, "PopulationFailed",
+			"ResticSnapshotRefs in other namespaces are not supported, create the ResticSnapshotRef in the namespace of the PVC")
+		return ctrl.Result{}, nil
+	}
+	if pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock {
+		r.Recorder.Event(pvc, corev1.EventTypeWarning, "PopulationFailed",
+			"Restic restores files, Block volumes can't be populated from a ResticSnapshotRef")
+		return ctrl.Result{}, nil
+	}
+
+	snapshotRef := &backupv1alpha1.ResticSnapshotRef{}
+	if err := r.Get(ctx, types.NamespacedName{Name: source.Name, Namespace: pvc.Namespace}, snapshotRef); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(pvc, corev1.EventTypeWarning, "SnapshotRefNotFound",
+			fmt.Sprintf("ResticSnapshotRef %s not found", source.Name))
+		return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+	}
+
+	// With WaitForFirstConsumer, the volume is provisioned on the node the scheduler
+	// selected for the first pod using the PVC
+	selectedNode := pvc.Annotations[selectedNodeAnnotation]
+	if selectedNode == "" {
+		waiting, err := r.waitsForFirstConsumer(ctx, pvc)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if waiting {
+			log.V(1).Info("Waiting for a consumer of the PVC")
+			return ctrl.Result{}, nil
+		}
+	}
+
+	prime, err := r.ensurePrimeClaim(ctx, pvc)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	restore := &backupv1alpha1.ResticRestore{}
+	err = r.Get(ctx, types.NamespacedName{Name: populatorName(pvc), Namespace: pvc.Namespace}, restore)
+	if apierrors.IsNotFound(err) {
+		restore = buildPopulatorRestore(pvc, snapshotRef, selectedNode)
+		if err := controllerutil.SetControllerReference(pvc, restore, r.Scheme); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to set owner reference: %w", err)
+		}
+		if _, err := createOrAdopt(ctx, r.Client, pvc, restore, &backupv1alpha1.ResticRestore{}); err != nil {
+			r.Recorder.Event(pvc, corev1.EventTypeWarning, "PopulationFailed", fmt.Sprintf("Failed to create ResticRestore: %v", err))
+			return ctrl.Result{RequeueAfter: errorRequeueInterval}, nil
+		}
+		r.Recorder.Event(pvc, corev1.EventTypeNormal, "PopulationStarted",
+			fmt.Sprintf("Restoring ResticSnapshotRef %s with ResticRestore %s", snapshotRef.Name, restore.Name))
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch restore.Status.Phase {
+	case backupv1alpha1.RestorePhaseFailed:
+		r.Recorder.Event(pvc, corev1.EventTypeWarning, "PopulationFailed",
+			fmt.Sprintf("ResticRestore %s failed, delete the PVC to retry", restore.Name))
+		return ctrl.Result{}, nil
+	case backupv1alpha1.RestorePhaseCompleted:
+		return ctrl.Result{}, r.rebindVolume(ctx, pvc, prime, snapshotRef)
+	default:
+		return ctrl.Result{}, nil
+	}
+}
+
+// waitsForFirstConsumer reports whether the storage class of the PVC delays provisioning
+// until a pod uses the PVC.
+func (r *VolumePopulatorReconciler) waitsForFirstConsumer(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false, nil
+	}
+	storageClass := &storagev1.StorageClass{}
+	if err := r.Get(ctx, types.NamespacedName{Name: *pvc.Spec.StorageClassName}, storageClass); err != nil {
+		return false, fmt.Errorf("failed to get storage class: %w", err)
+	}
+	return storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer, nil
+}
+
+// ensurePrimeClaim creates the prime PVC the snapshot is restored into, with the spec of
+// the PVC without its data source.
+func (r *VolumePopulatorReconciler) ensurePrimeClaim(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
+	prime := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, types.NamespacedName{Name: populatorName(pvc), Namespace: pvc.Namespace}, prime)
+	if err == nil {
+		return prime, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	prime = buildPrimeClaim(pvc)
+	if err := controllerutil.SetControllerReference(pvc, prime, r.Scheme); err != nil {
+		return nil, fmt.Errorf("failed to set owner reference: %w", err)
+	}
+	existing := &corev1.PersistentVolumeClaim{}
+	created, err := createOrAdopt(ctx, r.Client, pvc, prime, existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prime PVC: %w", err)
+	}
+	if !created {
+		return existing, nil
+	}
+	return prime, nil
+}
+
+// rebindVolume binds the PersistentVolume of the populated prime PVC to the PVC. The
+// Kubernetes PV controller then completes the binding of the PVC.
+func (r *VolumePopulatorReconciler) rebindVolume(ctx context.Context, pvc, prime *corev1.PersistentVolumeClaim, snapshotRef *backupv1alpha1.ResticSnapshotRef) error {
+	if prime.Spec.VolumeName == "" {
+		return fmt.Errorf("prime PVC %s is not bound", prime.Name)
+	}
+	pv := &corev1.PersistentVolume{}
+	if err := r.Get(ctx, types.NamespacedName{Name: prime.Spec.VolumeName}, pv); err != nil {
+		return fmt.Errorf("failed to get volume of prime PVC: %w", err)
+	}
+	if pv.Spec.ClaimRef != nil && pv.Spec.ClaimRef.UID == pvc.UID {
+		return nil
+	}
+
+	pv.Spec.ClaimRef = &corev1.ObjectReference{
+		Kind:            "PersistentVolumeClaim",
+		APIVersion:      "v1",
+		Namespace:       pvc.Namespace,
+		Name:            pvc.Name,
+		UID:             pvc.UID,
+		ResourceVersion: pvc.ResourceVersion,
+	}
+	if pv.Annotations == nil {
+		pv.Annotations = map[string]string{}
+	}
+	pv.Annotations[populatedFromAnnotation] = snapshotRef.Name
+	if err := r.Update(ctx, pv); err != nil {
+		return fmt.Errorf("failed to bind volume %s: %w", pv.Name, err)
+	}
+	r.Recorder.Event(pvc, corev1.EventTypeNormal, "PopulationCompleted",
+		fmt.Sprintf("Populated volume %s from ResticSnapshotRef %s", pv.Name, snapshotRef.Name))
+	return nil
+}
+
+// cleanup deletes the prime PVC and ResticRestore of a bound PVC.
+func (r *VolumePopulatorReconciler) cleanup(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
+	key := types.NamespacedName{Name: populatorName(pvc), Namespace: pvc.Namespace}
+	for _, obj := range []client.Object{&backupv1alpha1.ResticRestore{}, &corev1.PersistentVolumeClaim{}} {
+		if err := r.Get(ctx, key, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !metav1.IsControlledBy(obj, pvc) {
+			continue
+		}
+		if err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isPopulatedClaim reports whether the PVC is populated from a ResticSnapshotRef.
+func isPopulatedClaim(pvc *corev1.PersistentVolumeClaim) bool {
+	source := pvc.Spec.DataSourceRef
+	return source != nil && source.APIGroup != nil &&
+		*source.APIGroup == backupv1alpha1.GroupVersion.Group && source.Kind == "ResticSnapshotRef"
+}
+
+// populatorName returns the name of the prime PVC and ResticRestore populating a PVC.
+func populatorName(pvc *corev1.PersistentVolumeClaim) string {
+	return populatorPrefix + string(pvc.UID)
+}
+
+// buildPrimeClaim builds the prime PVC of a PVC. It is provisioned on the node selected
+// for the PVC.
+func buildPrimeClaim(pvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
+	prime := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      populatorName(pvc),
+			Namespace: pvc.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "restic-backup-operator",
+				populatorLabel:                 pvc.Name,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      pvc.Spec.AccessModes,
+			Resources:        pvc.Spec.Resources,
+			StorageClassName: pvc.Spec.StorageClassName,
+			VolumeMode:       pvc.Spec.VolumeMode,
+		},
+	}
+	if node := pvc.Annotations[selectedNodeAnnotation]; node != "" {
+		prime.Annotations = map[string]string{selectedNodeAnnotation: node}
+	}
+	return prime
+}
+
+// buildPopulatorRestore builds the ResticRestore restoring the snapshot of a
+// ResticSnapshotRef into the prime PVC. Without explicit snapshot, the latest snapshot of
+// the backup's hostname is restored. With a selected node, the restore job runs on it, so
+// the volume is provisioned where the PVC is used.
+func buildPopulatorRestore(pvc *corev1.PersistentVolumeClaim, snapshotRef *backupv1alpha1.ResticSnapshotRef, selectedNode string) *backupv1alpha1.ResticRestore {
+	spec := snapshotRef.Spec.DeepCopy()
+	if spec.SnapshotID == "" && spec.SnapshotSelector == nil {
+		spec.SnapshotSelector = &backupv1alpha1.SnapshotSelector{Latest: true}
+	}
+
+	restore := &backupv1alpha1.ResticRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      populatorName(pvc),
+			Namespace: pvc.Namespace,
+			Labels: map[string]string{
+				populatorLabel: pvc.Name,
+			},
+		},
+		Spec: backupv1alpha1.ResticRestoreSpec{
+			BackupRef:        spec.BackupRef,
+			SnapshotID:       spec.SnapshotID,
+			SnapshotSelector: spec.SnapshotSelector,
+			Target: backupv1alpha1.RestoreTarget{
+				PVC: &backupv1alpha1.PVCTarget{ClaimName: populatorName(pvc)},
+			},
+			IncludePaths: spec.IncludePaths,
+			ExcludePaths: spec.ExcludePaths,
+			Options:      spec.Options,
+			JobConfig:    spec.JobConfig,
+		},
+	}
+
+	if selectedNode != "" {
+		if restore.Spec.JobConfig == nil {
+			restore.Spec.JobConfig = &backupv1alpha1.JobConfiguration{}
+		}
+		if restore.Spec.JobConfig.Affinity == nil {
+			restore.Spec.JobConfig.Affinity = &corev1.Affinity{}
+		}
+		restore.Spec.JobConfig.Affinity.NodeAffinity = &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchFields: []corev1.NodeSelectorRequirement{{
+						Key:      "metadata.name",
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{selectedNode},
+					}},
+				}},
+			},
+		}
+	}
+	return restore
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VolumePopulatorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	populated := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		pvc, ok := obj.(*corev1.PersistentVolumeClaim)
+		return ok && isPopulatedClaim(pvc)
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("volumepopulator").
+		For(&corev1.PersistentVolumeClaim{}, builder.WithPredicates(populated)).
+		Owns(&backupv1alpha1.ResticRestore{}).
+		Watches(&corev1.PersistentVolumeClaim{}, handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(),
+			&corev1.PersistentVolumeClaim{}, handler.OnlyControllerOwner())).
+		Complete(r)
+}
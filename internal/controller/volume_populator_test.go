@@ -0,0 +1,160 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+var _ = Describe("Volume populator", func() {
+	var (
+		reconciler  *VolumePopulatorReconciler
+		pvc         *corev1.PersistentVolumeClaim
+		snapshotRef *backupv1alpha1.ResticSnapshotRef
+	)
+
+	newReconciler := func(objs ...client.Object) *VolumePopulatorReconciler {
+		testScheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(testScheme)).To(Succeed())
+		Expect(backupv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		return &VolumePopulatorReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build(),
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+		}
+	}
+	reconcile := func() {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pvc)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		storageClass := "fast"
+		pvc = &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data-db-2", Namespace: "default", UID: "1234"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				StorageClassName: &storageClass,
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")},
+				},
+				DataSourceRef: &corev1.TypedObjectReference{
+					APIGroup: &backupv1alpha1.GroupVersion.Group,
+					Kind:     "ResticSnapshotRef",
+					Name:     "db-seed",
+				},
+			},
+		}
+		snapshotRef = &backupv1alpha1.ResticSnapshotRef{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-seed", Namespace: "default"},
+			Spec: backupv1alpha1.ResticSnapshotRefSpec{
+				BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "db"},
+			},
+		}
+	})
+
+	It("should only populate PVCs referencing a ResticSnapshotRef", func() {
+		Expect(isPopulatedClaim(pvc)).To(BeTrue())
+
+		other := pvc.DeepCopy()
+		other.Spec.DataSourceRef.Kind = "VolumeSnapshot"
+		Expect(isPopulatedClaim(other)).To(BeFalse())
+		other.Spec.DataSourceRef = nil
+		Expect(isPopulatedClaim(other)).To(BeFalse())
+	})
+
+	It("should restore the latest snapshot on the selected node", func() {
+		restore := buildPopulatorRestore(pvc, snapshotRef, "node-1")
+		Expect(restore.Name).To(Equal("populate-1234"))
+		Expect(restore.Spec.Target.PVC.ClaimName).To(Equal("populate-1234"))
+		Expect(restore.Spec.SnapshotSelector).To(Equal(&backupv1alpha1.SnapshotSelector{Latest: true}))
+		terms := restore.Spec.JobConfig.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		Expect(terms).To(HaveLen(1))
+		Expect(terms[0].MatchFields[0].Values).To(Equal([]string{"node-1"}))
+		Expect(snapshotRef.Spec.JobConfig).To(BeNil())
+
+		prime := buildPrimeClaim(pvc)
+		Expect(prime.Spec.DataSourceRef).To(BeNil())
+		Expect(prime.Spec.StorageClassName).To(Equal(pvc.Spec.StorageClassName))
+		Expect(prime.Spec.Resources).To(Equal(pvc.Spec.Resources))
+	})
+
+	It("should wait for a consumer with WaitForFirstConsumer storage classes", func() {
+		waitForConsumer := storagev1.VolumeBindingWaitForFirstConsumer
+		reconciler = newReconciler(pvc, snapshotRef, &storagev1.StorageClass{
+			ObjectMeta:        metav1.ObjectMeta{Name: "fast"},
+			VolumeBindingMode: &waitForConsumer,
+		})
+		reconcile()
+
+		err := reconciler.Get(context.Background(), types.NamespacedName{Name: "populate-1234", Namespace: "default"}, &corev1.PersistentVolumeClaim{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should restore into a prime PVC and rebind its volume", func() {
+		reconciler = newReconciler(pvc, snapshotRef, &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}})
+		reconcile()
+
+		key := types.NamespacedName{Name: "populate-1234", Namespace: "default"}
+		prime := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Get(context.Background(), key, prime)).To(Succeed())
+		Expect(metav1.IsControlledBy(prime, pvc)).To(BeTrue())
+		restore := &backupv1alpha1.ResticRestore{}
+		Expect(reconciler.Get(context.Background(), key, restore)).To(Succeed())
+		Expect(restore.Spec.BackupRef.Name).To(Equal("db"))
+
+		// The restore completed into the provisioned prime PVC
+		pv := &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}}
+		Expect(reconciler.Create(context.Background(), pv)).To(Succeed())
+		prime.Spec.VolumeName = "pv-1"
+		Expect(reconciler.Update(context.Background(), prime)).To(Succeed())
+		restore.Status.Phase = backupv1alpha1.RestorePhaseCompleted
+		Expect(reconciler.Update(context.Background(), restore)).To(Succeed())
+		reconcile()
+
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Name: "pv-1"}, pv)).To(Succeed())
+		Expect(pv.Spec.ClaimRef.Name).To(Equal("data-db-2"))
+		Expect(pv.Spec.ClaimRef.UID).To(Equal(types.UID("1234")))
+		Expect(pv.Annotations).To(HaveKeyWithValue(populatedFromAnnotation, "db-seed"))
+
+		// Once bound, the prime PVC and restore are removed
+		Expect(reconciler.Get(context.Background(), client.ObjectKeyFromObject(pvc), pvc)).To(Succeed())
+		pvc.Spec.VolumeName = "pv-1"
+		Expect(reconciler.Update(context.Background(), pvc)).To(Succeed())
+		reconcile()
+
+		Expect(apierrors.IsNotFound(reconciler.Get(context.Background(), key, &corev1.PersistentVolumeClaim{}))).To(BeTrue())
+		Expect(apierrors.IsNotFound(reconciler.Get(context.Background(), key, &backupv1alpha1.ResticRestore{}))).To(BeTrue())
+	})
+})
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+const (
+	// scaledDownByAnnotation records on a scaled down workload what it was scaled down
+	// for: "<backup>/<job>" for a backup job, "ResticRestore/<restore>" for a restore.
+	scaledDownByAnnotation = "backup.resticbackup.io/scaled-down-by"
+	// scaledDownReplicasAnnotation records the replicas of a workload before it was scaled down.
+	scaledDownReplicasAnnotation = "backup.resticbackup.io/scaled-down-replicas"
+	// scaledDownAtAnnotation records when a workload was scaled down.
+	scaledDownAtAnnotation = "backup.resticbackup.io/scaled-down-at"
+	// defaultScaleDownTimeout is used for workloads scaled down without timeout.
+	defaultScaleDownTimeout = 5 * time.Minute
+)
+
+// scaleDownTimeout returns how long to wait for the pods of a scaled down workload to terminate.
+func scaleDownTimeout(hook *backupv1alpha1.ScaleDownHook) time.Duration {
+	if hook.Timeout != nil {
+		return hook.Timeout.Duration
+	}
+	return defaultScaleDownTimeout
+}
+
+// getWorkload returns the Deployment or StatefulSet referenced by ref.
+func getWorkload(ctx context.Context, reader client.Reader, namespace string, ref backupv1alpha1.WorkloadReference) (client.Object, error) {
+	var workload client.Object
+	switch ref.Kind {
+	case "Deployment":
+		workload = &appsv1.Deployment{}
+	case "StatefulSet":
+		workload = &appsv1.StatefulSet{}
+	default:
+		return nil, fmt.Errorf("unsupported workload kind %q", ref.Kind)
+	}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, workload); err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %w", ref.Kind, ref.Name, err)
+	}
+	return workload, nil
+}
+
+// workloadScaledDownFor returns what a workload is scaled down for, empty if it is not.
+func workloadScaledDownFor(workload client.Object) string {
+	return workload.GetAnnotations()[scaledDownByAnnotation]
+}
+
+// workloadScaledDownSince returns when a workload was scaled down, the zero time if unknown.
+func workloadScaledDownSince(workload client.Object) time.Time {
+	since, err := time.Parse(time.RFC3339, workload.GetAnnotations()[scaledDownAtAnnotation])
+	if err != nil {
+		return time.Time{}
+	}
+	return since
+}
+
+// scaleDownWorkloadFor scales a workload to zero replicas for owner and returns its
+// previous replicas. They are stored in annotations of the workload together with the
+// owner, so the workload is scaled up again after restarts of the operator.
+func scaleDownWorkloadFor(ctx context.Context, c client.Client, workload client.Object, owner string) (int32, error) {
+	replicas := workloadReplicas(workload)
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	annotations := workload.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[scaledDownByAnnotation] = owner
+	annotations[scaledDownReplicasAnnotation] = strconv.Itoa(int(replicas))
+	annotations[scaledDownAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	workload.SetAnnotations(annotations)
+	setWorkloadReplicas(workload, 0)
+	if err := c.Patch(ctx, workload, patch); err != nil {
+		return 0, fmt.Errorf("failed to scale down %s: %w", workload.GetName(), err)
+	}
+	return replicas, nil
+}
+
+// scaleUpWorkload scales a scaled down workload up to the replicas it had before and
+// removes the annotations. Workloads without valid replicas annotation get a single replica.
+func scaleUpWorkload(ctx context.Context, c client.Client, workload client.Object) (int32, error) {
+	annotations := workload.GetAnnotations()
+	replicas := int32(1)
+	if n, err := strconv.ParseInt(annotations[scaledDownReplicasAnnotation], 10, 32); err == nil {
+		replicas = int32(n)
+	}
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	delete(annotations, scaledDownByAnnotation)
+	delete(annotations, scaledDownReplicasAnnotation)
+	delete(annotations, scaledDownAtAnnotation)
+	workload.SetAnnotations(annotations)
+	setWorkloadReplicas(workload, replicas)
+	if err := c.Patch(ctx, workload, patch); err != nil {
+		return 0, fmt.Errorf("failed to scale up %s: %w", workload.GetName(), err)
+	}
+	return replicas, nil
+}
+
+// workloadReplicas returns the desired replicas of a workload, which default to 1.
+func workloadReplicas(workload client.Object) int32 {
+	var replicas *int32
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		replicas = w.Spec.Replicas
+	case *appsv1.StatefulSet:
+		replicas = w.Spec.Replicas
+	}
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// setWorkloadReplicas sets the desired replicas of a workload.
+func setWorkloadReplicas(workload client.Object, replicas int32) {
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		w.Spec.Replicas = &replicas
+	case *appsv1.StatefulSet:
+		w.Spec.Replicas = &replicas
+	}
+}
+
+// countWorkloadPods returns the number of pods of a workload that did not terminate
+// yet, including pods that are shutting down.
+func countWorkloadPods(ctx context.Context, reader client.Reader, workload client.Object) (int, error) {
+	var labelSelector *metav1.LabelSelector
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		labelSelector = w.Spec.Selector
+	case *appsv1.StatefulSet:
+		labelSelector = w.Spec.Selector
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return 0, fmt.Errorf("invalid selector of %s: %w", workload.GetName(), err)
+	}
+
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods, client.InNamespace(workload.GetNamespace()), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return 0, fmt.Errorf("failed to list pods of %s: %w", workload.GetName(), err)
+	}
+	running := 0
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			running++
+		}
+	}
+	return running, nil
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package features implements feature gates for operator capabilities, so new
+// subsystems can ship disabled by default and graduate without forked builds.
+package features
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Feature is the name of a feature gate.
+type Feature string
+
+const (
+	// ScheduleRecommendations suggests backup schedules matching the data-change rate.
+	ScheduleRecommendations Feature = "ScheduleRecommendations"
+
+	// OverlappingBackupDetection warns about backups of the same volume in other namespaces.
+	OverlappingBackupDetection Feature = "OverlappingBackupDetection"
+
+	// SnapshotHostnameCheck detects snapshots of a backup written with another hostname.
+	SnapshotHostnameCheck Feature = "SnapshotHostnameCheck"
+
+	// ReferenceGrants requires a ResticReferenceGrant for references to other namespaces.
+	ReferenceGrants Feature = "ReferenceGrants"
+
+	// VolumePopulator populates PVCs with a dataSourceRef to a ResticSnapshotRef.
+	VolumePopulator Feature = "VolumePopulator"
+
+	// JobEventRelay relays Warning events of Job pods to the resource owning the Job.
+	JobEventRelay Feature = "JobEventRelay"
+
+	// AnnotatedPVCBackups creates ResticBackups for PVCs annotated with backup.resticbackup.io/enabled.
+	AnnotatedPVCBackups Feature = "AnnotatedPVCBackups"
+)
+
+// Stage is the maturity of a feature.
+type Stage string
+
+const (
+	// Alpha features are disabled by default and may change or be removed.
+	Alpha Stage = "Alpha"
+	// Beta features are enabled by default and can be disabled.
+	Beta Stage = "Beta"
+	// GA features are always enabled, their gate is kept for compatibility.
+	GA Stage = "GA"
+)
+
+// Spec describes the default and maturity of a feature.
+type Spec struct {
+	Default bool
+	Stage   Stage
+}
+
+// defaultFeatures lists all known features. New subsystems are added as Alpha.
+var defaultFeatures = map[Feature]Spec{
+	ScheduleRecommendations:    {Default: true, Stage: Beta},
+	OverlappingBackupDetection: {Default: true, Stage: Beta},
+	SnapshotHostnameCheck:      {Default: true, Stage: Beta},
+	ReferenceGrants:            {Default: true, Stage: Beta},
+	VolumePopulator:            {Default: false, Stage: Alpha},
+	JobEventRelay:              {Default: false, Stage: Alpha},
+	AnnotatedPVCBackups:        {Default: false, Stage: Alpha},
+}
+
+// Gate holds the enabled state of the features. A nil Gate reports the defaults.
+// It implements flag.Value to be set from a --feature-gates flag.
+type Gate struct {
+	overrides map[Feature]bool
+}
+
+// NewGate returns a gate with all features at their default.
+func NewGate() *Gate {
+	return &Gate{overrides: map[Feature]bool{}}
+}
+
+// Enabled reports whether a feature is enabled. Unknown features are disabled.
+func (g *Gate) Enabled(feature Feature) bool {
+	spec, known := defaultFeatures[feature]
+	if !known {
+		return false
+	}
+	if spec.Stage == GA {
+		return true
+	}
+	if g != nil {
+		if enabled, set := g.overrides[feature]; set {
+			return enabled
+		}
+	}
+	return spec.Default
+}
+
+// Set parses a comma-separated list of Feature=bool pairs, e.g.
+// "ScheduleRecommendations=false". Unknown features are rejected.
+func (g *Gate) Set(value string) error {
+	overrides := map[Feature]bool{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, raw, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("invalid feature gate %q, expected Feature=true|false", pair)
+		}
+		feature := Feature(strings.TrimSpace(name))
+		spec, known := defaultFeatures[feature]
+		if !known {
+			return fmt.Errorf("unknown feature gate %q", feature)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid value %q for feature gate %s, expected true or false", raw, feature)
+		}
+		if spec.Stage == GA && !enabled {
+			return fmt.Errorf("feature gate %s is GA and can't be disabled", feature)
+		}
+		overrides[feature] = enabled
+	}
+
+	if g.overrides == nil {
+		g.overrides = map[Feature]bool{}
+	}
+	for feature, enabled := range overrides {
+		g.overrides[feature] = enabled
+	}
+	return nil
+}
+
+// String returns the overridden features as Feature=bool pairs.
+func (g *Gate) String() string {
+	if g == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(g.overrides))
+	for feature, enabled := range g.overrides {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", feature, enabled))
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// States returns the enabled state of every known feature.
+func (g *Gate) States() map[string]bool {
+	states := make(map[string]bool, len(defaultFeatures))
+	for feature := range defaultFeatures {
+		states[string(feature)] = g.Enabled(feature)
+	}
+	return states
+}
+
+// Known returns the sorted names of all known features with their default and stage.
+func Known() []string {
+	known := make([]string, 0, len(defaultFeatures))
+	for feature, spec := range defaultFeatures {
+		known = append(known, fmt.Sprintf("%s=true|false (%s - default=%t)", feature, spec.Stage, spec.Default))
+	}
+	slices.Sort(known)
+	return known
+}
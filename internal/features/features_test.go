@@ -0,0 +1,112 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features
+
+import (
+	"testing"
+)
+
+func TestGateDefaults(t *testing.T) {
+	var nilGate *Gate
+	for _, gate := range []*Gate{nilGate, NewGate()} {
+		if !gate.Enabled(ScheduleRecommendations) {
+			t.Errorf("expected ScheduleRecommendations to be enabled by default")
+		}
+		if gate.Enabled(Feature("Unknown")) {
+			t.Errorf("expected unknown features to be disabled")
+		}
+	}
+}
+
+func TestGateSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		wantErr  bool
+		expected map[Feature]bool
+	}{
+		{
+			name:     "empty value keeps defaults",
+			value:    "",
+			expected: map[Feature]bool{ScheduleRecommendations: true, OverlappingBackupDetection: true},
+		},
+		{
+			name:     "disable a beta feature",
+			value:    "ScheduleRecommendations=false, OverlappingBackupDetection=true,",
+			expected: map[Feature]bool{ScheduleRecommendations: false, OverlappingBackupDetection: true},
+		},
+		{
+			name:    "unknown feature",
+			value:   "SnapshotClone=true",
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			value:   "ScheduleRecommendations",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			value:   "ScheduleRecommendations=maybe",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := NewGate()
+			err := gate.Set(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for feature, enabled := range tt.expected {
+				if gate.Enabled(feature) != enabled {
+					t.Errorf("Enabled(%s) = %t, want %t", feature, !enabled, enabled)
+				}
+			}
+		})
+	}
+}
+
+func TestGateSetIsAtomic(t *testing.T) {
+	gate := NewGate()
+	if err := gate.Set("ScheduleRecommendations=false,Unknown=true"); err == nil {
+		t.Fatal("expected an error for an unknown feature")
+	}
+	if !gate.Enabled(ScheduleRecommendations) {
+		t.Error("expected a failed Set to leave the gate unchanged")
+	}
+}
+
+func TestGateStringAndStates(t *testing.T) {
+	gate := NewGate()
+	if err := gate.Set("SnapshotHostnameCheck=false,ScheduleRecommendations=true"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := gate.String(); got != "ScheduleRecommendations=true,SnapshotHostnameCheck=false" {
+		t.Errorf("String() = %q", got)
+	}
+
+	states := gate.States()
+	if len(states) != len(defaultFeatures) {
+		t.Errorf("States() has %d features, want %d", len(states), len(defaultFeatures))
+	}
+	if states[string(SnapshotHostnameCheck)] {
+		t.Error("expected SnapshotHostnameCheck to be disabled")
+	}
+}
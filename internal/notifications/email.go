@@ -0,0 +1,292 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifications
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// TLS modes of the SMTP connection.
+const (
+	// EmailTLSStartTLS upgrades the connection with STARTTLS.
+	EmailTLSStartTLS = "StartTLS"
+	// EmailTLSImplicit connects with TLS, e.g. on port 465.
+	EmailTLSImplicit = "TLS"
+	// EmailTLSNone sends without encryption.
+	EmailTLSNone = "None"
+)
+
+const emailTimeout = 30 * time.Second
+
+// EmailNotifier sends notifications via SMTP. Events sent to the same recipients within
+// the batch window are combined into a single email.
+type EmailNotifier struct {
+	log logr.Logger
+	// send delivers an email, replaced in tests
+	send func(ctx context.Context, config EmailConfig, subject, body string) error
+
+	mu      sync.Mutex
+	batches map[string]*emailBatch
+}
+
+// emailBatch holds the events waiting for the end of the batch window.
+type emailBatch struct {
+	config EmailConfig
+	events []Event
+}
+
+// NewEmailNotifier creates a new email notifier.
+func NewEmailNotifier(log logr.Logger) *EmailNotifier {
+	return &EmailNotifier{
+		log:     log,
+		send:    sendMail,
+		batches: map[string]*emailBatch{},
+	}
+}
+
+// Notify sends an email for the event. With a batch window, the event is queued and
+// sent together with all events for the same recipients at the end of the window;
+// errors of queued events are only logged.
+func (n *EmailNotifier) Notify(ctx context.Context, config EmailConfig, event Event) error {
+	if err := validateEmailAddresses(config); err != nil {
+		return err
+	}
+	if config.BatchWindow <= 0 {
+		subject, body := emailContent([]Event{event})
+		if err := n.send(ctx, config, subject, body); err != nil {
+			return err
+		}
+		n.log.V(1).Info("Sent email notification", "to", config.To, "type", event.Type)
+		return nil
+	}
+
+	key := emailBatchKey(config)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if batch, ok := n.batches[key]; ok {
+		batch.events = append(batch.events, event)
+		return nil
+	}
+	n.batches[key] = &emailBatch{config: config, events: []Event{event}}
+	time.AfterFunc(config.BatchWindow, func() { n.flush(context.Background(), key) })
+	return nil
+}
+
+// Flush sends all queued events without waiting for the end of their batch window.
+// The batches are only kept in memory, so they must be flushed before the operator stops.
+func (n *EmailNotifier) Flush(ctx context.Context) {
+	n.mu.Lock()
+	keys := make([]string, 0, len(n.batches))
+	for key := range n.batches {
+		keys = append(keys, key)
+	}
+	n.mu.Unlock()
+
+	for _, key := range keys {
+		n.flush(ctx, key)
+	}
+}
+
+// flush sends the events of a batch in one email.
+func (n *EmailNotifier) flush(ctx context.Context, key string) {
+	n.mu.Lock()
+	batch := n.batches[key]
+	delete(n.batches, key)
+	n.mu.Unlock()
+	if batch == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+
+	subject, body := emailContent(batch.events)
+	if err := n.send(ctx, batch.config, subject, body); err != nil {
+		n.log.Error(err, "Failed to send email notification", "to", batch.config.To, "events", len(batch.events))
+		return
+	}
+	n.log.V(1).Info("Sent email notification", "to", batch.config.To, "events", len(batch.events))
+}
+
+// validateEmailAddresses checks that the sender and recipients are single addresses.
+// Line breaks in them would inject headers into the email.
+func validateEmailAddresses(config EmailConfig) error {
+	for _, address := range append([]string{config.From}, config.To...) {
+		if strings.ContainsAny(address, "\r\n") {
+			return fmt.Errorf("invalid email address %q: contains a line break", address)
+		}
+		if _, err := mail.ParseAddress(address); err != nil {
+			return fmt.Errorf("invalid email address %q: %w", address, err)
+		}
+	}
+	return nil
+}
+
+// emailHeader returns the header of an email. Values with line breaks are rejected, they
+// would inject headers.
+func emailHeader(config EmailConfig, subject string, date time.Time) (string, error) {
+	if err := validateEmailAddresses(config); err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(subject, "\r\n") {
+		return "", fmt.Errorf("invalid email subject %q: contains a line break", subject)
+	}
+	return fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n",
+		config.From, strings.Join(config.To, ", "), subject, date.Format(time.RFC1123Z)), nil
+}
+
+// emailBatchKey identifies the server, credentials, TLS settings, sender and recipients
+// of an email, so events are only batched with others sent the same way. The fields are
+// quoted, separators in a value cannot make two configs collide.
+func emailBatchKey(config EmailConfig) string {
+	fields := []string{
+		config.Host, strconv.Itoa(int(config.Port)), config.Username, config.Password,
+		config.TLS, strconv.FormatBool(config.InsecureSkipVerify), config.From,
+	}
+	fields = append(fields, config.To...)
+	for i, field := range fields {
+		fields[i] = strconv.Quote(field)
+	}
+	return strings.Join(fields, "|")
+}
+
+// emailContent builds the subject and body of an email summarizing the events.
+func emailContent(events []Event) (string, string) {
+	var subject string
+	if len(events) == 1 {
+		event := events[0]
+		subject = fmt.Sprintf("[restic-backup-operator] %s/%s %s", event.Namespace, event.Resource, eventStatus(event.Type))
+	} else {
+		failed := 0
+		for _, event := range events {
+			if event.Type == EventTypeFailure {
+				failed++
+			}
+		}
+		subject = fmt.Sprintf("[restic-backup-operator] %d notifications, %d failed", len(events), failed)
+	}
+
+	var body strings.Builder
+	for i, event := range events {
+		if i > 0 {
+			body.WriteString("\r\n")
+		}
+		fmt.Fprintf(&body, "%s/%s: %s\r\n", event.Namespace, event.Resource, eventStatus(event.Type))
+		fmt.Fprintf(&body, "%s\r\n", event.Message)
+		if !event.Timestamp.IsZero() {
+			fmt.Fprintf(&body, "Time: %s\r\n", event.Timestamp.UTC().Format(time.RFC3339))
+		}
+		if event.Duration > 0 {
+			fmt.Fprintf(&body, "Duration: %s\r\n", event.Duration.Round(time.Second))
+		}
+		if event.Size != "" {
+			fmt.Fprintf(&body, "Size: %s\r\n", event.Size)
+		}
+		if event.Files > 0 {
+			fmt.Fprintf(&body, "Files: %d\r\n", event.Files)
+		}
+	}
+	return subject, body.String()
+}
+
+func eventStatus(eventType EventType) string {
+	switch eventType {
+	case EventTypeSuccess:
+		return "succeeded"
+	case EventTypeFailure:
+		return "failed"
+	default:
+		return "warning"
+	}
+}
+
+// sendMail delivers an email via the SMTP server of the config.
+func sendMail(ctx context.Context, config EmailConfig, subject, body string) error {
+	header, err := emailHeader(config, subject, time.Now())
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port)))
+	tlsConfig := &tls.Config{ServerName: config.Host, InsecureSkipVerify: config.InsecureSkipVerify}
+
+	dialer := &net.Dialer{Timeout: emailTimeout}
+	var conn net.Conn
+	if config.TLS == EmailTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if config.TLS == "" || config.TLS == EmailTLSStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(config.From); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM failed: %w", err)
+	}
+	for _, to := range config.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s failed: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	if _, err := w.Write([]byte(header + "\r\n" + body)); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return client.Quit()
+}
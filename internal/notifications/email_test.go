@@ -0,0 +1,289 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// startSMTPServer starts a minimal SMTP server without TLS and returns its port and a
+// channel receiving the DATA of every email.
+func startSMTPServer(t *testing.T) (int32, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	received := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+
+	port, _ := strconv.Atoi(strings.TrimPrefix(listener.Addr().String(), "127.0.0.1:"))
+	return int32(port), received
+}
+
+func serveSMTP(conn net.Conn, received chan<- string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 send data")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			received <- data.String()
+			reply("250 queued")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestEmailNotifier_Notify(t *testing.T) {
+	port, received := startSMTPServer(t)
+	notifier := NewEmailNotifier(logr.Discard())
+
+	config := EmailConfig{
+		Host: "127.0.0.1",
+		Port: port,
+		From: "operator@example.com",
+		To:   []string{"admin@example.com", "ops@example.com"},
+		TLS:  EmailTLSNone,
+	}
+	event := Event{
+		Type:       EventTypeSuccess,
+		Resource:   "emby",
+		Namespace:  "media",
+		Message:    "Backup completed successfully: abc123",
+		Duration:   90 * time.Second,
+		SnapshotID: "abc123",
+		Size:       "1.5 GiB",
+		Files:      1200,
+	}
+
+	if err := notifier.Notify(context.Background(), config, event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case data := <-received:
+		for _, expected := range []string{
+			"From: operator@example.com",
+			"To: admin@example.com, ops@example.com",
+			"Subject: [restic-backup-operator] media/emby succeeded",
+			"Backup completed successfully: abc123",
+			"Size: 1.5 GiB",
+			"Files: 1200",
+		} {
+			if !strings.Contains(data, expected) {
+				t.Errorf("expected email to contain %q, got:\n%s", expected, data)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+	}
+}
+
+func TestEmailNotifier_Notify_ConnectionError(t *testing.T) {
+	notifier := NewEmailNotifier(logr.Discard())
+	config := EmailConfig{Host: "127.0.0.1", Port: 1, From: "a@example.com", To: []string{"b@example.com"}, TLS: EmailTLSNone}
+
+	if err := notifier.Notify(context.Background(), config, Event{Type: EventTypeFailure}); err == nil {
+		t.Error("expected error for unreachable SMTP server")
+	}
+}
+
+func TestEmailNotifier_Notify_HeaderInjection(t *testing.T) {
+	notifier := NewEmailNotifier(logr.Discard())
+	notifier.send = func(_ context.Context, _ EmailConfig, _, _ string) error {
+		t.Error("unexpected email")
+		return nil
+	}
+
+	for _, config := range []EmailConfig{
+		{Host: "smtp.example.com", From: "a@example.com\r\nBcc: c@example.com", To: []string{"b@example.com"}},
+		{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com\nBcc: c@example.com"}},
+		{Host: "smtp.example.com", From: "a@example.com", To: []string{"not an address"}},
+	} {
+		if err := notifier.Notify(context.Background(), config, Event{Type: EventTypeFailure}); err == nil {
+			t.Errorf("expected error for From %q and To %q", config.From, config.To)
+		}
+	}
+
+	config := EmailConfig{From: "a@example.com", To: []string{"b@example.com"}}
+	if _, err := emailHeader(config, "backup failed\r\nBcc: c@example.com", time.Now()); err == nil {
+		t.Error("expected error for a subject with a line break")
+	}
+}
+
+func TestEmailNotifier_Flush(t *testing.T) {
+	notifier := NewEmailNotifier(logr.Discard())
+	sent := 0
+	notifier.send = func(_ context.Context, _ EmailConfig, _, _ string) error {
+		sent++
+		return nil
+	}
+
+	config := EmailConfig{Host: "smtp.example.com", Port: 587, From: "a@example.com", To: []string{"b@example.com"}, BatchWindow: time.Hour}
+	if err := notifier.Notify(context.Background(), config, Event{Type: EventTypeFailure}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	notifier.Flush(context.Background())
+	if sent != 1 {
+		t.Errorf("expected the queued email to be sent, got %d emails", sent)
+	}
+
+	// The batch timer finds the batch already sent
+	notifier.flush(context.Background(), emailBatchKey(config))
+	if sent != 1 {
+		t.Errorf("expected 1 email, got %d", sent)
+	}
+}
+
+func TestEmailNotifier_Notify_BatchPerCredentials(t *testing.T) {
+	notifier := NewEmailNotifier(logr.Discard())
+	var users []string
+	notifier.send = func(_ context.Context, config EmailConfig, _, _ string) error {
+		users = append(users, config.Username)
+		return nil
+	}
+
+	config := EmailConfig{Host: "smtp.example.com", Port: 587, From: "a@example.com", To: []string{"b@example.com"}, BatchWindow: time.Hour}
+	for _, username := range []string{"media", "cloud"} {
+		config.Username, config.Password = username, username+"-password"
+		if err := notifier.Notify(context.Background(), config, Event{Type: EventTypeFailure}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	notifier.Flush(context.Background())
+	slices.Sort(users)
+	if !slices.Equal(users, []string{"cloud", "media"}) {
+		t.Errorf("expected one email per credentials, got emails sent as %v", users)
+	}
+}
+
+func TestEmailNotifier_Notify_Batch(t *testing.T) {
+	notifier := NewEmailNotifier(logr.Discard())
+
+	var mu sync.Mutex
+	var subjects, bodies []string
+	notifier.send = func(_ context.Context, _ EmailConfig, subject, body string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		subjects = append(subjects, subject)
+		bodies = append(bodies, body)
+		return nil
+	}
+
+	config := EmailConfig{Host: "smtp.example.com", Port: 587, From: "a@example.com", To: []string{"b@example.com"}, BatchWindow: 50 * time.Millisecond}
+	events := []Event{
+		{Type: EventTypeSuccess, Resource: "emby", Namespace: "media", Message: "Backup completed"},
+		{Type: EventTypeFailure, Resource: "nextcloud", Namespace: "cloud", Message: "Backup failed: timeout"},
+		{Type: EventTypeSuccess, Resource: "jellyfin", Namespace: "media", Message: "Backup completed"},
+	}
+	for _, event := range events {
+		if err := notifier.Notify(context.Background(), config, event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(subjects)
+		mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(subjects) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(subjects))
+	}
+	if subjects[0] != "[restic-backup-operator] 3 notifications, 1 failed" {
+		t.Errorf("unexpected subject %q", subjects[0])
+	}
+	for _, expected := range []string{"media/emby: succeeded", "cloud/nextcloud: failed", "Backup failed: timeout", "media/jellyfin: succeeded"} {
+		if !strings.Contains(bodies[0], expected) {
+			t.Errorf("expected body to contain %q, got:\n%s", expected, bodies[0])
+		}
+	}
+}
+
+func TestManager_Notify_EmailOnlyOnFailure(t *testing.T) {
+	manager := NewManager(logr.Discard())
+	sent := 0
+	manager.email.send = func(_ context.Context, _ EmailConfig, _, _ string) error {
+		sent++
+		return nil
+	}
+
+	config := Config{Email: &EmailConfig{Host: "smtp.example.com", Port: 587, From: "a@example.com", To: []string{"b@example.com"}, OnlyOnFailure: true}}
+	if err := manager.Notify(context.Background(), config, Event{Type: EventTypeSuccess}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := manager.Notify(context.Background(), config, Event{Type: EventTypeFailure}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sent != 1 {
+		t.Errorf("expected 1 email, got %d", sent)
+	}
+}
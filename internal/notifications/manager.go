@@ -56,6 +56,8 @@ type Config struct {
 	Pushgateway *PushgatewayConfig
 	// Ntfy configuration
 	Ntfy *NtfyConfig
+	// Email configuration
+	Email *EmailConfig
 }
 
 // PushgatewayConfig contains Pushgateway configuration.
@@ -76,11 +78,26 @@ type NtfyConfig struct {
 	Tags          []string
 }
 
+// EmailConfig contains SMTP email configuration.
+type EmailConfig struct {
+	Host               string
+	Port               int32
+	Username           string
+	Password           string
+	From               string
+	To                 []string
+	TLS                string // StartTLS (default), TLS or None
+	InsecureSkipVerify bool
+	OnlyOnFailure      bool
+	BatchWindow        time.Duration // Events within the window are sent in one email
+}
+
 // Manager coordinates sending notifications to multiple backends.
 type Manager struct {
 	log         logr.Logger
 	ntfy        *NtfyNotifier
 	pushgateway *PushgatewayNotifier
+	email       *EmailNotifier
 }
 
 // NewManager creates a new notification manager.
@@ -89,9 +106,23 @@ func NewManager(log logr.Logger) *Manager {
 		log:         log,
 		ntfy:        NewNtfyNotifier(log),
 		pushgateway: NewPushgatewayNotifier(log),
+		email:       NewEmailNotifier(log),
 	}
 }
 
+// Start waits until the operator stops and sends the emails still waiting for the end
+// of their batch window, which would be lost otherwise. It implements manager.Runnable.
+func (m *Manager) Start(ctx context.Context) error {
+	<-ctx.Done()
+	m.email.Flush(context.Background())
+	return nil
+}
+
+// NeedLeaderElection returns false, every replica flushes the emails it queued.
+func (m *Manager) NeedLeaderElection() bool {
+	return false
+}
+
 // Notify sends a notification to all configured backends.
 func (m *Manager) Notify(ctx context.Context, config Config, event Event) error {
 	var errs []error
@@ -115,6 +146,16 @@ func (m *Manager) Notify(ctx context.Context, config Config, event Event) error
 		}
 	}
 
+	// Send via email
+	if config.Email != nil && config.Email.Host != "" && len(config.Email.To) > 0 {
+		if !config.Email.OnlyOnFailure || event.Type == EventTypeFailure {
+			if err := m.email.Notify(ctx, *config.Email, event); err != nil {
+				m.log.Error(err, "Failed to send email notification")
+				errs = append(errs, fmt.Errorf("email: %w", err))
+			}
+		}
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("notification errors: %v", errs)
 	}
@@ -122,6 +163,20 @@ func (m *Manager) Notify(ctx context.Context, config Config, event Event) error
 	return nil
 }
 
+// DeleteBackupMetrics removes the metrics of a backup from Pushgateway, so that
+// dashboards do not keep showing removed backups.
+func (m *Manager) DeleteBackupMetrics(ctx context.Context, config Config, resource, namespace string) error {
+	if config.Pushgateway == nil || config.Pushgateway.URL == "" {
+		return nil
+	}
+
+	if err := m.pushgateway.Delete(ctx, *config.Pushgateway, resource, namespace); err != nil {
+		return fmt.Errorf("pushgateway: %w", err)
+	}
+
+	return nil
+}
+
 // NotifyBackupSuccess sends a backup success notification.
 func (m *Manager) NotifyBackupSuccess(ctx context.Context, config Config, resource, namespace, snapshotID, size string, files int64, duration time.Duration) error {
 	event := Event{
@@ -159,6 +214,23 @@ func (m *Manager) NotifyBackupFailure(ctx context.Context, config Config, resour
 	return m.Notify(ctx, config, event)
 }
 
+// NotifyBackupMissed sends a notification that a backup has not succeeded for too long.
+// It is not pushed to Pushgateway, whose metrics describe backup runs.
+func (m *Manager) NotifyBackupMissed(ctx context.Context, config Config, resource, namespace, message string) error {
+	config.Pushgateway = nil
+	event := Event{
+		Type:      EventTypeFailure,
+		Resource:  resource,
+		Namespace: namespace,
+		Message:   fmt.Sprintf("Backup missed: %s", message),
+		Timestamp: time.Now(),
+		Details: map[string]string{
+			"error": message,
+		},
+	}
+	return m.Notify(ctx, config, event)
+}
+
 // NotifyRestoreSuccess sends a restore success notification.
 func (m *Manager) NotifyRestoreSuccess(ctx context.Context, config Config, resource, namespace, snapshotID string, duration time.Duration) error {
 	event := Event{
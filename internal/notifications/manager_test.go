@@ -397,6 +397,52 @@ func TestManager_NotifyBackupFailure(t *testing.T) {
 	}
 }
 
+func TestManager_NotifyBackupMissed(t *testing.T) {
+	var eventReceived, pushed atomic.Bool
+
+	ntfyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		eventReceived.Store(true)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ntfyServer.Close()
+
+	pushgatewayServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushed.Store(true)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer pushgatewayServer.Close()
+
+	log := logr.Discard()
+	manager := NewManager(log)
+
+	config := Config{
+		Ntfy: &NtfyConfig{
+			ServerURL:     ntfyServer.URL,
+			Topic:         "test",
+			OnlyOnFailure: true,
+		},
+		Pushgateway: &PushgatewayConfig{URL: pushgatewayServer.URL},
+	}
+
+	err := manager.NotifyBackupMissed(
+		context.Background(),
+		config,
+		"test-backup",
+		"default",
+		"no successful backup since 2024-01-13T02:05:00Z",
+	)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if !eventReceived.Load() {
+		t.Error("expected notification to be sent")
+	}
+	if pushed.Load() {
+		t.Error("expected no metrics to be pushed to Pushgateway")
+	}
+}
+
 func TestManager_NotifyRestoreSuccess(t *testing.T) {
 	var eventReceived atomic.Bool
 
@@ -469,6 +515,44 @@ func TestManager_NotifyRestoreFailure(t *testing.T) {
 	}
 }
 
+func TestManager_DeleteBackupMetrics(t *testing.T) {
+	var deleteReceived atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleteReceived.Store(true)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	manager := NewManager(logr.Discard())
+
+	config := Config{
+		Pushgateway: &PushgatewayConfig{
+			URL: server.URL,
+		},
+	}
+
+	err := manager.DeleteBackupMetrics(context.Background(), config, "test-backup", "default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !deleteReceived.Load() {
+		t.Error("expected Pushgateway to receive a DELETE request")
+	}
+}
+
+func TestManager_DeleteBackupMetrics_NoPushgateway(t *testing.T) {
+	manager := NewManager(logr.Discard())
+
+	err := manager.DeleteBackupMetrics(context.Background(), Config{}, "test-backup", "default")
+	if err != nil {
+		t.Errorf("expected no error without Pushgateway, got: %v", err)
+	}
+}
+
 func TestEventType_Constants(t *testing.T) {
 	// Verify event type constants have expected values
 	if EventTypeSuccess != "success" {
@@ -103,3 +103,27 @@ func (p *PushgatewayNotifier) Notify(ctx context.Context, config PushgatewayConf
 
 	return nil
 }
+
+// Delete removes all metrics of a backup grouping from Pushgateway.
+func (p *PushgatewayNotifier) Delete(ctx context.Context, config PushgatewayConfig, resource, namespace string) error {
+	jobName := config.JobName
+	if jobName == "" {
+		jobName = "backup"
+	}
+
+	pusher := push.New(config.URL, jobName).
+		Grouping("backup", resource).
+		Grouping("namespace", namespace)
+
+	if err := pusher.Delete(); err != nil {
+		return fmt.Errorf("failed to delete metrics from Pushgateway: %w", err)
+	}
+
+	p.log.V(1).Info("Deleted metrics from Pushgateway",
+		"url", config.URL,
+		"job", jobName,
+		"backup", resource,
+		"namespace", namespace)
+
+	return nil
+}
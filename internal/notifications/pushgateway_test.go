@@ -465,3 +465,54 @@ func TestPushgatewayNotifier_Notify_FilesMetricConditional(t *testing.T) {
 		})
 	}
 }
+
+func TestPushgatewayNotifier_Delete(t *testing.T) {
+	var receivedMethod, receivedPath string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedMethod = r.Method
+		receivedPath = r.URL.Path
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	notifier := NewPushgatewayNotifier(logr.Discard())
+
+	config := PushgatewayConfig{
+		URL: server.URL,
+	}
+
+	err := notifier.Delete(context.Background(), config, "my-backup", "media")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if receivedMethod != http.MethodDelete {
+		t.Errorf("expected DELETE request, got %s", receivedMethod)
+	}
+
+	for _, part := range []string{"/metrics/job/backup", "backup/my-backup", "namespace/media"} {
+		if !strings.Contains(receivedPath, part) {
+			t.Errorf("expected path to contain %q, got %q", part, receivedPath)
+		}
+	}
+}
+
+func TestPushgatewayNotifier_Delete_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier := NewPushgatewayNotifier(logr.Discard())
+
+	config := PushgatewayConfig{
+		URL:     server.URL,
+		JobName: "backup",
+	}
+
+	err := notifier.Delete(context.Background(), config, "my-backup", "media")
+	if err == nil {
+		t.Error("expected error for server error response")
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// backupSummary is the summary message printed by restic backup --json.
+type backupSummary struct {
+	MessageType         string `json:"message_type"`
+	SnapshotID          string `json:"snapshot_id"`
+	FilesNew            int64  `json:"files_new"`
+	FilesChanged        int64  `json:"files_changed"`
+	FilesUnmodified     int64  `json:"files_unmodified"`
+	DirsNew             int64  `json:"dirs_new"`
+	DirsChanged         int64  `json:"dirs_changed"`
+	DirsUnmodified      int64  `json:"dirs_unmodified"`
+	DataAdded           uint64 `json:"data_added"`
+	TotalFilesProcessed int64  `json:"total_files_processed"`
+	TotalBytesProcessed uint64 `json:"total_bytes_processed"`
+}
+
+// ParseBackupSummary extracts the result from the JSON output of restic backup.
+// The last summary message is used; status and error messages are ignored.
+// The duration is not part of the result.
+func ParseBackupSummary(output string) (*BackupResult, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		var summary backupSummary
+		if err := json.Unmarshal([]byte(lines[i]), &summary); err != nil || summary.MessageType != "summary" {
+			continue
+		}
+
+		return &BackupResult{
+			SnapshotID:      summary.SnapshotID,
+			FilesNew:        summary.FilesNew,
+			FilesChanged:    summary.FilesChanged,
+			FilesUnmodified: summary.FilesUnmodified,
+			DirsNew:         summary.DirsNew,
+			DirsChanged:     summary.DirsChanged,
+			DirsUnmodified:  summary.DirsUnmodified,
+			DataAdded:       summary.DataAdded,
+			TotalFiles:      summary.TotalFilesProcessed,
+			TotalBytes:      summary.TotalBytesProcessed,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no summary found in backup output")
+}
+
+// restoreSummary is the summary message printed by restic restore --json.
+type restoreSummary struct {
+	MessageType   string `json:"message_type"`
+	FilesRestored int64  `json:"files_restored"`
+	BytesRestored uint64 `json:"bytes_restored"`
+}
+
+// ParseRestoreSummary extracts the result from the JSON output of restic restore.
+// The duration is not part of the result.
+func ParseRestoreSummary(output string) (*RestoreResult, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		var summary restoreSummary
+		if err := json.Unmarshal([]byte(lines[i]), &summary); err != nil || summary.MessageType != "summary" {
+			continue
+		}
+
+		return &RestoreResult{
+			RestoredFiles: summary.FilesRestored,
+			RestoredBytes: summary.BytesRestored,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no summary found in restore output")
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"testing"
+)
+
+func TestParseBackupSummary(t *testing.T) {
+	output := `{"message_type":"status","percent_done":0.5,"total_files":10,"files_done":5}
+{"message_type":"error","error":{"message":"permission denied"},"during":"archival","item":"/backup/secret"}
+{"message_type":"summary","files_new":3,"files_changed":1,"files_unmodified":6,"dirs_new":1,"dirs_changed":0,"dirs_unmodified":2,"data_blobs":4,"tree_blobs":2,"data_added":2048,"data_added_packed":1024,"total_files_processed":10,"total_bytes_processed":4096,"total_duration":1.5,"snapshot_id":"abc123def456"}
+`
+
+	result, err := ParseBackupSummary(output)
+	if err != nil {
+		t.Fatalf("ParseBackupSummary() error = %v", err)
+	}
+	if result.SnapshotID != "abc123def456" {
+		t.Errorf("SnapshotID = %q, want %q", result.SnapshotID, "abc123def456")
+	}
+	if result.FilesNew != 3 || result.FilesChanged != 1 || result.FilesUnmodified != 6 {
+		t.Errorf("files = %d/%d/%d, want 3/1/6", result.FilesNew, result.FilesChanged, result.FilesUnmodified)
+	}
+	if result.DataAdded != 2048 {
+		t.Errorf("DataAdded = %d, want 2048", result.DataAdded)
+	}
+	if result.TotalFiles != 10 || result.TotalBytes != 4096 {
+		t.Errorf("totals = %d files / %d bytes, want 10 files / 4096 bytes", result.TotalFiles, result.TotalBytes)
+	}
+}
+
+func TestParseBackupSummaryWithoutSummary(t *testing.T) {
+	for _, output := range []string{
+		"",
+		`{"message_type":"status","percent_done":1}`,
+		"Fatal: unable to open repository",
+	} {
+		if _, err := ParseBackupSummary(output); err == nil {
+			t.Errorf("ParseBackupSummary(%q) expected error", output)
+		}
+	}
+}
+
+func TestParseRestoreSummary(t *testing.T) {
+	output := `{"message_type":"status","seconds_elapsed":1,"percent_done":0.5,"total_files":4,"files_restored":2,"total_bytes":4096,"bytes_restored":2048}
+{"message_type":"summary","seconds_elapsed":2,"total_files":4,"files_restored":4,"files_skipped":0,"total_bytes":4096,"bytes_restored":4096,"bytes_skipped":0}
+`
+
+	result, err := ParseRestoreSummary(output)
+	if err != nil {
+		t.Fatalf("ParseRestoreSummary() error = %v", err)
+	}
+	if result.RestoredFiles != 4 || result.RestoredBytes != 4096 {
+		t.Errorf("restored = %d files / %d bytes, want 4 files / 4096 bytes", result.RestoredFiles, result.RestoredBytes)
+	}
+
+	if _, err := ParseRestoreSummary("restoring <Snapshot abc123> to /restore"); err == nil {
+		t.Error("ParseRestoreSummary() of plain output expected error")
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// diffMessage is a change or the statistics message printed by restic diff --json.
+type diffMessage struct {
+	MessageType  string    `json:"message_type"`
+	Path         string    `json:"path"`
+	Modifier     string    `json:"modifier"`
+	ChangedFiles int       `json:"changed_files"`
+	Added        DiffStats `json:"added"`
+	Removed      DiffStats `json:"removed"`
+}
+
+// ParseDiffOutput extracts the changes and statistics from the JSON output of restic
+// diff. Lines that aren't JSON are ignored.
+func ParseDiffOutput(output string) (*DiffResult, error) {
+	result := &DiffResult{}
+	statistics := false
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var msg diffMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			continue
+		}
+		switch msg.MessageType {
+		case "change":
+			result.Changes = append(result.Changes, DiffChange{Path: msg.Path, Modifier: msg.Modifier})
+		case "statistics":
+			statistics = true
+			result.ChangedFiles = msg.ChangedFiles
+			result.Added = msg.Added
+			result.Removed = msg.Removed
+		}
+	}
+
+	if !statistics {
+		return nil, fmt.Errorf("no statistics found in diff output")
+	}
+	return result, nil
+}
+
+// lsMessage is a line printed by restic ls --json. The first line describes the
+// snapshot, the others its nodes.
+type lsMessage struct {
+	Node
+	StructType string `json:"struct_type"`
+}
+
+// ParseLsOutput extracts the nodes from the JSON output of restic ls.
+func ParseLsOutput(output string) ([]Node, error) {
+	nodes := []Node{}
+	snapshot := false
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		var msg lsMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			return nil, fmt.Errorf("failed to parse ls output: %w", err)
+		}
+		switch msg.StructType {
+		case "snapshot":
+			snapshot = true
+		case "node":
+			nodes = append(nodes, msg.Node)
+		}
+	}
+
+	if !snapshot {
+		return nil, fmt.Errorf("no snapshot found in ls output")
+	}
+	return nodes, nil
+}
+
+// ParseFindOutput extracts the matches per snapshot from the JSON output of restic find.
+func ParseFindOutput(output []byte) ([]FindResult, error) {
+	if len(output) == 0 || string(output) == "null" {
+		return []FindResult{}, nil
+	}
+
+	var results []FindResult
+	if err := json.Unmarshal(output, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse find output: %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"testing"
+)
+
+func TestParseDiffOutput(t *testing.T) {
+	output := `{"message_type":"change","path":"/data/config.yaml","modifier":"M"}
+{"message_type":"change","path":"/data/new.txt","modifier":"+"}
+{"message_type":"statistics","source_snapshot":"abc","target_snapshot":"def","changed_files":1,"added":{"files":2,"dirs":0,"others":0,"data_blobs":2,"tree_blobs":1,"bytes":2048},"removed":{"files":1,"dirs":0,"others":0,"data_blobs":1,"tree_blobs":1,"bytes":1024}}
+`
+
+	result, err := ParseDiffOutput(output)
+	if err != nil {
+		t.Fatalf("ParseDiffOutput() error = %v", err)
+	}
+	if len(result.Changes) != 2 || result.Changes[1] != (DiffChange{Path: "/data/new.txt", Modifier: "+"}) {
+		t.Errorf("Changes = %v", result.Changes)
+	}
+	if result.ChangedFiles != 1 {
+		t.Errorf("ChangedFiles = %d, want 1", result.ChangedFiles)
+	}
+	if result.Added != (DiffStats{Files: 2, Bytes: 2048}) || result.Removed != (DiffStats{Files: 1, Bytes: 1024}) {
+		t.Errorf("Added = %v, Removed = %v", result.Added, result.Removed)
+	}
+
+	if _, err := ParseDiffOutput(""); err == nil {
+		t.Error("ParseDiffOutput(\"\") expected error")
+	}
+}
+
+func TestParseLsOutput(t *testing.T) {
+	output := `{"time":"2024-01-15T10:00:00Z","tree":"abc","paths":["/data"],"hostname":"app","id":"abc123","short_id":"abc123","struct_type":"snapshot","message_type":"snapshot"}
+{"name":"data","type":"dir","path":"/data","uid":0,"gid":0,"mode":2147484141,"mtime":"2024-01-15T09:00:00Z","struct_type":"node","message_type":"node"}
+{"name":"config.yaml","type":"file","path":"/data/config.yaml","uid":33,"gid":33,"size":512,"mode":420,"mtime":"2024-01-15T09:30:00Z","struct_type":"node","message_type":"node"}
+`
+
+	nodes, err := ParseLsOutput(output)
+	if err != nil {
+		t.Fatalf("ParseLsOutput() error = %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(nodes))
+	}
+	file := nodes[1]
+	if file.Path != "/data/config.yaml" || file.Type != "file" || file.Size != 512 || file.UID != 33 {
+		t.Errorf("unexpected node %+v", file)
+	}
+
+	if _, err := ParseLsOutput("Fatal: no matching ID found"); err == nil {
+		t.Error("ParseLsOutput() expected error for non-JSON output")
+	}
+}
+
+func TestParseFindOutput(t *testing.T) {
+	output := []byte(`[{"matches":[{"path":"/data/config.yaml","permissions":"-rw-r--r--","type":"file","mode":420,"mtime":"2024-01-15T09:30:00Z","uid":33,"gid":33,"size":512}],"hits":1,"snapshot":"abc123"}]`)
+
+	results, err := ParseFindOutput(output)
+	if err != nil {
+		t.Fatalf("ParseFindOutput() error = %v", err)
+	}
+	if len(results) != 1 || results[0].SnapshotID != "abc123" || results[0].Hits != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[0].Matches[0].Path != "/data/config.yaml" {
+		t.Errorf("unexpected match %+v", results[0].Matches[0])
+	}
+
+	for _, empty := range []string{"", "null"} {
+		results, err := ParseFindOutput([]byte(empty))
+		if err != nil || len(results) != 0 {
+			t.Errorf("ParseFindOutput(%q) = %v, %v, want no results", empty, results, err)
+		}
+	}
+}
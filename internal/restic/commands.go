@@ -21,10 +21,12 @@ import (
 	"strconv"
 )
 
-// CommandBuilder builds restic command arguments.
+// CommandBuilder builds restic command arguments. Positional arguments, e.g. snapshot
+// IDs and paths, follow a "--" separator, so that restic never parses them as flags.
 type CommandBuilder struct {
-	command string
-	args    []string
+	command    string
+	args       []string
+	positional []string
 }
 
 // NewCommand creates a new command builder.
@@ -81,6 +83,26 @@ func (b *CommandBuilder) WithTags(tags []string) *CommandBuilder {
 	return b
 }
 
+// WithAddTags adds an --add flag for each tag (for tag).
+func (b *CommandBuilder) WithAddTags(tags []string) *CommandBuilder {
+	for _, tag := range tags {
+		if tag != "" {
+			b.args = append(b.args, "--add", tag)
+		}
+	}
+	return b
+}
+
+// WithRemoveTags adds a --remove flag for each tag (for tag).
+func (b *CommandBuilder) WithRemoveTags(tags []string) *CommandBuilder {
+	for _, tag := range tags {
+		if tag != "" {
+			b.args = append(b.args, "--remove", tag)
+		}
+	}
+	return b
+}
+
 // WithExclude adds an --exclude flag.
 func (b *CommandBuilder) WithExclude(pattern string) *CommandBuilder {
 	if pattern != "" {
@@ -169,6 +191,70 @@ func (b *CommandBuilder) WithKeepYearly(n int) *CommandBuilder {
 	return b
 }
 
+// WithKeepWithin adds the --keep-within flag.
+func (b *CommandBuilder) WithKeepWithin(duration string) *CommandBuilder {
+	if duration != "" {
+		b.args = append(b.args, "--keep-within", duration)
+	}
+	return b
+}
+
+// WithKeepWithinHourly adds the --keep-within-hourly flag.
+func (b *CommandBuilder) WithKeepWithinHourly(duration string) *CommandBuilder {
+	if duration != "" {
+		b.args = append(b.args, "--keep-within-hourly", duration)
+	}
+	return b
+}
+
+// WithKeepWithinDaily adds the --keep-within-daily flag.
+func (b *CommandBuilder) WithKeepWithinDaily(duration string) *CommandBuilder {
+	if duration != "" {
+		b.args = append(b.args, "--keep-within-daily", duration)
+	}
+	return b
+}
+
+// WithKeepWithinWeekly adds the --keep-within-weekly flag.
+func (b *CommandBuilder) WithKeepWithinWeekly(duration string) *CommandBuilder {
+	if duration != "" {
+		b.args = append(b.args, "--keep-within-weekly", duration)
+	}
+	return b
+}
+
+// WithKeepWithinMonthly adds the --keep-within-monthly flag.
+func (b *CommandBuilder) WithKeepWithinMonthly(duration string) *CommandBuilder {
+	if duration != "" {
+		b.args = append(b.args, "--keep-within-monthly", duration)
+	}
+	return b
+}
+
+// WithKeepWithinYearly adds the --keep-within-yearly flag.
+func (b *CommandBuilder) WithKeepWithinYearly(duration string) *CommandBuilder {
+	if duration != "" {
+		b.args = append(b.args, "--keep-within-yearly", duration)
+	}
+	return b
+}
+
+// WithKeepTag adds the --keep-tag flag.
+func (b *CommandBuilder) WithKeepTag(tag string) *CommandBuilder {
+	if tag != "" {
+		b.args = append(b.args, "--keep-tag", tag)
+	}
+	return b
+}
+
+// WithReadDataSubset adds the --read-data-subset flag.
+func (b *CommandBuilder) WithReadDataSubset(subset string) *CommandBuilder {
+	if subset != "" {
+		b.args = append(b.args, "--read-data-subset", subset)
+	}
+	return b
+}
+
 // WithGroupBy adds the --group-by flag.
 func (b *CommandBuilder) WithGroupBy(groupBy string) *CommandBuilder {
 	if groupBy != "" {
@@ -189,17 +275,31 @@ func (b *CommandBuilder) WithDryRun() *CommandBuilder {
 	return b
 }
 
-// WithPath adds a path argument.
+// WithPath adds a positional path argument.
 func (b *CommandBuilder) WithPath(path string) *CommandBuilder {
 	if path != "" {
-		b.args = append(b.args, path)
+		b.positional = append(b.positional, path)
 	}
 	return b
 }
 
-// WithPaths adds multiple path arguments.
+// WithPaths adds multiple positional path arguments.
 func (b *CommandBuilder) WithPaths(paths []string) *CommandBuilder {
-	b.args = append(b.args, paths...)
+	b.positional = append(b.positional, paths...)
+	return b
+}
+
+// WithSnapshotID adds a positional snapshot ID argument.
+func (b *CommandBuilder) WithSnapshotID(id string) *CommandBuilder {
+	if id != "" {
+		b.positional = append(b.positional, id)
+	}
+	return b
+}
+
+// WithSnapshotIDs adds multiple positional snapshot ID arguments.
+func (b *CommandBuilder) WithSnapshotIDs(ids []string) *CommandBuilder {
+	b.positional = append(b.positional, ids...)
 	return b
 }
 
@@ -227,7 +327,12 @@ func (b *CommandBuilder) WithMode(mode string) *CommandBuilder {
 
 // Build returns the final arguments slice.
 func (b *CommandBuilder) Build() []string {
-	return b.args
+	if len(b.positional) == 0 {
+		return b.args
+	}
+	args := append([]string{}, b.args...)
+	args = append(args, "--")
+	return append(args, b.positional...)
 }
 
 // String returns the command as a string for logging.
@@ -360,6 +360,29 @@ func TestCommandBuilder_WithKeepYearly(t *testing.T) {
 	}
 }
 
+func TestCommandBuilder_WithKeepWithin(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func(*CommandBuilder) *CommandBuilder
+		expected []string
+	}{
+		{"keep within", func(b *CommandBuilder) *CommandBuilder { return b.WithKeepWithin("2d") }, []string{"forget", "--keep-within", "2d"}},
+		{"keep within hourly", func(b *CommandBuilder) *CommandBuilder { return b.WithKeepWithinHourly("1d12h") }, []string{"forget", "--keep-within-hourly", "1d12h"}},
+		{"keep within daily", func(b *CommandBuilder) *CommandBuilder { return b.WithKeepWithinDaily("1m") }, []string{"forget", "--keep-within-daily", "1m"}},
+		{"keep within weekly", func(b *CommandBuilder) *CommandBuilder { return b.WithKeepWithinWeekly("3m") }, []string{"forget", "--keep-within-weekly", "3m"}},
+		{"keep within monthly", func(b *CommandBuilder) *CommandBuilder { return b.WithKeepWithinMonthly("1y6m") }, []string{"forget", "--keep-within-monthly", "1y6m"}},
+		{"keep within yearly", func(b *CommandBuilder) *CommandBuilder { return b.WithKeepWithinYearly("10y") }, []string{"forget", "--keep-within-yearly", "10y"}},
+		{"keep within empty", func(b *CommandBuilder) *CommandBuilder { return b.WithKeepWithin("") }, []string{"forget"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.build(NewCommand("forget")).Build()
+			assertArgs(t, tt.expected, result)
+		})
+	}
+}
+
 func TestCommandBuilder_WithGroupBy(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -380,6 +403,44 @@ func TestCommandBuilder_WithGroupBy(t *testing.T) {
 	}
 }
 
+func TestCommandBuilder_WithKeepTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		expected []string
+	}{
+		{"keep tag", "retention-protected", []string{"forget", "--keep-tag", "retention-protected"}},
+		{"empty keep tag", "", []string{"forget"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewCommand("forget").WithKeepTag(tt.tag)
+			result := cmd.Build()
+			assertArgs(t, tt.expected, result)
+		})
+	}
+}
+
+func TestCommandBuilder_WithReadDataSubset(t *testing.T) {
+	tests := []struct {
+		name     string
+		subset   string
+		expected []string
+	}{
+		{"rotating subset", "3/10", []string{"check", "--read-data-subset", "3/10"}},
+		{"empty subset", "", []string{"check"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewCommand("check").WithReadDataSubset(tt.subset)
+			result := cmd.Build()
+			assertArgs(t, tt.expected, result)
+		})
+	}
+}
+
 func TestCommandBuilder_WithPrune(t *testing.T) {
 	cmd := NewCommand("forget").WithPrune()
 	result := cmd.Build()
@@ -402,7 +463,7 @@ func TestCommandBuilder_WithPath(t *testing.T) {
 		path     string
 		expected []string
 	}{
-		{"with path", "/backup/data", []string{"backup", "/backup/data"}},
+		{"with path", "/backup/data", []string{"backup", "--", "/backup/data"}},
 		{"empty path", "", []string{"backup"}},
 	}
 
@@ -421,8 +482,8 @@ func TestCommandBuilder_WithPaths(t *testing.T) {
 		paths    []string
 		expected []string
 	}{
-		{"multiple paths", []string{"/data", "/config"}, []string{"backup", "/data", "/config"}},
-		{"single path", []string{"/data"}, []string{"backup", "/data"}},
+		{"multiple paths", []string{"/data", "/config"}, []string{"backup", "--", "/data", "/config"}},
+		{"single path", []string{"/data"}, []string{"backup", "--", "/data"}},
 		{"empty paths", []string{}, []string{"backup"}},
 	}
 
@@ -435,6 +496,27 @@ func TestCommandBuilder_WithPaths(t *testing.T) {
 	}
 }
 
+func TestCommandBuilder_WithSnapshotID(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		expected []string
+	}{
+		{"with snapshot ID", "abc12345", []string{"dump", "--json", "--", "abc12345", "/etc/hosts"}},
+		{"flag-like snapshot ID", "--password-command=sh", []string{"dump", "--json", "--", "--password-command=sh", "/etc/hosts"}},
+		{"empty snapshot ID", "", []string{"dump", "--json", "--", "/etc/hosts"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Flags added after the positional arguments still precede the separator
+			cmd := NewCommand("dump").WithSnapshotID(tt.id).WithPath("/etc/hosts").WithJSON()
+			result := cmd.Build()
+			assertArgs(t, tt.expected, result)
+		})
+	}
+}
+
 func TestCommandBuilder_WithArg(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -535,6 +617,7 @@ func TestCommandBuilder_Chaining(t *testing.T) {
 		"--tag", "app1",
 		"--exclude", "*.tmp",
 		"--exclude", "*.log",
+		"--",
 		"/data",
 		"/config",
 	}
@@ -603,6 +686,22 @@ func TestCommandBuilder_RestoreCommand(t *testing.T) {
 	assertArgs(t, expected, result)
 }
 
+func TestCommandBuilder_TagCommand(t *testing.T) {
+	cmd := NewCommand("tag").
+		WithAddTags([]string{"pinned", ""}).
+		WithRemoveTags([]string{"daily"}).
+		WithArg("abc12345")
+
+	expected := []string{
+		"tag",
+		"--add", "pinned",
+		"--remove", "daily",
+		"abc12345",
+	}
+
+	assertArgs(t, expected, cmd.Build())
+}
+
 // assertArgs is a helper function to compare argument slices
 func assertArgs(t *testing.T, expected, actual []string) {
 	t.Helper()
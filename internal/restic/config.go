@@ -0,0 +1,94 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ExecutorConfig configures how a DefaultExecutor runs restic commands.
+type ExecutorConfig struct {
+	// Retry controls the retries of commands failing with transient errors.
+	Retry RetryPolicy
+	// Timeouts limits the duration of every attempt of a restic command by its name,
+	// e.g. "check". Commands without a timeout only end with their context.
+	Timeouts map[string]time.Duration
+}
+
+// DefaultTimeouts are the timeouts of restic commands of new executors. Backup, restore
+// and copy run as long as their data requires and have no timeout.
+var DefaultTimeouts = map[string]time.Duration{
+	"init":      5 * time.Minute,
+	"unlock":    5 * time.Minute,
+	"cat":       2 * time.Minute,
+	"check":     10 * time.Minute,
+	"stats":     30 * time.Minute,
+	"snapshots": 5 * time.Minute,
+	"forget":    30 * time.Minute,
+	"prune":     6 * time.Hour,
+	"dump":      30 * time.Minute,
+	"diff":      30 * time.Minute,
+	"ls":        10 * time.Minute,
+	"find":      30 * time.Minute,
+	"tag":       5 * time.Minute,
+}
+
+// timeoutCommands are the restic commands run by the executor.
+var timeoutCommands = []string{"init", "unlock", "cat", "check", "stats", "snapshots", "backup",
+	"restore", "forget", "prune", "dump", "diff", "ls", "find", "tag", "copy"}
+
+// DefaultExecutorConfig returns the configuration of new executors.
+func DefaultExecutorConfig() ExecutorConfig {
+	return ExecutorConfig{
+		Retry:    DefaultRetryPolicy,
+		Timeouts: maps.Clone(DefaultTimeouts),
+	}
+}
+
+// ParseTimeouts parses a comma-separated list of command=duration pairs, e.g.
+// "check=1h,prune=12h", and returns the default timeouts overridden by them. A duration
+// of 0 removes the timeout of a command.
+func ParseTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := maps.Clone(DefaultTimeouts)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		command, raw, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid restic timeout %q, expected command=duration", pair)
+		}
+		if !slices.Contains(timeoutCommands, command) {
+			return nil, fmt.Errorf("unknown restic command %q, expected one of %s", command, strings.Join(timeoutCommands, ", "))
+		}
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid timeout %q for %s, expected a non-negative duration", raw, command)
+		}
+		if timeout == 0 {
+			delete(timeouts, command)
+			continue
+		}
+		timeouts[command] = timeout
+	}
+	return timeouts, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	timeouts, err := ParseTimeouts("check=1h, prune=0,backup=12h,")
+	if err != nil {
+		t.Fatalf("ParseTimeouts() error = %v", err)
+	}
+	if timeouts["check"] != time.Hour {
+		t.Errorf("check timeout = %v, want 1h", timeouts["check"])
+	}
+	if _, found := timeouts["prune"]; found {
+		t.Errorf("expected the prune timeout to be removed, got %v", timeouts["prune"])
+	}
+	if timeouts["backup"] != 12*time.Hour {
+		t.Errorf("backup timeout = %v, want 12h", timeouts["backup"])
+	}
+	if timeouts["stats"] != DefaultTimeouts["stats"] {
+		t.Errorf("stats timeout = %v, want the default %v", timeouts["stats"], DefaultTimeouts["stats"])
+	}
+	if DefaultTimeouts["check"] != 10*time.Minute {
+		t.Error("ParseTimeouts() modified the default timeouts")
+	}
+
+	for _, value := range []string{"check", "backups=1h", "check=soon", "check=-1m"} {
+		if _, err := ParseTimeouts(value); err == nil {
+			t.Errorf("ParseTimeouts(%q) expected an error", value)
+		}
+	}
+}
+
+// TestDefaultExecutor_Timeout tests that hung commands are killed and not retried
+func TestDefaultExecutor_Timeout(t *testing.T) {
+	dir := t.TempDir()
+
+	// The fake restic binary hangs and counts its runs
+	binary := dir + "/restic"
+	runsFile := dir + "/runs"
+	script := `#!/bin/sh
+echo run >> ` + runsFile + `
+exec sleep 60
+`
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake restic: %v", err)
+	}
+
+	executor := NewExecutorWithBinary(binary, getTestLogger()).WithConfig(ExecutorConfig{
+		Retry:    RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		Timeouts: map[string]time.Duration{"cat": 100 * time.Millisecond},
+	})
+
+	start := time.Now()
+	_, err := executor.CatConfig(context.Background(), Credentials{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if IsRetryable(err) {
+		t.Error("expected the timeout not to be retryable")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the command to be killed after its timeout, took %v", elapsed)
+	}
+
+	data, err := os.ReadFile(runsFile)
+	if err != nil {
+		t.Fatalf("failed to read runs: %v", err)
+	}
+	if n := len(data) / len("run\n"); n != 1 {
+		t.Errorf("expected 1 run, got %d", n)
+	}
+}
@@ -20,7 +20,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -36,8 +39,12 @@ type Executor interface {
 	// Unlock removes stale locks from the repository.
 	Unlock(ctx context.Context, creds Credentials) error
 
+	// CatConfig reads the repository config, verifying that the repository is
+	// reachable with the credentials without reading any data.
+	CatConfig(ctx context.Context, creds Credentials) (*RepositoryConfig, error)
+
 	// Check verifies the repository integrity.
-	Check(ctx context.Context, creds Credentials) (*CheckResult, error)
+	Check(ctx context.Context, creds Credentials, opts CheckOptions) (*CheckResult, error)
 
 	// Stats returns repository statistics.
 	Stats(ctx context.Context, creds Credentials, opts StatsOptions) (*RepoStats, error)
@@ -56,12 +63,38 @@ type Executor interface {
 
 	// Prune removes unused data from the repository.
 	Prune(ctx context.Context, creds Credentials) (*PruneResult, error)
+
+	// Dump returns the content of a file or a tar archive of a directory of a snapshot.
+	Dump(ctx context.Context, creds Credentials, opts DumpOptions) ([]byte, error)
+
+	// Diff returns the paths changed between two snapshots.
+	Diff(ctx context.Context, creds Credentials, snapshotA, snapshotB string) (*DiffResult, error)
+
+	// Ls lists the entries of a snapshot below a path. An empty path lists all entries.
+	Ls(ctx context.Context, creds Credentials, snapshotID, path string) ([]Node, error)
+
+	// Find searches all snapshots for entries matching a pattern.
+	Find(ctx context.Context, creds Credentials, pattern string) ([]FindResult, error)
+
+	// Tag adds and removes tags of a snapshot.
+	Tag(ctx context.Context, creds Credentials, snapshotID string, opts TagOptions) error
+
+	// Copy copies snapshots from the repository of opts.From into the repository of creds.
+	Copy(ctx context.Context, creds Credentials, opts CopyOptions) error
 }
 
+// ErrDumpTooLarge is returned by Dump if the dumped data exceeds DumpOptions.MaxSize.
+var ErrDumpTooLarge = errors.New("dumped data exceeds the maximum size")
+
+// commandWaitDelay is how long a timed out restic command may keep its output open
+// after being killed, e.g. by a hung rclone child process.
+const commandWaitDelay = 10 * time.Second
+
 // DefaultExecutor implements Executor using the restic binary.
 type DefaultExecutor struct {
 	binary string
 	log    logr.Logger
+	config ExecutorConfig
 }
 
 // NewExecutor creates a new restic executor.
@@ -69,6 +102,7 @@ func NewExecutor(log logr.Logger) *DefaultExecutor {
 	return &DefaultExecutor{
 		binary: "restic",
 		log:    log,
+		config: DefaultExecutorConfig(),
 	}
 }
 
@@ -80,9 +114,16 @@ func NewExecutorWithBinary(binary string, log logr.Logger) *DefaultExecutor {
 	return &DefaultExecutor{
 		binary: binary,
 		log:    log,
+		config: DefaultExecutorConfig(),
 	}
 }
 
+// WithConfig sets the retries and timeouts of restic commands.
+func (e *DefaultExecutor) WithConfig(config ExecutorConfig) *DefaultExecutor {
+	e.config = config
+	return e
+}
+
 func (e *DefaultExecutor) buildEnv(creds Credentials) []string {
 	env := []string{
 		fmt.Sprintf("RESTIC_REPOSITORY=%s", creds.Repository),
@@ -95,6 +136,25 @@ func (e *DefaultExecutor) buildEnv(creds Credentials) []string {
 	if creds.AWSSecretAccessKey != "" {
 		env = append(env, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", creds.AWSSecretAccessKey))
 	}
+
+	optional := []struct {
+		name  string
+		value string
+	}{
+		{"AZURE_ACCOUNT_NAME", creds.AzureAccountName},
+		{"AZURE_ACCOUNT_KEY", creds.AzureAccountKey},
+		{"AZURE_ACCOUNT_SAS", creds.AzureAccountSAS},
+		{"GOOGLE_PROJECT_ID", creds.GoogleProjectID},
+		{"B2_ACCOUNT_ID", creds.B2AccountID},
+		{"B2_ACCOUNT_KEY", creds.B2AccountKey},
+		{"RESTIC_REST_USERNAME", creds.RESTUsername},
+		{"RESTIC_REST_PASSWORD", creds.RESTPassword},
+	}
+	for _, v := range optional {
+		if v.value != "" {
+			env = append(env, fmt.Sprintf("%s=%s", v.name, v.value))
+		}
+	}
 	if creds.CacheDir != "" {
 		env = append(env, fmt.Sprintf("RESTIC_CACHE_DIR=%s", creds.CacheDir))
 	}
@@ -103,21 +163,105 @@ func (e *DefaultExecutor) buildEnv(creds Credentials) []string {
 }
 
 func (e *DefaultExecutor) run(ctx context.Context, creds Credentials, args []string) ([]byte, []byte, error) {
-	cmd := exec.CommandContext(ctx, e.binary, args...)
+	var stdout bytes.Buffer
+	var stderr []byte
+	err := e.retry(ctx, args, func() error {
+		stdout.Reset()
+		var err error
+		stderr, err = e.runTo(ctx, creds, nil, args, &stdout)
+		return err
+	})
+	return stdout.Bytes(), stderr, err
+}
+
+// runWithProgress runs restic and returns its output. If progress is set, it is called
+// with the status lines while restic runs.
+func (e *DefaultExecutor) runWithProgress(ctx context.Context, creds Credentials, args []string, progress ProgressFunc) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := e.retry(ctx, args, func() error {
+		stdout.Reset()
+		var w io.Writer = &stdout
+		if progress != nil {
+			w = newProgressWriter(&stdout, progress)
+		}
+		_, err := e.runTo(ctx, creds, nil, args, w)
+		return err
+	})
+	return stdout.Bytes(), err
+}
+
+// runTo runs restic writing its output to stdout and returns the redacted error output.
+// The repository and password of from, if set, are passed as the source of restic copy.
+// A command running longer than its timeout is killed and fails with an error wrapping
+// context.DeadlineExceeded.
+func (e *DefaultExecutor) runTo(ctx context.Context, creds Credentials, from *Credentials, args []string, stdout io.Writer) ([]byte, error) {
+	cmdCtx := ctx
+	timeout := e.config.Timeouts[args[0]]
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		cmdCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(cmdCtx, e.binary, args...)
+	cmd.WaitDelay = commandWaitDelay
 	cmd.Env = e.buildEnv(creds)
+	if from != nil {
+		cmd.Env = append(cmd.Env,
+			fmt.Sprintf("RESTIC_FROM_REPOSITORY=%s", from.Repository),
+			fmt.Sprintf("RESTIC_FROM_PASSWORD=%s", from.Password),
+		)
+	}
+
+	// The GCS backend reads the service account key from a file
+	if creds.GoogleApplicationCredentials != "" {
+		file, err := writeTempFile("restic-gcs-*.json", creds.GoogleApplicationCredentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write GCS credentials: %w", err)
+		}
+		defer func() { _ = os.Remove(file) }()
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GOOGLE_APPLICATION_CREDENTIALS=%s", file))
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
 	cmd.Stderr = &stderr
 
 	e.log.V(1).Info("executing restic command", "args", strings.Join(args, " "))
 
 	err := cmd.Run()
+	errOutput := redactOutput(stderr.String(), creds)
+	if from != nil {
+		errOutput = redactOutput(errOutput, *from)
+	}
+	if err != nil && cmdCtx.Err() != nil && ctx.Err() == nil {
+		e.log.Error(err, "restic command timed out", "command", args[0], "timeout", timeout)
+		return []byte(errOutput), fmt.Errorf("restic %s timed out after %s: %w", args[0], timeout, context.DeadlineExceeded)
+	}
 	if err != nil {
-		e.log.Error(err, "restic command failed", "stderr", stderr.String())
+		e.log.Error(err, "restic command failed", "stderr", errOutput)
+		err = &CommandError{Err: err, Stderr: errOutput}
 	}
 
-	return stdout.Bytes(), stderr.Bytes(), err
+	return []byte(errOutput), err
+}
+
+// writeTempFile writes content to a new temporary file readable only by the operator.
+func writeTempFile(pattern, content string) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
 }
 
 // Init initializes a new repository.
@@ -147,10 +291,26 @@ func (e *DefaultExecutor) Unlock(ctx context.Context, creds Credentials) error {
 	return nil
 }
 
+// CatConfig reads the repository config, verifying that the repository is
+// reachable with the credentials without reading any data.
+func (e *DefaultExecutor) CatConfig(ctx context.Context, creds Credentials) (*RepositoryConfig, error) {
+	args := NewCommand("cat").WithArg("config").Build()
+	stdout, stderr, err := e.run(ctx, creds, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository config: %w: %s", err, string(stderr))
+	}
+
+	var config RepositoryConfig
+	if err := json.Unmarshal(stdout, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse repository config: %w", err)
+	}
+	return &config, nil
+}
+
 // Check verifies the repository integrity.
-func (e *DefaultExecutor) Check(ctx context.Context, creds Credentials) (*CheckResult, error) {
+func (e *DefaultExecutor) Check(ctx context.Context, creds Credentials, opts CheckOptions) (*CheckResult, error) {
 	start := time.Now()
-	args := NewCommand("check").Build()
+	args := NewCommand("check").WithReadDataSubset(opts.ReadDataSubset).Build()
 	_, stderr, err := e.run(ctx, creds, args)
 
 	stderrStr := string(stderr)
@@ -241,54 +401,19 @@ func (e *DefaultExecutor) Backup(ctx context.Context, creds Credentials, opts Ba
 
 	args := cmd.Build()
 
-	stdout, _, err := e.run(ctx, creds, args)
+	stdout, err := e.runWithProgress(ctx, creds, args, opts.Progress)
 	if err != nil {
 		return nil, fmt.Errorf("backup failed: %w", err)
 	}
 
-	// Parse the JSON output (last line contains summary)
-	lines := strings.Split(strings.TrimSpace(string(stdout)), "\n")
-	if len(lines) == 0 {
-		return nil, fmt.Errorf("no output from backup command")
-	}
-
 	// Parse the summary message
-	var summary struct {
-		MessageType         string `json:"message_type"`
-		SnapshotID          string `json:"snapshot_id"`
-		FilesNew            int64  `json:"files_new"`
-		FilesChanged        int64  `json:"files_changed"`
-		FilesUnmodified     int64  `json:"files_unmodified"`
-		DirsNew             int64  `json:"dirs_new"`
-		DirsChanged         int64  `json:"dirs_changed"`
-		DirsUnmodified      int64  `json:"dirs_unmodified"`
-		DataAdded           uint64 `json:"data_added"`
-		TotalFilesProcessed int64  `json:"total_files_processed"`
-		TotalBytesProcessed uint64 `json:"total_bytes_processed"`
-	}
-
-	// Find the summary line
-	for i := len(lines) - 1; i >= 0; i-- {
-		if err := json.Unmarshal([]byte(lines[i]), &summary); err == nil {
-			if summary.MessageType == "summary" {
-				break
-			}
-		}
+	result, err := ParseBackupSummary(string(stdout))
+	if err != nil {
+		result = &BackupResult{}
 	}
+	result.Duration = time.Since(start)
 
-	return &BackupResult{
-		SnapshotID:      summary.SnapshotID,
-		FilesNew:        summary.FilesNew,
-		FilesChanged:    summary.FilesChanged,
-		FilesUnmodified: summary.FilesUnmodified,
-		DirsNew:         summary.DirsNew,
-		DirsChanged:     summary.DirsChanged,
-		DirsUnmodified:  summary.DirsUnmodified,
-		DataAdded:       summary.DataAdded,
-		TotalFiles:      summary.TotalFilesProcessed,
-		TotalBytes:      summary.TotalBytesProcessed,
-		Duration:        time.Since(start),
-	}, nil
+	return result, nil
 }
 
 // Restore restores data from a snapshot.
@@ -299,22 +424,37 @@ func (e *DefaultExecutor) Restore(ctx context.Context, creds Credentials, opts R
 		WithTarget(opts.Target).
 		WithIncludes(opts.Include).
 		WithExcludes(opts.Exclude).
-		WithArg(opts.SnapshotID)
+		WithSnapshotID(opts.SnapshotID)
 
 	if opts.Verify {
 		cmd.WithArg("--verify")
 	}
+	if opts.Sparse {
+		cmd.WithArg("--sparse")
+	}
+	if opts.OverwriteMode != "" {
+		cmd.WithArgs([]string{"--overwrite", opts.OverwriteMode})
+	}
+	if opts.Progress != nil {
+		cmd.WithJSON()
+	}
 
 	args := cmd.Build()
 
-	_, _, err := e.run(ctx, creds, args)
+	stdout, err := e.runWithProgress(ctx, creds, args, opts.Progress)
 	if err != nil {
 		return nil, fmt.Errorf("restore failed: %w", err)
 	}
 
-	return &RestoreResult{
-		Duration: time.Since(start),
-	}, nil
+	result := &RestoreResult{}
+	if opts.Progress != nil {
+		if summary, err := ParseRestoreSummary(string(stdout)); err == nil {
+			result = summary
+		}
+	}
+	result.Duration = time.Since(start)
+
+	return result, nil
 }
 
 // Forget removes snapshots according to the retention policy.
@@ -328,7 +468,13 @@ func (e *DefaultExecutor) Forget(ctx context.Context, creds Credentials, opts Fo
 		WithKeepDaily(opts.KeepDaily).
 		WithKeepWeekly(opts.KeepWeekly).
 		WithKeepMonthly(opts.KeepMonthly).
-		WithKeepYearly(opts.KeepYearly)
+		WithKeepYearly(opts.KeepYearly).
+		WithKeepWithin(opts.KeepWithin).
+		WithKeepWithinHourly(opts.KeepWithinHourly).
+		WithKeepWithinDaily(opts.KeepWithinDaily).
+		WithKeepWithinWeekly(opts.KeepWithinWeekly).
+		WithKeepWithinMonthly(opts.KeepWithinMonthly).
+		WithKeepWithinYearly(opts.KeepWithinYearly)
 
 	if len(opts.GroupBy) > 0 {
 		cmd.WithGroupBy(strings.Join(opts.GroupBy, ","))
@@ -378,12 +524,141 @@ func (e *DefaultExecutor) Prune(ctx context.Context, creds Credentials) (*PruneR
 	start := time.Now()
 	args := NewCommand("prune").Build()
 
-	_, _, err := e.run(ctx, creds, args)
+	stdout, _, err := e.run(ctx, creds, args)
 	if err != nil {
 		return nil, fmt.Errorf("prune failed: %w", err)
 	}
 
-	return &PruneResult{
-		Duration: time.Since(start),
-	}, nil
+	result := ParsePruneOutput(string(stdout))
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// limitedBuffer is a buffer refusing writes beyond its limit. A limit of zero means
+// unlimited. The buffer isn't embedded, its ReadFrom would bypass the limit.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.buf.Len()+len(p) > b.limit {
+		b.exceeded = true
+		return 0, ErrDumpTooLarge
+	}
+	return b.buf.Write(p)
+}
+
+// Dump returns the content of a file or a tar archive of a directory of a snapshot.
+// Dumps larger than opts.MaxSize are aborted with ErrDumpTooLarge.
+func (e *DefaultExecutor) Dump(ctx context.Context, creds Credentials, opts DumpOptions) ([]byte, error) {
+	args := NewCommand("dump").
+		WithArgs(opts.ExtraArgs).
+		WithSnapshotID(opts.SnapshotID).
+		WithPath(opts.Path).
+		Build()
+
+	var stdout *limitedBuffer
+	err := e.retry(ctx, args, func() error {
+		stdout = &limitedBuffer{limit: opts.MaxSize}
+		_, err := e.runTo(ctx, creds, nil, args, stdout)
+		// restic is killed by the closed pipe once the limit is reached
+		if err != nil && stdout.exceeded {
+			return ErrDumpTooLarge
+		}
+		return err
+	})
+	if errors.Is(err, ErrDumpTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dump failed: %w", err)
+	}
+
+	return stdout.buf.Bytes(), nil
+}
+
+// Diff returns the paths changed between two snapshots.
+func (e *DefaultExecutor) Diff(ctx context.Context, creds Credentials, snapshotA, snapshotB string) (*DiffResult, error) {
+	args := NewCommand("diff").
+		WithJSON().
+		WithSnapshotID(snapshotA).
+		WithSnapshotID(snapshotB).
+		Build()
+
+	stdout, _, err := e.run(ctx, creds, args)
+	if err != nil {
+		return nil, fmt.Errorf("diff failed: %w", err)
+	}
+
+	return ParseDiffOutput(string(stdout))
+}
+
+// Ls lists the entries of a snapshot below a path. An empty path lists all entries.
+func (e *DefaultExecutor) Ls(ctx context.Context, creds Credentials, snapshotID, path string) ([]Node, error) {
+	args := NewCommand("ls").
+		WithJSON().
+		WithSnapshotID(snapshotID).
+		WithPath(path).
+		Build()
+
+	stdout, _, err := e.run(ctx, creds, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot: %w", err)
+	}
+
+	return ParseLsOutput(string(stdout))
+}
+
+// Find searches all snapshots for entries matching a pattern.
+func (e *DefaultExecutor) Find(ctx context.Context, creds Credentials, pattern string) ([]FindResult, error) {
+	args := NewCommand("find").
+		WithJSON().
+		WithPath(pattern).
+		Build()
+
+	stdout, _, err := e.run(ctx, creds, args)
+	if err != nil {
+		return nil, fmt.Errorf("find failed: %w", err)
+	}
+
+	return ParseFindOutput(stdout)
+}
+
+// Tag adds and removes tags of a snapshot.
+func (e *DefaultExecutor) Tag(ctx context.Context, creds Credentials, snapshotID string, opts TagOptions) error {
+	if len(opts.Add) == 0 && len(opts.Remove) == 0 {
+		return nil
+	}
+	args := NewCommand("tag").
+		WithAddTags(opts.Add).
+		WithRemoveTags(opts.Remove).
+		WithSnapshotID(snapshotID).
+		Build()
+
+	if _, stderr, err := e.run(ctx, creds, args); err != nil {
+		return fmt.Errorf("failed to tag snapshot: %w: %s", err, string(stderr))
+	}
+	return nil
+}
+
+// Copy copies snapshots from the repository of opts.From into the repository of creds.
+// Snapshots already copied are skipped by restic.
+func (e *DefaultExecutor) Copy(ctx context.Context, creds Credentials, opts CopyOptions) error {
+	cmd := NewCommand("copy").WithTags(opts.Tags)
+	for _, host := range opts.Hosts {
+		cmd.WithHost(host)
+	}
+	args := cmd.WithArgs(opts.ExtraArgs).WithSnapshotIDs(opts.SnapshotIDs).Build()
+
+	err := e.retry(ctx, args, func() error {
+		_, err := e.runTo(ctx, creds, &opts.From, args, io.Discard)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("copy failed: %w", err)
+	}
+	return nil
 }
@@ -18,6 +18,7 @@ package restic
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"strings"
@@ -119,6 +120,29 @@ func TestDefaultExecutor_buildEnv(t *testing.T) {
 				"RESTIC_CACHE_DIR=/tmp/restic-cache": true,
 			},
 		},
+		{
+			name: "with Azure, B2 and REST server credentials",
+			creds: Credentials{
+				Repository:       "azure:backups:/",
+				Password:         "secret",
+				AzureAccountName: "account",
+				AzureAccountKey:  "key",
+				B2AccountID:      "b2-id",
+				B2AccountKey:     "b2-key",
+				RESTUsername:     "user",
+				RESTPassword:     "pass",
+			},
+			expected: map[string]bool{
+				"RESTIC_REPOSITORY=azure:backups:/": true,
+				"RESTIC_PASSWORD=secret":            true,
+				"AZURE_ACCOUNT_NAME=account":        true,
+				"AZURE_ACCOUNT_KEY=key":             true,
+				"B2_ACCOUNT_ID=b2-id":               true,
+				"B2_ACCOUNT_KEY=b2-key":             true,
+				"RESTIC_REST_USERNAME=user":         true,
+				"RESTIC_REST_PASSWORD=pass":         true,
+			},
+		},
 		{
 			name: "all options",
 			creds: Credentials{
@@ -221,7 +245,7 @@ func TestDefaultExecutor_Check_BinaryNotFound(t *testing.T) {
 		Password:   "test",
 	}
 
-	result, err := executor.Check(context.Background(), creds)
+	result, err := executor.Check(context.Background(), creds, CheckOptions{})
 	if err == nil {
 		t.Error("expected error when binary doesn't exist")
 	}
@@ -233,6 +257,21 @@ func TestDefaultExecutor_Check_BinaryNotFound(t *testing.T) {
 	}
 }
 
+// TestDefaultExecutor_CatConfig_BinaryNotFound tests CatConfig with a non-existent binary
+func TestDefaultExecutor_CatConfig_BinaryNotFound(t *testing.T) {
+	log := getTestLogger()
+	executor := NewExecutorWithBinary("/nonexistent/restic", log)
+
+	creds := Credentials{
+		Repository: "local:/tmp/test-repo",
+		Password:   "test",
+	}
+
+	if _, err := executor.CatConfig(context.Background(), creds); err == nil {
+		t.Error("expected error when binary doesn't exist")
+	}
+}
+
 // TestDefaultExecutor_Stats_BinaryNotFound tests Stats with a non-existent binary
 func TestDefaultExecutor_Stats_BinaryNotFound(t *testing.T) {
 	log := getTestLogger()
@@ -343,6 +382,111 @@ func TestDefaultExecutor_Prune_BinaryNotFound(t *testing.T) {
 	}
 }
 
+// TestDefaultExecutor_Dump_BinaryNotFound tests Dump with a non-existent binary
+func TestDefaultExecutor_Dump_BinaryNotFound(t *testing.T) {
+	log := getTestLogger()
+	executor := NewExecutorWithBinary("/nonexistent/restic", log)
+
+	creds := Credentials{
+		Repository: "local:/tmp/test-repo",
+		Password:   "test",
+	}
+
+	_, err := executor.Dump(context.Background(), creds, DumpOptions{SnapshotID: "latest", Path: "/etc/hosts"})
+	if err == nil || errors.Is(err, ErrDumpTooLarge) {
+		t.Errorf("expected error when binary doesn't exist, got %v", err)
+	}
+}
+
+// TestDefaultExecutor_Dump_MaxSize tests that dumps beyond the maximum size are aborted
+func TestDefaultExecutor_Dump_MaxSize(t *testing.T) {
+	dir := t.TempDir()
+
+	// The fake restic binary prints the dumped path: restic dump -- <snapshot> <path>
+	binary := dir + "/restic"
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\ncat \"$4\"\n"), 0755); err != nil {
+		t.Fatalf("failed to write fake restic: %v", err)
+	}
+	file := dir + "/config.yaml"
+	if err := os.WriteFile(file, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	executor := NewExecutorWithBinary(binary, getTestLogger())
+	opts := DumpOptions{SnapshotID: "latest", Path: file}
+
+	data, err := executor.Dump(context.Background(), Credentials{}, opts)
+	if err != nil {
+		t.Fatalf("expected the dump to succeed, got %v", err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("expected the file content, got %q", data)
+	}
+
+	opts.MaxSize = 4
+	if _, err := executor.Dump(context.Background(), Credentials{}, opts); !errors.Is(err, ErrDumpTooLarge) {
+		t.Errorf("expected ErrDumpTooLarge, got %v", err)
+	}
+}
+
+// TestDefaultExecutor_Tag tests the arguments passed to restic tag
+func TestDefaultExecutor_Tag(t *testing.T) {
+	dir := t.TempDir()
+
+	// The fake restic binary records its arguments
+	binary := dir + "/restic"
+	argsFile := dir + "/args"
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho \"$@\" > "+argsFile+"\n"), 0755); err != nil {
+		t.Fatalf("failed to write fake restic: %v", err)
+	}
+
+	executor := NewExecutorWithBinary(binary, getTestLogger())
+	opts := TagOptions{Add: []string{"pinned"}, Remove: []string{"daily"}}
+	if err := executor.Tag(context.Background(), Credentials{}, "abc12345", opts); err != nil {
+		t.Fatalf("expected the tag to succeed, got %v", err)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("failed to read arguments: %v", err)
+	}
+	if string(args) != "tag --add pinned --remove daily -- abc12345\n" {
+		t.Errorf("unexpected arguments %q", args)
+	}
+}
+
+// TestDefaultExecutor_Copy tests the arguments and source repository passed to restic copy
+func TestDefaultExecutor_Copy(t *testing.T) {
+	dir := t.TempDir()
+
+	// The fake restic binary records its arguments and the source repository
+	binary := dir + "/restic"
+	argsFile := dir + "/args"
+	script := "#!/bin/sh\necho \"$@ $RESTIC_FROM_REPOSITORY $RESTIC_FROM_PASSWORD\" > " + argsFile + "\n"
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake restic: %v", err)
+	}
+
+	executor := NewExecutorWithBinary(binary, getTestLogger())
+	creds := Credentials{Repository: "s3:s3.amazonaws.com/offsite", Password: "offsite"}
+	opts := CopyOptions{
+		From:  Credentials{Repository: "s3:minio.local/primary", Password: "primary"},
+		Hosts: []string{"app"},
+		Tags:  []string{"daily"},
+	}
+	if err := executor.Copy(context.Background(), creds, opts); err != nil {
+		t.Fatalf("expected the copy to succeed, got %v", err)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("failed to read arguments: %v", err)
+	}
+	if string(args) != "copy --tag daily --host app s3:minio.local/primary primary\n" {
+		t.Errorf("unexpected arguments %q", args)
+	}
+}
+
 // TestDefaultExecutor_ContextCancellation tests that context cancellation works
 func TestDefaultExecutor_ContextCancellation(t *testing.T) {
 	// Skip if restic is not installed
@@ -366,7 +510,7 @@ func TestDefaultExecutor_ContextCancellation(t *testing.T) {
 	// We don't assert the error because it may be nil (if restic isn't installed)
 	// or non-nil (context cancellation or restic error). The main point is that
 	// the context is passed through and the code doesn't panic or hang.
-	_, _ = executor.Check(ctx, creds)
+	_, _ = executor.Check(ctx, creds, CheckOptions{})
 }
 
 // Integration tests that require restic binary
@@ -430,7 +574,7 @@ func TestDefaultExecutor_Integration_Check(t *testing.T) {
 	}
 
 	// Check the repository
-	result, err := executor.Check(context.Background(), creds)
+	result, err := executor.Check(context.Background(), creds, CheckOptions{})
 	if err != nil {
 		t.Fatalf("check failed: %v", err)
 	}
@@ -444,6 +588,40 @@ func TestDefaultExecutor_Integration_Check(t *testing.T) {
 	}
 }
 
+func TestDefaultExecutor_Integration_CatConfig(t *testing.T) {
+	if _, err := exec.LookPath("restic"); err != nil {
+		t.Skip("restic binary not found, skipping integration test")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "restic-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	executor := NewExecutor(getTestLogger())
+	creds := Credentials{
+		Repository: "local:" + tmpDir,
+		Password:   "test-password",
+	}
+
+	if err := executor.Init(context.Background(), creds); err != nil {
+		t.Fatalf("failed to initialize repository: %v", err)
+	}
+	config, err := executor.CatConfig(context.Background(), creds)
+	if err != nil {
+		t.Fatalf("cat config failed: %v", err)
+	}
+	if len(config.ID) != 64 {
+		t.Errorf("expected a 64 character repository ID, got %q", config.ID)
+	}
+
+	creds.Password = "wrong-password"
+	if _, err := executor.CatConfig(context.Background(), creds); err == nil {
+		t.Error("expected cat config to fail with a wrong password")
+	}
+}
+
 func TestDefaultExecutor_Integration_Snapshots_Empty(t *testing.T) {
 	if _, err := exec.LookPath("restic"); err != nil {
 		t.Skip("restic binary not found, skipping integration test")
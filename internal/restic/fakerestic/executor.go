@@ -0,0 +1,318 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakerestic provides a restic executor and Job helpers for testing the
+// controllers without a restic binary or a cluster running Jobs.
+package fakerestic
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// Errors of restic commands as the executor returns them, for programming failures.
+var (
+	// ErrUnreachable is the error of a backend that can't be reached.
+	ErrUnreachable error = &restic.CommandError{Err: errors.New("exit status 1"),
+		Stderr: "Fatal: unable to open config file: dial tcp 10.0.0.1:8000: connect: connection refused"}
+	// ErrWrongPassword is the error of a repository opened with a wrong password.
+	ErrWrongPassword error = &restic.CommandError{Err: errors.New("exit status 12"),
+		Stderr: "Fatal: wrong password or no key found"}
+	// ErrNoRepository is the error of a repository that was not initialized.
+	ErrNoRepository error = &restic.CommandError{Err: errors.New("exit status 10"),
+		Stderr: "Fatal: repository does not exist: unable to open config file: 404 Not Found"}
+	// ErrLocked is the error of a repository locked by another restic process.
+	ErrLocked error = &restic.CommandError{Err: errors.New("exit status 11"),
+		Stderr: "unable to create lock in backend: repository is already locked by PID 42 on host (UID 0, GID 0)\nlock was created at 2025-01-12 02:00:00 (10m0s ago)"}
+)
+
+// Call is a recorded call of the executor.
+type Call struct {
+	// Method is the name of the Executor method, e.g. "Snapshots".
+	Method string
+	// Repository is the repository URL of the credentials.
+	Repository string
+	// Options are the options of the call, e.g. restic.BackupOptions, or the snapshot
+	// IDs, path or pattern of Diff, Ls, Find and Tag.
+	Options []any
+}
+
+// Executor is a restic.Executor running no restic command. Operations succeed with
+// an empty result unless the function of the method is set or an error was programmed
+// with Fail. All calls are recorded. An Executor is safe for concurrent use.
+type Executor struct {
+	InitFunc      func(ctx context.Context, creds restic.Credentials) error
+	UnlockFunc    func(ctx context.Context, creds restic.Credentials) error
+	CatConfigFunc func(ctx context.Context, creds restic.Credentials) (*restic.RepositoryConfig, error)
+	CheckFunc     func(ctx context.Context, creds restic.Credentials, opts restic.CheckOptions) (*restic.CheckResult, error)
+	StatsFunc     func(ctx context.Context, creds restic.Credentials, opts restic.StatsOptions) (*restic.RepoStats, error)
+	SnapshotsFunc func(ctx context.Context, creds restic.Credentials) ([]restic.Snapshot, error)
+	BackupFunc    func(ctx context.Context, creds restic.Credentials, opts restic.BackupOptions) (*restic.BackupResult, error)
+	RestoreFunc   func(ctx context.Context, creds restic.Credentials, opts restic.RestoreOptions) (*restic.RestoreResult, error)
+	ForgetFunc    func(ctx context.Context, creds restic.Credentials, opts restic.ForgetOptions) (*restic.ForgetResult, error)
+	PruneFunc     func(ctx context.Context, creds restic.Credentials) (*restic.PruneResult, error)
+	DumpFunc      func(ctx context.Context, creds restic.Credentials, opts restic.DumpOptions) ([]byte, error)
+	DiffFunc      func(ctx context.Context, creds restic.Credentials, snapshotA, snapshotB string) (*restic.DiffResult, error)
+	LsFunc        func(ctx context.Context, creds restic.Credentials, snapshotID, path string) ([]restic.Node, error)
+	FindFunc      func(ctx context.Context, creds restic.Credentials, pattern string) ([]restic.FindResult, error)
+	TagFunc       func(ctx context.Context, creds restic.Credentials, snapshotID string, opts restic.TagOptions) error
+	CopyFunc      func(ctx context.Context, creds restic.Credentials, opts restic.CopyOptions) error
+
+	mu     sync.Mutex
+	calls  []Call
+	errors map[string]error
+	snaps  []restic.Snapshot
+	repoID string
+}
+
+var _ restic.Executor = &Executor{}
+
+// New returns an executor on which every operation succeeds.
+func New() *Executor {
+	return &Executor{}
+}
+
+// WithSnapshots makes Snapshots return the given snapshots and Stats count them.
+func (e *Executor) WithSnapshots(snapshots ...restic.Snapshot) *Executor {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.snaps = snapshots
+	return e
+}
+
+// WithRepositoryID makes CatConfig return a repository config with the given ID.
+func (e *Executor) WithRepositoryID(id string) *Executor {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.repoID = id
+	return e
+}
+
+// Fail makes the method fail with err until it is set to nil, regardless of the
+// function of the method.
+func (e *Executor) Fail(method string, err error) *Executor {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.errors == nil {
+		e.errors = map[string]error{}
+	}
+	e.errors[method] = err
+	return e
+}
+
+// Calls returns the recorded calls of the method, all calls if method is empty.
+func (e *Executor) Calls(method string) []Call {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var calls []Call
+	for _, call := range e.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls.
+func (e *Executor) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = nil
+}
+
+// record records a call and returns the programmed error of the method.
+func (e *Executor) record(method string, creds restic.Credentials, options ...any) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, Call{Method: method, Repository: creds.Repository, Options: options})
+	return e.errors[method]
+}
+
+// snapshots returns the programmed snapshots.
+func (e *Executor) snapshots() []restic.Snapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]restic.Snapshot{}, e.snaps...)
+}
+
+// Init implements restic.Executor.
+func (e *Executor) Init(ctx context.Context, creds restic.Credentials) error {
+	if err := e.record("Init", creds); err != nil || e.InitFunc == nil {
+		return err
+	}
+	return e.InitFunc(ctx, creds)
+}
+
+// Unlock implements restic.Executor.
+func (e *Executor) Unlock(ctx context.Context, creds restic.Credentials) error {
+	if err := e.record("Unlock", creds); err != nil || e.UnlockFunc == nil {
+		return err
+	}
+	return e.UnlockFunc(ctx, creds)
+}
+
+// CatConfig implements restic.Executor.
+func (e *Executor) CatConfig(ctx context.Context, creds restic.Credentials) (*restic.RepositoryConfig, error) {
+	if err := e.record("CatConfig", creds); err != nil {
+		return nil, err
+	}
+	if e.CatConfigFunc != nil {
+		return e.CatConfigFunc(ctx, creds)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return &restic.RepositoryConfig{Version: 2, ID: e.repoID}, nil
+}
+
+// Check implements restic.Executor.
+func (e *Executor) Check(ctx context.Context, creds restic.Credentials, opts restic.CheckOptions) (*restic.CheckResult, error) {
+	if err := e.record("Check", creds, opts); err != nil {
+		return &restic.CheckResult{Message: err.Error()}, err
+	}
+	if e.CheckFunc != nil {
+		return e.CheckFunc(ctx, creds, opts)
+	}
+	return &restic.CheckResult{Success: true, Message: "no errors were found"}, nil
+}
+
+// Stats implements restic.Executor.
+func (e *Executor) Stats(ctx context.Context, creds restic.Credentials, opts restic.StatsOptions) (*restic.RepoStats, error) {
+	if err := e.record("Stats", creds, opts); err != nil {
+		return nil, err
+	}
+	if e.StatsFunc != nil {
+		return e.StatsFunc(ctx, creds, opts)
+	}
+	return &restic.RepoStats{SnapshotCount: len(e.snapshots())}, nil
+}
+
+// Snapshots implements restic.Executor.
+func (e *Executor) Snapshots(ctx context.Context, creds restic.Credentials) ([]restic.Snapshot, error) {
+	if err := e.record("Snapshots", creds); err != nil {
+		return nil, err
+	}
+	if e.SnapshotsFunc != nil {
+		return e.SnapshotsFunc(ctx, creds)
+	}
+	return e.snapshots(), nil
+}
+
+// Backup implements restic.Executor.
+func (e *Executor) Backup(ctx context.Context, creds restic.Credentials, opts restic.BackupOptions) (*restic.BackupResult, error) {
+	if err := e.record("Backup", creds, opts); err != nil {
+		return nil, err
+	}
+	if e.BackupFunc != nil {
+		return e.BackupFunc(ctx, creds, opts)
+	}
+	return &restic.BackupResult{}, nil
+}
+
+// Restore implements restic.Executor.
+func (e *Executor) Restore(ctx context.Context, creds restic.Credentials, opts restic.RestoreOptions) (*restic.RestoreResult, error) {
+	if err := e.record("Restore", creds, opts); err != nil {
+		return nil, err
+	}
+	if e.RestoreFunc != nil {
+		return e.RestoreFunc(ctx, creds, opts)
+	}
+	return &restic.RestoreResult{}, nil
+}
+
+// Forget implements restic.Executor.
+func (e *Executor) Forget(ctx context.Context, creds restic.Credentials, opts restic.ForgetOptions) (*restic.ForgetResult, error) {
+	if err := e.record("Forget", creds, opts); err != nil {
+		return nil, err
+	}
+	if e.ForgetFunc != nil {
+		return e.ForgetFunc(ctx, creds, opts)
+	}
+	return &restic.ForgetResult{}, nil
+}
+
+// Prune implements restic.Executor.
+func (e *Executor) Prune(ctx context.Context, creds restic.Credentials) (*restic.PruneResult, error) {
+	if err := e.record("Prune", creds); err != nil {
+		return nil, err
+	}
+	if e.PruneFunc != nil {
+		return e.PruneFunc(ctx, creds)
+	}
+	return &restic.PruneResult{}, nil
+}
+
+// Dump implements restic.Executor.
+func (e *Executor) Dump(ctx context.Context, creds restic.Credentials, opts restic.DumpOptions) ([]byte, error) {
+	if err := e.record("Dump", creds, opts); err != nil {
+		return nil, err
+	}
+	if e.DumpFunc != nil {
+		return e.DumpFunc(ctx, creds, opts)
+	}
+	return nil, nil
+}
+
+// Diff implements restic.Executor.
+func (e *Executor) Diff(ctx context.Context, creds restic.Credentials, snapshotA, snapshotB string) (*restic.DiffResult, error) {
+	if err := e.record("Diff", creds, snapshotA, snapshotB); err != nil {
+		return nil, err
+	}
+	if e.DiffFunc != nil {
+		return e.DiffFunc(ctx, creds, snapshotA, snapshotB)
+	}
+	return &restic.DiffResult{}, nil
+}
+
+// Ls implements restic.Executor.
+func (e *Executor) Ls(ctx context.Context, creds restic.Credentials, snapshotID, path string) ([]restic.Node, error) {
+	if err := e.record("Ls", creds, snapshotID, path); err != nil {
+		return nil, err
+	}
+	if e.LsFunc != nil {
+		return e.LsFunc(ctx, creds, snapshotID, path)
+	}
+	return nil, nil
+}
+
+// Find implements restic.Executor.
+func (e *Executor) Find(ctx context.Context, creds restic.Credentials, pattern string) ([]restic.FindResult, error) {
+	if err := e.record("Find", creds, pattern); err != nil {
+		return nil, err
+	}
+	if e.FindFunc != nil {
+		return e.FindFunc(ctx, creds, pattern)
+	}
+	return nil, nil
+}
+
+// Tag implements restic.Executor.
+func (e *Executor) Tag(ctx context.Context, creds restic.Credentials, snapshotID string, opts restic.TagOptions) error {
+	if err := e.record("Tag", creds, snapshotID, opts); err != nil || e.TagFunc == nil {
+		return err
+	}
+	return e.TagFunc(ctx, creds, snapshotID, opts)
+}
+
+// Copy implements restic.Executor.
+func (e *Executor) Copy(ctx context.Context, creds restic.Credentials, opts restic.CopyOptions) error {
+	if err := e.record("Copy", creds, opts); err != nil || e.CopyFunc == nil {
+		return err
+	}
+	return e.CopyFunc(ctx, creds, opts)
+}
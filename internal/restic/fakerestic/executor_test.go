@@ -0,0 +1,112 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakerestic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+func TestExecutor(t *testing.T) {
+	ctx := context.Background()
+	creds := restic.Credentials{Repository: "s3:s3.amazonaws.com/bucket"}
+	executor := New().WithSnapshots(restic.Snapshot{ID: "a1"}, restic.Snapshot{ID: "b2"})
+
+	snapshots, err := executor.Snapshots(ctx, creds)
+	if err != nil || len(snapshots) != 2 {
+		t.Fatalf("Snapshots() = %v, %v, want the programmed snapshots", snapshots, err)
+	}
+	if stats, err := executor.Stats(ctx, creds, restic.StatsOptions{}); err != nil || stats.SnapshotCount != 2 {
+		t.Errorf("Stats() = %+v, %v, want 2 snapshots", stats, err)
+	}
+
+	executor.Fail("CatConfig", ErrUnreachable)
+	if _, err := executor.CatConfig(ctx, creds); !restic.IsRetryable(err) {
+		t.Errorf("CatConfig() = %v, want a retryable error", err)
+	}
+	executor.Fail("CatConfig", nil).WithRepositoryID("5d41402abc")
+	if config, err := executor.CatConfig(ctx, creds); err != nil || config.ID != "5d41402abc" {
+		t.Errorf("CatConfig() = %+v, %v, want the programmed repository ID after the error was cleared", config, err)
+	}
+
+	executor.BackupFunc = func(_ context.Context, _ restic.Credentials, opts restic.BackupOptions) (*restic.BackupResult, error) {
+		if len(opts.Paths) == 0 {
+			return nil, errors.New("no paths")
+		}
+		return &restic.BackupResult{SnapshotID: "c3"}, nil
+	}
+	if result, err := executor.Backup(ctx, creds, restic.BackupOptions{Paths: []string{"/data"}}); err != nil || result.SnapshotID != "c3" {
+		t.Errorf("Backup() = %+v, %v, want the result of BackupFunc", result, err)
+	}
+
+	calls := executor.Calls("CatConfig")
+	if len(calls) != 2 || calls[0].Repository != creds.Repository {
+		t.Errorf("Calls(CatConfig) = %+v, want 2 calls of the repository", calls)
+	}
+	backups := executor.Calls("Backup")
+	if len(backups) != 1 || backups[0].Options[0].(restic.BackupOptions).Paths[0] != "/data" {
+		t.Errorf("Calls(Backup) = %+v, want the backup options", backups)
+	}
+	executor.Reset()
+	if calls := executor.Calls(""); len(calls) != 0 {
+		t.Errorf("Calls() = %+v after Reset, want none", calls)
+	}
+}
+
+func TestFinishJob(t *testing.T) {
+	ctx := context.Background()
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "resticbackup-data-1", Namespace: "media"}}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(job).Build()
+
+	message := BackupSummary(restic.BackupResult{SnapshotID: "4f2a9c81", TotalFiles: 12, TotalBytes: 2048})
+	if err := FinishJob(ctx, c, job, true, message); err != nil {
+		t.Fatalf("FinishJob() = %v", err)
+	}
+
+	finished := &batchv1.Job{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(job), finished); err != nil {
+		t.Fatal(err)
+	}
+	if finished.Status.Succeeded != 1 || len(finished.Status.Conditions) != 1 || finished.Status.Conditions[0].Type != batchv1.JobComplete {
+		t.Errorf("job status = %+v, want complete", finished.Status)
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.List(ctx, pods, client.MatchingLabels{batchv1.JobNameLabel: job.Name}); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("pods of the job = %v, %v, want one", pods.Items, err)
+	}
+	terminated := pods.Items[0].Status.ContainerStatuses[0].State.Terminated
+	result, err := restic.ParseBackupSummary(terminated.Message)
+	if err != nil || result.SnapshotID != "4f2a9c81" || result.TotalFiles != 12 {
+		t.Errorf("ParseBackupSummary(termination message) = %+v, %v, want the summary", result, err)
+	}
+
+	restored, err := restic.ParseRestoreSummary(RestoreSummary(restic.RestoreResult{RestoredFiles: 3, RestoredBytes: 512}))
+	if err != nil || restored.RestoredFiles != 3 || restored.RestoredBytes != 512 {
+		t.Errorf("ParseRestoreSummary(RestoreSummary()) = %+v, %v", restored, err)
+	}
+}
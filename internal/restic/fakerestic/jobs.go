@@ -0,0 +1,136 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakerestic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/madic-creates/restic-backup-operator/internal/restic"
+)
+
+// resticContainer is the name of the container whose termination message the
+// controllers read.
+const resticContainer = "restic"
+
+// FinishJob completes a Job created by a controller as if it ran: it creates a
+// terminated pod of the Job whose restic container reports message as termination
+// message, and sets the Job status to complete or failed. Without a cluster running
+// Jobs, this lets tests drive the controllers through a backup, restore or retention run.
+func FinishJob(ctx context.Context, c client.Client, job *batchv1.Job, succeeded bool, message string) error {
+	now := metav1.Now()
+	exitCode := int32(0)
+	if !succeeded {
+		exitCode = 1
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", job.Name, now.UnixNano()),
+			Namespace: job.Namespace,
+			Labels: map[string]string{
+				batchv1.JobNameLabel:       job.Name,
+				batchv1.ControllerUidLabel: string(job.UID),
+			},
+		},
+		Spec: job.Spec.Template.Spec,
+		Status: corev1.PodStatus{
+			Phase: corev1.PodSucceeded,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: resticContainer,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					ExitCode:   exitCode,
+					Message:    message,
+					StartedAt:  now,
+					FinishedAt: now,
+				}},
+			}},
+		},
+	}
+	if !succeeded {
+		pod.Status.Phase = corev1.PodFailed
+	}
+	if err := c.Create(ctx, pod); err != nil {
+		return fmt.Errorf("failed to create pod of job %s: %w", job.Name, err)
+	}
+
+	conditionType := batchv1.JobComplete
+	if !succeeded {
+		conditionType = batchv1.JobFailed
+	}
+	job.Status.StartTime = &now
+	job.Status.Active = 0
+	if succeeded {
+		job.Status.Succeeded = 1
+		job.Status.CompletionTime = &now
+	} else {
+		job.Status.Failed = 1
+	}
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:               conditionType,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	if err := c.Status().Update(ctx, job); err != nil {
+		return fmt.Errorf("failed to update status of job %s: %w", job.Name, err)
+	}
+	return nil
+}
+
+// BackupSummary returns the summary line restic backup --json prints for result, the
+// termination message of a successful backup Job.
+func BackupSummary(result restic.BackupResult) string {
+	return summary(map[string]any{
+		"message_type":          "summary",
+		"snapshot_id":           result.SnapshotID,
+		"files_new":             result.FilesNew,
+		"files_changed":         result.FilesChanged,
+		"files_unmodified":      result.FilesUnmodified,
+		"dirs_new":              result.DirsNew,
+		"dirs_changed":          result.DirsChanged,
+		"dirs_unmodified":       result.DirsUnmodified,
+		"data_added":            result.DataAdded,
+		"total_files_processed": result.TotalFiles,
+		"total_bytes_processed": result.TotalBytes,
+		"total_duration":        result.Duration.Seconds(),
+	})
+}
+
+// RestoreSummary returns the summary line restic restore --json prints for result,
+// the termination message of a successful restore Job.
+func RestoreSummary(result restic.RestoreResult) string {
+	return summary(map[string]any{
+		"message_type":    "summary",
+		"files_restored":  result.RestoredFiles,
+		"bytes_restored":  result.RestoredBytes,
+		"seconds_elapsed": int64(result.Duration / time.Second),
+	})
+}
+
+// summary marshals a restic JSON message. Strings and numbers always marshal.
+func summary(message map[string]any) string {
+	data, _ := json.Marshal(message)
+	return string(data)
+}
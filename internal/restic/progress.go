@@ -0,0 +1,107 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// progressInterval is the minimum interval between two progress callbacks. restic prints
+// JSON status messages up to 60 times per second.
+const progressInterval = time.Second
+
+// statusMessage is the status message printed by restic backup --json and restic
+// restore --json (restic 0.17 and newer) while the command runs.
+type statusMessage struct {
+	MessageType      string  `json:"message_type"`
+	SecondsElapsed   int64   `json:"seconds_elapsed"`
+	SecondsRemaining int64   `json:"seconds_remaining"`
+	PercentDone      float64 `json:"percent_done"`
+	TotalFiles       int64   `json:"total_files"`
+	FilesDone        int64   `json:"files_done"`
+	FilesRestored    int64   `json:"files_restored"`
+	TotalBytes       uint64  `json:"total_bytes"`
+	BytesDone        uint64  `json:"bytes_done"`
+	BytesRestored    uint64  `json:"bytes_restored"`
+}
+
+// ParseProgress parses a status line of restic backup --json or restic restore --json.
+// It returns false for other lines, e.g. summary and error messages.
+func ParseProgress(line []byte) (Progress, bool) {
+	var status statusMessage
+	if err := json.Unmarshal(line, &status); err != nil || status.MessageType != "status" {
+		return Progress{}, false
+	}
+
+	return Progress{
+		PercentDone: status.PercentDone,
+		TotalFiles:  status.TotalFiles,
+		FilesDone:   status.FilesDone + status.FilesRestored,
+		TotalBytes:  status.TotalBytes,
+		BytesDone:   status.BytesDone + status.BytesRestored,
+		Elapsed:     time.Duration(status.SecondsElapsed) * time.Second,
+		ETA:         time.Duration(status.SecondsRemaining) * time.Second,
+	}, true
+}
+
+// progressWriter passes the output of restic to w and calls progress for the status
+// lines as they arrive, at most once per interval. The final status is always reported.
+type progressWriter struct {
+	w        io.Writer
+	progress ProgressFunc
+	interval time.Duration
+	last     time.Time
+	line     []byte
+}
+
+func newProgressWriter(w io.Writer, progress ProgressFunc) *progressWriter {
+	return &progressWriter{w: w, progress: progress, interval: progressInterval}
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	n, err := p.w.Write(data)
+	if err != nil {
+		return n, err
+	}
+
+	p.line = append(p.line, data...)
+	for {
+		i := bytes.IndexByte(p.line, '\n')
+		if i < 0 {
+			break
+		}
+		p.handleLine(p.line[:i])
+		p.line = p.line[i+1:]
+	}
+	return n, nil
+}
+
+func (p *progressWriter) handleLine(line []byte) {
+	progress, ok := ParseProgress(line)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if progress.PercentDone < 1 && now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	p.progress(progress)
+}
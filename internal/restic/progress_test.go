@@ -0,0 +1,139 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		ok       bool
+		expected Progress
+	}{
+		{
+			name: "backup status",
+			line: `{"message_type":"status","seconds_elapsed":60,"seconds_remaining":180,"percent_done":0.25,"total_files":100,"files_done":25,"total_bytes":4096,"bytes_done":1024,"current_files":["/backup/db"]}`,
+			ok:   true,
+			expected: Progress{
+				PercentDone: 0.25, TotalFiles: 100, FilesDone: 25, TotalBytes: 4096, BytesDone: 1024,
+				Elapsed: time.Minute, ETA: 3 * time.Minute,
+			},
+		},
+		{
+			name: "restore status",
+			line: `{"message_type":"status","seconds_elapsed":2,"percent_done":0.5,"total_files":4,"files_restored":2,"total_bytes":4096,"bytes_restored":2048}`,
+			ok:   true,
+			expected: Progress{
+				PercentDone: 0.5, TotalFiles: 4, FilesDone: 2, TotalBytes: 4096, BytesDone: 2048,
+				Elapsed: 2 * time.Second,
+			},
+		},
+		{
+			name: "summary",
+			line: `{"message_type":"summary","snapshot_id":"abc123"}`,
+		},
+		{
+			name: "plain output",
+			line: "repository abc123 opened",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress, ok := ParseProgress([]byte(tt.line))
+			if ok != tt.ok {
+				t.Fatalf("ParseProgress() ok = %v, want %v", ok, tt.ok)
+			}
+			if progress != tt.expected {
+				t.Errorf("ParseProgress() = %+v, want %+v", progress, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	var output bytes.Buffer
+	var updates []Progress
+	w := newProgressWriter(&output, func(p Progress) { updates = append(updates, p) })
+
+	// Lines split across writes are reassembled, updates within the interval are
+	// dropped and the final status is always reported
+	data := `{"message_type":"status","percent_done":0.1}
+{"message_type":"status","percent_done":0.2}
+{"message_type":"status","percent_done":1}
+{"message_type":"summary","snapshot_id":"abc123"}
+`
+	for _, chunk := range []string{data[:20], data[20:100], data[100:]} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	if output.String() != data {
+		t.Errorf("output = %q, want the restic output unchanged", output.String())
+	}
+	if len(updates) != 2 || updates[0].PercentDone != 0.1 || updates[1].PercentDone != 1 {
+		t.Errorf("updates = %+v, want 0.1 and 1", updates)
+	}
+}
+
+// TestDefaultExecutor_Backup_Progress tests that the status of a running backup is
+// reported before restic exits
+func TestDefaultExecutor_Backup_Progress(t *testing.T) {
+	dir := t.TempDir()
+
+	// The fake restic binary prints a status line and waits for the test to read it
+	binary := dir + "/restic"
+	release := dir + "/release"
+	script := `#!/bin/sh
+echo '{"message_type":"status","seconds_elapsed":1,"percent_done":0.5,"total_files":2,"files_done":1}'
+while [ ! -f ` + release + ` ]; do sleep 0.05; done
+echo '{"message_type":"summary","snapshot_id":"abc123","total_files_processed":2}'
+`
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake restic: %v", err)
+	}
+
+	executor := NewExecutorWithBinary(binary, getTestLogger())
+	opts := BackupOptions{
+		Paths: []string{"/backup"},
+		Progress: func(p Progress) {
+			if p.PercentDone == 0.5 {
+				if err := os.WriteFile(release, nil, 0644); err != nil {
+					t.Errorf("failed to release fake restic: %v", err)
+				}
+			}
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result, err := executor.Backup(ctx, Credentials{}, opts)
+	if err != nil {
+		t.Fatalf("expected the backup to succeed, got %v", err)
+	}
+	if result.SnapshotID != "abc123" {
+		t.Errorf("SnapshotID = %q, want abc123", result.SnapshotID)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// pruneTotalRegex matches the summary line, e.g. "total prune:  22 blobs / 4.096 KiB"
+	pruneTotalRegex = regexp.MustCompile(`^total prune:\s+\d+ blobs / ([\d.]+) (B|KiB|MiB|GiB|TiB|PiB)$`)
+	// pruneFilesDeletedRegex matches the progress line of deleted packs, e.g. "[0:00] 100.00%  2 / 2 files deleted"
+	pruneFilesDeletedRegex = regexp.MustCompile(`(\d+) / \d+ files deleted$`)
+	// pruneOldPacksRegex matches the line announcing pack removal, e.g. "removing 2 old packs"
+	pruneOldPacksRegex = regexp.MustCompile(`^removing (\d+) old packs$`)
+)
+
+var sizeUnits = map[string]float64{
+	"B":   1,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+	"PiB": 1 << 50,
+}
+
+// ParsePruneOutput extracts statistics from the text output of restic prune.
+// Lines that are not recognized are ignored, so an empty result is returned
+// for output that does not contain a prune summary.
+func ParsePruneOutput(output string) *PruneResult {
+	result := &PruneResult{}
+	var filesDeleted, oldPacks int
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if m := pruneTotalRegex.FindStringSubmatch(line); m != nil {
+			if value, err := strconv.ParseFloat(m[1], 64); err == nil {
+				result.BytesFreed = uint64(value * sizeUnits[m[2]])
+			}
+			continue
+		}
+		if m := pruneFilesDeletedRegex.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			filesDeleted += n
+			continue
+		}
+		if m := pruneOldPacksRegex.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			oldPacks += n
+		}
+	}
+
+	// Progress output may be missing when restic does not run in a terminal,
+	// fall back to the announced number of packs in that case
+	result.PacksDeleted = filesDeleted
+	if result.PacksDeleted == 0 {
+		result.PacksDeleted = oldPacks
+	}
+
+	return result
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"testing"
+)
+
+func TestParsePruneOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		packsDeleted int
+		bytesFreed   uint64
+	}{
+		{
+			name: "full prune output",
+			output: `loading indexes...
+loading all snapshots...
+finding data that is still in use for 3 snapshots
+[0:00] 100.00%  3 / 3 snapshots
+searching used packs...
+collecting packs for deletion and repacking
+[0:00] 100.00%  12 / 12 packs processed
+
+to repack:            10 blobs / 1.500 MiB
+this removes:          5 blobs / 512.000 KiB
+to delete:            22 blobs / 2.000 MiB
+total prune:          27 blobs / 2.500 MiB
+remaining:           100 blobs / 10.000 MiB
+unused size after prune: 0 B (0.00% of remaining size)
+
+repacking packs
+[0:00] 100.00%  1 / 1 packs repacked
+rebuilding index
+[0:00] 100.00%  2 / 2 indexes processed
+[0:00] 100.00%  2 / 2 old indexes deleted
+removing 4 old packs
+[0:00] 100.00%  4 / 4 files deleted
+done
+`,
+			packsDeleted: 4,
+			bytesFreed:   2621440,
+		},
+		{
+			name:         "unreferenced and old packs",
+			output:       "total prune: 3 blobs / 1.000 KiB\n[0:00] 100.00%  2 / 2 files deleted\n[0:01] 100.00%  5 / 5 files deleted\n",
+			packsDeleted: 7,
+			bytesFreed:   1024,
+		},
+		{
+			name:         "without progress output",
+			output:       "total prune:          27 blobs / 1.000 GiB\nremoving 6 old packs\n",
+			packsDeleted: 6,
+			bytesFreed:   1073741824,
+		},
+		{
+			name:         "nothing to prune",
+			output:       "total prune:           0 blobs / 0 B\ndone\n",
+			packsDeleted: 0,
+			bytesFreed:   0,
+		},
+		{
+			name:         "empty output",
+			output:       "",
+			packsDeleted: 0,
+			bytesFreed:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParsePruneOutput(tt.output)
+			if result.PacksDeleted != tt.packsDeleted {
+				t.Errorf("expected %d packs deleted, got %d", tt.packsDeleted, result.PacksDeleted)
+			}
+			if result.BytesFreed != tt.bytesFreed {
+				t.Errorf("expected %d bytes freed, got %d", tt.bytesFreed, result.BytesFreed)
+			}
+		})
+	}
+}
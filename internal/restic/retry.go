@@ -0,0 +1,123 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how often a restic command failing with a transient error is
+// run again. Commands failing with other errors are not retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a command is run. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. It doubles with every retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between two attempts. Zero means no cap.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy of new executors.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 2 * time.Second,
+	MaxBackoff:     30 * time.Second,
+}
+
+// backoff returns the wait before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < retry; i++ {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+// transientErrors are parts of the restic error output of failures that may succeed
+// when retried: network errors, server errors of S3 and REST backends and locks held
+// by other restic processes.
+var transientErrors = []string{
+	"timeout",
+	"timed out",
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"no such host",
+	"no route to host",
+	"network is unreachable",
+	"temporary failure in name resolution",
+	"unexpected eof",
+	"internal server error",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+	"slowdown",
+	"repository is already locked",
+	"unable to create lock",
+}
+
+// serverErrorPattern matches HTTP 5xx status codes in the restic error output, e.g.
+// "unexpected HTTP response (503)" or "StatusCode: 500".
+var serverErrorPattern = regexp.MustCompile(`(?i)(response|status ?code|status)[ :=(]*5\d\d\b`)
+
+// IsRetryable reports whether err is a restic command failure that may succeed when
+// retried, e.g. a network timeout, a 5xx response of the backend or a locked
+// repository. Wrong credentials, missing repositories, commands exceeding their
+// timeout and other errors are not.
+func IsRetryable(err error) bool {
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	stderr := strings.ToLower(cmdErr.Stderr)
+	for _, transient := range transientErrors {
+		if strings.Contains(stderr, transient) {
+			return true
+		}
+	}
+	return serverErrorPattern.MatchString(cmdErr.Stderr)
+}
+
+// retry calls run until it succeeds, fails with an error that is not retryable, the
+// context is done or the attempts of the retry policy are used up.
+func (e *DefaultExecutor) retry(ctx context.Context, args []string, run func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || attempt >= e.config.Retry.MaxAttempts || !IsRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		backoff := e.config.Retry.backoff(attempt)
+		e.log.Info("retrying restic command after transient error", "command", args[0],
+			"attempt", attempt, "backoff", backoff, "error", StderrExcerpt(err, 1))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"network timeout", &CommandError{Err: errors.New("exit status 1"),
+			Stderr: "Fatal: unable to open repository: Get \"https://s3.example.com/\": dial tcp 10.0.0.1:443: i/o timeout"}, true},
+		{"connection refused", &CommandError{Err: errors.New("exit status 1"),
+			Stderr: "Fatal: unable to open repository: dial tcp 10.0.0.1:8000: connect: connection refused"}, true},
+		{"no route to host", &CommandError{Err: errors.New("exit status 1"),
+			Stderr: "Fatal: unable to open config file: Head \"http://nas.vpn:8000/config\": dial tcp 10.8.0.2:8000: connect: no route to host"}, true},
+		{"S3 server error", &CommandError{Err: errors.New("exit status 1"),
+			Stderr: "Fatal: unable to open config file: Stat: We encountered an internal error. Please try again. StatusCode: 500"}, true},
+		{"REST server error", &CommandError{Err: errors.New("exit status 1"),
+			Stderr: "Fatal: unable to open config file: unexpected HTTP response (503): 503 Service Unavailable"}, true},
+		{"locked repository", &CommandError{Err: errors.New("exit status 11"),
+			Stderr: "unable to create lock in backend: repository is already locked by PID 42 on host"}, true},
+		{"wrong password", &CommandError{Err: errors.New("exit status 12"),
+			Stderr: "Fatal: wrong password or no key found"}, false},
+		{"missing repository", &CommandError{Err: errors.New("exit status 10"),
+			Stderr: "Fatal: repository does not exist: unable to open config file: 404 Not Found"}, false},
+		{"binary not found", errors.New("exec: \"restic\": executable file not found in $PATH"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.retryable {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 2 * time.Second, MaxBackoff: 5 * time.Second}
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := policy.backoff(i + 1); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+// TestDefaultExecutor_Retry tests that commands are retried on transient errors only
+func TestDefaultExecutor_Retry(t *testing.T) {
+	dir := t.TempDir()
+
+	// The fake restic binary fails once with the content of the error file as error output
+	// and counts its runs
+	binary := dir + "/restic"
+	errorFile := dir + "/error"
+	runsFile := dir + "/runs"
+	script := `#!/bin/sh
+echo run >> ` + runsFile + `
+if [ -f ` + errorFile + ` ]; then
+  cat ` + errorFile + ` >&2
+  rm ` + errorFile + `
+  exit 1
+fi
+echo '{"version":2,"id":"5d41402abc"}'
+`
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake restic: %v", err)
+	}
+
+	runs := func() int {
+		data, err := os.ReadFile(runsFile)
+		if err != nil {
+			t.Fatalf("failed to read runs: %v", err)
+		}
+		_ = os.Remove(runsFile)
+		return len(data) / len("run\n")
+	}
+
+	executor := NewExecutorWithBinary(binary, getTestLogger()).WithConfig(ExecutorConfig{Retry: RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}})
+
+	// A transient error is retried
+	if err := os.WriteFile(errorFile, []byte("Fatal: dial tcp: i/o timeout\n"), 0644); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if config, err := executor.CatConfig(context.Background(), Credentials{}); err != nil || config.ID != "5d41402abc" {
+		t.Errorf("expected the retried command to return the repository config, got %+v, %v", config, err)
+	}
+	if n := runs(); n != 2 {
+		t.Errorf("expected 2 runs, got %d", n)
+	}
+
+	// Other errors are not retried
+	if err := os.WriteFile(errorFile, []byte("Fatal: wrong password or no key found\n"), 0644); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if _, err := executor.CatConfig(context.Background(), Credentials{}); err == nil {
+		t.Error("expected the command to fail")
+	}
+	if n := runs(); n != 1 {
+		t.Errorf("expected 1 run, got %d", n)
+	}
+}
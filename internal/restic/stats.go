@@ -0,0 +1,41 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ParseStatsOutput parses the output of restic stats --json, which includes the number
+// of snapshots of the repository.
+func ParseStatsOutput(output string) (*RepoStats, error) {
+	var stats struct {
+		TotalSize      uint64 `json:"total_size"`
+		TotalFileCount uint64 `json:"total_file_count"`
+		SnapshotsCount int    `json:"snapshots_count"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &stats); err != nil {
+		return nil, fmt.Errorf("failed to parse stats output: %w", err)
+	}
+	return &RepoStats{
+		TotalSize:      stats.TotalSize,
+		TotalFileCount: stats.TotalFileCount,
+		SnapshotCount:  stats.SnapshotsCount,
+	}, nil
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import "testing"
+
+func TestParseStatsOutput(t *testing.T) {
+	stats, err := ParseStatsOutput(`{"total_size":1048576,"total_file_count":42,"snapshots_count":7}` + "\n")
+	if err != nil {
+		t.Fatalf("ParseStatsOutput() error = %v", err)
+	}
+	if stats.TotalSize != 1048576 || stats.TotalFileCount != 42 || stats.SnapshotCount != 7 {
+		t.Errorf("ParseStatsOutput() = %+v", stats)
+	}
+
+	if _, err := ParseStatsOutput("Fatal: unable to open repository"); err == nil {
+		t.Error("expected an error for output without statistics")
+	}
+}
@@ -28,10 +28,33 @@ type Credentials struct {
 	AWSAccessKeyID string
 	// AWS secret access key (for S3 repositories)
 	AWSSecretAccessKey string
+	// Azure storage account name and key or SAS token (for Azure repositories)
+	AzureAccountName string
+	AzureAccountKey  string
+	AzureAccountSAS  string
+	// Google Cloud project ID (for GCS repositories)
+	GoogleProjectID string
+	// Content of the Google service account key file (for GCS repositories).
+	// It is written to a temporary file for each restic command.
+	GoogleApplicationCredentials string
+	// Backblaze B2 account ID and key (for B2 repositories)
+	B2AccountID  string
+	B2AccountKey string
+	// Basic auth credentials (for REST server repositories)
+	RESTUsername string
+	RESTPassword string
 	// Cache directory (optional)
 	CacheDir string
 }
 
+// RepositoryConfig is the config of a restic repository.
+type RepositoryConfig struct {
+	// Version is the repository format version.
+	Version int `json:"version"`
+	// ID identifies the repository. It is generated on init and never changes.
+	ID string `json:"id"`
+}
+
 // Snapshot represents a restic snapshot.
 type Snapshot struct {
 	ID       string    `json:"id"`
@@ -43,6 +66,59 @@ type Snapshot struct {
 	Paths    []string  `json:"paths"`
 	Tags     []string  `json:"tags"`
 	Parent   string    `json:"parent,omitempty"`
+	// Original is the ID of the snapshot this snapshot was copied from by restic copy.
+	Original string `json:"original,omitempty"`
+	// Summary is the backup summary stored by restic 0.17 and newer.
+	Summary *SnapshotSummary `json:"summary,omitempty"`
+}
+
+// SnapshotSummary contains the backup summary stored in a snapshot.
+type SnapshotSummary struct {
+	TotalFilesProcessed int64  `json:"total_files_processed"`
+	TotalBytesProcessed uint64 `json:"total_bytes_processed"`
+}
+
+// Node is a file, directory or other entry of a snapshot, as printed by restic ls and
+// restic find.
+type Node struct {
+	Name  string    `json:"name"`
+	Type  string    `json:"type"`
+	Path  string    `json:"path"`
+	UID   uint32    `json:"uid"`
+	GID   uint32    `json:"gid"`
+	Size  uint64    `json:"size"`
+	Mode  uint32    `json:"mode"`
+	MTime time.Time `json:"mtime"`
+}
+
+// FindResult contains the matches of restic find in one snapshot.
+type FindResult struct {
+	SnapshotID string `json:"snapshot"`
+	Hits       int    `json:"hits"`
+	Matches    []Node `json:"matches"`
+}
+
+// DiffChange is a path that differs between two snapshots. The modifier is "+" for
+// added, "-" for removed, "M" for modified content, "U" for updated metadata and "T"
+// for a changed type.
+type DiffChange struct {
+	Path     string `json:"path"`
+	Modifier string `json:"modifier"`
+}
+
+// DiffStats counts the entries and data added or removed between two snapshots.
+type DiffStats struct {
+	Files int    `json:"files"`
+	Dirs  int    `json:"dirs"`
+	Bytes uint64 `json:"bytes"`
+}
+
+// DiffResult contains the result of a diff operation.
+type DiffResult struct {
+	Changes      []DiffChange
+	ChangedFiles int
+	Added        DiffStats
+	Removed      DiffStats
 }
 
 // RepoStats contains repository statistics.
@@ -94,6 +170,22 @@ type CheckResult struct {
 	Duration time.Duration
 }
 
+// Progress is a status update of a running backup or restore.
+type Progress struct {
+	// PercentDone is the done fraction of the operation, from 0 to 1
+	PercentDone float64
+	TotalFiles  int64
+	FilesDone   int64
+	TotalBytes  uint64
+	BytesDone   uint64
+	Elapsed     time.Duration
+	// ETA is the estimated remaining time, zero if restic has no estimate yet
+	ETA time.Duration
+}
+
+// ProgressFunc is called with the status updates of a running operation.
+type ProgressFunc func(Progress)
+
 // BackupOptions contains options for a backup operation.
 type BackupOptions struct {
 	// Source paths to backup
@@ -106,6 +198,8 @@ type BackupOptions struct {
 	Tags []string
 	// Extra arguments to pass to restic
 	ExtraArgs []string
+	// Progress, if set, is called with the status of the running backup
+	Progress ProgressFunc
 }
 
 // RestoreOptions contains options for a restore operation.
@@ -120,8 +214,52 @@ type RestoreOptions struct {
 	Exclude []string
 	// Overwrite existing files
 	Overwrite bool
+	// OverwriteMode selects which existing files are overwritten (always, if-changed,
+	// if-newer or never). Empty uses the restic default.
+	OverwriteMode string
+	// Sparse restores files as sparse files
+	Sparse bool
 	// Verify restored files
 	Verify bool
+	// Progress, if set, is called with the status of the running restore.
+	// Requires restic 0.17 or newer.
+	Progress ProgressFunc
+}
+
+// DumpOptions contains options for a dump operation.
+type DumpOptions struct {
+	// Snapshot ID to dump from
+	SnapshotID string
+	// Path of the file or directory in the snapshot. Directories are dumped as tar archive.
+	Path string
+	// MaxSize is the maximum size of the dumped data in bytes. Larger dumps are aborted.
+	// Zero means unlimited.
+	MaxSize int
+	// Extra arguments to pass to restic
+	ExtraArgs []string
+}
+
+// CopyOptions contains options for a copy operation.
+type CopyOptions struct {
+	// From are the credentials of the repository to copy from. Only the repository and
+	// password are used, the backend credentials are shared with the destination.
+	From Credentials
+	// Snapshot IDs to copy. Empty copies all snapshots matching the filters.
+	SnapshotIDs []string
+	// Filter by hostnames
+	Hosts []string
+	// Filter by tags
+	Tags []string
+	// Extra arguments to pass to restic
+	ExtraArgs []string
+}
+
+// TagOptions contains options for a tag operation.
+type TagOptions struct {
+	// Tags to add to the snapshot
+	Add []string
+	// Tags to remove from the snapshot
+	Remove []string
 }
 
 // ForgetOptions contains options for a forget operation.
@@ -133,6 +271,13 @@ type ForgetOptions struct {
 	KeepWeekly  int
 	KeepMonthly int
 	KeepYearly  int
+	// Keep policies by duration, e.g. "1y6m" or "2d12h"
+	KeepWithin        string
+	KeepWithinHourly  string
+	KeepWithinDaily   string
+	KeepWithinWeekly  string
+	KeepWithinMonthly string
+	KeepWithinYearly  string
 	// Filter by tags
 	Tags []string
 	// Filter by hostname
@@ -145,6 +290,12 @@ type ForgetOptions struct {
 	DryRun bool
 }
 
+// CheckOptions contains options for a check operation.
+type CheckOptions struct {
+	// ReadDataSubset verifies a subset of the pack files, e.g. "2/10"
+	ReadDataSubset string
+}
+
 // StatsOptions contains options for a stats operation.
 type StatsOptions struct {
 	// Mode: raw-data, files-by-contents, blobs-per-file, restore-size
@@ -0,0 +1,44 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package version provides the operator version.
+package version
+
+import "runtime/debug"
+
+// Version is the operator version. It is set at build time via
+// -ldflags "-X github.com/madic-creates/restic-backup-operator/internal/version.Version=v1.2.3".
+var Version = "dev"
+
+// GitCommit is the git commit the operator was built from. It is set at build time via
+// -ldflags "-X github.com/madic-creates/restic-backup-operator/internal/version.GitCommit=abc123".
+var GitCommit = ""
+
+// Commit returns the git commit the operator was built from. Without GitCommit it
+// falls back to the VCS revision recorded by go build, or "unknown".
+func Commit() string {
+	if GitCommit != "" {
+		return GitCommit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
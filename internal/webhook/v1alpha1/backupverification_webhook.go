@@ -0,0 +1,81 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// SetupBackupVerificationWebhookWithManager registers the webhook validating
+// BackupVerifications.
+func SetupBackupVerificationWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.BackupVerification{}).
+		WithValidator(&BackupVerificationCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-backupverification,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=backupverifications,verbs=create;update,versions=v1alpha1,name=vbackupverification-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// BackupVerificationCustomValidator checks the schedule and timezone of a BackupVerification.
+type BackupVerificationCustomValidator struct{}
+
+var _ webhook.CustomValidator = &BackupVerificationCustomValidator{}
+
+// ValidateCreate validates a new BackupVerification.
+func (v *BackupVerificationCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	verification, ok := obj.(*backupv1alpha1.BackupVerification)
+	if !ok {
+		return nil, fmt.Errorf("expected a BackupVerification object but got %T", obj)
+	}
+	return nil, invalid("BackupVerification", verification.Name, validateVerificationSpec(verification))
+}
+
+// ValidateUpdate validates an updated BackupVerification.
+func (v *BackupVerificationCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	verification, ok := newObj.(*backupv1alpha1.BackupVerification)
+	if !ok {
+		return nil, fmt.Errorf("expected a BackupVerification object but got %T", newObj)
+	}
+	if !verification.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return nil, invalid("BackupVerification", verification.Name, validateVerificationSpec(verification))
+}
+
+// ValidateDelete admits every deletion.
+func (v *BackupVerificationCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateVerificationSpec checks the schedule, timezone and sidecars of a
+// BackupVerification.
+func validateVerificationSpec(verification *backupv1alpha1.BackupVerification) field.ErrorList {
+	spec := field.NewPath("spec")
+	errs := validateSchedule(spec.Child("schedule"), verification.Spec.Schedule)
+	errs = append(errs, validateTimezone(spec.Child("timezone"), verification.Spec.Timezone)...)
+	return append(errs, validateSidecars(spec.Child("jobConfig"), verification.Spec.JobConfig)...)
+}
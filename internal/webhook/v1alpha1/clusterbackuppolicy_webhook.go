@@ -0,0 +1,94 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// SetupClusterBackupPolicyWebhookWithManager registers the webhook validating
+// ClusterBackupPolicies.
+func SetupClusterBackupPolicyWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.ClusterBackupPolicy{}).
+		WithValidator(&ClusterBackupPolicyCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-clusterbackuppolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=clusterbackuppolicies,verbs=create;update,versions=v1alpha1,name=vclusterbackuppolicy-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ClusterBackupPolicyCustomValidator checks the selectors and the backup template of a
+// ClusterBackupPolicy.
+type ClusterBackupPolicyCustomValidator struct{}
+
+var _ webhook.CustomValidator = &ClusterBackupPolicyCustomValidator{}
+
+// ValidateCreate validates a new ClusterBackupPolicy.
+func (v *ClusterBackupPolicyCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	policy, ok := obj.(*backupv1alpha1.ClusterBackupPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterBackupPolicy object but got %T", obj)
+	}
+	return nil, invalid("ClusterBackupPolicy", policy.Name, validateClusterBackupPolicySpec(policy))
+}
+
+// ValidateUpdate validates an updated ClusterBackupPolicy.
+func (v *ClusterBackupPolicyCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	policy, ok := newObj.(*backupv1alpha1.ClusterBackupPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterBackupPolicy object but got %T", newObj)
+	}
+	return nil, invalid("ClusterBackupPolicy", policy.Name, validateClusterBackupPolicySpec(policy))
+}
+
+// ValidateDelete admits every deletion.
+func (v *ClusterBackupPolicyCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateClusterBackupPolicySpec checks that the selectors parse and that the backup
+// template has a valid schedule, timezone, retention policy and sidecars.
+func validateClusterBackupPolicySpec(policy *backupv1alpha1.ClusterBackupPolicy) field.ErrorList {
+	spec := field.NewPath("spec")
+	var errs field.ErrorList
+	if _, err := metav1.LabelSelectorAsSelector(&policy.Spec.NamespaceSelector); err != nil {
+		errs = append(errs, field.Invalid(spec.Child("namespaceSelector"), policy.Spec.NamespaceSelector, err.Error()))
+	}
+	if _, err := metav1.LabelSelectorAsSelector(&policy.Spec.PVCSelector); err != nil {
+		errs = append(errs, field.Invalid(spec.Child("pvcSelector"), policy.Spec.PVCSelector, err.Error()))
+	}
+
+	template := spec.Child("template", "spec")
+	backup := policy.Spec.Template.Spec
+	errs = append(errs, validateSchedule(template.Child("schedule"), backup.Schedule)...)
+	errs = append(errs, validateTimezone(template.Child("timezone"), backup.Timezone)...)
+	if retention := backup.Retention; retention != nil && retention.Enabled && retention.Policy != nil {
+		errs = append(errs, validateRetentionPolicy(template.Child("retention", "policy"), retention.Policy)...)
+	}
+	errs = append(errs, validateSidecars(template.Child("jobConfig"), backup.JobConfig)...)
+	return errs
+}
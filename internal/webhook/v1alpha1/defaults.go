@@ -0,0 +1,57 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// defaultJobsHistoryLimit is the number of finished jobs the controllers keep per CronJob.
+const defaultJobsHistoryLimit int32 = 3
+
+// Defaulter fills in the defaults the controllers otherwise apply implicitly, so they
+// are visible in the stored resource. Defaults are applied when a resource is created.
+// The restic image is not defaulted: resources without an image follow the image of
+// the running operator, the image mirror and the image digests across upgrades.
+type Defaulter struct{}
+
+// defaultTimezone sets an empty timezone to UTC.
+func defaultTimezone(timezone *string) {
+	if *timezone == "" {
+		*timezone = "UTC"
+	}
+}
+
+// defaultJobConfig returns the job configuration with the concurrency policy and job
+// history limits the controllers use for CronJobs.
+func defaultJobConfig(config *backupv1alpha1.JobConfiguration) *backupv1alpha1.JobConfiguration {
+	if config == nil {
+		config = &backupv1alpha1.JobConfiguration{}
+	}
+	if config.ConcurrencyPolicy == "" {
+		config.ConcurrencyPolicy = "Forbid"
+	}
+	if config.SuccessfulJobsHistoryLimit == nil {
+		limit := defaultJobsHistoryLimit
+		config.SuccessfulJobsHistoryLimit = &limit
+	}
+	if config.FailedJobsHistoryLimit == nil {
+		limit := defaultJobsHistoryLimit
+		config.FailedJobsHistoryLimit = &limit
+	}
+	return config
+}
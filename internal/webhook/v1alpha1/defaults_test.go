@@ -0,0 +1,92 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+func TestResticBackupDefault(t *testing.T) {
+	d := &ResticBackupCustomDefaulter{Defaulter: &Defaulter{}}
+	backup := newBackup("repo")
+
+	if err := d.Default(context.Background(), backup); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if backup.Spec.Restic.Image != "" {
+		t.Errorf("expected the image to follow the operator, got %q", backup.Spec.Restic.Image)
+	}
+	if backup.Spec.Restic.Hostname != "backup" {
+		t.Errorf("expected the name as hostname, got %q", backup.Spec.Restic.Hostname)
+	}
+	if backup.Spec.Timezone != "UTC" {
+		t.Errorf("expected UTC, got %q", backup.Spec.Timezone)
+	}
+	jobConfig := backup.Spec.JobConfig
+	if jobConfig.ConcurrencyPolicy != "Forbid" {
+		t.Errorf("expected Forbid, got %q", jobConfig.ConcurrencyPolicy)
+	}
+	if *jobConfig.SuccessfulJobsHistoryLimit != 3 || *jobConfig.FailedJobsHistoryLimit != 3 {
+		t.Errorf("expected history limits of 3, got %d and %d",
+			*jobConfig.SuccessfulJobsHistoryLimit, *jobConfig.FailedJobsHistoryLimit)
+	}
+}
+
+func TestResticBackupDefault_KeepsConfiguredValues(t *testing.T) {
+	d := &ResticBackupCustomDefaulter{Defaulter: &Defaulter{}}
+	limit := int32(10)
+	backup := newBackup("repo")
+	backup.Spec.Timezone = "Europe/Berlin"
+	backup.Spec.Restic = &backupv1alpha1.ResticConfig{Image: "restic:custom", Hostname: "{{ .Namespace }}-{{ .Name }}"}
+	backup.Spec.JobConfig = &backupv1alpha1.JobConfiguration{ConcurrencyPolicy: "Replace", FailedJobsHistoryLimit: &limit}
+
+	if err := d.Default(context.Background(), backup); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if backup.Spec.Restic.Image != "restic:custom" || backup.Spec.Restic.Hostname != "{{ .Namespace }}-{{ .Name }}" {
+		t.Errorf("expected the configured restic settings to be kept, got %+v", backup.Spec.Restic)
+	}
+	if backup.Spec.Timezone != "Europe/Berlin" {
+		t.Errorf("expected the configured timezone to be kept, got %q", backup.Spec.Timezone)
+	}
+	if backup.Spec.JobConfig.ConcurrencyPolicy != "Replace" || *backup.Spec.JobConfig.FailedJobsHistoryLimit != 10 {
+		t.Errorf("expected the configured job settings to be kept, got %+v", backup.Spec.JobConfig)
+	}
+	if *backup.Spec.JobConfig.SuccessfulJobsHistoryLimit != 3 {
+		t.Errorf("expected the unset history limit to be defaulted, got %d", *backup.Spec.JobConfig.SuccessfulJobsHistoryLimit)
+	}
+}
+
+func TestResticCheckDefault(t *testing.T) {
+	d := &ResticCheckCustomDefaulter{Defaulter: &Defaulter{}}
+	check := &backupv1alpha1.ResticCheck{ObjectMeta: metav1.ObjectMeta{Name: "check", Namespace: "default"}}
+
+	if err := d.Default(context.Background(), check); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if check.Spec.Timezone != "UTC" || check.Spec.JobConfig == nil {
+		t.Errorf("expected timezone and job configuration to be defaulted, got %+v", check.Spec)
+	}
+	if check.Spec.Image != "" {
+		t.Errorf("expected the image to follow the operator, got %q", check.Spec.Image)
+	}
+}
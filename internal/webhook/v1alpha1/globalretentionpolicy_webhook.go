@@ -0,0 +1,128 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// SetupGlobalRetentionPolicyWebhookWithManager registers the webhooks defaulting and
+// validating GlobalRetentionPolicies.
+func SetupGlobalRetentionPolicyWebhookWithManager(mgr ctrl.Manager, validator *ReferenceValidator) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.GlobalRetentionPolicy{}).
+		WithDefaulter(&GlobalRetentionPolicyCustomDefaulter{}).
+		WithValidator(&GlobalRetentionPolicyCustomValidator{ReferenceValidator: validator}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-backup-resticbackup-io-v1alpha1-globalretentionpolicy,mutating=true,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=globalretentionpolicies,verbs=create,versions=v1alpha1,name=mglobalretentionpolicy-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// GlobalRetentionPolicyCustomDefaulter sets the job configuration of new
+// GlobalRetentionPolicies.
+type GlobalRetentionPolicyCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &GlobalRetentionPolicyCustomDefaulter{}
+
+// Default sets the defaults of a GlobalRetentionPolicy.
+func (d *GlobalRetentionPolicyCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	policy, ok := obj.(*backupv1alpha1.GlobalRetentionPolicy)
+	if !ok {
+		return fmt.Errorf("expected a GlobalRetentionPolicy object but got %T", obj)
+	}
+	policy.Spec.JobConfig = defaultJobConfig(policy.Spec.JobConfig)
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-globalretentionpolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=globalretentionpolicies,verbs=create;update,versions=v1alpha1,name=vglobalretentionpolicy-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// GlobalRetentionPolicyCustomValidator checks the schedules and retention rules of a
+// GlobalRetentionPolicy and that the repository it references exists.
+type GlobalRetentionPolicyCustomValidator struct {
+	*ReferenceValidator
+}
+
+var _ webhook.CustomValidator = &GlobalRetentionPolicyCustomValidator{}
+
+// ValidateCreate validates a new GlobalRetentionPolicy.
+func (v *GlobalRetentionPolicyCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	policy, ok := obj.(*backupv1alpha1.GlobalRetentionPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a GlobalRetentionPolicy object but got %T", obj)
+	}
+	return v.validate(ctx, "GlobalRetentionPolicy", policy.Namespace, policy.Name,
+		policyReferences(policy), validatePolicySpec(policy))
+}
+
+// ValidateUpdate validates a GlobalRetentionPolicy and its repository reference if it
+// changed.
+func (v *GlobalRetentionPolicyCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldPolicy, ok := oldObj.(*backupv1alpha1.GlobalRetentionPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a GlobalRetentionPolicy object but got %T", oldObj)
+	}
+	policy, ok := newObj.(*backupv1alpha1.GlobalRetentionPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a GlobalRetentionPolicy object but got %T", newObj)
+	}
+	if !policy.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	var references []reference
+	if oldPolicy.Spec.RepositoryRef != policy.Spec.RepositoryRef {
+		references = policyReferences(policy)
+	}
+	return v.validate(ctx, "GlobalRetentionPolicy", policy.Namespace, policy.Name,
+		references, validatePolicySpec(policy))
+}
+
+// ValidateDelete admits every deletion.
+func (v *GlobalRetentionPolicyCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func policyReferences(policy *backupv1alpha1.GlobalRetentionPolicy) []reference {
+	return []reference{{
+		path:   field.NewPath("spec", "repositoryRef"),
+		ref:    policy.Spec.RepositoryRef,
+		kind:   "ResticRepository",
+		target: &backupv1alpha1.ResticRepository{},
+	}}
+}
+
+// validatePolicySpec checks the schedules, retention rules and sidecars of a
+// GlobalRetentionPolicy.
+func validatePolicySpec(policy *backupv1alpha1.GlobalRetentionPolicy) field.ErrorList {
+	spec := field.NewPath("spec")
+	errs := validateSchedule(spec.Child("schedule"), policy.Spec.Schedule)
+	errs = append(errs, validateSidecars(spec.Child("jobConfig"), policy.Spec.JobConfig)...)
+	for i := range policy.Spec.Policies {
+		entry := spec.Child("policies").Index(i)
+		errs = append(errs, validateSchedule(entry.Child("schedule"), policy.Spec.Policies[i].Schedule)...)
+		errs = append(errs, validateRetentionPolicy(entry.Child("retention"), &policy.Spec.Policies[i].Retention)...)
+	}
+	return errs
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// NamespaceQuota limits the number of resources per namespace at admission, protecting
+// shared repositories and the cluster from automation creating resources in a loop.
+// The resources are counted from the cache, so concurrent creations can exceed a limit
+// by a few resources.
+type NamespaceQuota struct {
+	// Reader lists the resources of a namespace.
+	Reader client.Reader
+	// MaxBackupsPerNamespace limits the ResticBackups of a namespace. 0 disables the limit.
+	MaxBackupsPerNamespace int
+	// MaxActiveRestoresPerNamespace limits the ResticRestores of a namespace that
+	// haven't completed or failed yet. 0 disables the limit.
+	MaxActiveRestoresPerNamespace int
+}
+
+// checkBackups refuses a new ResticBackup if its namespace has reached the backup limit.
+func (q *NamespaceQuota) checkBackups(ctx context.Context, backup *backupv1alpha1.ResticBackup) error {
+	if q == nil || q.MaxBackupsPerNamespace <= 0 {
+		return nil
+	}
+
+	backups := &backupv1alpha1.ResticBackupList{}
+	if err := q.Reader.List(ctx, backups, client.InNamespace(backup.Namespace)); err != nil {
+		return fmt.Errorf("failed to list ResticBackups: %w", err)
+	}
+	if len(backups.Items) >= q.MaxBackupsPerNamespace {
+		return apierrors.NewForbidden(backupv1alpha1.GroupVersion.WithResource("resticbackups").GroupResource(), backup.Name,
+			fmt.Errorf("namespace %s has reached the limit of %d ResticBackups", backup.Namespace, q.MaxBackupsPerNamespace))
+	}
+	return nil
+}
+
+// checkRestores refuses a new ResticRestore if its namespace has reached the limit of
+// active restores. Restore drills are only active while a drill runs.
+func (q *NamespaceQuota) checkRestores(ctx context.Context, restore *backupv1alpha1.ResticRestore) error {
+	if q == nil || q.MaxActiveRestoresPerNamespace <= 0 {
+		return nil
+	}
+
+	restores := &backupv1alpha1.ResticRestoreList{}
+	if err := q.Reader.List(ctx, restores, client.InNamespace(restore.Namespace)); err != nil {
+		return fmt.Errorf("failed to list ResticRestores: %w", err)
+	}
+	active := 0
+	for i := range restores.Items {
+		switch restores.Items[i].Status.Phase {
+		case backupv1alpha1.RestorePhaseCompleted, backupv1alpha1.RestorePhaseFailed, backupv1alpha1.RestorePhaseScheduled:
+		default:
+			active++
+		}
+	}
+	if active >= q.MaxActiveRestoresPerNamespace {
+		return apierrors.NewForbidden(backupv1alpha1.GroupVersion.WithResource("resticrestores").GroupResource(), restore.Name,
+			fmt.Errorf("namespace %s has reached the limit of %d active ResticRestores", restore.Namespace, q.MaxActiveRestoresPerNamespace))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+func TestNamespaceQuota_Backups(t *testing.T) {
+	existing := newBackup("repo")
+	validator := newValidator(t, DanglingReferenceWarn, existing)
+	v := &ResticBackupCustomValidator{
+		ReferenceValidator: validator,
+		Quota:              &NamespaceQuota{Reader: validator.Reader, MaxBackupsPerNamespace: 1},
+	}
+
+	backup := newBackup("repo")
+	backup.Name = "second"
+	if _, err := v.ValidateCreate(context.Background(), backup); !apierrors.IsForbidden(err) {
+		t.Errorf("expected a backup beyond the limit to be forbidden, got %v", err)
+	}
+
+	backup.Namespace = "other"
+	if _, err := v.ValidateCreate(context.Background(), backup); err != nil {
+		t.Errorf("expected a backup in another namespace to be admitted, got %v", err)
+	}
+
+	// Updates of existing backups are not limited
+	if _, err := v.ValidateUpdate(context.Background(), existing, existing.DeepCopy()); err != nil {
+		t.Errorf("expected an update to be admitted, got %v", err)
+	}
+}
+
+func TestNamespaceQuota_ActiveRestores(t *testing.T) {
+	newRestore := func(name string, phase backupv1alpha1.RestorePhase) *backupv1alpha1.ResticRestore {
+		return &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "backup"},
+				Target:    backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "data"}},
+			},
+			Status: backupv1alpha1.ResticRestoreStatus{Phase: phase},
+		}
+	}
+	validator := newValidator(t, DanglingReferenceReject, newBackup("repo"),
+		newRestore("running", backupv1alpha1.RestorePhaseInProgress),
+		newRestore("done", backupv1alpha1.RestorePhaseCompleted),
+		newRestore("failed", backupv1alpha1.RestorePhaseFailed),
+		newRestore("drill", backupv1alpha1.RestorePhaseScheduled),
+	)
+	quota := &NamespaceQuota{Reader: validator.Reader, MaxActiveRestoresPerNamespace: 2}
+	v := &ResticRestoreCustomValidator{ReferenceValidator: validator, Quota: quota}
+
+	if _, err := v.ValidateCreate(context.Background(), newRestore("second", "")); err != nil {
+		t.Errorf("expected a restore within the limit to be admitted, got %v", err)
+	}
+
+	quota.MaxActiveRestoresPerNamespace = 1
+	if _, err := v.ValidateCreate(context.Background(), newRestore("second", "")); !apierrors.IsForbidden(err) {
+		t.Errorf("expected a restore beyond the limit to be forbidden, got %v", err)
+	}
+
+	quota.MaxActiveRestoresPerNamespace = 0
+	if _, err := v.ValidateCreate(context.Background(), newRestore("second", "")); err != nil {
+		t.Errorf("expected no limit to admit the restore, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 contains the admission webhooks of the backup.resticbackup.io/v1alpha1 API.
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// Policies for references to resources that don't exist.
+const (
+	// DanglingReferenceReject rejects resources referencing missing resources.
+	DanglingReferenceReject = "Reject"
+	// DanglingReferenceWarn admits resources referencing missing resources with a warning.
+	DanglingReferenceWarn = "Warn"
+)
+
+// ReferenceValidator checks the references of resources at admission.
+type ReferenceValidator struct {
+	// Reader reads the referenced resources. It should read from the API server, a
+	// cache may not have seen a resource applied together with the referencing one.
+	Reader client.Reader
+	// DanglingReferencePolicy defines how references to missing resources are handled.
+	// Defaults to Reject.
+	DanglingReferencePolicy string
+	// DenyCrossNamespaceReferences rejects references to resources in other namespaces.
+	DenyCrossNamespaceReferences bool
+}
+
+// ParseDanglingReferencePolicy validates a dangling reference policy.
+func ParseDanglingReferencePolicy(value string) (string, error) {
+	switch value {
+	case DanglingReferenceReject, DanglingReferenceWarn:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid dangling reference policy %q, must be %s or %s", value, DanglingReferenceReject, DanglingReferenceWarn)
+}
+
+// reference is a reference of a resource to be validated.
+type reference struct {
+	path   *field.Path
+	ref    backupv1alpha1.CrossNamespaceObjectReference
+	kind   string
+	target client.Object
+}
+
+// validate checks the references of the resource name of kind in namespace. Missing
+// resources are reported as warnings or, with the Reject policy, as errors. errs are
+// the errors found validating the spec of the resource.
+func (v *ReferenceValidator) validate(ctx context.Context, kind, namespace, name string, references []reference, errs field.ErrorList) (admission.Warnings, error) {
+	var warnings admission.Warnings
+
+	for _, r := range references {
+		ns := r.ref.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		if v.DenyCrossNamespaceReferences && ns != namespace {
+			errs = append(errs, field.Forbidden(r.path.Child("namespace"), "references to other namespaces are not allowed"))
+			continue
+		}
+
+		err := v.Reader.Get(ctx, types.NamespacedName{Name: r.ref.Name, Namespace: ns}, r.target)
+		switch {
+		case err == nil:
+		case apierrors.IsNotFound(err):
+			message := fmt.Sprintf("%s %s/%s does not exist", r.kind, ns, r.ref.Name)
+			if v.DanglingReferencePolicy == DanglingReferenceWarn {
+				warnings = append(warnings, fmt.Sprintf("%s: %s", r.path, message))
+			} else {
+				errs = append(errs, field.Invalid(r.path.Child("name"), r.ref.Name, message))
+			}
+		default:
+			// Don't block admission if the reference cannot be verified
+			warnings = append(warnings, fmt.Sprintf("%s: failed to verify %s %s/%s: %v", r.path, r.kind, ns, r.ref.Name, err))
+		}
+	}
+
+	return warnings, invalid(kind, name, errs)
+}
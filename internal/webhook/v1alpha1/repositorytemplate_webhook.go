@@ -0,0 +1,92 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// namespacePlaceholder is replaced by the tenant namespace in the repository URL of a
+// RepositoryTemplate.
+const namespacePlaceholder = "{{namespace}}"
+
+// SetupRepositoryTemplateWebhookWithManager registers the webhook validating
+// RepositoryTemplates.
+func SetupRepositoryTemplateWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.RepositoryTemplate{}).
+		WithValidator(&RepositoryTemplateCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-repositorytemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=repositorytemplates,verbs=create;update,versions=v1alpha1,name=vrepositorytemplate-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// RepositoryTemplateCustomValidator checks the namespace selector and the repository
+// spec of a RepositoryTemplate.
+type RepositoryTemplateCustomValidator struct{}
+
+var _ webhook.CustomValidator = &RepositoryTemplateCustomValidator{}
+
+// ValidateCreate validates a new RepositoryTemplate.
+func (v *RepositoryTemplateCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	template, ok := obj.(*backupv1alpha1.RepositoryTemplate)
+	if !ok {
+		return nil, fmt.Errorf("expected a RepositoryTemplate object but got %T", obj)
+	}
+	return nil, invalid("RepositoryTemplate", template.Name, validateRepositoryTemplateSpec(template))
+}
+
+// ValidateUpdate validates an updated RepositoryTemplate.
+func (v *RepositoryTemplateCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	template, ok := newObj.(*backupv1alpha1.RepositoryTemplate)
+	if !ok {
+		return nil, fmt.Errorf("expected a RepositoryTemplate object but got %T", newObj)
+	}
+	return nil, invalid("RepositoryTemplate", template.Name, validateRepositoryTemplateSpec(template))
+}
+
+// ValidateDelete admits every deletion.
+func (v *RepositoryTemplateCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateRepositoryTemplateSpec checks that the namespace selector parses and that
+// the repository URL gives every namespace its own repository.
+func validateRepositoryTemplateSpec(template *backupv1alpha1.RepositoryTemplate) field.ErrorList {
+	spec := field.NewPath("spec")
+	var errs field.ErrorList
+	if _, err := metav1.LabelSelectorAsSelector(&template.Spec.NamespaceSelector); err != nil {
+		errs = append(errs, field.Invalid(spec.Child("namespaceSelector"), template.Spec.NamespaceSelector, err.Error()))
+	}
+	repository := spec.Child("template", "spec")
+	if url := template.Spec.Template.Spec.RepositoryURL; !strings.Contains(url, namespacePlaceholder) {
+		errs = append(errs, field.Invalid(repository.Child("repositoryURL"), url,
+			fmt.Sprintf("must contain %s so that every namespace gets its own repository", namespacePlaceholder)))
+	}
+	return append(errs, validateRepositorySpec(repository, &template.Spec.Template.Spec)...)
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// minRetryBackoff is the shortest wait before retrying a failed backup job, so failing
+// backups don't load the repository with retries.
+const minRetryBackoff = time.Minute
+
+// SetupResticBackupWebhookWithManager registers the webhooks defaulting and validating
+// ResticBackups.
+func SetupResticBackupWebhookWithManager(mgr ctrl.Manager, validator *ReferenceValidator, defaulter *Defaulter, quota *NamespaceQuota) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.ResticBackup{}).
+		WithDefaulter(&ResticBackupCustomDefaulter{Defaulter: defaulter}).
+		WithValidator(&ResticBackupCustomValidator{ReferenceValidator: validator, Quota: quota}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-backup-resticbackup-io-v1alpha1-resticbackup,mutating=true,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=resticbackups,verbs=create,versions=v1alpha1,name=mresticbackup-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResticBackupCustomDefaulter sets the restic hostname, timezone and job configuration
+// of new ResticBackups.
+type ResticBackupCustomDefaulter struct {
+	*Defaulter
+}
+
+var _ webhook.CustomDefaulter = &ResticBackupCustomDefaulter{}
+
+// Default sets the defaults of a ResticBackup.
+func (d *ResticBackupCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	backup, ok := obj.(*backupv1alpha1.ResticBackup)
+	if !ok {
+		return fmt.Errorf("expected a ResticBackup object but got %T", obj)
+	}
+
+	if backup.Spec.Restic == nil {
+		backup.Spec.Restic = &backupv1alpha1.ResticConfig{}
+	}
+	// Generated names are not set yet, the controller then defaults to the generated name
+	if backup.Spec.Restic.Hostname == "" {
+		backup.Spec.Restic.Hostname = backup.Name
+	}
+	defaultTimezone(&backup.Spec.Timezone)
+	backup.Spec.JobConfig = defaultJobConfig(backup.Spec.JobConfig)
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-resticbackup,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=resticbackups,verbs=create;update,versions=v1alpha1,name=vresticbackup-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResticBackupCustomValidator checks the schedule, timezone and retention policy of a
+// ResticBackup, that the repositories it references exist and that its namespace
+// hasn't reached the backup limit.
+type ResticBackupCustomValidator struct {
+	*ReferenceValidator
+	// Quota limits the ResticBackups per namespace. Nil disables the limit.
+	Quota *NamespaceQuota
+}
+
+var _ webhook.CustomValidator = &ResticBackupCustomValidator{}
+
+// ValidateCreate validates a new ResticBackup.
+func (v *ResticBackupCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	backup, ok := obj.(*backupv1alpha1.ResticBackup)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticBackup object but got %T", obj)
+	}
+	if err := v.Quota.checkBackups(ctx, backup); err != nil {
+		return nil, err
+	}
+	return v.validate(ctx, "ResticBackup", backup.Namespace, backup.Name,
+		backupReferences(backup, nil), validateBackupSpec(backup))
+}
+
+// ValidateUpdate validates a ResticBackup and its changed repository references.
+func (v *ResticBackupCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldBackup, ok := oldObj.(*backupv1alpha1.ResticBackup)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticBackup object but got %T", oldObj)
+	}
+	backup, ok := newObj.(*backupv1alpha1.ResticBackup)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticBackup object but got %T", newObj)
+	}
+	// Removing finalizers must not fail because the repository was deleted first
+	if !backup.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return v.validate(ctx, "ResticBackup", backup.Namespace, backup.Name,
+		backupReferences(backup, oldBackup), validateBackupSpec(backup))
+}
+
+// ValidateDelete admits every deletion.
+func (v *ResticBackupCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// backupReferences returns the repository references of a ResticBackup. If old is set,
+// only references that changed are returned.
+func backupReferences(backup, old *backupv1alpha1.ResticBackup) []reference {
+	spec := field.NewPath("spec")
+	var references []reference
+	if old == nil || old.Spec.RepositoryRef != backup.Spec.RepositoryRef {
+		references = append(references, reference{
+			path:   spec.Child("repositoryRef"),
+			ref:    backup.Spec.RepositoryRef,
+			kind:   "ResticRepository",
+			target: &backupv1alpha1.ResticRepository{},
+		})
+	}
+	if fallback := backup.Spec.FallbackRepositoryRef; fallback != nil {
+		if old == nil || old.Spec.FallbackRepositoryRef == nil || *old.Spec.FallbackRepositoryRef != *fallback {
+			references = append(references, reference{
+				path:   spec.Child("fallbackRepositoryRef"),
+				ref:    *fallback,
+				kind:   "ResticRepository",
+				target: &backupv1alpha1.ResticRepository{},
+			})
+		}
+	}
+	return references
+}
+
+// validateBackupSpec checks the schedule, timezone, retention policy, memory cap, retry
+// backoff, sidecars, notification secrets, PVC source paths and PVC selectors of a
+// ResticBackup.
+func validateBackupSpec(backup *backupv1alpha1.ResticBackup) field.ErrorList {
+	spec := field.NewPath("spec")
+	errs := validateSchedule(spec.Child("schedule"), backup.Spec.Schedule)
+	errs = append(errs, validateTimezone(spec.Child("timezone"), backup.Spec.Timezone)...)
+	if retention := backup.Spec.Retention; retention != nil && retention.Enabled && retention.Policy != nil {
+		errs = append(errs, validateRetentionPolicy(spec.Child("retention", "policy"), retention.Policy)...)
+	}
+	if tune := backup.Spec.AutoTuneResources; tune != nil && tune.MaxMemory.Sign() <= 0 {
+		errs = append(errs, field.Invalid(spec.Child("autoTuneResources", "maxMemory"), tune.MaxMemory.String(), "must be greater than zero"))
+	}
+	if retry := backup.Spec.Retry; retry != nil && retry.Backoff != nil && retry.Backoff.Duration < minRetryBackoff {
+		errs = append(errs, field.Invalid(spec.Child("retry", "backoff"), retry.Backoff.Duration.String(), "must be at least 1m"))
+	}
+	if notifications := backup.Spec.Notifications; notifications != nil && notifications.Ntfy != nil {
+		if ref := notifications.Ntfy.CredentialsSecretRef; ref != nil && ref.Namespace != "" && ref.Namespace != backup.Namespace {
+			errs = append(errs, field.Invalid(spec.Child("notifications", "ntfy", "credentialsSecretRef", "namespace"), ref.Namespace,
+				"must be the namespace of the ResticBackup"))
+		}
+	}
+	errs = append(errs, validateSidecars(spec.Child("jobConfig"), backup.Spec.JobConfig)...)
+	for i, period := range backup.Spec.BlackoutPeriods {
+		if !period.End.After(period.Start.Time) {
+			errs = append(errs, field.Invalid(spec.Child("blackoutPeriods").Index(i).Child("end"), period.End.String(), "must be after start"))
+		}
+	}
+	if pvc := backup.Spec.Source.PVC; pvc != nil {
+		errs = append(errs, validateSourcePaths(spec.Child("source", "pvc", "paths"), pvc.Paths)...)
+	}
+	for i, pvc := range backup.Spec.Source.PVCs {
+		errs = append(errs, validateSourcePaths(spec.Child("source", "pvcs").Index(i).Child("paths"), pvc.Paths)...)
+	}
+	if selector := backup.Spec.Source.PVCSelector; selector != nil {
+		path := spec.Child("source", "pvcSelector")
+		if _, err := metav1.LabelSelectorAsSelector(&selector.Selector); err != nil {
+			errs = append(errs, field.Invalid(path.Child("selector"), selector.Selector, err.Error()))
+		}
+	}
+	if resources := backup.Spec.Source.Resources; resources != nil {
+		path := spec.Child("source", "resources")
+		if resources.LabelSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(resources.LabelSelector); err != nil {
+				errs = append(errs, field.Invalid(path.Child("labelSelector"), resources.LabelSelector, err.Error()))
+			}
+		}
+		// The backup pods run with the resources ServiceAccount
+		if jobConfig := backup.Spec.JobConfig; jobConfig != nil && (jobConfig.ServiceAccountName != "" || jobConfig.CreateServiceAccount) {
+			errs = append(errs, field.Forbidden(spec.Child("jobConfig"), "serviceAccountName and createServiceAccount can't be combined with a resources source"))
+		}
+	}
+	return errs
+}
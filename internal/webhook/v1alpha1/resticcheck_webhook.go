@@ -0,0 +1,102 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// SetupResticCheckWebhookWithManager registers the webhooks defaulting and validating
+// ResticChecks.
+func SetupResticCheckWebhookWithManager(mgr ctrl.Manager, defaulter *Defaulter) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.ResticCheck{}).
+		WithDefaulter(&ResticCheckCustomDefaulter{Defaulter: defaulter}).
+		WithValidator(&ResticCheckCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-backup-resticbackup-io-v1alpha1-resticcheck,mutating=true,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=resticchecks,verbs=create,versions=v1alpha1,name=mresticcheck-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResticCheckCustomDefaulter sets the timezone and job configuration of new
+// ResticChecks.
+type ResticCheckCustomDefaulter struct {
+	*Defaulter
+}
+
+var _ webhook.CustomDefaulter = &ResticCheckCustomDefaulter{}
+
+// Default sets the defaults of a ResticCheck.
+func (d *ResticCheckCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	check, ok := obj.(*backupv1alpha1.ResticCheck)
+	if !ok {
+		return fmt.Errorf("expected a ResticCheck object but got %T", obj)
+	}
+	defaultTimezone(&check.Spec.Timezone)
+	check.Spec.JobConfig = defaultJobConfig(check.Spec.JobConfig)
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-resticcheck,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=resticchecks,verbs=create;update,versions=v1alpha1,name=vresticcheck-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResticCheckCustomValidator checks the schedule and timezone of a ResticCheck.
+type ResticCheckCustomValidator struct{}
+
+var _ webhook.CustomValidator = &ResticCheckCustomValidator{}
+
+// ValidateCreate validates a new ResticCheck.
+func (v *ResticCheckCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	check, ok := obj.(*backupv1alpha1.ResticCheck)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticCheck object but got %T", obj)
+	}
+	return nil, invalid("ResticCheck", check.Name, validateCheckSpec(check))
+}
+
+// ValidateUpdate validates an updated ResticCheck.
+func (v *ResticCheckCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	check, ok := newObj.(*backupv1alpha1.ResticCheck)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticCheck object but got %T", newObj)
+	}
+	if !check.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return nil, invalid("ResticCheck", check.Name, validateCheckSpec(check))
+}
+
+// ValidateDelete admits every deletion.
+func (v *ResticCheckCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateCheckSpec checks the schedule, timezone and sidecars of a ResticCheck.
+func validateCheckSpec(check *backupv1alpha1.ResticCheck) field.ErrorList {
+	spec := field.NewPath("spec")
+	errs := validateSchedule(spec.Child("schedule"), check.Spec.Schedule)
+	errs = append(errs, validateTimezone(spec.Child("timezone"), check.Spec.Timezone)...)
+	return append(errs, validateSidecars(spec.Child("jobConfig"), check.Spec.JobConfig)...)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// SetupResticPruneWebhookWithManager registers the webhook validating ResticPrunes.
+func SetupResticPruneWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.ResticPrune{}).
+		WithValidator(&ResticPruneCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-resticprune,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=resticprunes,verbs=create;update,versions=v1alpha1,name=vresticprune-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResticPruneCustomValidator checks the sidecars of a ResticPrune.
+type ResticPruneCustomValidator struct{}
+
+var _ webhook.CustomValidator = &ResticPruneCustomValidator{}
+
+// ValidateCreate validates a new ResticPrune.
+func (v *ResticPruneCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	prune, ok := obj.(*backupv1alpha1.ResticPrune)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticPrune object but got %T", obj)
+	}
+	return nil, invalid("ResticPrune", prune.Name, validateSidecars(field.NewPath("spec", "jobConfig"), prune.Spec.JobConfig))
+}
+
+// ValidateUpdate validates an updated ResticPrune.
+func (v *ResticPruneCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	prune, ok := newObj.(*backupv1alpha1.ResticPrune)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticPrune object but got %T", newObj)
+	}
+	if !prune.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return nil, invalid("ResticPrune", prune.Name, validateSidecars(field.NewPath("spec", "jobConfig"), prune.Spec.JobConfig))
+}
+
+// ValidateDelete admits every deletion.
+func (v *ResticPruneCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// SetupResticReplicationWebhookWithManager registers the webhooks defaulting and
+// validating ResticReplications.
+func SetupResticReplicationWebhookWithManager(mgr ctrl.Manager, validator *ReferenceValidator, defaulter *Defaulter) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.ResticReplication{}).
+		WithDefaulter(&ResticReplicationCustomDefaulter{Defaulter: defaulter}).
+		WithValidator(&ResticReplicationCustomValidator{ReferenceValidator: validator}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-backup-resticbackup-io-v1alpha1-resticreplication,mutating=true,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=resticreplications,verbs=create,versions=v1alpha1,name=mresticreplication-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResticReplicationCustomDefaulter sets the timezone and job configuration of new
+// ResticReplications.
+type ResticReplicationCustomDefaulter struct {
+	*Defaulter
+}
+
+var _ webhook.CustomDefaulter = &ResticReplicationCustomDefaulter{}
+
+// Default sets the defaults of a ResticReplication.
+func (d *ResticReplicationCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	replication, ok := obj.(*backupv1alpha1.ResticReplication)
+	if !ok {
+		return fmt.Errorf("expected a ResticReplication object but got %T", obj)
+	}
+	defaultTimezone(&replication.Spec.Timezone)
+	replication.Spec.JobConfig = defaultJobConfig(replication.Spec.JobConfig)
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-resticreplication,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=resticreplications,verbs=create;update,versions=v1alpha1,name=vresticreplication-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResticReplicationCustomValidator checks the schedule of a ResticReplication and that
+// the repositories it references exist and differ.
+type ResticReplicationCustomValidator struct {
+	*ReferenceValidator
+}
+
+var _ webhook.CustomValidator = &ResticReplicationCustomValidator{}
+
+// ValidateCreate validates a new ResticReplication.
+func (v *ResticReplicationCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	replication, ok := obj.(*backupv1alpha1.ResticReplication)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticReplication object but got %T", obj)
+	}
+	return v.validate(ctx, "ResticReplication", replication.Namespace, replication.Name,
+		replicationReferences(replication), validateReplicationSpec(replication))
+}
+
+// ValidateUpdate validates a ResticReplication and its repository references if they
+// changed.
+func (v *ResticReplicationCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldReplication, ok := oldObj.(*backupv1alpha1.ResticReplication)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticReplication object but got %T", oldObj)
+	}
+	replication, ok := newObj.(*backupv1alpha1.ResticReplication)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticReplication object but got %T", newObj)
+	}
+	if !replication.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	var references []reference
+	if oldReplication.Spec.SourceRepositoryRef != replication.Spec.SourceRepositoryRef ||
+		oldReplication.Spec.DestinationRepositoryRef != replication.Spec.DestinationRepositoryRef {
+		references = replicationReferences(replication)
+	}
+	return v.validate(ctx, "ResticReplication", replication.Namespace, replication.Name,
+		references, validateReplicationSpec(replication))
+}
+
+// ValidateDelete admits every deletion.
+func (v *ResticReplicationCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func replicationReferences(replication *backupv1alpha1.ResticReplication) []reference {
+	spec := field.NewPath("spec")
+	return []reference{
+		{
+			path:   spec.Child("sourceRepositoryRef"),
+			ref:    replication.Spec.SourceRepositoryRef,
+			kind:   "ResticRepository",
+			target: &backupv1alpha1.ResticRepository{},
+		},
+		{
+			path:   spec.Child("destinationRepositoryRef"),
+			ref:    replication.Spec.DestinationRepositoryRef,
+			kind:   "ResticRepository",
+			target: &backupv1alpha1.ResticRepository{},
+		},
+	}
+}
+
+// validateReplicationSpec checks the schedule, timezone and sidecars of a
+// ResticReplication and that it copies between two repositories.
+func validateReplicationSpec(replication *backupv1alpha1.ResticReplication) field.ErrorList {
+	spec := field.NewPath("spec")
+	errs := validateSchedule(spec.Child("schedule"), replication.Spec.Schedule)
+	errs = append(errs, validateTimezone(spec.Child("timezone"), replication.Spec.Timezone)...)
+	errs = append(errs, validateSidecars(spec.Child("jobConfig"), replication.Spec.JobConfig)...)
+
+	namespaceOf := func(ref backupv1alpha1.CrossNamespaceObjectReference) string {
+		if ref.Namespace != "" {
+			return ref.Namespace
+		}
+		return replication.Namespace
+	}
+	source, destination := replication.Spec.SourceRepositoryRef, replication.Spec.DestinationRepositoryRef
+	if source.Name == destination.Name && namespaceOf(source) == namespaceOf(destination) {
+		errs = append(errs, field.Invalid(spec.Child("destinationRepositoryRef", "name"), destination.Name,
+			"the destination must differ from the source repository"))
+	}
+	return errs
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// SetupResticRepositoryWebhookWithManager registers the webhook validating ResticRepositories.
+func SetupResticRepositoryWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.ResticRepository{}).
+		WithValidator(&ResticRepositoryCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-resticrepository,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=resticrepositories,verbs=create;update,versions=v1alpha1,name=vresticrepository-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResticRepositoryCustomValidator checks the schedules and default retention policy of
+// a ResticRepository.
+type ResticRepositoryCustomValidator struct{}
+
+var _ webhook.CustomValidator = &ResticRepositoryCustomValidator{}
+
+// ValidateCreate validates a new ResticRepository.
+func (v *ResticRepositoryCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	repository, ok := obj.(*backupv1alpha1.ResticRepository)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticRepository object but got %T", obj)
+	}
+	return nil, invalid("ResticRepository", repository.Name, validateRepositorySpec(field.NewPath("spec"), &repository.Spec))
+}
+
+// ValidateUpdate validates an updated ResticRepository.
+func (v *ResticRepositoryCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	repository, ok := newObj.(*backupv1alpha1.ResticRepository)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticRepository object but got %T", newObj)
+	}
+	// Removing the finalizer must not fail on a spec written before the webhook
+	if !repository.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return nil, invalid("ResticRepository", repository.Name, validateRepositorySpec(field.NewPath("spec"), &repository.Spec))
+}
+
+// ValidateDelete admits every deletion.
+func (v *ResticRepositoryCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateRepositorySpec checks the schedules, default retention policy, check strategy,
+// deletion confirmation and timezones of a ResticRepository spec found at path.
+func validateRepositorySpec(spec *field.Path, repository *backupv1alpha1.ResticRepositorySpec) field.ErrorList {
+	var errs field.ErrorList
+	if check := repository.IntegrityCheck; check != nil {
+		errs = append(errs, validateSchedule(spec.Child("integrityCheck", "schedule"), check.Schedule)...)
+	}
+	if cache := repository.Cache; cache != nil {
+		errs = append(errs, validateSchedule(spec.Child("cache", "cleanupSchedule"), cache.CleanupSchedule)...)
+	}
+	if retention := repository.DefaultRetention; retention != nil && retention.Enabled && retention.Policy != nil {
+		errs = append(errs, validateRetentionPolicy(spec.Child("defaultRetention", "policy"), retention.Policy)...)
+	}
+	errs = append(errs, validateSpaceCheck(spec.Child("spaceCheck"), repository)...)
+	if strings.HasPrefix(repository.RepositoryURL, "sftp:") && repository.CheckStrategy != backupv1alpha1.CheckStrategyJob {
+		errs = append(errs, field.Invalid(spec.Child("checkStrategy"), repository.CheckStrategy,
+			"must be Job for sftp repositories, the operator has no SSH client"))
+	}
+	if repository.DeletionPolicy == "Delete" && repository.DeletionConfirmation != repository.RepositoryURL {
+		errs = append(errs, field.Invalid(spec.Child("deletionConfirmation"), repository.DeletionConfirmation,
+			"must be the repositoryURL to delete the repository data"))
+	}
+	if intermittent := repository.Intermittent; intermittent != nil {
+		errs = append(errs, validateTimezone(spec.Child("intermittent", "timezone"), intermittent.Timezone)...)
+	}
+	return errs
+}
+
+// validateSpaceCheck checks that the free space of the backend can be determined. Only
+// the file system of local and sftp backends can be queried, other backends need a
+// capacity.
+func validateSpaceCheck(path *field.Path, repository *backupv1alpha1.ResticRepositorySpec) field.ErrorList {
+	check := repository.SpaceCheck
+	if check == nil || check.Capacity != nil {
+		return nil
+	}
+	scheme, _, _ := strings.Cut(repository.RepositoryURL, ":")
+	if scheme != "local" && scheme != "sftp" {
+		return field.ErrorList{field.Required(path.Child("capacity"),
+			fmt.Sprintf("the free space of %s backends can't be queried, set the capacity of the repository", scheme))}
+	}
+	return nil
+}
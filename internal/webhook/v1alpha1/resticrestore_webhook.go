@@ -0,0 +1,222 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// SetupResticRestoreWebhookWithManager registers the webhook validating ResticRestores.
+func SetupResticRestoreWebhookWithManager(mgr ctrl.Manager, validator *ReferenceValidator, quota *NamespaceQuota) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&backupv1alpha1.ResticRestore{}).
+		WithValidator(&ResticRestoreCustomValidator{ReferenceValidator: validator, Quota: quota}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-backup-resticbackup-io-v1alpha1-resticrestore,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.resticbackup.io,resources=resticrestores,verbs=create;update,versions=v1alpha1,name=vresticrestore-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResticRestoreCustomValidator checks the target of a ResticRestore, that the
+// ResticBackup it references exists and that its namespace hasn't reached the limit of
+// active restores.
+// Restores are validated on creation only, their backup may be deleted while they run.
+type ResticRestoreCustomValidator struct {
+	*ReferenceValidator
+	// Quota limits the active ResticRestores per namespace. Nil disables the limit.
+	Quota *NamespaceQuota
+}
+
+var _ webhook.CustomValidator = &ResticRestoreCustomValidator{}
+
+// ValidateCreate validates a new ResticRestore.
+func (v *ResticRestoreCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	restore, ok := obj.(*backupv1alpha1.ResticRestore)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticRestore object but got %T", obj)
+	}
+	if err := v.Quota.checkRestores(ctx, restore); err != nil {
+		return nil, err
+	}
+	return v.validate(ctx, "ResticRestore", restore.Namespace, restore.Name,
+		[]reference{{
+			path:   field.NewPath("spec", "backupRef"),
+			ref:    restore.Spec.BackupRef,
+			kind:   "ResticBackup",
+			target: &backupv1alpha1.ResticBackup{},
+		}}, slices.Concat(validateRestoreTarget(&restore.Spec), validateRestoreChain(&restore.Spec), validateRestoreDrill(&restore.Spec),
+			validateSidecars(field.NewPath("spec", "jobConfig"), restore.Spec.JobConfig)))
+}
+
+// ValidateUpdate validates the schedule of restore drills, which keep running after
+// updates. The spec of other restores only takes effect on creation.
+func (v *ResticRestoreCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	restore, ok := newObj.(*backupv1alpha1.ResticRestore)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResticRestore object but got %T", newObj)
+	}
+	return nil, invalid("ResticRestore", restore.Name, validateRestoreDrill(&restore.Spec))
+}
+
+// ValidateDelete admits every deletion.
+func (v *ResticRestoreCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateRestoreTarget checks that exactly one target is set, that the pod target
+// is used by the FileRestore mode, which restores selected paths only, and that only
+// restores into an existing PVC stop a workload.
+func validateRestoreTarget(spec *backupv1alpha1.ResticRestoreSpec) field.ErrorList {
+	path := field.NewPath("spec", "target")
+	target := &spec.Target
+	set := 0
+	for _, isSet := range []bool{target.PVC != nil, target.NewPVC != nil, target.Pod != nil, target.Dump != nil} {
+		if isSet {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return field.ErrorList{field.Forbidden(path, "pvc, newPVC, pod and dump are mutually exclusive")}
+	case set == 0:
+		return field.ErrorList{field.Required(path, "one of pvc, newPVC, pod or dump must be set")}
+	}
+
+	fileRestore := spec.Mode == backupv1alpha1.RestoreModeFileRestore
+	switch {
+	case fileRestore && target.Pod == nil:
+		return field.ErrorList{field.Required(path.Child("pod"), "the FileRestore mode restores into a pod target")}
+	case !fileRestore && target.Pod != nil:
+		return field.ErrorList{field.Forbidden(path.Child("pod"), "a pod target requires the FileRestore mode")}
+	case fileRestore && len(spec.IncludePaths) == 0:
+		return field.ErrorList{field.Required(field.NewPath("spec", "includePaths"), "the FileRestore mode restores selected paths")}
+	case spec.StopWorkload != nil && target.PVC == nil:
+		return field.ErrorList{field.Forbidden(field.NewPath("spec", "stopWorkload"), "only restores into a pvc target stop the workload using it")}
+	}
+	if target.Dump != nil {
+		return validateDumpTarget(spec)
+	}
+	if target.NewPVC != nil {
+		return validateNewPVCTarget(path.Child("newPVC"), target.NewPVC)
+	}
+	return nil
+}
+
+// validateNewPVCTarget checks a newPVC target. Restic restores files, so Block volumes
+// can't be restored into.
+func validateNewPVCTarget(path *field.Path, target *backupv1alpha1.NewPVCTarget) field.ErrorList {
+	var errs field.ErrorList
+	if target.Size == "" && !target.InheritFromSource && !target.RestoreMetadata {
+		errs = append(errs, field.Required(path.Child("size"), "the size is required unless inheritFromSource or restoreMetadata is set"))
+	}
+	if target.Size != "" {
+		if _, err := resource.ParseQuantity(target.Size); err != nil {
+			errs = append(errs, field.Invalid(path.Child("size"), target.Size, err.Error()))
+		}
+	}
+	if target.VolumeMode == string(corev1.PersistentVolumeBlock) {
+		errs = append(errs, field.NotSupported(path.Child("volumeMode"), target.VolumeMode, []string{string(corev1.PersistentVolumeFilesystem)}))
+	}
+	return errs
+}
+
+// validateDumpTarget checks a dump target. The operator dumps a single path into a
+// Secret or ConfigMap without a restore job, so the job settings don't apply.
+func validateDumpTarget(spec *backupv1alpha1.ResticRestoreSpec) field.ErrorList {
+	path := field.NewPath("spec", "target", "dump")
+	var errs field.ErrorList
+	if spec.Target.Dump.Key != "" {
+		for _, msg := range validation.IsConfigMapKey(spec.Target.Dump.Key) {
+			errs = append(errs, field.Invalid(path.Child("key"), spec.Target.Dump.Key, msg))
+		}
+	}
+	if len(spec.IncludePaths) > 0 {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "includePaths"), "a dump target restores the path of the dump"))
+	}
+	if spec.Assertions != nil {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "assertions"), "a dump target runs no restore job to check"))
+	}
+	return errs
+}
+
+// validateRestoreChain checks that a restore chain replaces the snapshot selection of
+// the restore and restores into a PVC or pod. The time range applies to the chain
+// selector only.
+func validateRestoreChain(spec *backupv1alpha1.ResticRestoreSpec) field.ErrorList {
+	chain := spec.Chain
+	if chain == nil {
+		return nil
+	}
+
+	path := field.NewPath("spec")
+	var errs field.ErrorList
+	if spec.SnapshotID != "" {
+		errs = append(errs, field.Forbidden(path.Child("snapshotID"), "a restore chain selects its snapshots with chain"))
+	}
+	if spec.SnapshotSelector != nil {
+		errs = append(errs, field.Forbidden(path.Child("snapshotSelector"), "a restore chain selects its snapshots with chain"))
+	}
+	if spec.Target.Dump != nil {
+		errs = append(errs, field.Forbidden(path.Child("target", "dump"), "a dump target restores a single snapshot"))
+	}
+	if chain.After != nil && chain.Selector == nil {
+		errs = append(errs, field.Forbidden(path.Child("chain", "after"), "the time range applies to the chain selector"))
+	}
+	if chain.After != nil && chain.Selector != nil && chain.Selector.Before != nil && !chain.After.Before(chain.Selector.Before) {
+		errs = append(errs, field.Invalid(path.Child("chain", "after"), chain.After.Format(time.RFC3339), "must be before the end of the time range"))
+	}
+	return errs
+}
+
+// validateRestoreDrill checks the schedule of a restore drill. Drills restore the latest
+// snapshot into a PVC created for them, so they can't pin a snapshot or restore into
+// an existing PVC.
+func validateRestoreDrill(spec *backupv1alpha1.ResticRestoreSpec) field.ErrorList {
+	if spec.Schedule == "" {
+		return nil
+	}
+
+	path := field.NewPath("spec")
+	errs := validateSchedule(path.Child("schedule"), spec.Schedule)
+	errs = append(errs, validateTimezone(path.Child("timezone"), spec.Timezone)...)
+	if spec.SnapshotID != "" {
+		errs = append(errs, field.Forbidden(path.Child("snapshotID"), "a restore drill restores the latest snapshot"))
+	}
+	if spec.SnapshotSelector != nil && spec.SnapshotSelector.Before != nil {
+		errs = append(errs, field.Forbidden(path.Child("snapshotSelector", "before"), "a restore drill restores the latest snapshot"))
+	}
+	if spec.Chain != nil {
+		errs = append(errs, field.Forbidden(path.Child("chain"), "a restore drill restores the latest snapshot"))
+	}
+	if spec.Target.NewPVC == nil {
+		errs = append(errs, field.Required(path.Child("target", "newPVC"), "a restore drill restores into a newPVC target"))
+	}
+	return errs
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// scheduleParser parses cron schedules as the CronJob controller does.
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// validateSchedule checks the syntax of a cron schedule. Empty schedules are left to
+// the CRD schema, which requires them where they are mandatory.
+func validateSchedule(path *field.Path, schedule string) field.ErrorList {
+	if schedule == "" {
+		return nil
+	}
+	if _, err := scheduleParser.Parse(schedule); err != nil {
+		return field.ErrorList{field.Invalid(path, schedule, fmt.Sprintf("invalid cron schedule: %v", err))}
+	}
+	return nil
+}
+
+// validateTimezone checks that a timezone is a known IANA time zone name.
+func validateTimezone(path *field.Path, timezone string) field.ErrorList {
+	if timezone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return field.ErrorList{field.Invalid(path, timezone, "unknown time zone")}
+	}
+	return nil
+}
+
+// validateRetentionPolicy checks that a retention policy has at least one keep rule,
+// restic refuses to forget snapshots without one.
+func validateRetentionPolicy(path *field.Path, policy *backupv1alpha1.RetentionPolicy) field.ErrorList {
+	if policy.HasKeepRule() {
+		return nil
+	}
+	return field.ErrorList{field.Required(path, "at least one keep rule must be set")}
+}
+
+// validateSourcePaths checks the paths of a PVC source. Paths must be absolute and
+// must not contain "..", so they can't escape the volume, and must not repeat or
+// contain each other. Trailing slashes are ignored, "/data/" is the path "/data".
+func validateSourcePaths(fieldPath *field.Path, paths []string) field.ErrorList {
+	var errs field.ErrorList
+	cleaned := make([]string, 0, len(paths))
+	for i, p := range paths {
+		if !strings.HasPrefix(p, "/") {
+			errs = append(errs, field.Invalid(fieldPath.Index(i), p, "must be absolute"))
+			continue
+		}
+		if slices.Contains(strings.Split(p, "/"), "..") {
+			errs = append(errs, field.Invalid(fieldPath.Index(i), p, `must not contain ".."`))
+			continue
+		}
+		clean := path.Clean(p)
+		for _, other := range cleaned {
+			if pathContains(other, clean) || pathContains(clean, other) {
+				errs = append(errs, field.Invalid(fieldPath.Index(i), p, fmt.Sprintf("overlaps path %q", other)))
+				break
+			}
+		}
+		cleaned = append(cleaned, clean)
+	}
+	return errs
+}
+
+// validateSidecars checks that no sidecar of a job configuration uses the name of a
+// container the operator adds to the job pods or of another sidecar.
+func validateSidecars(path *field.Path, jobConfig *backupv1alpha1.JobConfiguration) field.ErrorList {
+	if name := jobConfig.SidecarNameConflict(); name != "" {
+		return field.ErrorList{field.Invalid(path.Child("sidecars"), name,
+			fmt.Sprintf("sidecar names must be unique and must not be one of %s", strings.Join(backupv1alpha1.ReservedContainerNames, ", ")))}
+	}
+	return nil
+}
+
+// pathContains reports whether child is parent or a path below it.
+func pathContains(parent, child string) bool {
+	return parent == "/" || child == parent || strings.HasPrefix(child, parent+"/")
+}
+
+// invalid returns an Invalid error for the resource name of kind, or nil if there are
+// no errors.
+func invalid(kind, name string, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: backupv1alpha1.GroupVersion.Group, Kind: kind}, name, errs)
+}
@@ -0,0 +1,605 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+func TestResticBackupValidateCreate_Spec(t *testing.T) {
+	keep := int32(7)
+	start := metav1.NewTime(time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name    string
+		mutate  func(*backupv1alpha1.ResticBackup)
+		wantErr bool
+	}{
+		{"valid schedule", func(b *backupv1alpha1.ResticBackup) { b.Spec.Schedule = "0 2 * * *" }, false},
+		{"descriptor schedule", func(b *backupv1alpha1.ResticBackup) { b.Spec.Schedule = "@daily" }, false},
+		{"invalid schedule", func(b *backupv1alpha1.ResticBackup) { b.Spec.Schedule = "0 25 * * *" }, true},
+		{"too few schedule fields", func(b *backupv1alpha1.ResticBackup) { b.Spec.Schedule = "0 2 * *" }, true},
+		{"valid timezone", func(b *backupv1alpha1.ResticBackup) { b.Spec.Timezone = "Europe/Berlin" }, false},
+		{"unknown timezone", func(b *backupv1alpha1.ResticBackup) { b.Spec.Timezone = "Europe/Nowhere" }, true},
+		{"retention with keep rule", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Retention = &backupv1alpha1.RetentionConfig{Enabled: true, Policy: &backupv1alpha1.RetentionPolicy{KeepDaily: &keep}}
+		}, false},
+		{"retention without keep rule", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Retention = &backupv1alpha1.RetentionConfig{Enabled: true, Policy: &backupv1alpha1.RetentionPolicy{}}
+		}, true},
+		{"disabled retention without keep rule", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Retention = &backupv1alpha1.RetentionConfig{Policy: &backupv1alpha1.RetentionPolicy{}}
+		}, false},
+		{"memory cap", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.AutoTuneResources = &backupv1alpha1.AutoTuneResources{MaxMemory: resource.MustParse("2Gi")}
+		}, false},
+		{"zero memory cap", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.AutoTuneResources = &backupv1alpha1.AutoTuneResources{}
+		}, true},
+		{"retry backoff", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Retry = &backupv1alpha1.BackupRetryPolicy{Backoff: &metav1.Duration{Duration: 10 * time.Minute}}
+		}, false},
+		{"retry backoff below a minute", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Retry = &backupv1alpha1.BackupRetryPolicy{Backoff: &metav1.Duration{Duration: 10 * time.Second}}
+		}, true},
+		{"ntfy credentials in the backup namespace", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Notifications = &backupv1alpha1.NotificationConfig{Ntfy: &backupv1alpha1.NtfyConfig{
+				CredentialsSecretRef: &backupv1alpha1.NtfyCredentialsSecretRef{Name: "ntfy", Namespace: b.Namespace},
+			}}
+		}, false},
+		{"ntfy credentials in another namespace", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Notifications = &backupv1alpha1.NotificationConfig{Ntfy: &backupv1alpha1.NtfyConfig{
+				CredentialsSecretRef: &backupv1alpha1.NtfyCredentialsSecretRef{Name: "ntfy", Namespace: "kube-system"},
+			}}
+		}, true},
+		{"blackout period", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.BlackoutPeriods = []backupv1alpha1.BlackoutPeriod{{Start: start, End: metav1.NewTime(start.Add(time.Hour))}}
+		}, false},
+		{"blackout period ending before its start", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.BlackoutPeriods = []backupv1alpha1.BlackoutPeriod{{Start: start, End: metav1.NewTime(start.Add(-time.Hour))}}
+		}, true},
+		{"sidecar", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.JobConfig = &backupv1alpha1.JobConfiguration{Sidecars: []corev1.Container{{Name: "fluent-bit"}}}
+		}, false},
+		{"sidecar named restic", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.JobConfig = &backupv1alpha1.JobConfiguration{Sidecars: []corev1.Container{{Name: "restic"}}}
+		}, true},
+		{"sidecars with the same name", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.JobConfig = &backupv1alpha1.JobConfiguration{Sidecars: []corev1.Container{{Name: "proxy"}, {Name: "proxy"}}}
+		}, true},
+		{"pvc source paths", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Source.PVC = &backupv1alpha1.PVCSource{ClaimName: "data", Paths: []string{"/media", "/media-cache", "/data/"}}
+		}, false},
+		{"relative pvc source path", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Source.PVC = &backupv1alpha1.PVCSource{ClaimName: "data", Paths: []string{"media"}}
+		}, true},
+		{"pvc source path escaping the volume", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Source.PVC = &backupv1alpha1.PVCSource{ClaimName: "data", Paths: []string{"/media/../../etc"}}
+		}, true},
+		{"overlapping pvc source paths", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Source.PVCs = []backupv1alpha1.PVCSource{{ClaimName: "data", Paths: []string{"/media/", "/media/cache"}}}
+		}, true},
+		{"pvc selector", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Source = backupv1alpha1.BackupSource{PVCSelector: &backupv1alpha1.PVCSelectorSource{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "postgres"}},
+			}}
+		}, false},
+		{"invalid pvc selector", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Source = backupv1alpha1.BackupSource{PVCSelector: &backupv1alpha1.PVCSelectorSource{
+				Selector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Like"}}},
+			}}
+		}, true},
+		{"resources source", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Source.Resources = &backupv1alpha1.ResourcesSource{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nextcloud"}},
+			}
+		}, false},
+		{"invalid resources label selector", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Source.Resources = &backupv1alpha1.ResourcesSource{
+				LabelSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Like"}}},
+			}
+		}, true},
+		{"resources source with service account", func(b *backupv1alpha1.ResticBackup) {
+			b.Spec.Source.Resources = &backupv1alpha1.ResourcesSource{}
+			b.Spec.JobConfig = &backupv1alpha1.JobConfiguration{ServiceAccountName: "backup"}
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &ResticBackupCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceReject, newRepository("default", "repo"))}
+			backup := newBackup("repo")
+			tt.mutate(backup)
+
+			_, err := v.ValidateCreate(context.Background(), backup)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !apierrors.IsInvalid(err) {
+				t.Errorf("expected an Invalid error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestResticRestoreValidateCreate_Target(t *testing.T) {
+	v := &ResticRestoreCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceReject, newBackup("repo"))}
+	restore := &backupv1alpha1.ResticRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "default"},
+		Spec: backupv1alpha1.ResticRestoreSpec{
+			BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "backup"},
+			Target: backupv1alpha1.RestoreTarget{
+				PVC:    &backupv1alpha1.PVCTarget{ClaimName: "data"},
+				NewPVC: &backupv1alpha1.NewPVCTarget{Name: "restored", Size: "1Gi"},
+			},
+		},
+	}
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected pvc and newPVC to be rejected, got %v", err)
+	}
+
+	restore.Spec.Target.PVC = nil
+	if _, err := v.ValidateCreate(context.Background(), restore); err != nil {
+		t.Errorf("expected a newPVC target to be admitted, got %v", err)
+	}
+
+	restore.Spec.Target.NewPVC.VolumeMode = "Block"
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a Block newPVC to be rejected, got %v", err)
+	}
+
+	restore.Spec.Target.NewPVC.VolumeMode = ""
+	restore.Spec.Target.NewPVC.Size = ""
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a newPVC without size to be rejected, got %v", err)
+	}
+
+	restore.Spec.Target.NewPVC.RestoreMetadata = true
+	if _, err := v.ValidateCreate(context.Background(), restore); err != nil {
+		t.Errorf("expected a newPVC restoring the recorded size to be admitted, got %v", err)
+	}
+
+	restore.Spec.Target.NewPVC.RestoreMetadata = false
+	restore.Spec.Target.NewPVC.InheritFromSource = true
+	if _, err := v.ValidateCreate(context.Background(), restore); err != nil {
+		t.Errorf("expected a newPVC inheriting the size to be admitted, got %v", err)
+	}
+
+	restore.Spec.Target.NewPVC = nil
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a missing target to be rejected, got %v", err)
+	}
+
+	restore.Spec.Target.Pod = &backupv1alpha1.PodTarget{Name: "nextcloud-0", Volume: "data"}
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a pod target without the FileRestore mode to be rejected, got %v", err)
+	}
+
+	restore.Spec.Mode = backupv1alpha1.RestoreModeFileRestore
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a file restore without include paths to be rejected, got %v", err)
+	}
+
+	restore.Spec.IncludePaths = []string{"/data/config.php"}
+	if _, err := v.ValidateCreate(context.Background(), restore); err != nil {
+		t.Errorf("expected a file restore into a pod to be admitted, got %v", err)
+	}
+
+	restore.Spec.StopWorkload = &backupv1alpha1.ScaleDownHook{
+		WorkloadRef: backupv1alpha1.WorkloadReference{Kind: "StatefulSet", Name: "nextcloud"},
+	}
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected stopping the workload of a pod target to be rejected, got %v", err)
+	}
+
+	restore.Spec.Target.Pod = nil
+	restore.Spec.Target.PVC = &backupv1alpha1.PVCTarget{ClaimName: "data"}
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a file restore without pod target to be rejected, got %v", err)
+	}
+
+	restore.Spec.Mode = backupv1alpha1.RestoreModeFull
+	if _, err := v.ValidateCreate(context.Background(), restore); err != nil {
+		t.Errorf("expected stopping the workload of a pvc target to be admitted, got %v", err)
+	}
+}
+
+func TestResticRestoreValidateCreate_DumpTarget(t *testing.T) {
+	v := &ResticRestoreCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceReject, newBackup("repo"))}
+	restore := &backupv1alpha1.ResticRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "default"},
+		Spec: backupv1alpha1.ResticRestoreSpec{
+			BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "backup"},
+			Target: backupv1alpha1.RestoreTarget{
+				Dump: &backupv1alpha1.DumpTarget{Path: "/data/config.yaml", Name: "config"},
+			},
+		},
+	}
+	if _, err := v.ValidateCreate(context.Background(), restore); err != nil {
+		t.Errorf("expected a dump target to be admitted, got %v", err)
+	}
+
+	restore.Spec.Target.PVC = &backupv1alpha1.PVCTarget{ClaimName: "data"}
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected dump and pvc to be rejected, got %v", err)
+	}
+
+	restore.Spec.Target.PVC = nil
+	restore.Spec.Target.Dump.Key = "config/yaml"
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected an invalid dump key to be rejected, got %v", err)
+	}
+
+	restore.Spec.Target.Dump.Key = ""
+	restore.Spec.IncludePaths = []string{"/data"}
+	if _, err := v.ValidateCreate(context.Background(), restore); !apierrors.IsInvalid(err) {
+		t.Errorf("expected include paths with a dump target to be rejected, got %v", err)
+	}
+}
+
+func TestResticRestoreValidateCreate_Chain(t *testing.T) {
+	v := &ResticRestoreCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceReject, newBackup("repo"))}
+	after := metav1.NewTime(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	before := metav1.NewTime(after.Add(24 * time.Hour))
+	newRestore := func(mutate func(*backupv1alpha1.ResticRestoreSpec)) *backupv1alpha1.ResticRestore {
+		restore := &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "default"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "backup"},
+				Chain: &backupv1alpha1.RestoreChain{
+					Selector: &backupv1alpha1.SnapshotSelector{Tags: []string{"postgres"}, Before: &before},
+					After:    &after,
+				},
+				Target: backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "data"}},
+			},
+		}
+		mutate(&restore.Spec)
+		return restore
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*backupv1alpha1.ResticRestoreSpec)
+		wantErr bool
+	}{
+		{name: "chain selector", mutate: func(*backupv1alpha1.ResticRestoreSpec) {}},
+		{name: "snapshot IDs", mutate: func(s *backupv1alpha1.ResticRestoreSpec) {
+			s.Chain = &backupv1alpha1.RestoreChain{SnapshotIDs: []string{"base", "wal"}}
+		}},
+		{name: "snapshot ID", mutate: func(s *backupv1alpha1.ResticRestoreSpec) { s.SnapshotID = "abc123" }, wantErr: true},
+		{name: "snapshot selector", mutate: func(s *backupv1alpha1.ResticRestoreSpec) {
+			s.SnapshotSelector = &backupv1alpha1.SnapshotSelector{Latest: true}
+		}, wantErr: true},
+		{name: "dump target", mutate: func(s *backupv1alpha1.ResticRestoreSpec) {
+			s.Target = backupv1alpha1.RestoreTarget{Dump: &backupv1alpha1.DumpTarget{Path: "/data/config.yaml", Name: "config"}}
+		}, wantErr: true},
+		{name: "time range without selector", mutate: func(s *backupv1alpha1.ResticRestoreSpec) {
+			s.Chain.SnapshotIDs, s.Chain.Selector = []string{"base"}, nil
+		}, wantErr: true},
+		{name: "empty time range", mutate: func(s *backupv1alpha1.ResticRestoreSpec) { s.Chain.After = &before }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateCreate(context.Background(), newRestore(tt.mutate))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !apierrors.IsInvalid(err) {
+				t.Errorf("expected an Invalid error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestResticRestoreValidateUpdate_Drill(t *testing.T) {
+	v := &ResticRestoreCustomValidator{}
+	newRestore := func(mutate func(*backupv1alpha1.ResticRestoreSpec)) *backupv1alpha1.ResticRestore {
+		restore := &backupv1alpha1.ResticRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "drill", Namespace: "default"},
+			Spec: backupv1alpha1.ResticRestoreSpec{
+				BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "backup"},
+				Schedule:  "0 4 * * 0",
+				Target:    backupv1alpha1.RestoreTarget{NewPVC: &backupv1alpha1.NewPVCTarget{Name: "scratch", Size: "1Gi"}},
+			},
+		}
+		mutate(&restore.Spec)
+		return restore
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*backupv1alpha1.ResticRestoreSpec)
+		wantErr bool
+	}{
+		{name: "valid drill", mutate: func(*backupv1alpha1.ResticRestoreSpec) {}},
+		{name: "invalid schedule", mutate: func(s *backupv1alpha1.ResticRestoreSpec) { s.Schedule = "weekly" }, wantErr: true},
+		{name: "unknown timezone", mutate: func(s *backupv1alpha1.ResticRestoreSpec) { s.Timezone = "Mars/Olympus" }, wantErr: true},
+		{name: "snapshot ID", mutate: func(s *backupv1alpha1.ResticRestoreSpec) { s.SnapshotID = "abc123" }, wantErr: true},
+		{name: "restore chain", mutate: func(s *backupv1alpha1.ResticRestoreSpec) {
+			s.Chain = &backupv1alpha1.RestoreChain{SnapshotIDs: []string{"base", "wal"}}
+		}, wantErr: true},
+		{name: "existing PVC", mutate: func(s *backupv1alpha1.ResticRestoreSpec) {
+			s.Target = backupv1alpha1.RestoreTarget{PVC: &backupv1alpha1.PVCTarget{ClaimName: "data"}}
+		}, wantErr: true},
+		{name: "no schedule", mutate: func(s *backupv1alpha1.ResticRestoreSpec) {
+			s.Schedule = ""
+			s.SnapshotID = "abc123"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateUpdate(context.Background(), nil, newRestore(tt.mutate))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !apierrors.IsInvalid(err) {
+				t.Errorf("expected an Invalid error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGlobalRetentionPolicyValidateCreate_Spec(t *testing.T) {
+	v := &GlobalRetentionPolicyCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceReject, newRepository("default", "repo"))}
+	keep := int32(3)
+	policy := &backupv1alpha1.GlobalRetentionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+		Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+			RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo", Namespace: "default"},
+			Schedule:      "0 4 * * *",
+			Policies: []backupv1alpha1.RetentionPolicyEntry{
+				{Retention: backupv1alpha1.RetentionPolicy{KeepLast: &keep}},
+				{Retention: backupv1alpha1.RetentionPolicy{KeepWithin: "30d"}, Schedule: "0 5 * * 0"},
+			},
+		},
+	}
+	if _, err := v.ValidateCreate(context.Background(), policy); err != nil {
+		t.Fatalf("expected a valid policy to be admitted, got %v", err)
+	}
+
+	policy.Spec.Policies[1].Schedule = "every sunday"
+	policy.Spec.Policies[0].Retention.KeepLast = nil
+	_, err := v.ValidateCreate(context.Background(), policy)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an invalid policy to be rejected, got %v", err)
+	}
+	if causes := err.(apierrors.APIStatus).Status().Details.Causes; len(causes) != 2 {
+		t.Errorf("expected the entry schedule and retention to be reported, got %v", causes)
+	}
+}
+
+func TestResticCheckValidate(t *testing.T) {
+	v := &ResticCheckCustomValidator{}
+	check := &backupv1alpha1.ResticCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "check", Namespace: "default"},
+		Spec:       backupv1alpha1.ResticCheckSpec{Schedule: "0 6 * * 0", Timezone: "UTC"},
+	}
+	if _, err := v.ValidateCreate(context.Background(), check); err != nil {
+		t.Fatalf("expected a valid check to be admitted, got %v", err)
+	}
+
+	updated := check.DeepCopy()
+	updated.Spec.Schedule = "0 6 * * 8"
+	if _, err := v.ValidateUpdate(context.Background(), check, updated); !apierrors.IsInvalid(err) {
+		t.Errorf("expected an invalid schedule to be rejected, got %v", err)
+	}
+}
+
+func TestResticPruneValidate(t *testing.T) {
+	v := &ResticPruneCustomValidator{}
+	prune := &backupv1alpha1.ResticPrune{
+		ObjectMeta: metav1.ObjectMeta{Name: "prune", Namespace: "default"},
+		Spec: backupv1alpha1.ResticPruneSpec{
+			JobConfig: &backupv1alpha1.JobConfiguration{Sidecars: []corev1.Container{{Name: "egress-proxy"}}},
+		},
+	}
+	if _, err := v.ValidateCreate(context.Background(), prune); err != nil {
+		t.Fatalf("expected a valid prune to be admitted, got %v", err)
+	}
+
+	updated := prune.DeepCopy()
+	updated.Spec.JobConfig.Sidecars[0].Name = "restic"
+	_, err := v.ValidateUpdate(context.Background(), prune, updated)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.jobConfig.sidecars") {
+		t.Errorf("expected a sidecar named restic to be rejected, got %v", err)
+	}
+}
+
+func TestResticReplicationValidateCreate(t *testing.T) {
+	v := &ResticReplicationCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceReject,
+		newRepository("default", "primary"), newRepository("default", "offsite"))}
+	replication := &backupv1alpha1.ResticReplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "replication", Namespace: "default"},
+		Spec: backupv1alpha1.ResticReplicationSpec{
+			SourceRepositoryRef:      backupv1alpha1.CrossNamespaceObjectReference{Name: "primary"},
+			DestinationRepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "offsite"},
+			Schedule:                 "0 5 * * *",
+		},
+	}
+	if _, err := v.ValidateCreate(context.Background(), replication); err != nil {
+		t.Fatalf("expected a valid replication to be admitted, got %v", err)
+	}
+
+	replication.Spec.DestinationRepositoryRef = backupv1alpha1.CrossNamespaceObjectReference{Name: "primary", Namespace: "default"}
+	if _, err := v.ValidateCreate(context.Background(), replication); !apierrors.IsInvalid(err) {
+		t.Errorf("expected replicating into the source repository to be rejected, got %v", err)
+	}
+
+	replication.Spec.DestinationRepositoryRef = backupv1alpha1.CrossNamespaceObjectReference{Name: "missing"}
+	if _, err := v.ValidateCreate(context.Background(), replication); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a missing destination repository to be rejected, got %v", err)
+	}
+}
+
+func TestBackupVerificationValidate(t *testing.T) {
+	v := &BackupVerificationCustomValidator{}
+	verification := &backupv1alpha1.BackupVerification{
+		ObjectMeta: metav1.ObjectMeta{Name: "verify", Namespace: "default"},
+		Spec:       backupv1alpha1.BackupVerificationSpec{BackupName: "backup", Schedule: "0 6 * * *"},
+	}
+	if _, err := v.ValidateCreate(context.Background(), verification); err != nil {
+		t.Fatalf("expected a valid verification to be admitted, got %v", err)
+	}
+
+	updated := verification.DeepCopy()
+	updated.Spec.Timezone = "Mars/Olympus"
+	if _, err := v.ValidateUpdate(context.Background(), verification, updated); !apierrors.IsInvalid(err) {
+		t.Errorf("expected an unknown timezone to be rejected, got %v", err)
+	}
+}
+
+func TestResticRepositoryValidate(t *testing.T) {
+	v := &ResticRepositoryCustomValidator{}
+	repository := newRepository("default", "repo")
+	repository.Spec.Cache = &backupv1alpha1.CacheConfig{CleanupSchedule: "@daily"}
+	repository.Spec.DefaultRetention = &backupv1alpha1.RetentionConfig{Enabled: true, Policy: &backupv1alpha1.RetentionPolicy{KeepWeekly: new(int32)}}
+
+	_, err := v.ValidateCreate(context.Background(), repository)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected a retention keeping nothing to be rejected, got %v", err)
+	}
+
+	repository.Spec.DefaultRetention = nil
+	repository.Spec.RepositoryURL = "s3:s3.amazonaws.com/bucket"
+	repository.Spec.SpaceCheck = &backupv1alpha1.SpaceCheckConfig{MinFreeSpace: resource.MustParse("10Gi")}
+	if _, err := v.ValidateCreate(context.Background(), repository); !apierrors.IsInvalid(err) {
+		t.Fatalf("expected a space check of an s3 backend without capacity to be rejected, got %v", err)
+	}
+	capacity := resource.MustParse("1Ti")
+	repository.Spec.SpaceCheck.Capacity = &capacity
+	if _, err := v.ValidateCreate(context.Background(), repository); err != nil {
+		t.Fatalf("expected a space check with capacity to be admitted, got %v", err)
+	}
+	repository.Spec.RepositoryURL = "sftp:backup@nas:/srv/restic"
+	repository.Spec.SpaceCheck.Capacity = nil
+	repository.Spec.CheckStrategy = backupv1alpha1.CheckStrategyInProcess
+	if _, err := v.ValidateCreate(context.Background(), repository); !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an sftp repository probed in the operator to be rejected, got %v", err)
+	}
+	repository.Spec.CheckStrategy = backupv1alpha1.CheckStrategyJob
+	if _, err := v.ValidateCreate(context.Background(), repository); err != nil {
+		t.Fatalf("expected a space check of an sftp backend to be admitted, got %v", err)
+	}
+
+	repository.Spec.DeletionPolicy = "Delete"
+	if _, err := v.ValidateCreate(context.Background(), repository); !apierrors.IsInvalid(err) {
+		t.Fatalf("expected deletionPolicy Delete without confirmation to be rejected, got %v", err)
+	}
+	repository.Spec.DeletionConfirmation = repository.Spec.RepositoryURL
+	if _, err := v.ValidateCreate(context.Background(), repository); err != nil {
+		t.Fatalf("expected a confirmed deletionPolicy Delete to be admitted, got %v", err)
+	}
+
+	repository.Spec.Intermittent = &backupv1alpha1.IntermittentConfig{Timezone: "Mars/Olympus"}
+	if _, err := v.ValidateCreate(context.Background(), repository); !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an unknown timezone of the online window to be rejected, got %v", err)
+	}
+	repository.Spec.Intermittent.Timezone = "Europe/Berlin"
+	if _, err := v.ValidateCreate(context.Background(), repository); err != nil {
+		t.Fatalf("expected an intermittent backend to be admitted, got %v", err)
+	}
+
+	now := metav1.Now()
+	repository.DeletionTimestamp = &now
+	if _, err := v.ValidateUpdate(context.Background(), repository, repository); err != nil {
+		t.Errorf("expected update of a deleted repository to be admitted, got %v", err)
+	}
+}
+
+func TestRepositoryTemplateValidate(t *testing.T) {
+	v := &RepositoryTemplateCustomValidator{}
+	template := &backupv1alpha1.RepositoryTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenants", Namespace: "backup-system"},
+		Spec: backupv1alpha1.RepositoryTemplateSpec{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"backup": "enabled"}},
+			Template: backupv1alpha1.RepositoryTemplateResource{
+				Spec: backupv1alpha1.ResticRepositorySpec{RepositoryURL: "s3:s3.amazonaws.com/backups/{{namespace}}"},
+			},
+		},
+	}
+	if _, err := v.ValidateCreate(context.Background(), template); err != nil {
+		t.Fatalf("expected a valid template to be admitted, got %v", err)
+	}
+
+	shared := template.DeepCopy()
+	shared.Spec.Template.Spec.RepositoryURL = "s3:s3.amazonaws.com/backups"
+	if _, err := v.ValidateUpdate(context.Background(), template, shared); !apierrors.IsInvalid(err) {
+		t.Errorf("expected a repository URL without the namespace placeholder to be rejected, got %v", err)
+	}
+
+	selector := template.DeepCopy()
+	selector.Spec.NamespaceSelector.MatchExpressions = []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Near"}}
+	if _, err := v.ValidateCreate(context.Background(), selector); !apierrors.IsInvalid(err) {
+		t.Errorf("expected an invalid namespace selector to be rejected, got %v", err)
+	}
+
+	schedule := template.DeepCopy()
+	schedule.Spec.Template.Spec.Cache = &backupv1alpha1.CacheConfig{CleanupSchedule: "every day"}
+	_, err := v.ValidateCreate(context.Background(), schedule)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.template.spec.cache.cleanupSchedule") {
+		t.Errorf("expected an invalid cleanup schedule of the template to be rejected, got %v", err)
+	}
+}
+
+func TestClusterBackupPolicyValidate(t *testing.T) {
+	v := &ClusterBackupPolicyCustomValidator{}
+	policy := &backupv1alpha1.ClusterBackupPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "labeled-pvcs"},
+		Spec: backupv1alpha1.ClusterBackupPolicySpec{
+			PVCSelector: metav1.LabelSelector{MatchLabels: map[string]string{"backup": "true"}},
+			Template: backupv1alpha1.ClusterBackupTemplate{
+				Spec: backupv1alpha1.ClusterBackupTemplateSpec{
+					RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "restic-repository"},
+					Schedule:      "0 2 * * *",
+				},
+			},
+		},
+	}
+	if _, err := v.ValidateCreate(context.Background(), policy); err != nil {
+		t.Fatalf("expected a valid policy to be admitted, got %v", err)
+	}
+
+	selector := policy.DeepCopy()
+	selector.Spec.NamespaceSelector.MatchExpressions = []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Near"}}
+	_, err := v.ValidateUpdate(context.Background(), policy, selector)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.namespaceSelector") {
+		t.Errorf("expected an invalid namespace selector to be rejected, got %v", err)
+	}
+
+	schedule := policy.DeepCopy()
+	schedule.Spec.Template.Spec.Schedule = "every day"
+	_, err = v.ValidateCreate(context.Background(), schedule)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.template.spec.schedule") {
+		t.Errorf("expected an invalid schedule of the template to be rejected, got %v", err)
+	}
+
+	timezone := policy.DeepCopy()
+	timezone.Spec.Template.Spec.Timezone = "Mars/Olympus"
+	_, err = v.ValidateCreate(context.Background(), timezone)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.template.spec.timezone") {
+		t.Errorf("expected an unknown timezone of the template to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+func newValidator(t *testing.T, policy string, objs ...client.Object) *ReferenceValidator {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := backupv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	return &ReferenceValidator{
+		Reader:                  fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		DanglingReferencePolicy: policy,
+	}
+}
+
+func newRepository(namespace, name string) *backupv1alpha1.ResticRepository {
+	return &backupv1alpha1.ResticRepository{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+}
+
+func newBackup(repository string) *backupv1alpha1.ResticBackup {
+	return &backupv1alpha1.ResticBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "default"},
+		Spec: backupv1alpha1.ResticBackupSpec{
+			RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: repository},
+		},
+	}
+}
+
+func TestResticBackupValidateCreate(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		repository   string
+		wantErr      bool
+		wantWarnings int
+	}{
+		{"existing repository", DanglingReferenceReject, "repo", false, 0},
+		{"missing repository rejected", DanglingReferenceReject, "typo", true, 0},
+		{"missing repository warned", DanglingReferenceWarn, "typo", false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &ResticBackupCustomValidator{ReferenceValidator: newValidator(t, tt.policy, newRepository("default", "repo"))}
+
+			warnings, err := v.ValidateCreate(context.Background(), newBackup(tt.repository))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !apierrors.IsInvalid(err) {
+				t.Errorf("expected an Invalid error, got %v", err)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("expected %d warnings, got %v", tt.wantWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestResticBackupValidateCreate_FallbackRepository(t *testing.T) {
+	v := &ResticBackupCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceReject, newRepository("default", "repo"))}
+	backup := newBackup("repo")
+	backup.Spec.FallbackRepositoryRef = &backupv1alpha1.CrossNamespaceObjectReference{Name: "missing"}
+
+	if _, err := v.ValidateCreate(context.Background(), backup); err == nil {
+		t.Error("expected the missing fallback repository to be rejected")
+	}
+}
+
+func TestResticBackupValidateCreate_CrossNamespace(t *testing.T) {
+	validator := newValidator(t, DanglingReferenceReject, newRepository("backup-system", "repo"))
+	backup := newBackup("repo")
+	backup.Spec.RepositoryRef.Namespace = "backup-system"
+
+	v := &ResticBackupCustomValidator{ReferenceValidator: validator}
+	if _, err := v.ValidateCreate(context.Background(), backup); err != nil {
+		t.Fatalf("expected cross-namespace reference to be admitted, got %v", err)
+	}
+
+	validator.DenyCrossNamespaceReferences = true
+	if _, err := v.ValidateCreate(context.Background(), backup); !apierrors.IsInvalid(err) {
+		t.Errorf("expected cross-namespace reference to be rejected, got %v", err)
+	}
+}
+
+func TestResticBackupValidateUpdate(t *testing.T) {
+	v := &ResticBackupCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceReject, newRepository("default", "repo"))}
+
+	// The repository was deleted after the backup was created
+	old := newBackup("deleted")
+	updated := old.DeepCopy()
+	updated.Spec.Schedule = "0 3 * * *"
+	if _, err := v.ValidateUpdate(context.Background(), old, updated); err != nil {
+		t.Errorf("expected unchanged reference to be admitted, got %v", err)
+	}
+
+	updated.Spec.RepositoryRef.Name = "typo"
+	if _, err := v.ValidateUpdate(context.Background(), old, updated); err == nil {
+		t.Error("expected changed reference to a missing repository to be rejected")
+	}
+
+	now := metav1.Now()
+	updated.DeletionTimestamp = &now
+	if _, err := v.ValidateUpdate(context.Background(), old, updated); err != nil {
+		t.Errorf("expected update of a deleted backup to be admitted, got %v", err)
+	}
+}
+
+func TestResticRestoreValidateCreate(t *testing.T) {
+	backup := newBackup("repo")
+	v := &ResticRestoreCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceReject, backup)}
+
+	restore := &backupv1alpha1.ResticRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "default"},
+		Spec: backupv1alpha1.ResticRestoreSpec{
+			BackupRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "backup"},
+			Target: backupv1alpha1.RestoreTarget{
+				PVC: &backupv1alpha1.PVCTarget{ClaimName: "data"},
+			},
+		},
+	}
+	if _, err := v.ValidateCreate(context.Background(), restore); err != nil {
+		t.Errorf("expected restore of an existing backup to be admitted, got %v", err)
+	}
+
+	restore.Spec.BackupRef.Name = "missing"
+	if _, err := v.ValidateCreate(context.Background(), restore); err == nil {
+		t.Error("expected restore of a missing backup to be rejected")
+	}
+}
+
+func TestGlobalRetentionPolicyValidateUpdate(t *testing.T) {
+	v := &GlobalRetentionPolicyCustomValidator{ReferenceValidator: newValidator(t, DanglingReferenceWarn)}
+
+	old := &backupv1alpha1.GlobalRetentionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+		Spec: backupv1alpha1.GlobalRetentionPolicySpec{
+			RepositoryRef: backupv1alpha1.CrossNamespaceObjectReference{Name: "repo", Namespace: "default"},
+		},
+	}
+	updated := old.DeepCopy()
+	updated.Spec.RepositoryRef.Name = "missing"
+
+	warnings, err := v.ValidateUpdate(context.Background(), old, updated)
+	if err != nil {
+		t.Fatalf("expected the Warn policy to admit the policy, got %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected one warning, got %v", warnings)
+	}
+}
+
+func TestParseDanglingReferencePolicy(t *testing.T) {
+	for _, value := range []string{DanglingReferenceReject, DanglingReferenceWarn} {
+		if _, err := ParseDanglingReferencePolicy(value); err != nil {
+			t.Errorf("expected %q to be valid, got %v", value, err)
+		}
+	}
+	if _, err := ParseDanglingReferencePolicy("Ignore"); err == nil {
+		t.Error("expected invalid policy to be rejected")
+	}
+}
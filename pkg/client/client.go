@@ -0,0 +1,150 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client provides typed clients and informers for the backup.resticbackup.io
+// v1alpha1 API, so that tools like portals, CLIs and policy engines can read and
+// write the resources of the operator without unstructured access. It wraps the
+// controller-runtime client and cache the operator uses itself.
+package client
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// NewScheme returns a scheme with the Kubernetes built-in types and the v1alpha1 types.
+func NewScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := backupv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
+
+// Clientset provides typed clients for the resources of the v1alpha1 API.
+type Clientset struct {
+	client ctrlclient.WithWatch
+}
+
+// NewForConfig creates a Clientset talking to the API server of the given config.
+func NewForConfig(config *rest.Config) (*Clientset, error) {
+	scheme, err := NewScheme()
+	if err != nil {
+		return nil, err
+	}
+	c, err := ctrlclient.NewWithWatch(config, ctrlclient.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	return NewForClient(c), nil
+}
+
+// NewForClient creates a Clientset using an existing controller-runtime client, e.g. a
+// fake client in tests. Its scheme must contain the v1alpha1 types.
+func NewForClient(c ctrlclient.WithWatch) *Clientset {
+	return &Clientset{client: c}
+}
+
+// Client returns the underlying controller-runtime client.
+func (c *Clientset) Client() ctrlclient.WithWatch {
+	return c.client
+}
+
+// BackupVerifications returns a client for the BackupVerifications in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) BackupVerifications(namespace string) *ResourceClient[*backupv1alpha1.BackupVerification, *backupv1alpha1.BackupVerificationList] {
+	return newResourceClient(c.client, namespace, newBackupVerification, newBackupVerificationList)
+}
+
+// ClusterBackupPolicies returns a client for the cluster-scoped ClusterBackupPolicies.
+func (c *Clientset) ClusterBackupPolicies() *ResourceClient[*backupv1alpha1.ClusterBackupPolicy, *backupv1alpha1.ClusterBackupPolicyList] {
+	return newResourceClient(c.client, "", newClusterBackupPolicy, newClusterBackupPolicyList)
+}
+
+// GlobalRetentionPolicies returns a client for the GlobalRetentionPolicies in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) GlobalRetentionPolicies(namespace string) *ResourceClient[*backupv1alpha1.GlobalRetentionPolicy, *backupv1alpha1.GlobalRetentionPolicyList] {
+	return newResourceClient(c.client, namespace, newGlobalRetentionPolicy, newGlobalRetentionPolicyList)
+}
+
+// NamespaceRestores returns a client for the NamespaceRestores in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) NamespaceRestores(namespace string) *ResourceClient[*backupv1alpha1.NamespaceRestore, *backupv1alpha1.NamespaceRestoreList] {
+	return newResourceClient(c.client, namespace, newNamespaceRestore, newNamespaceRestoreList)
+}
+
+// RepositoryTemplates returns a client for the RepositoryTemplates in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) RepositoryTemplates(namespace string) *ResourceClient[*backupv1alpha1.RepositoryTemplate, *backupv1alpha1.RepositoryTemplateList] {
+	return newResourceClient(c.client, namespace, newRepositoryTemplate, newRepositoryTemplateList)
+}
+
+// ResticBackups returns a client for the ResticBackups in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) ResticBackups(namespace string) *ResourceClient[*backupv1alpha1.ResticBackup, *backupv1alpha1.ResticBackupList] {
+	return newResourceClient(c.client, namespace, newResticBackup, newResticBackupList)
+}
+
+// ResticChecks returns a client for the ResticChecks in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) ResticChecks(namespace string) *ResourceClient[*backupv1alpha1.ResticCheck, *backupv1alpha1.ResticCheckList] {
+	return newResourceClient(c.client, namespace, newResticCheck, newResticCheckList)
+}
+
+// ResticPrunes returns a client for the ResticPrunes in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) ResticPrunes(namespace string) *ResourceClient[*backupv1alpha1.ResticPrune, *backupv1alpha1.ResticPruneList] {
+	return newResourceClient(c.client, namespace, newResticPrune, newResticPruneList)
+}
+
+// ResticReferenceGrants returns a client for the ResticReferenceGrants in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) ResticReferenceGrants(namespace string) *ResourceClient[*backupv1alpha1.ResticReferenceGrant, *backupv1alpha1.ResticReferenceGrantList] {
+	return newResourceClient(c.client, namespace, newResticReferenceGrant, newResticReferenceGrantList)
+}
+
+// ResticReplications returns a client for the ResticReplications in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) ResticReplications(namespace string) *ResourceClient[*backupv1alpha1.ResticReplication, *backupv1alpha1.ResticReplicationList] {
+	return newResourceClient(c.client, namespace, newResticReplication, newResticReplicationList)
+}
+
+// ResticRepositories returns a client for the ResticRepositories in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) ResticRepositories(namespace string) *ResourceClient[*backupv1alpha1.ResticRepository, *backupv1alpha1.ResticRepositoryList] {
+	return newResourceClient(c.client, namespace, newResticRepository, newResticRepositoryList)
+}
+
+// ResticRestores returns a client for the ResticRestores in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) ResticRestores(namespace string) *ResourceClient[*backupv1alpha1.ResticRestore, *backupv1alpha1.ResticRestoreList] {
+	return newResourceClient(c.client, namespace, newResticRestore, newResticRestoreList)
+}
+
+// ResticSnapshotRefs returns a client for the ResticSnapshotRefs in a namespace.
+// An empty namespace lists and watches all namespaces.
+func (c *Clientset) ResticSnapshotRefs(namespace string) *ResourceClient[*backupv1alpha1.ResticSnapshotRef, *backupv1alpha1.ResticSnapshotRefList] {
+	return newResourceClient(c.client, namespace, newResticSnapshotRef, newResticSnapshotRefList)
+}
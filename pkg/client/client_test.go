@@ -0,0 +1,131 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+func newTestClientset(t *testing.T, objects ...ctrlclient.Object) *Clientset {
+	t.Helper()
+	scheme, err := NewScheme()
+	if err != nil {
+		t.Fatalf("NewScheme() error = %v", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+		WithStatusSubresource(&backupv1alpha1.ResticBackup{}).Build()
+	return NewForClient(c)
+}
+
+func TestResourceClient(t *testing.T) {
+	ctx := context.Background()
+	clientset := newTestClientset(t, &backupv1alpha1.ResticBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "other"},
+	})
+	backups := clientset.ResticBackups("media")
+
+	backup := &backupv1alpha1.ResticBackup{ObjectMeta: metav1.ObjectMeta{Name: "db"}}
+	if err := backups.Create(ctx, backup); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if backup.Namespace != "media" {
+		t.Errorf("Create() namespace = %q, want media", backup.Namespace)
+	}
+
+	backup.Status.SnapshotsAfterRetention = 7
+	if err := backups.UpdateStatus(ctx, backup); err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+	got, err := backups.Get(ctx, "db")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Status.SnapshotsAfterRetention != 7 {
+		t.Errorf("Get() snapshotsAfterRetention = %d, want 7", got.Status.SnapshotsAfterRetention)
+	}
+
+	list, err := backups.List(ctx)
+	if err != nil || len(list.Items) != 1 {
+		t.Fatalf("List() = %v, %v, want the backup in media", list, err)
+	}
+	all, err := clientset.ResticBackups("").List(ctx)
+	if err != nil || len(all.Items) != 2 {
+		t.Fatalf("List() of all namespaces = %v, %v, want 2 backups", all, err)
+	}
+
+	if err := backups.Delete(ctx, "db"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := backups.Get(ctx, "db"); err == nil {
+		t.Error("Get() after Delete() succeeded")
+	}
+}
+
+func TestResourceClientWatch(t *testing.T) {
+	ctx := context.Background()
+	repositories := newTestClientset(t).ResticRepositories("media")
+
+	watcher, err := repositories.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer watcher.Stop()
+
+	repository := &backupv1alpha1.ResticRepository{ObjectMeta: metav1.ObjectMeta{Name: "nas"}}
+	if err := repositories.Create(ctx, repository); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	event := <-watcher.ResultChan()
+	if event.Type != watch.Added || event.Object.(*backupv1alpha1.ResticRepository).Name != "nas" {
+		t.Errorf("Watch() event = %s %v, want the added repository", event.Type, event.Object)
+	}
+}
+
+func TestInformer(t *testing.T) {
+	ctx := context.Background()
+	scheme, err := NewScheme()
+	if err != nil {
+		t.Fatalf("NewScheme() error = %v", err)
+	}
+	informers := &informertest.FakeInformers{Scheme: scheme}
+	factory := NewInformerFactoryForCache(informers)
+
+	added := 0
+	if _, err := factory.ResticRestores().AddEventHandler(ctx, toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(any) { added++ },
+	}); err != nil {
+		t.Fatalf("AddEventHandler() error = %v", err)
+	}
+	informer, err := informers.FakeInformerFor(ctx, &backupv1alpha1.ResticRestore{})
+	if err != nil {
+		t.Fatalf("FakeInformerFor() error = %v", err)
+	}
+	informer.Add(&backupv1alpha1.ResticRestore{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "media"}})
+	if added != 1 {
+		t.Errorf("AddFunc called %d times, want 1", added)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// InformerFactory provides shared informers for the resources of the v1alpha1 API.
+// Informers are created on first use and run once the factory is started.
+type InformerFactory struct {
+	cache cache.Cache
+}
+
+// NewInformerFactory creates an InformerFactory watching the API server of the given
+// config. options.DefaultNamespaces restricts the informers to namespaces. The
+// scheme defaults to NewScheme.
+func NewInformerFactory(config *rest.Config, options cache.Options) (*InformerFactory, error) {
+	if options.Scheme == nil {
+		scheme, err := NewScheme()
+		if err != nil {
+			return nil, err
+		}
+		options.Scheme = scheme
+	}
+	c, err := cache.New(config, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache: %w", err)
+	}
+	return NewInformerFactoryForCache(c), nil
+}
+
+// NewInformerFactoryForCache creates an InformerFactory using an existing cache, e.g.
+// the cache of a controller-runtime manager.
+func NewInformerFactoryForCache(c cache.Cache) *InformerFactory {
+	return &InformerFactory{cache: c}
+}
+
+// Start runs the informers until ctx is done. It blocks.
+func (f *InformerFactory) Start(ctx context.Context) error {
+	return f.cache.Start(ctx)
+}
+
+// WaitForCacheSync waits until the informers are synced and reports whether they are.
+func (f *InformerFactory) WaitForCacheSync(ctx context.Context) bool {
+	return f.cache.WaitForCacheSync(ctx)
+}
+
+// Cache returns the underlying controller-runtime cache.
+func (f *InformerFactory) Cache() cache.Cache {
+	return f.cache
+}
+
+// Informer notifies about changes of the objects of one resource and lists them from
+// its local cache. T is the object type and L its list type.
+type Informer[T ctrlclient.Object, L ctrlclient.ObjectList] struct {
+	cache     cache.Cache
+	newObject func() T
+	newList   func() L
+}
+
+func newInformer[T ctrlclient.Object, L ctrlclient.ObjectList](c cache.Cache, newObject func() T, newList func() L) *Informer[T, L] {
+	return &Informer[T, L]{cache: c, newObject: newObject, newList: newList}
+}
+
+// AddEventHandler registers handler for added, updated and deleted objects.
+func (i *Informer[T, L]) AddEventHandler(ctx context.Context, handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error) {
+	informer, err := i.cache.GetInformer(ctx, i.newObject())
+	if err != nil {
+		return nil, err
+	}
+	return informer.AddEventHandler(handler)
+}
+
+// Get returns the object with the given namespace and name from the cache.
+func (i *Informer[T, L]) Get(ctx context.Context, namespace, name string) (T, error) {
+	obj := i.newObject()
+	err := i.cache.Get(ctx, ctrlclient.ObjectKey{Namespace: namespace, Name: name}, obj)
+	return obj, err
+}
+
+// List returns the objects from the cache, e.g. filtered with client.InNamespace.
+func (i *Informer[T, L]) List(ctx context.Context, opts ...ctrlclient.ListOption) (L, error) {
+	list := i.newList()
+	err := i.cache.List(ctx, list, opts...)
+	return list, err
+}
+
+// BackupVerifications returns the informer of the BackupVerifications.
+func (f *InformerFactory) BackupVerifications() *Informer[*backupv1alpha1.BackupVerification, *backupv1alpha1.BackupVerificationList] {
+	return newInformer(f.cache, newBackupVerification, newBackupVerificationList)
+}
+
+// ClusterBackupPolicies returns the informer of the ClusterBackupPolicies.
+func (f *InformerFactory) ClusterBackupPolicies() *Informer[*backupv1alpha1.ClusterBackupPolicy, *backupv1alpha1.ClusterBackupPolicyList] {
+	return newInformer(f.cache, newClusterBackupPolicy, newClusterBackupPolicyList)
+}
+
+// GlobalRetentionPolicies returns the informer of the GlobalRetentionPolicies.
+func (f *InformerFactory) GlobalRetentionPolicies() *Informer[*backupv1alpha1.GlobalRetentionPolicy, *backupv1alpha1.GlobalRetentionPolicyList] {
+	return newInformer(f.cache, newGlobalRetentionPolicy, newGlobalRetentionPolicyList)
+}
+
+// NamespaceRestores returns the informer of the NamespaceRestores.
+func (f *InformerFactory) NamespaceRestores() *Informer[*backupv1alpha1.NamespaceRestore, *backupv1alpha1.NamespaceRestoreList] {
+	return newInformer(f.cache, newNamespaceRestore, newNamespaceRestoreList)
+}
+
+// RepositoryTemplates returns the informer of the RepositoryTemplates.
+func (f *InformerFactory) RepositoryTemplates() *Informer[*backupv1alpha1.RepositoryTemplate, *backupv1alpha1.RepositoryTemplateList] {
+	return newInformer(f.cache, newRepositoryTemplate, newRepositoryTemplateList)
+}
+
+// ResticBackups returns the informer of the ResticBackups.
+func (f *InformerFactory) ResticBackups() *Informer[*backupv1alpha1.ResticBackup, *backupv1alpha1.ResticBackupList] {
+	return newInformer(f.cache, newResticBackup, newResticBackupList)
+}
+
+// ResticChecks returns the informer of the ResticChecks.
+func (f *InformerFactory) ResticChecks() *Informer[*backupv1alpha1.ResticCheck, *backupv1alpha1.ResticCheckList] {
+	return newInformer(f.cache, newResticCheck, newResticCheckList)
+}
+
+// ResticPrunes returns the informer of the ResticPrunes.
+func (f *InformerFactory) ResticPrunes() *Informer[*backupv1alpha1.ResticPrune, *backupv1alpha1.ResticPruneList] {
+	return newInformer(f.cache, newResticPrune, newResticPruneList)
+}
+
+// ResticReferenceGrants returns the informer of the ResticReferenceGrants.
+func (f *InformerFactory) ResticReferenceGrants() *Informer[*backupv1alpha1.ResticReferenceGrant, *backupv1alpha1.ResticReferenceGrantList] {
+	return newInformer(f.cache, newResticReferenceGrant, newResticReferenceGrantList)
+}
+
+// ResticReplications returns the informer of the ResticReplications.
+func (f *InformerFactory) ResticReplications() *Informer[*backupv1alpha1.ResticReplication, *backupv1alpha1.ResticReplicationList] {
+	return newInformer(f.cache, newResticReplication, newResticReplicationList)
+}
+
+// ResticRepositories returns the informer of the ResticRepositories.
+func (f *InformerFactory) ResticRepositories() *Informer[*backupv1alpha1.ResticRepository, *backupv1alpha1.ResticRepositoryList] {
+	return newInformer(f.cache, newResticRepository, newResticRepositoryList)
+}
+
+// ResticRestores returns the informer of the ResticRestores.
+func (f *InformerFactory) ResticRestores() *Informer[*backupv1alpha1.ResticRestore, *backupv1alpha1.ResticRestoreList] {
+	return newInformer(f.cache, newResticRestore, newResticRestoreList)
+}
+
+// ResticSnapshotRefs returns the informer of the ResticSnapshotRefs.
+func (f *InformerFactory) ResticSnapshotRefs() *Informer[*backupv1alpha1.ResticSnapshotRef, *backupv1alpha1.ResticSnapshotRefList] {
+	return newInformer(f.cache, newResticSnapshotRef, newResticSnapshotRefList)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/watch"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResourceClient reads and writes the objects of one resource in one namespace.
+// T is the object type and L its list type, e.g. *v1alpha1.ResticBackup and
+// *v1alpha1.ResticBackupList.
+type ResourceClient[T ctrlclient.Object, L ctrlclient.ObjectList] struct {
+	client    ctrlclient.WithWatch
+	namespace string
+	newObject func() T
+	newList   func() L
+}
+
+func newResourceClient[T ctrlclient.Object, L ctrlclient.ObjectList](c ctrlclient.WithWatch, namespace string, newObject func() T, newList func() L) *ResourceClient[T, L] {
+	return &ResourceClient[T, L]{client: c, namespace: namespace, newObject: newObject, newList: newList}
+}
+
+// Get returns the object with the given name.
+func (r *ResourceClient[T, L]) Get(ctx context.Context, name string) (T, error) {
+	obj := r.newObject()
+	err := r.client.Get(ctx, ctrlclient.ObjectKey{Namespace: r.namespace, Name: name}, obj)
+	return obj, err
+}
+
+// List returns the objects in the namespace of the client, e.g. filtered with
+// client.MatchingLabels.
+func (r *ResourceClient[T, L]) List(ctx context.Context, opts ...ctrlclient.ListOption) (L, error) {
+	list := r.newList()
+	err := r.client.List(ctx, list, append([]ctrlclient.ListOption{ctrlclient.InNamespace(r.namespace)}, opts...)...)
+	return list, err
+}
+
+// Watch watches the objects in the namespace of the client.
+func (r *ResourceClient[T, L]) Watch(ctx context.Context, opts ...ctrlclient.ListOption) (watch.Interface, error) {
+	return r.client.Watch(ctx, r.newList(), append([]ctrlclient.ListOption{ctrlclient.InNamespace(r.namespace)}, opts...)...)
+}
+
+// Create creates obj in the namespace of the client if obj has no namespace.
+func (r *ResourceClient[T, L]) Create(ctx context.Context, obj T, opts ...ctrlclient.CreateOption) error {
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(r.namespace)
+	}
+	return r.client.Create(ctx, obj, opts...)
+}
+
+// Update updates the spec and metadata of obj.
+func (r *ResourceClient[T, L]) Update(ctx context.Context, obj T, opts ...ctrlclient.UpdateOption) error {
+	return r.client.Update(ctx, obj, opts...)
+}
+
+// UpdateStatus updates the status of obj.
+func (r *ResourceClient[T, L]) UpdateStatus(ctx context.Context, obj T, opts ...ctrlclient.SubResourceUpdateOption) error {
+	return r.client.Status().Update(ctx, obj, opts...)
+}
+
+// Patch patches obj, e.g. with client.MergeFrom.
+func (r *ResourceClient[T, L]) Patch(ctx context.Context, obj T, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+	return r.client.Patch(ctx, obj, patch, opts...)
+}
+
+// Delete deletes the object with the given name.
+func (r *ResourceClient[T, L]) Delete(ctx context.Context, name string, opts ...ctrlclient.DeleteOption) error {
+	obj := r.newObject()
+	obj.SetNamespace(r.namespace)
+	obj.SetName(name)
+	return r.client.Delete(ctx, obj, opts...)
+}
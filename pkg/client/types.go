@@ -0,0 +1,127 @@
+/*
+Copyright 2024 madic-creates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	backupv1alpha1 "github.com/madic-creates/restic-backup-operator/api/v1alpha1"
+)
+
+// Constructors of the v1alpha1 objects and lists used by the typed clients and informers.
+
+func newBackupVerification() *backupv1alpha1.BackupVerification {
+	return &backupv1alpha1.BackupVerification{}
+}
+
+func newBackupVerificationList() *backupv1alpha1.BackupVerificationList {
+	return &backupv1alpha1.BackupVerificationList{}
+}
+
+func newClusterBackupPolicy() *backupv1alpha1.ClusterBackupPolicy {
+	return &backupv1alpha1.ClusterBackupPolicy{}
+}
+
+func newClusterBackupPolicyList() *backupv1alpha1.ClusterBackupPolicyList {
+	return &backupv1alpha1.ClusterBackupPolicyList{}
+}
+
+func newGlobalRetentionPolicy() *backupv1alpha1.GlobalRetentionPolicy {
+	return &backupv1alpha1.GlobalRetentionPolicy{}
+}
+
+func newGlobalRetentionPolicyList() *backupv1alpha1.GlobalRetentionPolicyList {
+	return &backupv1alpha1.GlobalRetentionPolicyList{}
+}
+
+func newNamespaceRestore() *backupv1alpha1.NamespaceRestore {
+	return &backupv1alpha1.NamespaceRestore{}
+}
+
+func newNamespaceRestoreList() *backupv1alpha1.NamespaceRestoreList {
+	return &backupv1alpha1.NamespaceRestoreList{}
+}
+
+func newRepositoryTemplate() *backupv1alpha1.RepositoryTemplate {
+	return &backupv1alpha1.RepositoryTemplate{}
+}
+
+func newRepositoryTemplateList() *backupv1alpha1.RepositoryTemplateList {
+	return &backupv1alpha1.RepositoryTemplateList{}
+}
+
+func newResticBackup() *backupv1alpha1.ResticBackup {
+	return &backupv1alpha1.ResticBackup{}
+}
+
+func newResticBackupList() *backupv1alpha1.ResticBackupList {
+	return &backupv1alpha1.ResticBackupList{}
+}
+
+func newResticCheck() *backupv1alpha1.ResticCheck {
+	return &backupv1alpha1.ResticCheck{}
+}
+
+func newResticCheckList() *backupv1alpha1.ResticCheckList {
+	return &backupv1alpha1.ResticCheckList{}
+}
+
+func newResticPrune() *backupv1alpha1.ResticPrune {
+	return &backupv1alpha1.ResticPrune{}
+}
+
+func newResticPruneList() *backupv1alpha1.ResticPruneList {
+	return &backupv1alpha1.ResticPruneList{}
+}
+
+func newResticReferenceGrant() *backupv1alpha1.ResticReferenceGrant {
+	return &backupv1alpha1.ResticReferenceGrant{}
+}
+
+func newResticReferenceGrantList() *backupv1alpha1.ResticReferenceGrantList {
+	return &backupv1alpha1.ResticReferenceGrantList{}
+}
+
+func newResticReplication() *backupv1alpha1.ResticReplication {
+	return &backupv1alpha1.ResticReplication{}
+}
+
+func newResticReplicationList() *backupv1alpha1.ResticReplicationList {
+	return &backupv1alpha1.ResticReplicationList{}
+}
+
+func newResticRepository() *backupv1alpha1.ResticRepository {
+	return &backupv1alpha1.ResticRepository{}
+}
+
+func newResticRepositoryList() *backupv1alpha1.ResticRepositoryList {
+	return &backupv1alpha1.ResticRepositoryList{}
+}
+
+func newResticRestore() *backupv1alpha1.ResticRestore {
+	return &backupv1alpha1.ResticRestore{}
+}
+
+func newResticRestoreList() *backupv1alpha1.ResticRestoreList {
+	return &backupv1alpha1.ResticRestoreList{}
+}
+
+func newResticSnapshotRef() *backupv1alpha1.ResticSnapshotRef {
+	return &backupv1alpha1.ResticSnapshotRef{}
+}
+
+func newResticSnapshotRefList() *backupv1alpha1.ResticSnapshotRefList {
+	return &backupv1alpha1.ResticSnapshotRefList{}
+}